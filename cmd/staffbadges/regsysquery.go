@@ -216,9 +216,31 @@ func lookupBadgeNumber(identity string, baseUrl string, token string, jwt string
 	return result.Ids[0], nil
 }
 
+const findPageSize = 1000
+
+// findAttendees walks through all pages of search results, so each request stays well below the server timeout.
 func findAttendees(reqBody attendee.AttendeeSearchCriteria, baseUrl string, token string, jwt string) (attendee.AttendeeSearchResultList, error) {
 	result := attendee.AttendeeSearchResultList{}
 
+	reqBody.NumResults = findPageSize
+	for {
+		page, err := findAttendeesPage(reqBody, baseUrl, token, jwt)
+		if err != nil {
+			return result, err
+		}
+
+		result.Attendees = append(result.Attendees, page.Attendees...)
+		result.Total = page.Total
+		if page.Next == "" {
+			return result, nil
+		}
+		reqBody.Cursor = page.Next
+	}
+}
+
+func findAttendeesPage(reqBody attendee.AttendeeSearchCriteria, baseUrl string, token string, jwt string) (attendee.AttendeeSearchResultList, error) {
+	result := attendee.AttendeeSearchResultList{}
+
 	reqBodyBytes, err := json.Marshal(reqBody)
 	if err != nil {
 		return result, err
@@ -28,6 +28,7 @@ type AttendeeDto struct {
 	TshirtSize           string `json:"tshirt_size"`
 	SpokenLanguages      string `json:"spoken_languages"`      // configurable subset of configured language codes, comma separated (de,en)
 	RegistrationLanguage string `json:"registration_language"` // one out of configurable subset of RFC 5646 locales (default en-US)
+	Currency             string `json:"currency"`              // billing currency, one of the configured currencies, can only be chosen at registration (default is the configured currency)
 
 	// comma separated lists, allowed choices are convention dependent
 	Flags        string         `json:"flags"`    // hc,anon,ev
@@ -50,6 +51,44 @@ type AttendeeIdList struct {
 type DueDate struct {
 	// The due date as an ISO date. Note that this is an accounting date, which does not have a time or a time zone.
 	DueDate string `json:"due_date"`
+	// Read only. Only set if a package with a payment plan was booked.
+	Installments []Installment `json:"installments,omitempty"`
+}
+
+type Installment struct {
+	DueDate string `json:"due_date"`
+	Amount  int64  `json:"amount"` // in cents
+	Open    int64  `json:"open"`   // the part of the amount not yet covered by payments, in cents
+}
+
+// Invoice is a snapshot of the dues of an attendee, issued whenever they change.
+type Invoice struct {
+	Number       string        `json:"number"`    // the configured prefix followed by a sequential number
+	IssuedOn     string        `json:"issued_on"` // ISO date
+	Recipient    []string      `json:"recipient"` // name and address at the time of issue, one line each
+	Currency     string        `json:"currency"`
+	Items        []InvoiceItem `json:"items"`
+	VatBreakdown []InvoiceVat  `json:"vat_breakdown"` // by vat rate, in ascending order
+	Total        int64         `json:"total"`         // in cents, including vat
+	Superseded   bool          `json:"superseded"`    // a later invoice for the attendee replaces this one
+}
+
+type InvoiceItem struct {
+	Description string  `json:"description"`
+	Count       int     `json:"count"`
+	Amount      int64   `json:"amount"` // in cents, including vat, for all of count
+	VatPercent  float64 `json:"vat_percent"`
+}
+
+type InvoiceVat struct {
+	VatPercent float64 `json:"vat_percent"`
+	Net        int64   `json:"net"`   // in cents
+	Vat        int64   `json:"vat"`   // in cents
+	Gross      int64   `json:"gross"` // in cents
+}
+
+type InvoiceList struct {
+	Invoices []Invoice `json:"invoices"` // oldest first
 }
 
 // --- search criteria ---
@@ -62,6 +101,7 @@ type AttendeeSearchCriteria struct {
 	FillFields []string                        `json:"fill_fields"`
 	SortBy     string                          `json:"sort_by"`
 	SortOrder  string                          `json:"sort_order"`
+	Cursor     string                          `json:"cursor,omitempty"` // continue after the previous page, use the value of next from its result
 }
 
 type AttendeeSearchSingleCriterion struct {
@@ -85,12 +125,17 @@ type AttendeeSearchSingleCriterion struct {
 	AdminComments        string          `json:"admin_comments"`
 	AddInfo              map[string]int8 `json:"add_info"` // can only search for presence of a value for each area, Note: special area 'overdue'
 	IdentitySubjects     []string        `json:"identity_subjects"`
+	GroupIds             []uint          `json:"group_ids,omitempty"`     // members of any of these groups, open invitations do not count
+	VoucherCodes         []string        `json:"voucher_codes,omitempty"` // attendees who have redeemed any of these vouchers
+	Fuzzy                string          `json:"fuzzy,omitempty"`         // words matched against nickname, name and comments, tolerating typos and diacritics
 }
 
 // --- search result ---
 
 type AttendeeSearchResultList struct {
 	Attendees []AttendeeSearchResult `json:"attendees"`
+	Total     *int64                 `json:"total,omitempty"` // only set for paged searches, the number of matches on all pages
+	Next      string                 `json:"next,omitempty"`  // only set if there are more results, pass this as cursor to get the next page
 }
 
 type AttendeeSearchResult struct {
@@ -123,6 +168,7 @@ type AttendeeSearchResult struct {
 	PackagesList         []PackageState `json:"packages_list,omitempty"`
 	UserComments         *string        `json:"user_comments,omitempty"`
 	Status               *status.Status `json:"status,omitempty"`
+	Currency             *string        `json:"currency,omitempty"`
 	TotalDues            *int64         `json:"total_dues,omitempty"`
 	PaymentBalance       *int64         `json:"payment_balance,omitempty"`
 	CurrentDues          *int64         `json:"current_dues,omitempty"`
@@ -131,6 +177,8 @@ type AttendeeSearchResult struct {
 	AdminComments        *string        `json:"admin_comments,omitempty"`
 	IdentitySubject      *string        `json:"identity_subject"`
 	Avatar               *string        `json:"avatar"`
+	GroupId              *uint          `json:"group_id,omitempty"`  // only set for group members
+	Relevance            *float64       `json:"relevance,omitempty"` // only set for fuzzy searches, 1 is a perfect match
 }
 
 // --- flags/options/packages result ---
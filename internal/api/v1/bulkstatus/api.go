@@ -0,0 +1,36 @@
+package bulkstatus
+
+import (
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+)
+
+type BulkStatusChangeDto struct {
+	// exactly one of ids or criteria must be given
+	Ids      []uint                           `json:"ids,omitempty"`
+	Criteria *attendee.AttendeeSearchCriteria `json:"criteria,omitempty"`
+
+	Status  status.Status `json:"status"`
+	Comment string        `json:"comment"`
+
+	// only check which status changes would be possible, do not change anything
+	DryRun bool `json:"dry_run"`
+}
+
+type BulkStatusChangeResultList struct {
+	DryRun    bool                     `json:"dry_run"`
+	Succeeded int                      `json:"succeeded"`
+	Failed    int                      `json:"failed"`
+	Results   []BulkStatusChangeResult `json:"results"` // in the order of the ids, or the search result
+}
+
+type BulkStatusChangeResult struct {
+	Id        uint          `json:"id"`
+	OldStatus status.Status `json:"old_status,omitempty"`
+	// the status after the change, which may differ from the requested status, e.g. approved can
+	// advance to paid if nothing is due. In a dry run, this is the requested status.
+	NewStatus status.Status `json:"new_status,omitempty"`
+	Success   bool          `json:"success"`
+	Error     string        `json:"error,omitempty"` // the error message that the single status change would return, e.g. status.ban.match
+	Details   string        `json:"details,omitempty"`
+}
@@ -0,0 +1,36 @@
+package duplicates
+
+// reasons why two registrations are considered likely duplicates
+const (
+	ReasonSameIdentity           = "same_identity"
+	ReasonSamePhone              = "same_phone"
+	ReasonSimilarNameAndBirthday = "similar_name_and_birthday"
+)
+
+type DuplicateList struct {
+	Duplicates []Duplicate `json:"duplicates"` // best candidates first
+}
+
+type Duplicate struct {
+	Ids     []uint   `json:"ids"`     // always two ids, the lower one first
+	Score   float64  `json:"score"`   // how likely this is a duplicate, between 0 and 1
+	Reasons []string `json:"reasons"` // see the Reason constants
+}
+
+type MergeDto struct {
+	// the registration to merge into the one in the path. It is cancelled after the merge.
+	DuplicateId uint   `json:"duplicate_id"`
+	Comment     string `json:"comment"`
+}
+
+type MergeResult struct {
+	SurvivingId uint `json:"surviving_id"`
+	MergedId    uint `json:"merged_id"`
+	// the areas of additional info that were moved to the surviving registration
+	MovedAdditionalInfo []string `json:"moved_additional_info"`
+	// the areas of additional info that were left on the merged registration, because the
+	// surviving registration already had a value for them
+	ConflictingAdditionalInfo []string `json:"conflicting_additional_info"`
+	// the number of status history entries moved to the surviving registration
+	StatusHistoryEntries int `json:"status_history_entries"`
+}
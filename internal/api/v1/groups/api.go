@@ -0,0 +1,22 @@
+package groups
+
+type GroupCreate struct {
+	Name string `json:"name"`
+}
+
+type Group struct {
+	Id      uint          `json:"id"`
+	Name    string        `json:"name"`
+	Owner   uint          `json:"owner"`   // badge number of the attendee who created the group
+	Members []GroupMember `json:"members"` // includes open invitations
+}
+
+type GroupMember struct {
+	Id       uint   `json:"id"` // badge number
+	Nickname string `json:"nickname"`
+	Joined   bool   `json:"joined"` // false while the invitation has not been accepted
+}
+
+type GroupList struct {
+	Groups []Group `json:"groups"`
+}
@@ -0,0 +1,24 @@
+package history
+
+// HistoryList is the chronological list of recorded changes to an attendee and their admin info.
+type HistoryList struct {
+	Entries []HistoryEntry `json:"entries"`
+}
+
+type HistoryEntry struct {
+	Id        uint          `json:"id"`
+	Timestamp string        `json:"timestamp"`
+	Entity    string        `json:"entity"`     // Attendee or AdminInfo
+	Identity  string        `json:"identity"`   // subject of the user who made the change, blank if made using the api token
+	RequestId string        `json:"request_id"` // request id of the change, to correlate with the logs
+	Changes   []FieldChange `json:"changes"`
+}
+
+// FieldChange is the change of a single entity field.
+//
+// Fields that are not historized, such as comments, never appear here.
+type FieldChange struct {
+	Field    string `json:"field"`
+	OldValue string `json:"old_value"`
+	NewValue string `json:"new_value"`
+}
@@ -0,0 +1,18 @@
+package outbox
+
+type OutboxMail struct {
+	Id          uint     `json:"id"`
+	AttendeeId  uint     `json:"attendee_id"`  // badge number
+	CommonID    string   `json:"cid"`          // mail template common id
+	Lang        string   `json:"lang"`         // mail template language
+	To          []string `json:"to"`           // recipients
+	State       string   `json:"state"`        // pending, sending, sent, failed
+	Attempts    int      `json:"attempts"`     // number of delivery attempts made so far
+	NextAttempt string   `json:"next_attempt"` // time of the next delivery attempt, only meaningful while pending
+	LastError   string   `json:"last_error"`   // error message from the last failed delivery attempt
+	Created     string   `json:"created"`      // time at which the mail was first queued
+}
+
+type OutboxMailList struct {
+	Mails []OutboxMail `json:"mails"`
+}
@@ -0,0 +1,14 @@
+package savedsearch
+
+import "github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+
+type SavedSearchDto struct {
+	Name        string                          `json:"name"`                  // must be empty or match the name in the path
+	Description string                          `json:"description,omitempty"` // shown to staff when listing the searches
+	Permissions string                          `json:"permissions,omitempty"` // comma separated list of admin info permissions that allow running this search
+	Criteria    attendee.AttendeeSearchCriteria `json:"criteria"`
+}
+
+type SavedSearchList struct {
+	Searches []SavedSearchDto `json:"searches"`
+}
@@ -0,0 +1,12 @@
+package transfer
+
+type TransferOfferDto struct {
+	// the email address of the recipient, who will receive the code needed to accept the transfer
+	Email string `json:"email"`
+}
+
+type TransferDto struct {
+	AttendeeId uint   `json:"attendee_id"`
+	Email      string `json:"email"`      // the email address the transfer was offered to
+	ExpiresAt  string `json:"expires_at"` // the transfer can only be accepted before this time (RFC 3339)
+}
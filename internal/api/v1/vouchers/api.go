@@ -0,0 +1,35 @@
+package vouchers
+
+type VoucherDto struct {
+	Code        string `json:"code"`                  // must be empty or match the code in the path, case is ignored
+	Description string `json:"description"`           // shown to attendees and in the transaction comment
+	Kind        string `json:"kind"`                  // percent or fixed
+	Amount      int64  `json:"amount"`                // percent (1-100) or fixed amount in cents
+	Currency    string `json:"currency,omitempty"`    // only for fixed amounts, one of the configured currencies, defaults to the configured currency. Only attendees billed in this currency can redeem the voucher.
+	Package     string `json:"package,omitempty"`     // if set, the discount only applies to this package
+	MaxUses     int    `json:"max_uses,omitempty"`    // how often the voucher can be redeemed in total, 0 means unlimited
+	ValidUntil  string `json:"valid_until,omitempty"` // last day the voucher can be redeemed, ISO date, empty means no expiry
+	Uses        int    `json:"uses"`                  // read only, how often the voucher has been redeemed
+}
+
+type VoucherList struct {
+	Vouchers []VoucherDto `json:"vouchers"`
+}
+
+type VoucherRedeem struct {
+	Code string `json:"code"`
+}
+
+// RedeemedVoucher is what the attendee gets to see about the vouchers they have redeemed.
+type RedeemedVoucher struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	Kind        string `json:"kind"`
+	Amount      int64  `json:"amount"`
+	Currency    string `json:"currency,omitempty"`
+	Package     string `json:"package,omitempty"`
+}
+
+type RedeemedVoucherList struct {
+	Vouchers []RedeemedVoucher `json:"vouchers"`
+}
@@ -0,0 +1,14 @@
+package waitlist
+
+type WaitlistEntry struct {
+	Position int    `json:"position"` // 1-based position in the queue
+	Id       uint   `json:"id"`       // badge number
+	Count    int    `json:"count"`    // how many of the package the attendee is waiting for
+	Since    string `json:"since"`    // time at which the attendee was queued
+}
+
+type Waitlist struct {
+	Package string          `json:"package"`
+	Length  int             `json:"length"` // total number of queued attendees, even if not all entries are visible to the caller
+	Entries []WaitlistEntry `json:"entries"`
+}
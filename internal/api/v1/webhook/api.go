@@ -0,0 +1,52 @@
+package webhook
+
+import "github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+
+const (
+	EventAttendeeRegistered       = "attendee.registered"
+	EventAttendeeUpdated          = "attendee.updated"
+	EventAttendeeStatusChanged    = "attendee.status_changed"
+	EventAttendeeAdminInfoChanged = "attendee.admininfo_changed"
+	EventAttendeeAddInfoChanged   = "attendee.addinfo_changed"
+	EventAttendeeGroupChanged     = "attendee.group_changed"
+)
+
+var AllEvents = []string{
+	EventAttendeeRegistered,
+	EventAttendeeUpdated,
+	EventAttendeeStatusChanged,
+	EventAttendeeAdminInfoChanged,
+	EventAttendeeAddInfoChanged,
+	EventAttendeeGroupChanged,
+}
+
+// Event is the body sent to webhook subscribers.
+//
+// Events deliberately do not contain personal data. Subscribers should use their api token to read
+// what they need.
+type Event struct {
+	Event      string        `json:"event"`
+	Timestamp  string        `json:"timestamp"`
+	AttendeeId uint          `json:"attendee_id"`                    // badge number
+	Status     status.Status `json:"status,omitempty"`               // new status, only for status changes
+	OldStatus  status.Status `json:"old_status,omitempty"`           // previous status, only for status changes
+	Area       string        `json:"additional_info_area,omitempty"` // only for additional info changes
+	GroupId    uint          `json:"group_id,omitempty"`             // the group joined or left, only for group changes
+}
+
+type Delivery struct {
+	Id          uint   `json:"id"`
+	Subscriber  string `json:"subscriber"`
+	Event       string `json:"event"`
+	AttendeeId  uint   `json:"attendee_id"`
+	State       string `json:"state"`        // pending, sent, failed
+	Attempts    int    `json:"attempts"`     // number of delivery attempts made so far
+	NextAttempt string `json:"next_attempt"` // time of the next delivery attempt, only meaningful while pending
+	LastStatus  int    `json:"last_status"`  // http status of the last delivery attempt, 0 if no response was received
+	LastError   string `json:"last_error"`   // error message from the last failed delivery attempt
+	Created     string `json:"created"`      // time at which the event was queued
+}
+
+type DeliveryList struct {
+	Deliveries []Delivery `json:"deliveries"`
+}
@@ -4,8 +4,8 @@ import "gorm.io/gorm"
 
 type Ban struct {
 	gorm.Model
-	Reason          string `gorm:"type:varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci"`
-	NamePattern     string `gorm:"type:varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci"`
-	NicknamePattern string `gorm:"type:varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci"`
-	EmailPattern    string `gorm:"type:varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci"`
+	Reason          string `gorm:"type:varchar(255)"`
+	NamePattern     string `gorm:"type:varchar(255)"`
+	NicknamePattern string `gorm:"type:varchar(255)"`
+	EmailPattern    string `gorm:"type:varchar(255)"`
 }
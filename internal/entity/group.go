@@ -0,0 +1,29 @@
+package entity
+
+import "gorm.io/gorm"
+
+// configured sizes count characters, not bytes (mysql since version 5, postgres always)
+
+// Group links registrations that want to be treated together, such as roommates.
+//
+// The owner is the attendee who created the group, and is always one of its members.
+type Group struct {
+	gorm.Model
+	Name    string `gorm:"type:varchar(80);NOT NULL"`
+	OwnerId uint   `gorm:"NOT NULL;index:att_groups_owner_idx"`
+}
+
+// GroupMember is an invitation of an attendee to a group, which makes them a member once accepted.
+//
+// An attendee can be invited to several groups, but can only be a member of one group at a time.
+type GroupMember struct {
+	gorm.Model
+	GroupId    uint   `gorm:"NOT NULL;uniqueIndex:att_group_members_uidx"`
+	AttendeeId uint   `gorm:"NOT NULL;uniqueIndex:att_group_members_uidx;index:att_group_members_attendee_idx"`
+	State      string `gorm:"type:varchar(32);NOT NULL"`
+}
+
+const (
+	GroupMemberStateInvited  = "invited"
+	GroupMemberStateAccepted = "accepted"
+)
@@ -0,0 +1,28 @@
+package entity
+
+import "gorm.io/gorm"
+
+// configured sizes count characters, not bytes (mysql since version 5, postgres always)
+
+// Invoice is a snapshot of the dues of an attendee, taken whenever they change.
+//
+// Invoices never change once issued. A later invoice for the same attendee supersedes the earlier ones.
+type Invoice struct {
+	gorm.Model
+	AttendeeId   uint   `gorm:"NOT NULL;index:att_invoices_attendee_idx"`
+	Number       uint   `gorm:"NOT NULL;uniqueIndex:att_invoices_number_uidx"` // sequential over all attendees, assigned by the repository
+	NumberPrefix string `gorm:"type:varchar(20);NOT NULL"`                     // the configured prefix at the time of issue
+	IssuedOn     string `gorm:"type:varchar(10);NOT NULL"`                     // ISO date
+	Currency     string `gorm:"type:varchar(3);NOT NULL"`
+	Recipient    string `gorm:"type:text"` // name and address at the time of issue, one line each
+	Items        string `gorm:"type:text"` // json encoded line items
+	Total        int64  `gorm:"NOT NULL"`  // in cents
+}
+
+// InvoiceNumber is the single row that holds the last invoice number issued.
+//
+// Invoice numbers are taken from it by an atomic update, so concurrent invoices never get the same number.
+type InvoiceNumber struct {
+	ID         uint `gorm:"primaryKey;autoIncrement:false"`
+	LastNumber uint `gorm:"NOT NULL"`
+}
@@ -0,0 +1,28 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// configured sizes count characters, not bytes (mysql since version 5, postgres always)
+
+// OutboxMail is a mail that has been queued for delivery to the mail service.
+type OutboxMail struct {
+	gorm.Model
+	AttendeeId  uint      `gorm:"NOT NULL;index:att_outbox_mails_attendee_idx"`
+	CommonID    string    `gorm:"type:varchar(80);NOT NULL"`
+	State       string    `gorm:"type:varchar(32);NOT NULL;index:att_outbox_mails_state_idx"`
+	Attempts    int       `gorm:"NOT NULL"`
+	NextAttempt time.Time `gorm:"NOT NULL"`
+	LastError   string    `gorm:"type:text"`
+	Payload     string    `gorm:"type:text;NOT NULL"` // json encoded mail send request
+}
+
+const (
+	OutboxStatePending = "pending" // waiting for (another) delivery attempt
+	OutboxStateSending = "sending" // a delivery attempt is in progress, NextAttempt is when it is considered lost
+	OutboxStateSent    = "sent"
+	OutboxStateFailed  = "failed" // gave up after too many attempts, needs admin attention
+)
@@ -0,0 +1,16 @@
+package entity
+
+import "gorm.io/gorm"
+
+// configured sizes count characters, not bytes (mysql since version 5, postgres always)
+
+// PackagePrice locks the price of a package with a price schedule at the time the attendee first booked it.
+//
+// The lock is kept even if the package is later removed, so booking it again does not change the price.
+type PackagePrice struct {
+	gorm.Model
+	AttendeeId uint   `gorm:"NOT NULL;uniqueIndex:att_package_prices_uidx"`
+	Package    string `gorm:"type:varchar(80);NOT NULL;uniqueIndex:att_package_prices_uidx"`
+	BookedOn   string `gorm:"type:varchar(10);NOT NULL"` // ISO date
+	Price      int64  `gorm:"NOT NULL"`
+}
@@ -0,0 +1,14 @@
+package entity
+
+import "gorm.io/gorm"
+
+// configured sizes count characters, not bytes (mysql since version 5, postgres always)
+
+// SavedSearch is a named set of attendee search criteria that admins store for repeated use.
+type SavedSearch struct {
+	gorm.Model
+	Name        string `gorm:"type:varchar(80);NOT NULL;uniqueIndex:att_saved_searches_name_uidx"`
+	Description string `gorm:"type:varchar(255)"`
+	Permissions string `gorm:"type:varchar(255)"`  // comma separated permissions that allow running this search, with wrapping commas
+	Criteria    string `gorm:"type:text;NOT NULL"` // json encoded attendee.AttendeeSearchCriteria
+}
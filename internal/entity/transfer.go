@@ -0,0 +1,29 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// configured sizes count characters, not bytes (mysql since version 5, postgres always)
+
+// Transfer is an offer by the owner of a registration to hand it over to another person.
+//
+// The code is mailed to the recipient, who must present it to accept the transfer.
+type Transfer struct {
+	gorm.Model
+	AttendeeId   uint      `gorm:"NOT NULL;index:att_transfers_attendee_idx"`
+	FromIdentity string    `gorm:"type:varchar(255);NOT NULL"`
+	ToEmail      string    `gorm:"type:varchar(200);NOT NULL"`
+	ToIdentity   string    `gorm:"type:varchar(255)"` // set once accepted
+	Code         string    `gorm:"type:varchar(64);NOT NULL;uniqueIndex:att_transfers_code_uidx"`
+	State        string    `gorm:"type:varchar(32);NOT NULL"`
+	ExpiresAt    time.Time `gorm:"NOT NULL"`
+}
+
+const (
+	TransferStatePending   = "pending"
+	TransferStateAccepted  = "accepted"
+	TransferStateCancelled = "cancelled" // withdrawn by the owner, or replaced by a newer offer
+)
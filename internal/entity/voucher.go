@@ -0,0 +1,34 @@
+package entity
+
+import "gorm.io/gorm"
+
+// configured sizes count characters, not bytes (mysql since version 5, postgres always)
+
+// Voucher is a discount code that attendees can redeem.
+//
+// The discount is either a percentage of the package prices, or a fixed amount, and can be limited to a single package.
+type Voucher struct {
+	gorm.Model
+	Code        string `gorm:"type:varchar(32);NOT NULL;uniqueIndex:att_vouchers_code_uidx"` // always upper case
+	Description string `gorm:"type:varchar(256);NOT NULL"`
+	Kind        string `gorm:"type:varchar(16);NOT NULL"`
+	Amount      int64  `gorm:"NOT NULL"`                  // percent for VoucherKindPercent, cents for VoucherKindFixed
+	Currency    string `gorm:"type:varchar(3)"`           // only for VoucherKindFixed, empty means the default currency
+	Package     string `gorm:"type:varchar(80);NOT NULL"` // empty means all packages
+	MaxUses     int    `gorm:"NOT NULL"`                  // 0 means unlimited
+	ValidUntil  string `gorm:"type:varchar(10);NOT NULL"` // ISO date, inclusive, empty means no expiry
+}
+
+// VoucherRedemption records that an attendee has redeemed a voucher.
+//
+// Each attendee can redeem each voucher at most once.
+type VoucherRedemption struct {
+	gorm.Model
+	VoucherId  uint `gorm:"NOT NULL;uniqueIndex:att_voucher_redemptions_uidx"`
+	AttendeeId uint `gorm:"NOT NULL;uniqueIndex:att_voucher_redemptions_uidx;index:att_voucher_redemptions_attendee_idx"`
+}
+
+const (
+	VoucherKindPercent = "percent"
+	VoucherKindFixed   = "fixed"
+)
@@ -0,0 +1,15 @@
+package entity
+
+import "gorm.io/gorm"
+
+// configured sizes count characters, not bytes (mysql since version 5, postgres always)
+
+// WaitlistEntry places an attendee in the waiting list for a limited package.
+//
+// The queue order is given by ascending ID.
+type WaitlistEntry struct {
+	gorm.Model
+	Package    string `gorm:"type:varchar(80);NOT NULL;uniqueIndex:att_waitlist_entries_uidx"`
+	AttendeeId uint   `gorm:"NOT NULL;uniqueIndex:att_waitlist_entries_uidx"`
+	Count      int    `gorm:"NOT NULL"` // how many of the package the attendee is waiting for
+}
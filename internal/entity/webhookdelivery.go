@@ -0,0 +1,41 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// configured sizes count characters, not bytes (mysql since version 5, postgres always)
+
+// WebhookDelivery is an event that has been queued for delivery to a single webhook subscriber.
+//
+// Each subscriber has its own queue, which is delivered strictly in order of ID.
+type WebhookDelivery struct {
+	gorm.Model
+	Subscriber  string    `gorm:"type:varchar(80);NOT NULL;index:att_webhook_deliveries_queue_idx"`
+	State       string    `gorm:"type:varchar(32);NOT NULL;index:att_webhook_deliveries_queue_idx"`
+	Event       string    `gorm:"type:varchar(80);NOT NULL"`
+	AttendeeId  uint      `gorm:"NOT NULL;index:att_webhook_deliveries_attendee_idx"`
+	Attempts    int       `gorm:"NOT NULL"`
+	NextAttempt time.Time `gorm:"NOT NULL"`
+	LastStatus  int       `gorm:"NOT NULL"` // http status of the last attempt, 0 if there was no response
+	LastError   string    `gorm:"type:text"`
+	Payload     string    `gorm:"type:text;NOT NULL"` // json encoded event, exactly as signed and sent
+}
+
+const (
+	WebhookStatePending = "pending" // waiting for (another) delivery attempt
+	WebhookStateSent    = "sent"
+	WebhookStateFailed  = "failed" // gave up after too many attempts
+)
+
+// WebhookQueue is the lock on the delivery queue of a webhook subscriber.
+//
+// Only the holder of the lock delivers to the subscriber, so events are never sent twice or out of order,
+// even with several instances running.
+type WebhookQueue struct {
+	Subscriber  string    `gorm:"type:varchar(80);primaryKey"`
+	LockedUntil time.Time `gorm:"NOT NULL"`
+	LockHolder  string    `gorm:"type:varchar(36);NOT NULL"`
+}
@@ -6,6 +6,7 @@ import (
 	"crypto/rsa"
 	"fmt"
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"net/url"
 	"sort"
 	"strings"
 	"time"
@@ -66,8 +67,51 @@ func DatabaseMysqlConnectString() string {
 		c.Database + "?" + strings.Join(c.Parameters, "&")
 }
 
+// DatabasePostgresConnectString returns a connection url. For postgres, the database field
+// should be host:port/dbname, and parameters are key=value pairs such as sslmode=require.
+func DatabasePostgresConnectString() string {
+	c := Configuration().Database
+	host, dbname, _ := strings.Cut(c.Database, "/")
+	connectUrl := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.Username, c.Password),
+		Host:     host,
+		Path:     "/" + dbname,
+		RawQuery: strings.Join(c.Parameters, "&"),
+	}
+	return connectUrl.String()
+}
+
+// DatabaseSqliteConnectString returns the data source for sqlite. The database field is the path
+// of the database file, and parameters are appended as the query string, e.g. _pragma=busy_timeout(5000).
+func DatabaseSqliteConnectString() string {
+	c := Configuration().Database
+	if len(c.Parameters) == 0 {
+		return c.Database
+	}
+	return c.Database + "?" + strings.Join(c.Parameters, "&")
+}
+
 func MigrateDatabase() bool {
-	return dbMigrate
+	return dbMigrate || dbMigrateOnly
+}
+
+// MigrateOnly is true if the service should exit after migrating the database.
+func MigrateOnly() bool {
+	return dbMigrateOnly
+}
+
+// MigrateStatus is true if the service should only report the database migration status.
+func MigrateStatus() bool {
+	return dbMigrateStatus
+}
+
+// MigrateDownTo returns the schema version to revert the database to, if requested.
+func MigrateDownTo() (uint, bool) {
+	if dbMigrateDownTo < 0 {
+		return 0, false
+	}
+	return uint(dbMigrateDownTo), true
 }
 
 func LoggingSeverity() string {
@@ -259,6 +303,14 @@ func PackagesConfig() map[string]ChoiceConfig {
 	return Configuration().Choices.Packages
 }
 
+func GroupConstraint() string {
+	return Configuration().Choices.GroupConstraint
+}
+
+func GroupConstraintMsg() string {
+	return Configuration().Choices.GroupConstraintMsg
+}
+
 func OptionsConfig() map[string]ChoiceConfig {
 	return Configuration().Choices.Options
 }
@@ -271,6 +323,10 @@ func LatestBirthday() string {
 	return Configuration().Birthday.Latest
 }
 
+func AgeReferenceDate() string {
+	return Configuration().Birthday.AgeReferenceDate
+}
+
 func RegistrationStartTime() time.Time {
 	t, _ := time.Parse(StartTimeFormat, Configuration().GoLive.StartIsoDatetime)
 	return t
@@ -314,6 +370,50 @@ func AuthServiceBaseUrl() string {
 	return Configuration().Service.AuthService
 }
 
+func MailOutboxDispatchInterval() time.Duration {
+	return time.Duration(Configuration().Service.MailOutbox.DispatchIntervalSeconds) * time.Second
+}
+
+func MailOutboxMaxAttempts() int {
+	return Configuration().Service.MailOutbox.MaxAttempts
+}
+
+func MailOutboxInitialBackoff() time.Duration {
+	return time.Duration(Configuration().Service.MailOutbox.InitialBackoffSeconds) * time.Second
+}
+
+func MailOutboxMaxBackoff() time.Duration {
+	return time.Duration(Configuration().Service.MailOutbox.MaxBackoffSeconds) * time.Second
+}
+
+func WebhooksConfig() map[string]WebhookConfig {
+	return Configuration().Service.Webhooks
+}
+
+func WebhookDispatchInterval() time.Duration {
+	return time.Duration(Configuration().Service.WebhookDelivery.DispatchIntervalSeconds) * time.Second
+}
+
+func WebhookMaxAttempts() int {
+	return Configuration().Service.WebhookDelivery.MaxAttempts
+}
+
+func WebhookInitialBackoff() time.Duration {
+	return time.Duration(Configuration().Service.WebhookDelivery.InitialBackoffSeconds) * time.Second
+}
+
+func WebhookMaxBackoff() time.Duration {
+	return time.Duration(Configuration().Service.WebhookDelivery.MaxBackoffSeconds) * time.Second
+}
+
+func BulkStatusConcurrency() int {
+	return Configuration().Service.BulkStatus.Concurrency
+}
+
+func BulkStatusMaxIds() int {
+	return Configuration().Service.BulkStatus.MaxIds
+}
+
 func DueDays() time.Duration {
 	return time.Duration(Configuration().Dues.DueDays*24) * time.Hour
 }
@@ -326,14 +426,35 @@ func LatestDueDate() string {
 	return Configuration().Dues.LatestDueDate
 }
 
+func PaymentPlans() map[string]PaymentPlanConfig {
+	return Configuration().Dues.PaymentPlans
+}
+
+func PaymentTolerance() []PaymentToleranceConfig {
+	return Configuration().Dues.PaymentTolerance
+}
+
 func Currency() string {
 	return Configuration().Currency
 }
 
+// AllowedCurrencies lists the billing currencies attendees can choose from, the default currency first.
+func AllowedCurrencies() []string {
+	return append([]string{Configuration().Currency}, Configuration().AdditionalCurrencies...)
+}
+
 func VatPercent() float64 {
 	return Configuration().VatPercent
 }
 
+func Invoices() InvoiceConfig {
+	return Configuration().Invoices
+}
+
+func InvoicesEnabled() bool {
+	return len(Configuration().Invoices.Issuer) > 0
+}
+
 func RegsysPublicUrl() string {
 	return Configuration().Service.RegsysPublicUrl
 }
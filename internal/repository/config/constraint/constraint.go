@@ -0,0 +1,216 @@
+// Package constraint implements the expression language used for constraints on flags, packages and options.
+//
+// The simplest constraint is a comma separated list of choice keys, each of which must be picked, or must not be
+// picked if prefixed with !, as in "stage,!day-sat". Beyond that, constraints may use
+//
+//   - and, or, not (also written as , or &, | and !) with the usual precedence, and parentheses
+//   - counts, as in "sponsor:2", which means at least 2 of sponsor
+//   - references to other categories, as in "flags.hc" or "options.music"
+//   - the age of the attendee at the reference date, as in "age>=18" or "age<27", with one of < <= > >= = !=
+//   - the status of the registration, as in "status=paid" or "status!=new"
+//
+// Keys without a category refer to the category of the choice the constraint is on. The words and, or, not, age
+// and status are reserved, so a choice with one of these keys must be referenced with its category.
+package constraint
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	Flags    = "flags"
+	Packages = "packages"
+	Options  = "options"
+)
+
+const isoDateFormat = "2006-01-02"
+
+// Facts are what a constraint is evaluated against.
+type Facts struct {
+	Choices          map[string]map[string]int // category -> choice key -> count
+	Birthday         string                    // yyyy-mm-dd, age conditions are false if missing or invalid
+	AgeReferenceDate string                    // yyyy-mm-dd, the date the age is computed for
+	Status           string
+}
+
+// Expr is a parsed constraint.
+type Expr interface {
+	Eval(f *Facts) bool
+	String() string
+}
+
+// Choice requires at least Count of a choice.
+type Choice struct {
+	Category string
+	Key      string
+	Count    int
+}
+
+type Not struct {
+	Operand Expr
+}
+
+type And struct {
+	Operands []Expr
+}
+
+type Or struct {
+	Operands []Expr
+}
+
+// Age compares the age of the attendee in full years.
+type Age struct {
+	Op    string
+	Years int
+}
+
+// Status compares the status of the registration.
+type Status struct {
+	Op    string
+	Value string
+}
+
+func (c *Choice) Eval(f *Facts) bool {
+	return f.Choices[c.Category][c.Key] >= c.Count
+}
+
+func (c *Choice) String() string {
+	if c.Count != 1 {
+		return fmt.Sprintf("%s.%s:%d", c.Category, c.Key, c.Count)
+	}
+	return c.Category + "." + c.Key
+}
+
+func (n *Not) Eval(f *Facts) bool {
+	return !n.Operand.Eval(f)
+}
+
+func (n *Not) String() string {
+	return "!" + n.Operand.String()
+}
+
+func (a *And) Eval(f *Facts) bool {
+	for _, o := range a.Operands {
+		if !o.Eval(f) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *And) String() string {
+	return "(" + joinStrings(a.Operands, " and ") + ")"
+}
+
+func (o *Or) Eval(f *Facts) bool {
+	for _, op := range o.Operands {
+		if op.Eval(f) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *Or) String() string {
+	return "(" + joinStrings(o.Operands, " or ") + ")"
+}
+
+func (a *Age) Eval(f *Facts) bool {
+	age, ok := AgeAt(f.Birthday, f.AgeReferenceDate)
+	if !ok {
+		return false
+	}
+	return compare(age, a.Op, a.Years)
+}
+
+func (a *Age) String() string {
+	return fmt.Sprintf("age%s%d", a.Op, a.Years)
+}
+
+func (s *Status) Eval(f *Facts) bool {
+	if s.Op == "!=" {
+		return f.Status != s.Value
+	}
+	return f.Status == s.Value
+}
+
+func (s *Status) String() string {
+	return "status" + s.Op + s.Value
+}
+
+// Violation returns nil if the facts satisfy e. Otherwise, it returns the part of e that is not satisfied,
+// which is the first failing operand for an and, and e itself for everything else.
+func Violation(e Expr, f *Facts) Expr {
+	if e.Eval(f) {
+		return nil
+	}
+	if and, ok := e.(*And); ok {
+		for _, o := range and.Operands {
+			if v := Violation(o, f); v != nil {
+				return v
+			}
+		}
+	}
+	return e
+}
+
+// Walk calls visit for e and all expressions contained in it.
+func Walk(e Expr, visit func(e Expr)) {
+	visit(e)
+	switch v := e.(type) {
+	case *Not:
+		Walk(v.Operand, visit)
+	case *And:
+		for _, o := range v.Operands {
+			Walk(o, visit)
+		}
+	case *Or:
+		for _, o := range v.Operands {
+			Walk(o, visit)
+		}
+	}
+}
+
+// AgeAt returns the age in full years on the reference date for someone born on birthday.
+func AgeAt(birthday string, referenceDate string) (int, bool) {
+	born, err := time.Parse(isoDateFormat, birthday)
+	if err != nil {
+		return 0, false
+	}
+	ref, err := time.Parse(isoDateFormat, referenceDate)
+	if err != nil {
+		return 0, false
+	}
+	age := ref.Year() - born.Year()
+	if ref.Month() < born.Month() || (ref.Month() == born.Month() && ref.Day() < born.Day()) {
+		age--
+	}
+	return age, true
+}
+
+func compare(value int, op string, target int) bool {
+	switch op {
+	case "<":
+		return value < target
+	case "<=":
+		return value <= target
+	case ">":
+		return value > target
+	case ">=":
+		return value >= target
+	case "!=":
+		return value != target
+	default:
+		return value == target
+	}
+}
+
+func joinStrings(exprs []Expr, sep string) string {
+	parts := make([]string, len(exprs))
+	for i, e := range exprs {
+		parts[i] = e.String()
+	}
+	return strings.Join(parts, sep)
+}
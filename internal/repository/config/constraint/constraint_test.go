@@ -0,0 +1,103 @@
+package constraint
+
+import (
+	"testing"
+
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/stretchr/testify/require"
+)
+
+func tstFacts() *Facts {
+	return &Facts{
+		Choices: map[string]map[string]int{
+			Flags:    {"hc": 1},
+			Packages: {"attendance": 1, "stage": 1, "sponsor": 2},
+			Options:  {},
+		},
+		Birthday:         "2005-08-20",
+		AgeReferenceDate: "2023-08-19",
+		Status:           "approved",
+	}
+}
+
+func tstEval(t *testing.T, expression string) bool {
+	e, err := Parse(expression, Packages)
+	require.Nil(t, err, expression)
+	return e.Eval(tstFacts())
+}
+
+func TestParseLegacyList(t *testing.T) {
+	docs.Description("the comma separated lists of the original constraint syntax should parse to an and")
+	e, err := Parse("stage,!day-sat", Packages)
+	require.Nil(t, err)
+	require.Equal(t, &And{Operands: []Expr{
+		&Choice{Category: Packages, Key: "stage", Count: 1},
+		&Not{Operand: &Choice{Category: Packages, Key: "day-sat", Count: 1}},
+	}}, e)
+}
+
+func TestEval(t *testing.T) {
+	docs.Description("constraints should evaluate correctly against the facts")
+	require.True(t, tstEval(t, "attendance,!boat-trip"))
+	require.False(t, tstEval(t, "attendance,boat-trip"))
+	require.True(t, tstEval(t, "boat-trip or stage"))
+	require.True(t, tstEval(t, "boat-trip | stage & attendance"))
+	require.False(t, tstEval(t, "(boat-trip | stage) & !attendance"))
+	require.True(t, tstEval(t, "not (boat-trip and stage)"))
+	require.True(t, tstEval(t, "sponsor:2"))
+	require.False(t, tstEval(t, "sponsor:3"))
+	require.True(t, tstEval(t, "flags.hc, !options.music"))
+	require.True(t, tstEval(t, "age<18"))
+	require.False(t, tstEval(t, "age>=18"))
+	require.True(t, tstEval(t, "age=17, age!=18, age<=17, age>16"))
+	require.True(t, tstEval(t, "status=approved"))
+	require.False(t, tstEval(t, "status!=approved"))
+}
+
+func TestAgeAt(t *testing.T) {
+	docs.Description("the age should only increase on the birthday itself")
+	age, ok := AgeAt("2005-08-20", "2023-08-19")
+	require.True(t, ok)
+	require.Equal(t, 17, age)
+	age, _ = AgeAt("2005-08-20", "2023-08-20")
+	require.Equal(t, 18, age)
+	_, ok = AgeAt("", "2023-08-20")
+	require.False(t, ok)
+}
+
+func TestViolation(t *testing.T) {
+	docs.Description("the violation should point at the first failing part of an and")
+	e, err := Parse("attendance,!stage,boat-trip", Packages)
+	require.Nil(t, err)
+	require.Equal(t, "!packages.stage", Violation(e, tstFacts()).String())
+
+	e, err = Parse("boat-trip | !stage", Packages)
+	require.Nil(t, err)
+	require.Equal(t, e, Violation(e, tstFacts()))
+
+	e, err = Parse("attendance", Packages)
+	require.Nil(t, err)
+	require.Nil(t, Violation(e, tstFacts()))
+}
+
+func TestParseErrors(t *testing.T) {
+	docs.Description("syntax errors should be reported with their position")
+	tests := map[string]string{
+		"":                  "constraint is empty",
+		"stage,":            "expected a choice key, age, status, ! or ( at end of constraint",
+		"(stage | boat":     "missing ')' for '(' at position 1",
+		"stage boat":        "unexpected 'boat' at position 7",
+		"age 18":            "expected one of < <= > >= = != after age at position 1",
+		"age>=old":          "expected a number of years at position 6, found 'old'",
+		"status<paid":       "expected = or != after status at position 1",
+		"rooms.single":      "unknown category 'rooms' at position 1, must be one of flags, packages, options",
+		"sponsor:0":         "count for sponsor must be at least 1",
+		"stage;boat":        "invalid character ';' at position 6",
+		"stage and or boat": "unexpected 'or' at position 11",
+	}
+	for expression, expected := range tests {
+		_, err := Parse(expression, Packages)
+		require.NotNil(t, err, expression)
+		require.Equal(t, expected, err.Error(), expression)
+	}
+}
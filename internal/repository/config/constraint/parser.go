@@ -0,0 +1,239 @@
+package constraint
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type token struct {
+	text string
+	pos  int // 1-based, for error messages
+}
+
+// Parse parses a constraint. Keys without a category are taken to be in defaultCategory.
+//
+// Parse only checks the syntax. Whether the referenced choices exist is up to the caller, see Walk.
+func Parse(expression string, defaultCategory string) (Expr, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("constraint is empty")
+	}
+	p := &parser{tokens: tokens, defaultCategory: defaultCategory}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := p.peek(); ok {
+		return nil, fmt.Errorf("unexpected '%s' at position %d", t.text, t.pos)
+	}
+	return e, nil
+}
+
+func isIdentChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '-'
+}
+
+func tokenize(expression string) ([]token, error) {
+	tokens := make([]token, 0)
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case isIdentChar(c):
+			start := i
+			for i < len(expression) && isIdentChar(expression[i]) {
+				i++
+			}
+			tokens = append(tokens, token{text: expression[start:i], pos: start + 1})
+		case strings.HasPrefix(expression[i:], "<=") || strings.HasPrefix(expression[i:], ">=") || strings.HasPrefix(expression[i:], "!="):
+			tokens = append(tokens, token{text: expression[i : i+2], pos: i + 1})
+			i += 2
+		case strings.ContainsRune("()!,&|.:<>=", rune(c)):
+			tokens = append(tokens, token{text: string(c), pos: i + 1})
+			i++
+		default:
+			return nil, fmt.Errorf("invalid character '%c' at position %d", c, i+1)
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens          []token
+	next            int
+	defaultCategory string
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.next >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.next], true
+}
+
+func (p *parser) peekIs(texts ...string) bool {
+	t, ok := p.peek()
+	if !ok {
+		return false
+	}
+	for _, text := range texts {
+		if t.text == text {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) expectIdent(what string) (token, error) {
+	t, ok := p.peek()
+	if !ok {
+		return token{}, fmt.Errorf("expected %s at end of constraint", what)
+	}
+	if !isIdentChar(t.text[0]) {
+		return token{}, fmt.Errorf("expected %s at position %d, found '%s'", what, t.pos, t.text)
+	}
+	p.next++
+	return t, nil
+}
+
+func (p *parser) expectNumber(what string) (int, error) {
+	t, err := p.expectIdent(what)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(t.text)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("expected %s at position %d, found '%s'", what, t.pos, t.text)
+	}
+	return n, nil
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	operands := []Expr{first}
+	for p.peekIs("|", "or") {
+		p.next++
+		e, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, e)
+	}
+	if len(operands) == 1 {
+		return first, nil
+	}
+	return &Or{Operands: operands}, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	first, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	operands := []Expr{first}
+	for p.peekIs(",", "&", "and") {
+		p.next++
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, e)
+	}
+	if len(operands) == 1 {
+		return first, nil
+	}
+	return &And{Operands: operands}, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if p.peekIs("!", "not") {
+		p.next++
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Operand: e}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	if p.peekIs("(") {
+		open, _ := p.peek()
+		p.next++
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peekIs(")") {
+			return nil, fmt.Errorf("missing ')' for '(' at position %d", open.pos)
+		}
+		p.next++
+		return e, nil
+	}
+
+	t, err := p.expectIdent("a choice key, age, status, ! or (")
+	if err != nil {
+		return nil, err
+	}
+	switch t.text {
+	case "and", "or", "not":
+		return nil, fmt.Errorf("unexpected '%s' at position %d", t.text, t.pos)
+	case "age":
+		if p.peekIs("<", "<=", ">", ">=", "=", "!=") {
+			op, _ := p.peek()
+			p.next++
+			years, err := p.expectNumber("a number of years")
+			if err != nil {
+				return nil, err
+			}
+			return &Age{Op: op.text, Years: years}, nil
+		}
+		return nil, fmt.Errorf("expected one of < <= > >= = != after age at position %d", t.pos)
+	case "status":
+		if p.peekIs("=", "!=") {
+			op, _ := p.peek()
+			p.next++
+			value, err := p.expectIdent("a status")
+			if err != nil {
+				return nil, err
+			}
+			return &Status{Op: op.text, Value: value.text}, nil
+		}
+		return nil, fmt.Errorf("expected = or != after status at position %d", t.pos)
+	}
+
+	choice := &Choice{Category: p.defaultCategory, Key: t.text, Count: 1}
+	if p.peekIs(".") {
+		if t.text != Flags && t.text != Packages && t.text != Options {
+			return nil, fmt.Errorf("unknown category '%s' at position %d, must be one of flags, packages, options", t.text, t.pos)
+		}
+		p.next++
+		key, err := p.expectIdent("a choice key")
+		if err != nil {
+			return nil, err
+		}
+		choice.Category = t.text
+		choice.Key = key.text
+	}
+	if p.peekIs(":") {
+		p.next++
+		count, err := p.expectNumber("a count")
+		if err != nil {
+			return nil, err
+		}
+		if count < 1 {
+			return nil, fmt.Errorf("count for %s must be at least 1", choice.Key)
+		}
+		choice.Count = count
+	}
+	return choice, nil
+}
@@ -18,6 +18,9 @@ var (
 	configurationLock     *sync.RWMutex
 	configurationFilename string
 	dbMigrate             bool
+	dbMigrateOnly         bool
+	dbMigrateStatus       bool
+	dbMigrateDownTo       int
 	ecsLogging            bool
 
 	generateCount uint
@@ -41,6 +44,9 @@ func init() {
 
 	flag.StringVar(&configurationFilename, "config", "", "config file path")
 	flag.BoolVar(&dbMigrate, "migrate-database", false, "migrate database on startup")
+	flag.BoolVar(&dbMigrateOnly, "migrate-only", false, "migrate database, then exit without starting the service")
+	flag.BoolVar(&dbMigrateStatus, "migrate-status", false, "log which database migrations have been applied, then exit")
+	flag.IntVar(&dbMigrateDownTo, "migrate-down-to", -1, "revert database migrations after the given version, then exit")
 	flag.BoolVar(&ecsLogging, "ecs-json-logging", false, "switch to structured json logging")
 }
 
@@ -78,11 +84,16 @@ func parseAndOverwriteConfig(yamlFile []byte) error {
 	validateSecurityConfiguration(errs, newConfigurationData.Security)
 	validateDatabaseConfiguration(errs, newConfigurationData.Database)
 	validateFlagsConfiguration(errs, newConfigurationData.Choices.Flags)
-	validatePackagesConfiguration(errs, newConfigurationData.Choices.Packages)
+	validatePackagesConfiguration(errs, newConfigurationData.Choices.Packages, newConfigurationData.Birthday.AgeReferenceDate)
 	validateOptionsConfiguration(errs, newConfigurationData.Choices.Options)
+	validateChoiceConstraints(errs, newConfigurationData.Choices, newConfigurationData.Birthday.AgeReferenceDate)
+	validateGroupConstraint(errs, newConfigurationData.Choices, newConfigurationData.Birthday.AgeReferenceDate)
 	validateBirthdayConfiguration(errs, newConfigurationData.Birthday)
 	validateRegistrationStartTime(errs, newConfigurationData.GoLive, newConfigurationData.Security)
 	validateDuesConfiguration(errs, newConfigurationData.Dues)
+	validatePaymentPlans(errs, newConfigurationData.Dues.PaymentPlans, newConfigurationData.Choices.Packages)
+	validateCurrencies(errs, newConfigurationData.Currency, newConfigurationData.AdditionalCurrencies, newConfigurationData.Choices.Packages)
+	validateInvoiceConfiguration(errs, newConfigurationData.Invoices)
 	validateAdditionalInfoConfiguration(errs, newConfigurationData.AdditionalInfo)
 
 	if len(errs) != 0 {
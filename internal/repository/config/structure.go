@@ -8,6 +8,8 @@ type (
 const (
 	Inmemory DatabaseType = "inmemory"
 	Mysql    DatabaseType = "mysql"
+	Postgres DatabaseType = "postgres"
+	Sqlite   DatabaseType = "sqlite"
 
 	Plain LogStyle = "plain"
 	ECS   LogStyle = "ecs" // default
@@ -36,18 +38,46 @@ type (
 		Countries             []string                 `yaml:"countries"`
 		SpokenLanguages       []string                 `yaml:"spoken_languages"`
 		RegistrationLanguages []string                 `yaml:"registration_languages"`
-		Currency              string                   `yaml:"currency"`
-		VatPercent            float64                  `yaml:"vat_percent"` // used for manual dues
+		Currency              string                   `yaml:"currency"`              // the default billing currency, package prices are in this currency
+		AdditionalCurrencies  []string                 `yaml:"additional_currencies"` // other billing currencies attendees can choose at registration, packages need prices in each of them
+		VatPercent            float64                  `yaml:"vat_percent"`           // used for manual dues
+		Invoices              InvoiceConfig            `yaml:"invoices"`
 	}
 
 	// ServiceConfig contains configuration values
 	// for service related tasks. E.g. URLs to downstream services
 	ServiceConfig struct {
-		Name            string `yaml:"name"`
-		RegsysPublicUrl string `yaml:"regsys_public_url"` // used in emails
-		PaymentService  string `yaml:"payment_service"`   // base url, usually http://localhost:nnnn, will use in-memory-mock if unset
-		MailService     string `yaml:"mail_service"`      // base url, usually http://localhost:nnnn, will use in-memory-mock if unset
-		AuthService     string `yaml:"auth_service"`      // base url, usually http://localhost:nnnn, will skip userinfo checks if unset
+		Name            string                   `yaml:"name"`
+		RegsysPublicUrl string                   `yaml:"regsys_public_url"` // used in emails
+		PaymentService  string                   `yaml:"payment_service"`   // base url, usually http://localhost:nnnn, will use in-memory-mock if unset
+		MailService     string                   `yaml:"mail_service"`      // base url, usually http://localhost:nnnn, will use in-memory-mock if unset
+		AuthService     string                   `yaml:"auth_service"`      // base url, usually http://localhost:nnnn, will skip userinfo checks if unset
+		MailOutbox      RetryConfig              `yaml:"mail_outbox"`
+		Webhooks        map[string]WebhookConfig `yaml:"webhooks"` // subscriber name -> config
+		WebhookDelivery RetryConfig              `yaml:"webhook_delivery"`
+		BulkStatus      BulkStatusConfig         `yaml:"bulk_status"`
+	}
+
+	// BulkStatusConfig configures the bulk status change endpoint
+	BulkStatusConfig struct {
+		Concurrency int `yaml:"concurrency"` // how many attendees are processed in parallel
+		MaxIds      int `yaml:"max_ids"`     // upper limit for the number of attendees in a single request
+	}
+
+	// RetryConfig configures background delivery retries for mails or webhook events that could not be delivered right away
+	RetryConfig struct {
+		DispatchIntervalSeconds int `yaml:"dispatch_interval_seconds"` // how often the background dispatcher looks for due deliveries
+		MaxAttempts             int `yaml:"max_attempts"`              // after this many failed attempts, a delivery is marked failed and needs admin attention
+		InitialBackoffSeconds   int `yaml:"initial_backoff_seconds"`   // wait time after the first failed attempt, doubles with each further attempt
+		MaxBackoffSeconds       int `yaml:"max_backoff_seconds"`       // upper limit for the wait time between attempts
+	}
+
+	// WebhookConfig configures a subscriber that receives attendee lifecycle events
+	WebhookConfig struct {
+		Url            string   `yaml:"url"`             // events are POSTed here
+		Secret         string   `yaml:"secret"`          // used to sign the events, the subscriber should check the signature
+		Events         []string `yaml:"events"`          // the event types to send, leave empty to send all events
+		TimeoutSeconds int      `yaml:"timeout_seconds"` // per delivery attempt
 	}
 
 	// ServerConfig contains all values for http configuration
@@ -59,8 +89,8 @@ type (
 		IdleTimeout  int    `yaml:"idle_timeout_seconds"`
 	}
 
-	// DatabaseConfig configures which db to use (mysql, inmemory)
-	// and how to connect to it (needed for mysql only)
+	// DatabaseConfig configures which db to use (mysql, postgres, sqlite, inmemory)
+	// and how to connect to it (not needed for inmemory, only database and parameters for sqlite)
 	DatabaseConfig struct {
 		Use        DatabaseType `yaml:"use"`
 		Username   string       `yaml:"username"`
@@ -118,10 +148,15 @@ type (
 	//
 	// options are personal preferences (interested in music, fursuiter, ...) that do not
 	// affect how the registration is treated.
+	//
+	// group_constraint restricts which attendees can be members of a group. It uses the same
+	// syntax as constraint, but refers to packages, and applies to every group member.
 	FlagsPkgOptConfig struct {
-		Flags    map[string]ChoiceConfig `yaml:"flags"`
-		Packages map[string]ChoiceConfig `yaml:"packages"`
-		Options  map[string]ChoiceConfig `yaml:"options"`
+		Flags              map[string]ChoiceConfig `yaml:"flags"`
+		Packages           map[string]ChoiceConfig `yaml:"packages"`
+		Options            map[string]ChoiceConfig `yaml:"options"`
+		GroupConstraint    string                  `yaml:"group_constraint"` // example: "!room-none" means every group member must have booked a room
+		GroupConstraintMsg string                  `yaml:"group_constraint_msg"`
 	}
 
 	ChoiceConfig struct {
@@ -139,8 +174,37 @@ type (
 		Constraint    string   `yaml:"constraint"`
 		ConstraintMsg string   `yaml:"constraint_msg"`
 		Limit         int      `yaml:"limit"`    // only supported for packages, the maximum number of available stock. 0 means unlimited.
+		Waitlist      bool     `yaml:"waitlist"` // only supported for packages with a limit, new registrations that would overrun the limit are queued in status waiting and approved automatically when stock frees up
 		Category      string   `yaml:"category"` // ignored - display option only
 		Sorting       int      `yaml:"sorting"`  // ignored - display option only
+
+		AgePrices []AgePriceConfig `yaml:"age_prices"` // only supported for packages, the first tier that matches the age of the attendee at birthday.age_reference_date replaces price
+		MinAge    int              `yaml:"min_age"`    // only supported for packages, attendees younger than this at birthday.age_reference_date cannot pick the package. 0 means no limit.
+		MaxAge    int              `yaml:"max_age"`    // only supported for packages, attendees older than this at birthday.age_reference_date cannot pick the package. 0 means no limit.
+
+		PriceSchedule []PriceScheduleConfig `yaml:"price_schedule"` // only supported for packages, replaces price for bookings made on or after each from date. The price is locked when the package is first booked. Age based prices take precedence.
+		PaymentPlan   string                `yaml:"payment_plan"`   // only supported for packages, key of an entry in dues.payment_plans. The price of the package is then due in installments.
+
+		Prices map[string]int64 `yaml:"prices"` // only supported for packages, the price in each of the additional currencies, by currency code
+	}
+
+	// PriceScheduleConfig is a price for a package that applies to bookings made on or after a date
+	PriceScheduleConfig struct {
+		From        string `yaml:"from"` // ISO date, entries must be in ascending order
+		Price       int64  `yaml:"price"`
+		Description string `yaml:"description"` // e.g. "Late Booking"
+
+		Prices map[string]int64 `yaml:"prices"` // the price in each of the additional currencies, by currency code
+	}
+
+	// AgePriceConfig is a price tier for a package based on the age of the attendee
+	AgePriceConfig struct {
+		MinAge      int    `yaml:"min_age"` // inclusive
+		MaxAge      int    `yaml:"max_age"` // inclusive, 0 means no upper limit
+		Price       int64  `yaml:"price"`
+		Description string `yaml:"description"` // shown in the status mail and the dues transaction comment, e.g. "Youth Rate"
+
+		Prices map[string]int64 `yaml:"prices"` // the price in each of the additional currencies, by currency code
 	}
 
 	// AddInfoConfig configures access permissions to an additional info field
@@ -155,8 +219,9 @@ type (
 	//
 	// use it to exclude nonsensical values, or to exclude participants under a minimum age
 	BirthdayConfig struct {
-		Earliest string `yaml:"earliest"`
-		Latest   string `yaml:"latest"`
+		Earliest         string `yaml:"earliest"`
+		Latest           string `yaml:"latest"`
+		AgeReferenceDate string `yaml:"age_reference_date"` // the date ages are computed for, usually the first day of the convention
 	}
 
 	// GoLiveConfig configures the time at which registration becomes available
@@ -167,8 +232,42 @@ type (
 
 	// DuesConfig configures the due date calculations
 	DuesConfig struct {
-		EarliestDueDate string `yaml:"earliest_due_date"`
-		LatestDueDate   string `yaml:"latest_due_date"` // inclusive
-		DueDays         int    `yaml:"due_days"`
+		EarliestDueDate string                       `yaml:"earliest_due_date"`
+		LatestDueDate   string                       `yaml:"latest_due_date"` // inclusive
+		DueDays         int                          `yaml:"due_days"`
+		PaymentPlans    map[string]PaymentPlanConfig `yaml:"payment_plans"` // referenced by packages via payment_plan
+		// the first matching rule determines how much underpayment still counts as paid in full. The rest of the
+		// dues is then written off. Defaults to a single rule with an amount of 100 cents.
+		PaymentTolerance []PaymentToleranceConfig `yaml:"payment_tolerance"`
+	}
+
+	// PaymentToleranceConfig is a rule for how much underpayment is tolerated
+	PaymentToleranceConfig struct {
+		Currency string  `yaml:"currency"` // optional, the rule only applies if the last payment was made in this currency
+		Method   string  `yaml:"method"`   // optional, the rule only applies if the last payment was made with this method, one of credit, paypal, cash, transfer, internal, gift
+		Amount   int64   `yaml:"amount"`   // in cents
+		Percent  float64 `yaml:"percent"`  // of the total dues. If both amount and percent are set, the smaller tolerance applies.
+	}
+
+	// InvoiceConfig configures the invoices issued to attendees whenever their dues change.
+	//
+	// Invoices are only issued if an issuer is configured.
+	InvoiceConfig struct {
+		Issuer       []string `yaml:"issuer"`        // name and address of the organisation, one line each
+		VatId        string   `yaml:"vat_id"`        // optional, the VAT identification number of the organisation
+		NumberPrefix string   `yaml:"number_prefix"` // invoice numbers are this prefix followed by a sequential number, as in EF2024-000042
+		Footer       []string `yaml:"footer"`        // optional lines at the bottom of each invoice, e.g. bank details
+	}
+
+	// PaymentPlanConfig splits the price of a package into installments with separate due dates
+	PaymentPlanConfig struct {
+		Description  string              `yaml:"description"`
+		Installments []InstallmentConfig `yaml:"installments"` // in order of their due dates, the percentages must add up to 100
+	}
+
+	// InstallmentConfig is a part of the price of a package and when it is due
+	InstallmentConfig struct {
+		Percent int    `yaml:"percent"`
+		DueDate string `yaml:"due_date"` // optional ISO date. The installment is due at the regular due date, or at this date if it is later.
 	}
 )
@@ -8,7 +8,10 @@ import (
 	"slices"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/webhook"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config/constraint"
 	"github.com/eurofurence/reg-attendee-service/internal/web/util/validation"
 	"github.com/golang-jwt/jwt/v4"
 )
@@ -62,6 +65,23 @@ func setConfigurationDefaults(c *Application) {
 	if c.Dues.DueDays == 0 {
 		c.Dues.DueDays = 14
 	}
+	if c.Dues.PaymentTolerance == nil {
+		c.Dues.PaymentTolerance = []PaymentToleranceConfig{{Amount: 100}}
+	}
+	setRetryDefaults(&c.Service.MailOutbox, 30, 10, 60, 3600)
+	setRetryDefaults(&c.Service.WebhookDelivery, 10, 10, 30, 3600)
+	if c.Service.BulkStatus.Concurrency <= 0 {
+		c.Service.BulkStatus.Concurrency = 4
+	}
+	if c.Service.BulkStatus.MaxIds <= 0 {
+		c.Service.BulkStatus.MaxIds = 1000
+	}
+	for name, conf := range c.Service.Webhooks {
+		if conf.TimeoutSeconds <= 0 {
+			conf.TimeoutSeconds = 5
+			c.Service.Webhooks[name] = conf
+		}
+	}
 	if len(c.Security.FindApiAccess.Permissions) == 0 {
 		c.Security.FindApiAccess.Permissions = []string{"regdesk", "sponsordesk"}
 	}
@@ -121,17 +141,20 @@ func validateSecurityConfiguration(errs url.Values, c SecurityConfig) {
 	}
 }
 
-var allowedDatabases = []DatabaseType{Mysql, Inmemory}
+var allowedDatabases = []DatabaseType{Mysql, Postgres, Sqlite, Inmemory}
 
 func validateDatabaseConfiguration(errs url.Values, c DatabaseConfig) {
 	if validation.NotInAllowedValues(allowedDatabases[:], c.Use) {
-		errs.Add("database.use", "must be one of mysql, inmemory")
+		errs.Add("database.use", "must be one of mysql, postgres, sqlite, inmemory")
 	}
-	if c.Use == Mysql {
+	if c.Use == Mysql || c.Use == Postgres {
 		validation.CheckLength(&errs, 1, 256, "database.username", c.Username)
 		validation.CheckLength(&errs, 1, 256, "database.password", c.Password)
 		validation.CheckLength(&errs, 1, 256, "database.database", c.Database)
 	}
+	if c.Use == Sqlite {
+		validation.CheckLength(&errs, 1, 256, "database.database", c.Database)
+	}
 }
 
 func validateBirthdayConfiguration(errs url.Values, c BirthdayConfig) {
@@ -141,6 +164,9 @@ func validateBirthdayConfiguration(errs url.Values, c BirthdayConfig) {
 	if validation.InvalidISODate(c.Latest) {
 		errs.Add("birthday.latest", "invalid latest birthday, must be specified as an ISO Date, as in 2019-08-24. It is acceptable to specify the last day of the convention, if you wish to allow any underage participants. Otherwise use the first day, 18 years ago.")
 	}
+	if c.AgeReferenceDate != "" && validation.InvalidISODate(c.AgeReferenceDate) {
+		errs.Add("birthday.age_reference_date", "invalid age reference date, must be specified as an ISO Date, as in 2023-09-03. Usually the first day of the convention.")
+	}
 }
 
 const keyPattern = "^[a-zA-Z0-9_-]+$"
@@ -151,7 +177,6 @@ func validateFlagsConfiguration(errs url.Values, c map[string]ChoiceConfig) {
 			errs.Add("choices.flags."+k, "invalid key, must consist of a-z A-Z 0-9 - _ only")
 		}
 		validation.CheckLength(&errs, 1, 256, "choices.flags."+k+".description", v.Description)
-		checkConstraints(errs, c, "choices.flags", k, v.Constraint, v.ConstraintMsg)
 		if v.AdminOnly && v.ReadOnly {
 			errs.Add("choices.flags."+k+".admin", "a flag cannot both be admin_only and read_only")
 		}
@@ -161,13 +186,12 @@ func validateFlagsConfiguration(errs url.Values, c map[string]ChoiceConfig) {
 	}
 }
 
-func validatePackagesConfiguration(errs url.Values, c map[string]ChoiceConfig) {
+func validatePackagesConfiguration(errs url.Values, c map[string]ChoiceConfig, ageReferenceDate string) {
 	for k, v := range c {
 		if validation.ViolatesPattern(keyPattern, k) {
 			errs.Add("choices.packages."+k, "invalid key, must consist of a-z A-Z 0-9 - _ only")
 		}
 		validation.CheckLength(&errs, 1, 256, "choices.packages."+k+".description", v.Description)
-		checkConstraints(errs, c, "choices.packages", k, v.Constraint, v.ConstraintMsg)
 		if v.AdminOnly {
 			errs.Add("choices.packages."+k+".admin", "packages cannot be admin_only (they cost money). Try read_only instead.")
 		}
@@ -181,6 +205,55 @@ func validatePackagesConfiguration(errs url.Values, c map[string]ChoiceConfig) {
 		if v.Limit < 0 {
 			errs.Add("choices.packages."+k+".limit", "limit value cannot be negative")
 		}
+		if v.Waitlist && v.Limit <= 0 {
+			errs.Add("choices.packages."+k+".waitlist", "a waiting list can only be configured for packages with a limit")
+		}
+		validatePackageAgeRules(errs, k, v, ageReferenceDate)
+		validatePackagePriceSchedule(errs, k, v)
+	}
+}
+
+func validatePackagePriceSchedule(errs url.Values, k string, v ChoiceConfig) {
+	previousFrom := ""
+	for i, entry := range v.PriceSchedule {
+		entryKey := fmt.Sprintf("choices.packages.%s.price_schedule.%d", k, i)
+		if validation.InvalidISODate(entry.From) {
+			errs.Add(entryKey+".from", "invalid from date, must be specified as an ISO Date, as in 2024-06-01")
+		} else if entry.From <= previousFrom {
+			errs.Add(entryKey+".from", "price schedule must be in ascending order of from dates")
+		} else {
+			previousFrom = entry.From
+		}
+		if entry.Price < 0 {
+			errs.Add(entryKey+".price", "price cannot be negative")
+		}
+		validation.CheckLength(&errs, 1, 256, entryKey+".description", entry.Description)
+	}
+}
+
+func validatePackageAgeRules(errs url.Values, k string, v ChoiceConfig, ageReferenceDate string) {
+	if ageReferenceDate == "" && (v.MinAge != 0 || v.MaxAge != 0 || len(v.AgePrices) > 0) {
+		errs.Add("choices.packages."+k+".age", "age dependent prices and age limits need birthday.age_reference_date to be set")
+	}
+	checkAgeRange(errs, "choices.packages."+k, v.MinAge, v.MaxAge)
+	for i, tier := range v.AgePrices {
+		tierKey := fmt.Sprintf("choices.packages.%s.age_prices.%d", k, i)
+		checkAgeRange(errs, tierKey, tier.MinAge, tier.MaxAge)
+		if tier.Price < 0 {
+			errs.Add(tierKey+".price", "price cannot be negative")
+		}
+		validation.CheckLength(&errs, 1, 256, tierKey+".description", tier.Description)
+	}
+}
+
+func checkAgeRange(errs url.Values, key string, minAge int, maxAge int) {
+	if minAge < 0 {
+		errs.Add(key+".min_age", "min_age cannot be negative")
+	}
+	if maxAge < 0 {
+		errs.Add(key+".max_age", "max_age cannot be negative")
+	} else if maxAge > 0 && maxAge < minAge {
+		errs.Add(key+".max_age", "max_age cannot be less than min_age")
 	}
 }
 
@@ -190,7 +263,6 @@ func validateOptionsConfiguration(errs url.Values, c map[string]ChoiceConfig) {
 			errs.Add("choices.options."+k, "invalid key, must consist of a-z A-Z 0-9 - _ only")
 		}
 		validation.CheckLength(&errs, 1, 256, "choices.options."+k+".description", v.Description)
-		checkConstraints(errs, c, "choices.options", k, v.Constraint, v.ConstraintMsg)
 		if v.AdminOnly {
 			errs.Add("choices.options."+k+".admin", "options cannot be admin_only (they represent user choices).")
 		}
@@ -200,26 +272,92 @@ func validateOptionsConfiguration(errs url.Values, c map[string]ChoiceConfig) {
 	}
 }
 
-func checkConstraints(errs url.Values, c map[string]ChoiceConfig, keyPrefix string, key string, constraint string, constraintMsg string) {
-	if constraint != "" {
-		constraints := strings.Split(constraint, ",")
-		for _, cn := range constraints {
-			choiceKey := cn
-			if strings.HasPrefix(cn, "!") {
-				choiceKey = strings.TrimPrefix(cn, "!")
-			}
-			if _, ok := c[choiceKey]; !ok {
-				errs.Add(keyPrefix+"."+key+".constraint", "invalid key in constraint, references nonexistent entry")
-			} else {
-				if c[choiceKey].AdminOnly != c[key].AdminOnly {
-					errs.Add(keyPrefix+"."+key+".constraint", "invalid key in constraint, references across admin only and non-admin only")
+func validateChoiceConstraints(errs url.Values, c FlagsPkgOptConfig, ageReferenceDate string) {
+	for category, choices := range map[string]map[string]ChoiceConfig{
+		constraint.Flags:    c.Flags,
+		constraint.Packages: c.Packages,
+		constraint.Options:  c.Options,
+	} {
+		for k, v := range choices {
+			checkConstraints(errs, c, ageReferenceDate, category, k, v.Constraint, v.ConstraintMsg)
+		}
+	}
+}
+
+func validateGroupConstraint(errs url.Values, c FlagsPkgOptConfig, ageReferenceDate string) {
+	if c.GroupConstraint != "" {
+		expr, err := constraint.Parse(c.GroupConstraint, constraint.Packages)
+		if err != nil {
+			errs.Add("choices.group_constraint", "invalid group constraint: "+err.Error())
+		} else {
+			constraint.Walk(expr, func(e constraint.Expr) {
+				switch v := e.(type) {
+				case *constraint.Choice:
+					if _, ok := choicesOfCategory(c, v.Category)[v.Key]; !ok {
+						errs.Add("choices.group_constraint", "invalid key in group constraint, references nonexistent "+strings.TrimSuffix(v.Category, "s"))
+					}
+				default:
+					checkAgeAndStatusConditions(errs, "choices.group_constraint", e, ageReferenceDate)
 				}
-			}
-			if choiceKey == key {
-				errs.Add(keyPrefix+"."+key+".constraint", "invalid self referential constraint")
-			}
-			validation.CheckLength(&errs, 1, 256, keyPrefix+"."+key+".constraint_msg", constraintMsg)
+			})
 		}
+		validation.CheckLength(&errs, 1, 256, "choices.group_constraint_msg", c.GroupConstraintMsg)
+	}
+}
+
+func checkConstraints(errs url.Values, c FlagsPkgOptConfig, ageReferenceDate string, category string, key string, constraintStr string, constraintMsg string) {
+	if constraintStr != "" {
+		errKey := "choices." + category + "." + key + ".constraint"
+		expr, err := constraint.Parse(constraintStr, category)
+		if err != nil {
+			errs.Add(errKey, "invalid constraint: "+err.Error())
+		} else {
+			self := choicesOfCategory(c, category)[key]
+			constraint.Walk(expr, func(e constraint.Expr) {
+				switch v := e.(type) {
+				case *constraint.Choice:
+					if other, ok := choicesOfCategory(c, v.Category)[v.Key]; !ok {
+						errs.Add(errKey, "invalid key in constraint, references nonexistent entry")
+					} else if other.AdminOnly != self.AdminOnly {
+						errs.Add(errKey, "invalid key in constraint, references across admin only and non-admin only")
+					}
+					if v.Category == category && v.Key == key {
+						errs.Add(errKey, "invalid self referential constraint")
+					}
+				default:
+					checkAgeAndStatusConditions(errs, errKey, e, ageReferenceDate)
+				}
+			})
+		}
+		validation.CheckLength(&errs, 1, 256, "choices."+category+"."+key+".constraint_msg", constraintMsg)
+	}
+}
+
+func checkAgeAndStatusConditions(errs url.Values, errKey string, e constraint.Expr, ageReferenceDate string) {
+	switch v := e.(type) {
+	case *constraint.Age:
+		if ageReferenceDate == "" {
+			errs.Add(errKey, "age conditions need birthday.age_reference_date to be set")
+		}
+	case *constraint.Status:
+		allowed := make([]string, 0)
+		for _, s := range AllowedStatusValues() {
+			allowed = append(allowed, string(s))
+		}
+		if validation.NotInAllowedValues(allowed, v.Value) {
+			errs.Add(errKey, "invalid status "+v.Value+" in constraint, must be one of "+strings.Join(allowed, ","))
+		}
+	}
+}
+
+func choicesOfCategory(c FlagsPkgOptConfig, category string) map[string]ChoiceConfig {
+	switch category {
+	case constraint.Flags:
+		return c.Flags
+	case constraint.Packages:
+		return c.Packages
+	default:
+		return c.Options
 	}
 }
 
@@ -245,6 +383,10 @@ func validateRegistrationStartTime(errs url.Values, c GoLiveConfig, s SecurityCo
 	}
 }
 
+const currencyPattern = "^[A-Z]{3}$"
+
+var paymentMethods = []string{"credit", "paypal", "cash", "transfer", "internal", "gift"}
+
 func validateDuesConfiguration(errs url.Values, c DuesConfig) {
 	earliest, err := time.Parse(IsoDateFormat, c.EarliestDueDate)
 	if err != nil {
@@ -263,6 +405,131 @@ func validateDuesConfiguration(errs url.Values, c DuesConfig) {
 	if latest.Before(earliest) {
 		errs.Add("dues.latest_due_date", "must be no earlier than dues.earliest_due_date")
 	}
+
+	for i, rule := range c.PaymentTolerance {
+		ruleKey := fmt.Sprintf("dues.payment_tolerance.%d", i)
+		if rule.Currency != "" && validation.ViolatesPattern(currencyPattern, rule.Currency) {
+			errs.Add(ruleKey+".currency", "must be empty or a three letter currency code, as in EUR")
+		}
+		if rule.Method != "" && !slices.Contains(paymentMethods, rule.Method) {
+			errs.Add(ruleKey+".method", "must be empty or one of "+strings.Join(paymentMethods, ", "))
+		}
+		if rule.Amount < 0 {
+			errs.Add(ruleKey+".amount", "amount cannot be negative")
+		}
+		if rule.Percent < 0 || rule.Percent > 100 {
+			errs.Add(ruleKey+".percent", "percent must be between 0 and 100")
+		}
+	}
+}
+
+func validatePaymentPlans(errs url.Values, plans map[string]PaymentPlanConfig, packages map[string]ChoiceConfig) {
+	for k, v := range plans {
+		planKey := "dues.payment_plans." + k
+		if validation.ViolatesPattern(keyPattern, k) {
+			errs.Add(planKey, "invalid key, must consist of a-z A-Z 0-9 - _ only")
+		}
+		validation.CheckLength(&errs, 1, 256, planKey+".description", v.Description)
+		if len(v.Installments) == 0 {
+			errs.Add(planKey+".installments", "a payment plan needs at least one installment")
+		}
+		totalPercent := 0
+		previousDueDate := ""
+		for i, entry := range v.Installments {
+			entryKey := fmt.Sprintf("%s.installments.%d", planKey, i)
+			if entry.Percent < 1 || entry.Percent > 100 {
+				errs.Add(entryKey+".percent", "percent must be between 1 and 100")
+			}
+			totalPercent += entry.Percent
+			if entry.DueDate != "" && validation.InvalidISODate(entry.DueDate) {
+				errs.Add(entryKey+".due_date", "invalid due date, must be empty or specified as an ISO Date, as in 2024-06-01")
+			} else if entry.DueDate < previousDueDate {
+				errs.Add(entryKey+".due_date", "installments must be in ascending order of due dates, installments without due date come first")
+			} else {
+				previousDueDate = entry.DueDate
+			}
+		}
+		if len(v.Installments) > 0 && totalPercent != 100 {
+			errs.Add(planKey+".installments", "the percentages of the installments must add up to 100")
+		}
+	}
+	for k, v := range packages {
+		if v.PaymentPlan != "" {
+			if _, ok := plans[v.PaymentPlan]; !ok {
+				errs.Add("choices.packages."+k+".payment_plan", "must be empty or one of the keys in dues.payment_plans")
+			}
+		}
+	}
+}
+
+func validateCurrencies(errs url.Values, currency string, additional []string, packages map[string]ChoiceConfig) {
+	if validation.ViolatesPattern(currencyPattern, currency) {
+		errs.Add("currency", "must be a three letter currency code, as in EUR")
+	}
+	for i, c := range additional {
+		if validation.ViolatesPattern(currencyPattern, c) {
+			errs.Add(fmt.Sprintf("additional_currencies.%d", i), "must be a three letter currency code, as in GBP")
+		} else if c == currency || slices.Contains(additional[:i], c) {
+			errs.Add(fmt.Sprintf("additional_currencies.%d", i), "duplicate currency "+c)
+		}
+	}
+
+	for k, v := range packages {
+		packageKey := "choices.packages." + k
+		checkPricesInCurrencies(errs, packageKey, v.Price, v.Prices, additional)
+		for i, tier := range v.AgePrices {
+			checkPricesInCurrencies(errs, fmt.Sprintf("%s.age_prices.%d", packageKey, i), tier.Price, tier.Prices, additional)
+		}
+		for i, entry := range v.PriceSchedule {
+			checkPricesInCurrencies(errs, fmt.Sprintf("%s.price_schedule.%d", packageKey, i), entry.Price, entry.Prices, additional)
+		}
+	}
+}
+
+// checkPricesInCurrencies makes sure there is a price in each additional currency, unless the price is zero anyway.
+func checkPricesInCurrencies(errs url.Values, key string, price int64, prices map[string]int64, additional []string) {
+	for c, p := range prices {
+		if !slices.Contains(additional, c) {
+			errs.Add(key+".prices."+c, "must be one of additional_currencies")
+		} else if p < 0 {
+			errs.Add(key+".prices."+c, "price cannot be negative")
+		}
+	}
+	for _, c := range additional {
+		if _, ok := prices[c]; !ok && price != 0 {
+			errs.Add(key+".prices", "missing price in "+c)
+		}
+	}
+}
+
+const invoiceNumberPrefixPattern = "^[A-Za-z0-9_/-]{0,20}$"
+
+// invoiceLineMaxLength keeps issuer and footer lines within the width of the invoice pdf.
+const invoiceLineMaxLength = 80
+
+func validateInvoiceConfiguration(errs url.Values, c InvoiceConfig) {
+	if validation.ViolatesPattern(invoiceNumberPrefixPattern, c.NumberPrefix) {
+		errs.Add("invoices.number_prefix", "must consist of at most 20 letters, digits, -, _ and /")
+	}
+	if len(c.Issuer) > 6 {
+		errs.Add("invoices.issuer", "at most 6 lines allowed")
+	}
+	if len(c.Footer) > 4 {
+		errs.Add("invoices.footer", "at most 4 lines allowed")
+	}
+	for i, line := range c.Issuer {
+		if utf8.RuneCountInString(line) > invoiceLineMaxLength {
+			errs.Add(fmt.Sprintf("invoices.issuer.%d", i), fmt.Sprintf("must be at most %d characters long", invoiceLineMaxLength))
+		}
+	}
+	for i, line := range c.Footer {
+		if utf8.RuneCountInString(line) > invoiceLineMaxLength {
+			errs.Add(fmt.Sprintf("invoices.footer.%d", i), fmt.Sprintf("must be at most %d characters long", invoiceLineMaxLength))
+		}
+	}
+	if len(c.Issuer) == 0 && (c.VatId != "" || c.NumberPrefix != "" || len(c.Footer) > 0) {
+		errs.Add("invoices.issuer", "invoices are only issued if an issuer is configured")
+	}
 }
 
 const publicUrlPattern = "^https?://"
@@ -278,6 +545,52 @@ func validateServiceConfiguration(errs url.Values, c ServiceConfig) {
 	if validation.ViolatesPattern(downstreamPattern, c.MailService) {
 		errs.Add("service.mail_service", "base url must be empty (enables in-memory simulator) or start with http:// or https:// and may not end in a /")
 	}
+	validateRetryConfiguration(errs, "service.mail_outbox", c.MailOutbox)
+	validateRetryConfiguration(errs, "service.webhook_delivery", c.WebhookDelivery)
+	validateWebhooksConfiguration(errs, c.Webhooks)
+}
+
+func setRetryDefaults(c *RetryConfig, dispatchIntervalSeconds int, maxAttempts int, initialBackoffSeconds int, maxBackoffSeconds int) {
+	if c.DispatchIntervalSeconds <= 0 {
+		c.DispatchIntervalSeconds = dispatchIntervalSeconds
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = maxAttempts
+	}
+	if c.InitialBackoffSeconds <= 0 {
+		c.InitialBackoffSeconds = initialBackoffSeconds
+	}
+	if c.MaxBackoffSeconds <= 0 {
+		c.MaxBackoffSeconds = maxBackoffSeconds
+	}
+}
+
+func validateRetryConfiguration(errs url.Values, key string, c RetryConfig) {
+	if c.MaxBackoffSeconds < c.InitialBackoffSeconds {
+		errs.Add(key+".max_backoff_seconds", "must be no smaller than "+key+".initial_backoff_seconds")
+	}
+}
+
+const webhookNamePattern = "^[a-z0-9_-]+$"
+
+func validateWebhooksConfiguration(errs url.Values, c map[string]WebhookConfig) {
+	for name, conf := range c {
+		key := "service.webhooks." + name
+		if validation.ViolatesPattern(webhookNamePattern, name) {
+			errs.Add(key, "invalid subscriber name, must match "+webhookNamePattern)
+		}
+		if validation.ViolatesPattern(publicUrlPattern, conf.Url) {
+			errs.Add(key+".url", "url must start with http:// or https://")
+		}
+		if len(conf.Secret) < 16 {
+			errs.Add(key+".secret", "secret must be at least 16 characters long")
+		}
+		for _, event := range conf.Events {
+			if validation.NotInAllowedValues(webhook.AllEvents, event) {
+				errs.Add(key+".events", fmt.Sprintf("unknown event type %s", event))
+			}
+		}
+	}
 }
 
 const addInfoAreaPattern = "^[a-z]+$"
@@ -4,27 +4,39 @@ import (
 	"encoding/json"
 	"net/url"
 	"reflect"
+	"strings"
 	"testing"
 )
 
 func TestCheckConstraints(t *testing.T) {
-	c := make(map[string]ChoiceConfig)
-	c["selfref"] = ChoiceConfig{Constraint: "selfref", ConstraintMsg: "self referential"}
-	c["msgmissing"] = ChoiceConfig{Constraint: "selfref"}
-	c["wrongref"] = ChoiceConfig{Constraint: "unicorn", ConstraintMsg: "wrong reference"}
-	c["admincrossref"] = ChoiceConfig{AdminOnly: true, Constraint: "!wrongref", ConstraintMsg: "wrong reference"}
+	c := FlagsPkgOptConfig{
+		Flags: map[string]ChoiceConfig{
+			"hc":    {},
+			"guest": {AdminOnly: true},
+		},
+		Packages: make(map[string]ChoiceConfig),
+	}
+	c.Packages["selfref"] = ChoiceConfig{Constraint: "selfref", ConstraintMsg: "self referential"}
+	c.Packages["msgmissing"] = ChoiceConfig{Constraint: "selfref"}
+	c.Packages["wrongref"] = ChoiceConfig{Constraint: "unicorn", ConstraintMsg: "wrong reference"}
+	c.Packages["admincrossref"] = ChoiceConfig{Constraint: "!flags.guest", ConstraintMsg: "wrong reference"}
+	c.Packages["crossref"] = ChoiceConfig{Constraint: "flags.hc | age>=18 & status!=new", ConstraintMsg: "valid"}
+	c.Packages["syntax"] = ChoiceConfig{Constraint: "(wrongref", ConstraintMsg: "syntax error"}
+	c.Packages["badstatus"] = ChoiceConfig{Constraint: "status=happy", ConstraintMsg: "no such status"}
 
 	actualErrors := url.Values{}
-	checkConstraints(actualErrors, c, "blah", "selfref", c["selfref"].Constraint, c["selfref"].ConstraintMsg)
-	checkConstraints(actualErrors, c, "blah", "msgmissing", c["msgmissing"].Constraint, c["msgmissing"].ConstraintMsg)
-	checkConstraints(actualErrors, c, "blah", "wrongref", c["wrongref"].Constraint, c["wrongref"].ConstraintMsg)
-	checkConstraints(actualErrors, c, "blah", "admincrossref", c["admincrossref"].Constraint, c["admincrossref"].ConstraintMsg)
+	for k, v := range c.Packages {
+		checkConstraints(actualErrors, c, "", "packages", k, v.Constraint, v.ConstraintMsg)
+	}
 
 	expectedErrors := url.Values{
-		"blah.admincrossref.constraint":  []string{"invalid key in constraint, references across admin only and non-admin only"},
-		"blah.selfref.constraint":        []string{"invalid self referential constraint"},
-		"blah.msgmissing.constraint_msg": []string{"blah.msgmissing.constraint_msg field must be at least 1 and at most 256 characters long"},
-		"blah.wrongref.constraint":       []string{"invalid key in constraint, references nonexistent entry"},
+		"choices.packages.admincrossref.constraint":  []string{"invalid key in constraint, references across admin only and non-admin only"},
+		"choices.packages.selfref.constraint":        []string{"invalid self referential constraint"},
+		"choices.packages.msgmissing.constraint_msg": []string{"choices.packages.msgmissing.constraint_msg field must be at least 1 and at most 256 characters long"},
+		"choices.packages.wrongref.constraint":       []string{"invalid key in constraint, references nonexistent entry"},
+		"choices.packages.crossref.constraint":       []string{"age conditions need birthday.age_reference_date to be set"},
+		"choices.packages.syntax.constraint":         []string{"invalid constraint: missing ')' for '(' at position 1"},
+		"choices.packages.badstatus.constraint":      []string{"invalid status happy in constraint, must be one of new,approved,partially paid,paid,checked in,waiting,cancelled,deleted"},
 	}
 	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
 	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
@@ -57,14 +69,97 @@ func TestCheckPackages(t *testing.T) {
 	c["counttoohigh"] = ChoiceConfig{AllowedCounts: []int{1, 17, 34}, Description: "allowed_counts higher than max_count", MaxCount: 17}
 	c["maxcountunset"] = ChoiceConfig{AllowedCounts: []int{1, 17, 34}, Description: "allowed_counts but no max_count"}
 	c["limitnegative"] = ChoiceConfig{Description: "limit negative", Limit: -4}
+	c["waitlistunlimited"] = ChoiceConfig{Description: "waiting list without limit", Waitlist: true}
 
 	actualErrors := url.Values{}
-	validatePackagesConfiguration(actualErrors, c)
+	validatePackagesConfiguration(actualErrors, c, "2023-08-16")
 	expectedErrors := url.Values{
 		"choices.packages.myadmin.admin":                []string{"packages cannot be admin_only (they cost money). Try read_only instead."},
 		"choices.packages.counttoohigh.allowed_counts":  []string{"maximum allowed_counts value cannot be larger than max_count for package"},
 		"choices.packages.maxcountunset.allowed_counts": []string{"can only list allowed counts if max_count is set to at least 2"},
 		"choices.packages.limitnegative.limit":          []string{"limit value cannot be negative"},
+		"choices.packages.waitlistunlimited.waitlist":   []string{"a waiting list can only be configured for packages with a limit"},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
+
+func TestCheckPackageAgeRules(t *testing.T) {
+	c := make(map[string]ChoiceConfig)
+	c["agerange"] = ChoiceConfig{Description: "min age above max age", MinAge: 18, MaxAge: 16}
+	c["tiers"] = ChoiceConfig{Description: "invalid tiers", Price: 9000, AgePrices: []AgePriceConfig{
+		{MaxAge: 17, Price: 4500, Description: "Youth Rate"},
+		{MinAge: -1, Price: -100},
+	}}
+
+	actualErrors := url.Values{}
+	validatePackagesConfiguration(actualErrors, c, "")
+	expectedErrors := url.Values{
+		"choices.packages.agerange.age":                   []string{"age dependent prices and age limits need birthday.age_reference_date to be set"},
+		"choices.packages.agerange.max_age":               []string{"max_age cannot be less than min_age"},
+		"choices.packages.tiers.age":                      []string{"age dependent prices and age limits need birthday.age_reference_date to be set"},
+		"choices.packages.tiers.age_prices.1.min_age":     []string{"min_age cannot be negative"},
+		"choices.packages.tiers.age_prices.1.price":       []string{"price cannot be negative"},
+		"choices.packages.tiers.age_prices.1.description": []string{"choices.packages.tiers.age_prices.1.description field must be at least 1 and at most 256 characters long"},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
+
+func TestCheckPackagePriceSchedule(t *testing.T) {
+	c := make(map[string]ChoiceConfig)
+	c["schedule"] = ChoiceConfig{Description: "invalid schedule", Price: 9000, PriceSchedule: []PriceScheduleConfig{
+		{From: "2024-06-01", Price: 10000, Description: "Regular"},
+		{From: "2024-05-01", Price: 12000, Description: "Late Booking"},
+		{From: "tomorrow", Price: -1, Description: "Too Late"},
+	}}
+
+	actualErrors := url.Values{}
+	validatePackagesConfiguration(actualErrors, c, "")
+	expectedErrors := url.Values{
+		"choices.packages.schedule.price_schedule.1.from":  []string{"price schedule must be in ascending order of from dates"},
+		"choices.packages.schedule.price_schedule.2.from":  []string{"invalid from date, must be specified as an ISO Date, as in 2024-06-01"},
+		"choices.packages.schedule.price_schedule.2.price": []string{"price cannot be negative"},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
+
+func TestCheckPaymentPlans(t *testing.T) {
+	plans := make(map[string]PaymentPlanConfig)
+	plans["valid"] = PaymentPlanConfig{Description: "Three Installments", Installments: []InstallmentConfig{
+		{Percent: 50},
+		{Percent: 25, DueDate: "2024-03-01"},
+		{Percent: 25, DueDate: "2024-06-01"},
+	}}
+	plans["invalid"] = PaymentPlanConfig{Description: "Invalid Installments", Installments: []InstallmentConfig{
+		{Percent: 50, DueDate: "2024-06-01"},
+		{Percent: 0, DueDate: "2024-03-01"},
+		{Percent: 40, DueDate: "tomorrow"},
+	}}
+	plans["empty"] = PaymentPlanConfig{Description: "No Installments"}
+	packages := make(map[string]ChoiceConfig)
+	packages["sponsor"] = ChoiceConfig{Description: "Sponsor", PaymentPlan: "valid"}
+	packages["dealer"] = ChoiceConfig{Description: "Dealer", PaymentPlan: "unknown"}
+
+	actualErrors := url.Values{}
+	validatePaymentPlans(actualErrors, plans, packages)
+	expectedErrors := url.Values{
+		"dues.payment_plans.empty.installments":              []string{"a payment plan needs at least one installment"},
+		"dues.payment_plans.invalid.installments":            []string{"the percentages of the installments must add up to 100"},
+		"dues.payment_plans.invalid.installments.1.percent":  []string{"percent must be between 1 and 100"},
+		"dues.payment_plans.invalid.installments.1.due_date": []string{"installments must be in ascending order of due dates, installments without due date come first"},
+		"dues.payment_plans.invalid.installments.2.due_date": []string{"invalid due date, must be empty or specified as an ISO Date, as in 2024-06-01"},
+		"choices.packages.dealer.payment_plan":               []string{"must be empty or one of the keys in dues.payment_plans"},
 	}
 	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
 	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
@@ -90,3 +185,141 @@ func TestCheckOptions(t *testing.T) {
 		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
 	}
 }
+
+func TestCheckGroupConstraint(t *testing.T) {
+	c := FlagsPkgOptConfig{
+		Packages: map[string]ChoiceConfig{
+			"room-none": {Description: "no room"},
+		},
+		GroupConstraint: "!room-none,unicorn",
+	}
+
+	actualErrors := url.Values{}
+	validateGroupConstraint(actualErrors, c, "")
+	expectedErrors := url.Values{
+		"choices.group_constraint":     []string{"invalid key in group constraint, references nonexistent package"},
+		"choices.group_constraint_msg": []string{"choices.group_constraint_msg field must be at least 1 and at most 256 characters long"},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
+
+func TestCheckWebhooks(t *testing.T) {
+	c := make(map[string]WebhookConfig)
+	c["valid"] = WebhookConfig{Url: "https://example.com/hook", Secret: "long-enough-secret", Events: []string{"attendee.registered"}}
+	c["Invalid Name"] = WebhookConfig{Url: "https://example.com/hook", Secret: "long-enough-secret"}
+	c["badurl"] = WebhookConfig{Url: "ftp://example.com/hook", Secret: "long-enough-secret"}
+	c["shortsecret"] = WebhookConfig{Url: "https://example.com/hook", Secret: "short"}
+	c["badevent"] = WebhookConfig{Url: "https://example.com/hook", Secret: "long-enough-secret", Events: []string{"attendee.exploded"}}
+
+	actualErrors := url.Values{}
+	validateWebhooksConfiguration(actualErrors, c)
+	expectedErrors := url.Values{
+		"service.webhooks.Invalid Name":       []string{"invalid subscriber name, must match ^[a-z0-9_-]+$"},
+		"service.webhooks.badurl.url":         []string{"url must start with http:// or https://"},
+		"service.webhooks.shortsecret.secret": []string{"secret must be at least 16 characters long"},
+		"service.webhooks.badevent.events":    []string{"unknown event type attendee.exploded"},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
+
+func TestCheckPaymentTolerance(t *testing.T) {
+	c := DuesConfig{
+		EarliestDueDate: "2022-01-01",
+		LatestDueDate:   "2022-12-31",
+		DueDays:         14,
+		PaymentTolerance: []PaymentToleranceConfig{
+			{Currency: "EUR", Method: "transfer", Amount: 100, Percent: 1.5},
+			{Currency: "euro", Method: "bitcoin", Amount: -1, Percent: 101},
+		},
+	}
+
+	actualErrors := url.Values{}
+	validateDuesConfiguration(actualErrors, c)
+	expectedErrors := url.Values{
+		"dues.payment_tolerance.1.currency": []string{"must be empty or a three letter currency code, as in EUR"},
+		"dues.payment_tolerance.1.method":   []string{"must be empty or one of credit, paypal, cash, transfer, internal, gift"},
+		"dues.payment_tolerance.1.amount":   []string{"amount cannot be negative"},
+		"dues.payment_tolerance.1.percent":  []string{"percent must be between 0 and 100"},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
+
+func TestCheckCurrencies(t *testing.T) {
+	actualErrors := url.Values{}
+	validateCurrencies(actualErrors, "euro", []string{"GBP", "EUR", "usd", "GBP"}, map[string]ChoiceConfig{})
+	expectedErrors := url.Values{
+		"currency":                []string{"must be a three letter currency code, as in EUR"},
+		"additional_currencies.2": []string{"must be a three letter currency code, as in GBP"},
+		"additional_currencies.3": []string{"duplicate currency GBP"},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
+
+func TestCheckPricesInCurrencies(t *testing.T) {
+	packages := map[string]ChoiceConfig{
+		"attendance": {
+			Price:  10000,
+			Prices: map[string]int64{"GBP": 8500, "USD": -1},
+			AgePrices: []AgePriceConfig{
+				{MaxAge: 17, Price: 5000, Prices: map[string]int64{"GBP": 4200}},
+				{MaxAge: 5},
+			},
+			PriceSchedule: []PriceScheduleConfig{
+				{From: "2022-06-30", Price: 12000},
+			},
+		},
+		"stage": {Price: 2000, Prices: map[string]int64{"CHF": 2000, "GBP": 1700, "USD": 2200}},
+	}
+
+	actualErrors := url.Values{}
+	validateCurrencies(actualErrors, "EUR", []string{"GBP", "USD"}, packages)
+	expectedErrors := url.Values{
+		"choices.packages.attendance.prices.USD":              []string{"price cannot be negative"},
+		"choices.packages.attendance.age_prices.0.prices":     []string{"missing price in USD"},
+		"choices.packages.attendance.price_schedule.0.prices": []string{"missing price in GBP", "missing price in USD"},
+		"choices.packages.stage.prices.CHF":                   []string{"must be one of additional_currencies"},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
+
+func TestCheckInvoiceConfiguration(t *testing.T) {
+	actualErrors := url.Values{}
+	validateInvoiceConfiguration(actualErrors, InvoiceConfig{
+		Issuer:       []string{"Eurofurence e.V.", strings.Repeat("x", 81), "1", "2", "3", "4"},
+		NumberPrefix: "EF 2022",
+		Footer:       []string{"a", "b", "c", "d", "e"},
+	})
+	validateInvoiceConfiguration(actualErrors, InvoiceConfig{VatId: "DE123456789"})
+	validateInvoiceConfiguration(actualErrors, InvoiceConfig{})
+	expectedErrors := url.Values{
+		"invoices.number_prefix": []string{"must consist of at most 20 letters, digits, -, _ and /"},
+		"invoices.issuer.1":      []string{"must be at most 80 characters long"},
+		"invoices.footer":        []string{"at most 4 lines allowed"},
+		"invoices.issuer":        []string{"invoices are only issued if an issuer is configured"},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
@@ -0,0 +1,77 @@
+package dbrepo
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+)
+
+var MalformedSearchCursorError = errors.New("malformed cursor")
+var SearchCursorMismatchError = errors.New("cursor does not match sort_by and sort_order")
+
+// SearchCursor marks a position in the results of an attendee search. Results continue after the attendee
+// with the given id.
+//
+// Only the id is stored, so the cursor does not reveal any field values. The sort value is read from the
+// attendee when continuing. The exception is the relevance of fuzzy searches, which depends on the search.
+type SearchCursor struct {
+	SortBy    string  `json:"s,omitempty"`
+	SortOrder string  `json:"o,omitempty"`
+	Id        uint    `json:"i"`
+	Relevance float64 `json:"r,omitempty"`
+}
+
+// SearchSortBy returns the field the search results are actually sorted by, or the empty string
+// if sorted by id.
+func SearchSortBy(criteria *attendee.AttendeeSearchCriteria) string {
+	switch criteria.SortBy {
+	case "birthday", "city", "country", "email", "name", "nickname", "relevance", "zip":
+		return criteria.SortBy
+	default:
+		return ""
+	}
+}
+
+// SearchSortDescending returns true if the search results are sorted in descending order.
+func SearchSortDescending(criteria *attendee.AttendeeSearchCriteria) bool {
+	return criteria.SortOrder == "descending"
+}
+
+// NewSearchCursor returns the cursor that continues the search after the given search result.
+func NewSearchCursor(criteria *attendee.AttendeeSearchCriteria, last *entity.AttendeeQueryResult) string {
+	cursor := SearchCursor{
+		SortBy:    SearchSortBy(criteria),
+		Id:        last.ID,
+		Relevance: last.Relevance,
+	}
+	if SearchSortDescending(criteria) {
+		cursor.SortOrder = "descending"
+	}
+	// cannot fail for this struct
+	encoded, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// DecodeSearchCursor parses the cursor in the search criteria. Returns nil if there is none.
+func DecodeSearchCursor(criteria *attendee.AttendeeSearchCriteria) (*SearchCursor, error) {
+	if criteria.Cursor == "" {
+		return nil, nil
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(criteria.Cursor)
+	if err != nil {
+		return nil, MalformedSearchCursorError
+	}
+	cursor := SearchCursor{}
+	if err := json.Unmarshal(decoded, &cursor); err != nil || cursor.Id == 0 {
+		return nil, MalformedSearchCursorError
+	}
+
+	if cursor.SortBy != SearchSortBy(criteria) || (cursor.SortOrder == "descending") != SearchSortDescending(criteria) {
+		return nil, SearchCursorMismatchError
+	}
+	return &cursor, nil
+}
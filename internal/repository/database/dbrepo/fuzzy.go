@@ -0,0 +1,271 @@
+package dbrepo
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// relevance of a single word match, by kind of match
+const (
+	fuzzyExact    = 1.0
+	fuzzyPrefix   = 0.9
+	fuzzyTypo     = 0.8 // minus fuzzyTypoStep for each additional edit
+	fuzzyTypoStep = 0.1
+	fuzzyPhonetic = 0.6
+
+	// matches in comments count less than matches in names
+	fuzzyCommentWeight = 0.5
+)
+
+// FuzzyScore computes how well the words in query match the words in names and comments.
+//
+// Every word in the query must match some word, or the result is 0. Otherwise, the result is the
+// average relevance of the query words, between 0 (exclusive) and 1.
+//
+// Case and diacritics are ignored, so "muller" matches "Müller". Words also match if they start with the
+// query word, if they differ by a few typos after the first letter, or if they sound alike according to the
+// Cologne phonetics, which suits German names and works reasonably for others.
+func FuzzyScore(query string, names string, comments string) float64 {
+	queryWords := fuzzyWords(query)
+	if len(queryWords) == 0 {
+		return 0
+	}
+	nameWords := fuzzyWords(names)
+	commentWords := fuzzyWords(comments)
+
+	total := 0.0
+	for _, q := range queryWords {
+		best := max(bestWordRelevance(q, nameWords), fuzzyCommentWeight*bestWordRelevance(q, commentWords))
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+	return total / float64(len(queryWords))
+}
+
+func bestWordRelevance(query string, words []string) float64 {
+	best := 0.0
+	for _, w := range words {
+		best = max(best, wordRelevance(query, w))
+		if best == fuzzyExact {
+			break
+		}
+	}
+	return best
+}
+
+func wordRelevance(query string, word string) float64 {
+	if query == word {
+		return fuzzyExact
+	}
+	queryLen := len([]rune(query))
+	if queryLen >= 3 && strings.HasPrefix(word, query) {
+		return fuzzyPrefix
+	}
+	// typos in the first letter are rare, and not allowing them is what makes FuzzyPrefilter possible
+	if allowed := allowedTypos(queryLen); allowed > 0 && []rune(word)[0] == []rune(query)[0] {
+		if distance := editDistance(query, word); distance <= allowed {
+			return fuzzyTypo - fuzzyTypoStep*float64(distance-1)
+		}
+	}
+	if code := colognePhonetic(query); code != "" && code == colognePhonetic(word) {
+		return fuzzyPhonetic
+	}
+	return 0
+}
+
+// FuzzyPrefilter returns, for each word of query, the letters of which the names or comments of every
+// match contain at least one, in all their upper and lower case forms.
+//
+// This lets the database narrow down the candidates before they are scored. A word only matches if it
+// starts with the same letter as the query word, or if it sounds alike, in which case it contains a letter
+// that produces the first digit of the phonetic code of the query word.
+func FuzzyPrefilter(query string) [][]string {
+	result := make([][]string, 0)
+	for _, q := range fuzzyWords(query) {
+		letters := map[rune]bool{[]rune(q)[0]: true}
+		if code := colognePhonetic(q); code != "" {
+			for _, c := range phoneticLetters[code[0]] {
+				letters[c] = true
+			}
+		}
+		for original, replacement := range transliterations {
+			if strings.ContainsFunc(replacement, func(c rune) bool { return letters[c] }) {
+				letters[original] = true
+			}
+		}
+
+		variants := make([]string, 0, 2*len(letters))
+		for c := range letters {
+			variants = append(variants, string(c))
+			for f := unicode.SimpleFold(c); f != c; f = unicode.SimpleFold(f) {
+				variants = append(variants, string(f))
+			}
+		}
+		sort.Strings(variants)
+		result = append(result, variants)
+	}
+	return result
+}
+
+// phoneticLetters are the letters that can produce a digit of the Cologne phonetics code.
+var phoneticLetters = map[byte]string{
+	'0': "aeijouy",
+	'1': "bp",
+	'2': "dt",
+	'3': "fpvw",
+	'4': "cgkqx",
+	'5': "l",
+	'6': "mn",
+	'7': "r",
+	'8': "cdstxz",
+}
+
+func allowedTypos(wordLen int) int {
+	switch {
+	case wordLen >= 8:
+		return 2
+	case wordLen >= 4:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// fuzzyWords splits a text into lower case words without diacritics.
+func fuzzyWords(text string) []string {
+	normalized := strings.Builder{}
+	for _, c := range strings.ToLower(text) {
+		if replacement, ok := transliterations[c]; ok {
+			normalized.WriteString(replacement)
+		} else {
+			normalized.WriteRune(c)
+		}
+	}
+	return strings.FieldsFunc(normalized.String(), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	})
+}
+
+// transliterations removes diacritics from the lower case letters used in European names.
+var transliterations = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'č': "c", 'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ğ': "g", 'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'į': "i", 'ı': "i",
+	'ł': "l", 'ľ': "l", 'ĺ': "l", 'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o", 'œ': "oe",
+	'ř': "r", 'ŕ': "r", 'ś': "s", 'š': "s", 'ş': "s", 'ș': "s", 'ß': "ss",
+	'ť': "t", 'ţ': "t", 'ț': "t", 'þ': "th",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ý': "y", 'ÿ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+}
+
+// editDistance is the number of insertions, deletions, substitutions and swaps of adjacent characters
+// needed to turn a into b (optimal string alignment distance).
+func editDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	// d[i][j] is the distance between the first i runes of a and the first j runes of b
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+// colognePhonetic computes the Cologne phonetics ("Kölner Phonetik") code of a word from fuzzyWords.
+//
+// Returns the empty string for words that contain anything but the letters a-z.
+func colognePhonetic(word string) string {
+	letters := []rune(word)
+	at := func(i int) rune {
+		if i < 0 || i >= len(letters) {
+			return 0
+		}
+		return letters[i]
+	}
+
+	digits := make([]byte, 0, len(letters))
+	for i, c := range letters {
+		prev, next := at(i-1), at(i+1)
+		var code string
+		switch c {
+		case 'a', 'e', 'i', 'j', 'o', 'u', 'y':
+			code = "0"
+		case 'h':
+			code = ""
+		case 'b':
+			code = "1"
+		case 'p':
+			code = "1"
+			if next == 'h' {
+				code = "3"
+			}
+		case 'd', 't':
+			code = "2"
+			if strings.ContainsRune("csz", next) {
+				code = "8"
+			}
+		case 'f', 'v', 'w':
+			code = "3"
+		case 'g', 'k', 'q':
+			code = "4"
+		case 'c':
+			code = "8"
+			if i == 0 {
+				if strings.ContainsRune("ahkloqrux", next) {
+					code = "4"
+				}
+			} else if strings.ContainsRune("ahkoqux", next) && !strings.ContainsRune("sz", prev) {
+				code = "4"
+			}
+		case 'x':
+			code = "48"
+			if strings.ContainsRune("ckq", prev) {
+				code = "8"
+			}
+		case 'l':
+			code = "5"
+		case 'm', 'n':
+			code = "6"
+		case 'r':
+			code = "7"
+		case 's', 'z':
+			code = "8"
+		default:
+			return ""
+		}
+		digits = append(digits, code...)
+	}
+
+	// collapse repeated digits, then drop the vowels except at the start
+	result := make([]byte, 0, len(digits))
+	for i, d := range digits {
+		if i > 0 && digits[i-1] == d {
+			continue
+		}
+		if d == '0' && i > 0 {
+			continue
+		}
+		result = append(result, d)
+	}
+	return string(result)
+}
@@ -0,0 +1,69 @@
+package dbrepo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFuzzyWords(t *testing.T) {
+	require.Equal(t, []string{"jurgen", "strasse", "o", "brien"}, fuzzyWords("Jürgen Straße, O'Brien"))
+	require.Empty(t, fuzzyWords(" -- "))
+}
+
+func TestEditDistance(t *testing.T) {
+	require.Equal(t, 0, editDistance("muller", "muller"))
+	require.Equal(t, 1, editDistance("muller", "mueller"))
+	require.Equal(t, 1, editDistance("cheetah", "cheetha")) // swapped letters count once
+	require.Equal(t, 2, editDistance("smith", "smyte"))
+	require.Equal(t, 3, editDistance("abc", ""))
+}
+
+func TestColognePhonetic(t *testing.T) {
+	require.Equal(t, "657", colognePhonetic("muller"))
+	require.Equal(t, "657", colognePhonetic("mueller"))
+	require.Equal(t, "862", colognePhonetic("schmidt"))
+	require.Equal(t, "862", colognePhonetic("schmitt"))
+	require.Equal(t, "3412", colognePhonetic("wikipedia"))
+	require.Equal(t, "47823", colognePhonetic("christoph"))
+	require.Equal(t, "", colognePhonetic("r2d2"))
+}
+
+func TestFuzzyScore(t *testing.T) {
+	require.Equal(t, 1.0, FuzzyScore("Müller", "BlackCheetah Hans Muller", ""))
+	require.Equal(t, 1.0, FuzzyScore("hans muller", "BlackCheetah Hans Müller", ""))
+	require.Equal(t, fuzzyPrefix, FuzzyScore("black", "BlackCheetah Hans Müller", ""))
+	require.Equal(t, fuzzyTypo, FuzzyScore("Mueller", "BlackCheetah Hans Müller", ""))
+	require.Equal(t, fuzzyTypo, FuzzyScore("Schmitt", "Fox Jana Schmidt", ""))
+	require.Equal(t, fuzzyPhonetic, FuzzyScore("Mayr", "Fox Jana Meier", ""))
+	require.Equal(t, fuzzyCommentWeight, FuzzyScore("vegan", "Fox Jana Meier", "please note: vegan"))
+	require.Equal(t, 0.0, FuzzyScore("hans meier", "BlackCheetah Hans Müller", ""), "all words must match")
+	require.Equal(t, 0.0, FuzzyScore("", "BlackCheetah Hans Müller", ""))
+	require.Equal(t, 0.0, FuzzyScore("Buller", "BlackCheetah Hans Müller", ""), "typos in the first letter do not match")
+}
+
+func TestFuzzyPrefilter(t *testing.T) {
+	require.Equal(t, [][]string{
+		{"M", "N", "m", "n", "Ñ", "ñ", "Ń", "ń", "Ň", "ň"},
+		{"1"}, // digits have no phonetic code
+	}, FuzzyPrefilter("Mayr 1"))
+	require.Empty(t, FuzzyPrefilter(" -- "))
+}
+
+func TestFuzzyPrefilter_CoversMatches(t *testing.T) {
+	cases := []struct{ query, names, comments string }{
+		{"Müller", "BlackCheetah Hans Muller", ""},
+		{"Mueller", "BlackCheetah Hans Müller", ""},
+		{"Schmitt", "Fox Jana Schmidt", ""},
+		{"Mayr", "Fox Jana Meier", ""},
+		{"Zander", "Cäsar Sander", ""},
+		{"vegan", "Fox Jana Meier", "please note: vegan"},
+	}
+	for _, c := range cases {
+		require.Greater(t, FuzzyScore(c.query, c.names, c.comments), 0.0, c.query)
+		for _, letters := range FuzzyPrefilter(c.query) {
+			require.True(t, strings.ContainsAny(c.names+" "+c.comments, strings.Join(letters, "")), c.query)
+		}
+	}
+}
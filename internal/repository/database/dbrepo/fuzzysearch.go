@@ -0,0 +1,163 @@
+package dbrepo
+
+import (
+	"sort"
+
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+)
+
+// HasFuzzyCriteria returns true if any of the criteria uses a fuzzy search text.
+//
+// Fuzzy matching cannot be fully expressed in the query languages of the databases, so these searches
+// are run through FuzzySearchMatches and FuzzySearchPage instead.
+func HasFuzzyCriteria(criteria *attendee.AttendeeSearchCriteria) bool {
+	for _, cond := range criteria.MatchAny {
+		if cond.Fuzzy != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// FuzzySearchMatches returns all attendees that match the criteria, in sort order, with their relevance set.
+//
+// find is called once for each criterion. It must not score the fuzzy text, but should narrow down the
+// candidates using FuzzyPrefilter, so not every attendee has to be read. The candidates are then filtered
+// and scored here. An attendee matched by more than one criterion gets the best relevance. Criteria
+// without a fuzzy text match with relevance 1.
+func FuzzySearchMatches(criteria *attendee.AttendeeSearchCriteria, find func(criteria *attendee.AttendeeSearchCriteria) ([]*entity.AttendeeQueryResult, error)) ([]*entity.AttendeeQueryResult, error) {
+	matches := make(map[uint]*entity.AttendeeQueryResult)
+	for _, cond := range criteria.MatchAny {
+		fuzzy := cond.Fuzzy
+		candidates, err := find(&attendee.AttendeeSearchCriteria{
+			MatchAny:   []attendee.AttendeeSearchSingleCriterion{cond},
+			MinId:      criteria.MinId,
+			MaxId:      criteria.MaxId,
+			FillFields: []string{"all"}, // need all fields we match or sort on
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, candidate := range candidates {
+			relevance := 1.0
+			if fuzzy != "" {
+				relevance = FuzzyScore(fuzzy, fuzzyNames(candidate), fuzzyComments(candidate))
+			}
+			if relevance == 0 {
+				continue
+			}
+			if previous, ok := matches[candidate.ID]; !ok || previous.Relevance < relevance {
+				candidate.Relevance = relevance
+				matches[candidate.ID] = candidate
+			}
+		}
+	}
+
+	result := make([]*entity.AttendeeQueryResult, 0, len(matches))
+	for _, match := range matches {
+		result = append(result, match)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return lessFuzzyMatch(result[i], result[j], criteria)
+	})
+	return result, nil
+}
+
+// FuzzySearchPage returns the part of the sorted matches that comes after the cursor, limited to
+// the requested number of results.
+//
+// If the attendee the cursor points to is no longer among the matches, it is read with find, so
+// the page continues at the right position.
+func FuzzySearchPage(criteria *attendee.AttendeeSearchCriteria, cursor *SearchCursor, matches []*entity.AttendeeQueryResult, find func(criteria *attendee.AttendeeSearchCriteria) ([]*entity.AttendeeQueryResult, error)) ([]*entity.AttendeeQueryResult, error) {
+	if cursor != nil {
+		position, err := fuzzyCursorPosition(cursor, matches, find)
+		if err != nil {
+			return nil, err
+		}
+		if position == nil {
+			return []*entity.AttendeeQueryResult{}, nil
+		}
+		first := sort.Search(len(matches), func(i int) bool {
+			return lessFuzzyMatch(position, matches[i], criteria)
+		})
+		matches = matches[first:]
+	}
+
+	if criteria.NumResults > 0 && len(matches) > int(criteria.NumResults) {
+		matches = matches[:criteria.NumResults]
+	}
+	return matches, nil
+}
+
+func fuzzyCursorPosition(cursor *SearchCursor, matches []*entity.AttendeeQueryResult, find func(criteria *attendee.AttendeeSearchCriteria) ([]*entity.AttendeeQueryResult, error)) (*entity.AttendeeQueryResult, error) {
+	for _, match := range matches {
+		if match.ID == cursor.Id {
+			return match, nil
+		}
+	}
+
+	found, err := find(&attendee.AttendeeSearchCriteria{
+		MatchAny: []attendee.AttendeeSearchSingleCriterion{{
+			Ids:    []uint{cursor.Id},
+			Status: config.AllowedStatusValues(),
+		}},
+		FillFields: []string{"all"},
+	})
+	if err != nil || len(found) == 0 {
+		return nil, err
+	}
+	position := found[0]
+	position.Relevance = cursor.Relevance
+	return position, nil
+}
+
+// lessFuzzyMatch compares by the sort field first, then by id, so the order is unique.
+//
+// Sorting by relevance puts the best matches first, unless the sort order is descending.
+func lessFuzzyMatch(a1 *entity.AttendeeQueryResult, a2 *entity.AttendeeQueryResult, criteria *attendee.AttendeeSearchCriteria) bool {
+	descending := SearchSortDescending(criteria)
+	sortBy := SearchSortBy(criteria)
+	if sortBy == "relevance" {
+		if a1.Relevance != a2.Relevance {
+			return (a1.Relevance > a2.Relevance) != descending
+		}
+	} else if sortBy != "" {
+		v1, v2 := fuzzySortValue(a1, sortBy), fuzzySortValue(a2, sortBy)
+		if v1 != v2 {
+			return (v1 < v2) != descending
+		}
+	}
+	return (a1.ID < a2.ID) != descending
+}
+
+func fuzzySortValue(a *entity.AttendeeQueryResult, sortBy string) string {
+	switch sortBy {
+	case "birthday":
+		return a.Birthday
+	case "city":
+		return a.City
+	case "country":
+		return a.Country
+	case "email":
+		return a.Email
+	case "name":
+		return a.FirstName + " " + a.LastName
+	case "nickname":
+		return a.Nickname
+	case "zip":
+		return a.Zip
+	default:
+		return ""
+	}
+}
+
+func fuzzyNames(a *entity.AttendeeQueryResult) string {
+	return a.Nickname + " " + a.FirstName + " " + a.LastName
+}
+
+func fuzzyComments(a *entity.AttendeeQueryResult) string {
+	return a.UserComments + " " + a.AdminComments
+}
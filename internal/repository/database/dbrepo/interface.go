@@ -2,6 +2,7 @@ package dbrepo
 
 import (
 	"context"
+	"time"
 
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
@@ -10,7 +11,18 @@ import (
 type Repository interface {
 	Open() error
 	Close()
+
+	// Migrate applies all pending schema migrations.
 	Migrate() error
+	// MigrateDownTo reverts all schema migrations after the given version.
+	MigrateDownTo(version uint) error
+	MigrationStatus() ([]MigrationStatus, error)
+
+	// WithTransaction runs f as a single unit of work. If f returns an error, all changes made through
+	// the repository passed to f are rolled back, otherwise they are committed.
+	//
+	// Only use the repository passed to f while inside f. Nested transactions are not supported.
+	WithTransaction(ctx context.Context, f func(repo Repository) error) error
 
 	AddAttendee(ctx context.Context, a *entity.Attendee) (uint, error)
 	UpdateAttendee(ctx context.Context, a *entity.Attendee) error
@@ -32,7 +44,17 @@ type Repository interface {
 	GetStatusChangesByAttendeeId(ctx context.Context, attendeeId uint) ([]entity.StatusChange, error)
 	AddStatusChange(ctx context.Context, sc *entity.StatusChange) error
 
+	// FindAttendees returns the attendees matching the criteria, in the requested order.
+	//
+	// Results start after the cursor, if the criteria contain one, and are limited to NumResults, if set.
 	FindAttendees(ctx context.Context, criteria *attendee.AttendeeSearchCriteria) ([]*entity.AttendeeQueryResult, error)
+	// StreamAttendees works like FindAttendees, but passes each result to f as it is read, instead of
+	// collecting them all in memory. Stops at the first error returned by f.
+	//
+	// f must not access the database, because the result set may still be holding the connection.
+	StreamAttendees(ctx context.Context, criteria *attendee.AttendeeSearchCriteria, f func(a *entity.AttendeeQueryResult) error) error
+	// CountAttendees counts all attendees matching the criteria, ignoring the cursor and NumResults.
+	CountAttendees(ctx context.Context, criteria *attendee.AttendeeSearchCriteria) (int64, error)
 	FindByIdentity(ctx context.Context, identity string) ([]*entity.Attendee, error)
 
 	GetAllBans(ctx context.Context) ([]*entity.Ban, error)
@@ -41,6 +63,69 @@ type Repository interface {
 	UpdateBan(ctx context.Context, b *entity.Ban) error
 	DeleteBan(ctx context.Context, b *entity.Ban) error
 
+	// GetAllSavedSearches returns all saved searches, ordered by name.
+	GetAllSavedSearches(ctx context.Context) ([]*entity.SavedSearch, error)
+	GetSavedSearchByName(ctx context.Context, name string) (*entity.SavedSearch, error)
+	AddSavedSearch(ctx context.Context, s *entity.SavedSearch) (uint, error)
+	UpdateSavedSearch(ctx context.Context, s *entity.SavedSearch) error
+	DeleteSavedSearch(ctx context.Context, s *entity.SavedSearch) error
+
+	// GetPendingTransferFor returns the transfer offer for the attendee that is still pending, or an error if there is none.
+	GetPendingTransferFor(ctx context.Context, attendeeId uint) (*entity.Transfer, error)
+	GetTransferByCode(ctx context.Context, code string) (*entity.Transfer, error)
+	AddTransfer(ctx context.Context, t *entity.Transfer) (uint, error)
+	UpdateTransfer(ctx context.Context, t *entity.Transfer) error
+
+	GetGroupById(ctx context.Context, id uint) (*entity.Group, error)
+	AddGroup(ctx context.Context, g *entity.Group) (uint, error)
+	// DeleteGroup removes the group only. Remove its members first.
+	DeleteGroup(ctx context.Context, g *entity.Group) error
+
+	// GetGroupMembers returns the members and open invitations of a group, in the order they were invited.
+	GetGroupMembers(ctx context.Context, groupId uint) ([]*entity.GroupMember, error)
+	// GetGroupMembershipsByAttendeeId returns the memberships and open invitations of an attendee, across all groups.
+	GetGroupMembershipsByAttendeeId(ctx context.Context, attendeeId uint) ([]*entity.GroupMember, error)
+	GetGroupMember(ctx context.Context, groupId uint, attendeeId uint) (*entity.GroupMember, error)
+	AddGroupMember(ctx context.Context, m *entity.GroupMember) (uint, error)
+	UpdateGroupMember(ctx context.Context, m *entity.GroupMember) error
+	DeleteGroupMember(ctx context.Context, m *entity.GroupMember) error
+
+	// GetPackagePricesByAttendeeId returns the locked package prices of an attendee.
+	GetPackagePricesByAttendeeId(ctx context.Context, attendeeId uint) ([]*entity.PackagePrice, error)
+
+	// AddPackagePrice locks the price of a package for an attendee. Fails if there already is a lock.
+	//
+	// Note: price locks are not historized, they never change once created.
+	AddPackagePrice(ctx context.Context, p *entity.PackagePrice) (uint, error)
+
+	// GetInvoicesByAttendeeId returns the invoices of an attendee, ordered by number.
+	GetInvoicesByAttendeeId(ctx context.Context, attendeeId uint) ([]*entity.Invoice, error)
+
+	// AddInvoice assigns the next invoice number and stores the invoice.
+	//
+	// Note: invoices are not historized, they never change once issued.
+	AddInvoice(ctx context.Context, i *entity.Invoice) (uint, error)
+
+	// GetAllVouchers returns all vouchers, ordered by code.
+	GetAllVouchers(ctx context.Context) ([]*entity.Voucher, error)
+	GetVoucherById(ctx context.Context, id uint) (*entity.Voucher, error)
+	GetVoucherByCode(ctx context.Context, code string) (*entity.Voucher, error)
+	// GetVoucherByCodeForUpdate works like GetVoucherByCode, but also locks the voucher until the end of the
+	// transaction, so redemptions of the same voucher happen one after the other.
+	GetVoucherByCodeForUpdate(ctx context.Context, code string) (*entity.Voucher, error)
+	AddVoucher(ctx context.Context, v *entity.Voucher) (uint, error)
+	UpdateVoucher(ctx context.Context, v *entity.Voucher) error
+	// DeleteVoucher removes the voucher only. It should not have any redemptions.
+	DeleteVoucher(ctx context.Context, v *entity.Voucher) error
+
+	// GetVoucherRedemptions returns all redemptions of a voucher, in the order they were made.
+	GetVoucherRedemptions(ctx context.Context, voucherId uint) ([]*entity.VoucherRedemption, error)
+	// GetVoucherRedemptionsByAttendeeId returns the redemptions of an attendee, in the order they were made.
+	GetVoucherRedemptionsByAttendeeId(ctx context.Context, attendeeId uint) ([]*entity.VoucherRedemption, error)
+	GetVoucherRedemption(ctx context.Context, voucherId uint, attendeeId uint) (*entity.VoucherRedemption, error)
+	AddVoucherRedemption(ctx context.Context, vr *entity.VoucherRedemption) (uint, error)
+	DeleteVoucherRedemption(ctx context.Context, vr *entity.VoucherRedemption) error
+
 	GetAllAdditionalInfoForArea(ctx context.Context, area string) ([]*entity.AdditionalInfo, error)
 	GetAdditionalInfoFor(ctx context.Context, attendeeId uint, area string) (*entity.AdditionalInfo, error)
 	WriteAdditionalInfo(ctx context.Context, ad *entity.AdditionalInfo) error
@@ -69,6 +154,75 @@ type Repository interface {
 
 	// GetCount obtains the current count for area and name.
 	GetCount(ctx context.Context, area string, name string) (*entity.Count, error)
+	// GetCountForUpdate works like GetCount, but also locks the count until the end of the transaction,
+	// so concurrent limit checks for the same area and name happen one after the other.
+	//
+	// Lock counts in a consistent order, e.g. sorted by name, to avoid deadlocks.
+	GetCountForUpdate(ctx context.Context, area string, name string) (*entity.Count, error)
+
+	// GetWaitlistForPackage returns the waiting list entries for a package in queue order.
+	GetWaitlistForPackage(ctx context.Context, pkg string) ([]*entity.WaitlistEntry, error)
+
+	// GetWaitlistEntriesByAttendeeId returns all waiting list entries for an attendee, across all packages.
+	GetWaitlistEntriesByAttendeeId(ctx context.Context, attendeeId uint) ([]*entity.WaitlistEntry, error)
+
+	// AddWaitlistEntry appends an attendee to the end of the waiting list for a package.
+	//
+	// Note: waiting list changes are not historized, the status changes they cause are.
+	AddWaitlistEntry(ctx context.Context, e *entity.WaitlistEntry) (uint, error)
+	UpdateWaitlistEntry(ctx context.Context, e *entity.WaitlistEntry) error
+	DeleteWaitlistEntry(ctx context.Context, e *entity.WaitlistEntry) error
+
+	// AddOutboxMail records a mail for delivery. Should be called in the same unit of work as the change
+	// that causes the mail.
+	//
+	// Note: the outbox is not historized.
+	AddOutboxMail(ctx context.Context, m *entity.OutboxMail) (uint, error)
+	UpdateOutboxMail(ctx context.Context, m *entity.OutboxMail) error
+	GetOutboxMailById(ctx context.Context, id uint) (*entity.OutboxMail, error)
+
+	// GetOutboxMailsByState returns all outbox mails in the given state, oldest first.
+	GetOutboxMailsByState(ctx context.Context, state string) ([]*entity.OutboxMail, error)
+
+	// GetDueOutboxMails returns up to limit pending outbox mails whose next delivery attempt is due at the given time,
+	// oldest first. This includes mails whose delivery attempt was lost, see ClaimOutboxMail.
+	GetDueOutboxMails(ctx context.Context, now time.Time, limit int) ([]*entity.OutboxMail, error)
+
+	// ClaimOutboxMail sets a pending or failed outbox mail to sending until leaseUntil, so no one else attempts
+	// delivery at the same time. The update is made as an atomic operation.
+	//
+	// Returns false if the mail is already being sent, or if its state or attempts have changed since it was read.
+	// A mail that is still sending after leaseUntil can be claimed again.
+	ClaimOutboxMail(ctx context.Context, m *entity.OutboxMail, now time.Time, leaseUntil time.Time) (bool, error)
+
+	// DeleteOutboxMail discards an outbox mail (soft delete).
+	DeleteOutboxMail(ctx context.Context, m *entity.OutboxMail) error
+
+	// AddWebhookDelivery queues an event for delivery to a webhook subscriber. Should be called in the same unit
+	// of work as the change that causes the event.
+	//
+	// Note: webhook deliveries are not historized.
+	AddWebhookDelivery(ctx context.Context, d *entity.WebhookDelivery) (uint, error)
+	UpdateWebhookDelivery(ctx context.Context, d *entity.WebhookDelivery) error
+
+	// GetPendingWebhookDeliveries returns up to limit pending deliveries for a subscriber in queue order,
+	// regardless of whether they are due yet.
+	GetPendingWebhookDeliveries(ctx context.Context, subscriber string, limit int) ([]*entity.WebhookDelivery, error)
+
+	// LockWebhookQueue takes the delivery queue of a subscriber for holder until lockedUntil. The update is made
+	// as an atomic operation.
+	//
+	// Returns false if someone else holds the queue after now. The holder can extend its own lock.
+	LockWebhookQueue(ctx context.Context, subscriber string, holder string, now time.Time, lockedUntil time.Time) (bool, error)
+
+	// UnlockWebhookQueue releases the delivery queue of a subscriber, if holder still holds it.
+	UnlockWebhookQueue(ctx context.Context, subscriber string, holder string) error
+
+	// GetWebhookDeliveryLog returns up to limit deliveries, newest first. Blank subscriber or state and
+	// attendeeId 0 mean no condition.
+	GetWebhookDeliveryLog(ctx context.Context, subscriber string, state string, attendeeId uint, limit int) ([]*entity.WebhookDelivery, error)
 
 	RecordHistory(ctx context.Context, h *entity.History) error
+	// GetHistoryByEntity returns the history entries for an entity, oldest first.
+	GetHistoryByEntity(ctx context.Context, entityName string, entityId uint) ([]*entity.History, error)
 }
@@ -0,0 +1,15 @@
+package dbrepo
+
+import "time"
+
+// MigrationStatus describes a single versioned schema migration.
+type MigrationStatus struct {
+	Version   uint
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+
+	// Unknown is set for migrations that have been applied to the database, but are not known to this
+	// version of the service. This means the database schema is ahead of the service.
+	Unknown bool
+}
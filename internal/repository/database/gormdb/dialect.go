@@ -0,0 +1,46 @@
+package gormdb
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"gorm.io/gorm"
+)
+
+// Dialect covers what differs between the sql databases we support.
+//
+// Everything else is written in sql that all of them understand.
+type Dialect struct {
+	Type config.DatabaseType
+
+	// Open returns the gorm dialector for the driver.
+	Open func(connectString string) gorm.Dialector
+
+	ConnectString func() string
+
+	// ConfigurePool sets up the connection pool after opening.
+	ConfigurePool func(sqlDb *sql.DB)
+
+	// CloseOnShutdown closes the connection pool in Close, which discards in-memory databases.
+	CloseOnShutdown bool
+
+	// CaseInsensitiveCollation is set if = already compares strings case-insensitively, so indexes can be used.
+	CaseInsensitiveCollation bool
+
+	// NewestStatusSubQuery selects attendee_id and status of the latest status change of each attendee.
+	NewestStatusSubQuery string
+
+	// Concat concatenates string expressions, none of which may be NULL.
+	Concat func(parts ...string) string
+}
+
+// ConcatFunction is a Concat that uses the CONCAT function.
+func ConcatFunction(parts ...string) string {
+	return "CONCAT(" + strings.Join(parts, ", ") + ")"
+}
+
+// ConcatOperator is a Concat that uses the standard sql || operator.
+func ConcatOperator(parts ...string) string {
+	return strings.Join(parts, " || ")
+}
@@ -0,0 +1,1382 @@
+package gormdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database/dbrepo"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database/migrations"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// GormRepository implements the repository on top of gorm, for all sql databases we support.
+//
+// The differences between the databases are covered by its Dialect.
+type GormRepository struct {
+	dialect Dialect
+	db      *gorm.DB
+	Now     func() time.Time
+}
+
+func Create(dialect Dialect) dbrepo.Repository {
+	return &GormRepository{
+		dialect: dialect,
+		Now:     time.Now,
+	}
+}
+
+func (r *GormRepository) Open() error {
+	gormConfig := gorm.Config{
+		NamingStrategy: schema.NamingStrategy{
+			TablePrefix: "att_",
+		},
+		Logger: logger.Default.LogMode(logger.Silent),
+	}
+	connectString := r.dialect.ConnectString()
+
+	db, err := gorm.Open(r.dialect.Open(connectString), &gormConfig)
+	if err != nil {
+		aulogging.Logger.NoCtx().Error().WithErr(err).Printf("failed to open %s connection: %s", r.dialect.Type, err.Error())
+		return err
+	}
+
+	sqlDb, err := db.DB()
+	if err != nil {
+		aulogging.Logger.NoCtx().Error().WithErr(err).Printf("failed to configure %s connection: %s", r.dialect.Type, err.Error())
+		return err
+	}
+	r.dialect.ConfigurePool(sqlDb)
+
+	r.db = db
+	return nil
+}
+
+func (r *GormRepository) Close() {
+	// no more db close in gorm v2, except where the dialect needs it
+	if !r.dialect.CloseOnShutdown {
+		return
+	}
+	sqlDb, err := r.db.DB()
+	if err != nil {
+		aulogging.Logger.NoCtx().Warn().WithErr(err).Printf("failed to obtain %s connection for closing: %s", r.dialect.Type, err.Error())
+		return
+	}
+	if err := sqlDb.Close(); err != nil {
+		aulogging.Logger.NoCtx().Warn().WithErr(err).Printf("failed to close %s connection: %s", r.dialect.Type, err.Error())
+	}
+}
+
+func (r *GormRepository) Migrate() error {
+	runner, err := r.migrationRunner()
+	if err != nil {
+		return err
+	}
+	return runner.Up()
+}
+
+func (r *GormRepository) MigrateDownTo(version uint) error {
+	runner, err := r.migrationRunner()
+	if err != nil {
+		return err
+	}
+	return runner.DownTo(version)
+}
+
+func (r *GormRepository) MigrationStatus() ([]dbrepo.MigrationStatus, error) {
+	runner, err := r.migrationRunner()
+	if err != nil {
+		return nil, err
+	}
+	return runner.Status()
+}
+
+func (r *GormRepository) migrationRunner() (*migrations.Runner, error) {
+	runner, err := migrations.New(r.db, r.dialect.Type)
+	if err != nil {
+		aulogging.Logger.NoCtx().Error().WithErr(err).Printf("failed to load %s migrations: %s", r.dialect.Type, err.Error())
+		return nil, err
+	}
+	runner.Now = r.Now
+	return runner, nil
+}
+
+func (r *GormRepository) WithTransaction(ctx context.Context, f func(repo dbrepo.Repository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return f(&GormRepository{
+			dialect: r.dialect,
+			db:      tx,
+			Now:     r.Now,
+		})
+	})
+}
+
+// --- attendee ---
+
+func (r *GormRepository) AddAttendee(ctx context.Context, a *entity.Attendee) (uint, error) {
+	err := r.db.Create(a).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during attendee insert: %s", err.Error())
+	}
+	return a.ID, err
+}
+
+func (r *GormRepository) UpdateAttendee(ctx context.Context, a *entity.Attendee) error {
+	// allow updating deleted (because the admin ui allows it)
+	err := r.db.Unscoped().Save(a).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during attendee update: %s", err.Error())
+	}
+	return err
+}
+
+func (r *GormRepository) GetAttendeeById(ctx context.Context, id uint) (*entity.Attendee, error) {
+	var a entity.Attendee
+	// allow reading deleted so history and undelete work
+	err := r.db.Unscoped().First(&a, id).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Info().WithErr(err).Printf("database error during attendee select - might be ok: %s", err.Error())
+	}
+	return &a, err
+}
+
+func (r *GormRepository) SoftDeleteAttendeeById(ctx context.Context, id uint) error {
+	var a entity.Attendee
+	err := r.db.First(&a, id).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during attendee soft delete - attendee not found: %s", err.Error())
+		return err
+	}
+	err = r.db.Delete(&a).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during attendee soft delete - deletion failed: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *GormRepository) UndeleteAttendeeById(ctx context.Context, id uint) error {
+	var a entity.Attendee
+	err := r.db.Unscoped().First(&a, id).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during attendee undelete - attendee not found: %s", err.Error())
+		return err
+	}
+	err = r.db.Unscoped().Model(&a).Where("id", id).Update("deleted_at", nil).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during attendee undelete: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *GormRepository) CountAttendeesByNicknameZipEmail(ctx context.Context, nickname string, zip string, email string) (int64, error) {
+	var count int64
+	// count deleted because the unique index in the db will
+	query := r.db.Unscoped().Model(&entity.Attendee{})
+	if r.dialect.CaseInsensitiveCollation {
+		query = query.Where(&entity.Attendee{Nickname: nickname, Zip: zip, Email: email})
+	} else {
+		// compare case-insensitively, like the utf8mb4_general_ci collation does on mysql
+		query = query.Where("LOWER(nickname) = LOWER(?) AND LOWER(zip) = LOWER(?) AND LOWER(email) = LOWER(?)", nickname, zip, email)
+	}
+	err := query.Count(&count).Error
+	if err != nil {
+		return -1, err
+	}
+	return count, nil
+}
+
+func (r *GormRepository) CountAttendeesByIdentity(ctx context.Context, identity string) (int64, error) {
+	var count int64
+	// count deleted because the unique index in the db will
+	err := r.db.Unscoped().Model(&entity.Attendee{}).Where(&entity.Attendee{Identity: identity}).Count(&count).Error
+	if err != nil {
+		return -1, err
+	}
+	return count, nil
+}
+
+func (r *GormRepository) MaxAttendeeId(ctx context.Context) (uint, error) {
+	var max uint
+	// count deleted
+	rows, err := r.db.Unscoped().Model(&entity.Attendee{}).Select("coalesce(max(id),0) AS max_id").Rows()
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error querying for max attendee id: %s", err.Error())
+		return 0, err
+	}
+	for rows.Next() {
+		err = rows.Scan(&max)
+		if err != nil {
+			aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading max attendee id: %s", err.Error())
+			break
+		}
+	}
+	err2 := rows.Close()
+	if err2 != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err2).Printf("secondary error closing recordset: %s", err2.Error())
+	}
+	return max, err
+}
+
+// --- attendee search ---
+
+func (r *GormRepository) FindAttendees(ctx context.Context, criteria *attendee.AttendeeSearchCriteria) ([]*entity.AttendeeQueryResult, error) {
+	result := make([]*entity.AttendeeQueryResult, 0)
+	err := r.StreamAttendees(ctx, criteria, func(a *entity.AttendeeQueryResult) error {
+		result = append(result, a)
+		return nil
+	})
+	return result, err
+}
+
+func (r *GormRepository) StreamAttendees(ctx context.Context, criteria *attendee.AttendeeSearchCriteria, f func(a *entity.AttendeeQueryResult) error) error {
+	cursor, err := dbrepo.DecodeSearchCursor(criteria)
+	if err != nil {
+		return err
+	}
+	if dbrepo.HasFuzzyCriteria(criteria) {
+		return r.streamFuzzyAttendees(ctx, criteria, cursor, f)
+	}
+	return r.streamAttendeeQuery(ctx, criteria, cursor, f)
+}
+
+// streamAttendeeQuery runs the search in sql. Fuzzy criteria are only prefiltered, see FuzzyPrefilter.
+func (r *GormRepository) streamAttendeeQuery(ctx context.Context, criteria *attendee.AttendeeSearchCriteria, cursor *dbrepo.SearchCursor, f func(a *entity.AttendeeQueryResult) error) error {
+	params := make(map[string]interface{})
+	query := r.constructAttendeeSearchQuery(ctx, criteria, cursor, params)
+
+	// Raw finds deleted attendees
+	rows, err := r.db.Raw(query, params).Rows()
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error finding attendees: %s", err.Error())
+		return err
+	}
+	defer func() {
+		err2 := rows.Close()
+		if err2 != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err2).Printf("secondary error closing recordset during find: %s", err2.Error())
+		}
+	}()
+
+	for rows.Next() {
+		attendeeBuffer := entity.AttendeeQueryResult{}
+		err = r.db.ScanRows(rows, &attendeeBuffer)
+		if err != nil {
+			aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading attendeeBuffer during find: %s", err.Error())
+			return err
+		}
+		if err := f(&attendeeBuffer); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (r *GormRepository) CountAttendees(ctx context.Context, criteria *attendee.AttendeeSearchCriteria) (int64, error) {
+	if dbrepo.HasFuzzyCriteria(criteria) {
+		matches, err := dbrepo.FuzzySearchMatches(criteria, r.fuzzyFind(ctx))
+		return int64(len(matches)), err
+	}
+
+	countCriteria := *criteria
+	countCriteria.FillFields = []string{"id"}
+	countCriteria.NumResults = 0
+	params := make(map[string]interface{})
+	query := "SELECT COUNT(*) FROM ( " + r.constructAttendeeSearchQuery(ctx, &countCriteria, nil, params) + " ) AS matches"
+
+	var count int64
+	err := r.db.Raw(query, params).Scan(&count).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error counting attendees: %s", err.Error())
+	}
+	return count, err
+}
+
+// streamFuzzyAttendees filters and sorts searches with fuzzy criteria in memory, because the matching
+// cannot be done in sql. The database only prefilters the candidates.
+func (r *GormRepository) streamFuzzyAttendees(ctx context.Context, criteria *attendee.AttendeeSearchCriteria, cursor *dbrepo.SearchCursor, f func(a *entity.AttendeeQueryResult) error) error {
+	matches, err := dbrepo.FuzzySearchMatches(criteria, r.fuzzyFind(ctx))
+	if err != nil {
+		return err
+	}
+	page, err := dbrepo.FuzzySearchPage(criteria, cursor, matches, r.fuzzyFind(ctx))
+	if err != nil {
+		return err
+	}
+	for _, a := range page {
+		if err := f(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *GormRepository) fuzzyFind(ctx context.Context) func(criteria *attendee.AttendeeSearchCriteria) ([]*entity.AttendeeQueryResult, error) {
+	return func(criteria *attendee.AttendeeSearchCriteria) ([]*entity.AttendeeQueryResult, error) {
+		result := make([]*entity.AttendeeQueryResult, 0)
+		err := r.streamAttendeeQuery(ctx, criteria, nil, func(a *entity.AttendeeQueryResult) error {
+			result = append(result, a)
+			return nil
+		})
+		return result, err
+	}
+}
+
+// --- admin info ---
+
+func (r *GormRepository) GetAdminInfoByAttendeeId(ctx context.Context, attendeeId uint) (*entity.AdminInfo, error) {
+	var ai entity.AdminInfo
+	err := r.db.First(&ai, attendeeId).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ai.ID = attendeeId
+			err = nil // acceptable situation - we only write admin info on change
+		} else {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during admin info select - not record not found: %s", err.Error())
+			ai.ID = attendeeId
+		}
+	}
+	return &ai, err
+}
+
+func (r *GormRepository) WriteAdminInfo(ctx context.Context, ai *entity.AdminInfo) error {
+	err := r.db.Save(ai).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during admin info save: %s", err.Error())
+	}
+	return err
+}
+
+// --- status changes ---
+
+func (r *GormRepository) GetLatestStatusChangeByAttendeeId(ctx context.Context, attendeeId uint) (*entity.StatusChange, error) {
+	var sc entity.StatusChange
+	err := r.db.Model(&entity.StatusChange{}).Where(&entity.StatusChange{AttendeeId: attendeeId}).Last(&sc).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sc = entity.StatusChange{
+				AttendeeId: attendeeId,
+				Status:     status.New,
+				Comments:   "",
+			}
+			err = nil
+		}
+	}
+	return &sc, err
+}
+
+func (r *GormRepository) GetStatusChangesByAttendeeId(ctx context.Context, attendeeId uint) ([]entity.StatusChange, error) {
+	rows, err := r.db.Model(&entity.StatusChange{}).Where(&entity.StatusChange{AttendeeId: attendeeId}).Rows()
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during status change select: %s", err.Error())
+		return make([]entity.StatusChange, 0), err
+	}
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during status change result set close: %s", err.Error())
+		}
+	}()
+
+	result := make([]entity.StatusChange, 0)
+	for rows.Next() {
+		var sc entity.StatusChange
+		err := r.db.ScanRows(rows, &sc)
+		if err != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during status change read: %s", err.Error())
+			return make([]entity.StatusChange, 0), err
+		}
+
+		result = append(result, sc)
+	}
+
+	return result, nil
+}
+
+func (r *GormRepository) AddStatusChange(ctx context.Context, sc *entity.StatusChange) error {
+	err := r.db.Create(sc).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during status change insert: %s", err.Error())
+	}
+	return err
+}
+
+func (r *GormRepository) FindByIdentity(ctx context.Context, identity string) ([]*entity.Attendee, error) {
+	result := make([]*entity.Attendee, 0)
+	rows, err := r.db.Model(&entity.Attendee{}).Where(&entity.Attendee{Identity: identity}).Rows()
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during identity select: %s", err.Error())
+		return result, err
+	}
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during attendee by identity result set close: %s", err.Error())
+		}
+	}()
+
+	for rows.Next() {
+		var a entity.Attendee
+		err := r.db.ScanRows(rows, &a)
+		if err != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during attendee by identity read: %s", err.Error())
+			return make([]*entity.Attendee, 0), err
+		}
+
+		result = append(result, &a)
+	}
+
+	return result, nil
+}
+
+// --- bans ---
+
+func (r *GormRepository) GetAllBans(ctx context.Context) ([]*entity.Ban, error) {
+	result := make([]*entity.Ban, 0)
+	banBuffer := entity.Ban{}
+
+	rows, err := r.db.Model(&entity.Ban{}).Order("id").Rows()
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading bans: %s", err.Error())
+		return result, err
+	}
+	defer func() {
+		err2 := rows.Close()
+		if err2 != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err2).Printf("secondary error closing recordset during find: %s", err2.Error())
+		}
+	}()
+
+	for rows.Next() {
+		err = r.db.ScanRows(rows, &banBuffer)
+		if err != nil {
+			aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading ban during find: %s", err.Error())
+			return result, err
+		}
+		copiedBan := banBuffer
+		result = append(result, &copiedBan)
+	}
+
+	return result, nil
+}
+
+func (r *GormRepository) GetBanById(ctx context.Context, id uint) (*entity.Ban, error) {
+	var b entity.Ban
+	err := r.db.First(&b, id).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Info().WithErr(err).Printf("database error during ban select - might be ok: %s", err.Error())
+	}
+	return &b, err
+}
+
+func (r *GormRepository) AddBan(ctx context.Context, b *entity.Ban) (uint, error) {
+	err := r.db.Create(b).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during ban insert: %s", err.Error())
+	}
+	return b.ID, err
+}
+
+func (r *GormRepository) UpdateBan(ctx context.Context, b *entity.Ban) error {
+	err := r.db.Save(b).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during ban update: %s", err.Error())
+	}
+	return err
+}
+
+func (r *GormRepository) DeleteBan(ctx context.Context, b *entity.Ban) error {
+	err := r.db.Delete(b).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during ban delete: %s", err.Error())
+	}
+	return err
+}
+
+// --- saved searches ---
+
+func (r *GormRepository) GetAllSavedSearches(ctx context.Context) ([]*entity.SavedSearch, error) {
+	result := make([]*entity.SavedSearch, 0)
+	searchBuffer := entity.SavedSearch{}
+
+	rows, err := r.db.Model(&entity.SavedSearch{}).Order("name").Rows()
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading saved searches: %s", err.Error())
+		return result, err
+	}
+	defer func() {
+		err2 := rows.Close()
+		if err2 != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err2).Printf("secondary error closing recordset during saved search read: %s", err2.Error())
+		}
+	}()
+
+	for rows.Next() {
+		err = r.db.ScanRows(rows, &searchBuffer)
+		if err != nil {
+			aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading saved search: %s", err.Error())
+			return result, err
+		}
+		copiedSearch := searchBuffer
+		result = append(result, &copiedSearch)
+	}
+
+	return result, nil
+}
+
+func (r *GormRepository) GetSavedSearchByName(ctx context.Context, name string) (*entity.SavedSearch, error) {
+	var s entity.SavedSearch
+	err := r.db.Where(&entity.SavedSearch{Name: name}).First(&s).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Info().WithErr(err).Printf("database error during saved search select - might be ok: %s", err.Error())
+	}
+	return &s, err
+}
+
+func (r *GormRepository) AddSavedSearch(ctx context.Context, s *entity.SavedSearch) (uint, error) {
+	err := r.db.Create(s).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during saved search insert: %s", err.Error())
+	}
+	return s.ID, err
+}
+
+func (r *GormRepository) UpdateSavedSearch(ctx context.Context, s *entity.SavedSearch) error {
+	err := r.db.Save(s).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during saved search update: %s", err.Error())
+	}
+	return err
+}
+
+func (r *GormRepository) DeleteSavedSearch(ctx context.Context, s *entity.SavedSearch) error {
+	// hard delete, so the unique index allows the name to be used again
+	err := r.db.Unscoped().Delete(s).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during saved search delete: %s", err.Error())
+	}
+	return err
+}
+
+// --- transfers ---
+
+func (r *GormRepository) GetPendingTransferFor(ctx context.Context, attendeeId uint) (*entity.Transfer, error) {
+	var t entity.Transfer
+	err := r.db.Where(&entity.Transfer{AttendeeId: attendeeId, State: entity.TransferStatePending}).Order("id DESC").First(&t).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Info().WithErr(err).Printf("database error during transfer select - might be ok: %s", err.Error())
+	}
+	return &t, err
+}
+
+func (r *GormRepository) GetTransferByCode(ctx context.Context, code string) (*entity.Transfer, error) {
+	var t entity.Transfer
+	err := r.db.Where(&entity.Transfer{Code: code}).First(&t).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Info().WithErr(err).Printf("database error during transfer select - might be ok: %s", err.Error())
+	}
+	return &t, err
+}
+
+func (r *GormRepository) AddTransfer(ctx context.Context, t *entity.Transfer) (uint, error) {
+	err := r.db.Create(t).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during transfer insert: %s", err.Error())
+	}
+	return t.ID, err
+}
+
+func (r *GormRepository) UpdateTransfer(ctx context.Context, t *entity.Transfer) error {
+	err := r.db.Save(t).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during transfer update: %s", err.Error())
+	}
+	return err
+}
+
+// --- groups ---
+
+func (r *GormRepository) GetGroupById(ctx context.Context, id uint) (*entity.Group, error) {
+	var g entity.Group
+	err := r.db.First(&g, id).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Info().WithErr(err).Printf("database error during group select - might be ok: %s", err.Error())
+	}
+	return &g, err
+}
+
+func (r *GormRepository) AddGroup(ctx context.Context, g *entity.Group) (uint, error) {
+	err := r.db.Create(g).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during group insert: %s", err.Error())
+	}
+	return g.ID, err
+}
+
+func (r *GormRepository) DeleteGroup(ctx context.Context, g *entity.Group) error {
+	err := r.db.Delete(g).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during group delete: %s", err.Error())
+	}
+	return err
+}
+
+func (r *GormRepository) GetGroupMembers(ctx context.Context, groupId uint) ([]*entity.GroupMember, error) {
+	return r.findGroupMembers(ctx, &entity.GroupMember{GroupId: groupId})
+}
+
+func (r *GormRepository) GetGroupMembershipsByAttendeeId(ctx context.Context, attendeeId uint) ([]*entity.GroupMember, error) {
+	return r.findGroupMembers(ctx, &entity.GroupMember{AttendeeId: attendeeId})
+}
+
+func (r *GormRepository) findGroupMembers(ctx context.Context, queryBuffer *entity.GroupMember) ([]*entity.GroupMember, error) {
+	result := make([]*entity.GroupMember, 0)
+	memberBuffer := entity.GroupMember{}
+
+	rows, err := r.db.Model(&entity.GroupMember{}).Where(queryBuffer).Order("id").Rows()
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading group members: %s", err.Error())
+		return result, err
+	}
+	defer func() {
+		err2 := rows.Close()
+		if err2 != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err2).Printf("secondary error closing recordset during group member read: %s", err2.Error())
+		}
+	}()
+
+	for rows.Next() {
+		err = r.db.ScanRows(rows, &memberBuffer)
+		if err != nil {
+			aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading group member: %s", err.Error())
+			return result, err
+		}
+		copiedMember := memberBuffer
+		result = append(result, &copiedMember)
+	}
+
+	return result, nil
+}
+
+func (r *GormRepository) GetGroupMember(ctx context.Context, groupId uint, attendeeId uint) (*entity.GroupMember, error) {
+	var m entity.GroupMember
+	err := r.db.Where(&entity.GroupMember{GroupId: groupId, AttendeeId: attendeeId}).First(&m).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Info().WithErr(err).Printf("database error during group member select - might be ok: %s", err.Error())
+	}
+	return &m, err
+}
+
+func (r *GormRepository) AddGroupMember(ctx context.Context, m *entity.GroupMember) (uint, error) {
+	err := r.db.Create(m).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during group member insert: %s", err.Error())
+	}
+	return m.ID, err
+}
+
+func (r *GormRepository) UpdateGroupMember(ctx context.Context, m *entity.GroupMember) error {
+	err := r.db.Save(m).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during group member update: %s", err.Error())
+	}
+	return err
+}
+
+func (r *GormRepository) DeleteGroupMember(ctx context.Context, m *entity.GroupMember) error {
+	// hard delete, so the unique index allows the attendee to be invited again later
+	err := r.db.Unscoped().Delete(m).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during group member delete: %s", err.Error())
+	}
+	return err
+}
+
+// --- package prices ---
+
+func (r *GormRepository) GetPackagePricesByAttendeeId(ctx context.Context, attendeeId uint) ([]*entity.PackagePrice, error) {
+	result := make([]*entity.PackagePrice, 0)
+	priceBuffer := entity.PackagePrice{}
+	queryBuffer := entity.PackagePrice{AttendeeId: attendeeId}
+
+	rows, err := r.db.Model(&entity.PackagePrice{}).Where(&queryBuffer).Order("id").Rows()
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading package prices for attendee %d: %s", attendeeId, err.Error())
+		return result, err
+	}
+	defer func() {
+		err2 := rows.Close()
+		if err2 != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err2).Printf("secondary error closing recordset during package price read: %s", err2.Error())
+		}
+	}()
+
+	for rows.Next() {
+		err = r.db.ScanRows(rows, &priceBuffer)
+		if err != nil {
+			aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading package price: %s", err.Error())
+			return result, err
+		}
+		copiedPrice := priceBuffer
+		result = append(result, &copiedPrice)
+	}
+
+	return result, nil
+}
+
+func (r *GormRepository) AddPackagePrice(ctx context.Context, p *entity.PackagePrice) (uint, error) {
+	err := r.db.Create(p).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during package price insert: %s", err.Error())
+	}
+	return p.ID, err
+}
+
+// --- vouchers ---
+
+func (r *GormRepository) GetAllVouchers(ctx context.Context) ([]*entity.Voucher, error) {
+	result := make([]*entity.Voucher, 0)
+	voucherBuffer := entity.Voucher{}
+
+	rows, err := r.db.Model(&entity.Voucher{}).Order("code").Rows()
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading vouchers: %s", err.Error())
+		return result, err
+	}
+	defer func() {
+		err2 := rows.Close()
+		if err2 != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err2).Printf("secondary error closing recordset during voucher read: %s", err2.Error())
+		}
+	}()
+
+	for rows.Next() {
+		err = r.db.ScanRows(rows, &voucherBuffer)
+		if err != nil {
+			aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading voucher: %s", err.Error())
+			return result, err
+		}
+		copiedVoucher := voucherBuffer
+		result = append(result, &copiedVoucher)
+	}
+
+	return result, nil
+}
+
+func (r *GormRepository) GetVoucherById(ctx context.Context, id uint) (*entity.Voucher, error) {
+	var v entity.Voucher
+	err := r.db.First(&v, id).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Info().WithErr(err).Printf("database error during voucher select - might be ok: %s", err.Error())
+	}
+	return &v, err
+}
+
+func (r *GormRepository) GetVoucherByCode(ctx context.Context, code string) (*entity.Voucher, error) {
+	var v entity.Voucher
+	err := r.db.Where(&entity.Voucher{Code: code}).First(&v).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Info().WithErr(err).Printf("database error during voucher select - might be ok: %s", err.Error())
+	}
+	return &v, err
+}
+
+func (r *GormRepository) GetVoucherByCodeForUpdate(ctx context.Context, code string) (*entity.Voucher, error) {
+	var v entity.Voucher
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where(&entity.Voucher{Code: code}).First(&v).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Info().WithErr(err).Printf("database error during voucher select for update - might be ok: %s", err.Error())
+	}
+	return &v, err
+}
+
+func (r *GormRepository) AddVoucher(ctx context.Context, v *entity.Voucher) (uint, error) {
+	err := r.db.Create(v).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during voucher insert: %s", err.Error())
+	}
+	return v.ID, err
+}
+
+func (r *GormRepository) UpdateVoucher(ctx context.Context, v *entity.Voucher) error {
+	err := r.db.Save(v).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during voucher update: %s", err.Error())
+	}
+	return err
+}
+
+func (r *GormRepository) DeleteVoucher(ctx context.Context, v *entity.Voucher) error {
+	// hard delete, so the unique index allows the code to be used again
+	err := r.db.Unscoped().Delete(v).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during voucher delete: %s", err.Error())
+	}
+	return err
+}
+
+func (r *GormRepository) GetVoucherRedemptions(ctx context.Context, voucherId uint) ([]*entity.VoucherRedemption, error) {
+	return r.findVoucherRedemptions(ctx, &entity.VoucherRedemption{VoucherId: voucherId})
+}
+
+func (r *GormRepository) GetVoucherRedemptionsByAttendeeId(ctx context.Context, attendeeId uint) ([]*entity.VoucherRedemption, error) {
+	return r.findVoucherRedemptions(ctx, &entity.VoucherRedemption{AttendeeId: attendeeId})
+}
+
+func (r *GormRepository) findVoucherRedemptions(ctx context.Context, queryBuffer *entity.VoucherRedemption) ([]*entity.VoucherRedemption, error) {
+	result := make([]*entity.VoucherRedemption, 0)
+	redemptionBuffer := entity.VoucherRedemption{}
+
+	rows, err := r.db.Model(&entity.VoucherRedemption{}).Where(queryBuffer).Order("id").Rows()
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading voucher redemptions: %s", err.Error())
+		return result, err
+	}
+	defer func() {
+		err2 := rows.Close()
+		if err2 != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err2).Printf("secondary error closing recordset during voucher redemption read: %s", err2.Error())
+		}
+	}()
+
+	for rows.Next() {
+		err = r.db.ScanRows(rows, &redemptionBuffer)
+		if err != nil {
+			aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading voucher redemption: %s", err.Error())
+			return result, err
+		}
+		copiedRedemption := redemptionBuffer
+		result = append(result, &copiedRedemption)
+	}
+
+	return result, nil
+}
+
+func (r *GormRepository) GetVoucherRedemption(ctx context.Context, voucherId uint, attendeeId uint) (*entity.VoucherRedemption, error) {
+	var vr entity.VoucherRedemption
+	err := r.db.Where(&entity.VoucherRedemption{VoucherId: voucherId, AttendeeId: attendeeId}).First(&vr).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Info().WithErr(err).Printf("database error during voucher redemption select - might be ok: %s", err.Error())
+	}
+	return &vr, err
+}
+
+func (r *GormRepository) AddVoucherRedemption(ctx context.Context, vr *entity.VoucherRedemption) (uint, error) {
+	err := r.db.Create(vr).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during voucher redemption insert: %s", err.Error())
+	}
+	return vr.ID, err
+}
+
+func (r *GormRepository) DeleteVoucherRedemption(ctx context.Context, vr *entity.VoucherRedemption) error {
+	// hard delete, so the unique index allows the voucher to be redeemed again later
+	err := r.db.Unscoped().Delete(vr).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during voucher redemption delete: %s", err.Error())
+	}
+	return err
+}
+
+// --- invoices ---
+
+func (r *GormRepository) GetInvoicesByAttendeeId(ctx context.Context, attendeeId uint) ([]*entity.Invoice, error) {
+	result := make([]*entity.Invoice, 0)
+	invoiceBuffer := entity.Invoice{}
+	queryBuffer := entity.Invoice{AttendeeId: attendeeId}
+
+	rows, err := r.db.Model(&entity.Invoice{}).Where(&queryBuffer).Order("number").Rows()
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading invoices for attendee %d: %s", attendeeId, err.Error())
+		return result, err
+	}
+	defer func() {
+		err2 := rows.Close()
+		if err2 != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err2).Printf("secondary error closing recordset during invoice read: %s", err2.Error())
+		}
+	}()
+
+	for rows.Next() {
+		err = r.db.ScanRows(rows, &invoiceBuffer)
+		if err != nil {
+			aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading invoice: %s", err.Error())
+			return result, err
+		}
+		copiedInvoice := invoiceBuffer
+		result = append(result, &copiedInvoice)
+	}
+
+	return result, nil
+}
+
+func (r *GormRepository) AddInvoice(ctx context.Context, i *entity.Invoice) (uint, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// the update locks the counter row until the transaction ends, so no number is issued twice
+		result := tx.Exec("UPDATE att_invoice_numbers SET last_number = last_number + 1 WHERE id = 1")
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return errors.New("invoice number counter missing - should have been set up during database migration")
+		}
+
+		counter := entity.InvoiceNumber{}
+		if err := tx.Where(&entity.InvoiceNumber{ID: 1}).First(&counter).Error; err != nil {
+			return err
+		}
+
+		i.Number = counter.LastNumber
+		return tx.Create(i).Error
+	})
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during invoice insert: %s", err.Error())
+	}
+	return i.ID, err
+}
+
+// --- additional info ---
+
+func (r *GormRepository) GetAllAdditionalInfoForArea(ctx context.Context, area string) ([]*entity.AdditionalInfo, error) {
+	result := make([]*entity.AdditionalInfo, 0)
+	addInfoBuffer := entity.AdditionalInfo{}
+	queryBuffer := entity.AdditionalInfo{Area: area}
+
+	rows, err := r.db.Model(&entity.AdditionalInfo{}).Where(&queryBuffer).Order("attendee_id").Rows()
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading additional infos for area %s: %s", area, err.Error())
+		return result, err
+	}
+	defer func() {
+		err2 := rows.Close()
+		if err2 != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err2).Printf("secondary error closing recordset during additional info read: %s", err2.Error())
+		}
+	}()
+
+	for rows.Next() {
+		err = r.db.ScanRows(rows, &addInfoBuffer)
+		if err != nil {
+			aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading additional info during find for area %s: %s", area, err.Error())
+			return result, err
+		}
+		copiedAddInfo := addInfoBuffer
+		result = append(result, &copiedAddInfo)
+	}
+
+	return result, nil
+}
+
+func (r *GormRepository) GetAdditionalInfoFor(ctx context.Context, attendeeId uint, area string) (*entity.AdditionalInfo, error) {
+	var ai entity.AdditionalInfo
+	// not using a struct condition, because gorm would drop attendee id 0, which is used for global values
+	err := r.db.Model(&entity.AdditionalInfo{}).Where(map[string]interface{}{"attendee_id": attendeeId, "area": area}).Last(&ai).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// return a new entry suitable for saving
+			ai = entity.AdditionalInfo{
+				AttendeeId: attendeeId,
+				Area:       area,
+			}
+			err = nil
+		}
+	}
+	return &ai, err
+}
+
+func (r *GormRepository) WriteAdditionalInfo(ctx context.Context, ad *entity.AdditionalInfo) error {
+	err := r.db.Save(ad).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during additional info insert or update: %s", err.Error())
+	}
+	return err
+}
+
+// --- count ---
+
+func (r *GormRepository) CreateCount(initial *entity.Count) (*entity.Count, error) {
+	if initial == nil || initial.Area == "" || initial.Name == "" {
+		aulogging.Logger.NoCtx().Error().Print("error setting up counts - received nil or unset area or name")
+		return &entity.Count{}, errors.New("error setting up counts - received nil or unset area or name")
+	}
+	err := r.db.Model(&entity.Count{}).Where(&entity.Count{Area: initial.Area, Name: initial.Name}).FirstOrCreate(initial).Error
+	if err != nil {
+		aulogging.Logger.NoCtx().Error().WithErr(err).Printf("error setting up counts for area %s name %s: %s", initial.Area, initial.Name, err.Error())
+		return initial, err
+	}
+	return initial, nil
+}
+
+func (r *GormRepository) AddCount(ctx context.Context, delta *entity.Count) (*entity.Count, error) {
+	if delta == nil || delta.Area == "" || delta.Name == "" {
+		aulogging.Logger.Ctx(ctx).Error().Print("error changing counts - received nil or unset area or name")
+		return &entity.Count{}, errors.New("error changing counts - received nil or unset area or name")
+	}
+	query := `UPDATE att_counts 
+              SET pending = pending + @deltaPending, attending = attending + @deltaAttending 
+              WHERE area = @area AND name = @name`
+	params := map[string]interface{}{
+		"area":           delta.Area,
+		"name":           delta.Name,
+		"deltaPending":   delta.Pending,
+		"deltaAttending": delta.Attending,
+	}
+	result := r.db.Exec(query, params)
+	current, err := r.GetCount(ctx, delta.Area, delta.Name)
+	if result.Error != nil {
+		aulogging.Logger.Ctx(ctx).Error().Printf("error adding counts area %s name %s: %s", delta.Area, delta.Name, result.Error.Error())
+		return current, result.Error
+	}
+	if result.RowsAffected != 1 {
+		aulogging.Logger.Ctx(ctx).Error().Printf("error adding counts area %s name %s: affected %d rows instead of 1", delta.Area, delta.Name, result.RowsAffected)
+		return current, fmt.Errorf("error adding counts area %s name %s: failed to affect 1 row", delta.Area, delta.Name)
+	}
+	return current, err
+}
+
+func (r *GormRepository) ResetCount(ctx context.Context, overwrite *entity.Count) error {
+	if overwrite == nil || overwrite.Area == "" || overwrite.Name == "" {
+		aulogging.Logger.Ctx(ctx).Error().Print("error resetting counts - received nil or unset area or name")
+		return errors.New("error resetting counts - received nil or unset area or name")
+	}
+	query := `UPDATE att_counts 
+              SET pending = @pending, attending = @attending 
+              WHERE area = @area AND name = @name`
+	params := map[string]interface{}{
+		"area":      overwrite.Area,
+		"name":      overwrite.Name,
+		"pending":   overwrite.Pending,
+		"attending": overwrite.Attending,
+	}
+	result := r.db.Exec(query, params)
+	if result.Error != nil {
+		aulogging.Logger.Ctx(ctx).Error().Printf("error resetting counts area %s name %s: %s", overwrite.Area, overwrite.Name, result.Error.Error())
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		aulogging.Logger.Ctx(ctx).Error().Printf("error adding counts area %s name %s: affected %d rows instead of 1", overwrite.Area, overwrite.Name, result.RowsAffected)
+		return fmt.Errorf("error resetting counts area %s name %s: failed to affect 1 row", overwrite.Area, overwrite.Name)
+	}
+	return nil
+}
+
+func (r *GormRepository) GetCount(ctx context.Context, area string, name string) (*entity.Count, error) {
+	c, err := r.getCountAllowMissing(ctx, area, name)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading counts for area %s name %s - should have been set up during database migration: %s", area, name, err.Error())
+			return c, err
+		}
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading counts for area %s name %s - other: %s", area, name, err.Error())
+		return c, err
+	}
+	return c, nil
+}
+
+func (r *GormRepository) GetCountForUpdate(ctx context.Context, area string, name string) (*entity.Count, error) {
+	var c entity.Count
+	if area == "" || name == "" {
+		aulogging.Logger.Ctx(ctx).Error().Print("error reading counts for update - received unset area or name")
+		return &c, errors.New("error reading counts for update - received unset area or name")
+	}
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&entity.Count{}).Where(&entity.Count{Area: area, Name: name}).First(&c).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading counts for update for area %s name %s: %s", area, name, err.Error())
+	}
+	return &c, err
+}
+
+func (r *GormRepository) getCountAllowMissing(ctx context.Context, area string, name string) (*entity.Count, error) {
+	var c entity.Count
+	if area == "" || name == "" {
+		aulogging.Logger.Ctx(ctx).Error().Print("error reading counts - received unset area or name")
+		return &c, errors.New("error reading counts - received unset area or name")
+	}
+	err := r.db.Model(&entity.Count{}).Where(&entity.Count{Area: area, Name: name}).First(&c).Error
+	return &c, err
+}
+
+// --- waiting list ---
+
+func (r *GormRepository) GetWaitlistForPackage(ctx context.Context, pkg string) ([]*entity.WaitlistEntry, error) {
+	return r.findWaitlistEntries(ctx, &entity.WaitlistEntry{Package: pkg})
+}
+
+func (r *GormRepository) GetWaitlistEntriesByAttendeeId(ctx context.Context, attendeeId uint) ([]*entity.WaitlistEntry, error) {
+	return r.findWaitlistEntries(ctx, &entity.WaitlistEntry{AttendeeId: attendeeId})
+}
+
+func (r *GormRepository) findWaitlistEntries(ctx context.Context, queryBuffer *entity.WaitlistEntry) ([]*entity.WaitlistEntry, error) {
+	result := make([]*entity.WaitlistEntry, 0)
+	entryBuffer := entity.WaitlistEntry{}
+
+	rows, err := r.db.Model(&entity.WaitlistEntry{}).Where(queryBuffer).Order("id").Rows()
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading waiting list entries: %s", err.Error())
+		return result, err
+	}
+	defer func() {
+		err2 := rows.Close()
+		if err2 != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err2).Printf("secondary error closing recordset during waiting list read: %s", err2.Error())
+		}
+	}()
+
+	for rows.Next() {
+		err = r.db.ScanRows(rows, &entryBuffer)
+		if err != nil {
+			aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading waiting list entry: %s", err.Error())
+			return result, err
+		}
+		copiedEntry := entryBuffer
+		result = append(result, &copiedEntry)
+	}
+
+	return result, nil
+}
+
+func (r *GormRepository) AddWaitlistEntry(ctx context.Context, e *entity.WaitlistEntry) (uint, error) {
+	err := r.db.Create(e).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during waiting list entry insert: %s", err.Error())
+	}
+	return e.ID, err
+}
+
+func (r *GormRepository) UpdateWaitlistEntry(ctx context.Context, e *entity.WaitlistEntry) error {
+	err := r.db.Save(e).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during waiting list entry update: %s", err.Error())
+	}
+	return err
+}
+
+func (r *GormRepository) DeleteWaitlistEntry(ctx context.Context, e *entity.WaitlistEntry) error {
+	// hard delete, so the unique index allows the attendee to be queued again later
+	err := r.db.Unscoped().Delete(e).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during waiting list entry delete: %s", err.Error())
+	}
+	return err
+}
+
+// --- mail outbox ---
+
+func (r *GormRepository) AddOutboxMail(ctx context.Context, m *entity.OutboxMail) (uint, error) {
+	err := r.db.Create(m).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during outbox mail insert: %s", err.Error())
+	}
+	return m.ID, err
+}
+
+func (r *GormRepository) UpdateOutboxMail(ctx context.Context, m *entity.OutboxMail) error {
+	err := r.db.Save(m).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during outbox mail update: %s", err.Error())
+	}
+	return err
+}
+
+func (r *GormRepository) GetOutboxMailById(ctx context.Context, id uint) (*entity.OutboxMail, error) {
+	var m entity.OutboxMail
+	err := r.db.First(&m, id).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Info().WithErr(err).Printf("database error during outbox mail select - might be ok: %s", err.Error())
+	}
+	return &m, err
+}
+
+func (r *GormRepository) GetOutboxMailsByState(ctx context.Context, state string) ([]*entity.OutboxMail, error) {
+	return r.findOutboxMails(ctx, r.db.Model(&entity.OutboxMail{}).Where(&entity.OutboxMail{State: state}).Order("id"))
+}
+
+func (r *GormRepository) GetDueOutboxMails(ctx context.Context, now time.Time, limit int) ([]*entity.OutboxMail, error) {
+	return r.findOutboxMails(ctx, r.db.Model(&entity.OutboxMail{}).
+		Where("state IN ? AND next_attempt <= ?", []string{entity.OutboxStatePending, entity.OutboxStateSending}, now).
+		Order("id").
+		Limit(limit))
+}
+
+func (r *GormRepository) ClaimOutboxMail(ctx context.Context, m *entity.OutboxMail, now time.Time, leaseUntil time.Time) (bool, error) {
+	result := r.db.Model(&entity.OutboxMail{}).
+		Where("id = ? AND attempts = ? AND (state IN ? OR (state = ? AND next_attempt <= ?))",
+			m.ID, m.Attempts, []string{entity.OutboxStatePending, entity.OutboxStateFailed}, entity.OutboxStateSending, now).
+		Updates(map[string]interface{}{"state": entity.OutboxStateSending, "next_attempt": leaseUntil})
+	if result.Error != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(result.Error).Printf("database error during outbox mail claim: %s", result.Error.Error())
+		return false, result.Error
+	}
+	if result.RowsAffected != 1 {
+		return false, nil
+	}
+	m.State = entity.OutboxStateSending
+	m.NextAttempt = leaseUntil
+	return true, nil
+}
+
+func (r *GormRepository) findOutboxMails(ctx context.Context, query *gorm.DB) ([]*entity.OutboxMail, error) {
+	result := make([]*entity.OutboxMail, 0)
+	mailBuffer := entity.OutboxMail{}
+
+	rows, err := query.Rows()
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading outbox mails: %s", err.Error())
+		return result, err
+	}
+	defer func() {
+		err2 := rows.Close()
+		if err2 != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err2).Printf("secondary error closing recordset during outbox read: %s", err2.Error())
+		}
+	}()
+
+	for rows.Next() {
+		err = r.db.ScanRows(rows, &mailBuffer)
+		if err != nil {
+			aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading outbox mail: %s", err.Error())
+			return result, err
+		}
+		copiedMail := mailBuffer
+		result = append(result, &copiedMail)
+	}
+
+	return result, nil
+}
+
+func (r *GormRepository) DeleteOutboxMail(ctx context.Context, m *entity.OutboxMail) error {
+	err := r.db.Delete(m).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during outbox mail delete: %s", err.Error())
+	}
+	return err
+}
+
+// --- webhook deliveries ---
+
+func (r *GormRepository) AddWebhookDelivery(ctx context.Context, d *entity.WebhookDelivery) (uint, error) {
+	err := r.db.Create(d).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during webhook delivery insert: %s", err.Error())
+	}
+	return d.ID, err
+}
+
+func (r *GormRepository) UpdateWebhookDelivery(ctx context.Context, d *entity.WebhookDelivery) error {
+	err := r.db.Save(d).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during webhook delivery update: %s", err.Error())
+	}
+	return err
+}
+
+func (r *GormRepository) GetPendingWebhookDeliveries(ctx context.Context, subscriber string, limit int) ([]*entity.WebhookDelivery, error) {
+	query := r.db.Model(&entity.WebhookDelivery{}).
+		Where(&entity.WebhookDelivery{Subscriber: subscriber, State: entity.WebhookStatePending}).
+		Order("id")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	return r.findWebhookDeliveries(ctx, query)
+}
+
+// webhookQueueUnlocked is the lock time of a queue that nobody holds, far enough in the past that
+// no rounding by the database can make it look current
+var webhookQueueUnlocked = time.Unix(0, 0)
+
+func (r *GormRepository) LockWebhookQueue(ctx context.Context, subscriber string, holder string, now time.Time, lockedUntil time.Time) (bool, error) {
+	err := r.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&entity.WebhookQueue{Subscriber: subscriber, LockedUntil: webhookQueueUnlocked}).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during webhook queue insert: %s", err.Error())
+		return false, err
+	}
+
+	result := r.db.Model(&entity.WebhookQueue{}).
+		Where("subscriber = ? AND (locked_until <= ? OR lock_holder = ?)", subscriber, now, holder).
+		Updates(map[string]interface{}{"locked_until": lockedUntil, "lock_holder": holder})
+	if result.Error != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(result.Error).Printf("database error during webhook queue lock: %s", result.Error.Error())
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *GormRepository) UnlockWebhookQueue(ctx context.Context, subscriber string, holder string) error {
+	err := r.db.Model(&entity.WebhookQueue{}).
+		Where("subscriber = ? AND lock_holder = ?", subscriber, holder).
+		Updates(map[string]interface{}{"locked_until": webhookQueueUnlocked, "lock_holder": ""}).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during webhook queue unlock: %s", err.Error())
+	}
+	return err
+}
+
+func (r *GormRepository) GetWebhookDeliveryLog(ctx context.Context, subscriber string, state string, attendeeId uint, limit int) ([]*entity.WebhookDelivery, error) {
+	// zero values in the condition struct are ignored by gorm, which is exactly what we want here
+	query := r.db.Model(&entity.WebhookDelivery{}).
+		Where(&entity.WebhookDelivery{Subscriber: subscriber, State: state, AttendeeId: attendeeId}).
+		Order("id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	return r.findWebhookDeliveries(ctx, query)
+}
+
+func (r *GormRepository) findWebhookDeliveries(ctx context.Context, query *gorm.DB) ([]*entity.WebhookDelivery, error) {
+	result := make([]*entity.WebhookDelivery, 0)
+	deliveryBuffer := entity.WebhookDelivery{}
+
+	rows, err := query.Rows()
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading webhook deliveries: %s", err.Error())
+		return result, err
+	}
+	defer func() {
+		err2 := rows.Close()
+		if err2 != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err2).Printf("secondary error closing recordset during webhook delivery read: %s", err2.Error())
+		}
+	}()
+
+	for rows.Next() {
+		err = r.db.ScanRows(rows, &deliveryBuffer)
+		if err != nil {
+			aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error reading webhook delivery: %s", err.Error())
+			return result, err
+		}
+		copiedDelivery := deliveryBuffer
+		result = append(result, &copiedDelivery)
+	}
+
+	return result, nil
+}
+
+// --- history ---
+
+func (r *GormRepository) RecordHistory(ctx context.Context, h *entity.History) error {
+	err := r.db.Create(h).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("database error during history entry insert: %s", err.Error())
+	}
+	return err
+}
+
+func (r *GormRepository) GetHistoryByEntity(ctx context.Context, entityName string, entityId uint) ([]*entity.History, error) {
+	result := make([]*entity.History, 0)
+	err := r.db.Where(map[string]interface{}{"entity": entityName, "entity_id": entityId}).Order("id").Find(&result).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("database error during history select: %s", err.Error())
+	}
+	return result, err
+}
@@ -1,4 +1,4 @@
-package mysqldb
+package gormdb
 
 import (
 	"context"
@@ -7,24 +7,19 @@ import (
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database/dbrepo"
 	"sort"
 	"strings"
 )
 
-func (r *MysqlRepository) constructAttendeeSearchQuery(ctx context.Context, conds *attendee.AttendeeSearchCriteria, params map[string]interface{}) string {
-	newestStatusSubQuery := strings.Builder{}
-	newestStatusSubQuery.WriteString(" SELECT sc.attendee_id AS attendee_id, ")
-	newestStatusSubQuery.WriteString("        ( SELECT sc2.status FROM att_status_changes AS sc2 WHERE sc2.id = max(sc.id) ) AS status ")
-	newestStatusSubQuery.WriteString(" FROM att_status_changes AS sc ")
-	newestStatusSubQuery.WriteString(" GROUP BY sc.attendee_id ")
-
+func (r *GormRepository) constructAttendeeSearchQuery(ctx context.Context, conds *attendee.AttendeeSearchCriteria, cursor *dbrepo.SearchCursor, params map[string]interface{}) string {
 	query := strings.Builder{}
 	query.WriteString("SELECT ")
 	fields := constructFieldList(conds.FillFields)
 	query.WriteString(strings.Join(fields, ", ") + " \n")
 	query.WriteString("FROM att_attendees AS a \n")
 	query.WriteString("  LEFT JOIN att_admin_infos AS ad ON ad.id = a.id \n")
-	query.WriteString("  LEFT JOIN ( " + newestStatusSubQuery.String() + " ) AS st ON st.attendee_id = a.id \n")
+	query.WriteString("  LEFT JOIN ( " + r.dialect.NewestStatusSubQuery + " ) AS st ON st.attendee_id = a.id \n")
 	query.WriteString("WHERE (\n  (0 = @param_force_named_query_detection)\n")
 	params["param_force_named_query_detection"] = 1 // if we do not have at least one @-param, GORM doesn't do named query mode, and then it fails with a type error
 	if conds != nil {
@@ -42,7 +37,14 @@ func (r *MysqlRepository) constructAttendeeSearchQuery(ctx context.Context, cond
 			query.WriteString("AND a.id <= @param_0_2 ")
 			params["param_0_2"] = conds.MaxId
 		}
-		query.WriteString(orderBy(conds.SortBy, conds.SortOrder))
+		if cursor != nil {
+			query.WriteString(r.dialect.afterCursor(conds.SortBy, conds.SortOrder))
+			params["param_0_3"] = cursor.Id
+		}
+		query.WriteString(r.dialect.orderBy(conds.SortBy, conds.SortOrder))
+		if conds.NumResults > 0 {
+			query.WriteString(fmt.Sprintf(" LIMIT %d", conds.NumResults))
+		}
 	}
 	result := query.String()
 	aulogging.Logger.Ctx(ctx).Debug().Printf("SQL query: %s", result)
@@ -65,17 +67,18 @@ func constructFieldList(spec []string) []string {
 		selected["a.pronouns as pronouns"] = true
 		selected["a.tshirt_size as tshirt_size"] = true
 		selected["a.flags as flags"] = true
-		selected["IFNULL(ad.flags, '') as admin_flags"] = true
+		selected["COALESCE(ad.flags, '') as admin_flags"] = true
 		selected["a.options as options"] = true
 		selected["a.packages as packages"] = true
 		selected["a.user_comments as user_comments"] = true
-		selected["IFNULL(st.status, 'new') as status"] = true
+		selected["COALESCE(st.status, 'new') as status"] = true
 		selected["a.cache_total_dues as cache_total_dues"] = true
 		selected["a.cache_payment_balance as cache_payment_balance"] = true
 		selected["a.cache_open_balance as cache_open_balance"] = true
 		selected["a.cache_due_date as cache_due_date"] = true
+		selected["a.currency as currency"] = true
 		selected["a.created_at as created_at"] = true
-		selected["IFNULL(ad.admin_comments, '') as admin_comments"] = true
+		selected["COALESCE(ad.admin_comments, '') as admin_comments"] = true
 	} else {
 		selected["a.id as id"] = true // always show badge number
 		for _, s := range spec {
@@ -89,22 +92,30 @@ func constructFieldList(spec []string) []string {
 				selected[defKey] = true
 			case "flags":
 				selected["a.flags as flags"] = true
-				selected["IFNULL(ad.flags, '') as admin_flags"] = true // from search viewpoint, these are just more flags
+				selected["COALESCE(ad.flags, '') as admin_flags"] = true // from search viewpoint, these are just more flags
 			case "options", "packages", "user_comments":
 				selected[defKey] = true
 			case "status":
-				selected["IFNULL(st.status, 'new') as status"] = true
+				selected["COALESCE(st.status, 'new') as status"] = true
 			case "total_dues", "payment_balance", "current_dues":
 				selected["a.cache_total_dues as cache_total_dues"] = true
 				selected["a.cache_payment_balance as cache_payment_balance"] = true
-				selected["IFNULL(ad.flags, '') as admin_flags"] = true // needed for dues calc (guest!)
-				selected["IFNULL(st.status, 'new') as status"] = true  // needed for dues calc
+				selected["COALESCE(ad.flags, '') as admin_flags"] = true // needed for dues calc (guest!)
+				selected["COALESCE(st.status, 'new') as status"] = true  // needed for dues calc
+			case "currency":
+				selected[defKey] = true
 			case "due_date":
 				selected["a.cache_due_date as cache_due_date"] = true
 			case "registered":
 				selected["a.created_at as created_at"] = true
 			case "admin_comments":
-				selected["IFNULL(ad.admin_comments, '') as admin_comments"] = true
+				selected["COALESCE(ad.admin_comments, '') as admin_comments"] = true
+			case "identity_subject":
+				selected["a.identity as identity"] = true
+			case "avatar":
+				selected["a.avatar as avatar"] = true
+			case "group_id":
+				selected["COALESCE(( SELECT gm.group_id FROM att_group_members AS gm WHERE gm.attendee_id = a.id AND gm.state = 'accepted' ), 0) as group_id"] = true
 			// custom field names
 			case "name":
 				selected["a.first_name as first_name"] = true
@@ -130,8 +141,9 @@ func constructFieldList(spec []string) []string {
 				selected["a.cache_payment_balance as cache_payment_balance"] = true
 				selected["a.cache_open_balance as cache_open_balance"] = true
 				selected["a.cache_due_date as cache_due_date"] = true
-				selected["IFNULL(ad.flags, '') as admin_flags"] = true // needed for dues calc (guest!)
-				selected["IFNULL(st.status, 'new') as status"] = true  // needed for dues calc
+				selected["a.currency as currency"] = true
+				selected["COALESCE(ad.flags, '') as admin_flags"] = true // needed for dues calc (guest!)
+				selected["COALESCE(st.status, 'new') as status"] = true  // needed for dues calc
 			case "all":
 				selected["a.nickname as nickname"] = true
 				selected["a.first_name as first_name"] = true
@@ -152,17 +164,21 @@ func constructFieldList(spec []string) []string {
 				selected["a.pronouns as pronouns"] = true
 				selected["a.tshirt_size as tshirt_size"] = true
 				selected["a.flags as flags"] = true
-				selected["IFNULL(ad.flags, '') as admin_flags"] = true
+				selected["COALESCE(ad.flags, '') as admin_flags"] = true
 				selected["a.options as options"] = true
 				selected["a.packages as packages"] = true
 				selected["a.user_comments as user_comments"] = true
-				selected["IFNULL(st.status, 'new') as status"] = true
+				selected["COALESCE(st.status, 'new') as status"] = true
 				selected["a.cache_total_dues as cache_total_dues"] = true
 				selected["a.cache_payment_balance as cache_payment_balance"] = true
 				selected["a.cache_open_balance as cache_open_balance"] = true
 				selected["a.cache_due_date as cache_due_date"] = true
+				selected["a.currency as currency"] = true
 				selected["a.created_at as created_at"] = true
-				selected["IFNULL(ad.admin_comments, '') as admin_comments"] = true
+				selected["COALESCE(ad.admin_comments, '') as admin_comments"] = true
+				selected["a.identity as identity"] = true
+				selected["a.avatar as avatar"] = true
+				selected["COALESCE(( SELECT gm.group_id FROM att_group_members AS gm WHERE gm.attendee_id = a.id AND gm.state = 'accepted' ), 0) as group_id"] = true
 			default:
 				// ignore
 			}
@@ -180,36 +196,50 @@ func constructFieldList(spec []string) []string {
 	return result
 }
 
-func orderBy(field string, direction string) string {
+func (d Dialect) orderBy(field string, direction string) string {
 	if direction == "descending" {
 		direction = "DESC"
 	} else {
 		direction = ""
 	}
-	var fieldName string
+	fieldName := d.sortFieldName(field, "a")
+	if fieldName == "a.id" {
+		return fmt.Sprintf("ORDER BY a.id %s", direction)
+	}
+	// the id makes the order unique, which is needed to continue after a cursor
+	return fmt.Sprintf("ORDER BY %s %s, a.id %s", fieldName, direction, direction)
+}
+
+// afterCursor restricts the results to those sorted after the attendee with id @param_0_3.
+//
+// The sort value of that attendee is read in a subquery, so it does not need to be part of the cursor.
+func (d Dialect) afterCursor(field string, direction string) string {
+	comparison := ">"
+	if direction == "descending" {
+		comparison = "<"
+	}
+	fieldName := d.sortFieldName(field, "a")
+	if fieldName == "a.id" {
+		return fmt.Sprintf("AND a.id %s @param_0_3 ", comparison)
+	}
+	cursorValue := fmt.Sprintf("( SELECT %s FROM att_attendees AS cur WHERE cur.id = @param_0_3 )", d.sortFieldName(field, "cur"))
+	return fmt.Sprintf("AND ( %s %s %s OR ( %s = %s AND a.id %s @param_0_3 ) ) ",
+		fieldName, comparison, cursorValue, fieldName, cursorValue, comparison)
+}
+
+func (d Dialect) sortFieldName(field string, alias string) string {
 	switch field {
-	case "birthday":
-		fieldName = "a.birthday"
-	case "city":
-		fieldName = "a.city"
-	case "country":
-		fieldName = "a.country"
-	case "email":
-		fieldName = "a.email"
+	case "birthday", "city", "country", "email", "nickname", "zip":
+		return alias + "." + field
 	case "name":
-		fieldName = "CONCAT(a.first_name, ' ', a.last_name)"
-	case "nickname":
-		fieldName = "a.nickname"
-	case "zip":
-		fieldName = "a.zip"
+		return d.Concat(alias+".first_name", "' '", alias+".last_name")
 	default:
 		// status sort must be done in post
-		fieldName = "a.id"
+		return alias + ".id"
 	}
-	return fmt.Sprintf("ORDER BY %s %s", fieldName, direction)
 }
 
-func (r *MysqlRepository) addSingleCondition(cond *attendee.AttendeeSearchSingleCriterion, params map[string]interface{}, idx int) string {
+func (r *GormRepository) addSingleCondition(cond *attendee.AttendeeSearchSingleCriterion, params map[string]interface{}, idx int) string {
 	paramBaseName := fmt.Sprintf("param_%d", idx)
 	paramNo := 1
 	query := strings.Builder{}
@@ -221,10 +251,10 @@ func (r *MysqlRepository) addSingleCondition(cond *attendee.AttendeeSearchSingle
 		query.WriteString(fullstringMatch("a.nickname", cond.Nickname, params, paramBaseName, &paramNo))
 	}
 	if cond.Name != "" {
-		query.WriteString(fullstringMatch("CONCAT(a.first_name, ' ', a.last_name)", cond.Name, params, paramBaseName, &paramNo))
+		query.WriteString(fullstringMatch(r.dialect.Concat("a.first_name", "' '", "a.last_name"), cond.Name, params, paramBaseName, &paramNo))
 	}
 	if cond.Address != "" {
-		query.WriteString(substringMatch("CONCAT(a.street, ' ', a.zip, ' ', a.city, ' ', a.state)", cond.Address, params, paramBaseName, &paramNo))
+		query.WriteString(substringMatch(r.dialect.Concat("a.street", "' '", "a.zip", "' '", "a.city", "' '", "COALESCE(a.state, '')"), cond.Address, params, paramBaseName, &paramNo))
 	}
 	if cond.Country != "" {
 		query.WriteString(stringExact("a.country", cond.Country, params, paramBaseName, &paramNo))
@@ -237,20 +267,20 @@ func (r *MysqlRepository) addSingleCondition(cond *attendee.AttendeeSearchSingle
 	}
 	query.WriteString(choiceMatch("a.spoken_languages", cond.SpokenLanguages, params, paramBaseName, &paramNo))
 	query.WriteString(choiceMatch("a.registration_language", cond.RegistrationLanguage, params, paramBaseName, &paramNo))
-	query.WriteString(choiceMatch("CONCAT(a.flags,IFNULL(ad.flags, ''))", cond.Flags, params, paramBaseName, &paramNo))
+	query.WriteString(choiceMatch(r.dialect.Concat("a.flags", "COALESCE(ad.flags, '')"), cond.Flags, params, paramBaseName, &paramNo))
 	query.WriteString(choiceMatch("a.options", cond.Options, params, paramBaseName, &paramNo))
 	query.WriteString(packageMatch("a.packages", cond.Packages, params, paramBaseName, &paramNo))
 	if cond.UserComments != "" {
 		query.WriteString(substringMatch("a.user_comments", cond.UserComments, params, paramBaseName, &paramNo))
 	}
 	if len(cond.Status) == 0 {
-		query.WriteString("    AND ( IFNULL(st.status, 'new') <> 'deleted' )\n")
+		query.WriteString("    AND ( COALESCE(st.status, 'new') <> 'deleted' )\n")
 	} else {
-		query.WriteString(safeStatusSliceMatch("IFNULL(st.status, 'new')", cond.Status))
+		query.WriteString(safeStatusSliceMatch("COALESCE(st.status, 'new')", cond.Status))
 	}
-	query.WriteString(choiceMatch("IFNULL(ad.permissions, '')", cond.Permissions, params, paramBaseName, &paramNo))
+	query.WriteString(choiceMatch("COALESCE(ad.permissions, '')", cond.Permissions, params, paramBaseName, &paramNo))
 	if cond.AdminComments != "" {
-		query.WriteString(substringMatch("IFNULL(ad.admin_comments, '')", cond.AdminComments, params, paramBaseName, &paramNo))
+		query.WriteString(substringMatch("COALESCE(ad.admin_comments, '')", cond.AdminComments, params, paramBaseName, &paramNo))
 	}
 	if len(cond.AddInfo) > 0 {
 		query.WriteString(r.addInfoConditions(cond.AddInfo, params, paramBaseName, &paramNo))
@@ -264,11 +294,23 @@ func (r *MysqlRepository) addSingleCondition(cond *attendee.AttendeeSearchSingle
 	if len(cond.IdentitySubjects) > 0 {
 		query.WriteString(stringSliceMatch("a.identity", cond.IdentitySubjects, params, paramBaseName, &paramNo))
 	}
+	if len(cond.GroupIds) > 0 {
+		query.WriteString(groupMatch(cond.GroupIds))
+	}
+	if len(cond.VoucherCodes) > 0 {
+		query.WriteString(voucherMatch(cond.VoucherCodes, params, paramBaseName, &paramNo))
+	}
+	if cond.Fuzzy != "" {
+		// the fuzzy score is computed later, this only narrows down the candidates
+		fuzzyText := r.dialect.Concat("COALESCE(a.nickname, '')", "' '", "COALESCE(a.first_name, '')", "' '", "COALESCE(a.last_name, '')",
+			"' '", "COALESCE(a.user_comments, '')", "' '", "COALESCE(ad.admin_comments, '')")
+		query.WriteString(fuzzyPrefilterMatch(fuzzyText, cond.Fuzzy, params, paramBaseName, &paramNo))
+	}
 
 	return query.String()
 }
 
-func (r *MysqlRepository) addInfoConditions(cond map[string]int8, params map[string]interface{}, paramBaseName string, idx *int) string {
+func (r *GormRepository) addInfoConditions(cond map[string]int8, params map[string]interface{}, paramBaseName string, idx *int) string {
 	pName := fmt.Sprintf("%s_%d", paramBaseName, *idx)
 	*idx++
 
@@ -304,6 +346,28 @@ func uintSliceMatch(field string, values []uint) string {
 	return fmt.Sprintf("    AND ( %s IN (%s))\n", field, strings.Join(mappedValues, ","))
 }
 
+// groupMatch restricts the results to members of the given groups. Open invitations do not count.
+func groupMatch(groupIds []uint) string {
+	mappedValues := make([]string, len(groupIds))
+	for i, v := range groupIds {
+		mappedValues[i] = fmt.Sprintf("%d", v)
+	}
+	return fmt.Sprintf("    AND ( a.id IN ( SELECT gm.attendee_id FROM att_group_members AS gm WHERE gm.state = 'accepted' AND gm.group_id IN (%s) ) )\n", strings.Join(mappedValues, ","))
+}
+
+func voucherMatch(codes []string, params map[string]interface{}, paramBaseName string, idx *int) string {
+	cond := make([]string, 0)
+	for i, v := range codes {
+		if i < 8 {
+			pName := fmt.Sprintf("%s_%d_%d", paramBaseName, *idx, i+1)
+			params[pName] = strings.ToUpper(v)
+			cond = append(cond, "@"+pName)
+		}
+	}
+	*idx++
+	return fmt.Sprintf("    AND ( a.id IN ( SELECT vr.attendee_id FROM att_voucher_redemptions AS vr JOIN att_vouchers AS v ON v.id = vr.voucher_id WHERE v.code IN ( %s ) ) )\n", strings.Join(cond, " , "))
+}
+
 func safeStatusSliceMatch(field string, values []status.Status) string {
 	allowedValues := config.AllowedStatusValues()
 	mappedValues := make([]string, 0)
@@ -347,6 +411,22 @@ func fullstringMatch(field string, condition string, params map[string]interface
 	return fmt.Sprintf("    AND ( LOWER(%s) LIKE LOWER( @%s ) )\n", field, pName)
 }
 
+// fuzzyPrefilterMatch needs no LOWER because the letters come in all cases, and sqlite only lowercases ascii.
+func fuzzyPrefilterMatch(field string, fuzzy string, params map[string]interface{}, paramBaseName string, idx *int) string {
+	query := strings.Builder{}
+	for _, letters := range dbrepo.FuzzyPrefilter(fuzzy) {
+		alternatives := make([]string, 0, len(letters))
+		for _, letter := range letters {
+			pName := fmt.Sprintf("%s_%d", paramBaseName, *idx)
+			params[pName] = "%" + letter + "%"
+			*idx++
+			alternatives = append(alternatives, fmt.Sprintf("%s LIKE @%s", field, pName))
+		}
+		query.WriteString("    AND ( " + strings.Join(alternatives, " OR ") + " )\n")
+	}
+	return query.String()
+}
+
 func stringExact(field string, condition string, params map[string]interface{}, paramBaseName string, idx *int) string {
 	pName := fmt.Sprintf("%s_%d", paramBaseName, *idx)
 	params[pName] = condition
@@ -358,14 +438,14 @@ func stringLessThanOrEqual(field string, condition string, params map[string]int
 	pName := fmt.Sprintf("%s_%d", paramBaseName, *idx)
 	params[pName] = condition
 	*idx++
-	return fmt.Sprintf("    AND ( STRCMP(%s,@%s) <= 0 )\n", field, pName)
+	return fmt.Sprintf("    AND ( %s <= @%s )\n", field, pName)
 }
 
 func stringGreaterThanOrEqual(field string, condition string, params map[string]interface{}, paramBaseName string, idx *int) string {
 	pName := fmt.Sprintf("%s_%d", paramBaseName, *idx)
 	params[pName] = condition
 	*idx++
-	return fmt.Sprintf("    AND ( STRCMP(%s,@%s) >= 0 )\n", field, pName)
+	return fmt.Sprintf("    AND ( %s >= @%s )\n", field, pName)
 }
 
 func choiceMatch(field string, condition map[string]int8, params map[string]interface{}, paramBaseName string, idx *int) string {
@@ -419,15 +499,15 @@ func sortedKeySet(condition map[string]int8) []string {
 func notOverdueCondition(todayIso string, params map[string]interface{}, paramName string) string {
 	// a.cache_due_date = NULL => not considered overdue
 	params[paramName] = todayIso
-	return fmt.Sprintf("    AND ( STRCMP( IFNULL(a.cache_due_date,'9999-99-99'), @%s ) >= 0 )\n", paramName) +
-		safeStatusSliceMatch("IFNULL(st.status, 'new')", []status.Status{status.Approved, status.PartiallyPaid})
+	return fmt.Sprintf("    AND ( COALESCE(a.cache_due_date,'9999-99-99') >= @%s )\n", paramName) +
+		safeStatusSliceMatch("COALESCE(st.status, 'new')", []status.Status{status.Approved, status.PartiallyPaid})
 }
 
 func overdueCondition(todayIso string, params map[string]interface{}, paramName string) string {
 	// a.cache_due_date = NULL => not considered overdue
 	params[paramName] = todayIso
-	return fmt.Sprintf("    AND ( STRCMP( IFNULL(a.cache_due_date,'9999-99-99'), @%s ) < 0 )\n", paramName) +
-		safeStatusSliceMatch("IFNULL(st.status, 'new')", []status.Status{status.Approved, status.PartiallyPaid})
+	return fmt.Sprintf("    AND ( COALESCE(a.cache_due_date,'9999-99-99') < @%s )\n", paramName) +
+		safeStatusSliceMatch("COALESCE(st.status, 'new')", []status.Status{status.Approved, status.PartiallyPaid})
 }
 
 func addInfoNotPresentCondition(unsafeKey string, params map[string]interface{}, paramName string) string {
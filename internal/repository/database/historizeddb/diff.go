@@ -0,0 +1,215 @@
+package historizeddb
+
+import (
+	"fmt"
+	"go/token"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// entity names used in history entries
+const (
+	EntityAttendee  = "Attendee"
+	EntityAdminInfo = "AdminInfo"
+)
+
+// Change is a single field of a history entry. Value is the field value before the change, as formatted by
+// messagediff.
+type Change struct {
+	Path  string
+	Value string
+}
+
+var diffLinePattern = regexp.MustCompile(`^modified: (\S+) = (.*)$`)
+
+// ParseDiff extracts the field changes from the diff of a history entry.
+//
+// Lines that do not describe a modified field, such as "<deleted>" for deleted bans, are skipped.
+func ParseDiff(diff string) []Change {
+	result := make([]Change, 0)
+	for _, line := range strings.Split(diff, "\n") {
+		if match := diffLinePattern.FindStringSubmatch(line); match != nil {
+			result = append(result, Change{
+				Path:  match[1],
+				Value: match[2],
+			})
+		}
+	}
+	return result
+}
+
+// FieldName turns the path of a change into a readable field name, e.g. ".Model.DeletedAt.Valid"
+// becomes "DeletedAt.Valid".
+func FieldName(path string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(path, "."), "Model.")
+}
+
+// Revert sets the fields in v back to their values before the changes.
+//
+// Times, such as DeletedAt.Time, are put back together from their recorded internals. Changes to other
+// fields that cannot be set are skipped, so those fields keep their current values.
+func Revert[T any](v *T, changes []Change) error {
+	// path of the time -> name of the internal field -> value before the change
+	times := make(map[string]map[string]string)
+	for _, change := range changes {
+		if timePath, internal, ok := lookupTimeInternal(v, change.Path); ok {
+			if times[timePath] == nil {
+				times[timePath] = make(map[string]string)
+			}
+			times[timePath][internal] = change.Value
+			continue
+		}
+		field, ok := lookupField(v, change.Path)
+		if !ok {
+			continue
+		}
+		if err := setFromDiffValue(field, change.Value); err != nil {
+			return fmt.Errorf("cannot revert %s: %w", change.Path, err)
+		}
+	}
+	for timePath, internals := range times {
+		field, _ := lookupValue(v, timePath)
+		if err := setTimeFromDiffValues(field, internals); err != nil {
+			return fmt.Errorf("cannot revert %s: %w", timePath, err)
+		}
+	}
+	return nil
+}
+
+// FieldValue formats the current value of the field at path in v.
+//
+// Returns false if the field cannot be reached, e.g. because it is unexported.
+func FieldValue[T any](v *T, path string) (string, bool) {
+	field, ok := lookupField(v, path)
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprint(field.Interface()), true
+}
+
+func lookupField[T any](v *T, path string) (reflect.Value, bool) {
+	current, ok := lookupValue(v, path)
+	if !ok {
+		return reflect.Value{}, false
+	}
+	switch current.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return current, current.CanSet()
+	default:
+		return reflect.Value{}, false
+	}
+}
+
+func lookupValue[T any](v *T, path string) (reflect.Value, bool) {
+	current := reflect.ValueOf(v).Elem()
+	for _, name := range strings.Split(strings.TrimPrefix(path, "."), ".") {
+		if current.Kind() != reflect.Struct || !token.IsExported(name) {
+			return reflect.Value{}, false
+		}
+		current = current.FieldByName(name)
+		if !current.IsValid() {
+			return reflect.Value{}, false
+		}
+	}
+	return current, true
+}
+
+// lookupTimeInternal checks if path points to one of the unexported fields of a settable time.Time,
+// e.g. ".Model.DeletedAt.Time.wall", and splits it into the path of the time and the field name.
+func lookupTimeInternal[T any](v *T, path string) (string, string, bool) {
+	dot := strings.LastIndex(path, ".")
+	if dot < 0 {
+		return "", "", false
+	}
+	timePath, internal := path[:dot], path[dot+1:]
+	field, ok := lookupValue(v, timePath)
+	if !ok || field.Type() != reflect.TypeOf(time.Time{}) || !field.CanSet() {
+		return "", "", false
+	}
+	return timePath, internal, true
+}
+
+// constants from the time package, needed to interpret the internals of a time.Time
+const (
+	timeHasMonotonic          = 1 << 63
+	timeNsecMask              = 1<<30 - 1
+	timeNsecShift             = 30
+	timeWallToInternal  int64 = (1884*365 + 1884/4 - 1884/100 + 1884/400) * 86400
+	timeUnixToInternal  int64 = (1969*365 + 1969/4 - 1969/100 + 1969/400) * 86400
+	timeNilLocationDiff       = "(*time.Location)(nil)"
+)
+
+// setTimeFromDiffValues sets a time.Time from the values of its internal fields before the change.
+// Internal fields that did not change are taken from the current value.
+//
+// The location is only recorded as a pointer, so a time in a location other than UTC is restored in the
+// local time zone. It is still the same instant.
+func setTimeFromDiffValues(field reflect.Value, internals map[string]string) error {
+	current := field.Interface().(time.Time)
+	wall := field.FieldByName("wall").Uint()
+	ext := field.FieldByName("ext").Int()
+	var err error
+	if value, ok := internals["wall"]; ok {
+		if wall, err = strconv.ParseUint(value, 0, 64); err != nil {
+			return err
+		}
+	}
+	if value, ok := internals["ext"]; ok {
+		if ext, err = strconv.ParseInt(value, 0, 64); err != nil {
+			return err
+		}
+	}
+
+	sec := ext
+	if wall&timeHasMonotonic != 0 {
+		sec = timeWallToInternal + int64(wall<<1>>(timeNsecShift+1))
+	}
+	restored := time.Unix(sec-timeUnixToInternal, int64(wall&timeNsecMask))
+
+	location := current.Location()
+	if value, ok := internals["loc"]; ok {
+		location = time.Local
+		if value == timeNilLocationDiff {
+			location = time.UTC
+		}
+	}
+	field.Set(reflect.ValueOf(restored.In(location)))
+	return nil
+}
+
+// setFromDiffValue parses a value formatted with %#v, as used by messagediff.
+func setFromDiffValue(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		s, err := strconv.Unquote(value)
+		if err != nil {
+			return err
+		}
+		field.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 0, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(i)
+	default:
+		// unsigned values are formatted as hex, e.g. 0x2a
+		u, err := strconv.ParseUint(value, 0, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(u)
+	}
+	return nil
+}
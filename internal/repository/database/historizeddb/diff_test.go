@@ -0,0 +1,117 @@
+package historizeddb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestParseDiff(t *testing.T) {
+	docs.Description("only modified fields are extracted from a history diff")
+	diff := "modified: .Nickname = \"BlackCheetah\"\nmodified: .Model.DeletedAt.Valid = false\n<deleted>\n"
+	expected := []Change{
+		{Path: ".Nickname", Value: "\"BlackCheetah\""},
+		{Path: ".Model.DeletedAt.Valid", Value: "false"},
+	}
+	require.Equal(t, expected, ParseDiff(diff))
+}
+
+func TestFieldName(t *testing.T) {
+	docs.Description("field names omit the leading dot and the embedded gorm model")
+	require.Equal(t, "Nickname", FieldName(".Nickname"))
+	require.Equal(t, "DeletedAt.Valid", FieldName(".Model.DeletedAt.Valid"))
+}
+
+func TestRevert_RoundTrip(t *testing.T) {
+	docs.Description("reverting the recorded diff restores all historized fields of the old version")
+	oldVersion := tstBuildValidAttendee()
+	newVersion := *oldVersion
+	newVersion.Nickname = "WhiteCheetah"
+	newVersion.State = ""
+	newVersion.Packages = ",room-none:1,"
+	newVersion.Flags = "line one\nline \"two\""
+	newVersion.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+
+	histEntry := diffReverse(context.TODO(), oldVersion, &newVersion, EntityAttendee, 1)
+
+	reverted := newVersion
+	require.Nil(t, Revert(&reverted, ParseDiff(histEntry.Diff)))
+
+	require.Equal(t, oldVersion.Nickname, reverted.Nickname)
+	require.Equal(t, oldVersion.State, reverted.State)
+	require.Equal(t, oldVersion.Packages, reverted.Packages)
+	require.Equal(t, oldVersion.Flags, reverted.Flags)
+	require.False(t, reverted.DeletedAt.Valid)
+}
+
+func TestRevert_DeletedAt(t *testing.T) {
+	docs.Description("reverting an undelete restores the deletion time, not just the deleted flag")
+	deletedAt := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	for _, oldTime := range []time.Time{deletedAt, deletedAt.Local(), time.Now()} {
+		oldVersion := tstBuildValidAttendee()
+		oldVersion.DeletedAt = gorm.DeletedAt{Time: oldTime, Valid: true}
+		newVersion := *oldVersion
+		newVersion.DeletedAt = gorm.DeletedAt{}
+
+		histEntry := diffReverse(context.TODO(), oldVersion, &newVersion, EntityAttendee, 1)
+
+		reverted := newVersion
+		require.Nil(t, Revert(&reverted, ParseDiff(histEntry.Diff)))
+		require.True(t, reverted.DeletedAt.Valid)
+		require.True(t, oldTime.Equal(reverted.DeletedAt.Time), "expected %v, got %v", oldTime, reverted.DeletedAt.Time)
+	}
+
+	docs.Description("and reverting a delete clears the deletion time")
+	oldVersion := tstBuildValidAttendee()
+	newVersion := *oldVersion
+	newVersion.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+
+	histEntry := diffReverse(context.TODO(), oldVersion, &newVersion, EntityAttendee, 1)
+
+	reverted := newVersion
+	require.Nil(t, Revert(&reverted, ParseDiff(histEntry.Diff)))
+	require.Equal(t, gorm.DeletedAt{}, reverted.DeletedAt)
+}
+
+func TestRevert_Numbers(t *testing.T) {
+	docs.Description("reverting works for numeric fields")
+	oldVersion := &entity.AdminInfo{ManualDues: -2000}
+	newVersion := &entity.AdminInfo{ManualDues: 5000}
+
+	histEntry := diffReverse(context.TODO(), oldVersion, newVersion, EntityAdminInfo, 1)
+
+	require.Nil(t, Revert(newVersion, ParseDiff(histEntry.Diff)))
+	require.Equal(t, int64(-2000), newVersion.ManualDues)
+}
+
+func TestRevert_InvalidValue(t *testing.T) {
+	docs.Description("a diff value that does not fit the field is an error")
+	a := tstBuildValidAttendee()
+	err := Revert(a, []Change{{Path: ".CacheTotalDues", Value: "\"not a number\""}})
+	require.NotNil(t, err)
+}
+
+func TestFieldValue(t *testing.T) {
+	docs.Description("field values are formatted for display, unreachable fields are reported")
+	a := tstBuildValidAttendee()
+	a.CacheTotalDues = 12500
+
+	value, ok := FieldValue(a, ".Nickname")
+	require.True(t, ok)
+	require.Equal(t, "BlackCheetah", value)
+
+	value, ok = FieldValue(a, ".CacheTotalDues")
+	require.True(t, ok)
+	require.Equal(t, "12500", value)
+
+	_, ok = FieldValue(a, ".Model.DeletedAt.Time.wall")
+	require.False(t, ok)
+
+	_, ok = FieldValue(a, ".DoesNotExist")
+	require.False(t, ok)
+}
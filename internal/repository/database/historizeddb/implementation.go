@@ -34,6 +34,21 @@ func (r *HistorizingRepository) Migrate() error {
 	return r.wrappedRepository.Migrate()
 }
 
+func (r *HistorizingRepository) MigrateDownTo(version uint) error {
+	return r.wrappedRepository.MigrateDownTo(version)
+}
+
+func (r *HistorizingRepository) MigrationStatus() ([]dbrepo.MigrationStatus, error) {
+	return r.wrappedRepository.MigrationStatus()
+}
+
+func (r *HistorizingRepository) WithTransaction(ctx context.Context, f func(repo dbrepo.Repository) error) error {
+	// history entries are written through the transaction, so they are rolled back together with the changes
+	return r.wrappedRepository.WithTransaction(ctx, func(repo dbrepo.Repository) error {
+		return f(Create(repo))
+	})
+}
+
 // --- attendee ---
 
 func (r *HistorizingRepository) AddAttendee(ctx context.Context, a *entity.Attendee) (uint, error) {
@@ -50,7 +65,7 @@ func (r *HistorizingRepository) UpdateAttendee(ctx context.Context, a *entity.At
 	oldVersion.CreatedAt = a.CreatedAt
 	oldVersion.UpdatedAt = a.UpdatedAt
 
-	histEntry := diffReverse(ctx, oldVersion, a, "Attendee", a.ID)
+	histEntry := diffReverse(ctx, oldVersion, a, EntityAttendee, a.ID)
 
 	err = r.wrappedRepository.RecordHistory(ctx, histEntry)
 	if err != nil {
@@ -78,7 +93,7 @@ func (r *HistorizingRepository) SoftDeleteAttendeeById(ctx context.Context, id u
 		Valid: true,
 	}
 
-	histEntry := diffReverse(ctx, oldVersion, newVersion, "Attendee", id)
+	histEntry := diffReverse(ctx, oldVersion, newVersion, EntityAttendee, id)
 
 	err = r.wrappedRepository.RecordHistory(ctx, histEntry)
 	if err != nil {
@@ -102,7 +117,7 @@ func (r *HistorizingRepository) UndeleteAttendeeById(ctx context.Context, id uin
 		Valid: false,
 	}
 
-	histEntry := diffReverse(ctx, oldVersion, newVersion, "Attendee", id)
+	histEntry := diffReverse(ctx, oldVersion, newVersion, EntityAttendee, id)
 
 	err = r.wrappedRepository.RecordHistory(ctx, histEntry)
 	if err != nil {
@@ -130,6 +145,14 @@ func (r *HistorizingRepository) FindAttendees(ctx context.Context, criteria *att
 	return r.wrappedRepository.FindAttendees(ctx, criteria)
 }
 
+func (r *HistorizingRepository) StreamAttendees(ctx context.Context, criteria *attendee.AttendeeSearchCriteria, f func(a *entity.AttendeeQueryResult) error) error {
+	return r.wrappedRepository.StreamAttendees(ctx, criteria, f)
+}
+
+func (r *HistorizingRepository) CountAttendees(ctx context.Context, criteria *attendee.AttendeeSearchCriteria) (int64, error) {
+	return r.wrappedRepository.CountAttendees(ctx, criteria)
+}
+
 // --- admin info ---
 
 func (r *HistorizingRepository) GetAdminInfoByAttendeeId(ctx context.Context, attendeeId uint) (*entity.AdminInfo, error) {
@@ -146,7 +169,7 @@ func (r *HistorizingRepository) WriteAdminInfo(ctx context.Context, ai *entity.A
 	oldVersion.CreatedAt = ai.CreatedAt
 	oldVersion.UpdatedAt = ai.UpdatedAt
 
-	histEntry := diffReverse(ctx, oldVersion, ai, "AdminInfo", ai.ID)
+	histEntry := diffReverse(ctx, oldVersion, ai, EntityAdminInfo, ai.ID)
 
 	err = r.wrappedRepository.RecordHistory(ctx, histEntry)
 	if err != nil {
@@ -231,6 +254,308 @@ func (r *HistorizingRepository) DeleteBan(ctx context.Context, b *entity.Ban) er
 	return r.wrappedRepository.DeleteBan(ctx, b)
 }
 
+// --- saved searches ---
+
+func (r *HistorizingRepository) GetAllSavedSearches(ctx context.Context) ([]*entity.SavedSearch, error) {
+	return r.wrappedRepository.GetAllSavedSearches(ctx)
+}
+
+func (r *HistorizingRepository) GetSavedSearchByName(ctx context.Context, name string) (*entity.SavedSearch, error) {
+	return r.wrappedRepository.GetSavedSearchByName(ctx, name)
+}
+
+func (r *HistorizingRepository) AddSavedSearch(ctx context.Context, s *entity.SavedSearch) (uint, error) {
+	return r.wrappedRepository.AddSavedSearch(ctx, s)
+}
+
+func (r *HistorizingRepository) UpdateSavedSearch(ctx context.Context, s *entity.SavedSearch) error {
+	oldVersion, err := r.wrappedRepository.GetSavedSearchByName(ctx, s.Name)
+	if err != nil {
+		return err
+	}
+
+	// hide always present diff in times
+	oldVersion.CreatedAt = s.CreatedAt
+	oldVersion.UpdatedAt = s.UpdatedAt
+
+	histEntry := diffReverse(ctx, oldVersion, s, "SavedSearch", s.ID)
+
+	err = r.wrappedRepository.RecordHistory(ctx, histEntry)
+	if err != nil {
+		return err
+	}
+
+	return r.wrappedRepository.UpdateSavedSearch(ctx, s)
+}
+
+func (r *HistorizingRepository) DeleteSavedSearch(ctx context.Context, s *entity.SavedSearch) error {
+	_, err := r.wrappedRepository.GetSavedSearchByName(ctx, s.Name)
+	if err != nil {
+		return err
+	}
+
+	histEntry := &entity.History{
+		Entity:    "SavedSearch",
+		EntityId:  s.ID,
+		RequestId: ctxvalues.RequestId(ctx),
+		Identity:  ctxvalues.Subject(ctx),
+		Diff:      "<deleted>",
+	}
+
+	err = r.wrappedRepository.RecordHistory(ctx, histEntry)
+	if err != nil {
+		return err
+	}
+
+	return r.wrappedRepository.DeleteSavedSearch(ctx, s)
+}
+
+// --- transfers ---
+
+func (r *HistorizingRepository) GetPendingTransferFor(ctx context.Context, attendeeId uint) (*entity.Transfer, error) {
+	return r.wrappedRepository.GetPendingTransferFor(ctx, attendeeId)
+}
+
+func (r *HistorizingRepository) GetTransferByCode(ctx context.Context, code string) (*entity.Transfer, error) {
+	return r.wrappedRepository.GetTransferByCode(ctx, code)
+}
+
+func (r *HistorizingRepository) AddTransfer(ctx context.Context, t *entity.Transfer) (uint, error) {
+	return r.wrappedRepository.AddTransfer(ctx, t)
+}
+
+func (r *HistorizingRepository) UpdateTransfer(ctx context.Context, t *entity.Transfer) error {
+	oldVersion, err := r.wrappedRepository.GetTransferByCode(ctx, t.Code)
+	if err != nil {
+		return err
+	}
+
+	// hide always present diff in times
+	oldVersion.CreatedAt = t.CreatedAt
+	oldVersion.UpdatedAt = t.UpdatedAt
+
+	histEntry := diffReverse(ctx, oldVersion, t, "Transfer", t.ID)
+
+	err = r.wrappedRepository.RecordHistory(ctx, histEntry)
+	if err != nil {
+		return err
+	}
+
+	return r.wrappedRepository.UpdateTransfer(ctx, t)
+}
+
+// --- groups ---
+
+func (r *HistorizingRepository) GetGroupById(ctx context.Context, id uint) (*entity.Group, error) {
+	return r.wrappedRepository.GetGroupById(ctx, id)
+}
+
+func (r *HistorizingRepository) AddGroup(ctx context.Context, g *entity.Group) (uint, error) {
+	return r.wrappedRepository.AddGroup(ctx, g)
+}
+
+func (r *HistorizingRepository) DeleteGroup(ctx context.Context, g *entity.Group) error {
+	_, err := r.wrappedRepository.GetGroupById(ctx, g.ID)
+	if err != nil {
+		return err
+	}
+
+	histEntry := &entity.History{
+		Entity:    "Group",
+		EntityId:  g.ID,
+		RequestId: ctxvalues.RequestId(ctx),
+		Identity:  ctxvalues.Subject(ctx),
+		Diff:      "<deleted>",
+	}
+
+	err = r.wrappedRepository.RecordHistory(ctx, histEntry)
+	if err != nil {
+		return err
+	}
+
+	return r.wrappedRepository.DeleteGroup(ctx, g)
+}
+
+func (r *HistorizingRepository) GetGroupMembers(ctx context.Context, groupId uint) ([]*entity.GroupMember, error) {
+	return r.wrappedRepository.GetGroupMembers(ctx, groupId)
+}
+
+func (r *HistorizingRepository) GetGroupMembershipsByAttendeeId(ctx context.Context, attendeeId uint) ([]*entity.GroupMember, error) {
+	return r.wrappedRepository.GetGroupMembershipsByAttendeeId(ctx, attendeeId)
+}
+
+func (r *HistorizingRepository) GetGroupMember(ctx context.Context, groupId uint, attendeeId uint) (*entity.GroupMember, error) {
+	return r.wrappedRepository.GetGroupMember(ctx, groupId, attendeeId)
+}
+
+func (r *HistorizingRepository) AddGroupMember(ctx context.Context, m *entity.GroupMember) (uint, error) {
+	return r.wrappedRepository.AddGroupMember(ctx, m)
+}
+
+func (r *HistorizingRepository) UpdateGroupMember(ctx context.Context, m *entity.GroupMember) error {
+	oldVersion, err := r.wrappedRepository.GetGroupMember(ctx, m.GroupId, m.AttendeeId)
+	if err != nil {
+		return err
+	}
+
+	// hide always present diff in times
+	oldVersion.CreatedAt = m.CreatedAt
+	oldVersion.UpdatedAt = m.UpdatedAt
+
+	histEntry := diffReverse(ctx, oldVersion, m, "GroupMember", m.ID)
+
+	err = r.wrappedRepository.RecordHistory(ctx, histEntry)
+	if err != nil {
+		return err
+	}
+
+	return r.wrappedRepository.UpdateGroupMember(ctx, m)
+}
+
+func (r *HistorizingRepository) DeleteGroupMember(ctx context.Context, m *entity.GroupMember) error {
+	_, err := r.wrappedRepository.GetGroupMember(ctx, m.GroupId, m.AttendeeId)
+	if err != nil {
+		return err
+	}
+
+	histEntry := &entity.History{
+		Entity:    "GroupMember",
+		EntityId:  m.ID,
+		RequestId: ctxvalues.RequestId(ctx),
+		Identity:  ctxvalues.Subject(ctx),
+		Diff:      "<deleted>",
+	}
+
+	err = r.wrappedRepository.RecordHistory(ctx, histEntry)
+	if err != nil {
+		return err
+	}
+
+	return r.wrappedRepository.DeleteGroupMember(ctx, m)
+}
+
+// --- package prices ---
+
+func (r *HistorizingRepository) GetPackagePricesByAttendeeId(ctx context.Context, attendeeId uint) ([]*entity.PackagePrice, error) {
+	return r.wrappedRepository.GetPackagePricesByAttendeeId(ctx, attendeeId)
+}
+
+func (r *HistorizingRepository) AddPackagePrice(ctx context.Context, p *entity.PackagePrice) (uint, error) {
+	return r.wrappedRepository.AddPackagePrice(ctx, p)
+}
+
+// --- vouchers ---
+
+func (r *HistorizingRepository) GetAllVouchers(ctx context.Context) ([]*entity.Voucher, error) {
+	return r.wrappedRepository.GetAllVouchers(ctx)
+}
+
+func (r *HistorizingRepository) GetVoucherById(ctx context.Context, id uint) (*entity.Voucher, error) {
+	return r.wrappedRepository.GetVoucherById(ctx, id)
+}
+
+func (r *HistorizingRepository) GetVoucherByCode(ctx context.Context, code string) (*entity.Voucher, error) {
+	return r.wrappedRepository.GetVoucherByCode(ctx, code)
+}
+
+func (r *HistorizingRepository) GetVoucherByCodeForUpdate(ctx context.Context, code string) (*entity.Voucher, error) {
+	return r.wrappedRepository.GetVoucherByCodeForUpdate(ctx, code)
+}
+
+func (r *HistorizingRepository) AddVoucher(ctx context.Context, v *entity.Voucher) (uint, error) {
+	return r.wrappedRepository.AddVoucher(ctx, v)
+}
+
+func (r *HistorizingRepository) UpdateVoucher(ctx context.Context, v *entity.Voucher) error {
+	oldVersion, err := r.wrappedRepository.GetVoucherById(ctx, v.ID)
+	if err != nil {
+		return err
+	}
+
+	// hide always present diff in times
+	oldVersion.CreatedAt = v.CreatedAt
+	oldVersion.UpdatedAt = v.UpdatedAt
+
+	histEntry := diffReverse(ctx, oldVersion, v, "Voucher", v.ID)
+
+	err = r.wrappedRepository.RecordHistory(ctx, histEntry)
+	if err != nil {
+		return err
+	}
+
+	return r.wrappedRepository.UpdateVoucher(ctx, v)
+}
+
+func (r *HistorizingRepository) DeleteVoucher(ctx context.Context, v *entity.Voucher) error {
+	_, err := r.wrappedRepository.GetVoucherById(ctx, v.ID)
+	if err != nil {
+		return err
+	}
+
+	histEntry := &entity.History{
+		Entity:    "Voucher",
+		EntityId:  v.ID,
+		RequestId: ctxvalues.RequestId(ctx),
+		Identity:  ctxvalues.Subject(ctx),
+		Diff:      "<deleted>",
+	}
+
+	err = r.wrappedRepository.RecordHistory(ctx, histEntry)
+	if err != nil {
+		return err
+	}
+
+	return r.wrappedRepository.DeleteVoucher(ctx, v)
+}
+
+func (r *HistorizingRepository) GetVoucherRedemptions(ctx context.Context, voucherId uint) ([]*entity.VoucherRedemption, error) {
+	return r.wrappedRepository.GetVoucherRedemptions(ctx, voucherId)
+}
+
+func (r *HistorizingRepository) GetVoucherRedemptionsByAttendeeId(ctx context.Context, attendeeId uint) ([]*entity.VoucherRedemption, error) {
+	return r.wrappedRepository.GetVoucherRedemptionsByAttendeeId(ctx, attendeeId)
+}
+
+func (r *HistorizingRepository) GetVoucherRedemption(ctx context.Context, voucherId uint, attendeeId uint) (*entity.VoucherRedemption, error) {
+	return r.wrappedRepository.GetVoucherRedemption(ctx, voucherId, attendeeId)
+}
+
+func (r *HistorizingRepository) AddVoucherRedemption(ctx context.Context, vr *entity.VoucherRedemption) (uint, error) {
+	return r.wrappedRepository.AddVoucherRedemption(ctx, vr)
+}
+
+func (r *HistorizingRepository) DeleteVoucherRedemption(ctx context.Context, vr *entity.VoucherRedemption) error {
+	_, err := r.wrappedRepository.GetVoucherRedemption(ctx, vr.VoucherId, vr.AttendeeId)
+	if err != nil {
+		return err
+	}
+
+	histEntry := &entity.History{
+		Entity:    "VoucherRedemption",
+		EntityId:  vr.ID,
+		RequestId: ctxvalues.RequestId(ctx),
+		Identity:  ctxvalues.Subject(ctx),
+		Diff:      "<deleted>",
+	}
+
+	err = r.wrappedRepository.RecordHistory(ctx, histEntry)
+	if err != nil {
+		return err
+	}
+
+	return r.wrappedRepository.DeleteVoucherRedemption(ctx, vr)
+}
+
+// --- invoices ---
+
+func (r *HistorizingRepository) GetInvoicesByAttendeeId(ctx context.Context, attendeeId uint) ([]*entity.Invoice, error) {
+	return r.wrappedRepository.GetInvoicesByAttendeeId(ctx, attendeeId)
+}
+
+func (r *HistorizingRepository) AddInvoice(ctx context.Context, i *entity.Invoice) (uint, error) {
+	return r.wrappedRepository.AddInvoice(ctx, i)
+}
+
 // --- additional info ---
 
 func (r *HistorizingRepository) GetAllAdditionalInfoForArea(ctx context.Context, area string) ([]*entity.AdditionalInfo, error) {
@@ -282,6 +607,94 @@ func (r *HistorizingRepository) GetCount(ctx context.Context, area string, name
 	return r.wrappedRepository.GetCount(ctx, area, name)
 }
 
+func (r *HistorizingRepository) GetCountForUpdate(ctx context.Context, area string, name string) (*entity.Count, error) {
+	return r.wrappedRepository.GetCountForUpdate(ctx, area, name)
+}
+
+// --- waiting list ---
+
+// the waiting list is not historized, the status changes caused by it are
+
+func (r *HistorizingRepository) GetWaitlistForPackage(ctx context.Context, pkg string) ([]*entity.WaitlistEntry, error) {
+	return r.wrappedRepository.GetWaitlistForPackage(ctx, pkg)
+}
+
+func (r *HistorizingRepository) GetWaitlistEntriesByAttendeeId(ctx context.Context, attendeeId uint) ([]*entity.WaitlistEntry, error) {
+	return r.wrappedRepository.GetWaitlistEntriesByAttendeeId(ctx, attendeeId)
+}
+
+func (r *HistorizingRepository) AddWaitlistEntry(ctx context.Context, e *entity.WaitlistEntry) (uint, error) {
+	return r.wrappedRepository.AddWaitlistEntry(ctx, e)
+}
+
+func (r *HistorizingRepository) UpdateWaitlistEntry(ctx context.Context, e *entity.WaitlistEntry) error {
+	return r.wrappedRepository.UpdateWaitlistEntry(ctx, e)
+}
+
+func (r *HistorizingRepository) DeleteWaitlistEntry(ctx context.Context, e *entity.WaitlistEntry) error {
+	return r.wrappedRepository.DeleteWaitlistEntry(ctx, e)
+}
+
+// --- mail outbox ---
+
+// the outbox is not historized, it is a delivery queue
+
+func (r *HistorizingRepository) AddOutboxMail(ctx context.Context, m *entity.OutboxMail) (uint, error) {
+	return r.wrappedRepository.AddOutboxMail(ctx, m)
+}
+
+func (r *HistorizingRepository) UpdateOutboxMail(ctx context.Context, m *entity.OutboxMail) error {
+	return r.wrappedRepository.UpdateOutboxMail(ctx, m)
+}
+
+func (r *HistorizingRepository) GetOutboxMailById(ctx context.Context, id uint) (*entity.OutboxMail, error) {
+	return r.wrappedRepository.GetOutboxMailById(ctx, id)
+}
+
+func (r *HistorizingRepository) GetOutboxMailsByState(ctx context.Context, state string) ([]*entity.OutboxMail, error) {
+	return r.wrappedRepository.GetOutboxMailsByState(ctx, state)
+}
+
+func (r *HistorizingRepository) GetDueOutboxMails(ctx context.Context, now time.Time, limit int) ([]*entity.OutboxMail, error) {
+	return r.wrappedRepository.GetDueOutboxMails(ctx, now, limit)
+}
+
+func (r *HistorizingRepository) ClaimOutboxMail(ctx context.Context, m *entity.OutboxMail, now time.Time, leaseUntil time.Time) (bool, error) {
+	return r.wrappedRepository.ClaimOutboxMail(ctx, m, now, leaseUntil)
+}
+
+func (r *HistorizingRepository) DeleteOutboxMail(ctx context.Context, m *entity.OutboxMail) error {
+	return r.wrappedRepository.DeleteOutboxMail(ctx, m)
+}
+
+// --- webhook deliveries ---
+
+// webhook deliveries are not historized, they are a delivery queue
+
+func (r *HistorizingRepository) AddWebhookDelivery(ctx context.Context, d *entity.WebhookDelivery) (uint, error) {
+	return r.wrappedRepository.AddWebhookDelivery(ctx, d)
+}
+
+func (r *HistorizingRepository) UpdateWebhookDelivery(ctx context.Context, d *entity.WebhookDelivery) error {
+	return r.wrappedRepository.UpdateWebhookDelivery(ctx, d)
+}
+
+func (r *HistorizingRepository) GetPendingWebhookDeliveries(ctx context.Context, subscriber string, limit int) ([]*entity.WebhookDelivery, error) {
+	return r.wrappedRepository.GetPendingWebhookDeliveries(ctx, subscriber, limit)
+}
+
+func (r *HistorizingRepository) LockWebhookQueue(ctx context.Context, subscriber string, holder string, now time.Time, lockedUntil time.Time) (bool, error) {
+	return r.wrappedRepository.LockWebhookQueue(ctx, subscriber, holder, now, lockedUntil)
+}
+
+func (r *HistorizingRepository) UnlockWebhookQueue(ctx context.Context, subscriber string, holder string) error {
+	return r.wrappedRepository.UnlockWebhookQueue(ctx, subscriber, holder)
+}
+
+func (r *HistorizingRepository) GetWebhookDeliveryLog(ctx context.Context, subscriber string, state string, attendeeId uint, limit int) ([]*entity.WebhookDelivery, error) {
+	return r.wrappedRepository.GetWebhookDeliveryLog(ctx, subscriber, state, attendeeId, limit)
+}
+
 // --- history ---
 
 // it is an error to call this from the outside. From the inside use wrappedRepository.RecordHistory to bypass the error
@@ -290,6 +703,10 @@ func (r *HistorizingRepository) RecordHistory(ctx context.Context, h *entity.His
 }
 
 // we diff reverse so the OLD value is printed in the diffs. The new value is in the database now.
+func (r *HistorizingRepository) GetHistoryByEntity(ctx context.Context, entityName string, entityId uint) ([]*entity.History, error) {
+	return r.wrappedRepository.GetHistoryByEntity(ctx, entityName, entityId)
+}
+
 func diffReverse[T any](ctx context.Context, oldVersion *T, newVersion *T, entityName string, entityID uint) *entity.History {
 	histEntry := &entity.History{
 		Entity:    entityName,
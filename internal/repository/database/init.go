@@ -9,7 +9,10 @@ import (
 	"github.com/eurofurence/reg-attendee-service/internal/repository/database/dbrepo"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/database/historizeddb"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/database/inmemorydb"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database/migrations"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/database/mysqldb"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database/postgresdb"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database/sqlitedb"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/system"
 )
 
@@ -17,6 +20,8 @@ var (
 	ActiveRepository dbrepo.Repository
 )
 
+var ErrSchemaAhead = migrations.ErrSchemaAhead
+
 // only exported so you can use it in test code - use Open()
 func SetRepository(repository dbrepo.Repository) {
 	ActiveRepository = repository
@@ -24,10 +29,17 @@ func SetRepository(repository dbrepo.Repository) {
 
 func Open() error {
 	var r dbrepo.Repository
-	if config.DatabaseUse() == "mysql" {
+	switch config.DatabaseUse() {
+	case config.Mysql:
 		aulogging.Logger.NoCtx().Info().Print("Opening mysql database...")
 		r = historizeddb.Create(mysqldb.Create())
-	} else {
+	case config.Postgres:
+		aulogging.Logger.NoCtx().Info().Print("Opening postgres database...")
+		r = historizeddb.Create(postgresdb.Create())
+	case config.Sqlite:
+		aulogging.Logger.NoCtx().Info().Print("Opening sqlite database...")
+		r = historizeddb.Create(sqlitedb.Create())
+	default:
 		aulogging.Logger.NoCtx().Warn().Print("Opening inmemory database (not useful for production!)...")
 		r = historizeddb.Create(inmemorydb.Create())
 	}
@@ -58,6 +70,56 @@ func MigrateIfSwitchedOn() error {
 	return nil
 }
 
+// CheckSchemaVersion refuses to continue if the database schema has been migrated by a newer version of this
+// service, and warns about pending migrations.
+func CheckSchemaVersion() error {
+	migrations, err := GetRepository().MigrationStatus()
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, m := range migrations {
+		if m.Unknown {
+			aulogging.Logger.NoCtx().Error().Printf("database schema has unknown migration %04d %s applied, so it is ahead of this version of the service. Refusing to start.", m.Version, m.Name)
+			return ErrSchemaAhead
+		}
+		if !m.Applied {
+			pending++
+		}
+	}
+	if pending > 0 && !config.MigrateDatabase() {
+		aulogging.Logger.NoCtx().Warn().Printf("database schema has %d pending migrations. Provide -migrate-database command line switch to apply them.", pending)
+	}
+	return nil
+}
+
+// LogMigrationStatus lists all migrations and whether they have been applied.
+func LogMigrationStatus() error {
+	migrations, err := GetRepository().MigrationStatus()
+	if err != nil {
+		return err
+	}
+	if len(migrations) == 0 {
+		aulogging.Logger.NoCtx().Info().Print("This database type does not use migrations.")
+	}
+	for _, m := range migrations {
+		switch {
+		case m.Unknown:
+			aulogging.Logger.NoCtx().Warn().Printf("migration %04d %s: applied %s, but UNKNOWN to this version of the service", m.Version, m.Name, m.AppliedAt.Format(time.RFC3339))
+		case m.Applied:
+			aulogging.Logger.NoCtx().Info().Printf("migration %04d %s: applied %s", m.Version, m.Name, m.AppliedAt.Format(time.RFC3339))
+		default:
+			aulogging.Logger.NoCtx().Info().Printf("migration %04d %s: pending", m.Version, m.Name)
+		}
+	}
+	return nil
+}
+
+func MigrateDownTo(version uint) error {
+	aulogging.Logger.NoCtx().Info().Printf("Reverting database migrations after version %d...", version)
+	return GetRepository().MigrateDownTo(version)
+}
+
 func SetUpPackageCounts() error {
 	for key, conf := range config.PackagesConfig() {
 		if conf.Limit > 0 {
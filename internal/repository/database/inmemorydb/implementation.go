@@ -24,8 +24,28 @@ type InMemoryRepository struct {
 	statusChanges map[uint][]entity.StatusChange
 	history       map[uint]*entity.History
 	counts        map[string]entity.Count
+	waitlist      map[uint]*entity.WaitlistEntry
+	outbox        map[uint]*entity.OutboxMail
+	webhooks      map[uint]*entity.WebhookDelivery
+	webhookQueues map[string]*entity.WebhookQueue
+	savedSearches map[uint]*entity.SavedSearch
+	transfers     map[uint]*entity.Transfer
+	groups        map[uint]*entity.Group
+	groupMembers  map[uint]*entity.GroupMember
+	packagePrices map[uint]*entity.PackagePrice
+	vouchers      map[uint]*entity.Voucher
+	redemptions   map[uint]*entity.VoucherRedemption
+	invoices      map[uint]*entity.Invoice
 	idSequence    uint32
-	Now           func() time.Time
+	// webhook deliveries are queued for many writes, so they get their own sequence
+	// to avoid shifting the ids of everything else
+	webhookIdSequence uint32
+	// same for package price locks, which are created on many registrations
+	packagePriceIdSequence uint32
+	// and invoices, which are issued on many status changes
+	invoiceIdSequence     uint32
+	invoiceNumberSequence uint32
+	Now                   func() time.Time
 }
 
 func Create() dbrepo.Repository {
@@ -42,6 +62,18 @@ func (r *InMemoryRepository) Open() error {
 	r.statusChanges = make(map[uint][]entity.StatusChange)
 	r.history = make(map[uint]*entity.History)
 	r.counts = make(map[string]entity.Count)
+	r.waitlist = make(map[uint]*entity.WaitlistEntry)
+	r.outbox = make(map[uint]*entity.OutboxMail)
+	r.webhooks = make(map[uint]*entity.WebhookDelivery)
+	r.webhookQueues = make(map[string]*entity.WebhookQueue)
+	r.savedSearches = make(map[uint]*entity.SavedSearch)
+	r.transfers = make(map[uint]*entity.Transfer)
+	r.groups = make(map[uint]*entity.Group)
+	r.groupMembers = make(map[uint]*entity.GroupMember)
+	r.packagePrices = make(map[uint]*entity.PackagePrice)
+	r.vouchers = make(map[uint]*entity.Voucher)
+	r.redemptions = make(map[uint]*entity.VoucherRedemption)
+	r.invoices = make(map[uint]*entity.Invoice)
 	return nil
 }
 
@@ -53,6 +85,18 @@ func (r *InMemoryRepository) Close() {
 	r.statusChanges = nil
 	r.history = nil
 	r.counts = nil
+	r.waitlist = nil
+	r.outbox = nil
+	r.webhooks = nil
+	r.webhookQueues = nil
+	r.savedSearches = nil
+	r.transfers = nil
+	r.groups = nil
+	r.groupMembers = nil
+	r.packagePrices = nil
+	r.vouchers = nil
+	r.redemptions = nil
+	r.invoices = nil
 }
 
 func (r *InMemoryRepository) Migrate() error {
@@ -60,6 +104,16 @@ func (r *InMemoryRepository) Migrate() error {
 	return nil
 }
 
+func (r *InMemoryRepository) MigrateDownTo(version uint) error {
+	// nothing to do
+	return nil
+}
+
+func (r *InMemoryRepository) MigrationStatus() ([]dbrepo.MigrationStatus, error) {
+	// there is no schema, so there are no migrations
+	return make([]dbrepo.MigrationStatus, 0), nil
+}
+
 // --- attendee ---
 
 func (r *InMemoryRepository) AddAttendee(ctx context.Context, a *entity.Attendee) (uint, error) {
@@ -158,18 +212,25 @@ func (r *InMemoryRepository) MaxAttendeeId(ctx context.Context) (uint, error) {
 // --- attendee search ---
 
 func (r *InMemoryRepository) FindAttendees(ctx context.Context, criteria *attendee.AttendeeSearchCriteria) ([]*entity.AttendeeQueryResult, error) {
-	resultIds := make([]uint, 0)
-	for id, a := range r.attendees {
-		adm, _ := r.GetAdminInfoByAttendeeId(ctx, a.ID)
-		sc, _ := r.GetLatestStatusChangeByAttendeeId(ctx, a.ID)
-		addInfs := r.GetAllAdditionalInfoOrEmptyMap(ctx, a.ID)
-		if r.matchesCriteria(criteria, a, adm, sc, addInfs) {
-			resultIds = append(resultIds, id)
+	cursor, err := dbrepo.DecodeSearchCursor(criteria)
+	if err != nil {
+		return nil, err
+	}
+	if dbrepo.HasFuzzyCriteria(criteria) {
+		matches, err := dbrepo.FuzzySearchMatches(criteria, r.fuzzyFind(ctx))
+		if err != nil {
+			return nil, err
 		}
+		return dbrepo.FuzzySearchPage(criteria, cursor, matches, r.fuzzyFind(ctx))
 	}
 
+	resultIds := r.matchingAttendeeIds(ctx, criteria)
 	sort.Slice(resultIds, r.lessFunction(criteria.SortBy, criteria.SortOrder, resultIds))
 
+	if cursor != nil {
+		resultIds = r.idsAfterCursor(criteria, resultIds, cursor.Id)
+	}
+
 	resultLen := len(resultIds)
 	if criteria.NumResults > 0 && resultLen > int(criteria.NumResults) {
 		resultLen = int(criteria.NumResults)
@@ -186,6 +247,7 @@ func (r *InMemoryRepository) FindAttendees(ctx context.Context, criteria *attend
 				Status:        latestStatus.Status,
 				AdminComments: adminInfo.AdminComments,
 				AdminFlags:    adminInfo.Flags,
+				GroupId:       r.groupIdOf(aid),
 			}
 			result[i] = &copiedResult
 		}
@@ -194,34 +256,99 @@ func (r *InMemoryRepository) FindAttendees(ctx context.Context, criteria *attend
 	return result, nil
 }
 
+func (r *InMemoryRepository) StreamAttendees(ctx context.Context, criteria *attendee.AttendeeSearchCriteria, f func(a *entity.AttendeeQueryResult) error) error {
+	result, err := r.FindAttendees(ctx, criteria)
+	if err != nil {
+		return err
+	}
+	for _, a := range result {
+		if err := f(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *InMemoryRepository) CountAttendees(ctx context.Context, criteria *attendee.AttendeeSearchCriteria) (int64, error) {
+	if dbrepo.HasFuzzyCriteria(criteria) {
+		matches, err := dbrepo.FuzzySearchMatches(criteria, r.fuzzyFind(ctx))
+		return int64(len(matches)), err
+	}
+	return int64(len(r.matchingAttendeeIds(ctx, criteria))), nil
+}
+
+func (r *InMemoryRepository) fuzzyFind(ctx context.Context) func(criteria *attendee.AttendeeSearchCriteria) ([]*entity.AttendeeQueryResult, error) {
+	return func(criteria *attendee.AttendeeSearchCriteria) ([]*entity.AttendeeQueryResult, error) {
+		// no need to prefilter in memory, all candidates are scored anyway
+		candidateCriteria := *criteria
+		candidateCriteria.MatchAny = make([]attendee.AttendeeSearchSingleCriterion, len(criteria.MatchAny))
+		for i, cond := range criteria.MatchAny {
+			cond.Fuzzy = ""
+			candidateCriteria.MatchAny[i] = cond
+		}
+		return r.FindAttendees(ctx, &candidateCriteria)
+	}
+}
+
+func (r *InMemoryRepository) matchingAttendeeIds(ctx context.Context, criteria *attendee.AttendeeSearchCriteria) []uint {
+	resultIds := make([]uint, 0)
+	for id, a := range r.attendees {
+		adm, _ := r.GetAdminInfoByAttendeeId(ctx, a.ID)
+		sc, _ := r.GetLatestStatusChangeByAttendeeId(ctx, a.ID)
+		addInfs := r.GetAllAdditionalInfoOrEmptyMap(ctx, a.ID)
+		if r.matchesCriteria(criteria, a, adm, sc, addInfs) {
+			resultIds = append(resultIds, id)
+		}
+	}
+	return resultIds
+}
+
+// idsAfterCursor returns the sorted ids that come after the attendee with the cursor id, even if that
+// attendee no longer matches.
+func (r *InMemoryRepository) idsAfterCursor(criteria *attendee.AttendeeSearchCriteria, sortedIds []uint, cursorId uint) []uint {
+	cursorAttendee, ok := r.attendees[cursorId]
+	if !ok {
+		return []uint{}
+	}
+	first := sort.Search(len(sortedIds), func(i int) bool {
+		return lessAttendee(cursorAttendee, r.attendees[sortedIds[i]], criteria.SortBy, criteria.SortOrder)
+	})
+	return sortedIds[first:]
+}
+
 func (r *InMemoryRepository) lessFunction(sortBy string, sortOrder string, matchingIds []uint) func(i, j int) bool {
 	return func(i, j int) bool {
-		a1 := r.attendees[matchingIds[i]]
-		a2 := r.attendees[matchingIds[j]]
-		switch sortBy {
-		case "status":
-			// TODO status lookup and sort by it
-			return lessFunctionId(a1, a2, sortOrder)
-		case "nickname":
-			return lessFunctionString(a1, a2, func(a *entity.Attendee) string { return a.Nickname }, sortOrder)
-		case "birthday":
-			return lessFunctionString(a1, a2, func(a *entity.Attendee) string { return a.Birthday }, sortOrder)
-		case "email":
-			return lessFunctionString(a1, a2, func(a *entity.Attendee) string { return a.Email }, sortOrder)
-		case "name":
-			return lessFunctionString(a1, a2, func(a *entity.Attendee) string { return a.FirstName + " " + a.LastName }, sortOrder)
-		case "zip":
-			return lessFunctionString(a1, a2, func(a *entity.Attendee) string { return a.Zip }, sortOrder)
-		case "city":
-			return lessFunctionString(a1, a2, func(a *entity.Attendee) string { return a.City }, sortOrder)
-		case "country":
-			return lessFunctionString(a1, a2, func(a *entity.Attendee) string { return a.Country }, sortOrder)
-		default:
-			return lessFunctionId(a1, a2, sortOrder)
-		}
+		return lessAttendee(r.attendees[matchingIds[i]], r.attendees[matchingIds[j]], sortBy, sortOrder)
 	}
 }
 
+// lessAttendee compares by the sort field first, then by id, so the order is unique.
+func lessAttendee(a1 *entity.Attendee, a2 *entity.Attendee, sortBy string, sortOrder string) bool {
+	var get func(a *entity.Attendee) string
+	switch sortBy {
+	case "status":
+		// TODO status lookup and sort by it
+	case "nickname":
+		get = func(a *entity.Attendee) string { return a.Nickname }
+	case "birthday":
+		get = func(a *entity.Attendee) string { return a.Birthday }
+	case "email":
+		get = func(a *entity.Attendee) string { return a.Email }
+	case "name":
+		get = func(a *entity.Attendee) string { return a.FirstName + " " + a.LastName }
+	case "zip":
+		get = func(a *entity.Attendee) string { return a.Zip }
+	case "city":
+		get = func(a *entity.Attendee) string { return a.City }
+	case "country":
+		get = func(a *entity.Attendee) string { return a.Country }
+	}
+	if get != nil && get(a1) != get(a2) {
+		return lessFunctionString(a1, a2, get, sortOrder)
+	}
+	return lessFunctionId(a1, a2, sortOrder)
+}
+
 func lessFunctionId(a1 *entity.Attendee, a2 *entity.Attendee, sortOrder string) bool {
 	if sortOrder == "descending" {
 		return a1.ID > a2.ID
@@ -372,6 +499,460 @@ func (r *InMemoryRepository) DeleteBan(ctx context.Context, b *entity.Ban) error
 	}
 }
 
+// --- saved searches ---
+
+func (r *InMemoryRepository) GetAllSavedSearches(ctx context.Context) ([]*entity.SavedSearch, error) {
+	result := make([]*entity.SavedSearch, 0)
+	for _, s := range r.savedSearches {
+		copiedSearch := *s
+		result = append(result, &copiedSearch)
+	}
+	sort.Slice(result, func(i int, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+func (r *InMemoryRepository) GetSavedSearchByName(ctx context.Context, name string) (*entity.SavedSearch, error) {
+	for _, s := range r.savedSearches {
+		if s.Name == name {
+			copiedSearch := *s
+			return &copiedSearch, nil
+		}
+	}
+	return &entity.SavedSearch{}, gorm.ErrRecordNotFound
+}
+
+func (r *InMemoryRepository) AddSavedSearch(ctx context.Context, s *entity.SavedSearch) (uint, error) {
+	for _, existing := range r.savedSearches {
+		if existing.Name == s.Name {
+			return 0, errors.New("unique constraint violated, there is already a saved search with this name")
+		}
+	}
+
+	newId := uint(atomic.AddUint32(&r.idSequence, 1))
+	s.ID = newId
+	s.CreatedAt = r.Now()
+	s.UpdatedAt = s.CreatedAt
+
+	// copy the search, so later modifications won't also modify it in the simulated db
+	copiedSearch := *s
+	r.savedSearches[newId] = &copiedSearch
+	return newId, nil
+}
+
+func (r *InMemoryRepository) UpdateSavedSearch(ctx context.Context, s *entity.SavedSearch) error {
+	if _, ok := r.savedSearches[s.ID]; ok {
+		s.UpdatedAt = r.Now()
+		// copy the search, so later modifications won't also modify it in the simulated db
+		copiedSearch := *s
+		r.savedSearches[s.ID] = &copiedSearch
+		return nil
+	} else {
+		return fmt.Errorf("cannot update saved search %d - not present", s.ID)
+	}
+}
+
+func (r *InMemoryRepository) DeleteSavedSearch(ctx context.Context, s *entity.SavedSearch) error {
+	if _, ok := r.savedSearches[s.ID]; ok {
+		delete(r.savedSearches, s.ID)
+		return nil
+	} else {
+		return fmt.Errorf("cannot delete saved search %d - not present", s.ID)
+	}
+}
+
+// --- transfers ---
+
+func (r *InMemoryRepository) GetPendingTransferFor(ctx context.Context, attendeeId uint) (*entity.Transfer, error) {
+	var found *entity.Transfer
+	for _, t := range r.transfers {
+		if t.AttendeeId == attendeeId && t.State == entity.TransferStatePending {
+			if found == nil || t.ID > found.ID {
+				found = t
+			}
+		}
+	}
+	if found == nil {
+		return &entity.Transfer{}, gorm.ErrRecordNotFound
+	}
+	copiedTransfer := *found
+	return &copiedTransfer, nil
+}
+
+func (r *InMemoryRepository) GetTransferByCode(ctx context.Context, code string) (*entity.Transfer, error) {
+	for _, t := range r.transfers {
+		if t.Code == code {
+			copiedTransfer := *t
+			return &copiedTransfer, nil
+		}
+	}
+	return &entity.Transfer{}, gorm.ErrRecordNotFound
+}
+
+func (r *InMemoryRepository) AddTransfer(ctx context.Context, t *entity.Transfer) (uint, error) {
+	for _, existing := range r.transfers {
+		if existing.Code == t.Code {
+			return 0, errors.New("unique constraint violated, there is already a transfer with this code")
+		}
+	}
+
+	newId := uint(atomic.AddUint32(&r.idSequence, 1))
+	t.ID = newId
+	t.CreatedAt = r.Now()
+	t.UpdatedAt = t.CreatedAt
+
+	// copy the transfer, so later modifications won't also modify it in the simulated db
+	copiedTransfer := *t
+	r.transfers[newId] = &copiedTransfer
+	return newId, nil
+}
+
+func (r *InMemoryRepository) UpdateTransfer(ctx context.Context, t *entity.Transfer) error {
+	if _, ok := r.transfers[t.ID]; ok {
+		t.UpdatedAt = r.Now()
+		// copy the transfer, so later modifications won't also modify it in the simulated db
+		copiedTransfer := *t
+		r.transfers[t.ID] = &copiedTransfer
+		return nil
+	} else {
+		return fmt.Errorf("cannot update transfer %d - not present", t.ID)
+	}
+}
+
+// --- groups ---
+
+func (r *InMemoryRepository) GetGroupById(ctx context.Context, id uint) (*entity.Group, error) {
+	g, ok := r.groups[id]
+	if !ok {
+		return &entity.Group{}, gorm.ErrRecordNotFound
+	}
+	copiedGroup := *g
+	return &copiedGroup, nil
+}
+
+func (r *InMemoryRepository) AddGroup(ctx context.Context, g *entity.Group) (uint, error) {
+	newId := uint(atomic.AddUint32(&r.idSequence, 1))
+	g.ID = newId
+	g.CreatedAt = r.Now()
+	g.UpdatedAt = g.CreatedAt
+
+	// copy the group, so later modifications won't also modify it in the simulated db
+	copiedGroup := *g
+	r.groups[newId] = &copiedGroup
+	return newId, nil
+}
+
+func (r *InMemoryRepository) DeleteGroup(ctx context.Context, g *entity.Group) error {
+	if _, ok := r.groups[g.ID]; ok {
+		delete(r.groups, g.ID)
+		return nil
+	} else {
+		return fmt.Errorf("cannot delete group %d - not present", g.ID)
+	}
+}
+
+func (r *InMemoryRepository) GetGroupMembers(ctx context.Context, groupId uint) ([]*entity.GroupMember, error) {
+	return r.selectGroupMembers(func(m *entity.GroupMember) bool {
+		return m.GroupId == groupId
+	}), nil
+}
+
+func (r *InMemoryRepository) GetGroupMembershipsByAttendeeId(ctx context.Context, attendeeId uint) ([]*entity.GroupMember, error) {
+	return r.selectGroupMembers(func(m *entity.GroupMember) bool {
+		return m.AttendeeId == attendeeId
+	}), nil
+}
+
+func (r *InMemoryRepository) GetGroupMember(ctx context.Context, groupId uint, attendeeId uint) (*entity.GroupMember, error) {
+	found := r.selectGroupMembers(func(m *entity.GroupMember) bool {
+		return m.GroupId == groupId && m.AttendeeId == attendeeId
+	})
+	if len(found) == 0 {
+		return &entity.GroupMember{}, gorm.ErrRecordNotFound
+	}
+	return found[0], nil
+}
+
+// groupIdOf returns the id of the group the attendee is a member of, or 0 if none.
+func (r *InMemoryRepository) groupIdOf(attendeeId uint) uint {
+	for _, m := range r.groupMembers {
+		if m.AttendeeId == attendeeId && m.State == entity.GroupMemberStateAccepted {
+			return m.GroupId
+		}
+	}
+	return 0
+}
+
+func (r *InMemoryRepository) selectGroupMembers(matches func(m *entity.GroupMember) bool) []*entity.GroupMember {
+	result := make([]*entity.GroupMember, 0)
+	for _, m := range r.groupMembers {
+		if matches(m) {
+			copiedMember := *m
+			result = append(result, &copiedMember)
+		}
+	}
+	sort.Slice(result, func(i int, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+func (r *InMemoryRepository) AddGroupMember(ctx context.Context, m *entity.GroupMember) (uint, error) {
+	for _, existing := range r.groupMembers {
+		if existing.GroupId == m.GroupId && existing.AttendeeId == m.AttendeeId {
+			return 0, errors.New("unique constraint violated, attendee is already invited to this group")
+		}
+	}
+
+	newId := uint(atomic.AddUint32(&r.idSequence, 1))
+	m.ID = newId
+	m.CreatedAt = r.Now()
+	m.UpdatedAt = m.CreatedAt
+
+	// copy the member, so later modifications won't also modify it in the simulated db
+	copiedMember := *m
+	r.groupMembers[newId] = &copiedMember
+	return newId, nil
+}
+
+func (r *InMemoryRepository) UpdateGroupMember(ctx context.Context, m *entity.GroupMember) error {
+	if _, ok := r.groupMembers[m.ID]; ok {
+		m.UpdatedAt = r.Now()
+		// copy the member, so later modifications won't also modify it in the simulated db
+		copiedMember := *m
+		r.groupMembers[m.ID] = &copiedMember
+		return nil
+	} else {
+		return fmt.Errorf("cannot update group member %d - not present", m.ID)
+	}
+}
+
+func (r *InMemoryRepository) DeleteGroupMember(ctx context.Context, m *entity.GroupMember) error {
+	if _, ok := r.groupMembers[m.ID]; ok {
+		delete(r.groupMembers, m.ID)
+		return nil
+	} else {
+		return fmt.Errorf("cannot delete group member %d - not present", m.ID)
+	}
+}
+
+// --- package prices ---
+
+func (r *InMemoryRepository) GetPackagePricesByAttendeeId(ctx context.Context, attendeeId uint) ([]*entity.PackagePrice, error) {
+	result := make([]*entity.PackagePrice, 0)
+	for _, p := range r.packagePrices {
+		if p.AttendeeId == attendeeId {
+			copiedPrice := *p
+			result = append(result, &copiedPrice)
+		}
+	}
+	sort.Slice(result, func(i int, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+func (r *InMemoryRepository) AddPackagePrice(ctx context.Context, p *entity.PackagePrice) (uint, error) {
+	for _, existing := range r.packagePrices {
+		if existing.AttendeeId == p.AttendeeId && existing.Package == p.Package {
+			return 0, errors.New("unique constraint violated, package price is already locked for this attendee")
+		}
+	}
+
+	newId := uint(atomic.AddUint32(&r.packagePriceIdSequence, 1))
+	p.ID = newId
+	p.CreatedAt = r.Now()
+	p.UpdatedAt = p.CreatedAt
+
+	// copy the price, so later modifications won't also modify it in the simulated db
+	copiedPrice := *p
+	r.packagePrices[newId] = &copiedPrice
+	return newId, nil
+}
+
+// --- vouchers ---
+
+func (r *InMemoryRepository) GetAllVouchers(ctx context.Context) ([]*entity.Voucher, error) {
+	result := make([]*entity.Voucher, 0)
+	for _, v := range r.vouchers {
+		copiedVoucher := *v
+		result = append(result, &copiedVoucher)
+	}
+	sort.Slice(result, func(i int, j int) bool {
+		return result[i].Code < result[j].Code
+	})
+	return result, nil
+}
+
+func (r *InMemoryRepository) GetVoucherById(ctx context.Context, id uint) (*entity.Voucher, error) {
+	v, ok := r.vouchers[id]
+	if !ok {
+		return &entity.Voucher{}, gorm.ErrRecordNotFound
+	}
+	copiedVoucher := *v
+	return &copiedVoucher, nil
+}
+
+func (r *InMemoryRepository) GetVoucherByCode(ctx context.Context, code string) (*entity.Voucher, error) {
+	for _, v := range r.vouchers {
+		if v.Code == code {
+			copiedVoucher := *v
+			return &copiedVoucher, nil
+		}
+	}
+	return &entity.Voucher{}, gorm.ErrRecordNotFound
+}
+
+func (r *InMemoryRepository) GetVoucherByCodeForUpdate(ctx context.Context, code string) (*entity.Voucher, error) {
+	// nothing to lock, the in-memory database is not used concurrently
+	return r.GetVoucherByCode(ctx, code)
+}
+
+func (r *InMemoryRepository) AddVoucher(ctx context.Context, v *entity.Voucher) (uint, error) {
+	for _, existing := range r.vouchers {
+		if existing.Code == v.Code {
+			return 0, errors.New("unique constraint violated, there is already a voucher with this code")
+		}
+	}
+
+	newId := uint(atomic.AddUint32(&r.idSequence, 1))
+	v.ID = newId
+	v.CreatedAt = r.Now()
+	v.UpdatedAt = v.CreatedAt
+
+	// copy the voucher, so later modifications won't also modify it in the simulated db
+	copiedVoucher := *v
+	r.vouchers[newId] = &copiedVoucher
+	return newId, nil
+}
+
+func (r *InMemoryRepository) UpdateVoucher(ctx context.Context, v *entity.Voucher) error {
+	if _, ok := r.vouchers[v.ID]; ok {
+		v.UpdatedAt = r.Now()
+		// copy the voucher, so later modifications won't also modify it in the simulated db
+		copiedVoucher := *v
+		r.vouchers[v.ID] = &copiedVoucher
+		return nil
+	} else {
+		return fmt.Errorf("cannot update voucher %d - not present", v.ID)
+	}
+}
+
+func (r *InMemoryRepository) DeleteVoucher(ctx context.Context, v *entity.Voucher) error {
+	if _, ok := r.vouchers[v.ID]; ok {
+		delete(r.vouchers, v.ID)
+		return nil
+	} else {
+		return fmt.Errorf("cannot delete voucher %d - not present", v.ID)
+	}
+}
+
+func (r *InMemoryRepository) GetVoucherRedemptions(ctx context.Context, voucherId uint) ([]*entity.VoucherRedemption, error) {
+	return r.selectVoucherRedemptions(func(vr *entity.VoucherRedemption) bool {
+		return vr.VoucherId == voucherId
+	}), nil
+}
+
+func (r *InMemoryRepository) GetVoucherRedemptionsByAttendeeId(ctx context.Context, attendeeId uint) ([]*entity.VoucherRedemption, error) {
+	return r.selectVoucherRedemptions(func(vr *entity.VoucherRedemption) bool {
+		return vr.AttendeeId == attendeeId
+	}), nil
+}
+
+func (r *InMemoryRepository) GetVoucherRedemption(ctx context.Context, voucherId uint, attendeeId uint) (*entity.VoucherRedemption, error) {
+	found := r.selectVoucherRedemptions(func(vr *entity.VoucherRedemption) bool {
+		return vr.VoucherId == voucherId && vr.AttendeeId == attendeeId
+	})
+	if len(found) == 0 {
+		return &entity.VoucherRedemption{}, gorm.ErrRecordNotFound
+	}
+	return found[0], nil
+}
+
+// voucherCodesOf returns the codes of all vouchers the attendee has redeemed.
+func (r *InMemoryRepository) voucherCodesOf(attendeeId uint) []string {
+	result := make([]string, 0)
+	for _, vr := range r.redemptions {
+		if vr.AttendeeId == attendeeId {
+			if v, ok := r.vouchers[vr.VoucherId]; ok {
+				result = append(result, v.Code)
+			}
+		}
+	}
+	return result
+}
+
+func (r *InMemoryRepository) selectVoucherRedemptions(matches func(vr *entity.VoucherRedemption) bool) []*entity.VoucherRedemption {
+	result := make([]*entity.VoucherRedemption, 0)
+	for _, vr := range r.redemptions {
+		if matches(vr) {
+			copiedRedemption := *vr
+			result = append(result, &copiedRedemption)
+		}
+	}
+	sort.Slice(result, func(i int, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+func (r *InMemoryRepository) AddVoucherRedemption(ctx context.Context, vr *entity.VoucherRedemption) (uint, error) {
+	for _, existing := range r.redemptions {
+		if existing.VoucherId == vr.VoucherId && existing.AttendeeId == vr.AttendeeId {
+			return 0, errors.New("unique constraint violated, attendee has already redeemed this voucher")
+		}
+	}
+
+	newId := uint(atomic.AddUint32(&r.idSequence, 1))
+	vr.ID = newId
+	vr.CreatedAt = r.Now()
+	vr.UpdatedAt = vr.CreatedAt
+
+	// copy the redemption, so later modifications won't also modify it in the simulated db
+	copiedRedemption := *vr
+	r.redemptions[newId] = &copiedRedemption
+	return newId, nil
+}
+
+func (r *InMemoryRepository) DeleteVoucherRedemption(ctx context.Context, vr *entity.VoucherRedemption) error {
+	if _, ok := r.redemptions[vr.ID]; ok {
+		delete(r.redemptions, vr.ID)
+		return nil
+	} else {
+		return fmt.Errorf("cannot delete voucher redemption %d - not present", vr.ID)
+	}
+}
+
+// --- invoices ---
+
+func (r *InMemoryRepository) GetInvoicesByAttendeeId(ctx context.Context, attendeeId uint) ([]*entity.Invoice, error) {
+	result := make([]*entity.Invoice, 0)
+	for _, i := range r.invoices {
+		if i.AttendeeId == attendeeId {
+			copiedInvoice := *i
+			result = append(result, &copiedInvoice)
+		}
+	}
+	sort.Slice(result, func(i int, j int) bool {
+		return result[i].Number < result[j].Number
+	})
+	return result, nil
+}
+
+func (r *InMemoryRepository) AddInvoice(ctx context.Context, i *entity.Invoice) (uint, error) {
+	newId := uint(atomic.AddUint32(&r.invoiceIdSequence, 1))
+	i.ID = newId
+	i.Number = uint(atomic.AddUint32(&r.invoiceNumberSequence, 1))
+	i.CreatedAt = r.Now()
+	i.UpdatedAt = i.CreatedAt
+
+	// copy the invoice, so later modifications won't also modify it in the simulated db
+	copiedInvoice := *i
+	r.invoices[newId] = &copiedInvoice
+	return newId, nil
+}
+
 // --- additional info ---
 
 func (r *InMemoryRepository) GetAllAdditionalInfoOrEmptyMap(ctx context.Context, attendeeId uint) map[string]*entity.AdditionalInfo {
@@ -497,19 +1078,278 @@ func (r *InMemoryRepository) GetCount(ctx context.Context, area string, name str
 	return &result, nil
 }
 
+func (r *InMemoryRepository) GetCountForUpdate(ctx context.Context, area string, name string) (*entity.Count, error) {
+	// nothing to lock, the in-memory database is not used concurrently
+	return r.GetCount(ctx, area, name)
+}
+
 func (r *InMemoryRepository) countPK(area string, name string) string {
 	return fmt.Sprintf("area=%s|name=%s", area, name)
 }
 
+// --- waiting list ---
+
+func (r *InMemoryRepository) GetWaitlistForPackage(ctx context.Context, pkg string) ([]*entity.WaitlistEntry, error) {
+	return r.selectWaitlistEntries(func(e *entity.WaitlistEntry) bool {
+		return e.Package == pkg
+	}), nil
+}
+
+func (r *InMemoryRepository) GetWaitlistEntriesByAttendeeId(ctx context.Context, attendeeId uint) ([]*entity.WaitlistEntry, error) {
+	return r.selectWaitlistEntries(func(e *entity.WaitlistEntry) bool {
+		return e.AttendeeId == attendeeId
+	}), nil
+}
+
+func (r *InMemoryRepository) selectWaitlistEntries(matches func(e *entity.WaitlistEntry) bool) []*entity.WaitlistEntry {
+	result := make([]*entity.WaitlistEntry, 0)
+	for _, e := range r.waitlist {
+		if matches(e) {
+			copiedEntry := *e
+			result = append(result, &copiedEntry)
+		}
+	}
+	sort.Slice(result, func(i int, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+func (r *InMemoryRepository) AddWaitlistEntry(ctx context.Context, e *entity.WaitlistEntry) (uint, error) {
+	for _, existing := range r.waitlist {
+		if existing.Package == e.Package && existing.AttendeeId == e.AttendeeId {
+			return 0, errors.New("unique constraint violated, attendee is already on the waiting list for this package")
+		}
+	}
+
+	newId := uint(atomic.AddUint32(&r.idSequence, 1))
+	e.ID = newId
+	e.CreatedAt = r.Now()
+	e.UpdatedAt = e.CreatedAt
+
+	// copy the entry, so later modifications won't also modify it in the simulated db
+	copiedEntry := *e
+	r.waitlist[newId] = &copiedEntry
+	return newId, nil
+}
+
+func (r *InMemoryRepository) UpdateWaitlistEntry(ctx context.Context, e *entity.WaitlistEntry) error {
+	if _, ok := r.waitlist[e.ID]; ok {
+		e.UpdatedAt = r.Now()
+		// copy the entry, so later modifications won't also modify it in the simulated db
+		copiedEntry := *e
+		r.waitlist[e.ID] = &copiedEntry
+		return nil
+	} else {
+		return fmt.Errorf("cannot update waiting list entry %d - not present", e.ID)
+	}
+}
+
+func (r *InMemoryRepository) DeleteWaitlistEntry(ctx context.Context, e *entity.WaitlistEntry) error {
+	if _, ok := r.waitlist[e.ID]; ok {
+		delete(r.waitlist, e.ID)
+		return nil
+	} else {
+		return fmt.Errorf("cannot delete waiting list entry %d - not present", e.ID)
+	}
+}
+
+// --- mail outbox ---
+
+func (r *InMemoryRepository) AddOutboxMail(ctx context.Context, m *entity.OutboxMail) (uint, error) {
+	newId := uint(atomic.AddUint32(&r.idSequence, 1))
+	m.ID = newId
+	m.CreatedAt = r.Now()
+	m.UpdatedAt = m.CreatedAt
+
+	// copy the mail, so later modifications won't also modify it in the simulated db
+	copiedMail := *m
+	r.outbox[newId] = &copiedMail
+	return newId, nil
+}
+
+func (r *InMemoryRepository) UpdateOutboxMail(ctx context.Context, m *entity.OutboxMail) error {
+	if _, ok := r.outbox[m.ID]; ok {
+		m.UpdatedAt = r.Now()
+		// copy the mail, so later modifications won't also modify it in the simulated db
+		copiedMail := *m
+		r.outbox[m.ID] = &copiedMail
+		return nil
+	} else {
+		return fmt.Errorf("cannot update outbox mail %d - not present", m.ID)
+	}
+}
+
+func (r *InMemoryRepository) GetOutboxMailById(ctx context.Context, id uint) (*entity.OutboxMail, error) {
+	m, ok := r.outbox[id]
+	if !ok {
+		return &entity.OutboxMail{}, gorm.ErrRecordNotFound
+	}
+	copiedMail := *m
+	return &copiedMail, nil
+}
+
+func (r *InMemoryRepository) GetOutboxMailsByState(ctx context.Context, state string) ([]*entity.OutboxMail, error) {
+	return r.selectOutboxMails(func(m *entity.OutboxMail) bool {
+		return m.State == state
+	}, 0), nil
+}
+
+func (r *InMemoryRepository) GetDueOutboxMails(ctx context.Context, now time.Time, limit int) ([]*entity.OutboxMail, error) {
+	return r.selectOutboxMails(func(m *entity.OutboxMail) bool {
+		return (m.State == entity.OutboxStatePending || m.State == entity.OutboxStateSending) && !m.NextAttempt.After(now)
+	}, limit), nil
+}
+
+func (r *InMemoryRepository) ClaimOutboxMail(ctx context.Context, m *entity.OutboxMail, now time.Time, leaseUntil time.Time) (bool, error) {
+	current, ok := r.outbox[m.ID]
+	if !ok || current.Attempts != m.Attempts {
+		return false, nil
+	}
+	if current.State == entity.OutboxStateSent || current.State == entity.OutboxStateSending && current.NextAttempt.After(now) {
+		return false, nil
+	}
+
+	current.State = entity.OutboxStateSending
+	current.NextAttempt = leaseUntil
+	current.UpdatedAt = r.Now()
+	m.State = current.State
+	m.NextAttempt = current.NextAttempt
+	return true, nil
+}
+
+func (r *InMemoryRepository) selectOutboxMails(matches func(m *entity.OutboxMail) bool, limit int) []*entity.OutboxMail {
+	result := make([]*entity.OutboxMail, 0)
+	for _, m := range r.outbox {
+		if matches(m) {
+			copiedMail := *m
+			result = append(result, &copiedMail)
+		}
+	}
+	sort.Slice(result, func(i int, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+func (r *InMemoryRepository) DeleteOutboxMail(ctx context.Context, m *entity.OutboxMail) error {
+	if _, ok := r.outbox[m.ID]; ok {
+		delete(r.outbox, m.ID)
+		return nil
+	} else {
+		return fmt.Errorf("cannot delete outbox mail %d - not present", m.ID)
+	}
+}
+
+// --- webhook deliveries ---
+
+func (r *InMemoryRepository) AddWebhookDelivery(ctx context.Context, d *entity.WebhookDelivery) (uint, error) {
+	newId := uint(atomic.AddUint32(&r.webhookIdSequence, 1))
+	d.ID = newId
+	d.CreatedAt = r.Now()
+	d.UpdatedAt = d.CreatedAt
+
+	// copy the delivery, so later modifications won't also modify it in the simulated db
+	copiedDelivery := *d
+	r.webhooks[newId] = &copiedDelivery
+	return newId, nil
+}
+
+func (r *InMemoryRepository) UpdateWebhookDelivery(ctx context.Context, d *entity.WebhookDelivery) error {
+	if _, ok := r.webhooks[d.ID]; ok {
+		d.UpdatedAt = r.Now()
+		// copy the delivery, so later modifications won't also modify it in the simulated db
+		copiedDelivery := *d
+		r.webhooks[d.ID] = &copiedDelivery
+		return nil
+	} else {
+		return fmt.Errorf("cannot update webhook delivery %d - not present", d.ID)
+	}
+}
+
+func (r *InMemoryRepository) GetPendingWebhookDeliveries(ctx context.Context, subscriber string, limit int) ([]*entity.WebhookDelivery, error) {
+	result := r.selectWebhookDeliveries(func(d *entity.WebhookDelivery) bool {
+		return d.Subscriber == subscriber && d.State == entity.WebhookStatePending
+	})
+	sort.Slice(result, func(i int, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return limitWebhookDeliveries(result, limit), nil
+}
+
+func (r *InMemoryRepository) LockWebhookQueue(ctx context.Context, subscriber string, holder string, now time.Time, lockedUntil time.Time) (bool, error) {
+	q, ok := r.webhookQueues[subscriber]
+	if ok && q.LockedUntil.After(now) && q.LockHolder != holder {
+		return false, nil
+	}
+	r.webhookQueues[subscriber] = &entity.WebhookQueue{Subscriber: subscriber, LockedUntil: lockedUntil, LockHolder: holder}
+	return true, nil
+}
+
+func (r *InMemoryRepository) UnlockWebhookQueue(ctx context.Context, subscriber string, holder string) error {
+	if q, ok := r.webhookQueues[subscriber]; ok && q.LockHolder == holder {
+		delete(r.webhookQueues, subscriber)
+	}
+	return nil
+}
+
+func (r *InMemoryRepository) GetWebhookDeliveryLog(ctx context.Context, subscriber string, state string, attendeeId uint, limit int) ([]*entity.WebhookDelivery, error) {
+	result := r.selectWebhookDeliveries(func(d *entity.WebhookDelivery) bool {
+		return (subscriber == "" || d.Subscriber == subscriber) &&
+			(state == "" || d.State == state) &&
+			(attendeeId == 0 || d.AttendeeId == attendeeId)
+	})
+	sort.Slice(result, func(i int, j int) bool {
+		return result[i].ID > result[j].ID
+	})
+	return limitWebhookDeliveries(result, limit), nil
+}
+
+func (r *InMemoryRepository) selectWebhookDeliveries(matches func(d *entity.WebhookDelivery) bool) []*entity.WebhookDelivery {
+	result := make([]*entity.WebhookDelivery, 0)
+	for _, d := range r.webhooks {
+		if matches(d) {
+			copiedDelivery := *d
+			result = append(result, &copiedDelivery)
+		}
+	}
+	return result
+}
+
+func limitWebhookDeliveries(deliveries []*entity.WebhookDelivery, limit int) []*entity.WebhookDelivery {
+	if limit > 0 && len(deliveries) > limit {
+		return deliveries[:limit]
+	}
+	return deliveries
+}
+
 // --- history ---
 
 func (r *InMemoryRepository) RecordHistory(ctx context.Context, h *entity.History) error {
 	newId := uint(atomic.AddUint32(&r.idSequence, 1))
 	h.ID = newId
+	h.CreatedAt = r.Now()
 	r.history[newId] = h
 	return nil
 }
 
+func (r *InMemoryRepository) GetHistoryByEntity(ctx context.Context, entityName string, entityId uint) ([]*entity.History, error) {
+	result := make([]*entity.History, 0)
+	for _, h := range r.history {
+		if h.Entity == entityName && h.EntityId == entityId {
+			copiedEntry := *h
+			result = append(result, &copiedEntry)
+		}
+	}
+	sort.Slice(result, func(i int, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
 // only offered for testing, and only on the in memory db
 func (r *InMemoryRepository) GetHistoryById(ctx context.Context, id uint) (*entity.History, error) {
 	if h, ok := r.history[id]; ok {
@@ -2,9 +2,13 @@ package inmemorydb
 
 import (
 	"context"
+	"errors"
 	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database/dbrepo"
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 	"os"
 	"testing"
 	"time"
@@ -15,7 +19,7 @@ var (
 )
 
 func TestMain(m *testing.M) {
-	cut = &InMemoryRepository{}
+	cut = &InMemoryRepository{Now: time.Now}
 	cut.Open()
 	code := m.Run()
 	cut.Close()
@@ -52,6 +56,12 @@ func TestGetAttendeeNotFound(t *testing.T) {
 	require.Equal(t, uint(0), att.ID, "ID should still be at its initial value")
 }
 
+func TestGetSavedSearchNotFound(t *testing.T) {
+	docs.Description("retrieving a nonexistent saved search should fail like it does in the sql databases")
+	_, err := cut.GetSavedSearchByName(context.TODO(), "does-not-exist")
+	require.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
 func TestUpdateAttendee(t *testing.T) {
 	docs.Description("it should be possible to update an attendee")
 	att := &entity.Attendee{}
@@ -77,3 +87,166 @@ func TestUpdateAttendeeNotFound(t *testing.T) {
 	require.Equal(t, "cannot update attendee 0 - not present", err.Error(), "unexpected error message")
 	require.Equal(t, uint(0), att.ID, "ID should still be at its initial value")
 }
+
+func TestTransactionCommit(t *testing.T) {
+	docs.Description("changes made in a successful transaction should be kept")
+	att := &entity.Attendee{Nickname: "committed"}
+	var newId uint
+	err := cut.WithTransaction(context.TODO(), func(repo dbrepo.Repository) error {
+		var err error
+		newId, err = repo.AddAttendee(context.TODO(), att)
+		return err
+	})
+	require.Nil(t, err, "unexpected error during transaction")
+
+	att2, err := cut.GetAttendeeById(context.TODO(), newId)
+	require.Nil(t, err, "attendee not found after commit")
+	require.Equal(t, "committed", att2.Nickname)
+}
+
+func TestTransactionRollback(t *testing.T) {
+	docs.Description("changes made in a failed transaction should be rolled back")
+	att := &entity.Attendee{Nickname: "original"}
+	existingId, err := cut.AddAttendee(context.TODO(), att)
+	require.Nil(t, err, "unexpected error during add")
+
+	var newId uint
+	err = cut.WithTransaction(context.TODO(), func(repo dbrepo.Repository) error {
+		newId, _ = repo.AddAttendee(context.TODO(), &entity.Attendee{Nickname: "rolled back"})
+		changed := *att
+		changed.Nickname = "changed"
+		_ = repo.UpdateAttendee(context.TODO(), &changed)
+		_ = repo.SoftDeleteAttendeeById(context.TODO(), existingId)
+		return errors.New("some error")
+	})
+	require.NotNil(t, err, "no error returned from failed transaction")
+	require.Equal(t, "some error", err.Error())
+
+	_, err = cut.GetAttendeeById(context.TODO(), newId)
+	require.NotNil(t, err, "attendee added in failed transaction should be gone")
+
+	att2, err := cut.GetAttendeeById(context.TODO(), existingId)
+	require.Nil(t, err, "unexpected error during get")
+	require.Equal(t, "original", att2.Nickname, "update in failed transaction should be rolled back")
+	require.False(t, att2.DeletedAt.Valid, "soft delete in failed transaction should be rolled back")
+}
+
+func TestFindAttendeesAfterCursor(t *testing.T) {
+	docs.Description("search results can be walked page by page, even if sort values are not unique")
+	cut2 := &InMemoryRepository{Now: time.Now}
+	cut2.Open()
+	defer cut2.Close()
+	for _, nickname := range []string{"Zebra", "Aardvark", "Lion", "Aardvark", "Lion"} {
+		_, err := cut2.AddAttendee(context.TODO(), &entity.Attendee{Nickname: nickname})
+		require.Nil(t, err)
+	}
+
+	criteria := &attendee.AttendeeSearchCriteria{
+		MatchAny:   []attendee.AttendeeSearchSingleCriterion{{}},
+		SortBy:     "nickname",
+		SortOrder:  "descending",
+		NumResults: 2,
+	}
+	ids := make([]uint, 0)
+	for {
+		page, err := cut2.FindAttendees(context.TODO(), criteria)
+		require.Nil(t, err)
+		if len(page) == 0 {
+			break
+		}
+		for _, a := range page {
+			ids = append(ids, a.ID)
+		}
+		criteria.Cursor = dbrepo.NewSearchCursor(criteria, page[len(page)-1])
+	}
+	require.Equal(t, []uint{1, 5, 3, 4, 2}, ids)
+
+	count, err := cut2.CountAttendees(context.TODO(), criteria)
+	require.Nil(t, err)
+	require.Equal(t, int64(5), count)
+
+	criteria.SortOrder = ""
+	_, err = cut2.FindAttendees(context.TODO(), criteria)
+	require.Equal(t, dbrepo.SearchCursorMismatchError, err)
+}
+
+func TestFindAttendeesFuzzy(t *testing.T) {
+	docs.Description("fuzzy searches are sorted by relevance and can be walked page by page")
+	cut2 := &InMemoryRepository{Now: time.Now}
+	cut2.Open()
+	defer cut2.Close()
+	for _, lastName := range []string{"Mayr", "Müller", "Schulz", "Muller", "Mueller"} {
+		_, err := cut2.AddAttendee(context.TODO(), &entity.Attendee{Nickname: "Cheetah", LastName: lastName})
+		require.Nil(t, err)
+	}
+
+	criteria := &attendee.AttendeeSearchCriteria{
+		MatchAny:   []attendee.AttendeeSearchSingleCriterion{{Fuzzy: "muller"}, {Fuzzy: "meier"}},
+		SortBy:     "relevance",
+		NumResults: 2,
+	}
+	ids := make([]uint, 0)
+	relevances := make([]float64, 0)
+	for {
+		page, err := cut2.FindAttendees(context.TODO(), criteria)
+		require.Nil(t, err)
+		if len(page) == 0 {
+			break
+		}
+		for _, a := range page {
+			ids = append(ids, a.ID)
+			relevances = append(relevances, a.Relevance)
+		}
+		criteria.Cursor = dbrepo.NewSearchCursor(criteria, page[len(page)-1])
+	}
+	require.Equal(t, []uint{2, 4, 5, 1}, ids)
+	require.Equal(t, []float64{1, 1, 0.8, 0.6}, relevances)
+
+	count, err := cut2.CountAttendees(context.TODO(), criteria)
+	require.Nil(t, err)
+	require.Equal(t, int64(4), count)
+}
+
+func TestClaimOutboxMail(t *testing.T) {
+	docs.Description("an outbox mail can only be claimed once, until the lease runs out")
+	now := time.Now()
+	mail := &entity.OutboxMail{State: entity.OutboxStatePending, NextAttempt: now}
+	_, err := cut.AddOutboxMail(context.TODO(), mail)
+	require.Nil(t, err)
+	other := *mail
+
+	claimed, err := cut.ClaimOutboxMail(context.TODO(), mail, now, now.Add(time.Minute))
+	require.Nil(t, err)
+	require.True(t, claimed)
+	require.Equal(t, entity.OutboxStateSending, mail.State)
+
+	claimed, err = cut.ClaimOutboxMail(context.TODO(), &other, now, now.Add(time.Minute))
+	require.Nil(t, err)
+	require.False(t, claimed)
+
+	claimed, err = cut.ClaimOutboxMail(context.TODO(), &other, now.Add(time.Minute), now.Add(2*time.Minute))
+	require.Nil(t, err)
+	require.True(t, claimed)
+}
+
+func TestLockWebhookQueue(t *testing.T) {
+	docs.Description("the queue of a webhook subscriber can only be held by one dispatcher at a time")
+	now := time.Now()
+
+	locked, err := cut.LockWebhookQueue(context.TODO(), "sub", "first", now, now.Add(time.Minute))
+	require.Nil(t, err)
+	require.True(t, locked)
+
+	locked, err = cut.LockWebhookQueue(context.TODO(), "sub", "second", now, now.Add(time.Minute))
+	require.Nil(t, err)
+	require.False(t, locked)
+
+	locked, err = cut.LockWebhookQueue(context.TODO(), "sub", "first", now, now.Add(2*time.Minute))
+	require.Nil(t, err)
+	require.True(t, locked)
+
+	require.Nil(t, cut.UnlockWebhookQueue(context.TODO(), "sub", "first"))
+	locked, err = cut.LockWebhookQueue(context.TODO(), "sub", "second", now, now.Add(time.Minute))
+	require.Nil(t, err)
+	require.True(t, locked)
+}
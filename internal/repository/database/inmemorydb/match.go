@@ -50,7 +50,9 @@ func (r *InMemoryRepository) matches(cond *attendee.AttendeeSearchSingleCriterio
 		matchesAddInfoPresence(cond.AddInfo, addInf) &&
 		matchesOverdue(cond.AddInfo, a.CacheDueDate, r.Now().Format(config.IsoDateFormat), st.Status) &&
 		matchesIsoDateRange(cond.BirthdayFrom, cond.BirthdayTo, a.Birthday) &&
-		matchesIdentitySubjects(cond.IdentitySubjects, a.Identity)
+		matchesIdentitySubjects(cond.IdentitySubjects, a.Identity) &&
+		matchesGroups(cond.GroupIds, r.groupIdOf(a.ID)) &&
+		matchesVoucherCodes(cond.VoucherCodes, r.voucherCodesOf(a.ID))
 }
 
 func matchesUintSliceOrEmpty(cond []uint, value uint) bool {
@@ -192,3 +194,19 @@ func matchesIdentitySubjects(cond []string, value string) bool {
 	}
 	return len(cond) == 0 || slices.Contains(cond, value)
 }
+
+func matchesGroups(cond []uint, groupId uint) bool {
+	return len(cond) == 0 || (groupId != 0 && slices.Contains(cond, groupId))
+}
+
+func matchesVoucherCodes(cond []string, codes []string) bool {
+	if len(cond) == 0 {
+		return true
+	}
+	for _, c := range cond {
+		if slices.Contains(codes, strings.ToUpper(c)) {
+			return true
+		}
+	}
+	return false
+}
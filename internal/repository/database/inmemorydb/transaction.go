@@ -0,0 +1,115 @@
+package inmemorydb
+
+import (
+	"context"
+
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database/dbrepo"
+)
+
+// snapshot holds deep copies of all simulated tables, so a transaction can be rolled back.
+//
+// The id sequence is deliberately not rolled back, just like auto increment values in a real database.
+type snapshot struct {
+	addInfo       map[uint]map[string]*entity.AdditionalInfo
+	adminInfo     map[uint]*entity.AdminInfo
+	attendees     map[uint]*entity.Attendee
+	bans          map[uint]*entity.Ban
+	statusChanges map[uint][]entity.StatusChange
+	history       map[uint]*entity.History
+	counts        map[string]entity.Count
+	waitlist      map[uint]*entity.WaitlistEntry
+	outbox        map[uint]*entity.OutboxMail
+	webhooks      map[uint]*entity.WebhookDelivery
+	savedSearches map[uint]*entity.SavedSearch
+	transfers     map[uint]*entity.Transfer
+	groups        map[uint]*entity.Group
+	groupMembers  map[uint]*entity.GroupMember
+	packagePrices map[uint]*entity.PackagePrice
+	vouchers      map[uint]*entity.Voucher
+	redemptions   map[uint]*entity.VoucherRedemption
+	invoices      map[uint]*entity.Invoice
+}
+
+// WithTransaction takes a copy of the simulated database before running f, and restores it if f fails or panics.
+//
+// Note that the in-memory database is not safe for concurrent use, so this does not isolate
+// concurrent transactions from each other.
+func (r *InMemoryRepository) WithTransaction(ctx context.Context, f func(repo dbrepo.Repository) error) error {
+	before := r.takeSnapshot()
+	committed := false
+	defer func() {
+		if !committed {
+			r.restoreSnapshot(before)
+		}
+	}()
+
+	if err := f(r); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+func (r *InMemoryRepository) takeSnapshot() snapshot {
+	s := snapshot{
+		addInfo:       make(map[uint]map[string]*entity.AdditionalInfo),
+		adminInfo:     copyPointerMap(r.adminInfo),
+		attendees:     copyPointerMap(r.attendees),
+		bans:          copyPointerMap(r.bans),
+		statusChanges: make(map[uint][]entity.StatusChange),
+		history:       copyPointerMap(r.history),
+		counts:        make(map[string]entity.Count),
+		waitlist:      copyPointerMap(r.waitlist),
+		outbox:        copyPointerMap(r.outbox),
+		webhooks:      copyPointerMap(r.webhooks),
+		savedSearches: copyPointerMap(r.savedSearches),
+		transfers:     copyPointerMap(r.transfers),
+		groups:        copyPointerMap(r.groups),
+		groupMembers:  copyPointerMap(r.groupMembers),
+		packagePrices: copyPointerMap(r.packagePrices),
+		vouchers:      copyPointerMap(r.vouchers),
+		redemptions:   copyPointerMap(r.redemptions),
+		invoices:      copyPointerMap(r.invoices),
+	}
+	for id, areas := range r.addInfo {
+		s.addInfo[id] = copyPointerMap(areas)
+	}
+	for id, changes := range r.statusChanges {
+		s.statusChanges[id] = append([]entity.StatusChange{}, changes...)
+	}
+	for key, count := range r.counts {
+		s.counts[key] = count
+	}
+	return s
+}
+
+func (r *InMemoryRepository) restoreSnapshot(s snapshot) {
+	r.addInfo = s.addInfo
+	r.adminInfo = s.adminInfo
+	r.attendees = s.attendees
+	r.bans = s.bans
+	r.statusChanges = s.statusChanges
+	r.history = s.history
+	r.counts = s.counts
+	r.waitlist = s.waitlist
+	r.outbox = s.outbox
+	r.webhooks = s.webhooks
+	r.savedSearches = s.savedSearches
+	r.transfers = s.transfers
+	r.groups = s.groups
+	r.groupMembers = s.groupMembers
+	r.packagePrices = s.packagePrices
+	r.vouchers = s.vouchers
+	r.redemptions = s.redemptions
+	r.invoices = s.invoices
+}
+
+func copyPointerMap[K comparable, V any](m map[K]*V) map[K]*V {
+	result := make(map[K]*V, len(m))
+	for k, v := range m {
+		copied := *v
+		result[k] = &copied
+	}
+	return result
+}
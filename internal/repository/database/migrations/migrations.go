@@ -0,0 +1,300 @@
+// Package migrations applies the versioned schema migrations for the sql databases.
+//
+// Each database type has its own subdirectory with numbered up and down scripts, named
+// <version>_<name>.up.sql and <version>_<name>.down.sql. Versions start at 1 and must not have gaps.
+// Applied migrations are recorded in the att_schema_migrations table, which is prefixed like all other
+// tables because the registration services may share a database.
+//
+// Scripts are split into statements at semicolons that end a line, so do not put those inside string literals.
+package migrations
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database/dbrepo"
+	"gorm.io/gorm"
+)
+
+//go:embed mysql postgres sqlite
+var scripts embed.FS
+
+const bookkeepingScript = "schema_migrations.sql"
+
+var ErrSchemaAhead = errors.New("database schema is ahead of this version of the service")
+
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+type appliedMigration struct {
+	Version   uint
+	Name      string
+	AppliedAt time.Time
+}
+
+// Load returns the migrations for a database type, ordered by version.
+func Load(dbType config.DatabaseType) ([]Migration, error) {
+	dir := string(dbType)
+	entries, err := fs.ReadDir(scripts, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for database type %s: %w", dbType, err)
+	}
+
+	byVersion := make(map[uint]*Migration)
+	for _, entry := range entries {
+		filename := entry.Name()
+		if filename == bookkeepingScript {
+			continue
+		}
+
+		var direction string
+		base := ""
+		if strings.HasSuffix(filename, ".up.sql") {
+			direction = "up"
+			base = strings.TrimSuffix(filename, ".up.sql")
+		} else if strings.HasSuffix(filename, ".down.sql") {
+			direction = "down"
+			base = strings.TrimSuffix(filename, ".down.sql")
+		} else {
+			return nil, fmt.Errorf("unexpected file %s in %s migrations", filename, dbType)
+		}
+
+		versionStr, name, ok := strings.Cut(base, "_")
+		version, err := strconv.ParseUint(versionStr, 10, 32)
+		if !ok || err != nil || version == 0 {
+			return nil, fmt.Errorf("invalid migration file name %s in %s migrations", filename, dbType)
+		}
+
+		content, err := scripts.ReadFile(path.Join(dir, filename))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[uint(version)]
+		if !ok {
+			m = &Migration{Version: uint(version), Name: name}
+			byVersion[uint(version)] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration %d has conflicting names %s and %s in %s migrations", version, m.Name, name, dbType)
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	result := make([]Migration, 0, len(byVersion))
+	for version := uint(1); version <= uint(len(byVersion)); version++ {
+		m, ok := byVersion[version]
+		if !ok {
+			return nil, fmt.Errorf("migration %d is missing in %s migrations", version, dbType)
+		}
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d needs both an up and a down script in %s migrations", version, dbType)
+		}
+		result = append(result, *m)
+	}
+	return result, nil
+}
+
+type Runner struct {
+	db         *gorm.DB
+	dbType     config.DatabaseType
+	migrations []Migration
+	Now        func() time.Time
+}
+
+func New(db *gorm.DB, dbType config.DatabaseType) (*Runner, error) {
+	migrations, err := Load(dbType)
+	if err != nil {
+		return nil, err
+	}
+	return &Runner{
+		db:         db,
+		dbType:     dbType,
+		migrations: migrations,
+		Now:        time.Now,
+	}, nil
+}
+
+// Up applies all pending migrations in order of version.
+//
+// Each migration is recorded in the same transaction as its statements, but note that mysql implicitly
+// commits after each schema change, so a failed migration may have to be cleaned up manually there.
+func (r *Runner) Up() error {
+	applied, err := r.applied()
+	if err != nil {
+		return err
+	}
+	if err := r.checkNotAhead(applied); err != nil {
+		return err
+	}
+
+	for _, m := range r.migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		aulogging.Logger.NoCtx().Info().Printf("applying %s migration %04d %s", r.dbType, m.Version, m.Name)
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			if err := execScript(tx, m.Up); err != nil {
+				return err
+			}
+			return tx.Exec("INSERT INTO att_schema_migrations (version, name, applied_at) VALUES (?, ?, ?)", m.Version, m.Name, r.Now()).Error
+		})
+		if err != nil {
+			aulogging.Logger.NoCtx().Error().WithErr(err).Printf("failed to apply %s migration %04d %s: %s", r.dbType, m.Version, m.Name, err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+// DownTo reverts all applied migrations with a version greater than the given version, newest first.
+//
+// Down scripts usually drop data, so make a backup first.
+func (r *Runner) DownTo(version uint) error {
+	applied, err := r.applied()
+	if err != nil {
+		return err
+	}
+	if err := r.checkNotAhead(applied); err != nil {
+		return err
+	}
+
+	for i := len(r.migrations) - 1; i >= 0; i-- {
+		m := r.migrations[i]
+		if m.Version <= version {
+			break
+		}
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+
+		aulogging.Logger.NoCtx().Info().Printf("reverting %s migration %04d %s", r.dbType, m.Version, m.Name)
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			if err := execScript(tx, m.Down); err != nil {
+				return err
+			}
+			return tx.Exec("DELETE FROM att_schema_migrations WHERE version = ?", m.Version).Error
+		})
+		if err != nil {
+			aulogging.Logger.NoCtx().Error().WithErr(err).Printf("failed to revert %s migration %04d %s: %s", r.dbType, m.Version, m.Name, err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+// Status lists all migrations known to this version of the service, plus any unknown ones that have been
+// applied to the database, ordered by version.
+func (r *Runner) Status() ([]dbrepo.MigrationStatus, error) {
+	applied, err := r.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dbrepo.MigrationStatus, 0)
+	for _, m := range r.migrations {
+		status := dbrepo.MigrationStatus{
+			Version: m.Version,
+			Name:    m.Name,
+		}
+		if a, ok := applied[m.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = a.AppliedAt
+			delete(applied, m.Version)
+		}
+		result = append(result, status)
+	}
+	for _, a := range applied {
+		result = append(result, dbrepo.MigrationStatus{
+			Version:   a.Version,
+			Name:      a.Name,
+			Applied:   true,
+			AppliedAt: a.AppliedAt,
+			Unknown:   true,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+	return result, nil
+}
+
+func (r *Runner) applied() (map[uint]appliedMigration, error) {
+	bookkeeping, err := scripts.ReadFile(path.Join(string(r.dbType), bookkeepingScript))
+	if err != nil {
+		return nil, err
+	}
+	if err := execScript(r.db, string(bookkeeping)); err != nil {
+		aulogging.Logger.NoCtx().Error().WithErr(err).Printf("failed to set up %s migrations table: %s", r.dbType, err.Error())
+		return nil, err
+	}
+
+	rows := make([]appliedMigration, 0)
+	if err := r.db.Table("att_schema_migrations").Order("version").Find(&rows).Error; err != nil {
+		aulogging.Logger.NoCtx().Error().WithErr(err).Printf("failed to read %s migrations table: %s", r.dbType, err.Error())
+		return nil, err
+	}
+
+	result := make(map[uint]appliedMigration)
+	for _, row := range rows {
+		result[row.Version] = row
+	}
+	return result, nil
+}
+
+func (r *Runner) checkNotAhead(applied map[uint]appliedMigration) error {
+	for version := range applied {
+		if version > uint(len(r.migrations)) {
+			return ErrSchemaAhead
+		}
+	}
+	return nil
+}
+
+func execScript(db *gorm.DB, script string) error {
+	for _, statement := range statements(script) {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statements splits a script into its statements, leaving out comment lines.
+func statements(script string) []string {
+	result := make([]string, 0)
+	current := make([]string, 0)
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current = append(current, line)
+		if strings.HasSuffix(trimmed, ";") {
+			result = append(result, strings.TrimSuffix(strings.TrimSpace(strings.Join(current, "\n")), ";"))
+			current = current[:0]
+		}
+	}
+	if len(current) > 0 {
+		result = append(result, strings.TrimSpace(strings.Join(current, "\n")))
+	}
+	return result
+}
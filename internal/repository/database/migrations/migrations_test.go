@@ -0,0 +1,247 @@
+package migrations
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+var tstDatabaseTypes = []config.DatabaseType{config.Mysql, config.Postgres, config.Sqlite}
+
+var tstEntities = []interface{}{
+	&entity.AdditionalInfo{},
+	&entity.AdminInfo{},
+	&entity.Attendee{},
+	&entity.Ban{},
+	&entity.History{},
+	&entity.StatusChange{},
+	&entity.Count{},
+	&entity.WaitlistEntry{},
+	&entity.OutboxMail{},
+	&entity.WebhookDelivery{},
+	&entity.WebhookQueue{},
+	&entity.SavedSearch{},
+	&entity.Transfer{},
+	&entity.Group{},
+	&entity.GroupMember{},
+	&entity.PackagePrice{},
+	&entity.Voucher{},
+	&entity.VoucherRedemption{},
+	&entity.Invoice{},
+	&entity.InvoiceNumber{},
+}
+
+// tstColumnsAddedLater are the columns that later migrations add to existing tables,
+// so databases set up by gorm AutoMigrate never had them
+var tstColumnsAddedLater = map[interface{}][]string{
+	&entity.Attendee{}: {"currency"},
+	&entity.Voucher{}:  {"currency"},
+}
+
+var tstNamingStrategy = schema.NamingStrategy{TablePrefix: "att_"}
+
+func tstOpenSqlite(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		NamingStrategy: tstNamingStrategy,
+		Logger:         logger.Default.LogMode(logger.Silent),
+	})
+	require.Nil(t, err)
+	sqlDb, err := db.DB()
+	require.Nil(t, err)
+	sqlDb.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		_ = sqlDb.Close()
+	})
+	return db
+}
+
+func tstRunner(t *testing.T) *Runner {
+	runner, err := New(tstOpenSqlite(t), config.Sqlite)
+	require.Nil(t, err)
+	runner.Now = func() time.Time {
+		return time.Date(2022, 12, 8, 0, 0, 0, 0, time.UTC)
+	}
+	return runner
+}
+
+func TestLoad_SameMigrationsForAllDatabaseTypes(t *testing.T) {
+	docs.Description("all database types have the same migrations, each with an up and a down script")
+	expected, err := Load(config.Sqlite)
+	require.Nil(t, err)
+	require.NotEmpty(t, expected)
+
+	for _, dbType := range tstDatabaseTypes {
+		actual, err := Load(dbType)
+		require.Nil(t, err)
+		require.Equal(t, len(expected), len(actual), dbType)
+		for i := range expected {
+			require.Equal(t, uint(i+1), actual[i].Version, dbType)
+			require.Equal(t, expected[i].Name, actual[i].Name, dbType)
+			require.NotEmpty(t, statements(actual[i].Up), dbType)
+			require.NotEmpty(t, statements(actual[i].Down), dbType)
+		}
+	}
+}
+
+func TestLoad_UnknownDatabaseType(t *testing.T) {
+	docs.Description("loading migrations for a database type without scripts fails")
+	_, err := Load(config.Inmemory)
+	require.NotNil(t, err)
+}
+
+func TestScripts_CoverAllEntityColumns(t *testing.T) {
+	docs.Description("every entity column appears in the migration scripts of every database type, so the scripts do not drift from the entities")
+	for _, dbType := range tstDatabaseTypes {
+		migrations, err := Load(dbType)
+		require.Nil(t, err)
+		combined := ""
+		for _, m := range migrations {
+			combined += m.Up
+		}
+
+		for _, e := range tstEntities {
+			s, err := schema.Parse(e, &sync.Map{}, tstNamingStrategy)
+			require.Nil(t, err)
+			for _, field := range s.Fields {
+				if field.DBName == "" {
+					continue
+				}
+				pattern := regexp.MustCompile("(?s)(CREATE TABLE|ALTER TABLE)[^;]*\\b" + s.Table + "\\b[^;]*\\b" + field.DBName + "\\b")
+				require.True(t, pattern.MatchString(combined), "%s: column %s.%s not found in migrations", dbType, s.Table, field.DBName)
+			}
+		}
+	}
+}
+
+func TestRunner_UpCreatesEntitySchema(t *testing.T) {
+	docs.Description("applying all migrations creates tables and columns for all entities, and records the migrations")
+	runner := tstRunner(t)
+
+	require.Nil(t, runner.Up())
+
+	for _, e := range tstEntities {
+		s, err := schema.Parse(e, &sync.Map{}, tstNamingStrategy)
+		require.Nil(t, err)
+		require.True(t, runner.db.Migrator().HasTable(s.Table), s.Table)
+		for _, field := range s.Fields {
+			if field.DBName != "" {
+				require.True(t, runner.db.Migrator().HasColumn(e, field.DBName), "%s.%s", s.Table, field.DBName)
+			}
+		}
+		for _, index := range s.ParseIndexes() {
+			require.True(t, runner.db.Migrator().HasIndex(e, index.Name), "%s index %s", s.Table, index.Name)
+		}
+	}
+
+	status, err := runner.Status()
+	require.Nil(t, err)
+	require.Equal(t, len(runner.migrations), len(status))
+	for _, m := range status {
+		require.True(t, m.Applied)
+		require.False(t, m.Unknown)
+		require.Equal(t, "2022-12-08T00:00:00Z", m.AppliedAt.UTC().Format(time.RFC3339))
+	}
+}
+
+func TestRunner_UpIsIdempotent(t *testing.T) {
+	docs.Description("applying migrations again does nothing")
+	runner := tstRunner(t)
+
+	require.Nil(t, runner.Up())
+	require.Nil(t, runner.Up())
+
+	status, err := runner.Status()
+	require.Nil(t, err)
+	require.Equal(t, len(runner.migrations), len(status))
+}
+
+func TestRunner_UpSetsUpInvoiceNumbers(t *testing.T) {
+	docs.Description("applying all migrations sets up the invoice number counter")
+	runner := tstRunner(t)
+
+	require.Nil(t, runner.Up())
+
+	counter := entity.InvoiceNumber{}
+	require.Nil(t, runner.db.First(&counter, 1).Error)
+	require.Equal(t, uint(0), counter.LastNumber)
+}
+
+func TestRunner_UpAdoptsExistingSchema(t *testing.T) {
+	docs.Description("a database previously set up by gorm AutoMigrate is adopted by the initial migration")
+	runner := tstRunner(t)
+	require.Nil(t, runner.db.AutoMigrate(tstEntities...))
+	for e, columns := range tstColumnsAddedLater {
+		for _, column := range columns {
+			require.Nil(t, runner.db.Migrator().DropColumn(e, column))
+		}
+	}
+	require.Nil(t, runner.db.Exec("INSERT INTO att_bans (reason) VALUES ('existing')").Error)
+
+	require.Nil(t, runner.Up())
+
+	var count int64
+	require.Nil(t, runner.db.Table("att_bans").Count(&count).Error)
+	require.Equal(t, int64(1), count)
+}
+
+func TestRunner_DownTo(t *testing.T) {
+	docs.Description("reverting all migrations drops the tables and marks the migrations as pending again")
+	runner := tstRunner(t)
+	require.Nil(t, runner.Up())
+
+	require.Nil(t, runner.DownTo(0))
+
+	require.False(t, runner.db.Migrator().HasTable(&entity.Attendee{}))
+	status, err := runner.Status()
+	require.Nil(t, err)
+	for _, m := range status {
+		require.False(t, m.Applied)
+	}
+}
+
+func TestRunner_SchemaAhead(t *testing.T) {
+	docs.Description("a migration applied by a newer version of the service is reported, and blocks further migrations")
+	runner := tstRunner(t)
+	require.Nil(t, runner.Up())
+	require.Nil(t, runner.db.Exec("INSERT INTO att_schema_migrations (version, name, applied_at) VALUES (?, ?, ?)", 999, "from_the_future", runner.Now()).Error)
+
+	require.Equal(t, ErrSchemaAhead, runner.Up())
+	require.Equal(t, ErrSchemaAhead, runner.DownTo(0))
+
+	status, err := runner.Status()
+	require.Nil(t, err)
+	last := status[len(status)-1]
+	require.Equal(t, uint(999), last.Version)
+	require.Equal(t, "from_the_future", last.Name)
+	require.True(t, last.Unknown)
+}
+
+func TestStatements(t *testing.T) {
+	docs.Description("scripts are split at semicolons ending a line, leaving out comments")
+	script := `-- a comment
+CREATE TABLE a (
+  x integer
+);
+
+  -- indented comment
+DROP TABLE b;
+DELETE FROM c`
+	expected := []string{
+		"CREATE TABLE a (\n  x integer\n)",
+		"DROP TABLE b",
+		"DELETE FROM c",
+	}
+	require.Equal(t, expected, statements(script))
+	require.Empty(t, statements(strings.Repeat("-- only comments\n", 3)))
+}
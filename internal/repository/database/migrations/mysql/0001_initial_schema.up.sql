@@ -0,0 +1,176 @@
+-- the schema as previously created by gorm AutoMigrate
+--
+-- Uses IF NOT EXISTS throughout, so an existing database set up with -migrate-database by an earlier
+-- version of the service is simply adopted.
+
+CREATE TABLE IF NOT EXISTS `att_additional_infos` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `attendee_id` bigint unsigned NOT NULL,
+  `area` varchar(32) NOT NULL,
+  `json_value` text,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `att_add_infos_area_uidx` (`attendee_id`, `area`),
+  INDEX `idx_att_additional_infos_deleted_at` (`deleted_at`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `att_admin_infos` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `flags` varchar(255),
+  `permissions` varchar(255),
+  `admin_comments` text,
+  `manual_dues` bigint,
+  `manual_dues_description` text,
+  PRIMARY KEY (`id`),
+  INDEX `idx_att_admin_infos_deleted_at` (`deleted_at`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `att_attendees` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `nickname` varchar(80) NOT NULL,
+  `first_name` varchar(80) NOT NULL,
+  `last_name` varchar(80) NOT NULL,
+  `street` varchar(120) NOT NULL,
+  `zip` varchar(20) NOT NULL,
+  `city` varchar(80) NOT NULL,
+  `country` varchar(2) NOT NULL,
+  `state` varchar(80),
+  `email` varchar(200) NOT NULL,
+  `phone` varchar(32) NOT NULL,
+  `telegram` varchar(80),
+  `partner` varchar(80),
+  `birthday` varchar(10) NOT NULL,
+  `gender` varchar(32) NOT NULL,
+  `pronouns` varchar(40),
+  `tshirt_size` varchar(32),
+  `spoken_languages` varchar(255),
+  `registration_language` varchar(255),
+  `flags` varchar(4096),
+  `packages` varchar(4096),
+  `options` varchar(4096),
+  `user_comments` text,
+  `identity` varchar(255),
+  `avatar` varchar(255),
+  `cache_total_dues` bigint,
+  `cache_payment_balance` bigint,
+  `cache_open_balance` bigint,
+  `cache_due_date` varchar(10),
+  PRIMARY KEY (`id`),
+  INDEX `att_attendees_nick_idx` (`nickname`),
+  INDEX `att_attendees_email_idx` (`email`),
+  UNIQUE INDEX `att_attendees_dupl_uidx` (`nickname`, `zip`, `email`),
+  UNIQUE INDEX `att_attendees_identity_uidx` (`identity`),
+  INDEX `idx_att_attendees_deleted_at` (`deleted_at`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `att_bans` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `reason` varchar(255),
+  `name_pattern` varchar(255),
+  `nickname_pattern` varchar(255),
+  `email_pattern` varchar(255),
+  PRIMARY KEY (`id`),
+  INDEX `idx_att_bans_deleted_at` (`deleted_at`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `att_histories` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `entity` varchar(80) NOT NULL,
+  `entity_id` bigint unsigned NOT NULL,
+  `request_id` varchar(8),
+  `identity` varchar(255) NOT NULL,
+  `diff` text,
+  PRIMARY KEY (`id`),
+  INDEX `att_histories_entity_idx` (`entity`, `entity_id`),
+  INDEX `att_histories_identity_idx` (`identity`),
+  INDEX `idx_att_histories_deleted_at` (`deleted_at`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `att_status_changes` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `attendee_id` bigint unsigned NOT NULL,
+  `status` varchar(32) NOT NULL,
+  `comments` text,
+  PRIMARY KEY (`id`),
+  INDEX `att_status_changes_attendee_idx` (`attendee_id`),
+  INDEX `att_status_changes_status_idx` (`status`),
+  INDEX `idx_att_status_changes_deleted_at` (`deleted_at`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `att_counts` (
+  `area` varchar(191) NOT NULL,
+  `name` varchar(191) NOT NULL,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `pending` bigint NOT NULL,
+  `attending` bigint NOT NULL,
+  PRIMARY KEY (`area`, `name`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `att_waitlist_entries` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `package` varchar(80) NOT NULL,
+  `attendee_id` bigint unsigned NOT NULL,
+  `count` bigint NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `att_waitlist_entries_uidx` (`package`, `attendee_id`),
+  INDEX `idx_att_waitlist_entries_deleted_at` (`deleted_at`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `att_outbox_mails` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `attendee_id` bigint unsigned NOT NULL,
+  `common_id` varchar(80) NOT NULL,
+  `state` varchar(32) NOT NULL,
+  `attempts` bigint NOT NULL,
+  `next_attempt` datetime(3) NOT NULL,
+  `last_error` text,
+  `payload` text NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `att_outbox_mails_attendee_idx` (`attendee_id`),
+  INDEX `att_outbox_mails_state_idx` (`state`),
+  INDEX `idx_att_outbox_mails_deleted_at` (`deleted_at`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `att_webhook_deliveries` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `subscriber` varchar(80) NOT NULL,
+  `state` varchar(32) NOT NULL,
+  `event` varchar(80) NOT NULL,
+  `attendee_id` bigint unsigned NOT NULL,
+  `attempts` bigint NOT NULL,
+  `next_attempt` datetime(3) NOT NULL,
+  `last_status` bigint NOT NULL,
+  `last_error` text,
+  `payload` text NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `att_webhook_deliveries_queue_idx` (`subscriber`, `state`),
+  INDEX `att_webhook_deliveries_attendee_idx` (`attendee_id`),
+  INDEX `idx_att_webhook_deliveries_deleted_at` (`deleted_at`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
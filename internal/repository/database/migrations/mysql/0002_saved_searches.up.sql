@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS `att_saved_searches` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `name` varchar(80) NOT NULL,
+  `description` varchar(255),
+  `permissions` varchar(255),
+  `criteria` text NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `att_saved_searches_name_uidx` (`name`),
+  INDEX `idx_att_saved_searches_deleted_at` (`deleted_at`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
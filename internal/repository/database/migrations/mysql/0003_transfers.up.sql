@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS `att_transfers` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `attendee_id` bigint unsigned NOT NULL,
+  `from_identity` varchar(255) NOT NULL,
+  `to_email` varchar(200) NOT NULL,
+  `to_identity` varchar(255),
+  `code` varchar(64) NOT NULL,
+  `state` varchar(32) NOT NULL,
+  `expires_at` datetime(3) NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `att_transfers_attendee_idx` (`attendee_id`),
+  UNIQUE INDEX `att_transfers_code_uidx` (`code`),
+  INDEX `idx_att_transfers_deleted_at` (`deleted_at`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS `att_groups` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `name` varchar(80) NOT NULL,
+  `owner_id` bigint unsigned NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `att_groups_owner_idx` (`owner_id`),
+  INDEX `idx_att_groups_deleted_at` (`deleted_at`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `att_group_members` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `group_id` bigint unsigned NOT NULL,
+  `attendee_id` bigint unsigned NOT NULL,
+  `state` varchar(32) NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `att_group_members_uidx` (`group_id`, `attendee_id`),
+  INDEX `att_group_members_attendee_idx` (`attendee_id`),
+  INDEX `idx_att_group_members_deleted_at` (`deleted_at`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS `att_package_prices`;
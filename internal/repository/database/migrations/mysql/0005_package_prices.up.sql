@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS `att_package_prices` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `attendee_id` bigint unsigned NOT NULL,
+  `package` varchar(80) NOT NULL,
+  `booked_on` varchar(10) NOT NULL,
+  `price` bigint NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `att_package_prices_uidx` (`attendee_id`, `package`),
+  INDEX `idx_att_package_prices_deleted_at` (`deleted_at`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS `att_vouchers` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `code` varchar(32) NOT NULL,
+  `description` varchar(256) NOT NULL,
+  `kind` varchar(16) NOT NULL,
+  `amount` bigint NOT NULL,
+  `package` varchar(80) NOT NULL,
+  `max_uses` bigint NOT NULL,
+  `valid_until` varchar(10) NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `att_vouchers_code_uidx` (`code`),
+  INDEX `idx_att_vouchers_deleted_at` (`deleted_at`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `att_voucher_redemptions` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `voucher_id` bigint unsigned NOT NULL,
+  `attendee_id` bigint unsigned NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `att_voucher_redemptions_uidx` (`voucher_id`, `attendee_id`),
+  INDEX `att_voucher_redemptions_attendee_idx` (`attendee_id`),
+  INDEX `idx_att_voucher_redemptions_deleted_at` (`deleted_at`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS `att_invoices` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `attendee_id` bigint unsigned NOT NULL,
+  `number` bigint unsigned NOT NULL,
+  `number_prefix` varchar(20) NOT NULL,
+  `issued_on` varchar(10) NOT NULL,
+  `currency` varchar(3) NOT NULL,
+  `recipient` text,
+  `items` text,
+  `total` bigint NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `att_invoices_number_uidx` (`number`),
+  INDEX `att_invoices_attendee_idx` (`attendee_id`),
+  INDEX `idx_att_invoices_deleted_at` (`deleted_at`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `att_invoice_numbers` (
+  `id` bigint unsigned NOT NULL,
+  `last_number` bigint unsigned NOT NULL,
+  PRIMARY KEY (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+INSERT INTO `att_invoice_numbers` (`id`, `last_number`)
+  SELECT 1, COALESCE(MAX(`number`), 0) FROM `att_invoices`
+  WHERE NOT EXISTS (SELECT 1 FROM `att_invoice_numbers`);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS `att_webhook_queues` (
+  `subscriber` varchar(80) NOT NULL,
+  `locked_until` datetime(3) NOT NULL,
+  `lock_holder` varchar(36) NOT NULL,
+  PRIMARY KEY (`subscriber`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS `att_schema_migrations` (
+  `version` bigint unsigned NOT NULL,
+  `name` varchar(255) NOT NULL,
+  `applied_at` datetime(3) NOT NULL,
+  PRIMARY KEY (`version`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
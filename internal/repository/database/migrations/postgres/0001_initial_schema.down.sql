@@ -0,0 +1,12 @@
+-- drops all tables, including their data
+
+DROP TABLE IF EXISTS att_webhook_deliveries;
+DROP TABLE IF EXISTS att_outbox_mails;
+DROP TABLE IF EXISTS att_waitlist_entries;
+DROP TABLE IF EXISTS att_counts;
+DROP TABLE IF EXISTS att_status_changes;
+DROP TABLE IF EXISTS att_histories;
+DROP TABLE IF EXISTS att_bans;
+DROP TABLE IF EXISTS att_attendees;
+DROP TABLE IF EXISTS att_admin_infos;
+DROP TABLE IF EXISTS att_additional_infos;
@@ -0,0 +1,176 @@
+-- the schema as previously created by gorm AutoMigrate
+--
+-- Uses IF NOT EXISTS throughout, so an existing database set up with -migrate-database by an earlier
+-- version of the service is simply adopted.
+
+CREATE TABLE IF NOT EXISTS att_additional_infos (
+  id bigserial NOT NULL,
+  created_at timestamptz,
+  updated_at timestamptz,
+  deleted_at timestamptz,
+  attendee_id bigint NOT NULL,
+  area varchar(32) NOT NULL,
+  json_value text,
+  PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS att_add_infos_area_uidx ON att_additional_infos (attendee_id, area);
+CREATE INDEX IF NOT EXISTS idx_att_additional_infos_deleted_at ON att_additional_infos (deleted_at);
+
+CREATE TABLE IF NOT EXISTS att_admin_infos (
+  id bigserial NOT NULL,
+  created_at timestamptz,
+  updated_at timestamptz,
+  deleted_at timestamptz,
+  flags varchar(255),
+  permissions varchar(255),
+  admin_comments text,
+  manual_dues bigint,
+  manual_dues_description text,
+  PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_att_admin_infos_deleted_at ON att_admin_infos (deleted_at);
+
+CREATE TABLE IF NOT EXISTS att_attendees (
+  id bigserial NOT NULL,
+  created_at timestamptz,
+  updated_at timestamptz,
+  deleted_at timestamptz,
+  nickname varchar(80) NOT NULL,
+  first_name varchar(80) NOT NULL,
+  last_name varchar(80) NOT NULL,
+  street varchar(120) NOT NULL,
+  zip varchar(20) NOT NULL,
+  city varchar(80) NOT NULL,
+  country varchar(2) NOT NULL,
+  state varchar(80),
+  email varchar(200) NOT NULL,
+  phone varchar(32) NOT NULL,
+  telegram varchar(80),
+  partner varchar(80),
+  birthday varchar(10) NOT NULL,
+  gender varchar(32) NOT NULL,
+  pronouns varchar(40),
+  tshirt_size varchar(32),
+  spoken_languages varchar(255),
+  registration_language varchar(255),
+  flags varchar(4096),
+  packages varchar(4096),
+  options varchar(4096),
+  user_comments text,
+  identity varchar(255),
+  avatar varchar(255),
+  cache_total_dues bigint,
+  cache_payment_balance bigint,
+  cache_open_balance bigint,
+  cache_due_date varchar(10),
+  PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS att_attendees_nick_idx ON att_attendees (nickname);
+CREATE INDEX IF NOT EXISTS att_attendees_email_idx ON att_attendees (email);
+CREATE UNIQUE INDEX IF NOT EXISTS att_attendees_dupl_uidx ON att_attendees (nickname, zip, email);
+CREATE UNIQUE INDEX IF NOT EXISTS att_attendees_identity_uidx ON att_attendees (identity);
+CREATE INDEX IF NOT EXISTS idx_att_attendees_deleted_at ON att_attendees (deleted_at);
+
+CREATE TABLE IF NOT EXISTS att_bans (
+  id bigserial NOT NULL,
+  created_at timestamptz,
+  updated_at timestamptz,
+  deleted_at timestamptz,
+  reason varchar(255),
+  name_pattern varchar(255),
+  nickname_pattern varchar(255),
+  email_pattern varchar(255),
+  PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_att_bans_deleted_at ON att_bans (deleted_at);
+
+CREATE TABLE IF NOT EXISTS att_histories (
+  id bigserial NOT NULL,
+  created_at timestamptz,
+  updated_at timestamptz,
+  deleted_at timestamptz,
+  entity varchar(80) NOT NULL,
+  entity_id bigint NOT NULL,
+  request_id varchar(8),
+  identity varchar(255) NOT NULL,
+  diff text,
+  PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS att_histories_entity_idx ON att_histories (entity, entity_id);
+CREATE INDEX IF NOT EXISTS att_histories_identity_idx ON att_histories (identity);
+CREATE INDEX IF NOT EXISTS idx_att_histories_deleted_at ON att_histories (deleted_at);
+
+CREATE TABLE IF NOT EXISTS att_status_changes (
+  id bigserial NOT NULL,
+  created_at timestamptz,
+  updated_at timestamptz,
+  deleted_at timestamptz,
+  attendee_id bigint NOT NULL,
+  status varchar(32) NOT NULL,
+  comments text,
+  PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS att_status_changes_attendee_idx ON att_status_changes (attendee_id);
+CREATE INDEX IF NOT EXISTS att_status_changes_status_idx ON att_status_changes (status);
+CREATE INDEX IF NOT EXISTS idx_att_status_changes_deleted_at ON att_status_changes (deleted_at);
+
+CREATE TABLE IF NOT EXISTS att_counts (
+  area text NOT NULL,
+  name text NOT NULL,
+  created_at timestamptz,
+  updated_at timestamptz,
+  pending bigint NOT NULL,
+  attending bigint NOT NULL,
+  PRIMARY KEY (area, name)
+);
+
+CREATE TABLE IF NOT EXISTS att_waitlist_entries (
+  id bigserial NOT NULL,
+  created_at timestamptz,
+  updated_at timestamptz,
+  deleted_at timestamptz,
+  package varchar(80) NOT NULL,
+  attendee_id bigint NOT NULL,
+  count bigint NOT NULL,
+  PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS att_waitlist_entries_uidx ON att_waitlist_entries (package, attendee_id);
+CREATE INDEX IF NOT EXISTS idx_att_waitlist_entries_deleted_at ON att_waitlist_entries (deleted_at);
+
+CREATE TABLE IF NOT EXISTS att_outbox_mails (
+  id bigserial NOT NULL,
+  created_at timestamptz,
+  updated_at timestamptz,
+  deleted_at timestamptz,
+  attendee_id bigint NOT NULL,
+  common_id varchar(80) NOT NULL,
+  state varchar(32) NOT NULL,
+  attempts bigint NOT NULL,
+  next_attempt timestamptz NOT NULL,
+  last_error text,
+  payload text NOT NULL,
+  PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS att_outbox_mails_attendee_idx ON att_outbox_mails (attendee_id);
+CREATE INDEX IF NOT EXISTS att_outbox_mails_state_idx ON att_outbox_mails (state);
+CREATE INDEX IF NOT EXISTS idx_att_outbox_mails_deleted_at ON att_outbox_mails (deleted_at);
+
+CREATE TABLE IF NOT EXISTS att_webhook_deliveries (
+  id bigserial NOT NULL,
+  created_at timestamptz,
+  updated_at timestamptz,
+  deleted_at timestamptz,
+  subscriber varchar(80) NOT NULL,
+  state varchar(32) NOT NULL,
+  event varchar(80) NOT NULL,
+  attendee_id bigint NOT NULL,
+  attempts bigint NOT NULL,
+  next_attempt timestamptz NOT NULL,
+  last_status bigint NOT NULL,
+  last_error text,
+  payload text NOT NULL,
+  PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS att_webhook_deliveries_queue_idx ON att_webhook_deliveries (subscriber, state);
+CREATE INDEX IF NOT EXISTS att_webhook_deliveries_attendee_idx ON att_webhook_deliveries (attendee_id);
+CREATE INDEX IF NOT EXISTS idx_att_webhook_deliveries_deleted_at ON att_webhook_deliveries (deleted_at);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS att_transfers (
+  id bigserial NOT NULL,
+  created_at timestamptz,
+  updated_at timestamptz,
+  deleted_at timestamptz,
+  attendee_id bigint NOT NULL,
+  from_identity varchar(255) NOT NULL,
+  to_email varchar(200) NOT NULL,
+  to_identity varchar(255),
+  code varchar(64) NOT NULL,
+  state varchar(32) NOT NULL,
+  expires_at timestamptz NOT NULL,
+  PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS att_transfers_attendee_idx ON att_transfers (attendee_id);
+CREATE UNIQUE INDEX IF NOT EXISTS att_transfers_code_uidx ON att_transfers (code);
+CREATE INDEX IF NOT EXISTS idx_att_transfers_deleted_at ON att_transfers (deleted_at);
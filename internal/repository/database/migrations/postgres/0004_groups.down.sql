@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS att_group_members;
+DROP TABLE IF EXISTS att_groups;
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS att_groups (
+  id bigserial NOT NULL,
+  created_at timestamptz,
+  updated_at timestamptz,
+  deleted_at timestamptz,
+  name varchar(80) NOT NULL,
+  owner_id bigint NOT NULL,
+  PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS att_groups_owner_idx ON att_groups (owner_id);
+CREATE INDEX IF NOT EXISTS idx_att_groups_deleted_at ON att_groups (deleted_at);
+
+CREATE TABLE IF NOT EXISTS att_group_members (
+  id bigserial NOT NULL,
+  created_at timestamptz,
+  updated_at timestamptz,
+  deleted_at timestamptz,
+  group_id bigint NOT NULL,
+  attendee_id bigint NOT NULL,
+  state varchar(32) NOT NULL,
+  PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS att_group_members_uidx ON att_group_members (group_id, attendee_id);
+CREATE INDEX IF NOT EXISTS att_group_members_attendee_idx ON att_group_members (attendee_id);
+CREATE INDEX IF NOT EXISTS idx_att_group_members_deleted_at ON att_group_members (deleted_at);
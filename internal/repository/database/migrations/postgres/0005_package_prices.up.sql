@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS att_package_prices (
+  id bigserial NOT NULL,
+  created_at timestamptz,
+  updated_at timestamptz,
+  deleted_at timestamptz,
+  attendee_id bigint NOT NULL,
+  package varchar(80) NOT NULL,
+  booked_on varchar(10) NOT NULL,
+  price bigint NOT NULL,
+  PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS att_package_prices_uidx ON att_package_prices (attendee_id, package);
+CREATE INDEX IF NOT EXISTS idx_att_package_prices_deleted_at ON att_package_prices (deleted_at);
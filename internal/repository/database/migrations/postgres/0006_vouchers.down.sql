@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS att_voucher_redemptions;
+DROP TABLE IF EXISTS att_vouchers;
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS att_vouchers (
+  id bigserial NOT NULL,
+  created_at timestamptz,
+  updated_at timestamptz,
+  deleted_at timestamptz,
+  code varchar(32) NOT NULL,
+  description varchar(256) NOT NULL,
+  kind varchar(16) NOT NULL,
+  amount bigint NOT NULL,
+  package varchar(80) NOT NULL,
+  max_uses bigint NOT NULL,
+  valid_until varchar(10) NOT NULL,
+  PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS att_vouchers_code_uidx ON att_vouchers (code);
+CREATE INDEX IF NOT EXISTS idx_att_vouchers_deleted_at ON att_vouchers (deleted_at);
+
+CREATE TABLE IF NOT EXISTS att_voucher_redemptions (
+  id bigserial NOT NULL,
+  created_at timestamptz,
+  updated_at timestamptz,
+  deleted_at timestamptz,
+  voucher_id bigint NOT NULL,
+  attendee_id bigint NOT NULL,
+  PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS att_voucher_redemptions_uidx ON att_voucher_redemptions (voucher_id, attendee_id);
+CREATE INDEX IF NOT EXISTS att_voucher_redemptions_attendee_idx ON att_voucher_redemptions (attendee_id);
+CREATE INDEX IF NOT EXISTS idx_att_voucher_redemptions_deleted_at ON att_voucher_redemptions (deleted_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS att_invoice_numbers;
+DROP TABLE IF EXISTS att_invoices;
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS att_invoices (
+  id bigserial NOT NULL,
+  created_at timestamptz,
+  updated_at timestamptz,
+  deleted_at timestamptz,
+  attendee_id bigint NOT NULL,
+  number bigint NOT NULL,
+  number_prefix varchar(20) NOT NULL,
+  issued_on varchar(10) NOT NULL,
+  currency varchar(3) NOT NULL,
+  recipient text,
+  items text,
+  total bigint NOT NULL,
+  PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS att_invoices_number_uidx ON att_invoices (number);
+CREATE INDEX IF NOT EXISTS att_invoices_attendee_idx ON att_invoices (attendee_id);
+CREATE INDEX IF NOT EXISTS idx_att_invoices_deleted_at ON att_invoices (deleted_at);
+
+CREATE TABLE IF NOT EXISTS att_invoice_numbers (
+  id bigint NOT NULL,
+  last_number bigint NOT NULL,
+  PRIMARY KEY (id)
+);
+INSERT INTO att_invoice_numbers (id, last_number)
+  SELECT 1, COALESCE(MAX(number), 0) FROM att_invoices
+  WHERE NOT EXISTS (SELECT 1 FROM att_invoice_numbers);
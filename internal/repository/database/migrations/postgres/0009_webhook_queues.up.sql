@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS att_webhook_queues (
+  subscriber varchar(80) NOT NULL,
+  locked_until timestamptz NOT NULL,
+  lock_holder varchar(36) NOT NULL,
+  PRIMARY KEY (subscriber)
+);
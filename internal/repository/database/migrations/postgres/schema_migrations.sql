@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS att_schema_migrations (
+  version bigint NOT NULL,
+  name varchar(255) NOT NULL,
+  applied_at timestamptz NOT NULL,
+  PRIMARY KEY (version)
+);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS `att_saved_searches` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `name` varchar(80) NOT NULL,
+  `description` varchar(255),
+  `permissions` varchar(255),
+  `criteria` text NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `att_saved_searches_name_uidx` ON `att_saved_searches` (`name`);
+CREATE INDEX IF NOT EXISTS `idx_att_saved_searches_deleted_at` ON `att_saved_searches` (`deleted_at`);
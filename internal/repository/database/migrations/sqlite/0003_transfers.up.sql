@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS `att_transfers` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `attendee_id` integer NOT NULL,
+  `from_identity` varchar(255) NOT NULL,
+  `to_email` varchar(200) NOT NULL,
+  `to_identity` varchar(255),
+  `code` varchar(64) NOT NULL,
+  `state` varchar(32) NOT NULL,
+  `expires_at` datetime NOT NULL
+);
+CREATE INDEX IF NOT EXISTS `att_transfers_attendee_idx` ON `att_transfers` (`attendee_id`);
+CREATE UNIQUE INDEX IF NOT EXISTS `att_transfers_code_uidx` ON `att_transfers` (`code`);
+CREATE INDEX IF NOT EXISTS `idx_att_transfers_deleted_at` ON `att_transfers` (`deleted_at`);
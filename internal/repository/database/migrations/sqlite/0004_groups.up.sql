@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS `att_groups` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `name` varchar(80) NOT NULL,
+  `owner_id` integer NOT NULL
+);
+CREATE INDEX IF NOT EXISTS `att_groups_owner_idx` ON `att_groups` (`owner_id`);
+CREATE INDEX IF NOT EXISTS `idx_att_groups_deleted_at` ON `att_groups` (`deleted_at`);
+
+CREATE TABLE IF NOT EXISTS `att_group_members` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `group_id` integer NOT NULL,
+  `attendee_id` integer NOT NULL,
+  `state` varchar(32) NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `att_group_members_uidx` ON `att_group_members` (`group_id`, `attendee_id`);
+CREATE INDEX IF NOT EXISTS `att_group_members_attendee_idx` ON `att_group_members` (`attendee_id`);
+CREATE INDEX IF NOT EXISTS `idx_att_group_members_deleted_at` ON `att_group_members` (`deleted_at`);
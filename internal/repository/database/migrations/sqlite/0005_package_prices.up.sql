@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS `att_package_prices` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `attendee_id` integer NOT NULL,
+  `package` varchar(80) NOT NULL,
+  `booked_on` varchar(10) NOT NULL,
+  `price` integer NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `att_package_prices_uidx` ON `att_package_prices` (`attendee_id`, `package`);
+CREATE INDEX IF NOT EXISTS `idx_att_package_prices_deleted_at` ON `att_package_prices` (`deleted_at`);
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS `att_vouchers` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `code` varchar(32) NOT NULL,
+  `description` varchar(256) NOT NULL,
+  `kind` varchar(16) NOT NULL,
+  `amount` integer NOT NULL,
+  `package` varchar(80) NOT NULL,
+  `max_uses` integer NOT NULL,
+  `valid_until` varchar(10) NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `att_vouchers_code_uidx` ON `att_vouchers` (`code`);
+CREATE INDEX IF NOT EXISTS `idx_att_vouchers_deleted_at` ON `att_vouchers` (`deleted_at`);
+
+CREATE TABLE IF NOT EXISTS `att_voucher_redemptions` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `voucher_id` integer NOT NULL,
+  `attendee_id` integer NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `att_voucher_redemptions_uidx` ON `att_voucher_redemptions` (`voucher_id`, `attendee_id`);
+CREATE INDEX IF NOT EXISTS `att_voucher_redemptions_attendee_idx` ON `att_voucher_redemptions` (`attendee_id`);
+CREATE INDEX IF NOT EXISTS `idx_att_voucher_redemptions_deleted_at` ON `att_voucher_redemptions` (`deleted_at`);
@@ -0,0 +1,2 @@
+ALTER TABLE `att_vouchers` DROP COLUMN `currency`;
+ALTER TABLE `att_attendees` DROP COLUMN `currency`;
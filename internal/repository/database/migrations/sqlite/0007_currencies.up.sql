@@ -0,0 +1,2 @@
+ALTER TABLE `att_attendees` ADD COLUMN `currency` varchar(3);
+ALTER TABLE `att_vouchers` ADD COLUMN `currency` varchar(3);
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS `att_webhook_queues` (
+  `subscriber` varchar(80) PRIMARY KEY,
+  `locked_until` datetime NOT NULL,
+  `lock_holder` varchar(36) NOT NULL
+);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS `att_schema_migrations` (
+  `version` integer NOT NULL,
+  `name` varchar(255) NOT NULL,
+  `applied_at` datetime NOT NULL,
+  PRIMARY KEY (`version`)
+);
@@ -0,0 +1,31 @@
+package postgresdb
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database/dbrepo"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database/gormdb"
+	"gorm.io/driver/postgres"
+)
+
+func Create() dbrepo.Repository {
+	return gormdb.Create(Dialect)
+}
+
+var Dialect = gormdb.Dialect{
+	Type:          config.Postgres,
+	Open:          postgres.Open,
+	ConnectString: config.DatabasePostgresConnectString,
+	ConfigurePool: func(sqlDb *sql.DB) {
+		// see https://making.pusher.com/production-ready-connection-pooling-in-go/
+		sqlDb.SetMaxOpenConns(100)
+		sqlDb.SetMaxIdleConns(50)
+		sqlDb.SetConnMaxLifetime(time.Minute * 10)
+	},
+	NewestStatusSubQuery: " SELECT DISTINCT ON (sc.attendee_id) sc.attendee_id AS attendee_id, sc.status AS status " +
+		" FROM att_status_changes AS sc " +
+		" ORDER BY sc.attendee_id, sc.id DESC ",
+	Concat: gormdb.ConcatFunction,
+}
@@ -0,0 +1,32 @@
+package sqlitedb
+
+import (
+	"database/sql"
+
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database/dbrepo"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database/gormdb"
+	"github.com/glebarez/sqlite"
+)
+
+func Create() dbrepo.Repository {
+	return gormdb.Create(Dialect)
+}
+
+var Dialect = gormdb.Dialect{
+	Type:          config.Sqlite,
+	Open:          sqlite.Open,
+	ConnectString: config.DatabaseSqliteConnectString,
+	ConfigurePool: func(sqlDb *sql.DB) {
+		// sqlite only allows a single writer, so we serialize all access through one connection instead of
+		// running into "database is locked" errors. This also keeps an in-memory database alive.
+		sqlDb.SetMaxOpenConns(1)
+		sqlDb.SetMaxIdleConns(1)
+		sqlDb.SetConnMaxLifetime(0)
+	},
+	CloseOnShutdown: true, // an in-memory database is discarded when its connection is closed
+	NewestStatusSubQuery: " SELECT sc.attendee_id AS attendee_id, sc.status AS status " +
+		" FROM att_status_changes AS sc " +
+		" WHERE sc.id = ( SELECT max(sc2.id) FROM att_status_changes AS sc2 WHERE sc2.attendee_id = sc.attendee_id ) ",
+	Concat: gormdb.ConcatOperator,
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database/dbrepo"
+)
+
+type txContextKey struct{}
+
+// unitOfWork is attached to the context of everything running inside WithTransaction.
+type unitOfWork struct {
+	repo        dbrepo.Repository
+	afterCommit []func(ctx context.Context) error
+}
+
+// GetRepositoryFor returns the repository to use for ctx.
+//
+// Inside WithTransaction, this is the transaction, so reads see uncommitted changes and writes
+// are rolled back if the unit of work fails. Otherwise, it is the same as GetRepository().
+func GetRepositoryFor(ctx context.Context) dbrepo.Repository {
+	if uow, ok := ctx.Value(txContextKey{}).(*unitOfWork); ok {
+		return uow.repo
+	}
+	return GetRepository()
+}
+
+// WithTransaction runs f as a single unit of work. Use the context passed to f for all database access,
+// via GetRepositoryFor.
+//
+// If called while already inside a unit of work, f simply joins it.
+func WithTransaction(ctx context.Context, f func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txContextKey{}).(*unitOfWork); ok {
+		return f(ctx)
+	}
+
+	uow := &unitOfWork{}
+	err := GetRepository().WithTransaction(ctx, func(repo dbrepo.Repository) error {
+		uow.repo = repo
+		return f(context.WithValue(ctx, txContextKey{}, uow))
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range uow.afterCommit {
+		if err := hook(ctx); err != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("error in after commit hook: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// AfterCommit defers f until the current unit of work has been committed successfully. This is
+// useful for side effects outside the database, which cannot be rolled back.
+//
+// If ctx does not belong to a unit of work, f is run immediately and its error is returned.
+// Otherwise, errors from f are only logged, because the transaction has already been committed.
+func AfterCommit(ctx context.Context, f func(ctx context.Context) error) error {
+	if uow, ok := ctx.Value(txContextKey{}).(*unitOfWork); ok {
+		uow.afterCommit = append(uow.afterCommit, f)
+		return nil
+	}
+	return f(ctx)
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database/inmemorydb"
+	"github.com/stretchr/testify/require"
+)
+
+func tstSetupInmemoryRepository() {
+	r := inmemorydb.Create()
+	_ = r.Open()
+	SetRepository(r)
+}
+
+func TestWithTransaction_Commit(t *testing.T) {
+	docs.Description("after commit hooks run once the unit of work has been committed")
+	tstSetupInmemoryRepository()
+	defer SetRepository(nil)
+
+	hookCalls := 0
+	var newId uint
+	err := WithTransaction(context.TODO(), func(ctx context.Context) error {
+		var err error
+		newId, err = GetRepositoryFor(ctx).AddAttendee(ctx, &entity.Attendee{Nickname: "committed"})
+		if err != nil {
+			return err
+		}
+
+		return AfterCommit(ctx, func(ctx context.Context) error {
+			hookCalls++
+			_, err := GetRepositoryFor(ctx).GetAttendeeById(ctx, newId)
+			return err
+		})
+	})
+	require.Nil(t, err)
+	require.Equal(t, 1, hookCalls)
+}
+
+func TestWithTransaction_Rollback(t *testing.T) {
+	docs.Description("after commit hooks do not run if the unit of work fails, and changes are rolled back")
+	tstSetupInmemoryRepository()
+	defer SetRepository(nil)
+
+	hookCalls := 0
+	var newId uint
+	err := WithTransaction(context.TODO(), func(ctx context.Context) error {
+		newId, _ = GetRepositoryFor(ctx).AddAttendee(ctx, &entity.Attendee{Nickname: "rolled back"})
+		_ = AfterCommit(ctx, func(ctx context.Context) error {
+			hookCalls++
+			return nil
+		})
+		return errors.New("some error")
+	})
+	require.NotNil(t, err)
+	require.Equal(t, 0, hookCalls)
+
+	_, err = GetRepository().GetAttendeeById(context.TODO(), newId)
+	require.NotNil(t, err, "attendee should have been rolled back")
+}
+
+func TestWithTransaction_NestedJoinsOuter(t *testing.T) {
+	docs.Description("a nested unit of work joins the outer one, so a failure in the outer one rolls back both")
+	tstSetupInmemoryRepository()
+	defer SetRepository(nil)
+
+	var newId uint
+	err := WithTransaction(context.TODO(), func(ctx context.Context) error {
+		err := WithTransaction(ctx, func(ctx context.Context) error {
+			var err error
+			newId, err = GetRepositoryFor(ctx).AddAttendee(ctx, &entity.Attendee{Nickname: "inner"})
+			return err
+		})
+		require.Nil(t, err)
+		return errors.New("outer error")
+	})
+	require.NotNil(t, err)
+
+	_, err = GetRepository().GetAttendeeById(context.TODO(), newId)
+	require.NotNil(t, err, "attendee from inner unit of work should have been rolled back")
+}
+
+func TestAfterCommit_OutsideTransaction(t *testing.T) {
+	docs.Description("outside a unit of work, after commit hooks run immediately and their error is returned")
+	err := AfterCommit(context.TODO(), func(ctx context.Context) error {
+		return errors.New("hook error")
+	})
+	require.NotNil(t, err)
+	require.Equal(t, "hook error", err.Error())
+}
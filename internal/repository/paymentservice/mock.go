@@ -2,6 +2,8 @@ package paymentservice
 
 import (
 	"context"
+	"sync"
+
 	aulogging "github.com/StephanHCB/go-autumn-logging"
 )
 
@@ -16,6 +18,7 @@ type Mock interface {
 }
 
 type MockImpl struct {
+	mu               sync.Mutex
 	data             map[uint][]Transaction
 	recording        []Transaction
 	simulateGetError error
@@ -35,6 +38,9 @@ func newMock() Mock {
 }
 
 func (m *MockImpl) GetTransactions(ctx context.Context, debitorId uint) ([]Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	result := make([]Transaction, 0)
 	if m.simulateGetError != nil {
 		return result, m.simulateGetError
@@ -49,11 +55,14 @@ func (m *MockImpl) GetTransactions(ctx context.Context, debitorId uint) ([]Trans
 }
 
 func (m *MockImpl) AddTransaction(ctx context.Context, transaction Transaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if m.simulateAddError != nil {
 		return m.simulateAddError
 	}
 
-	_ = m.InjectTransaction(ctx, transaction)
+	m.inject(transaction)
 	m.recording = append(m.recording, transaction)
 
 	aulogging.Logger.Ctx(ctx).Info().Printf("add transaction debitor %d type %s status %s method %s for %0.2f %s", transaction.DebitorID, transaction.TransactionType, transaction.Status, transaction.Method, float64(transaction.Amount.GrossCent)/100.0, transaction.Amount.Currency)
@@ -64,24 +73,40 @@ func (m *MockImpl) AddTransaction(ctx context.Context, transaction Transaction)
 // only used in tests
 
 func (m *MockImpl) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.recording = make([]Transaction, 0)
 	m.simulateGetError = nil
 	m.simulateAddError = nil
 }
 
 func (m *MockImpl) Recording() []Transaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.recording
 }
 
 func (m *MockImpl) SimulateGetError(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.simulateGetError = err
 }
 
 func (m *MockImpl) SimulateAddError(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.simulateAddError = err
 }
 
 func (m *MockImpl) InjectTransaction(_ context.Context, transaction Transaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.inject(transaction)
+	return nil
+}
+
+func (m *MockImpl) inject(transaction Transaction) {
 	existingTransactions, ok := m.data[transaction.DebitorID]
 	if !ok {
 		existingTransactions = make([]Transaction, 0)
@@ -89,6 +114,4 @@ func (m *MockImpl) InjectTransaction(_ context.Context, transaction Transaction)
 
 	transactions := append(existingTransactions, transaction)
 	m.data[transaction.DebitorID] = transactions
-
-	return nil
 }
@@ -0,0 +1,54 @@
+package webhookservice
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/eurofurence/reg-attendee-service/internal/web/middleware"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/go-http-utils/headers"
+)
+
+type Impl struct {
+	client *http.Client
+}
+
+func newClient() WebhookService {
+	return &Impl{
+		client: &http.Client{},
+	}
+}
+
+func (i *Impl) Deliver(ctx context.Context, request DeliveryRequest) (int, error) {
+	// the body must be sent exactly as signed, so we cannot let a rest client re-encode it
+	ctx, cancel := context.WithTimeout(ctx, request.Timeout)
+	defer cancel()
+
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, request.Url, bytes.NewReader(request.Body))
+	if err != nil {
+		return 0, err
+	}
+	httpRequest.Header.Set(headers.ContentType, media.ContentTypeApplicationJson)
+	httpRequest.Header.Set(middleware.TraceIdHeader, ctxvalues.RequestId(ctx))
+	for name, value := range request.Headers {
+		httpRequest.Header.Set(name, value)
+	}
+
+	response, err := i.client.Do(httpRequest)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, response.Body)
+		_ = response.Body.Close()
+	}()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, fmt.Errorf("%w: http status %d", DownstreamError, response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
@@ -0,0 +1,18 @@
+package webhookservice
+
+var activeInstance WebhookService
+
+func Create() {
+	// subscriber urls come from the webhook configuration, so there is nothing to set up here
+	activeInstance = newClient()
+}
+
+func CreateMock() Mock {
+	instance := newMock()
+	activeInstance = instance
+	return instance
+}
+
+func Get() WebhookService {
+	return activeInstance
+}
@@ -0,0 +1,25 @@
+package webhookservice
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type WebhookService interface {
+	// Deliver sends a single event to a subscriber.
+	//
+	// Returns the http status received, or 0 if there was no response. Any status other than 2xx is an error.
+	Deliver(ctx context.Context, request DeliveryRequest) (int, error)
+}
+
+var (
+	DownstreamError = errors.New("webhook subscriber did not accept the event")
+)
+
+type DeliveryRequest struct {
+	Url     string
+	Headers map[string]string
+	Body    []byte
+	Timeout time.Duration
+}
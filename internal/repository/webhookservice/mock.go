@@ -0,0 +1,71 @@
+package webhookservice
+
+import (
+	"context"
+	"net/http"
+)
+
+type Mock interface {
+	WebhookService
+
+	Reset()
+	Recording() []DeliveryRequest
+	SimulateError(url string, err error)
+	SimulateHanging(url string) (release func())
+}
+
+type MockImpl struct {
+	recording      []DeliveryRequest
+	simulateErrors map[string]error
+	simulateHangs  map[string]chan struct{}
+}
+
+var (
+	_ WebhookService = (*MockImpl)(nil)
+	_ Mock           = (*MockImpl)(nil)
+)
+
+func newMock() Mock {
+	return &MockImpl{
+		recording:      make([]DeliveryRequest, 0),
+		simulateErrors: make(map[string]error),
+		simulateHangs:  make(map[string]chan struct{}),
+	}
+}
+
+func (m *MockImpl) Deliver(ctx context.Context, request DeliveryRequest) (int, error) {
+	if hang, ok := m.simulateHangs[request.Url]; ok {
+		<-hang
+	}
+	if err, ok := m.simulateErrors[request.Url]; ok {
+		return http.StatusBadGateway, err
+	}
+	m.recording = append(m.recording, request)
+	return http.StatusNoContent, nil
+}
+
+// only used in tests
+
+func (m *MockImpl) Reset() {
+	m.recording = make([]DeliveryRequest, 0)
+	m.simulateErrors = make(map[string]error)
+	m.simulateHangs = make(map[string]chan struct{})
+}
+
+func (m *MockImpl) Recording() []DeliveryRequest {
+	return m.recording
+}
+
+// SimulateError makes all deliveries to url fail with err.
+func (m *MockImpl) SimulateError(url string, err error) {
+	m.simulateErrors[url] = err
+}
+
+// SimulateHanging makes all deliveries to url wait until release is called.
+func (m *MockImpl) SimulateHanging(url string) (release func()) {
+	hang := make(chan struct{})
+	m.simulateHangs[url] = hang
+	return func() {
+		close(hang)
+	}
+}
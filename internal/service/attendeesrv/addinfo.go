@@ -3,13 +3,14 @@ package attendeesrv
 import (
 	"context"
 	"fmt"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/webhook"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
 	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
 )
 
 func (s *AttendeeServiceImplData) GetFullAdditionalInfoArea(ctx context.Context, area string) (map[string]string, error) {
-	entries, err := database.GetRepository().GetAllAdditionalInfoForArea(ctx, area)
+	entries, err := database.GetRepositoryFor(ctx).GetAllAdditionalInfoForArea(ctx, area)
 	if err != nil {
 		return make(map[string]string), err
 	}
@@ -24,19 +25,26 @@ func (s *AttendeeServiceImplData) GetFullAdditionalInfoArea(ctx context.Context,
 }
 
 func (s *AttendeeServiceImplData) GetAdditionalInfo(ctx context.Context, attendeeId uint, area string) (string, error) {
-	existing, err := database.GetRepository().GetAdditionalInfoFor(ctx, attendeeId, area)
+	existing, err := database.GetRepositoryFor(ctx).GetAdditionalInfoFor(ctx, attendeeId, area)
 	return existing.JsonValue, err
 }
 
 func (s *AttendeeServiceImplData) WriteAdditionalInfo(ctx context.Context, attendeeId uint, area string, value string) error {
-	existing, err := database.GetRepository().GetAdditionalInfoFor(ctx, attendeeId, area)
-	if err != nil {
-		return err
-	}
+	return database.WithTransaction(ctx, func(ctx context.Context) error {
+		existing, err := database.GetRepositoryFor(ctx).GetAdditionalInfoFor(ctx, attendeeId, area)
+		if err != nil {
+			return err
+		}
 
-	existing.JsonValue = value
+		existing.JsonValue = value
+
+		err = database.GetRepositoryFor(ctx).WriteAdditionalInfo(ctx, existing)
+		if err != nil {
+			return err
+		}
 
-	return database.GetRepository().WriteAdditionalInfo(ctx, existing)
+		return s.emitWebhookEvent(ctx, webhook.Event{Event: webhook.EventAttendeeAddInfoChanged, AttendeeId: attendeeId, Area: area})
+	})
 }
 
 func (s *AttendeeServiceImplData) CanAccessAdditionalInfoArea(ctx context.Context, area ...string) (bool, error) {
@@ -50,7 +58,7 @@ func (s *AttendeeServiceImplData) CanAccessAdditionalInfoArea(ctx context.Contex
 }
 
 func (s *AttendeeServiceImplData) CanAccessOwnAdditionalInfoArea(ctx context.Context, attendeeId uint, wantWriteAccess bool, area string) (bool, error) {
-	att, err := database.GetRepository().GetAttendeeById(ctx, attendeeId)
+	att, err := database.GetRepositoryFor(ctx).GetAttendeeById(ctx, attendeeId)
 	if err != nil {
 		// attendee does not exist is checked later in order to not expose information
 		return false, nil
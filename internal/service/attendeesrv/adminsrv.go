@@ -3,6 +3,7 @@ package attendeesrv
 import (
 	"context"
 	"fmt"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/webhook"
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
 	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
@@ -11,7 +12,7 @@ import (
 func (s *AttendeeServiceImplData) GetAdminInfo(ctx context.Context, attendeeId uint) (*entity.AdminInfo, error) {
 	// admin authorization is checked in the controller
 	// presence of attendeeId is checked in the controller
-	adminInfo, err := database.GetRepository().GetAdminInfoByAttendeeId(ctx, attendeeId)
+	adminInfo, err := database.GetRepositoryFor(ctx).GetAdminInfoByAttendeeId(ctx, attendeeId)
 	return adminInfo, err
 }
 
@@ -20,33 +21,40 @@ func (s *AttendeeServiceImplData) UpdateAdminInfo(ctx context.Context, attendee
 	// presence of attendeeId is checked in the controller
 	// controller has called GetAdminInfo before this, so we know ID is set
 
-	originalAdminInfo, err := s.GetAdminInfo(ctx, attendee.ID)
-	if err != nil {
-		return err
-	}
-
-	overrideDuesTransactionComment := "admin info change"
-	if originalAdminInfo.ManualDues != adminInfo.ManualDues {
-		overrideDuesTransactionComment = adminInfo.ManualDuesDescription
-	}
-
-	err = database.GetRepository().WriteAdminInfo(ctx, adminInfo)
-	if err != nil {
-		return err
-	}
-
-	statusHistory, err := s.GetFullStatusHistory(ctx, attendee)
-	if err != nil {
-		return err
-	}
-	currentStatus := statusHistory[len(statusHistory)-1].Status
-
-	// setting admin flags such as guest may change dues, and change status
-	subject := ctxvalues.Subject(ctx)
-	err = s.UpdateDuesAndDoStatusChangeIfNeeded(ctx, attendee, currentStatus, currentStatus, fmt.Sprintf("admin info update by %s", subject), overrideDuesTransactionComment, suppressMinorUpdateEmail, false)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return database.WithTransaction(ctx, func(ctx context.Context) error {
+		originalAdminInfo, err := s.GetAdminInfo(ctx, attendee.ID)
+		if err != nil {
+			return err
+		}
+
+		overrideDuesTransactionComment := "admin info change"
+		if originalAdminInfo.ManualDues != adminInfo.ManualDues {
+			overrideDuesTransactionComment = adminInfo.ManualDuesDescription
+		}
+
+		err = database.GetRepositoryFor(ctx).WriteAdminInfo(ctx, adminInfo)
+		if err != nil {
+			return err
+		}
+
+		err = s.emitWebhookEvent(ctx, webhook.Event{Event: webhook.EventAttendeeAdminInfoChanged, AttendeeId: attendee.ID})
+		if err != nil {
+			return err
+		}
+
+		statusHistory, err := s.GetFullStatusHistory(ctx, attendee)
+		if err != nil {
+			return err
+		}
+		currentStatus := statusHistory[len(statusHistory)-1].Status
+
+		// setting admin flags such as guest may change dues, and change status
+		subject := ctxvalues.Subject(ctx)
+		err = s.UpdateDuesAndDoStatusChangeIfNeeded(ctx, attendee, currentStatus, currentStatus, fmt.Sprintf("admin info update by %s", subject), overrideDuesTransactionComment, suppressMinorUpdateEmail, false)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
 }
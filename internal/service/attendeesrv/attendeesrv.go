@@ -7,8 +7,10 @@ import (
 	aulogging "github.com/StephanHCB/go-autumn-logging"
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/webhook"
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config/constraint"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
 	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
 	"sort"
@@ -53,7 +55,16 @@ func (s *AttendeeServiceImplData) RegisterNewAttendee(ctx context.Context, atten
 
 	attendee.Flags = s.setAutoFlags(ctx, attendee.Flags)
 
-	id, err := database.GetRepository().AddAttendee(ctx, attendee)
+	id, err := database.GetRepositoryFor(ctx).AddAttendee(ctx, attendee)
+	if err != nil {
+		return id, err
+	}
+
+	if err := s.lockPackagePrices(ctx, attendee); err != nil {
+		return id, err
+	}
+
+	err = s.emitWebhookEvent(ctx, webhook.Event{Event: webhook.EventAttendeeRegistered, AttendeeId: id})
 	return id, err
 }
 
@@ -71,46 +82,53 @@ func (s *AttendeeServiceImplData) setAutoFlags(ctx context.Context, flags string
 }
 
 func (s *AttendeeServiceImplData) GetAttendee(ctx context.Context, id uint) (*entity.Attendee, error) {
-	attendee, err := database.GetRepository().GetAttendeeById(ctx, id)
+	attendee, err := database.GetRepositoryFor(ctx).GetAttendeeById(ctx, id)
 	return attendee, err
 }
 
 func (s *AttendeeServiceImplData) UpdateAttendee(ctx context.Context, attendee *entity.Attendee, suppressMinorUpdateEmails bool) error {
-	alreadyExists, err := isDuplicateAttendee(ctx, attendee.Nickname, attendee.Zip, attendee.Email, 1)
-	if err != nil {
-		return err
-	}
-	if alreadyExists {
-		aulogging.Logger.Ctx(ctx).Warn().Printf("received update with registration duplicate - nick %s zip %s email %s", attendee.Nickname, attendee.Zip, attendee.Email)
-		return errors.New("your changes would lead to duplicate attendee data - same nickname, zip, email")
-	}
+	return database.WithTransaction(ctx, func(ctx context.Context) error {
+		alreadyExists, err := isDuplicateAttendee(ctx, attendee.Nickname, attendee.Zip, attendee.Email, 1)
+		if err != nil {
+			return err
+		}
+		if alreadyExists {
+			aulogging.Logger.Ctx(ctx).Warn().Printf("received update with registration duplicate - nick %s zip %s email %s", attendee.Nickname, attendee.Zip, attendee.Email)
+			return errors.New("your changes would lead to duplicate attendee data - same nickname, zip, email")
+		}
 
-	// TODO: verify permissions - after first payment, only admins can remove packages
+		// TODO: verify permissions - after first payment, only admins can remove packages
 
-	err = database.GetRepository().UpdateAttendee(ctx, attendee)
-	if err != nil {
-		return err
-	}
+		err = database.GetRepositoryFor(ctx).UpdateAttendee(ctx, attendee)
+		if err != nil {
+			return err
+		}
 
-	statusHistory, err := s.GetFullStatusHistory(ctx, attendee)
-	if err != nil {
-		return err
-	}
+		err = s.emitWebhookEvent(ctx, webhook.Event{Event: webhook.EventAttendeeUpdated, AttendeeId: attendee.ID})
+		if err != nil {
+			return err
+		}
+
+		statusHistory, err := s.GetFullStatusHistory(ctx, attendee)
+		if err != nil {
+			return err
+		}
 
-	currentStatus := statusHistory[len(statusHistory)-1].Status
+		currentStatus := statusHistory[len(statusHistory)-1].Status
 
-	subject := ctxvalues.Subject(ctx)
-	// changing packages may change the due amount
-	err = s.UpdateDuesAndDoStatusChangeIfNeeded(ctx, attendee, currentStatus, currentStatus, fmt.Sprintf("attendee update by %s", subject), "", suppressMinorUpdateEmails, false)
-	if err != nil {
-		return err
-	}
+		subject := ctxvalues.Subject(ctx)
+		// changing packages may change the due amount
+		err = s.UpdateDuesAndDoStatusChangeIfNeeded(ctx, attendee, currentStatus, currentStatus, fmt.Sprintf("attendee update by %s", subject), "", suppressMinorUpdateEmails, false)
+		if err != nil {
+			return err
+		}
 
-	return nil
+		return nil
+	})
 }
 
 func (s *AttendeeServiceImplData) GetAttendeeMaxId(ctx context.Context) (uint, error) {
-	max, err := database.GetRepository().MaxAttendeeId(ctx)
+	max, err := database.GetRepositoryFor(ctx).MaxAttendeeId(ctx)
 	return max, err
 }
 
@@ -142,31 +160,41 @@ func (s *AttendeeServiceImplData) CanChangeEmailTo(ctx context.Context, original
 	return errors.New("you can only use the email address you're logged in with")
 }
 
-func (s *AttendeeServiceImplData) CanChangeChoiceTo(ctx context.Context, what string, originalChoiceStr string, newChoiceStr string, configuration map[string]config.ChoiceConfig) error {
+func (s *AttendeeServiceImplData) CanChangeChoiceTo(ctx context.Context, what string, originalChoiceStr string, newChoiceStr string, configuration map[string]config.ChoiceConfig, newState *entity.Attendee, currentStatus status.Status) error {
 	originalChoicesMap := choiceStrToMap(originalChoiceStr, configuration)
 	newChoicesMap := choiceStrToMap(newChoiceStr, configuration)
-	return s.canChangeChoiceLowlevel(ctx, what, originalChoicesMap, newChoicesMap, configuration, "irrelevant")
+	facts := constraintFacts(newState, currentStatus)
+	return s.canChangeChoiceLowlevel(ctx, what, originalChoicesMap, newChoicesMap, configuration, "irrelevant", facts, billingCurrency(newState))
 }
 
-func (s *AttendeeServiceImplData) CanChangeChoiceToCurrentStatus(ctx context.Context, what string, originalChoice []attendee.PackageState, newChoice []attendee.PackageState, configuration map[string]config.ChoiceConfig, currentStatus status.Status) error {
+func (s *AttendeeServiceImplData) CanChangeChoiceToCurrentStatus(ctx context.Context, what string, originalChoice []attendee.PackageState, newChoice []attendee.PackageState, configuration map[string]config.ChoiceConfig, newState *entity.Attendee, currentStatus status.Status) error {
 	originalChoicesMap := choiceListToMap(originalChoice, configuration)
 	newChoicesMap := choiceListToMap(newChoice, configuration)
-	return s.canChangeChoiceLowlevel(ctx, what, originalChoicesMap, newChoicesMap, configuration, currentStatus)
+	facts := constraintFacts(newState, currentStatus)
+	return s.canChangeChoiceLowlevel(ctx, what, originalChoicesMap, newChoicesMap, configuration, currentStatus, facts, billingCurrency(newState))
 }
 
-func (s *AttendeeServiceImplData) canChangeChoiceLowlevel(ctx context.Context, what string, originalChoices map[string]int, newChoices map[string]int, configuration map[string]config.ChoiceConfig, currentStatus status.Status) error {
+func (s *AttendeeServiceImplData) canChangeChoiceLowlevel(ctx context.Context, what string, originalChoices map[string]int, newChoices map[string]int, configuration map[string]config.ChoiceConfig, currentStatus status.Status, facts *constraint.Facts, currency string) error {
+	category := constraintCategory(what)
+	facts.Choices[category] = newChoices
+
 	oneIsMandatory := false
 	satisfiesOneIsMandatory := false
 	mandatoryList := make([]string, 0)
 	for k, v := range configuration {
-		if err := checkNoForbiddenChanges(ctx, what, k, v, originalChoices, newChoices); err != nil {
+		if err := checkNoForbiddenChanges(ctx, what, k, v, category, originalChoices, newChoices, facts); err != nil {
 			return err
 		}
-		if err := checkNoConstraintViolation(k, v, newChoices); err != nil {
+		if err := checkNoConstraintViolation(k, v, category, facts); err != nil {
 			return err
 		}
+		if category == constraint.Packages {
+			if err := checkAgeEligibility(k, v, newChoices, facts); err != nil {
+				return err
+			}
+		}
 		if currentStatus != "irrelevant" {
-			if err := checkNoForbiddenChangesAfterPayment(ctx, what, k, v, configuration, originalChoices, newChoices, currentStatus); err != nil {
+			if err := checkNoForbiddenChangesAfterPayment(ctx, what, k, v, configuration, originalChoices, newChoices, currentStatus, facts.Birthday, currency); err != nil {
 				return err
 			}
 		}
@@ -211,7 +239,7 @@ func (s *AttendeeServiceImplData) CanRegisterAtThisTime(ctx context.Context) err
 }
 
 func isDuplicateAttendee(ctx context.Context, nickname string, zip string, email string, expectedCountMax int64) (bool, error) {
-	count, err := database.GetRepository().CountAttendeesByNicknameZipEmail(ctx, nickname, zip, email)
+	count, err := database.GetRepositoryFor(ctx).CountAttendeesByNicknameZipEmail(ctx, nickname, zip, email)
 	if err != nil {
 		return false, err
 	}
@@ -223,19 +251,19 @@ func userAlreadyHasAnotherRegistration(ctx context.Context, identity string, exp
 		return false, nil
 	}
 
-	count, err := database.GetRepository().CountAttendeesByIdentity(ctx, identity)
+	count, err := database.GetRepositoryFor(ctx).CountAttendeesByIdentity(ctx, identity)
 	if err != nil {
 		return false, err
 	}
 	return count != expectedCount, nil
 }
 
-func checkNoForbiddenChanges(ctx context.Context, what string, key string, choiceConfig config.ChoiceConfig, originalChoices map[string]int, newChoices map[string]int) error {
+func checkNoForbiddenChanges(ctx context.Context, what string, key string, choiceConfig config.ChoiceConfig, category string, originalChoices map[string]int, newChoices map[string]int, facts *constraint.Facts) error {
 	if originalChoices[key] != newChoices[key] {
 		// tolerate removing a read-only choice that has a constraint that forbids it anyway
 		if choiceConfig.ReadOnly {
 			if originalChoices[key] > 0 && newChoices[key] == 0 {
-				if canAllowRemovalDueToConstraint(ctx, what, key, choiceConfig, originalChoices, newChoices) {
+				if canAllowRemovalDueToConstraint(ctx, what, key, choiceConfig, category, facts) {
 					return nil
 				}
 			}
@@ -249,32 +277,31 @@ func checkNoForbiddenChanges(ctx context.Context, what string, key string, choic
 	return nil
 }
 
-func canAllowRemovalDueToConstraint(ctx context.Context, what string, key string, choiceConfig config.ChoiceConfig, originalChoices map[string]int, newChoices map[string]int) bool {
+func canAllowRemovalDueToConstraint(ctx context.Context, what string, key string, choiceConfig config.ChoiceConfig, category string, facts *constraint.Facts) bool {
 	if choiceConfig.Constraint != "" {
-		constraints := strings.Split(choiceConfig.Constraint, ",")
-		for _, cn := range constraints {
-			constraintK := cn
-			if strings.HasPrefix(cn, "!") {
-				constraintK = strings.TrimPrefix(cn, "!")
-				if newChoices[constraintK] > 0 {
-					aulogging.Logger.Ctx(ctx).Info().Printf("can allow removal of read only %s %s - it would violate a constraint for %s anyway", what, key, constraintK)
-					return true
-				}
-			}
+		expr, err := constraint.Parse(choiceConfig.Constraint, category)
+		if err != nil {
+			return false
+		}
+		// only if the constraint forbids something that was picked, a missing requirement is no excuse
+		if violation, ok := constraint.Violation(expr, facts).(*constraint.Not); ok {
+			aulogging.Logger.Ctx(ctx).Info().Printf("can allow removal of read only %s %s - it would violate a constraint for %s anyway", what, key, violation.Operand.String())
+			return true
 		}
 	}
 	return false
 }
 
-func checkNoForbiddenChangesAfterPayment(ctx context.Context, what string, key string, choiceConfig config.ChoiceConfig, configuration map[string]config.ChoiceConfig, originalChoices map[string]int, newChoices map[string]int, currentStatus status.Status) error {
+func checkNoForbiddenChangesAfterPayment(ctx context.Context, what string, key string, choiceConfig config.ChoiceConfig, configuration map[string]config.ChoiceConfig, originalChoices map[string]int, newChoices map[string]int, currentStatus status.Status, birthday string, currency string) error {
 	if ctxvalues.HasApiToken(ctx) || ctxvalues.IsAuthorizedAsGroup(ctx, config.OidcAdminGroup()) {
 		return nil
 	}
 
 	if currentStatus == status.PartiallyPaid || currentStatus == status.Paid || currentStatus == status.CheckedIn {
-		if originalChoices[key] > 0 && newChoices[key] == 0 && choiceConfig.Price > 0 {
-			oldDues := calcTotalDuesHelper(configuration, originalChoices)
-			newDues := calcTotalDuesHelper(configuration, newChoices)
+		price, _ := packagePrice(choiceConfig, birthday, config.AgeReferenceDate(), currency)
+		if originalChoices[key] > 0 && newChoices[key] == 0 && price > 0 {
+			oldDues := calcTotalDuesHelper(configuration, originalChoices, birthday, currency)
+			newDues := calcTotalDuesHelper(configuration, newChoices, birthday, currency)
 
 			if newDues < oldDues {
 				return fmt.Errorf("deselect of %s %s after payment leads to dues reduction - only an admin can do that at this time", what, key)
@@ -285,36 +312,91 @@ func checkNoForbiddenChangesAfterPayment(ctx context.Context, what string, key s
 	return nil
 }
 
-func calcTotalDuesHelper(configuration map[string]config.ChoiceConfig, choices map[string]int) (dues int64) {
+func calcTotalDuesHelper(configuration map[string]config.ChoiceConfig, choices map[string]int, birthday string, currency string) (dues int64) {
 	for k, count := range choices {
 		choiceConfig, ok := configuration[k]
 		if ok && count > 0 {
-			dues += choiceConfig.Price * int64(count)
+			price, _ := packagePrice(choiceConfig, birthday, config.AgeReferenceDate(), currency)
+			dues += price * int64(count)
 		}
 	}
 	return dues
 }
 
-func checkNoConstraintViolation(key string, choiceConfig config.ChoiceConfig, newChoices map[string]int) error {
-	if choiceConfig.Constraint != "" {
-		constraints := strings.Split(choiceConfig.Constraint, ",")
-		for _, cn := range constraints {
-			constraintK := cn
-			if strings.HasPrefix(cn, "!") {
-				constraintK = strings.TrimPrefix(cn, "!")
-				if newChoices[key] > 0 && newChoices[constraintK] > 0 {
-					return errors.New("cannot pick both " + key + " and " + constraintK + " - constraint violated")
-				}
-			} else {
-				if newChoices[key] > 0 && newChoices[constraintK] == 0 {
-					return errors.New("when picking " + key + ", must also pick " + constraintK + " - constraint violated")
-				}
+func checkAgeEligibility(key string, choiceConfig config.ChoiceConfig, newChoices map[string]int, facts *constraint.Facts) error {
+	if newChoices[key] == 0 || (choiceConfig.MinAge == 0 && choiceConfig.MaxAge == 0) {
+		return nil
+	}
+
+	var ageRange string
+	switch {
+	case choiceConfig.MaxAge == 0:
+		ageRange = fmt.Sprintf("aged %d or older", choiceConfig.MinAge)
+	case choiceConfig.MinAge == 0:
+		ageRange = fmt.Sprintf("aged %d or younger", choiceConfig.MaxAge)
+	default:
+		ageRange = fmt.Sprintf("aged %d to %d", choiceConfig.MinAge, choiceConfig.MaxAge)
+	}
+
+	age, ok := constraint.AgeAt(facts.Birthday, facts.AgeReferenceDate)
+	if !ok || age < choiceConfig.MinAge || (choiceConfig.MaxAge > 0 && age > choiceConfig.MaxAge) {
+		return fmt.Errorf("cannot pick package %s: only available to attendees %s on %s", key, ageRange, facts.AgeReferenceDate)
+	}
+	return nil
+}
+
+func checkNoConstraintViolation(key string, choiceConfig config.ChoiceConfig, category string, facts *constraint.Facts) error {
+	if choiceConfig.Constraint != "" && facts.Choices[category][key] > 0 {
+		expr, err := constraint.Parse(choiceConfig.Constraint, category)
+		if err != nil {
+			// cannot happen, constraints are validated when the configuration is loaded
+			return err
+		}
+		switch violation := constraint.Violation(expr, facts).(type) {
+		case nil:
+			return nil
+		case *constraint.Choice:
+			if violation.Category == category && violation.Count == 1 {
+				return errors.New("when picking " + key + ", must also pick " + violation.Key + " - constraint violated")
+			}
+		case *constraint.Not:
+			if forbidden, ok := violation.Operand.(*constraint.Choice); ok && forbidden.Category == category && forbidden.Count == 1 {
+				return errors.New("cannot pick both " + key + " and " + forbidden.Key + " - constraint violated")
 			}
 		}
+		return errors.New("cannot pick " + key + ": " + choiceConfig.ConstraintMsg + " - constraint violated")
 	}
 	return nil
 }
 
+// constraintCategory maps what is being changed to the constraint category it belongs to.
+func constraintCategory(what string) string {
+	switch what {
+	case "package":
+		return constraint.Packages
+	case "option":
+		return constraint.Options
+	default:
+		return constraint.Flags
+	}
+}
+
+// constraintFacts collects what constraints may refer to from the attendee as it will be after the change.
+//
+// Admin only flags are not included, they can only be referenced by constraints on other admin only flags.
+func constraintFacts(a *entity.Attendee, currentStatus status.Status) *constraint.Facts {
+	return &constraint.Facts{
+		Choices: map[string]map[string]int{
+			constraint.Flags:    choiceStrToMap(a.Flags, config.FlagsConfigNoAdmin()),
+			constraint.Packages: choiceStrToMap(a.Packages, config.PackagesConfig()),
+			constraint.Options:  choiceStrToMap(a.Options, config.OptionsConfig()),
+		},
+		Birthday:         a.Birthday,
+		AgeReferenceDate: config.AgeReferenceDate(),
+		Status:           string(currentStatus),
+	}
+}
+
 // choiceStrToMap converts a choice representation in the entity to a map of counts
 //
 // Can be used for packages, flags, options.
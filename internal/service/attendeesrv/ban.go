@@ -31,7 +31,7 @@ func (s *AttendeeServiceImplData) CreateBan(ctx context.Context, ban *entity.Ban
 		return 0, DuplicateBanError
 	}
 
-	id, err := database.GetRepository().AddBan(ctx, ban)
+	id, err := database.GetRepositoryFor(ctx).AddBan(ctx, ban)
 	return id, err
 }
 
@@ -50,7 +50,7 @@ func (s *AttendeeServiceImplData) UpdateBan(ctx context.Context, ban *entity.Ban
 		return DuplicateBanError
 	}
 
-	err = database.GetRepository().UpdateBan(ctx, ban)
+	err = database.GetRepositoryFor(ctx).UpdateBan(ctx, ban)
 	return err
 }
 
@@ -60,20 +60,20 @@ func (s *AttendeeServiceImplData) DeleteBan(ctx context.Context, ban *entity.Ban
 		return errors.New("cannot delete ban rule without assigned id - this is a program error")
 	}
 
-	err := database.GetRepository().DeleteBan(ctx, ban)
+	err := database.GetRepositoryFor(ctx).DeleteBan(ctx, ban)
 	return err
 }
 
 func (s *AttendeeServiceImplData) GetBan(ctx context.Context, id uint) (*entity.Ban, error) {
-	return database.GetRepository().GetBanById(ctx, id)
+	return database.GetRepositoryFor(ctx).GetBanById(ctx, id)
 }
 
 func (s *AttendeeServiceImplData) GetAllBans(ctx context.Context) ([]*entity.Ban, error) {
-	return database.GetRepository().GetAllBans(ctx)
+	return database.GetRepositoryFor(ctx).GetAllBans(ctx)
 }
 
 func isDuplicateBan(ctx context.Context, ban *entity.Ban) (bool, error) {
-	currentBans, err := database.GetRepository().GetAllBans(ctx)
+	currentBans, err := database.GetRepositoryFor(ctx).GetAllBans(ctx)
 	if err != nil {
 		return false, err
 	}
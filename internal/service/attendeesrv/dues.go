@@ -10,19 +10,26 @@ import (
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config/constraint"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
 	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 func (s *AttendeeServiceImplData) UpdateDuesTransactions(ctx context.Context, attendee *entity.Attendee, newStatus status.Status, commentOverride string) ([]paymentservice.Transaction, *entity.AdminInfo, error) {
-	adminInfo, err := database.GetRepository().GetAdminInfoByAttendeeId(ctx, attendee.ID)
+	adminInfo, err := database.GetRepositoryFor(ctx).GetAdminInfoByAttendeeId(ctx, attendee.ID)
 	if err != nil {
 		return []paymentservice.Transaction{}, adminInfo, err
 	}
 
+	// all changes of packages pass through here, so this is where newly booked packages get their price locked
+	if err := s.lockPackagePrices(ctx, attendee); err != nil {
+		return []paymentservice.Transaction{}, adminInfo, err
+	}
+
 	transactionHistory, err := paymentservice.Get().GetTransactions(ctx, attendee.ID)
 	if err != nil && !errors.Is(err, paymentservice.NoSuchDebitor404Error) {
 		return []paymentservice.Transaction{}, adminInfo, err
@@ -77,7 +84,10 @@ func (s *AttendeeServiceImplData) compensateAllDues(ctx context.Context, attende
 }
 
 func (s *AttendeeServiceImplData) compensateUnpaidDuesOnCancel(ctx context.Context, attendee *entity.Attendee, adminInfo *entity.AdminInfo, transactionHistory []paymentservice.Transaction) (bool, error) {
-	_, paid, _, _ := s.balances(transactionHistory)
+	_, paid, _, _, err := s.balances(billingCurrency(attendee), transactionHistory)
+	if err != nil {
+		return false, err
+	}
 	paid += s.pseudoPaymentsFromNegativeDues(transactionHistory)
 	updated := false
 
@@ -114,8 +124,12 @@ func (s *AttendeeServiceImplData) compensateUnpaidDuesOnCancel(ctx context.Conte
 }
 
 func (s *AttendeeServiceImplData) adjustDuesAccordingToSelectedPackages(ctx context.Context, attendee *entity.Attendee, adminInfo *entity.AdminInfo, transactionHistory []paymentservice.Transaction, commentOverride string) (bool, error) {
-	oldDuesByVAT := s.oldDuesByVAT(transactionHistory)
-	packageDuesByVAT := s.packageDuesByVAT(ctx, attendee, adminInfo)
+	packageTransactions, voucherTransactions := splitDuesTransactions(transactionHistory)
+	oldDuesByVAT := s.oldDuesByVAT(packageTransactions)
+	packageDuesByVAT, agePrices, err := s.packageDuesByVAT(ctx, attendee, adminInfo)
+	if err != nil {
+		return false, err
+	}
 	updated := false
 
 	// add missing keys to packageDuesByVAT, so we can just iterate over it and not miss any tax rates
@@ -127,6 +141,9 @@ func (s *AttendeeServiceImplData) adjustDuesAccordingToSelectedPackages(ctx cont
 	}
 
 	comment := "dues adjustment due to change in status or selected packages"
+	if len(agePrices) > 0 {
+		comment += " - age based prices: " + strings.Join(agePrices, ", ")
+	}
 	if commentOverride != "" {
 		comment = commentOverride
 	}
@@ -143,10 +160,122 @@ func (s *AttendeeServiceImplData) adjustDuesAccordingToSelectedPackages(ctx cont
 		}
 	}
 
+	voucherUpdated, err := s.adjustVoucherDiscounts(ctx, attendee, adminInfo, voucherTransactions)
+	return updated || voucherUpdated, err
+}
+
+// adjustVoucherDiscounts books the discount of each redeemed voucher as separate dues transactions, so
+// the discounts remain recognizable. Discounts of vouchers that are no longer redeemed are taken back.
+func (s *AttendeeServiceImplData) adjustVoucherDiscounts(ctx context.Context, attendee *entity.Attendee, adminInfo *entity.AdminInfo, voucherTransactions map[string][]paymentservice.Transaction) (bool, error) {
+	vouchers, err := s.GetRedeemedVouchers(ctx, attendee.ID)
+	if err != nil {
+		return false, err
+	}
+	charges := make([]packageCharge, 0)
+	if !s.considerGuest(ctx, adminInfo) {
+		// guests pay nothing for packages, so there is nothing to discount
+		charges, _, err = s.packageCharges(ctx, attendee)
+		if err != nil {
+			return false, err
+		}
+	}
+	// fixed amounts in another currency cannot be applied, so their discount is taken back
+	applicable := make([]*entity.Voucher, 0, len(vouchers))
+	for _, v := range vouchers {
+		if v.Kind != entity.VoucherKindFixed || voucherCurrency(v) == billingCurrency(attendee) {
+			applicable = append(applicable, v)
+		}
+	}
+	discounts := voucherDiscounts(applicable, charges)
+
+	comments := make(map[string]string)
+	codes := make([]string, 0)
+	for _, v := range vouchers {
+		comments[v.Code] = fmt.Sprintf("discount for voucher %s: %s", v.Code, v.Description)
+		codes = append(codes, v.Code)
+	}
+	removedCodes := make([]string, 0)
+	for code := range voucherTransactions {
+		if _, ok := comments[code]; !ok {
+			comments[code] = fmt.Sprintf("discount for voucher %s removed", code)
+			removedCodes = append(removedCodes, code)
+		}
+	}
+	sort.Strings(removedCodes)
+	codes = append(codes, removedCodes...)
+
+	updated := false
+	for _, code := range codes {
+		oldDiscountByVAT := s.oldDuesByVAT(voucherTransactions[code])
+		desiredDiscountByVAT := discounts[code]
+		vatStrs := make([]string, 0)
+		for vatStr := range oldDiscountByVAT {
+			vatStrs = append(vatStrs, vatStr)
+		}
+		for vatStr := range desiredDiscountByVAT {
+			if _, ok := oldDiscountByVAT[vatStr]; !ok {
+				vatStrs = append(vatStrs, vatStr)
+			}
+		}
+		sort.Strings(vatStrs)
+
+		for _, vatStr := range vatStrs {
+			currentBalance := oldDiscountByVAT[vatStr]
+			desiredBalance := desiredDiscountByVAT[vatStr]
+			if currentBalance != desiredBalance {
+				updated = true
+				diffTx := s.duesTransactionForAttendee(attendee, adminInfo, desiredBalance-currentBalance, vatStr, comments[code])
+				diffTx.Reason = s.duesReasonWithVoucher(attendee, adminInfo, code)
+				err := paymentservice.Get().AddTransaction(ctx, diffTx)
+				if err != nil {
+					return updated, err
+				}
+			}
+		}
+	}
 	return updated, nil
 }
 
-func (s *AttendeeServiceImplData) packageDuesByVAT(ctx context.Context, attendee *entity.Attendee, adminInfo *entity.AdminInfo) map[string]int64 {
+// voucherDiscounts applies the vouchers in the order they were redeemed, each one to what is left of the package
+// prices after the vouchers before it. Returns the discount of each voucher by code and vat rate, as a negative amount.
+//
+// Percentages are rounded down to the cent. Fixed amounts without a package are applied to the packages
+// in order of their keys, and are lost where they exceed the package prices.
+func voucherDiscounts(vouchers []*entity.Voucher, charges []packageCharge) map[string]map[string]int64 {
+	remaining := make([]int64, len(charges))
+	for i, c := range charges {
+		remaining[i] = c.Amount
+	}
+
+	result := make(map[string]map[string]int64)
+	for _, v := range vouchers {
+		discountByVAT := make(map[string]int64)
+		budget := v.Amount
+		for i, c := range charges {
+			if v.Package != "" && v.Package != c.Key {
+				continue
+			}
+			var discount int64
+			if v.Kind == entity.VoucherKindPercent {
+				discount = remaining[i] * v.Amount / 100
+			} else {
+				discount = min(budget, remaining[i])
+				budget -= discount
+			}
+			if discount > 0 {
+				remaining[i] -= discount
+				discountByVAT[c.VatStr] -= discount
+			}
+		}
+		result[v.Code] = discountByVAT
+	}
+	return result
+}
+
+// packageDuesByVAT calculates the dues the attendee should have before voucher discounts, by vat rate.
+//
+// Also returns a description of each age based price that was applied, sorted by package key.
+func (s *AttendeeServiceImplData) packageDuesByVAT(ctx context.Context, attendee *entity.Attendee, adminInfo *entity.AdminInfo) (map[string]int64, []string, error) {
 	result := make(map[string]int64)
 
 	// consider manual dues before guest status (they might be due a refund from last year, or something)
@@ -157,24 +286,159 @@ func (s *AttendeeServiceImplData) packageDuesByVAT(ctx context.Context, attendee
 
 	if s.considerGuest(ctx, adminInfo) {
 		// guests pay nothing for ANY normal packages
-		return result
+		return result, make([]string, 0), nil
+	}
+
+	charges, agePrices, err := s.packageCharges(ctx, attendee)
+	if err != nil {
+		return result, agePrices, err
+	}
+	for _, c := range charges {
+		previous, _ := result[c.VatStr]
+		result[c.VatStr] = previous + c.Amount
+	}
+	return result, agePrices, nil
+}
+
+// packageCharge is what the attendee should pay for one of their packages, taking its count into account.
+type packageCharge struct {
+	Key    string
+	VatStr string
+	Amount int64
+}
+
+// packageCharges calculates what the attendee should pay for each of their packages, sorted by package key.
+//
+// Also returns a description of each age based price that was applied.
+func (s *AttendeeServiceImplData) packageCharges(ctx context.Context, attendee *entity.Attendee) ([]packageCharge, []string, error) {
+	result := make([]packageCharge, 0)
+	agePrices := make([]string, 0)
+
+	lockedPrices, err := s.lockedPackagePrices(ctx, attendee)
+	if err != nil {
+		return result, agePrices, err
 	}
 
 	packageConfigs := config.PackagesConfig()
-	for key, count := range choiceStrToMap(attendee.Packages, packageConfigs) {
+	packageCounts := choiceStrToMap(attendee.Packages, packageConfigs)
+	keys := make([]string, 0, len(packageCounts))
+	for key := range packageCounts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		count := packageCounts[key]
 		if count > 0 {
 			packageConfig, ok := packageConfigs[key]
 			if !ok {
 				aulogging.Logger.Ctx(ctx).Warn().Printf("attendee id %d has unknown package %s in db - ignoring during dues calculation", attendee.ID, key)
 			} else {
-				vatStr := fmt.Sprintf("%.6f", packageConfig.VatPercent)
+				currency := billingCurrency(attendee)
+				price, tier := packagePrice(packageConfig, attendee.Birthday, config.AgeReferenceDate(), currency)
+				if tier != nil {
+					agePrices = append(agePrices, fmt.Sprintf("%s: %s", packageConfig.Description, tier.Description))
+				} else if locked, ok := lockedPrices[key]; ok {
+					price = locked.Price
+				} else {
+					price = scheduledPrice(packageConfig, s.Now().Format(config.IsoDateFormat), currency)
+				}
 
-				previous, _ := result[vatStr]
-				result[vatStr] = previous + packageConfig.Price*int64(count)
+				result = append(result, packageCharge{
+					Key:    key,
+					VatStr: fmt.Sprintf("%.6f", packageConfig.VatPercent),
+					Amount: price * int64(count),
+				})
 			}
 		}
 	}
-	return result
+	return result, agePrices, nil
+}
+
+// packagePrice returns the price of a package in currency for an attendee born on birthday.
+//
+// The first age tier that matches the age at ageReferenceDate wins. If none matches, or the age cannot be determined,
+// the regular price applies, and the returned tier is nil.
+func packagePrice(packageConfig config.ChoiceConfig, birthday string, ageReferenceDate string, currency string) (int64, *config.AgePriceConfig) {
+	age, ok := constraint.AgeAt(birthday, ageReferenceDate)
+	if !ok {
+		return priceIn(currency, packageConfig.Price, packageConfig.Prices), nil
+	}
+	for i := range packageConfig.AgePrices {
+		tier := &packageConfig.AgePrices[i]
+		if age >= tier.MinAge && (tier.MaxAge == 0 || age <= tier.MaxAge) {
+			return priceIn(currency, tier.Price, tier.Prices), tier
+		}
+	}
+	return priceIn(currency, packageConfig.Price, packageConfig.Prices), nil
+}
+
+// scheduledPrice returns the price of a package in currency for a booking made on date, according to its price schedule.
+func scheduledPrice(packageConfig config.ChoiceConfig, date string, currency string) int64 {
+	price := priceIn(currency, packageConfig.Price, packageConfig.Prices)
+	for _, entry := range packageConfig.PriceSchedule {
+		if entry.From <= date {
+			price = priceIn(currency, entry.Price, entry.Prices)
+		}
+	}
+	return price
+}
+
+// priceIn picks the price for currency. prices only lists the additional currencies, so the default currency
+// gets the regular price.
+func priceIn(currency string, price int64, prices map[string]int64) int64 {
+	if p, ok := prices[currency]; ok {
+		return p
+	}
+	return price
+}
+
+func (s *AttendeeServiceImplData) lockedPackagePrices(ctx context.Context, attendee *entity.Attendee) (map[string]*entity.PackagePrice, error) {
+	result := make(map[string]*entity.PackagePrice)
+	if attendee.ID == 0 {
+		// not yet saved, so nothing can have been locked
+		return result, nil
+	}
+	prices, err := database.GetRepositoryFor(ctx).GetPackagePricesByAttendeeId(ctx, attendee.ID)
+	if err != nil {
+		return result, err
+	}
+	for _, p := range prices {
+		result[p.Package] = p
+	}
+	return result, nil
+}
+
+// lockPackagePrices records the current scheduled price for every booked package with a price schedule
+// that does not have a locked price yet.
+func (s *AttendeeServiceImplData) lockPackagePrices(ctx context.Context, attendee *entity.Attendee) error {
+	lockedPrices, err := s.lockedPackagePrices(ctx, attendee)
+	if err != nil {
+		return err
+	}
+
+	today := s.Now().Format(config.IsoDateFormat)
+	packageConfigs := config.PackagesConfig()
+	for key, count := range choiceStrToMap(attendee.Packages, packageConfigs) {
+		packageConfig, ok := packageConfigs[key]
+		if !ok || count == 0 || len(packageConfig.PriceSchedule) == 0 {
+			continue
+		}
+		if _, alreadyLocked := lockedPrices[key]; alreadyLocked {
+			continue
+		}
+
+		lock := &entity.PackagePrice{
+			AttendeeId: attendee.ID,
+			Package:    key,
+			BookedOn:   today,
+			Price:      scheduledPrice(packageConfig, today, billingCurrency(attendee)),
+		}
+		if _, err := database.GetRepositoryFor(ctx).AddPackagePrice(ctx, lock); err != nil {
+			return err
+		}
+		aulogging.Logger.Ctx(ctx).Info().Printf("locked price of package %s for attendee id %d at %d", key, attendee.ID, lock.Price)
+	}
+	return nil
 }
 
 func (s *AttendeeServiceImplData) considerGuest(ctx context.Context, adminInfo *entity.AdminInfo) bool {
@@ -186,6 +450,26 @@ func (s *AttendeeServiceImplData) considerGuest(ctx context.Context, adminInfo *
 	return isGuest > 0
 }
 
+// splitDuesTransactions separates the dues transactions for voucher discounts from all other transactions,
+// and groups them by voucher code.
+//
+// Write-offs are left out entirely. They are never adjusted, only wiped together with all other dues.
+func splitDuesTransactions(transactionHistory []paymentservice.Transaction) ([]paymentservice.Transaction, map[string][]paymentservice.Transaction) {
+	others := make([]paymentservice.Transaction, 0)
+	byVoucher := make(map[string][]paymentservice.Transaction)
+	for _, tx := range transactionHistory {
+		reason := DuesReason{}
+		if tx.TransactionType == paymentservice.Due && json.Unmarshal([]byte(tx.Reason), &reason) == nil && (reason.Voucher != "" || reason.WriteOff) {
+			if !reason.WriteOff {
+				byVoucher[reason.Voucher] = append(byVoucher[reason.Voucher], tx)
+			}
+		} else {
+			others = append(others, tx)
+		}
+	}
+	return others, byVoucher
+}
+
 func (s *AttendeeServiceImplData) oldDuesByVAT(transactionHistory []paymentservice.Transaction) map[string]int64 {
 	oldDuesByVAT := make(map[string]int64)
 	for _, tx := range transactionHistory {
@@ -210,7 +494,19 @@ func (s *AttendeeServiceImplData) UpdateAttendeeCacheAndCalculateResultingStatus
 
 	avatar := s.avatarIfMatchingUser(ctx, identity)
 
-	dues, payments, open, dueDate := s.balances(updatedTransactionHistory)
+	currency := billingCurrency(attendee)
+	dues, payments, open, dueDate, err := s.balances(currency, updatedTransactionHistory)
+	if err != nil {
+		return newStatus, false, err
+	}
+	schedule, err := s.installmentSchedule(ctx, attendee, dues, dueDate)
+	if err != nil {
+		return newStatus, false, err
+	}
+	if installmentDate, ok := installmentDueDate(schedule, payments); ok {
+		// with a payment plan, only the first installment that is not fully paid is due
+		dueDate = installmentDate
+	}
 	// never move due date back in time (allows manual override)
 	if attendee.CacheDueDate != "" && attendee.CacheDueDate > dueDate {
 		dueDate = attendee.CacheDueDate
@@ -223,7 +519,7 @@ func (s *AttendeeServiceImplData) UpdateAttendeeCacheAndCalculateResultingStatus
 
 	if newStatus == status.Approved || newStatus == status.PartiallyPaid || newStatus == status.Paid {
 		// we do not adjust status back once checked in
-		newStatus = s.calculateResultingStatusForApprovedToPaid(payments, dues)
+		newStatus = s.calculateResultingStatusForApprovedToPaid(payments, dues, paymentTolerance(config.PaymentTolerance(), currency, updatedTransactionHistory, dues), schedule)
 	}
 
 	return newStatus, duesInformationChanged, nil
@@ -270,13 +566,13 @@ func (s *AttendeeServiceImplData) updateCachedValuesAndIdentityInAttendee(ctx co
 		if avatar != "" {
 			attendee.Avatar = avatar
 		}
-		err := database.GetRepository().UpdateAttendee(ctx, attendee)
+		err := database.GetRepositoryFor(ctx).UpdateAttendee(ctx, attendee)
 		return duesRelevantUpdate, err
 	}
 	return duesRelevantUpdate, nil
 }
 
-func (s *AttendeeServiceImplData) calculateResultingStatusForApprovedToPaid(payments int64, dues int64) status.Status {
+func (s *AttendeeServiceImplData) calculateResultingStatusForApprovedToPaid(payments int64, dues int64, tolerance int64, schedule []installment) status.Status {
 	if payments <= 0 {
 		if dues > 0 {
 			return status.Approved
@@ -285,7 +581,10 @@ func (s *AttendeeServiceImplData) calculateResultingStatusForApprovedToPaid(paym
 			return status.Paid
 		}
 	} else {
-		if payments < dues-graceAmountCents {
+		if len(schedule) > 0 && payments < schedule[0].Amount-tolerance {
+			// with a payment plan, the registration only counts as partially paid once the first installment is paid
+			return status.Approved
+		} else if payments < dues-tolerance {
 			return status.PartiallyPaid
 		} else {
 			return status.Paid
@@ -293,8 +592,16 @@ func (s *AttendeeServiceImplData) calculateResultingStatusForApprovedToPaid(paym
 	}
 }
 
-func (s *AttendeeServiceImplData) balances(transactionHistory []paymentservice.Transaction) (validDues int64, validPayments int64, openPayments int64, dueDate string) {
+// balances sums up the transactions, which must all be in currency. Deleted transactions are ignored.
+//
+// Returns MixedCurrenciesError if a transaction that is not deleted is in a different currency, because amounts
+// in different currencies cannot be added up.
+func (s *AttendeeServiceImplData) balances(currency string, transactionHistory []paymentservice.Transaction) (validDues int64, validPayments int64, openPayments int64, dueDate string, err error) {
 	for _, tx := range transactionHistory {
+		if tx.Status != paymentservice.Deleted && tx.Amount.Currency != currency {
+			err = fmt.Errorf("%w: found %s, expected %s", MixedCurrenciesError, tx.Amount.Currency, currency)
+			return
+		}
 		if tx.Status == paymentservice.Valid {
 			if tx.TransactionType == paymentservice.Payment {
 				validPayments += tx.Amount.GrossCent
@@ -349,7 +656,7 @@ func (s *AttendeeServiceImplData) duesTransactionForAttendee(attendee *entity.At
 		TransactionType: paymentservice.Due,
 		Method:          paymentservice.Internal,
 		Amount: paymentservice.Amount{
-			Currency:  config.Currency(),
+			Currency:  billingCurrency(attendee),
 			GrossCent: amount,
 			VatRate:   vat,
 		},
@@ -370,9 +677,21 @@ type DuesReason struct {
 	Packages   []attendee.PackageState `json:"packages_list"`
 	ManualDues map[string]ManualDues   `json:"manual_dues"`
 	Error      bool                    `json:"error,omitempty"`
+	Voucher    string                  `json:"voucher,omitempty"`   // only set for voucher discounts
+	WriteOff   bool                    `json:"write_off,omitempty"` // only set for write-offs within the payment tolerance
 }
 
 func (s *AttendeeServiceImplData) duesReason(attendee *entity.Attendee, adminInfo *entity.AdminInfo) string {
+	return encodeDuesReason(s.duesReasonData(attendee, adminInfo))
+}
+
+func (s *AttendeeServiceImplData) duesReasonWithVoucher(attendee *entity.Attendee, adminInfo *entity.AdminInfo, voucherCode string) string {
+	reason := s.duesReasonData(attendee, adminInfo)
+	reason.Voucher = voucherCode
+	return encodeDuesReason(reason)
+}
+
+func (s *AttendeeServiceImplData) duesReasonData(attendee *entity.Attendee, adminInfo *entity.AdminInfo) DuesReason {
 	manualDues := make(map[string]ManualDues)
 	if adminInfo.ManualDues != 0 {
 		manualDues["admin"] = ManualDues{
@@ -381,11 +700,13 @@ func (s *AttendeeServiceImplData) duesReason(attendee *entity.Attendee, adminInf
 		}
 	}
 
-	reason := DuesReason{
+	return DuesReason{
 		Packages:   sortedPackageListFromCommaSeparatedWithCounts(attendee.Packages),
 		ManualDues: manualDues,
 	}
+}
 
+func encodeDuesReason(reason DuesReason) string {
 	reasonBytes, err := json.Marshal(reason)
 	if err != nil {
 		// not really a problem
@@ -396,6 +717,22 @@ func (s *AttendeeServiceImplData) duesReason(attendee *entity.Attendee, adminInf
 	return string(reasonBytes)
 }
 
+// billingCurrency is the currency the attendee pays in.
+func billingCurrency(attendee *entity.Attendee) string {
+	if attendee.Currency == "" {
+		return config.Currency()
+	}
+	return attendee.Currency
+}
+
+// voucherCurrency is the currency of a fixed amount voucher.
+func voucherCurrency(voucher *entity.Voucher) string {
+	if voucher.Currency == "" {
+		return config.Currency()
+	}
+	return voucher.Currency
+}
+
 func (s *AttendeeServiceImplData) duesEffectiveDate() string {
 	return s.Now().Format(config.IsoDateFormat)
 }
@@ -2,6 +2,8 @@ package attendeesrv
 
 import (
 	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
 	"github.com/stretchr/testify/require"
 	"testing"
@@ -66,6 +68,22 @@ func TestBalances_LongerChain(t *testing.T) {
 	tstBalancesTestcase(t, txs, 40000, 25000, 15000, "2023-02-15")
 }
 
+func TestBalances_MixedCurrencies(t *testing.T) {
+	docs.Description("balances refuses to add up transactions in different currencies, but ignores deleted ones")
+	cut := New().(*AttendeeServiceImplData)
+	deleted := tstTx(paymentservice.Payment, paymentservice.Deleted, 12000, "2023-01-25", "ignoreme")
+	deleted.Amount.Currency = "USD"
+	txs := []paymentservice.Transaction{
+		tstTx(paymentservice.Due, paymentservice.Valid, 12000, "2023-01-24", "2023-02-05"),
+		deleted,
+	}
+	_, _, _, _, err := cut.balances("EUR", txs)
+	require.Nil(t, err)
+
+	_, _, _, _, err = cut.balances("GBP", txs)
+	require.ErrorIs(t, err, MixedCurrenciesError)
+}
+
 // --- helpers ---
 
 func tstBalancesTestcase(t *testing.T,
@@ -74,7 +92,8 @@ func tstBalancesTestcase(t *testing.T,
 
 	cut := New().(*AttendeeServiceImplData)
 
-	validDues, validPayments, openPayments, dueDate := cut.balances(transactionHistory)
+	validDues, validPayments, openPayments, dueDate, err := cut.balances("EUR", transactionHistory)
+	require.Nil(t, err)
 	require.Equal(t, expectedValidDues, validDues)
 	require.Equal(t, expectedValidPayments, validPayments)
 	require.Equal(t, expectedOpenPayments, openPayments)
@@ -100,3 +119,110 @@ func tstTx(ty paymentservice.TransactionType, st paymentservice.TransactionStatu
 		StatusHistory: nil,
 	}
 }
+
+func TestPackagePrice_AgeTiers(t *testing.T) {
+	docs.Description("the first matching age tier should determine the package price")
+	packageConfig := config.ChoiceConfig{
+		Price: 9000,
+		AgePrices: []config.AgePriceConfig{
+			{MaxAge: 17, Price: 4500, Description: "Youth Rate"},
+			{MinAge: 65, Price: 6000, Description: "Senior Rate"},
+		},
+	}
+
+	price, tier := packagePrice(packageConfig, "2005-08-17", "2023-08-16", "EUR")
+	require.Equal(t, int64(4500), price)
+	require.Equal(t, "Youth Rate", tier.Description)
+
+	price, tier = packagePrice(packageConfig, "2005-08-16", "2023-08-16", "EUR")
+	require.Equal(t, int64(9000), price)
+	require.Nil(t, tier)
+
+	price, tier = packagePrice(packageConfig, "1958-08-16", "2023-08-16", "EUR")
+	require.Equal(t, int64(6000), price)
+	require.Equal(t, "Senior Rate", tier.Description)
+
+	price, tier = packagePrice(packageConfig, "2005-08-17", "", "EUR")
+	require.Equal(t, int64(9000), price)
+	require.Nil(t, tier)
+}
+
+func TestScheduledPrice(t *testing.T) {
+	docs.Description("the latest schedule entry that has started should determine the package price")
+	packageConfig := config.ChoiceConfig{
+		Price: 15000,
+		PriceSchedule: []config.PriceScheduleConfig{
+			{From: "2024-06-01", Price: 18000, Description: "Regular"},
+			{From: "2024-08-01", Price: 20000, Description: "Late Booking"},
+		},
+	}
+
+	require.Equal(t, int64(15000), scheduledPrice(packageConfig, "2024-05-31", "EUR"))
+	require.Equal(t, int64(18000), scheduledPrice(packageConfig, "2024-06-01", "EUR"))
+	require.Equal(t, int64(18000), scheduledPrice(packageConfig, "2024-07-31", "EUR"))
+	require.Equal(t, int64(20000), scheduledPrice(packageConfig, "2024-08-01", "EUR"))
+}
+
+func TestPackagePrice_Currencies(t *testing.T) {
+	docs.Description("prices in additional currencies should apply to the regular price, age tiers and the price schedule")
+	packageConfig := config.ChoiceConfig{
+		Price:  15000,
+		Prices: map[string]int64{"GBP": 13000},
+		AgePrices: []config.AgePriceConfig{
+			{MaxAge: 17, Price: 7500, Description: "Youth Rate", Prices: map[string]int64{"GBP": 6500}},
+		},
+		PriceSchedule: []config.PriceScheduleConfig{
+			{From: "2024-08-01", Price: 20000, Description: "Late Booking", Prices: map[string]int64{"GBP": 17500}},
+		},
+	}
+
+	price, _ := packagePrice(packageConfig, "1990-01-01", "2023-08-16", "GBP")
+	require.Equal(t, int64(13000), price)
+	price, _ = packagePrice(packageConfig, "2005-08-17", "2023-08-16", "GBP")
+	require.Equal(t, int64(6500), price)
+	price, _ = packagePrice(packageConfig, "1990-01-01", "2023-08-16", "EUR")
+	require.Equal(t, int64(15000), price)
+
+	require.Equal(t, int64(13000), scheduledPrice(packageConfig, "2024-07-31", "GBP"))
+	require.Equal(t, int64(17500), scheduledPrice(packageConfig, "2024-08-01", "GBP"))
+	require.Equal(t, int64(20000), scheduledPrice(packageConfig, "2024-08-01", "EUR"))
+}
+
+func TestVoucherDiscounts(t *testing.T) {
+	docs.Description("vouchers should be applied in order, each to what is left of the package prices")
+	charges := []packageCharge{
+		{Key: "attendance", VatStr: "19.000000", Amount: 9000},
+		{Key: "room-none", VatStr: "7.000000", Amount: 0},
+		{Key: "sponsor", VatStr: "19.000000", Amount: 2500},
+		{Key: "stage", VatStr: "7.000000", Amount: 1000},
+	}
+	vouchers := []*entity.Voucher{
+		{Code: "STAGE50", Kind: entity.VoucherKindPercent, Amount: 50, Package: "stage"},
+		{Code: "TENOFF", Kind: entity.VoucherKindPercent, Amount: 10},
+		{Code: "FREESPONSOR", Kind: entity.VoucherKindFixed, Amount: 5000, Package: "sponsor"},
+		{Code: "HUNDRED", Kind: entity.VoucherKindFixed, Amount: 10000},
+	}
+
+	actual := voucherDiscounts(vouchers, charges)
+	require.Equal(t, map[string]map[string]int64{
+		"STAGE50":     {"7.000000": -500},
+		"TENOFF":      {"19.000000": -1150, "7.000000": -50},
+		"FREESPONSOR": {"19.000000": -2250},
+		"HUNDRED":     {"19.000000": -8100, "7.000000": -450},
+	}, actual)
+}
+
+func TestSplitDuesTransactions(t *testing.T) {
+	docs.Description("voucher discounts and write-offs should be recognized by their transaction reason")
+	packageTx := tstTx(paymentservice.Due, paymentservice.Valid, 25500, "2023-01-24", "2023-02-05")
+	packageTx.Reason = `{"packages_list":[],"manual_dues":{}}`
+	voucherTx := tstTx(paymentservice.Due, paymentservice.Valid, -1000, "2023-01-24", "2023-02-05")
+	voucherTx.Reason = `{"packages_list":[],"manual_dues":{},"voucher":"TENOFF"}`
+	payment := tstTx(paymentservice.Payment, paymentservice.Valid, 24450, "2023-01-25", "ignoreme")
+	writeOffTx := tstTx(paymentservice.Due, paymentservice.Valid, -50, "2023-01-25", "2023-02-05")
+	writeOffTx.Reason = `{"packages_list":[],"manual_dues":{},"write_off":true}`
+
+	others, byVoucher := splitDuesTransactions([]paymentservice.Transaction{packageTx, voucherTx, payment, writeOffTx})
+	require.Equal(t, []paymentservice.Transaction{packageTx, payment}, others)
+	require.Equal(t, map[string][]paymentservice.Transaction{"TENOFF": {voucherTx}}, byVoucher)
+}
@@ -0,0 +1,283 @@
+package attendeesrv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/duplicates"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database/dbrepo"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
+)
+
+// how likely two registrations are duplicates, per reason
+const (
+	duplicateScoreIdentity = 1.0
+	duplicateScorePhone    = 0.7
+	duplicateScoreBirthday = 0.4 // plus up to duplicateScoreName for similar names
+	duplicateScoreName     = 0.5
+)
+
+func (s *AttendeeServiceImplData) FindDuplicates(ctx context.Context, minScore float64) (*duplicates.DuplicateList, error) {
+	// cancelled registrations are left out, so merged duplicates do not show up again
+	candidates, err := database.GetRepositoryFor(ctx).FindAttendees(ctx, &attendee.AttendeeSearchCriteria{
+		MatchAny: []attendee.AttendeeSearchSingleCriterion{{
+			Status: []status.Status{status.New, status.Approved, status.PartiallyPaid, status.Paid, status.CheckedIn, status.Waiting},
+		}},
+		FillFields: []string{"all"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// only compare registrations that share at least one of the keys, comparing all pairs would take too long
+	groups := make(map[string][]*entity.AttendeeQueryResult)
+	for _, a := range candidates {
+		if a.Identity != "" {
+			groups["identity:"+a.Identity] = append(groups["identity:"+a.Identity], a)
+		}
+		if phone := normalizedPhone(a.Phone); phone != "" {
+			groups["phone:"+phone] = append(groups["phone:"+phone], a)
+		}
+		if a.Birthday != "" {
+			groups["birthday:"+a.Birthday] = append(groups["birthday:"+a.Birthday], a)
+		}
+	}
+
+	seen := make(map[[2]uint]bool)
+	result := duplicates.DuplicateList{Duplicates: make([]duplicates.Duplicate, 0)}
+	for _, group := range groups {
+		for i, a1 := range group {
+			for _, a2 := range group[i+1:] {
+				if a1.ID > a2.ID {
+					a1, a2 = a2, a1
+				}
+				pair := [2]uint{a1.ID, a2.ID}
+				if seen[pair] {
+					continue
+				}
+				seen[pair] = true
+
+				duplicate := duplicateScore(a1, a2)
+				if duplicate.Score > 0 && duplicate.Score >= minScore {
+					result.Duplicates = append(result.Duplicates, duplicate)
+				}
+			}
+		}
+	}
+
+	sort.Slice(result.Duplicates, func(i, j int) bool {
+		d1, d2 := result.Duplicates[i], result.Duplicates[j]
+		if d1.Score != d2.Score {
+			return d1.Score > d2.Score
+		}
+		if d1.Ids[0] != d2.Ids[0] {
+			return d1.Ids[0] < d2.Ids[0]
+		}
+		return d1.Ids[1] < d2.Ids[1]
+	})
+	return &result, nil
+}
+
+// duplicateScore combines the scores of all reasons that apply, so that several weak reasons
+// together are a strong indication.
+func duplicateScore(a1 *entity.AttendeeQueryResult, a2 *entity.AttendeeQueryResult) duplicates.Duplicate {
+	result := duplicates.Duplicate{
+		Ids:     []uint{a1.ID, a2.ID},
+		Reasons: make([]string, 0),
+	}
+	unlikely := 1.0
+	add := func(reason string, score float64) {
+		result.Reasons = append(result.Reasons, reason)
+		unlikely *= 1 - score
+	}
+
+	if a1.Identity != "" && a1.Identity == a2.Identity {
+		add(duplicates.ReasonSameIdentity, duplicateScoreIdentity)
+	}
+	if phone := normalizedPhone(a1.Phone); phone != "" && phone == normalizedPhone(a2.Phone) {
+		add(duplicates.ReasonSamePhone, duplicateScorePhone)
+	}
+	if a1.Birthday != "" && a1.Birthday == a2.Birthday {
+		similarity := max(
+			dbrepo.FuzzyScore(a1.FirstName+" "+a1.LastName, a2.FirstName+" "+a2.LastName+" "+a2.Nickname, ""),
+			dbrepo.FuzzyScore(a2.FirstName+" "+a2.LastName, a1.FirstName+" "+a1.LastName+" "+a1.Nickname, ""),
+		)
+		if similarity > 0 {
+			add(duplicates.ReasonSimilarNameAndBirthday, duplicateScoreBirthday+duplicateScoreName*similarity)
+		}
+	}
+
+	result.Score = math.Round((1-unlikely)*1000) / 1000
+	return result
+}
+
+// normalizedPhone keeps only the last 8 digits, so different ways of writing the country code
+// and area code still compare equal. Returns the empty string for numbers that are too short.
+func normalizedPhone(phone string) string {
+	digits := strings.Map(func(c rune) rune {
+		if unicode.IsDigit(c) {
+			return c
+		}
+		return -1
+	}, phone)
+	if len(digits) < 6 {
+		return ""
+	}
+	return digits[max(0, len(digits)-8):]
+}
+
+func (s *AttendeeServiceImplData) MergeAttendees(ctx context.Context, survivor *entity.Attendee, duplicate *entity.Attendee, comment string) (*duplicates.MergeResult, error) {
+	// controller checks that the ids differ
+	result := &duplicates.MergeResult{
+		SurvivingId:               survivor.ID,
+		MergedId:                  duplicate.ID,
+		MovedAdditionalInfo:       make([]string, 0),
+		ConflictingAdditionalInfo: make([]string, 0),
+	}
+
+	err := database.WithTransaction(ctx, func(ctx context.Context) error {
+		survivorHistory, err := s.GetFullStatusHistory(ctx, survivor)
+		if err != nil {
+			return err
+		}
+		duplicateHistory, err := s.GetFullStatusHistory(ctx, duplicate)
+		if err != nil {
+			return err
+		}
+		survivorStatus := survivorHistory[len(survivorHistory)-1].Status
+		duplicateStatus := duplicateHistory[len(duplicateHistory)-1].Status
+		if survivorStatus == status.Deleted || duplicateStatus == status.Deleted {
+			return MergeDeletedError
+		}
+
+		if err := s.checkDuplicateHasNoPayments(ctx, duplicate); err != nil {
+			return err
+		}
+
+		if err := s.moveAdditionalInfo(ctx, survivor.ID, duplicate.ID, result); err != nil {
+			return err
+		}
+
+		if err := s.mergeAdminComments(ctx, survivor.ID, duplicate.ID, comment); err != nil {
+			return err
+		}
+
+		// the moved entries keep the status of the survivor, the original status is only kept in the comments
+		for _, change := range duplicateHistory {
+			err = database.GetRepositoryFor(ctx).AddStatusChange(ctx, &entity.StatusChange{
+				AttendeeId: survivor.ID,
+				Status:     survivorStatus,
+				Comments:   fmt.Sprintf("merged from %d, originally %s at %s: %s", duplicate.ID, change.Status, change.CreatedAt.Format(config.IsoDateFormat), change.Comments),
+			})
+			if err != nil {
+				return err
+			}
+			result.StatusHistoryEntries++
+		}
+		// the latest entry determines the status, so it must be the current status of the survivor
+		err = database.GetRepositoryFor(ctx).AddStatusChange(ctx, &entity.StatusChange{
+			AttendeeId: survivor.ID,
+			Status:     survivorStatus,
+			Comments:   fmt.Sprintf("merged %d into this registration: %s", duplicate.ID, comment),
+		})
+		if err != nil {
+			return err
+		}
+
+		if duplicateStatus == status.Cancelled {
+			return nil
+		}
+		limitChanges, err := s.ComputeDeltasAndCheckLimitOverrun(ctx, duplicate, duplicate, duplicateStatus, status.Cancelled)
+		if err != nil {
+			return err
+		}
+		err = s.UpdateDuesAndDoStatusChangeIfNeeded(ctx, duplicate, duplicateStatus, status.Cancelled, fmt.Sprintf("merged into %d: %s", survivor.ID, comment), "", false, false)
+		if err != nil {
+			return err
+		}
+		return s.RecordLimitChanges(ctx, limitChanges)
+	})
+	return result, err
+}
+
+func (s *AttendeeServiceImplData) checkDuplicateHasNoPayments(ctx context.Context, duplicate *entity.Attendee) error {
+	transactionHistory, err := paymentservice.Get().GetTransactions(ctx, duplicate.ID)
+	if err != nil && !errors.Is(err, paymentservice.NoSuchDebitor404Error) {
+		return err
+	}
+	if err := s.checkNoPaymentsExist(ctx, duplicate, transactionHistory); err != nil {
+		return MergeHasPaymentsError
+	}
+	return nil
+}
+
+// moveAdditionalInfo moves all areas the survivor does not have a value for. The others stay where they are,
+// so nothing is lost.
+func (s *AttendeeServiceImplData) moveAdditionalInfo(ctx context.Context, survivorId uint, duplicateId uint, result *duplicates.MergeResult) error {
+	for _, area := range config.AdditionalInfoFieldNames() {
+		value, err := s.GetAdditionalInfo(ctx, duplicateId, area)
+		if err != nil {
+			return err
+		}
+		if value == "" {
+			continue
+		}
+
+		existing, err := s.GetAdditionalInfo(ctx, survivorId, area)
+		if err != nil {
+			return err
+		}
+		if existing != "" {
+			result.ConflictingAdditionalInfo = append(result.ConflictingAdditionalInfo, area)
+			continue
+		}
+
+		if err := s.WriteAdditionalInfo(ctx, survivorId, area, value); err != nil {
+			return err
+		}
+		if err := s.WriteAdditionalInfo(ctx, duplicateId, area, ""); err != nil {
+			return err
+		}
+		result.MovedAdditionalInfo = append(result.MovedAdditionalInfo, area)
+	}
+	return nil
+}
+
+func (s *AttendeeServiceImplData) mergeAdminComments(ctx context.Context, survivorId uint, duplicateId uint, comment string) error {
+	survivorInfo, err := database.GetRepositoryFor(ctx).GetAdminInfoByAttendeeId(ctx, survivorId)
+	if err != nil {
+		return err
+	}
+	duplicateInfo, err := database.GetRepositoryFor(ctx).GetAdminInfoByAttendeeId(ctx, duplicateId)
+	if err != nil {
+		return err
+	}
+
+	survivorInfo.AdminComments = appendComment(survivorInfo.AdminComments, fmt.Sprintf("merged %d into this registration: %s", duplicateId, comment))
+	if duplicateInfo.AdminComments != "" {
+		survivorInfo.AdminComments = appendComment(survivorInfo.AdminComments, fmt.Sprintf("merged from %d: %s", duplicateId, duplicateInfo.AdminComments))
+	}
+	if err := database.GetRepositoryFor(ctx).WriteAdminInfo(ctx, survivorInfo); err != nil {
+		return err
+	}
+
+	duplicateInfo.AdminComments = appendComment(duplicateInfo.AdminComments, fmt.Sprintf("merged into %d: %s", survivorId, comment))
+	return database.GetRepositoryFor(ctx).WriteAdminInfo(ctx, duplicateInfo)
+}
+
+func appendComment(comments string, addition string) string {
+	if comments == "" {
+		return addition
+	}
+	return comments + "\n" + addition
+}
@@ -0,0 +1,48 @@
+package attendeesrv
+
+import (
+	"testing"
+
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/duplicates"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/stretchr/testify/require"
+)
+
+func tstDuplicateCandidate(id uint, firstName string, lastName string, birthday string, phone string) *entity.AttendeeQueryResult {
+	result := &entity.AttendeeQueryResult{}
+	result.ID = id
+	result.FirstName = firstName
+	result.LastName = lastName
+	result.Birthday = birthday
+	result.Phone = phone
+	return result
+}
+
+func TestNormalizedPhone(t *testing.T) {
+	require.Equal(t, "30123456", normalizedPhone("+49 30 123456"))
+	require.Equal(t, "30123456", normalizedPhone("030 / 123 456"))
+	require.Equal(t, "123456", normalizedPhone("123-456"))
+	require.Equal(t, "", normalizedPhone("12345"))
+	require.Equal(t, "", normalizedPhone(""))
+}
+
+func TestDuplicateScore(t *testing.T) {
+	a := tstDuplicateCandidate(1, "Hans", "Mustermann", "1998-11-23", "+49 30 123456")
+
+	actual := duplicateScore(a, tstDuplicateCandidate(2, "Hans", "Musterman", "1998-11-23", "030 123456"))
+	require.Equal(t, []string{duplicates.ReasonSamePhone, duplicates.ReasonSimilarNameAndBirthday}, actual.Reasons)
+	require.Equal(t, 0.963, actual.Score)
+
+	actual = duplicateScore(a, tstDuplicateCandidate(3, "Jana", "Schulz", "1998-11-23", "+1 555 000"))
+	require.Empty(t, actual.Reasons)
+	require.Equal(t, 0.0, actual.Score)
+
+	actual = duplicateScore(a, tstDuplicateCandidate(4, "Jana", "Schulz", "1990-01-01", "+49 30 123456"))
+	require.Equal(t, []string{duplicates.ReasonSamePhone}, actual.Reasons)
+	require.Equal(t, 0.7, actual.Score)
+}
+
+func TestAppendComment(t *testing.T) {
+	require.Equal(t, "new", appendComment("", "new"))
+	require.Equal(t, "old\nnew", appendComment("old", "new"))
+}
@@ -17,7 +17,7 @@ import (
 func (s *AttendeeServiceImplData) GenerateFakeRegistrations(ctx context.Context, count uint) error {
 	for regNo := uint(0); regNo < count; regNo++ {
 		attendee := fakeRegistration()
-		id, err := database.GetRepository().AddAttendee(ctx, attendee)
+		id, err := database.GetRepositoryFor(ctx).AddAttendee(ctx, attendee)
 		if err != nil {
 			aulogging.Logger.Ctx(ctx).Warn().Printf("failed to save attendee #%d with nickname %s - BAILING OUT", regNo+1, attendee.Nickname)
 			return err
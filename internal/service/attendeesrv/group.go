@@ -0,0 +1,272 @@
+package attendeesrv
+
+import (
+	"context"
+	"fmt"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/webhook"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config/constraint"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/mailservice"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
+	"gorm.io/gorm"
+)
+
+func (s *AttendeeServiceImplData) GetGroup(ctx context.Context, id uint) (*entity.Group, []*entity.GroupMember, error) {
+	group, err := database.GetRepositoryFor(ctx).GetGroupById(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	members, err := database.GetRepositoryFor(ctx).GetGroupMembers(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return group, members, nil
+}
+
+func (s *AttendeeServiceImplData) GetGroupOf(ctx context.Context, attendeeId uint) (*entity.Group, error) {
+	membership, err := acceptedMembership(ctx, attendeeId)
+	if err != nil {
+		return nil, err
+	}
+	if membership == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return database.GetRepositoryFor(ctx).GetGroupById(ctx, membership.GroupId)
+}
+
+func (s *AttendeeServiceImplData) GetGroupInvitations(ctx context.Context, attendeeId uint) ([]*entity.Group, error) {
+	memberships, err := database.GetRepositoryFor(ctx).GetGroupMembershipsByAttendeeId(ctx, attendeeId)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*entity.Group, 0)
+	for _, m := range memberships {
+		if m.State == entity.GroupMemberStateInvited {
+			group, err := database.GetRepositoryFor(ctx).GetGroupById(ctx, m.GroupId)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, group)
+		}
+	}
+	return result, nil
+}
+
+func (s *AttendeeServiceImplData) CreateGroup(ctx context.Context, owner *entity.Attendee, name string) (*entity.Group, error) {
+	group := &entity.Group{
+		Name:    name,
+		OwnerId: owner.ID,
+	}
+
+	err := database.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.checkCanJoinGroup(ctx, owner); err != nil {
+			return err
+		}
+
+		if _, err := database.GetRepositoryFor(ctx).AddGroup(ctx, group); err != nil {
+			return err
+		}
+		member := &entity.GroupMember{
+			GroupId:    group.ID,
+			AttendeeId: owner.ID,
+			State:      entity.GroupMemberStateAccepted,
+		}
+		if _, err := database.GetRepositoryFor(ctx).AddGroupMember(ctx, member); err != nil {
+			return err
+		}
+
+		aulogging.Logger.Ctx(ctx).Info().Printf("group %d created for attendee %d by %s", group.ID, owner.ID, ctxvalues.Subject(ctx))
+		return s.emitGroupChangedEvent(ctx, member)
+	})
+	return group, err
+}
+
+func (s *AttendeeServiceImplData) DisbandGroup(ctx context.Context, group *entity.Group) error {
+	return database.WithTransaction(ctx, func(ctx context.Context) error {
+		members, err := database.GetRepositoryFor(ctx).GetGroupMembers(ctx, group.ID)
+		if err != nil {
+			return err
+		}
+		for _, m := range members {
+			if err := s.removeGroupMember(ctx, m); err != nil {
+				return err
+			}
+		}
+
+		aulogging.Logger.Ctx(ctx).Info().Printf("group %d disbanded by %s", group.ID, ctxvalues.Subject(ctx))
+		return database.GetRepositoryFor(ctx).DeleteGroup(ctx, group)
+	})
+}
+
+func (s *AttendeeServiceImplData) InviteToGroup(ctx context.Context, group *entity.Group, invitee *entity.Attendee) error {
+	return database.WithTransaction(ctx, func(ctx context.Context) error {
+		if _, err := database.GetRepositoryFor(ctx).GetGroupMember(ctx, group.ID, invitee.ID); err == nil {
+			return GroupAlreadyInvitedError
+		}
+		if err := s.checkCanJoinGroup(ctx, invitee); err != nil {
+			return err
+		}
+
+		member := &entity.GroupMember{
+			GroupId:    group.ID,
+			AttendeeId: invitee.ID,
+			State:      entity.GroupMemberStateInvited,
+		}
+		if _, err := database.GetRepositoryFor(ctx).AddGroupMember(ctx, member); err != nil {
+			return err
+		}
+
+		owner, err := database.GetRepositoryFor(ctx).GetAttendeeById(ctx, group.OwnerId)
+		if err != nil {
+			return err
+		}
+
+		aulogging.Logger.Ctx(ctx).Info().Printf("attendee %d invited to group %d by %s", invitee.ID, group.ID, ctxvalues.Subject(ctx))
+		return s.sendGroupInvitationEmail(ctx, group, invitee, owner)
+	})
+}
+
+func (s *AttendeeServiceImplData) JoinGroup(ctx context.Context, group *entity.Group, attendee *entity.Attendee) error {
+	return database.WithTransaction(ctx, func(ctx context.Context) error {
+		member, err := database.GetRepositoryFor(ctx).GetGroupMember(ctx, group.ID, attendee.ID)
+		if err != nil || member.State != entity.GroupMemberStateInvited {
+			return GroupNotInvitedError
+		}
+		if err := s.checkCanJoinGroup(ctx, attendee); err != nil {
+			return err
+		}
+
+		member.State = entity.GroupMemberStateAccepted
+		if err := database.GetRepositoryFor(ctx).UpdateGroupMember(ctx, member); err != nil {
+			return err
+		}
+
+		aulogging.Logger.Ctx(ctx).Info().Printf("attendee %d joined group %d", attendee.ID, group.ID)
+		return s.emitGroupChangedEvent(ctx, member)
+	})
+}
+
+func (s *AttendeeServiceImplData) RemoveFromGroup(ctx context.Context, group *entity.Group, attendee *entity.Attendee) error {
+	if attendee.ID == group.OwnerId {
+		return GroupOwnerError
+	}
+
+	return database.WithTransaction(ctx, func(ctx context.Context) error {
+		member, err := database.GetRepositoryFor(ctx).GetGroupMember(ctx, group.ID, attendee.ID)
+		if err != nil {
+			return GroupNotMemberError
+		}
+
+		aulogging.Logger.Ctx(ctx).Info().Printf("attendee %d removed from group %d by %s", attendee.ID, group.ID, ctxvalues.Subject(ctx))
+		return s.removeGroupMember(ctx, member)
+	})
+}
+
+func (s *AttendeeServiceImplData) CheckGroupConstraint(ctx context.Context, attendee *entity.Attendee) error {
+	membership, err := acceptedMembership(ctx, attendee.ID)
+	if err != nil {
+		return err
+	}
+	if membership == nil {
+		return nil
+	}
+	currentStatus, err := s.currentStatus(ctx, attendee)
+	if err != nil {
+		return err
+	}
+	if violatesGroupConstraint(attendee, currentStatus) {
+		return GroupConstraintError
+	}
+	return nil
+}
+
+// checkCanJoinGroup checks everything about the attendee that would prevent them from becoming a group member.
+func (s *AttendeeServiceImplData) checkCanJoinGroup(ctx context.Context, attendee *entity.Attendee) error {
+	currentStatus, err := s.currentStatus(ctx, attendee)
+	if err != nil {
+		return err
+	}
+	if currentStatus == status.Cancelled || currentStatus == status.Deleted {
+		return GroupStatusError
+	}
+
+	membership, err := acceptedMembership(ctx, attendee.ID)
+	if err != nil {
+		return err
+	}
+	if membership != nil {
+		return GroupMembershipError
+	}
+
+	if violatesGroupConstraint(attendee, currentStatus) {
+		return GroupConstraintError
+	}
+	return nil
+}
+
+func (s *AttendeeServiceImplData) removeGroupMember(ctx context.Context, member *entity.GroupMember) error {
+	if err := database.GetRepositoryFor(ctx).DeleteGroupMember(ctx, member); err != nil {
+		return err
+	}
+	if member.State == entity.GroupMemberStateAccepted {
+		return s.emitGroupChangedEvent(ctx, member)
+	}
+	return nil
+}
+
+func (s *AttendeeServiceImplData) emitGroupChangedEvent(ctx context.Context, member *entity.GroupMember) error {
+	return s.emitWebhookEvent(ctx, webhook.Event{Event: webhook.EventAttendeeGroupChanged, AttendeeId: member.AttendeeId, GroupId: member.GroupId})
+}
+
+func (s *AttendeeServiceImplData) sendGroupInvitationEmail(ctx context.Context, group *entity.Group, invitee *entity.Attendee, owner *entity.Attendee) error {
+	checkSummedId := s.badgeId(invitee.ID)
+	mailDto := mailservice.MailSendDto{
+		CommonID: "group-invitation",
+		Lang:     removeWrappingCommasWithDefault(invitee.RegistrationLanguage, "en-US"),
+		Variables: map[string]string{
+			"badge_number":               fmt.Sprintf("%d", invitee.ID),
+			"badge_number_with_checksum": *checkSummedId,
+			"nickname":                   invitee.Nickname,
+			"email":                      invitee.Email,
+			"group_id":                   fmt.Sprintf("%d", group.ID),
+			"group_name":                 group.Name,
+			"owner_nickname":             owner.Nickname,
+			"regsys_url":                 config.RegsysPublicUrl(),
+		},
+		To: []string{invitee.Email},
+	}
+	return s.sendMailViaOutbox(ctx, invitee.ID, mailDto)
+}
+
+// acceptedMembership returns the group membership of the attendee, or nil if they are not a member of any group.
+//
+// Open invitations are not memberships.
+func acceptedMembership(ctx context.Context, attendeeId uint) (*entity.GroupMember, error) {
+	memberships, err := database.GetRepositoryFor(ctx).GetGroupMembershipsByAttendeeId(ctx, attendeeId)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range memberships {
+		if m.State == entity.GroupMemberStateAccepted {
+			return m, nil
+		}
+	}
+	return nil, nil
+}
+
+func violatesGroupConstraint(attendee *entity.Attendee, currentStatus status.Status) bool {
+	if config.GroupConstraint() == "" {
+		return false
+	}
+	expr, err := constraint.Parse(config.GroupConstraint(), constraint.Packages)
+	if err != nil {
+		// cannot happen, the group constraint is validated when the configuration is loaded
+		return true
+	}
+	return !expr.Eval(constraintFacts(attendee, currentStatus))
+}
@@ -0,0 +1,224 @@
+package attendeesrv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/history"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/webhook"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database/historizeddb"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
+)
+
+func (s *AttendeeServiceImplData) GetAttendeeHistory(ctx context.Context, attendeeId uint) ([]history.HistoryEntry, error) {
+	attd, adminInfo, attdHistory, adminHistory, err := s.loadWithHistory(ctx, attendeeId)
+	if err != nil {
+		return nil, err
+	}
+
+	attdEntries, err := historyEntries(attd, attdHistory)
+	if err != nil {
+		return nil, err
+	}
+	adminEntries, err := historyEntries(adminInfo, adminHistory)
+	if err != nil {
+		return nil, err
+	}
+
+	// ids are assigned in the order the changes were made, across both entities
+	result := append(attdEntries, adminEntries...)
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id < result[j].Id
+	})
+	return result, nil
+}
+
+func (s *AttendeeServiceImplData) GetAttendeeAsOf(ctx context.Context, attendeeId uint, asOf time.Time) (*entity.Attendee, *entity.AdminInfo, error) {
+	attd, adminInfo, attdHistory, adminHistory, err := s.loadWithHistory(ctx, attendeeId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if attd.CreatedAt.After(asOf) {
+		return nil, nil, NotYetRegisteredError
+	}
+
+	isLater := func(h *entity.History) bool {
+		return h.CreatedAt.After(asOf)
+	}
+	if err := revertHistory(attd, attdHistory, isLater); err != nil {
+		return nil, nil, err
+	}
+	if err := revertHistory(adminInfo, adminHistory, isLater); err != nil {
+		return nil, nil, err
+	}
+	return attd, adminInfo, nil
+}
+
+func (s *AttendeeServiceImplData) RestoreAttendeeBefore(ctx context.Context, attendeeId uint, historyId uint) (*entity.Attendee, error) {
+	var restored *entity.Attendee
+	err := database.WithTransaction(ctx, func(ctx context.Context) error {
+		attd, adminInfo, attdHistory, adminHistory, err := s.loadWithHistory(ctx, attendeeId)
+		if err != nil {
+			return err
+		}
+		if !containsHistoryEntry(attdHistory, historyId) && !containsHistoryEntry(adminHistory, historyId) {
+			return HistoryEntryNotFoundError
+		}
+
+		original := *attd
+		isSameOrLater := func(h *entity.History) bool {
+			return h.ID >= historyId
+		}
+		if err := revertHistory(attd, attdHistory, isSameOrLater); err != nil {
+			return err
+		}
+		// deleting and undeleting go through status changes, which also update the counts and the status history
+		attd.DeletedAt = original.DeletedAt
+		originalAdminInfo := *adminInfo
+		if err := revertHistory(adminInfo, adminHistory, isSameOrLater); err != nil {
+			return err
+		}
+
+		statusHistory, err := s.GetFullStatusHistory(ctx, attd)
+		if err != nil {
+			return err
+		}
+		currentStatus := statusHistory[len(statusHistory)-1].Status
+
+		limitChanges, err := s.ComputeDeltasAndCheckLimitOverrun(ctx, &original, attd, currentStatus, currentStatus)
+		if err != nil {
+			return err
+		}
+
+		alreadyExists, err := isDuplicateAttendee(ctx, attd.Nickname, attd.Zip, attd.Email, 1)
+		if err != nil {
+			return err
+		}
+		if alreadyExists {
+			return errors.New("your changes would lead to duplicate attendee data - same nickname, zip, email")
+		}
+
+		// writing through the historizing repository records the restore as a change of its own
+		repo := database.GetRepositoryFor(ctx)
+		if err := repo.UpdateAttendee(ctx, attd); err != nil {
+			return err
+		}
+		if err := s.emitWebhookEvent(ctx, webhook.Event{Event: webhook.EventAttendeeUpdated, AttendeeId: attd.ID}); err != nil {
+			return err
+		}
+		if *adminInfo != originalAdminInfo {
+			if err := repo.WriteAdminInfo(ctx, adminInfo); err != nil {
+				return err
+			}
+			if err := s.emitWebhookEvent(ctx, webhook.Event{Event: webhook.EventAttendeeAdminInfoChanged, AttendeeId: attd.ID}); err != nil {
+				return err
+			}
+		}
+
+		if err := s.RecordLimitChanges(ctx, limitChanges); err != nil {
+			return err
+		}
+
+		// restored packages and flags may change the due amount
+		subject := ctxvalues.Subject(ctx)
+		err = s.UpdateDuesAndDoStatusChangeIfNeeded(ctx, attd, currentStatus, currentStatus, fmt.Sprintf("attendee restore by %s", subject), "", false, false)
+		if err != nil {
+			return err
+		}
+
+		restored = attd
+		return nil
+	})
+	return restored, err
+}
+
+func containsHistoryEntry(entries []*entity.History, historyId uint) bool {
+	for _, h := range entries {
+		if h.ID == historyId {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *AttendeeServiceImplData) loadWithHistory(ctx context.Context, attendeeId uint) (*entity.Attendee, *entity.AdminInfo, []*entity.History, []*entity.History, error) {
+	repo := database.GetRepositoryFor(ctx)
+	attd, err := repo.GetAttendeeById(ctx, attendeeId)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	adminInfo, err := repo.GetAdminInfoByAttendeeId(ctx, attendeeId)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	attdHistory, err := repo.GetHistoryByEntity(ctx, historizeddb.EntityAttendee, attendeeId)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	// admin info shares its id with the attendee
+	adminHistory, err := repo.GetHistoryByEntity(ctx, historizeddb.EntityAdminInfo, attendeeId)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	return attd, adminInfo, attdHistory, adminHistory, nil
+}
+
+// revertHistory reverts the changes recorded in entries (oldest first) to current, newest first, for as long
+// as revert returns true.
+func revertHistory[T any](current *T, entries []*entity.History, revert func(h *entity.History) bool) error {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if !revert(entries[i]) {
+			return nil
+		}
+		if err := historizeddb.Revert(current, historizeddb.ParseDiff(entries[i].Diff)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// historyEntries maps the history of an entity, oldest first.
+//
+// History entries only record the values before each change, so this walks back from the current version
+// to find the values after each change.
+func historyEntries[T any](current *T, entries []*entity.History) ([]history.HistoryEntry, error) {
+	result := make([]history.HistoryEntry, len(entries))
+	state := *current
+	for i := len(entries) - 1; i >= 0; i-- {
+		h := entries[i]
+		after := state
+		changes := historizeddb.ParseDiff(h.Diff)
+		if err := historizeddb.Revert(&state, changes); err != nil {
+			return nil, err
+		}
+
+		fieldChanges := make([]history.FieldChange, 0)
+		for _, change := range changes {
+			oldValue, ok := historizeddb.FieldValue(&state, change.Path)
+			if !ok {
+				continue
+			}
+			newValue, _ := historizeddb.FieldValue(&after, change.Path)
+			fieldChanges = append(fieldChanges, history.FieldChange{
+				Field:    historizeddb.FieldName(change.Path),
+				OldValue: oldValue,
+				NewValue: newValue,
+			})
+		}
+
+		result[i] = history.HistoryEntry{
+			Id:        h.ID,
+			Timestamp: h.CreatedAt.Format(time.RFC3339Nano),
+			Entity:    h.Entity,
+			Identity:  h.Identity,
+			RequestId: h.RequestId,
+			Changes:   fieldChanges,
+		}
+	}
+	return result, nil
+}
@@ -1,9 +1,21 @@
 package attendeesrv
 
-import "time"
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+)
 
 type AttendeeServiceImplData struct {
 	Now func() time.Time
+
+	// webhook delivery in the background, see wakeWebhookDispatch
+	webhookDispatchMu       sync.Mutex
+	webhookDispatchRunning  bool
+	webhookDispatchRunAgain bool
+	webhookDispatches       sync.WaitGroup
 }
 
 var _ AttendeeService = (*AttendeeServiceImplData)(nil)
@@ -13,3 +25,7 @@ func New() AttendeeService {
 		Now: time.Now,
 	}
 }
+
+func (s *AttendeeServiceImplData) WithTransaction(ctx context.Context, f func(ctx context.Context) error) error {
+	return database.WithTransaction(ctx, f)
+}
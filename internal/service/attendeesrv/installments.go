@@ -0,0 +1,178 @@
+package attendeesrv
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
+)
+
+// installment is an amount that is due on a date.
+type installment struct {
+	DueDate string
+	Amount  int64
+}
+
+func (s *AttendeeServiceImplData) GetInstallments(ctx context.Context, att *entity.Attendee) ([]attendee.Installment, error) {
+	result := make([]attendee.Installment, 0)
+
+	transactionHistory, err := paymentservice.Get().GetTransactions(ctx, att.ID)
+	if err != nil && !errors.Is(err, paymentservice.NoSuchDebitor404Error) {
+		return result, err
+	}
+	dues, payments, _, regularDueDate, err := s.balances(billingCurrency(att), transactionHistory)
+	if err != nil {
+		return result, err
+	}
+
+	schedule, err := s.installmentSchedule(ctx, att, dues, regularDueDate)
+	if err != nil {
+		return result, err
+	}
+
+	covered := payments
+	for _, inst := range schedule {
+		paid := max(min(covered, inst.Amount), 0)
+		covered -= paid
+		result = append(result, attendee.Installment{
+			DueDate: inst.DueDate,
+			Amount:  inst.Amount,
+			Open:    inst.Amount - paid,
+		})
+	}
+	return result, nil
+}
+
+// installmentSchedule splits the dues of the attendee into installments according to the payment plans
+// of their packages, in order of due dates. It is empty if none of their packages has a payment plan,
+// in which case all dues are due at the regular due date.
+//
+// Everything not covered by a payment plan is due at the regular due date, as is every installment
+// whose configured due date has already passed by then. Discounts and manual dues reduce the
+// last installments first.
+func (s *AttendeeServiceImplData) installmentSchedule(ctx context.Context, attendee *entity.Attendee, dues int64, regularDueDate string) ([]installment, error) {
+	result := make([]installment, 0)
+
+	plans := config.PaymentPlans()
+	if len(plans) == 0 || attendee.ID == 0 {
+		return result, nil
+	}
+
+	adminInfo, err := database.GetRepositoryFor(ctx).GetAdminInfoByAttendeeId(ctx, attendee.ID)
+	if err != nil {
+		return result, err
+	}
+	if s.considerGuest(ctx, adminInfo) {
+		return result, nil
+	}
+
+	charges, _, err := s.packageCharges(ctx, attendee)
+	if err != nil {
+		return result, err
+	}
+
+	packageConfigs := config.PackagesConfig()
+	planned := make([]installment, 0)
+	for _, c := range charges {
+		plan, ok := plans[packageConfigs[c.Key].PaymentPlan]
+		if !ok || c.Amount <= 0 {
+			continue
+		}
+		planned = append(planned, splitIntoInstallments(c.Amount, plan, regularDueDate)...)
+	}
+	if len(planned) == 0 {
+		return result, nil
+	}
+
+	return buildSchedule(planned, dues, regularDueDate), nil
+}
+
+// splitIntoInstallments splits amount according to the plan. The last installment gets the rounding difference.
+func splitIntoInstallments(amount int64, plan config.PaymentPlanConfig, regularDueDate string) []installment {
+	result := make([]installment, 0, len(plan.Installments))
+	remaining := amount
+	for i, entry := range plan.Installments {
+		part := amount * int64(entry.Percent) / 100
+		if i == len(plan.Installments)-1 {
+			part = remaining
+		}
+		remaining -= part
+
+		dueDate := regularDueDate
+		if entry.DueDate > dueDate {
+			dueDate = entry.DueDate
+		}
+		result = append(result, installment{DueDate: dueDate, Amount: part})
+	}
+	return result
+}
+
+// buildSchedule combines the planned installments with the rest of the dues, which is due at the regular due date,
+// and merges installments that are due on the same date.
+func buildSchedule(planned []installment, dues int64, regularDueDate string) []installment {
+	sort.SliceStable(planned, func(i, j int) bool {
+		return planned[i].DueDate < planned[j].DueDate
+	})
+
+	var plannedTotal int64
+	for _, inst := range planned {
+		plannedTotal += inst.Amount
+	}
+
+	// the dues can be lower than the planned installments, e.g. due to vouchers or negative manual dues
+	excess := plannedTotal - dues
+	for i := len(planned) - 1; i >= 0 && excess > 0; i-- {
+		reduction := min(excess, planned[i].Amount)
+		planned[i].Amount -= reduction
+		excess -= reduction
+	}
+
+	all := make([]installment, 0, len(planned)+1)
+	if dues > plannedTotal {
+		all = append(all, installment{DueDate: regularDueDate, Amount: dues - plannedTotal})
+	}
+	all = append(all, planned...)
+
+	result := make([]installment, 0, len(all))
+	for _, inst := range all {
+		if inst.Amount <= 0 {
+			continue
+		}
+		if len(result) > 0 && result[len(result)-1].DueDate == inst.DueDate {
+			result[len(result)-1].Amount += inst.Amount
+		} else {
+			result = append(result, inst)
+		}
+	}
+	return result
+}
+
+// installmentDueDate returns the due date of the first installment that payments do not cover.
+//
+// Returns false if there are no installments, or all of them are covered.
+func installmentDueDate(schedule []installment, payments int64) (string, bool) {
+	var accrued int64
+	for _, inst := range schedule {
+		accrued += inst.Amount
+		if accrued > payments {
+			return inst.DueDate, true
+		}
+	}
+	return "", false
+}
+
+// installmentOpenUntil returns how much of the installments due on or before dueDate payments do not cover.
+func installmentOpenUntil(schedule []installment, payments int64, dueDate string) int64 {
+	var accrued int64
+	for _, inst := range schedule {
+		if inst.DueDate <= dueDate {
+			accrued += inst.Amount
+		}
+	}
+	return max(accrued-payments, 0)
+}
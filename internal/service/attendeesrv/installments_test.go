@@ -0,0 +1,87 @@
+package attendeesrv
+
+import (
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func tstPaymentPlan() config.PaymentPlanConfig {
+	return config.PaymentPlanConfig{
+		Description: "Three Installments",
+		Installments: []config.InstallmentConfig{
+			{Percent: 40},
+			{Percent: 30, DueDate: "2023-02-01"},
+			{Percent: 30, DueDate: "2023-04-01"},
+		},
+	}
+}
+
+func TestSplitIntoInstallments(t *testing.T) {
+	docs.Description("the last installment gets the rounding difference, and passed due dates move to the regular due date")
+	actual := splitIntoInstallments(6501, tstPaymentPlan(), "2023-03-01")
+	require.Equal(t, []installment{
+		{DueDate: "2023-03-01", Amount: 2600},
+		{DueDate: "2023-03-01", Amount: 1950},
+		{DueDate: "2023-04-01", Amount: 1951},
+	}, actual)
+}
+
+func TestBuildSchedule(t *testing.T) {
+	docs.Description("the rest of the dues is due at the regular due date, and installments on the same date are merged")
+	planned := splitIntoInstallments(6500, tstPaymentPlan(), "2022-12-22")
+	actual := buildSchedule(planned, 16000, "2022-12-22")
+	require.Equal(t, []installment{
+		{DueDate: "2022-12-22", Amount: 12100},
+		{DueDate: "2023-02-01", Amount: 1950},
+		{DueDate: "2023-04-01", Amount: 1950},
+	}, actual)
+}
+
+func TestBuildSchedule_Discount(t *testing.T) {
+	docs.Description("if the dues are lower than the planned installments, the last installments are reduced first")
+	planned := splitIntoInstallments(6500, tstPaymentPlan(), "2022-12-22")
+	actual := buildSchedule(planned, 4000, "2022-12-22")
+	require.Equal(t, []installment{
+		{DueDate: "2022-12-22", Amount: 2600},
+		{DueDate: "2023-02-01", Amount: 1400},
+	}, actual)
+}
+
+func TestInstallmentDueDate(t *testing.T) {
+	docs.Description("the due date is the due date of the first installment that is not fully paid")
+	schedule := []installment{
+		{DueDate: "2022-12-22", Amount: 12100},
+		{DueDate: "2023-02-01", Amount: 1950},
+		{DueDate: "2023-04-01", Amount: 1950},
+	}
+	dueDate, ok := installmentDueDate(schedule, 0)
+	require.True(t, ok)
+	require.Equal(t, "2022-12-22", dueDate)
+	dueDate, _ = installmentDueDate(schedule, 12100)
+	require.Equal(t, "2023-02-01", dueDate)
+	dueDate, _ = installmentDueDate(schedule, 14049)
+	require.Equal(t, "2023-02-01", dueDate)
+	_, ok = installmentDueDate(schedule, 16000)
+	require.False(t, ok)
+	_, ok = installmentDueDate([]installment{}, 0)
+	require.False(t, ok)
+
+	require.Equal(t, int64(1951), installmentOpenUntil(schedule, 12099, "2023-02-01"))
+	require.Equal(t, int64(0), installmentOpenUntil(schedule, 15000, "2023-02-01"))
+}
+
+func TestCalculateResultingStatus_Installments(t *testing.T) {
+	docs.Description("with a payment plan, partially paid needs the first installment to be paid")
+	cut := &AttendeeServiceImplData{}
+	schedule := []installment{
+		{DueDate: "2022-12-22", Amount: 12100},
+		{DueDate: "2023-02-01", Amount: 3900},
+	}
+	require.Equal(t, status.Approved, cut.calculateResultingStatusForApprovedToPaid(10000, 16000, 100, schedule))
+	require.Equal(t, status.PartiallyPaid, cut.calculateResultingStatusForApprovedToPaid(12000, 16000, 100, schedule))
+	require.Equal(t, status.Paid, cut.calculateResultingStatusForApprovedToPaid(16000, 16000, 100, schedule))
+	require.Equal(t, status.PartiallyPaid, cut.calculateResultingStatusForApprovedToPaid(10000, 16000, 100, []installment{}))
+}
@@ -3,14 +3,23 @@ package attendeesrv
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/duplicates"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/history"
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
 )
 
 type AttendeeService interface {
+	// WithTransaction runs f as a single database unit of work. All service calls made with the context passed
+	// to f take part in it, and their database changes are rolled back together if f returns an error.
+	//
+	// Mails caused by these changes are only delivered once the unit of work has been committed.
+	WithTransaction(ctx context.Context, f func(ctx context.Context) error) error
+
 	// NewAttendee creates an empty (unsaved) attendee, without an assigned badge number (aka. ID).
 	//
 	// Mostly useful for filling in values and passing it to RegisterNewAttendee.
@@ -28,8 +37,12 @@ type AttendeeService interface {
 
 	CanChangeEmailTo(ctx context.Context, originalEmail string, newEmail string) error
 
-	CanChangeChoiceTo(ctx context.Context, what string, originalChoiceStr string, newChoiceStr string, configuration map[string]config.ChoiceConfig) error
-	CanChangeChoiceToCurrentStatus(ctx context.Context, what string, originalChoice []attendee.PackageState, newChoice []attendee.PackageState, configuration map[string]config.ChoiceConfig, currentStatus status.Status) error
+	// CanChangeChoiceTo checks permissions and constraints for a change of flags, admin flags or options.
+	//
+	// Constraints may refer to other categories, the birthday and the status, which are taken from newState and currentStatus.
+	CanChangeChoiceTo(ctx context.Context, what string, originalChoiceStr string, newChoiceStr string, configuration map[string]config.ChoiceConfig, newState *entity.Attendee, currentStatus status.Status) error
+	// CanChangeChoiceToCurrentStatus works like CanChangeChoiceTo, but also prevents deselecting packages after payment.
+	CanChangeChoiceToCurrentStatus(ctx context.Context, what string, originalChoice []attendee.PackageState, newChoice []attendee.PackageState, configuration map[string]config.ChoiceConfig, newState *entity.Attendee, currentStatus status.Status) error
 
 	GetAdminInfo(ctx context.Context, attendeeId uint) (*entity.AdminInfo, error)
 	UpdateAdminInfo(ctx context.Context, attendee *entity.Attendee, adminInfo *entity.AdminInfo, suppressMinorUpdateEmail bool) error
@@ -56,11 +69,29 @@ type AttendeeService interface {
 	// Can also be used to check new registrations, by setting packages on oldAttendeeState to empty
 	ComputeDeltasAndCheckLimitOverrun(ctx context.Context, oldAttendeeState *entity.Attendee, currentAttendeeState *entity.Attendee, oldStatus status.Status, newStatus status.Status) ([]*entity.Count, error)
 
+	// ComputeDeltasAndWaitlistPlacement works like ComputeDeltasAndCheckLimitOverrun, but packages with a waiting list
+	// do not cause an overrun error. Instead, they are left out of the deltas and returned in the second
+	// result, mapping package key to the count that should be queued.
+	//
+	// Intended for new registrations, pass the second result to PlaceOnWaitlist after the attendee has been saved.
+	ComputeDeltasAndWaitlistPlacement(ctx context.Context, oldAttendeeState *entity.Attendee, currentAttendeeState *entity.Attendee, oldStatus status.Status, newStatus status.Status) ([]*entity.Count, map[string]int, error)
+
 	// RecordLimitChanges changes the package limit cache according to deltas, if any.
 	//
+	// If this frees up stock in a package with a waiting list, queued attendees are approved automatically
+	// in queue order.
+	//
 	// It is no error to pass a slice of length 0.
 	RecordLimitChanges(ctx context.Context, deltas []*entity.Count) error
 
+	// PlaceOnWaitlist queues a newly registered attendee for the given packages and changes their status to waiting.
+	//
+	// It is no error to pass an empty map, nothing happens in that case.
+	PlaceOnWaitlist(ctx context.Context, attendee *entity.Attendee, queued map[string]int) error
+
+	// GetWaitlist obtains the waiting list entries for a given package key in queue order.
+	GetWaitlist(ctx context.Context, key string) ([]*entity.WaitlistEntry, error)
+
 	// GetLimitBookings obtains the limit and number of pending and attending bookings for a given package key.
 	GetLimitBookings(ctx context.Context, key string) (*entity.Count, error)
 
@@ -86,8 +117,23 @@ type AttendeeService interface {
 	IsOwnedByIdentity(ctx context.Context, identity string) ([]*entity.Attendee, error)
 
 	// FindAttendees runs the search by criteria in the database, then filters and converts the result.
+	//
+	// If the criteria set NumResults or a Cursor, the search is paged: the result then also contains the
+	// total number of matches, and the cursor for the next page if there are more results.
+	//
+	// Returns an error wrapping InvalidSearchCursorError if the cursor cannot be used with the criteria.
 	FindAttendees(ctx context.Context, criteria *attendee.AttendeeSearchCriteria) (*attendee.AttendeeSearchResultList, error)
 
+	// StreamAttendees works like FindAttendees, but passes each result to f as it is read from the database,
+	// so large result sets need not fit into memory. Stops at the first error returned by f.
+	//
+	// f must not call other service methods that access the database.
+	StreamAttendees(ctx context.Context, criteria *attendee.AttendeeSearchCriteria, f func(result *attendee.AttendeeSearchResult) error) error
+
+	// SearchResultFields lists the json names of the fields that FindAttendees fills in for the given
+	// FillFields, in the order they appear in a search result.
+	SearchResultFields(fillFields []string) []string
+
 	// NewBan creates an empty (unsaved) ban.
 	NewBan(ctx context.Context) *entity.Ban
 	CreateBan(ctx context.Context, ban *entity.Ban) (uint, error)
@@ -96,6 +142,128 @@ type AttendeeService interface {
 	GetBan(ctx context.Context, id uint) (*entity.Ban, error)
 	GetAllBans(ctx context.Context) ([]*entity.Ban, error)
 
+	// GetAllSavedSearches returns all saved searches ordered by name, regardless of who may run them.
+	GetAllSavedSearches(ctx context.Context) ([]*entity.SavedSearch, error)
+	GetSavedSearch(ctx context.Context, name string) (*entity.SavedSearch, error)
+	// SaveSavedSearch creates the saved search, or updates it if it already has an id.
+	SaveSavedSearch(ctx context.Context, search *entity.SavedSearch) error
+	DeleteSavedSearch(ctx context.Context, search *entity.SavedSearch) error
+
+	// CanRunSavedSearch checks whether the currently logged in subject may run a saved search.
+	//
+	// Admins and api token users may run all saved searches. Others must own a registration whose admin info
+	// has one of the permissions listed in the saved search.
+	CanRunSavedSearch(ctx context.Context, search *entity.SavedSearch) (bool, error)
+
+	// FindDuplicates scans all registrations that are not cancelled or deleted for likely duplicates, and
+	// returns the pairs with at least minScore, best candidates first.
+	FindDuplicates(ctx context.Context, minScore float64) (*duplicates.DuplicateList, error)
+
+	// MergeAttendees merges the duplicate registration into the survivor, and cancels the duplicate.
+	//
+	// Additional info is moved unless the survivor already has a value for the area, admin comments are
+	// appended, and the status history of the duplicate is copied (the status of the survivor stays the same).
+	//
+	// Returns MergeDeletedError if either registration is deleted, or MergeHasPaymentsError if the duplicate
+	// has payments, which must first be moved in the payment service.
+	MergeAttendees(ctx context.Context, survivor *entity.Attendee, duplicate *entity.Attendee, comment string) (*duplicates.MergeResult, error)
+
+	// GetPendingTransfer returns the transfer offer for the attendee that has not yet been accepted or cancelled.
+	GetPendingTransfer(ctx context.Context, attendeeId uint) (*entity.Transfer, error)
+	GetTransferByCode(ctx context.Context, code string) (*entity.Transfer, error)
+
+	// OfferTransfer offers the registration to the person with the given email address, replacing any
+	// earlier offer. The offer is mailed to the recipient, together with the code needed to accept it.
+	//
+	// Returns TransferStatusError if the registration is cancelled or deleted.
+	OfferTransfer(ctx context.Context, attendee *entity.Attendee, email string) (*entity.Transfer, error)
+	CancelTransfer(ctx context.Context, transfer *entity.Transfer) error
+
+	// AcceptTransfer hands the registration over to the currently logged in subject. The attendee must already
+	// contain the personal data of the recipient. The change is historized, and both parties are informed by mail.
+	//
+	// Returns TransferNotPendingError, TransferExpiredError or TransferStatusError if the transfer can no longer
+	// be accepted, TransferRecipientError if the recipient owns the registration or already has another one,
+	// and BanCandidateError if the recipient matches a ban rule.
+	AcceptTransfer(ctx context.Context, transfer *entity.Transfer, attendee *entity.Attendee) error
+
+	// GetGroup returns a group together with its members and open invitations.
+	GetGroup(ctx context.Context, id uint) (*entity.Group, []*entity.GroupMember, error)
+	// GetGroupOf returns the group the attendee is a member of, or an error if there is none.
+	GetGroupOf(ctx context.Context, attendeeId uint) (*entity.Group, error)
+	// GetGroupInvitations returns the groups the attendee has been invited to, but not yet joined.
+	GetGroupInvitations(ctx context.Context, attendeeId uint) ([]*entity.Group, error)
+
+	// CreateGroup creates a group owned by the attendee, who becomes its first member.
+	//
+	// Returns GroupStatusError if the registration is cancelled or deleted, GroupMembershipError if the
+	// attendee is already a member of another group, and GroupConstraintError if their packages violate
+	// the configured group constraint.
+	CreateGroup(ctx context.Context, owner *entity.Attendee, name string) (*entity.Group, error)
+
+	// DisbandGroup removes the group together with all its members and open invitations.
+	DisbandGroup(ctx context.Context, group *entity.Group) error
+
+	// InviteToGroup invites the attendee to the group, and informs them by mail.
+	//
+	// Returns GroupAlreadyInvitedError if there already is an invitation, and otherwise the same errors as CreateGroup.
+	InviteToGroup(ctx context.Context, group *entity.Group, invitee *entity.Attendee) error
+
+	// JoinGroup accepts an invitation to the group.
+	//
+	// Returns GroupNotInvitedError if there is no open invitation, and otherwise the same errors as CreateGroup.
+	JoinGroup(ctx context.Context, group *entity.Group, attendee *entity.Attendee) error
+
+	// RemoveFromGroup withdraws an invitation, or ends a membership.
+	//
+	// Returns GroupOwnerError for the owner, who can only disband the group, and GroupNotMemberError if the
+	// attendee is neither a member of the group nor invited to it.
+	RemoveFromGroup(ctx context.Context, group *entity.Group, attendee *entity.Attendee) error
+
+	// CheckGroupConstraint returns GroupConstraintError if the attendee is a group member, and their
+	// packages violate the configured group constraint. Call before saving package changes.
+	CheckGroupConstraint(ctx context.Context, attendee *entity.Attendee) error
+
+	// GetAllVouchers returns all vouchers ordered by code.
+	GetAllVouchers(ctx context.Context) ([]*entity.Voucher, error)
+	// GetVoucher looks up a voucher by its code, ignoring case.
+	GetVoucher(ctx context.Context, code string) (*entity.Voucher, error)
+	// CountVoucherUses returns how often the voucher has been redeemed.
+	CountVoucherUses(ctx context.Context, voucher *entity.Voucher) (int, error)
+	// SaveVoucher creates the voucher, or updates it if it already has an id.
+	SaveVoucher(ctx context.Context, voucher *entity.Voucher) error
+
+	// DeleteVoucher removes a voucher.
+	//
+	// Returns VoucherInUseError if it has been redeemed. Remove the redemptions first.
+	DeleteVoucher(ctx context.Context, voucher *entity.Voucher) error
+
+	// GetRedeemedVouchers returns the vouchers the attendee has redeemed, in the order they were redeemed.
+	GetRedeemedVouchers(ctx context.Context, attendeeId uint) ([]*entity.Voucher, error)
+
+	// RedeemVoucher redeems the voucher with the given code for the attendee, and books the discount.
+	//
+	// Returns VoucherNotFoundError if there is no such voucher, VoucherStatusError if the registration is
+	// cancelled or deleted, VoucherExpiredError, VoucherUsedUpError or VoucherAlreadyRedeemedError if the
+	// voucher can no longer be redeemed, and VoucherNotApplicableError if it is for a package the attendee
+	// has not booked.
+	RedeemVoucher(ctx context.Context, attendee *entity.Attendee, code string) error
+
+	// RemoveVoucherRedemption takes back a redeemed voucher, together with its discount.
+	//
+	// Returns VoucherNotRedeemedError if the attendee has not redeemed the voucher.
+	RemoveVoucherRedemption(ctx context.Context, attendee *entity.Attendee, voucher *entity.Voucher) error
+
+	// GetInstallments returns the installments the dues of the attendee are split into, in order of due dates.
+	//
+	// Empty unless the attendee has booked a package with a payment plan.
+	GetInstallments(ctx context.Context, attendee *entity.Attendee) ([]attendee.Installment, error)
+
+	// GetInvoices returns the invoices issued to the attendee, oldest first.
+	//
+	// A new invoice is issued whenever the dues of the attendee change, and supersedes all earlier ones.
+	GetInvoices(ctx context.Context, attendee *entity.Attendee) ([]attendee.Invoice, error)
+
 	// GetFullAdditionalInfoArea obtains all additional info values for an area.
 	//
 	// May return an empty map if no entries found. This is not an error.
@@ -148,6 +316,58 @@ type AttendeeService interface {
 	// Returns true if access is allowed, and an error if the check could not be performed.
 	CanUseFindAttendee(ctx context.Context) (bool, error)
 
+	// GetOutboxMails lists the mails in the outbox with the given state (pending, sending, sent, failed), oldest first.
+	GetOutboxMails(ctx context.Context, state string) ([]*entity.OutboxMail, error)
+	GetOutboxMail(ctx context.Context, id uint) (*entity.OutboxMail, error)
+
+	// RetryOutboxMail resets the retry count of a pending or failed outbox mail and attempts delivery right away.
+	//
+	// The outcome of the attempt is recorded in the mail. A failed attempt is not an error, but a mail that
+	// is being sent right now cannot be retried.
+	RetryOutboxMail(ctx context.Context, mail *entity.OutboxMail) error
+
+	// DiscardOutboxMail removes a pending or failed mail from the outbox without sending it.
+	DiscardOutboxMail(ctx context.Context, mail *entity.OutboxMail) error
+
+	// DispatchOutboxMails attempts delivery of all outbox mails that are due for (another) attempt.
+	//
+	// Called periodically by the background dispatcher.
+	DispatchOutboxMails(ctx context.Context) error
+
+	// GetWebhookDeliveries lists webhook deliveries, newest first.
+	//
+	// Blank subscriber or state and attendeeId 0 mean no filtering by that field.
+	GetWebhookDeliveries(ctx context.Context, subscriber string, state string, attendeeId uint, limit int) ([]*entity.WebhookDelivery, error)
+
+	// DispatchWebhooks attempts delivery of all webhook events that are due for (another) attempt.
+	//
+	// Each subscriber receives events in the order they occurred, so a delivery waiting for a retry
+	// holds up later events for the same subscriber.
+	//
+	// Called periodically by the background dispatcher.
+	DispatchWebhooks(ctx context.Context) error
+
+	// GetAttendeeHistory lists the recorded changes to an attendee and their admin info, oldest first.
+	GetAttendeeHistory(ctx context.Context, attendeeId uint) ([]history.HistoryEntry, error)
+
+	// GetAttendeeAsOf reconstructs an attendee and their admin info as they were at the given time,
+	// by reverting all later changes recorded in the history.
+	//
+	// Fields that are not historized, such as comments, keep their current values.
+	//
+	// Returns NotYetRegisteredError if the attendee did not exist at that time.
+	GetAttendeeAsOf(ctx context.Context, attendeeId uint, asOf time.Time) (*entity.Attendee, *entity.AdminInfo, error)
+
+	// RestoreAttendeeBefore reverts an attendee and their admin info to the state just before the given
+	// history entry, by reverting that change and all later ones. The restore is recorded in the history
+	// like any other change.
+	//
+	// Package limits are checked as for an update, and dues are recalculated.
+	//
+	// Returns HistoryEntryNotFoundError if the history entry does not belong to the attendee, or an error
+	// wrapping IntroducesOverrun if the restored packages are no longer available.
+	RestoreAttendeeBefore(ctx context.Context, attendeeId uint, historyId uint) (*entity.Attendee, error)
+
 	// GenerateFakeRegistrations creates the specified number of fake registrations in the database.
 	//
 	// Only for use on test systems.
@@ -162,12 +382,40 @@ type AttendeeService interface {
 }
 
 var (
-	SameStatusError          = errors.New("old and new status are the same")
-	InsufficientPaymentError = errors.New("payment amount not sufficient")
-	HasPaymentBalanceError   = errors.New("there is a non-zero payment balance, please use partially paid, or refund")
-	CannotDeleteError        = errors.New("cannot delete attendee for legal reasons (there were payments or invoices)")
-	GoToApprovedFirst        = errors.New("please change status to approved, this will automatically advance to (partially) paid as appropriate")
-	UnknownStatusError       = errors.New("unknown status value - this is a programming error")
-	BanCandidateError        = errors.New("this attendee matches a ban rule and cannot be approved, please review and either cancel or set the skip_ban_check admin flag to allow approval")
-	IntroducesOverrun        = errors.New("this change introduces a package overrun")
+	SameStatusError             = errors.New("old and new status are the same")
+	InsufficientPaymentError    = errors.New("payment amount not sufficient")
+	HasPaymentBalanceError      = errors.New("there is a non-zero payment balance, please use partially paid, or refund")
+	CannotDeleteError           = errors.New("cannot delete attendee for legal reasons (there were payments or invoices)")
+	GoToApprovedFirst           = errors.New("please change status to approved, this will automatically advance to (partially) paid as appropriate")
+	UnknownStatusError          = errors.New("unknown status value - this is a programming error")
+	BanCandidateError           = errors.New("this attendee matches a ban rule and cannot be approved, please review and either cancel or set the skip_ban_check admin flag to allow approval")
+	IntroducesOverrun           = errors.New("this change introduces a package overrun")
+	OutboxMailAlreadySentError  = errors.New("this mail has already been sent")
+	OutboxMailSendingError      = errors.New("this mail is being sent right now")
+	NotYetRegisteredError       = errors.New("the attendee did not exist at that time")
+	HistoryEntryNotFoundError   = errors.New("no such history entry for this attendee")
+	InvalidSearchCursorError    = errors.New("invalid search cursor")
+	MergeDeletedError           = errors.New("cannot merge deleted registrations, please undelete first")
+	MergeHasPaymentsError       = errors.New("the duplicate registration has payments, please move them in the payment service first")
+	TransferStatusError         = errors.New("cancelled or deleted registrations cannot be transferred")
+	TransferNotPendingError     = errors.New("this transfer has already been accepted or cancelled")
+	TransferExpiredError        = errors.New("this transfer has expired, please ask for a new one")
+	TransferRecipientError      = errors.New("the recipient must not own this or any other registration")
+	GroupStatusError            = errors.New("cancelled or deleted registrations cannot be group members")
+	GroupMembershipError        = errors.New("the attendee is already a member of a group")
+	GroupAlreadyInvitedError    = errors.New("the attendee has already been invited to this group")
+	GroupNotInvitedError        = errors.New("the attendee has no open invitation to this group")
+	GroupNotMemberError         = errors.New("the attendee is neither a member of this group nor invited to it")
+	GroupOwnerError             = errors.New("the owner cannot leave the group, please disband it instead")
+	GroupConstraintError        = errors.New("the packages of the attendee do not allow group membership")
+	VoucherNotFoundError        = errors.New("there is no voucher with this code")
+	VoucherStatusError          = errors.New("cancelled or deleted registrations cannot redeem vouchers")
+	VoucherExpiredError         = errors.New("this voucher has expired")
+	VoucherUsedUpError          = errors.New("this voucher has reached its usage limit")
+	VoucherAlreadyRedeemedError = errors.New("this voucher has already been redeemed for this registration")
+	VoucherNotApplicableError   = errors.New("this voucher is for a package that is not booked")
+	VoucherNotRedeemedError     = errors.New("this voucher has not been redeemed for this registration")
+	VoucherInUseError           = errors.New("this voucher has been redeemed, please remove the redemptions first")
+	VoucherCurrencyError        = errors.New("this voucher is for a different currency")
+	MixedCurrenciesError        = errors.New("there are transactions in a currency other than the billing currency of the attendee, please correct them in the payment service")
 )
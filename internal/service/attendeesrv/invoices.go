@@ -0,0 +1,251 @@
+package attendeesrv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
+)
+
+func (s *AttendeeServiceImplData) GetInvoices(ctx context.Context, att *entity.Attendee) ([]attendee.Invoice, error) {
+	result := make([]attendee.Invoice, 0)
+
+	invoices, err := database.GetRepositoryFor(ctx).GetInvoicesByAttendeeId(ctx, att.ID)
+	if err != nil {
+		return result, err
+	}
+
+	for i, inv := range invoices {
+		items := make([]attendee.InvoiceItem, 0)
+		if inv.Items != "" {
+			if err := json.Unmarshal([]byte(inv.Items), &items); err != nil {
+				return result, fmt.Errorf("invoice %d has invalid items: %v", inv.Number, err)
+			}
+		}
+		recipient := make([]string, 0)
+		if inv.Recipient != "" {
+			recipient = strings.Split(inv.Recipient, "\n")
+		}
+		result = append(result, attendee.Invoice{
+			Number:       invoiceNumber(inv),
+			IssuedOn:     inv.IssuedOn,
+			Recipient:    recipient,
+			Currency:     inv.Currency,
+			Items:        items,
+			VatBreakdown: vatBreakdown(items),
+			Total:        inv.Total,
+			Superseded:   i < len(invoices)-1,
+		})
+	}
+	return result, nil
+}
+
+func invoiceNumber(inv *entity.Invoice) string {
+	return fmt.Sprintf("%s%06d", inv.NumberPrefix, inv.Number)
+}
+
+// issueInvoiceIfDuesChanged issues a new invoice if the invoiced items, their amounts or the currency
+// differ from the latest invoice of the attendee.
+//
+// Once all dues are gone, the new invoice has no items and supersedes the previous one. No invoice is issued
+// for attendees that never had any dues.
+func (s *AttendeeServiceImplData) issueInvoiceIfDuesChanged(ctx context.Context, att *entity.Attendee, adminInfo *entity.AdminInfo, transactionHistory []paymentservice.Transaction) error {
+	if !config.InvoicesEnabled() {
+		return nil
+	}
+
+	items, err := s.invoiceItems(ctx, att, adminInfo, transactionHistory)
+	if err != nil {
+		return err
+	}
+	itemsJson, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	currency := billingCurrency(att)
+
+	previous, err := database.GetRepositoryFor(ctx).GetInvoicesByAttendeeId(ctx, att.ID)
+	if err != nil {
+		return err
+	}
+	if len(previous) == 0 {
+		if len(items) == 0 {
+			return nil
+		}
+	} else {
+		latest := previous[len(previous)-1]
+		if latest.Items == string(itemsJson) && latest.Currency == currency {
+			return nil
+		}
+	}
+
+	total := int64(0)
+	for _, item := range items {
+		total += item.Amount
+	}
+
+	invoice := &entity.Invoice{
+		AttendeeId:   att.ID,
+		NumberPrefix: config.Invoices().NumberPrefix,
+		IssuedOn:     s.Now().Format(config.IsoDateFormat),
+		Currency:     currency,
+		Recipient:    strings.Join(s.invoiceRecipient(att), "\n"),
+		Items:        string(itemsJson),
+		Total:        total,
+	}
+	if _, err := database.GetRepositoryFor(ctx).AddInvoice(ctx, invoice); err != nil {
+		return err
+	}
+	aulogging.Logger.Ctx(ctx).Info().Printf("issued invoice %s for attendee id %d over %d %s", invoiceNumber(invoice), att.ID, total, currency)
+	return nil
+}
+
+// invoiceItems lists what the attendee is charged for, such that the items add up to the dues booked per vat rate.
+//
+// Voucher discounts get an item each. Whatever else differs from the current package prices, such as a price
+// change that was not applied yet, ends up in one adjustment item per vat rate. Write-offs are not part
+// of the invoice, they do not change what the attendee was charged for.
+func (s *AttendeeServiceImplData) invoiceItems(ctx context.Context, att *entity.Attendee, adminInfo *entity.AdminInfo, transactionHistory []paymentservice.Transaction) ([]attendee.InvoiceItem, error) {
+	result := make([]attendee.InvoiceItem, 0)
+
+	packageTransactions, voucherTransactions := splitDuesTransactions(transactionHistory)
+	invoicedByVAT := s.oldDuesByVAT(packageTransactions)
+	voucherCodes := make([]string, 0, len(voucherTransactions))
+	for code, txs := range voucherTransactions {
+		voucherCodes = append(voucherCodes, code)
+		for vatStr, amount := range s.oldDuesByVAT(txs) {
+			invoicedByVAT[vatStr] += amount
+		}
+	}
+	sort.Strings(voucherCodes)
+
+	anyDues := false
+	for _, amount := range invoicedByVAT {
+		if amount != 0 {
+			anyDues = true
+		}
+	}
+	if !anyDues {
+		return result, nil
+	}
+
+	remainingByVAT := make(map[string]int64)
+	for vatStr, amount := range invoicedByVAT {
+		remainingByVAT[vatStr] = amount
+	}
+	add := func(description string, count int, amount int64, vatStr string) {
+		remainingByVAT[vatStr] -= amount
+		vatPercent, _ := strconv.ParseFloat(vatStr, 64)
+		result = append(result, attendee.InvoiceItem{
+			Description: description,
+			Count:       count,
+			Amount:      amount,
+			VatPercent:  vatPercent,
+		})
+	}
+
+	if !s.considerGuest(ctx, adminInfo) {
+		charges, _, err := s.packageCharges(ctx, att)
+		if err != nil {
+			return result, err
+		}
+		packageCounts := choiceStrToMap(att.Packages, config.PackagesConfig())
+		for _, c := range charges {
+			if c.Amount != 0 {
+				add(config.PackagesConfig()[c.Key].Description, packageCounts[c.Key], c.Amount, c.VatStr)
+			}
+		}
+	}
+
+	if adminInfo.ManualDues != 0 {
+		description := adminInfo.ManualDuesDescription
+		if description == "" {
+			description = "Manual dues"
+		}
+		add(description, 1, adminInfo.ManualDues, fmt.Sprintf("%.6f", config.VatPercent()))
+	}
+
+	for _, code := range voucherCodes {
+		discountByVAT := s.oldDuesByVAT(voucherTransactions[code])
+		for _, vatStr := range sortedVatStrings(discountByVAT) {
+			if amount := discountByVAT[vatStr]; amount != 0 {
+				add(fmt.Sprintf("Voucher %s", code), 1, amount, vatStr)
+			}
+		}
+	}
+
+	for _, vatStr := range sortedVatStrings(remainingByVAT) {
+		if remaining := remainingByVAT[vatStr]; remaining != 0 {
+			add("Discounts and adjustments", 1, remaining, vatStr)
+		}
+	}
+
+	return result, nil
+}
+
+func sortedVatStrings(byVAT map[string]int64) []string {
+	result := make([]string, 0, len(byVAT))
+	for vatStr := range byVAT {
+		result = append(result, vatStr)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, _ := strconv.ParseFloat(result[i], 64)
+		b, _ := strconv.ParseFloat(result[j], 64)
+		return a < b
+	})
+	return result
+}
+
+// invoiceRecipient is the name and address of the attendee, one line each.
+func (s *AttendeeServiceImplData) invoiceRecipient(att *entity.Attendee) []string {
+	result := []string{
+		strings.TrimSpace(att.FirstName + " " + att.LastName),
+		att.Street,
+		strings.TrimSpace(strings.TrimSuffix(att.Zip, fmt.Sprintf("_d_%d", att.ID)) + " " + att.City),
+	}
+	if att.State != "" {
+		result = append(result, att.State)
+	}
+	return append(result, att.Country)
+}
+
+// vatBreakdown sums up the items by vat rate, in ascending order of the rate.
+//
+// The amounts of the items include vat, so the net amount is calculated from the gross amount per rate.
+func vatBreakdown(items []attendee.InvoiceItem) []attendee.InvoiceVat {
+	grossByRate := make(map[float64]int64)
+	for _, item := range items {
+		grossByRate[item.VatPercent] += item.Amount
+	}
+	rates := make([]float64, 0, len(grossByRate))
+	for rate := range grossByRate {
+		rates = append(rates, rate)
+	}
+	sort.Float64s(rates)
+
+	result := make([]attendee.InvoiceVat, 0, len(rates))
+	for _, rate := range rates {
+		gross := grossByRate[rate]
+		if gross == 0 {
+			continue
+		}
+		net := int64(math.Round(float64(gross) * 100 / (100 + rate)))
+		result = append(result, attendee.InvoiceVat{
+			VatPercent: rate,
+			Net:        net,
+			Vat:        gross - net,
+			Gross:      gross,
+		})
+	}
+	return result
+}
@@ -0,0 +1,56 @@
+package attendeesrv
+
+import (
+	"testing"
+
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/stretchr/testify/require"
+)
+
+func tstInvoiceAttendee() *entity.Attendee {
+	att := &entity.Attendee{
+		FirstName: "Hans",
+		LastName:  "Mustermann",
+		Street:    "Teststraße 24",
+		Zip:       "12345",
+		City:      "Berlin",
+		Country:   "DE",
+	}
+	att.ID = 42
+	return att
+}
+
+func TestVatBreakdown(t *testing.T) {
+	docs.Description("items are summed up by vat rate, and the net amount is calculated from the gross amount per rate")
+	actual := vatBreakdown([]attendee.InvoiceItem{
+		{Description: "Room", Count: 1, Amount: 10700, VatPercent: 7},
+		{Description: "Sponsor", Count: 1, Amount: 16000, VatPercent: 19},
+		{Description: "T-Shirt", Count: 2, Amount: 2000, VatPercent: 19},
+		{Description: "Voucher X", Count: 1, Amount: -1000, VatPercent: 19},
+	})
+	require.Equal(t, []attendee.InvoiceVat{
+		{VatPercent: 7, Net: 10000, Vat: 700, Gross: 10700},
+		{VatPercent: 19, Net: 14286, Vat: 2714, Gross: 17000},
+	}, actual)
+}
+
+func TestVatBreakdownSkipsZero(t *testing.T) {
+	docs.Description("vat rates that add up to zero are left out of the breakdown")
+	actual := vatBreakdown([]attendee.InvoiceItem{
+		{Description: "Day Ticket", Count: 1, Amount: 6000, VatPercent: 19},
+		{Description: "Discounts and adjustments", Count: 1, Amount: -6000, VatPercent: 19},
+	})
+	require.Equal(t, []attendee.InvoiceVat{}, actual)
+}
+
+func TestInvoiceRecipient(t *testing.T) {
+	docs.Description("the recipient is the name and address of the attendee, without the suffix for deleted attendees")
+	s := &AttendeeServiceImplData{}
+	att := tstInvoiceAttendee()
+	require.Equal(t, []string{"Hans Mustermann", "Teststraße 24", "12345 Berlin", "DE"}, s.invoiceRecipient(att))
+	att.Zip = "12345_d_42"
+	att.State = "Berlin"
+	require.Equal(t, []string{"Hans Mustermann", "Teststraße 24", "12345 Berlin", "Berlin", "DE"}, s.invoiceRecipient(att))
+}
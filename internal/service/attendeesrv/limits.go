@@ -3,6 +3,7 @@ package attendeesrv
 import (
 	"context"
 	"fmt"
+	"sort"
 
 	aulogging "github.com/StephanHCB/go-autumn-logging"
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
@@ -13,61 +14,113 @@ import (
 )
 
 func (s *AttendeeServiceImplData) GetLimitBookings(ctx context.Context, key string) (*entity.Count, error) {
-	return database.GetRepository().GetCount(ctx, entity.CountAreaPackage, key)
+	return database.GetRepositoryFor(ctx).GetCount(ctx, entity.CountAreaPackage, key)
 }
 
 func (s *AttendeeServiceImplData) RecordLimitChanges(ctx context.Context, deltas []*entity.Count) error {
-	db := database.GetRepository()
+	db := database.GetRepositoryFor(ctx)
 	for _, delta := range deltas {
 		if _, err := db.AddCount(ctx, delta); err != nil {
 			return err
 		}
 	}
+
+	// freed up stock goes to the waiting list first
+	for _, delta := range deltas {
+		if delta.Area == entity.CountAreaPackage && delta.Pending+delta.Attending < 0 && config.PackagesConfig()[delta.Name].Waitlist {
+			if err := s.promoteFromWaitlist(ctx, delta.Name); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
 func (s *AttendeeServiceImplData) ComputeDeltasAndCheckLimitOverrun(ctx context.Context, oldState *entity.Attendee, currentState *entity.Attendee, oldStatus status.Status, newStatus status.Status) ([]*entity.Count, error) {
+	result, _, err := s.computeDeltasLowlevel(ctx, oldState, currentState, oldStatus, newStatus, false)
+	return result, err
+}
+
+func (s *AttendeeServiceImplData) ComputeDeltasAndWaitlistPlacement(ctx context.Context, oldState *entity.Attendee, currentState *entity.Attendee, oldStatus status.Status, newStatus status.Status) ([]*entity.Count, map[string]int, error) {
+	return s.computeDeltasLowlevel(ctx, oldState, currentState, oldStatus, newStatus, true)
+}
+
+func (s *AttendeeServiceImplData) computeDeltasLowlevel(ctx context.Context, oldState *entity.Attendee, currentState *entity.Attendee, oldStatus status.Status, newStatus status.Status, allowWaitlist bool) ([]*entity.Count, map[string]int, error) {
 	result := make([]*entity.Count, 0)
+	queued := make(map[string]int)
 
 	packagesConfig := config.PackagesConfig()
 	oldPackagesSelectedCountMap := choiceStrToMap(oldState.Packages, packagesConfig)
 	currentPackagesSelectedCountMap := choiceStrToMap(currentState.Packages, packagesConfig)
-	for key, conf := range packagesConfig {
+
+	// quantities queued on a waiting list do not count towards the limit
+	oldPackagesQueuedCountMap := make(map[string]int)
+	if oldStatus == status.Waiting && oldState.ID != 0 {
+		var err error
+		oldPackagesQueuedCountMap, err = queuedCountMap(ctx, oldState.ID)
+		if err != nil {
+			return result, queued, err
+		}
+	}
+
+	// the counts are locked until the end of the transaction, always in the same order to avoid deadlocks
+	keys := make([]string, 0, len(packagesConfig))
+	for key := range packagesConfig {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		conf := packagesConfig[key]
 		if conf.Limit > 0 {
 			// if the package isn't selected either before or after the update, then it cannot cause deltas or overruns
 			if currentPackagesSelectedCountMap[key] > 0 || oldPackagesSelectedCountMap[key] > 0 {
-				oldPendingCount := oldPackagesSelectedCountMap[key] * pendingMultiplier(oldStatus)
-				newPendingCount := currentPackagesSelectedCountMap[key] * pendingMultiplier(newStatus)
-
-				oldAttendingCount := oldPackagesSelectedCountMap[key] * attendingMultiplier(oldStatus)
-				newAttendingCount := currentPackagesSelectedCountMap[key] * attendingMultiplier(newStatus)
+				oldCounted := max(oldPackagesSelectedCountMap[key]-oldPackagesQueuedCountMap[key], 0)
+				currentCounted := currentPackagesSelectedCountMap[key]
+				if newStatus == status.Waiting {
+					// staying on the waiting list, see syncWaitlist
+					currentCounted = currentCounted - min(oldPackagesQueuedCountMap[key], currentCounted)
+				}
 
-				currentAllocation, err := database.GetRepository().GetCount(ctx, entity.CountAreaPackage, key)
+				// locked, so concurrent transactions cannot both pass the check and together overrun the limit
+				currentAllocation, err := database.GetRepositoryFor(ctx).GetCountForUpdate(ctx, entity.CountAreaPackage, key)
 				if err != nil {
-					return result, err
+					return result, queued, err
 				}
 
-				delta := entity.Count{
-					Area:      entity.CountAreaPackage,
-					Name:      key,
-					Pending:   newPendingCount - oldPendingCount,
-					Attending: newAttendingCount - oldAttendingCount,
-				}
+				delta := limitDelta(key, oldCounted, oldStatus, currentCounted, newStatus)
 				if delta.Pending != 0 || delta.Attending != 0 {
 					newPendingAllocation := currentAllocation.Pending + delta.Pending
 					newAttendingAllocation := currentAllocation.Attending + delta.Attending
 
 					if newPendingAllocation+newAttendingAllocation > conf.Limit {
-						return result, fmt.Errorf("cannot allocate package '%s', stock limit reached - please remove this package to continue: %w", key, IntroducesOverrun)
+						if !allowWaitlist || !conf.Waitlist {
+							return result, queued, fmt.Errorf("cannot allocate package '%s', stock limit reached - please remove this package to continue: %w", key, IntroducesOverrun)
+						}
+
+						queued[key] = currentPackagesSelectedCountMap[key]
+						delta = limitDelta(key, oldCounted, oldStatus, 0, newStatus)
+						if delta.Pending == 0 && delta.Attending == 0 {
+							continue
+						}
 					}
 
-					result = append(result, &delta)
+					result = append(result, delta)
 				}
 			}
 		}
 	}
 
-	return result, nil
+	return result, queued, nil
+}
+
+func limitDelta(key string, oldCount int, oldStatus status.Status, newCount int, newStatus status.Status) *entity.Count {
+	return &entity.Count{
+		Area:      entity.CountAreaPackage,
+		Name:      key,
+		Pending:   newCount*pendingMultiplier(newStatus) - oldCount*pendingMultiplier(oldStatus),
+		Attending: newCount*attendingMultiplier(newStatus) - oldCount*attendingMultiplier(oldStatus),
+	}
 }
 
 func (s *AttendeeServiceImplData) RecalculateLimit(ctx context.Context, key string) error {
@@ -82,11 +135,20 @@ func (s *AttendeeServiceImplData) RecalculateLimit(ctx context.Context, key stri
 		},
 		FillFields: []string{"packages", "status"},
 	}
-	searchResultList, err := database.GetRepository().FindAttendees(ctx, &criteria)
+	searchResultList, err := database.GetRepositoryFor(ctx).FindAttendees(ctx, &criteria)
 	if err != nil {
 		return err
 	}
 
+	queue, err := database.GetRepositoryFor(ctx).GetWaitlistForPackage(ctx, key)
+	if err != nil {
+		return err
+	}
+	queuedByAttendeeId := make(map[uint]int)
+	for _, entry := range queue {
+		queuedByAttendeeId[entry.AttendeeId] = entry.Count
+	}
+
 	newCounts := entity.Count{
 		Area: entity.CountAreaPackage,
 		Name: key,
@@ -96,6 +158,9 @@ func (s *AttendeeServiceImplData) RecalculateLimit(ctx context.Context, key stri
 			packages := choiceStrToMapWithoutChecks(searchResult.Packages)
 			pkgCount, ok := packages[key]
 			if ok {
+				if searchResult.Status == status.Waiting {
+					pkgCount = max(pkgCount-queuedByAttendeeId[searchResult.ID], 0)
+				}
 				if searchResult.Status == status.New || searchResult.Status == status.Waiting {
 					newCounts.Pending = newCounts.Pending + pkgCount
 				} else if searchResult.Status == status.Approved || searchResult.Status == status.PartiallyPaid || searchResult.Status == status.Paid || searchResult.Status == status.CheckedIn {
@@ -106,13 +171,13 @@ func (s *AttendeeServiceImplData) RecalculateLimit(ctx context.Context, key stri
 	}
 
 	// loading it also checks that count row is present
-	currentCounts, err := database.GetRepository().GetCount(ctx, entity.CountAreaPackage, key)
+	currentCounts, err := database.GetRepositoryFor(ctx).GetCount(ctx, entity.CountAreaPackage, key)
 	if err != nil {
 		return err
 	}
 	if currentCounts.Pending != newCounts.Pending || currentCounts.Attending != newCounts.Attending {
 		aulogging.Logger.Ctx(ctx).Warn().Printf("resetting counts for package '%s' - pending %d -> %d - attending %d -> %d", key, currentCounts.Pending, newCounts.Pending, currentCounts.Attending, newCounts.Attending)
-		return database.GetRepository().ResetCount(ctx, &newCounts)
+		return database.GetRepositoryFor(ctx).ResetCount(ctx, &newCounts)
 	} else {
 		aulogging.Logger.Ctx(ctx).Info().Printf("counts remain unchanged for package '%s' - pending %d - attending %d", key, newCounts.Pending, newCounts.Attending)
 		return nil
@@ -0,0 +1,149 @@
+package attendeesrv
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/mailservice"
+)
+
+// how many due mails the dispatcher picks up per run
+const outboxDispatchBatchSize = 50
+
+// how long a delivery attempt may take before the mail is considered lost and can be picked up again,
+// well above what the mail service client waits for a response
+const outboxSendingLease = 5 * time.Minute
+
+func (s *AttendeeServiceImplData) GetOutboxMails(ctx context.Context, state string) ([]*entity.OutboxMail, error) {
+	return database.GetRepositoryFor(ctx).GetOutboxMailsByState(ctx, state)
+}
+
+func (s *AttendeeServiceImplData) GetOutboxMail(ctx context.Context, id uint) (*entity.OutboxMail, error) {
+	return database.GetRepositoryFor(ctx).GetOutboxMailById(ctx, id)
+}
+
+func (s *AttendeeServiceImplData) RetryOutboxMail(ctx context.Context, mail *entity.OutboxMail) error {
+	if mail.State == entity.OutboxStateSent {
+		return OutboxMailAlreadySentError
+	}
+
+	claimed, err := database.GetRepositoryFor(ctx).ClaimOutboxMail(ctx, mail, s.Now(), s.Now().Add(outboxSendingLease))
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return OutboxMailSendingError
+	}
+
+	mail.Attempts = 0
+	return s.deliverOutboxMail(ctx, mail)
+}
+
+func (s *AttendeeServiceImplData) DiscardOutboxMail(ctx context.Context, mail *entity.OutboxMail) error {
+	if mail.State == entity.OutboxStateSent {
+		return OutboxMailAlreadySentError
+	}
+	if mail.State == entity.OutboxStateSending && mail.NextAttempt.After(s.Now()) {
+		return OutboxMailSendingError
+	}
+
+	aulogging.Logger.Ctx(ctx).Info().Printf("discarding outbox mail %d (%s) for attendee %d after %d attempts", mail.ID, mail.CommonID, mail.AttendeeId, mail.Attempts)
+	return database.GetRepositoryFor(ctx).DeleteOutboxMail(ctx, mail)
+}
+
+func (s *AttendeeServiceImplData) DispatchOutboxMails(ctx context.Context) error {
+	due, err := database.GetRepositoryFor(ctx).GetDueOutboxMails(ctx, s.Now(), outboxDispatchBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, mail := range due {
+		claimed, err := database.GetRepositoryFor(ctx).ClaimOutboxMail(ctx, mail, s.Now(), s.Now().Add(outboxSendingLease))
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			aulogging.Logger.Ctx(ctx).Debug().Printf("outbox mail %d was picked up by someone else, skipping", mail.ID)
+			continue
+		}
+
+		if err := s.deliverOutboxMail(ctx, mail); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendMailViaOutbox records the mail in the outbox, then makes a first delivery attempt right away,
+// or as soon as the current unit of work has been committed.
+//
+// The mail is recorded as sending, so the dispatcher leaves it alone unless the first attempt is lost.
+//
+// If the mail service is unavailable, this is not an error. The mail stays in the outbox and
+// the dispatcher will retry it later.
+func (s *AttendeeServiceImplData) sendMailViaOutbox(ctx context.Context, attendeeId uint, mailDto mailservice.MailSendDto) error {
+	payload, err := json.Marshal(mailDto)
+	if err != nil {
+		return err
+	}
+
+	mail := entity.OutboxMail{
+		AttendeeId:  attendeeId,
+		CommonID:    mailDto.CommonID,
+		State:       entity.OutboxStateSending,
+		NextAttempt: s.Now().Add(outboxSendingLease),
+		Payload:     string(payload),
+	}
+	if _, err := database.GetRepositoryFor(ctx).AddOutboxMail(ctx, &mail); err != nil {
+		return err
+	}
+
+	return database.AfterCommit(ctx, func(ctx context.Context) error {
+		return s.deliverOutboxMail(ctx, &mail)
+	})
+}
+
+// deliverOutboxMail makes a single delivery attempt for a mail that is sending, and records the outcome.
+//
+// Only returns an error if the outcome could not be recorded.
+func (s *AttendeeServiceImplData) deliverOutboxMail(ctx context.Context, mail *entity.OutboxMail) error {
+	mailDto := mailservice.MailSendDto{}
+	if err := json.Unmarshal([]byte(mail.Payload), &mailDto); err != nil {
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("outbox mail %d has an invalid payload: %s", mail.ID, err.Error())
+		mail.State = entity.OutboxStateFailed
+		mail.LastError = err.Error()
+		return database.GetRepositoryFor(ctx).UpdateOutboxMail(ctx, mail)
+	}
+
+	mail.Attempts++
+	if err := mailservice.Get().SendEmail(ctx, mailDto); err != nil {
+		mail.LastError = err.Error()
+		if mail.Attempts >= config.MailOutboxMaxAttempts() {
+			aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("giving up on outbox mail %d (%s) for attendee %d after %d attempts: %s", mail.ID, mail.CommonID, mail.AttendeeId, mail.Attempts, err.Error())
+			mail.State = entity.OutboxStateFailed
+		} else {
+			mail.State = entity.OutboxStatePending
+			mail.NextAttempt = s.Now().Add(retryBackoff(mail.Attempts, config.MailOutboxInitialBackoff(), config.MailOutboxMaxBackoff()))
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("failed to deliver outbox mail %d (%s) for attendee %d, will retry after %s: %s", mail.ID, mail.CommonID, mail.AttendeeId, mail.NextAttempt.Format(time.RFC3339), err.Error())
+		}
+	} else {
+		mail.State = entity.OutboxStateSent
+		mail.LastError = ""
+	}
+
+	return database.GetRepositoryFor(ctx).UpdateOutboxMail(ctx, mail)
+}
+
+// retryBackoff doubles the wait time with each failed attempt, up to maxBackoff.
+func retryBackoff(attempts int, initialBackoff time.Duration, maxBackoff time.Duration) time.Duration {
+	backoff := initialBackoff
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff = backoff * 2
+	}
+	return min(backoff, maxBackoff)
+}
@@ -16,12 +16,12 @@ func (s *AttendeeServiceImplData) subjectHasAreaPermissionEntry(ctx context.Cont
 	}
 
 	// check that any of the registrations owned by subject have one of the permissions configured for any of the areas
-	ownedAttendees, err := database.GetRepository().FindByIdentity(ctx, subject)
+	ownedAttendees, err := database.GetRepositoryFor(ctx).FindByIdentity(ctx, subject)
 	if err != nil {
 		return false, err
 	}
 	for _, oa := range ownedAttendees {
-		adminInfo, err := database.GetRepository().GetAdminInfoByAttendeeId(ctx, oa.ID)
+		adminInfo, err := database.GetRepositoryFor(ctx).GetAdminInfoByAttendeeId(ctx, oa.ID)
 		if err != nil {
 			return false, err
 		}
@@ -55,12 +55,12 @@ func (s *AttendeeServiceImplData) subjectHasDirectPermissionEntry(ctx context.Co
 	}
 
 	// check that any of the registrations owned by subject has one of the granting permissions
-	ownedAttendees, err := database.GetRepository().FindByIdentity(ctx, subject)
+	ownedAttendees, err := database.GetRepositoryFor(ctx).FindByIdentity(ctx, subject)
 	if err != nil {
 		return false, err
 	}
 	for _, oa := range ownedAttendees {
-		adminInfo, err := database.GetRepository().GetAdminInfoByAttendeeId(ctx, oa.ID)
+		adminInfo, err := database.GetRepositoryFor(ctx).GetAdminInfoByAttendeeId(ctx, oa.ID)
 		if err != nil {
 			return false, err
 		}
@@ -0,0 +1,50 @@
+package attendeesrv
+
+import (
+	"context"
+	"errors"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
+)
+
+func (s *AttendeeServiceImplData) GetAllSavedSearches(ctx context.Context) ([]*entity.SavedSearch, error) {
+	return database.GetRepositoryFor(ctx).GetAllSavedSearches(ctx)
+}
+
+func (s *AttendeeServiceImplData) GetSavedSearch(ctx context.Context, name string) (*entity.SavedSearch, error) {
+	return database.GetRepositoryFor(ctx).GetSavedSearchByName(ctx, name)
+}
+
+func (s *AttendeeServiceImplData) SaveSavedSearch(ctx context.Context, search *entity.SavedSearch) error {
+	if search.ID == 0 {
+		_, err := database.GetRepositoryFor(ctx).AddSavedSearch(ctx, search)
+		return err
+	}
+	return database.GetRepositoryFor(ctx).UpdateSavedSearch(ctx, search)
+}
+
+func (s *AttendeeServiceImplData) DeleteSavedSearch(ctx context.Context, search *entity.SavedSearch) error {
+	if search.ID == 0 {
+		aulogging.Logger.Ctx(ctx).Error().Print("cannot delete saved search without assigned id - this is a program error")
+		return errors.New("cannot delete saved search without assigned id - this is a program error")
+	}
+
+	return database.GetRepositoryFor(ctx).DeleteSavedSearch(ctx, search)
+}
+
+func (s *AttendeeServiceImplData) CanRunSavedSearch(ctx context.Context, search *entity.SavedSearch) (bool, error) {
+	if ctxvalues.HasApiToken(ctx) || ctxvalues.IsAuthorizedAsGroup(ctx, config.OidcAdminGroup()) {
+		return true, nil
+	}
+
+	permissions := listFromCommaSeparated(removeWrappingCommas(search.Permissions))
+	loggedInSubject := ctxvalues.Subject(ctx)
+	if len(permissions) == 0 || loggedInSubject == "" {
+		return false, nil
+	}
+	return s.subjectHasDirectPermissionEntry(ctx, loggedInSubject, permissions...)
+}
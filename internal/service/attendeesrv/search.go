@@ -3,18 +3,106 @@ package attendeesrv
 import (
 	"context"
 	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
-	"sort"
-	"strings"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database/dbrepo"
 )
 
 func (s *AttendeeServiceImplData) FindAttendees(ctx context.Context, criteria *attendee.AttendeeSearchCriteria) (*attendee.AttendeeSearchResultList, error) {
-	atts, err := database.GetRepository().FindAttendees(ctx, criteria)
-	return s.mapToAttendeeSearchResults(atts, criteria.FillFields), err
+	if criteria.NumResults == 0 && criteria.Cursor == "" {
+		atts, err := database.GetRepositoryFor(ctx).FindAttendees(ctx, criteria)
+		return s.mapToAttendeeSearchResults(atts, criteria.FillFields), err
+	}
+
+	if err := validateSearchCursor(criteria); err != nil {
+		return nil, err
+	}
+
+	total, err := database.GetRepositoryFor(ctx).CountAttendees(ctx, criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	// read one more, to find out if there is a next page
+	pageCriteria := *criteria
+	if pageCriteria.NumResults > 0 {
+		pageCriteria.NumResults++
+	}
+	atts, err := database.GetRepositoryFor(ctx).FindAttendees(ctx, &pageCriteria)
+	if err != nil {
+		return nil, err
+	}
+
+	next := ""
+	if criteria.NumResults > 0 && len(atts) > int(criteria.NumResults) {
+		atts = atts[:criteria.NumResults]
+		next = dbrepo.NewSearchCursor(criteria, atts[len(atts)-1])
+	}
+
+	result := s.mapToAttendeeSearchResults(atts, criteria.FillFields)
+	result.Total = &total
+	result.Next = next
+	return result, nil
+}
+
+func validateSearchCursor(criteria *attendee.AttendeeSearchCriteria) error {
+	if _, err := dbrepo.DecodeSearchCursor(criteria); err != nil {
+		return fmt.Errorf("%w: %s", InvalidSearchCursorError, err.Error())
+	}
+	return nil
+}
+
+func (s *AttendeeServiceImplData) StreamAttendees(ctx context.Context, criteria *attendee.AttendeeSearchCriteria, f func(result *attendee.AttendeeSearchResult) error) error {
+	if err := validateSearchCursor(criteria); err != nil {
+		return err
+	}
+	return database.GetRepositoryFor(ctx).StreamAttendees(ctx, criteria, func(a *entity.AttendeeQueryResult) error {
+		result := s.mapToAttendeeSearchResult(a, criteria.FillFields)
+		return f(&result)
+	})
+}
+
+func (s *AttendeeServiceImplData) SearchResultFields(fillFields []string) []string {
+	// optional fields are omitted if empty, so every field must have a value to find out what is filled in
+	probe := entity.AttendeeQueryResult{
+		Attendee: entity.Attendee{
+			Partner:         "-",
+			Gender:          "-",
+			Pronouns:        "-",
+			TshirtSize:      "-",
+			SpokenLanguages: ",-,",
+			Flags:           ",-,",
+			Options:         ",-,",
+			Packages:        ",-,",
+			UserComments:    "-",
+			CacheDueDate:    "-",
+			Identity:        "-",
+			Avatar:          "-",
+		},
+		Status:        status.New,
+		AdminComments: "-",
+		GroupId:       1,
+	}
+	probeResult := reflect.ValueOf(s.mapToAttendeeSearchResult(&probe, fillFields))
+
+	result := make([]string, 0)
+	for i := 0; i < probeResult.NumField(); i++ {
+		field := probeResult.Field(i)
+		if (field.Kind() == reflect.Pointer || field.Kind() == reflect.Slice) && field.IsNil() {
+			continue
+		}
+		name, _, _ := strings.Cut(probeResult.Type().Field(i).Tag.Get("json"), ",")
+		result = append(result, name)
+	}
+	return result
 }
 
 func (s *AttendeeServiceImplData) mapToAttendeeSearchResults(atts []*entity.AttendeeQueryResult, fillFields []string) *attendee.AttendeeSearchResultList {
@@ -32,10 +120,11 @@ func (s *AttendeeServiceImplData) mapToAttendeeSearchResult(att *entity.Attendee
 	if len(fillFields) == 0 {
 		fillFields = []string{"nickname", "name", "country", "spoken_languages", "email", "telegram", "birthday", "pronouns",
 			"tshirt_size", "flags", "options", "packages", "user_comments", "status",
-			"total_dues", "payment_balance", "current_dues", "due_date", "registered", "admin_comments", "avatar"}
+			"currency", "total_dues", "payment_balance", "current_dues", "due_date", "registered", "admin_comments", "avatar"}
 	}
 
 	var currentDues = att.CacheTotalDues - att.CachePaymentBalance
+	currency := billingCurrency(&att.Attendee)
 	var registered = att.CreatedAt.Format(config.IsoDateFormat)
 	spokenLanguages := removeWrappingCommas(att.SpokenLanguages)
 	mergedFlags := removeWrappingCommasJoin(att.Flags, att.AdminFlags)
@@ -80,6 +169,7 @@ func (s *AttendeeServiceImplData) mapToAttendeeSearchResult(att *entity.Attendee
 		PackagesList:         containsSlice(packagesList, fillFields, "all", "configuration", "packages"),
 		UserComments:         contains(n(att.UserComments), fillFields, "all", "user_comments"),
 		Status:               contains(&att.Status, fillFields, "all", "status"),
+		Currency:             contains(p(currency), fillFields, "all", "balances", "currency"),
 		TotalDues:            contains(&att.CacheTotalDues, fillFields, "all", "balances", "total_dues"),
 		PaymentBalance:       contains(&att.CachePaymentBalance, fillFields, "all", "balances", "payment_balance"),
 		CurrentDues:          contains(&currentDues, fillFields, "all", "balances", "current_dues"),
@@ -88,7 +178,26 @@ func (s *AttendeeServiceImplData) mapToAttendeeSearchResult(att *entity.Attendee
 		AdminComments:        contains(n(att.AdminComments), fillFields, "all", "admin_comments"),
 		IdentitySubject:      contains(n(identity), fillFields, "all", "identity_subject"),
 		Avatar:               contains(n(avatar), fillFields, "all", "avatar"),
+		GroupId:              contains(groupIdIfMember(att.GroupId), fillFields, "all", "group_id"),
+		Relevance:            relevance(att.Relevance),
+	}
+}
+
+// groupIdIfMember is only present for group members.
+func groupIdIfMember(value uint) *uint {
+	if value == 0 {
+		return nil
+	}
+	return &value
+}
+
+// relevance is only present for fuzzy searches, rounded so it is easy to read.
+func relevance(value float64) *float64 {
+	if value == 0 {
+		return nil
 	}
+	rounded := math.Round(value*1000) / 1000
+	return &rounded
 }
 
 var checksumLetters = strings.Split("FJQCEKNTWLVGYHSZXDBUARP", "") // 23 letters (prime)
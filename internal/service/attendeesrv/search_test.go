@@ -13,3 +13,11 @@ func TestChecksum(t *testing.T) {
 	require.Equal(t, "X", calculateChecksum(88210))
 	require.Equal(t, "W", calculateChecksum(987666))
 }
+
+func TestSearchResultFields(t *testing.T) {
+	cut := &AttendeeServiceImplData{}
+	require.Equal(t, []string{"id", "badge_id", "nickname", "email", "partner"},
+		cut.SearchResultFields([]string{"partner", "email", "nickname"}))
+	require.Equal(t, []string{"id", "badge_id", "flags", "flags_list", "options", "options_list", "packages", "packages_list"},
+		cut.SearchResultFields([]string{"flags", "options", "packages"}))
+}
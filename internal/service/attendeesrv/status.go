@@ -9,6 +9,7 @@ import (
 
 	aulogging "github.com/StephanHCB/go-autumn-logging"
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/webhook"
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
@@ -26,7 +27,7 @@ func (s *AttendeeServiceImplData) GetFullStatusHistory(ctx context.Context, atte
 		return result, errors.New("invalid attendee missing id, please read full dataset from the database - this is an implementation error")
 	}
 
-	fromDb, err := database.GetRepository().GetStatusChangesByAttendeeId(ctx, attendee.ID)
+	fromDb, err := database.GetRepositoryFor(ctx).GetStatusChangesByAttendeeId(ctx, attendee.ID)
 	if err != nil {
 		return result, err
 	}
@@ -54,64 +55,104 @@ func (s *AttendeeServiceImplData) UpdateDuesAndDoStatusChangeIfNeeded(ctx contex
 	// controller checks precondition via StatusChangePossible
 	// attendee has been loaded from db in all cases
 
-	updatedTransactionHistory, adminInfo, err := s.UpdateDuesTransactions(ctx, attendee, newStatus, overrideDuesComment)
-	if err != nil {
-		return err
-	}
+	return database.WithTransaction(ctx, func(ctx context.Context) error {
+		updatedTransactionHistory, adminInfo, err := s.UpdateDuesTransactions(ctx, attendee, newStatus, overrideDuesComment)
+		if err != nil {
+			return err
+		}
 
-	var duesInformationChanged bool
-	newStatus, duesInformationChanged, err = s.UpdateAttendeeCacheAndCalculateResultingStatus(ctx, attendee, updatedTransactionHistory, newStatus)
-	if err != nil {
-		return err
-	}
+		var duesInformationChanged bool
+		newStatus, duesInformationChanged, err = s.UpdateAttendeeCacheAndCalculateResultingStatus(ctx, attendee, updatedTransactionHistory, newStatus)
+		if err != nil {
+			return err
+		}
 
-	if newStatus != oldStatus {
-		change := entity.StatusChange{
-			AttendeeId: attendee.ID,
-			Status:     newStatus,
-			Comments:   statusComment,
+		if newStatus == status.Paid {
+			writtenOff, err := s.writeOffWithinTolerance(ctx, attendee, adminInfo, updatedTransactionHistory)
+			if err != nil {
+				return err
+			}
+			if writtenOff {
+				updatedTransactionHistory, err = paymentservice.Get().GetTransactions(ctx, attendee.ID)
+				if err != nil {
+					return err
+				}
+				newStatus, _, err = s.UpdateAttendeeCacheAndCalculateResultingStatus(ctx, attendee, updatedTransactionHistory, newStatus)
+				if err != nil {
+					return err
+				}
+				duesInformationChanged = true
+			}
 		}
-		err = database.GetRepository().AddStatusChange(ctx, &change)
+
+		err = s.issueInvoiceIfDuesChanged(ctx, attendee, adminInfo, updatedTransactionHistory)
+		if err != nil {
+			return err
+		}
+
+		err = s.syncWaitlist(ctx, attendee, newStatus)
 		if err != nil {
 			return err
 		}
 
-		if newStatus == status.Deleted {
-			err = database.GetRepository().SoftDeleteAttendeeById(ctx, attendee.ID)
+		if newStatus != oldStatus {
+			change := entity.StatusChange{
+				AttendeeId: attendee.ID,
+				Status:     newStatus,
+				Comments:   statusComment,
+			}
+			err = database.GetRepositoryFor(ctx).AddStatusChange(ctx, &change)
 			if err != nil {
 				return err
 			}
-		} else if oldStatus == status.Deleted {
-			err = database.GetRepository().UndeleteAttendeeById(ctx, attendee.ID)
+
+			err = s.emitWebhookEvent(ctx, webhook.Event{
+				Event:      webhook.EventAttendeeStatusChanged,
+				AttendeeId: attendee.ID,
+				Status:     newStatus,
+				OldStatus:  oldStatus,
+			})
 			if err != nil {
 				return err
 			}
-		}
 
-		if newStatus != status.Deleted && newStatus != status.CheckedIn {
-			suppress := suppressMinorUpdateEmail && isPaymentPhaseStatus(oldStatus) && isPaymentPhaseStatus(newStatus)
-			err = s.sendStatusChangeNotificationEmail(ctx, attendee, adminInfo, newStatus, statusComment, suppress, asyncEmail)
+			if newStatus == status.Deleted {
+				err = database.GetRepositoryFor(ctx).SoftDeleteAttendeeById(ctx, attendee.ID)
+				if err != nil {
+					return err
+				}
+			} else if oldStatus == status.Deleted {
+				err = database.GetRepositoryFor(ctx).UndeleteAttendeeById(ctx, attendee.ID)
+				if err != nil {
+					return err
+				}
+			}
+
+			if newStatus != status.Deleted && newStatus != status.CheckedIn {
+				suppress := suppressMinorUpdateEmail && isPaymentPhaseStatus(oldStatus) && isPaymentPhaseStatus(newStatus)
+				err = s.sendStatusChangeNotificationEmail(ctx, attendee, adminInfo, newStatus, statusComment, suppress, asyncEmail)
+				if err != nil {
+					return err
+				}
+			}
+		} else if duesInformationChanged && (newStatus == status.Approved || newStatus == status.PartiallyPaid) {
+			err = s.sendStatusChangeNotificationEmail(ctx, attendee, adminInfo, newStatus, statusComment, suppressMinorUpdateEmail, asyncEmail)
 			if err != nil {
 				return err
 			}
 		}
-	} else if duesInformationChanged && (newStatus == status.Approved || newStatus == status.PartiallyPaid) {
-		err = s.sendStatusChangeNotificationEmail(ctx, attendee, adminInfo, newStatus, statusComment, suppressMinorUpdateEmail, asyncEmail)
-		if err != nil {
-			return err
-		}
-	}
 
-	return nil
+		return nil
+	})
 }
 
 func isPaymentPhaseStatus(st status.Status) bool {
 	return st == status.Approved || st == status.PartiallyPaid || st == status.Paid
 }
 
-func formatCurr(value int64) string {
+func formatCurr(currency string, value int64) string {
 	// TODO: format currency according to provided format from config
-	return fmt.Sprintf("%s %0.2f", config.Currency(), float64(value)/100.0)
+	return fmt.Sprintf("%s %0.2f", currency, float64(value)/100.0)
 }
 
 func formatDate(value string) string {
@@ -124,7 +165,7 @@ func formatDate(value string) string {
 }
 
 func (s *AttendeeServiceImplData) ResendStatusMail(ctx context.Context, attendee *entity.Attendee, currentStatus status.Status, currentStatusComment string) error {
-	adminInfo, err := database.GetRepository().GetAdminInfoByAttendeeId(ctx, attendee.ID)
+	adminInfo, err := database.GetRepositoryFor(ctx).GetAdminInfoByAttendeeId(ctx, attendee.ID)
 	if err != nil {
 		return err
 	}
@@ -152,6 +193,22 @@ func (s *AttendeeServiceImplData) sendStatusChangeNotificationEmail(ctx context.
 		dueDate = ""
 	}
 
+	_, agePrices, err := s.packageDuesByVAT(ctx, attendee, adminInfo)
+	if err != nil {
+		return err
+	}
+
+	// with a payment plan, only part of the remaining dues is due by the due date
+	installmentDue := max(remainingDues, 0)
+	schedule, err := s.installmentSchedule(ctx, attendee, attendee.CacheTotalDues, attendee.CacheDueDate)
+	if err != nil {
+		return err
+	}
+	if len(schedule) > 0 && remainingDues > 0 {
+		installmentDue = installmentOpenUntil(schedule, attendee.CachePaymentBalance, attendee.CacheDueDate)
+	}
+
+	currency := billingCurrency(attendee)
 	mailDto := mailservice.MailSendDto{
 		CommonID: "change-status-" + string(newStatus),
 		Lang:     removeWrappingCommasWithDefault(attendee.RegistrationLanguage, "en-US"),
@@ -161,10 +218,12 @@ func (s *AttendeeServiceImplData) sendStatusChangeNotificationEmail(ctx context.
 			"nickname":                   attendee.Nickname,
 			"email":                      attendee.Email,
 			"reason":                     cancelReason,
-			"remaining_dues":             formatCurr(remainingDues),
-			"total_dues":                 formatCurr(attendee.CacheTotalDues),
-			"pending_payments":           formatCurr(attendee.CacheOpenBalance),
+			"remaining_dues":             formatCurr(currency, remainingDues),
+			"total_dues":                 formatCurr(currency, attendee.CacheTotalDues),
+			"pending_payments":           formatCurr(currency, attendee.CacheOpenBalance),
 			"due_date":                   dueDate,
+			"installment_due":            formatCurr(currency, installmentDue),
+			"age_based_prices":           strings.Join(agePrices, ", "),
 			"regsys_url":                 config.RegsysPublicUrl(),
 
 			// --- unused values ---
@@ -193,12 +252,7 @@ func (s *AttendeeServiceImplData) sendStatusChangeNotificationEmail(ctx context.
 		return nil
 	}
 
-	err := mailservice.Get().SendEmail(ctx, mailDto)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return s.sendMailViaOutbox(ctx, attendee.ID, mailDto)
 }
 
 func removeWrappingCommasWithDefault(v string, defaultValue string) string {
@@ -298,8 +352,6 @@ func (s *AttendeeServiceImplData) StatusChangePossible(ctx context.Context, atte
 	}
 }
 
-var graceAmountCents int64 = 100 // TODO read from config
-
 func (s *AttendeeServiceImplData) checkNoPaymentsExist(ctx context.Context, attendee *entity.Attendee, transactionHistory []paymentservice.Transaction) error {
 	for _, tx := range transactionHistory {
 		if tx.Status == paymentservice.Valid && tx.TransactionType == paymentservice.Payment && tx.Amount.GrossCent != 0 {
@@ -310,7 +362,10 @@ func (s *AttendeeServiceImplData) checkNoPaymentsExist(ctx context.Context, atte
 }
 
 func (s *AttendeeServiceImplData) checkZeroOrNegativePaymentBalance(ctx context.Context, attendee *entity.Attendee, transactionHistory []paymentservice.Transaction) error {
-	_, paid, _, _ := s.balances(transactionHistory)
+	_, paid, _, _, err := s.balances(billingCurrency(attendee), transactionHistory)
+	if err != nil {
+		return err
+	}
 	if paid <= 0 {
 		return nil
 	} else {
@@ -319,7 +374,10 @@ func (s *AttendeeServiceImplData) checkZeroOrNegativePaymentBalance(ctx context.
 }
 
 func (s *AttendeeServiceImplData) checkPositivePaymentBalanceButNotFullPayment(ctx context.Context, attendee *entity.Attendee, transactionHistory []paymentservice.Transaction) error {
-	dues, paid, _, _ := s.balances(transactionHistory)
+	dues, paid, _, _, err := s.balances(billingCurrency(attendee), transactionHistory)
+	if err != nil {
+		return err
+	}
 	if paid >= 0 && paid < dues {
 		return nil
 	} else {
@@ -328,9 +386,13 @@ func (s *AttendeeServiceImplData) checkPositivePaymentBalanceButNotFullPayment(c
 }
 
 func (s *AttendeeServiceImplData) checkPaidInFullWithGraceAmount(ctx context.Context, attendee *entity.Attendee, transactionHistory []paymentservice.Transaction) error {
-	dues, paid, _, _ := s.balances(transactionHistory)
+	currency := billingCurrency(attendee)
+	dues, paid, _, _, err := s.balances(currency, transactionHistory)
+	if err != nil {
+		return err
+	}
 	// intentionally do not check paid >= 0, there may be negative dues (previous year refunds)
-	if paid >= dues-graceAmountCents {
+	if paid >= dues-paymentTolerance(config.PaymentTolerance(), currency, transactionHistory, dues) {
 		return nil
 	} else {
 		return InsufficientPaymentError
@@ -338,7 +400,10 @@ func (s *AttendeeServiceImplData) checkPaidInFullWithGraceAmount(ctx context.Con
 }
 
 func (s *AttendeeServiceImplData) checkPaidInFull(ctx context.Context, attendee *entity.Attendee, transactionHistory []paymentservice.Transaction) error {
-	dues, paid, _, _ := s.balances(transactionHistory)
+	dues, paid, _, _, err := s.balances(billingCurrency(attendee), transactionHistory)
+	if err != nil {
+		return err
+	}
 	if paid >= dues {
 		return nil
 	} else {
@@ -353,7 +418,7 @@ func (s *AttendeeServiceImplData) IsOwnerFor(ctx context.Context) ([]*entity.Att
 
 func (s *AttendeeServiceImplData) IsOwnedByIdentity(ctx context.Context, identity string) ([]*entity.Attendee, error) {
 	if identity != "" {
-		return database.GetRepository().FindByIdentity(ctx, identity)
+		return database.GetRepositoryFor(ctx).FindByIdentity(ctx, identity)
 	} else {
 		return make([]*entity.Attendee, 0), nil
 	}
@@ -0,0 +1,82 @@
+package attendeesrv
+
+import (
+	"context"
+	"sort"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
+)
+
+// paymentTolerance returns how much underpayment still counts as paid in full.
+//
+// The first rule that matches the currency and method of the last valid payment applies, without payments
+// the billing currency counts. If no rule matches, nothing is tolerated.
+func paymentTolerance(rules []config.PaymentToleranceConfig, currency string, transactionHistory []paymentservice.Transaction, dues int64) int64 {
+	method := ""
+	for _, tx := range transactionHistory {
+		if tx.Status == paymentservice.Valid && tx.TransactionType == paymentservice.Payment {
+			currency = tx.Amount.Currency
+			method = string(tx.Method)
+		}
+	}
+
+	for _, rule := range rules {
+		if (rule.Currency == "" || rule.Currency == currency) && (rule.Method == "" || rule.Method == method) {
+			return toleranceOfRule(rule, dues)
+		}
+	}
+	return 0
+}
+
+func toleranceOfRule(rule config.PaymentToleranceConfig, dues int64) int64 {
+	byPercent := int64(float64(max(dues, 0)) * rule.Percent / 100)
+	if rule.Percent == 0 {
+		return rule.Amount
+	}
+	if rule.Amount == 0 {
+		return byPercent
+	}
+	return min(rule.Amount, byPercent)
+}
+
+// writeOffWithinTolerance books a write-off for the dues that are left when an attendee counts as paid in full
+// thanks to the payment tolerance, so the balance ends up at zero.
+//
+// The write-off is booked at the vat rate with the highest dues. Returns true if a write-off was booked.
+func (s *AttendeeServiceImplData) writeOffWithinTolerance(ctx context.Context, attendee *entity.Attendee, adminInfo *entity.AdminInfo, transactionHistory []paymentservice.Transaction) (bool, error) {
+	currency := billingCurrency(attendee)
+	dues, paid, _, _, err := s.balances(currency, transactionHistory)
+	if err != nil {
+		return false, err
+	}
+	remaining := dues - paid
+	if remaining <= 0 || remaining > paymentTolerance(config.PaymentTolerance(), currency, transactionHistory, dues) {
+		return false, nil
+	}
+
+	duesByVAT := s.oldDuesByVAT(transactionHistory)
+	vatStrs := make([]string, 0, len(duesByVAT))
+	for vatStr := range duesByVAT {
+		vatStrs = append(vatStrs, vatStr)
+	}
+	sort.Strings(vatStrs)
+	writeOffVatStr := ""
+	for _, vatStr := range vatStrs {
+		if writeOffVatStr == "" || duesByVAT[vatStr] > duesByVAT[writeOffVatStr] {
+			writeOffVatStr = vatStr
+		}
+	}
+
+	writeOffTx := s.duesTransactionForAttendee(attendee, adminInfo, -remaining, writeOffVatStr, "write-off of remaining dues within payment tolerance")
+	reason := s.duesReasonData(attendee, adminInfo)
+	reason.WriteOff = true
+	writeOffTx.Reason = encodeDuesReason(reason)
+	if err := paymentservice.Get().AddTransaction(ctx, writeOffTx); err != nil {
+		return false, err
+	}
+	aulogging.Logger.Ctx(ctx).Info().Printf("wrote off %d cents of remaining dues for attendee id %d", remaining, attendee.ID)
+	return true, nil
+}
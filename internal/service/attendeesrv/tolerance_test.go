@@ -0,0 +1,49 @@
+package attendeesrv
+
+import (
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func tstPayment(currency string, method paymentservice.PaymentMethod, status paymentservice.TransactionStatus) paymentservice.Transaction {
+	return paymentservice.Transaction{
+		TransactionType: paymentservice.Payment,
+		Method:          method,
+		Amount:          paymentservice.Amount{Currency: currency, GrossCent: 10000},
+		Status:          status,
+	}
+}
+
+func TestToleranceOfRule(t *testing.T) {
+	docs.Description("a rule with both an amount and a percentage tolerates the smaller of the two")
+	require.Equal(t, int64(100), toleranceOfRule(config.PaymentToleranceConfig{Amount: 100}, 25500))
+	require.Equal(t, int64(255), toleranceOfRule(config.PaymentToleranceConfig{Percent: 1}, 25500))
+	require.Equal(t, int64(100), toleranceOfRule(config.PaymentToleranceConfig{Amount: 100, Percent: 1}, 25500))
+	require.Equal(t, int64(50), toleranceOfRule(config.PaymentToleranceConfig{Amount: 100, Percent: 1}, 5000))
+	require.Equal(t, int64(0), toleranceOfRule(config.PaymentToleranceConfig{}, 25500))
+}
+
+func TestPaymentTolerance(t *testing.T) {
+	docs.Description("the first rule matching the currency and method of the last valid payment applies")
+	rules := []config.PaymentToleranceConfig{
+		{Currency: "USD", Amount: 300},
+		{Method: "transfer", Amount: 200},
+		{Currency: "EUR", Amount: 100},
+	}
+	history := []paymentservice.Transaction{
+		tstPayment("EUR", paymentservice.Transfer, paymentservice.Valid),
+		tstPayment("USD", paymentservice.Credit, paymentservice.Deleted),
+	}
+	require.Equal(t, int64(100), paymentTolerance(rules, "EUR", []paymentservice.Transaction{}, 25500))
+	require.Equal(t, int64(300), paymentTolerance(rules, "USD", []paymentservice.Transaction{}, 25500))
+	require.Equal(t, int64(200), paymentTolerance(rules, "EUR", history, 25500))
+
+	history = append(history, tstPayment("EUR", paymentservice.Paypal, paymentservice.Valid))
+	require.Equal(t, int64(100), paymentTolerance(rules, "EUR", history, 25500))
+
+	history = append(history, tstPayment("CHF", paymentservice.Paypal, paymentservice.Valid))
+	require.Equal(t, int64(0), paymentTolerance(rules, "EUR", history, 25500))
+}
@@ -0,0 +1,186 @@
+package attendeesrv
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/mailservice"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
+)
+
+// how long the recipient has to accept a transfer offer
+const transferValidity = 14 * 24 * time.Hour
+
+func (s *AttendeeServiceImplData) GetPendingTransfer(ctx context.Context, attendeeId uint) (*entity.Transfer, error) {
+	return database.GetRepositoryFor(ctx).GetPendingTransferFor(ctx, attendeeId)
+}
+
+func (s *AttendeeServiceImplData) GetTransferByCode(ctx context.Context, code string) (*entity.Transfer, error) {
+	return database.GetRepositoryFor(ctx).GetTransferByCode(ctx, code)
+}
+
+func (s *AttendeeServiceImplData) OfferTransfer(ctx context.Context, attendee *entity.Attendee, email string) (*entity.Transfer, error) {
+	code, err := transferCode()
+	if err != nil {
+		return nil, err
+	}
+
+	transfer := &entity.Transfer{
+		AttendeeId:   attendee.ID,
+		FromIdentity: attendee.Identity,
+		ToEmail:      email,
+		Code:         code,
+		State:        entity.TransferStatePending,
+		ExpiresAt:    s.Now().Add(transferValidity),
+	}
+
+	err = database.WithTransaction(ctx, func(ctx context.Context) error {
+		currentStatus, err := s.currentStatus(ctx, attendee)
+		if err != nil {
+			return err
+		}
+		if currentStatus == status.Cancelled || currentStatus == status.Deleted {
+			return TransferStatusError
+		}
+
+		// a new offer replaces the previous one, so only the latest recipient can accept
+		if previous, err := database.GetRepositoryFor(ctx).GetPendingTransferFor(ctx, attendee.ID); err == nil {
+			previous.State = entity.TransferStateCancelled
+			if err := database.GetRepositoryFor(ctx).UpdateTransfer(ctx, previous); err != nil {
+				return err
+			}
+		}
+
+		if _, err := database.GetRepositoryFor(ctx).AddTransfer(ctx, transfer); err != nil {
+			return err
+		}
+
+		aulogging.Logger.Ctx(ctx).Info().Printf("attendee %d offered for transfer to %s by %s", attendee.ID, email, ctxvalues.Subject(ctx))
+		return s.sendTransferEmail(ctx, attendee, transfer, "transfer-offered", email, attendee)
+	})
+	return transfer, err
+}
+
+func (s *AttendeeServiceImplData) CancelTransfer(ctx context.Context, transfer *entity.Transfer) error {
+	if transfer.State != entity.TransferStatePending {
+		return TransferNotPendingError
+	}
+
+	aulogging.Logger.Ctx(ctx).Info().Printf("transfer %d of attendee %d cancelled by %s", transfer.ID, transfer.AttendeeId, ctxvalues.Subject(ctx))
+	transfer.State = entity.TransferStateCancelled
+	return database.GetRepositoryFor(ctx).UpdateTransfer(ctx, transfer)
+}
+
+func (s *AttendeeServiceImplData) AcceptTransfer(ctx context.Context, transfer *entity.Transfer, attendee *entity.Attendee) error {
+	if transfer.State != entity.TransferStatePending {
+		return TransferNotPendingError
+	}
+	if !s.Now().Before(transfer.ExpiresAt) {
+		return TransferExpiredError
+	}
+
+	recipient := ctxvalues.Subject(ctx)
+	if recipient == "" || recipient == transfer.FromIdentity {
+		return TransferRecipientError
+	}
+
+	return database.WithTransaction(ctx, func(ctx context.Context) error {
+		alreadyHasRegistration, err := userAlreadyHasAnotherRegistration(ctx, recipient, 0)
+		if err != nil {
+			return err
+		}
+		if alreadyHasRegistration {
+			return TransferRecipientError
+		}
+
+		// the recipient must pass the same ban rules as a new registration
+		if err := s.matchesBanAndNoSkip(ctx, attendee); err != nil {
+			return err
+		}
+
+		currentStatus, err := s.currentStatus(ctx, attendee)
+		if err != nil {
+			return err
+		}
+		if currentStatus == status.Cancelled || currentStatus == status.Deleted {
+			return TransferStatusError
+		}
+
+		previous, err := database.GetRepositoryFor(ctx).GetAttendeeById(ctx, attendee.ID)
+		if err != nil {
+			return err
+		}
+
+		attendee.Identity = recipient
+		attendee.Avatar = ctxvalues.Avatar(ctx)
+		if err := s.UpdateAttendee(ctx, attendee, true); err != nil {
+			return err
+		}
+
+		transfer.State = entity.TransferStateAccepted
+		transfer.ToIdentity = recipient
+		if err := database.GetRepositoryFor(ctx).UpdateTransfer(ctx, transfer); err != nil {
+			return err
+		}
+
+		aulogging.Logger.Ctx(ctx).Info().Printf("attendee %d transferred from %s to %s", attendee.ID, transfer.FromIdentity, recipient)
+		if err := s.sendTransferEmail(ctx, attendee, transfer, "transfer-given", previous.Email, previous); err != nil {
+			return err
+		}
+		return s.sendTransferEmail(ctx, attendee, transfer, "transfer-received", attendee.Email, previous)
+	})
+}
+
+func (s *AttendeeServiceImplData) currentStatus(ctx context.Context, attendee *entity.Attendee) (status.Status, error) {
+	history, err := s.GetFullStatusHistory(ctx, attendee)
+	if err != nil {
+		return "", err
+	}
+	if len(history) == 0 {
+		return "", fmt.Errorf("got empty status change history for attendee %d", attendee.ID)
+	}
+	return history[len(history)-1].Status, nil
+}
+
+// sendTransferEmail informs one of the parties of a transfer. The previous owner's nickname is included,
+// so the recipient knows who the offer is from.
+func (s *AttendeeServiceImplData) sendTransferEmail(ctx context.Context, attendee *entity.Attendee, transfer *entity.Transfer, commonId string, to string, previous *entity.Attendee) error {
+	checkSummedId := s.badgeId(attendee.ID)
+	mailDto := mailservice.MailSendDto{
+		CommonID: commonId,
+		Lang:     removeWrappingCommasWithDefault(attendee.RegistrationLanguage, "en-US"),
+		Variables: map[string]string{
+			"badge_number":               fmt.Sprintf("%d", attendee.ID),
+			"badge_number_with_checksum": *checkSummedId,
+			"nickname":                   attendee.Nickname,
+			"previous_nickname":          previous.Nickname,
+			"email":                      to,
+			"transfer_code":              transfer.Code,
+			"expires":                    transfer.ExpiresAt.Format(config.HumanDateFormat),
+			"regsys_url":                 config.RegsysPublicUrl(),
+		},
+		To: []string{to},
+	}
+	if commonId != "transfer-offered" {
+		// the code is only for the recipient, and useless once accepted
+		delete(mailDto.Variables, "transfer_code")
+	}
+	return s.sendMailViaOutbox(ctx, attendee.ID, mailDto)
+}
+
+// transferCode returns a random code that is hard to guess, so only the recipient of the offer mail can accept.
+func transferCode() (string, error) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
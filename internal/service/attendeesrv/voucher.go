@@ -0,0 +1,156 @@
+package attendeesrv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
+	"gorm.io/gorm"
+)
+
+func (s *AttendeeServiceImplData) GetAllVouchers(ctx context.Context) ([]*entity.Voucher, error) {
+	return database.GetRepositoryFor(ctx).GetAllVouchers(ctx)
+}
+
+func (s *AttendeeServiceImplData) GetVoucher(ctx context.Context, code string) (*entity.Voucher, error) {
+	return database.GetRepositoryFor(ctx).GetVoucherByCode(ctx, strings.ToUpper(code))
+}
+
+func (s *AttendeeServiceImplData) CountVoucherUses(ctx context.Context, voucher *entity.Voucher) (int, error) {
+	redemptions, err := database.GetRepositoryFor(ctx).GetVoucherRedemptions(ctx, voucher.ID)
+	return len(redemptions), err
+}
+
+func (s *AttendeeServiceImplData) SaveVoucher(ctx context.Context, voucher *entity.Voucher) error {
+	voucher.Code = strings.ToUpper(voucher.Code)
+	if voucher.ID == 0 {
+		_, err := database.GetRepositoryFor(ctx).AddVoucher(ctx, voucher)
+		return err
+	}
+
+	// changes to the discount apply to everyone who has already redeemed the voucher, but only
+	// with the next dues adjustment for each attendee
+	return database.GetRepositoryFor(ctx).UpdateVoucher(ctx, voucher)
+}
+
+func (s *AttendeeServiceImplData) DeleteVoucher(ctx context.Context, voucher *entity.Voucher) error {
+	if voucher.ID == 0 {
+		aulogging.Logger.Ctx(ctx).Error().Print("cannot delete voucher without assigned id - this is a program error")
+		return errors.New("cannot delete voucher without assigned id - this is a program error")
+	}
+
+	return database.WithTransaction(ctx, func(ctx context.Context) error {
+		uses, err := s.CountVoucherUses(ctx, voucher)
+		if err != nil {
+			return err
+		}
+		if uses > 0 {
+			return VoucherInUseError
+		}
+
+		aulogging.Logger.Ctx(ctx).Info().Printf("voucher %s deleted by %s", voucher.Code, ctxvalues.Subject(ctx))
+		return database.GetRepositoryFor(ctx).DeleteVoucher(ctx, voucher)
+	})
+}
+
+func (s *AttendeeServiceImplData) GetRedeemedVouchers(ctx context.Context, attendeeId uint) ([]*entity.Voucher, error) {
+	result := make([]*entity.Voucher, 0)
+	redemptions, err := database.GetRepositoryFor(ctx).GetVoucherRedemptionsByAttendeeId(ctx, attendeeId)
+	if err != nil {
+		return result, err
+	}
+	for _, vr := range redemptions {
+		voucher, err := database.GetRepositoryFor(ctx).GetVoucherById(ctx, vr.VoucherId)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, voucher)
+	}
+	return result, nil
+}
+
+func (s *AttendeeServiceImplData) RedeemVoucher(ctx context.Context, attendee *entity.Attendee, code string) error {
+	return database.WithTransaction(ctx, func(ctx context.Context) error {
+		// locked, so concurrent redemptions cannot both take the last use
+		voucher, err := database.GetRepositoryFor(ctx).GetVoucherByCodeForUpdate(ctx, strings.ToUpper(code))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return VoucherNotFoundError
+		} else if err != nil {
+			return err
+		}
+
+		currentStatus, err := s.currentStatus(ctx, attendee)
+		if err != nil {
+			return err
+		}
+		if currentStatus == status.Cancelled || currentStatus == status.Deleted {
+			return VoucherStatusError
+		}
+
+		if voucher.ValidUntil != "" && s.Now().Format(config.IsoDateFormat) > voucher.ValidUntil {
+			return VoucherExpiredError
+		}
+		if _, err := database.GetRepositoryFor(ctx).GetVoucherRedemption(ctx, voucher.ID, attendee.ID); err == nil {
+			return VoucherAlreadyRedeemedError
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if voucher.MaxUses > 0 {
+			uses, err := s.CountVoucherUses(ctx, voucher)
+			if err != nil {
+				return err
+			}
+			if uses >= voucher.MaxUses {
+				return VoucherUsedUpError
+			}
+		}
+		if voucher.Package != "" && choiceStrToMap(attendee.Packages, config.PackagesConfig())[voucher.Package] == 0 {
+			return VoucherNotApplicableError
+		}
+		if voucher.Kind == entity.VoucherKindFixed && voucherCurrency(voucher) != billingCurrency(attendee) {
+			return VoucherCurrencyError
+		}
+
+		redemption := &entity.VoucherRedemption{
+			VoucherId:  voucher.ID,
+			AttendeeId: attendee.ID,
+		}
+		if _, err := database.GetRepositoryFor(ctx).AddVoucherRedemption(ctx, redemption); err != nil {
+			return err
+		}
+
+		subject := ctxvalues.Subject(ctx)
+		aulogging.Logger.Ctx(ctx).Info().Printf("voucher %s redeemed for attendee %d by %s", voucher.Code, attendee.ID, subject)
+		return s.UpdateDuesAndDoStatusChangeIfNeeded(ctx, attendee, currentStatus, currentStatus, fmt.Sprintf("voucher %s redeemed by %s", voucher.Code, subject), "", false, false)
+	})
+}
+
+func (s *AttendeeServiceImplData) RemoveVoucherRedemption(ctx context.Context, attendee *entity.Attendee, voucher *entity.Voucher) error {
+	return database.WithTransaction(ctx, func(ctx context.Context) error {
+		redemption, err := database.GetRepositoryFor(ctx).GetVoucherRedemption(ctx, voucher.ID, attendee.ID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return VoucherNotRedeemedError
+		} else if err != nil {
+			return err
+		}
+		if err := database.GetRepositoryFor(ctx).DeleteVoucherRedemption(ctx, redemption); err != nil {
+			return err
+		}
+
+		currentStatus, err := s.currentStatus(ctx, attendee)
+		if err != nil {
+			return err
+		}
+
+		subject := ctxvalues.Subject(ctx)
+		aulogging.Logger.Ctx(ctx).Info().Printf("voucher %s removed from attendee %d by %s", voucher.Code, attendee.ID, subject)
+		return s.UpdateDuesAndDoStatusChangeIfNeeded(ctx, attendee, currentStatus, currentStatus, fmt.Sprintf("voucher %s removed by %s", voucher.Code, subject), "", false, false)
+	})
+}
@@ -0,0 +1,173 @@
+package attendeesrv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+)
+
+func (s *AttendeeServiceImplData) GetWaitlist(ctx context.Context, key string) ([]*entity.WaitlistEntry, error) {
+	return database.GetRepositoryFor(ctx).GetWaitlistForPackage(ctx, key)
+}
+
+func (s *AttendeeServiceImplData) PlaceOnWaitlist(ctx context.Context, attendee *entity.Attendee, queued map[string]int) error {
+	if len(queued) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0)
+	for key := range queued {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		entry := entity.WaitlistEntry{
+			Package:    key,
+			AttendeeId: attendee.ID,
+			Count:      queued[key],
+		}
+		if _, err := database.GetRepositoryFor(ctx).AddWaitlistEntry(ctx, &entry); err != nil {
+			return err
+		}
+	}
+
+	comment := fmt.Sprintf("placed on waiting list for %s", strings.Join(keys, ","))
+	return s.UpdateDuesAndDoStatusChangeIfNeeded(ctx, attendee, status.New, status.Waiting, comment, "", false, false)
+}
+
+// syncWaitlist makes sure the waiting list entries for an attendee match their current status and packages.
+//
+// Only attendees in status waiting can be queued, and never for more than they have currently selected.
+func (s *AttendeeServiceImplData) syncWaitlist(ctx context.Context, attendee *entity.Attendee, currentStatus status.Status) error {
+	if !waitlistConfigured() {
+		return nil
+	}
+
+	entries, err := database.GetRepositoryFor(ctx).GetWaitlistEntriesByAttendeeId(ctx, attendee.ID)
+	if err != nil {
+		return err
+	}
+
+	selected := choiceStrToMap(attendee.Packages, config.PackagesConfig())
+	for _, entry := range entries {
+		if currentStatus != status.Waiting || selected[entry.Package] == 0 {
+			if err := database.GetRepositoryFor(ctx).DeleteWaitlistEntry(ctx, entry); err != nil {
+				return err
+			}
+		} else if selected[entry.Package] < entry.Count {
+			entry.Count = selected[entry.Package]
+			if err := database.GetRepositoryFor(ctx).UpdateWaitlistEntry(ctx, entry); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// promoteFromWaitlist approves queued attendees in queue order, as long as there is stock available.
+//
+// Entries that cannot be promoted at this time (not enough stock for their count, also queued for another
+// package that is still sold out, or matching a ban rule) are skipped, but keep their place in the queue.
+func (s *AttendeeServiceImplData) promoteFromWaitlist(ctx context.Context, key string) error {
+	limit := config.PackagesConfig()[key].Limit
+
+	queue, err := database.GetRepositoryFor(ctx).GetWaitlistForPackage(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range queue {
+		current, err := database.GetRepositoryFor(ctx).GetCount(ctx, entity.CountAreaPackage, key)
+		if err != nil {
+			return err
+		}
+		if current.Pending+current.Attending >= limit {
+			return nil
+		}
+
+		if err := s.promoteWaitlistEntry(ctx, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *AttendeeServiceImplData) promoteWaitlistEntry(ctx context.Context, entry *entity.WaitlistEntry) error {
+	db := database.GetRepositoryFor(ctx)
+
+	attendee, err := db.GetAttendeeById(ctx, entry.AttendeeId)
+	if err != nil {
+		return err
+	}
+
+	latestStatusChange, err := db.GetLatestStatusChangeByAttendeeId(ctx, attendee.ID)
+	if err != nil {
+		return err
+	}
+	if latestStatusChange.Status != status.Waiting {
+		aulogging.Logger.Ctx(ctx).Warn().Printf("removing stale waiting list entry for attendee %d package %s - status is %s", attendee.ID, entry.Package, latestStatusChange.Status)
+		return db.DeleteWaitlistEntry(ctx, entry)
+	}
+
+	deltas, err := s.ComputeDeltasAndCheckLimitOverrun(ctx, attendee, attendee, status.Waiting, status.Approved)
+	if err != nil {
+		if errors.Is(err, IntroducesOverrun) {
+			aulogging.Logger.Ctx(ctx).Info().Printf("cannot promote attendee %d from waiting list for package %s yet: %s", attendee.ID, entry.Package, err.Error())
+			return nil
+		}
+		return err
+	}
+
+	if err := s.StatusChangePossible(ctx, attendee, status.Waiting, status.Approved); err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().Printf("not promoting attendee %d from waiting list for package %s: %s", attendee.ID, entry.Package, err.Error())
+		return nil
+	}
+
+	comment := fmt.Sprintf("promoted from waiting list for %s", entry.Package)
+	if err := s.UpdateDuesAndDoStatusChangeIfNeeded(ctx, attendee, status.Waiting, status.Approved, comment, "", false, false); err != nil {
+		return err
+	}
+
+	for _, delta := range deltas {
+		if _, err := db.AddCount(ctx, delta); err != nil {
+			return err
+		}
+	}
+
+	aulogging.Logger.Ctx(ctx).Info().Printf("promoted attendee %d from waiting list for package %s", attendee.ID, entry.Package)
+	return nil
+}
+
+func queuedCountMap(ctx context.Context, attendeeId uint) (map[string]int, error) {
+	result := make(map[string]int)
+	if !waitlistConfigured() {
+		return result, nil
+	}
+
+	entries, err := database.GetRepositoryFor(ctx).GetWaitlistEntriesByAttendeeId(ctx, attendeeId)
+	if err != nil {
+		return result, err
+	}
+	for _, entry := range entries {
+		result[entry.Package] = entry.Count
+	}
+	return result, nil
+}
+
+func waitlistConfigured() bool {
+	for _, conf := range config.PackagesConfig() {
+		if conf.Waitlist {
+			return true
+		}
+	}
+	return false
+}
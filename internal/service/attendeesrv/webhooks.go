@@ -0,0 +1,259 @@
+package attendeesrv
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/webhook"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/webhookservice"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/validation"
+	"github.com/google/uuid"
+)
+
+// how many pending deliveries per subscriber the dispatcher picks up per run
+const webhookDispatchBatchSize = 50
+
+// how long a background delivery run started after a change may take
+const webhookBackgroundDispatchTimeout = 5 * time.Minute
+
+// how much longer than the configured timeout of a subscriber a delivery attempt may take before the lock
+// on its queue is considered lost
+const webhookQueueLockMargin = time.Minute
+
+const (
+	WebhookHeaderEvent     = "X-Webhook-Event"
+	WebhookHeaderDelivery  = "X-Webhook-Delivery"
+	WebhookHeaderTimestamp = "X-Webhook-Timestamp"
+	WebhookHeaderSignature = "X-Webhook-Signature"
+)
+
+func (s *AttendeeServiceImplData) GetWebhookDeliveries(ctx context.Context, subscriber string, state string, attendeeId uint, limit int) ([]*entity.WebhookDelivery, error) {
+	return database.GetRepositoryFor(ctx).GetWebhookDeliveryLog(ctx, subscriber, state, attendeeId, limit)
+}
+
+func (s *AttendeeServiceImplData) DispatchWebhooks(ctx context.Context) error {
+	subscribers := make([]string, 0)
+	for name := range config.WebhooksConfig() {
+		subscribers = append(subscribers, name)
+	}
+	sort.Strings(subscribers)
+
+	for _, name := range subscribers {
+		if err := s.dispatchWebhookQueue(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WaitForWebhookDispatches blocks until the deliveries started in the background after earlier changes are done.
+//
+// Undelivered events survive a shutdown in the database, so this is only needed by tests.
+func (s *AttendeeServiceImplData) WaitForWebhookDispatches() {
+	s.webhookDispatches.Wait()
+}
+
+// emitWebhookEvent queues the event for all subscribers that want it, then starts delivery in the
+// background once the current unit of work has been committed.
+func (s *AttendeeServiceImplData) emitWebhookEvent(ctx context.Context, event webhook.Event) error {
+	subscribers := webhookSubscribersFor(event.Event)
+	if len(subscribers) == 0 {
+		return nil
+	}
+
+	event.Timestamp = s.Now().Format(time.RFC3339)
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range subscribers {
+		delivery := entity.WebhookDelivery{
+			Subscriber:  name,
+			State:       entity.WebhookStatePending,
+			Event:       event.Event,
+			AttendeeId:  event.AttendeeId,
+			NextAttempt: s.Now(),
+			Payload:     string(payload),
+		}
+		if _, err := database.GetRepositoryFor(ctx).AddWebhookDelivery(ctx, &delivery); err != nil {
+			return err
+		}
+	}
+
+	return database.AfterCommit(ctx, func(ctx context.Context) error {
+		s.wakeWebhookDispatch(ctx)
+		return nil
+	})
+}
+
+// wakeWebhookDispatch delivers all webhook queues in the background, so a slow or unavailable subscriber
+// cannot make the request that caused an event time out.
+//
+// If a run is already going on, another run follows it, so events queued in the meantime are not left
+// waiting for the periodic dispatcher.
+//
+// The in-memory database is not safe for concurrent use, so then the run happens right away instead.
+func (s *AttendeeServiceImplData) wakeWebhookDispatch(ctx context.Context) {
+	if config.DatabaseUse() == config.Inmemory {
+		s.runWebhookDispatch(ctx)
+		return
+	}
+
+	s.webhookDispatchMu.Lock()
+	defer s.webhookDispatchMu.Unlock()
+	if s.webhookDispatchRunning {
+		s.webhookDispatchRunAgain = true
+		return
+	}
+	s.webhookDispatchRunning = true
+
+	// keeps the logger and request id, but not the deadline of the request
+	runCtx := context.WithoutCancel(ctx)
+	s.webhookDispatches.Add(1)
+	go func() {
+		defer s.webhookDispatches.Done()
+		for {
+			s.runWebhookDispatch(runCtx)
+
+			s.webhookDispatchMu.Lock()
+			again := s.webhookDispatchRunAgain
+			s.webhookDispatchRunAgain = false
+			s.webhookDispatchRunning = again
+			s.webhookDispatchMu.Unlock()
+			if !again {
+				return
+			}
+		}
+	}()
+}
+
+func (s *AttendeeServiceImplData) runWebhookDispatch(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, webhookBackgroundDispatchTimeout)
+	defer cancel()
+	if err := s.DispatchWebhooks(ctx); err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("background webhook dispatch failed: %s", err.Error())
+	}
+}
+
+// dispatchWebhookQueue delivers pending events to a subscriber in queue order.
+//
+// Stops at the first event that is still waiting for a retry, so a subscriber never receives events out of order.
+// Only one dispatcher works on the queue of a subscriber at a time, anyone else leaves it alone.
+// Only returns an error if the outcome of a delivery attempt could not be recorded.
+func (s *AttendeeServiceImplData) dispatchWebhookQueue(ctx context.Context, subscriber string) error {
+	conf, ok := config.WebhooksConfig()[subscriber]
+	if !ok {
+		return nil
+	}
+
+	db := database.GetRepositoryFor(ctx)
+	holder := uuid.NewString()
+	lockDuration := time.Duration(conf.TimeoutSeconds)*time.Second + webhookQueueLockMargin
+	locked, err := db.LockWebhookQueue(ctx, subscriber, holder, s.Now(), s.Now().Add(lockDuration))
+	if err != nil {
+		return err
+	}
+	if !locked {
+		aulogging.Logger.Ctx(ctx).Debug().Printf("webhook queue for %s is being delivered by someone else, skipping", subscriber)
+		return nil
+	}
+	defer func() {
+		_ = db.UnlockWebhookQueue(ctx, subscriber, holder)
+	}()
+
+	// read after locking, so deliveries completed by the previous holder are not repeated
+	pending, err := db.GetPendingWebhookDeliveries(ctx, subscriber, webhookDispatchBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, delivery := range pending {
+		if delivery.NextAttempt.After(s.Now()) {
+			return nil
+		}
+		// extend the lock for each attempt, so a long batch cannot outlive it
+		locked, err := db.LockWebhookQueue(ctx, subscriber, holder, s.Now(), s.Now().Add(lockDuration))
+		if err != nil {
+			return err
+		}
+		if !locked {
+			aulogging.Logger.Ctx(ctx).Warn().Printf("lost the lock on the webhook queue for %s, stopping", subscriber)
+			return nil
+		}
+
+		if err := s.deliverWebhook(ctx, conf, delivery); err != nil {
+			return err
+		}
+		if delivery.State == entity.WebhookStatePending {
+			return nil
+		}
+	}
+	return nil
+}
+
+// deliverWebhook makes a single delivery attempt and records the outcome.
+func (s *AttendeeServiceImplData) deliverWebhook(ctx context.Context, conf config.WebhookConfig, delivery *entity.WebhookDelivery) error {
+	timestamp := fmt.Sprintf("%d", s.Now().Unix())
+	request := webhookservice.DeliveryRequest{
+		Url: conf.Url,
+		Headers: map[string]string{
+			WebhookHeaderEvent:     delivery.Event,
+			WebhookHeaderDelivery:  fmt.Sprintf("%d", delivery.ID),
+			WebhookHeaderTimestamp: timestamp,
+			WebhookHeaderSignature: signWebhookPayload(conf.Secret, timestamp, []byte(delivery.Payload)),
+		},
+		Body:    []byte(delivery.Payload),
+		Timeout: time.Duration(conf.TimeoutSeconds) * time.Second,
+	}
+
+	delivery.Attempts++
+	httpStatus, err := webhookservice.Get().Deliver(ctx, request)
+	delivery.LastStatus = httpStatus
+	if err != nil {
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= config.WebhookMaxAttempts() {
+			aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("giving up on webhook delivery %d (%s) to %s after %d attempts: %s", delivery.ID, delivery.Event, delivery.Subscriber, delivery.Attempts, err.Error())
+			delivery.State = entity.WebhookStateFailed
+		} else {
+			delivery.NextAttempt = s.Now().Add(retryBackoff(delivery.Attempts, config.WebhookInitialBackoff(), config.WebhookMaxBackoff()))
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("failed to deliver webhook %d (%s) to %s, will retry after %s: %s", delivery.ID, delivery.Event, delivery.Subscriber, delivery.NextAttempt.Format(time.RFC3339), err.Error())
+		}
+	} else {
+		delivery.State = entity.WebhookStateSent
+		delivery.LastError = ""
+	}
+
+	return database.GetRepositoryFor(ctx).UpdateWebhookDelivery(ctx, delivery)
+}
+
+// signWebhookPayload computes the signature subscribers use to verify an event.
+//
+// The signature is a hex encoded HMAC-SHA256 over the timestamp header value, a dot, and the raw request body.
+func signWebhookPayload(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func webhookSubscribersFor(event string) []string {
+	result := make([]string, 0)
+	for name, conf := range config.WebhooksConfig() {
+		if len(conf.Events) == 0 || validation.SliceContains(conf.Events, event) {
+			result = append(result, name)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,25 @@
+package attendeesrv
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestSignWebhookPayload(t *testing.T) {
+	actual := signWebhookPayload("some-webhook-secret", "1670457600", []byte(`{"event":"attendee.registered"}`))
+	require.Equal(t, "sha256=65ff4f036df0df8479713cdfa7a194fe65f50e161ccadd6c7eaa7d1701e56588", actual)
+}
+
+func TestSignWebhookPayload_TimestampIsSigned(t *testing.T) {
+	body := []byte(`{"event":"attendee.registered"}`)
+	require.NotEqual(t, signWebhookPayload("some-webhook-secret", "1670457600", body), signWebhookPayload("some-webhook-secret", "1670457601", body))
+}
+
+func TestRetryBackoff(t *testing.T) {
+	require.Equal(t, 30*time.Second, retryBackoff(1, 30*time.Second, 5*time.Minute))
+	require.Equal(t, 60*time.Second, retryBackoff(2, 30*time.Second, 5*time.Minute))
+	require.Equal(t, 240*time.Second, retryBackoff(4, 30*time.Second, 5*time.Minute))
+	require.Equal(t, 5*time.Minute, retryBackoff(5, 30*time.Second, 5*time.Minute))
+	require.Equal(t, 5*time.Minute, retryBackoff(50, 30*time.Second, 5*time.Minute))
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/eurofurence/reg-attendee-service/internal/repository/mailservice"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/selfclient"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/webhookservice"
 	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
 	"sync"
 	"time"
@@ -40,9 +41,28 @@ func (i *Impl) Run() int {
 		return 1
 	}
 	defer database.Close()
+	if config.MigrateStatus() {
+		if err := database.LogMigrationStatus(); err != nil {
+			return 1
+		}
+		return 0
+	}
+	if version, ok := config.MigrateDownTo(); ok {
+		if err := database.MigrateDownTo(version); err != nil {
+			return 1
+		}
+		return 0
+	}
+	if err := database.CheckSchemaVersion(); err != nil {
+		return 1
+	}
 	if err := database.MigrateIfSwitchedOn(); err != nil {
 		return 1
 	}
+	if config.MigrateOnly() {
+		aulogging.Logger.NoCtx().Info().Print("Database migrated. Exiting because of -migrate-only.")
+		return 0
+	}
 
 	if err := paymentservice.Create(); err != nil {
 		return 1
@@ -53,6 +73,7 @@ func (i *Impl) Run() int {
 	if err := authservice.Create(); err != nil {
 		return 1
 	}
+	webhookservice.Create()
 
 	attendeeService := attendeesrv.New()
 	if err := runServerWithGracefulShutdown(attendeeService); err != nil {
@@ -76,6 +97,9 @@ func (i *Impl) Datagen() int {
 		return 1
 	}
 	defer database.Close()
+	if err := database.CheckSchemaVersion(); err != nil {
+		return 1
+	}
 
 	ctx := auzerolog.AddLoggerToCtx(context.Background())
 
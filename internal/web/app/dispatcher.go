@@ -0,0 +1,40 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	auzerolog "github.com/StephanHCB/go-autumn-logging-zerolog"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
+)
+
+// runOutboxDispatcher periodically retries delivery of pending outbox mails until ctx is cancelled.
+func runOutboxDispatcher(ctx context.Context, attSrv attendeesrv.AttendeeService) {
+	runPeriodically(ctx, "outbox", config.MailOutboxDispatchInterval(), attSrv.DispatchOutboxMails)
+}
+
+// runWebhookDispatcher periodically retries delivery of pending webhook events until ctx is cancelled.
+func runWebhookDispatcher(ctx context.Context, attSrv attendeesrv.AttendeeService) {
+	runPeriodically(ctx, "webhook", config.WebhookDispatchInterval(), attSrv.DispatchWebhooks)
+}
+
+func runPeriodically(ctx context.Context, name string, interval time.Duration, dispatch func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			aulogging.Logger.NoCtx().Debug().Printf("%s dispatcher stopped", name)
+			return
+		case <-ticker.C:
+			runCtx := ctxvalues.CreateContextWithValueMap(auzerolog.AddLoggerToCtx(context.Background()))
+			if err := dispatch(runCtx); err != nil {
+				aulogging.Logger.Ctx(runCtx).Error().WithErr(err).Printf("%s dispatch failed: %s", name, err.Error())
+			}
+		}
+	}
+}
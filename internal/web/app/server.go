@@ -20,9 +20,13 @@ import (
 	"github.com/eurofurence/reg-attendee-service/internal/web/controller/banctl"
 	"github.com/eurofurence/reg-attendee-service/internal/web/controller/countdownctl"
 	"github.com/eurofurence/reg-attendee-service/internal/web/controller/fallbackctl"
+	"github.com/eurofurence/reg-attendee-service/internal/web/controller/groupctl"
 	"github.com/eurofurence/reg-attendee-service/internal/web/controller/infoctl"
+	"github.com/eurofurence/reg-attendee-service/internal/web/controller/outboxctl"
 	"github.com/eurofurence/reg-attendee-service/internal/web/controller/packagectl"
 	"github.com/eurofurence/reg-attendee-service/internal/web/controller/statusctl"
+	"github.com/eurofurence/reg-attendee-service/internal/web/controller/voucherctl"
+	"github.com/eurofurence/reg-attendee-service/internal/web/controller/webhookctl"
 	"github.com/eurofurence/reg-attendee-service/internal/web/middleware"
 	"github.com/go-chi/chi/v5"
 )
@@ -43,8 +47,12 @@ func CreateRouter(ctx context.Context, attSrv attendeesrv.AttendeeService) chi.R
 	adminctl.Create(server, attSrv)
 	statusctl.Create(server, attSrv)
 	banctl.Create(server, attSrv)
+	groupctl.Create(server, attSrv)
 	packagectl.Create(server, attSrv)
+	voucherctl.Create(server, attSrv)
 	addinfoctl.Create(server, attSrv)
+	outboxctl.Create(server, attSrv)
+	webhookctl.Create(server, attSrv)
 	infoctl.Create(server)
 
 	fallbackctl.Create(server)
@@ -88,6 +96,9 @@ func runServerWithGracefulShutdown(attSrv attendeesrv.AttendeeService) error {
 		}
 	}()
 
+	go runOutboxDispatcher(ctx, attSrv)
+	go runWebhookDispatcher(ctx, attSrv)
+
 	aulogging.Logger.NoCtx().Info().Print("Running service on ", config.ServerAddr())
 	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
 		aulogging.Logger.NoCtx().Error().WithErr(err).Printf("Server closed unexpectedly: %s", err.Error())
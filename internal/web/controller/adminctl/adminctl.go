@@ -3,6 +3,7 @@ package adminctl
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	aulogging "github.com/StephanHCB/go-autumn-logging"
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/admin"
@@ -34,9 +35,17 @@ func Create(server chi.Router, attendeeSrv attendeesrv.AttendeeService) {
 	server.Get("/api/rest/v1/attendees/{id}/admin", filter.HasGroupOrApiToken(config.OidcAdminGroup(), filter.WithTimeout(3*time.Second, getAdminInfoHandler)))
 	server.Put("/api/rest/v1/attendees/{id}/admin", filter.HasGroupOrApiToken(config.OidcAdminGroup(), filter.WithTimeout(3*time.Second, writeAdminInfoHandler)))
 	server.Post("/api/rest/v1/attendees/find", filter.LoggedInOrApiToken(filter.WithTimeout(60*time.Second, findAttendeesHandler)))
+	server.Get("/api/rest/v1/searches", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, allSavedSearchesHandler)))
+	server.Get("/api/rest/v1/searches/{name}", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, getSavedSearchHandler)))
+	server.Put("/api/rest/v1/searches/{name}", filter.HasGroupOrApiToken(config.OidcAdminGroup(), filter.WithTimeout(3*time.Second, writeSavedSearchHandler)))
+	server.Delete("/api/rest/v1/searches/{name}", filter.HasGroupOrApiToken(config.OidcAdminGroup(), filter.WithTimeout(3*time.Second, deleteSavedSearchHandler)))
+	server.Post("/api/rest/v1/searches/{name}/run", filter.LoggedInOrApiToken(filter.WithTimeout(60*time.Second, runSavedSearchHandler)))
 	server.Get("/api/rest/v1/attendees/identity/{identity}", filter.HasGroupOrApiToken(config.OidcAdminGroup(), filter.WithTimeout(3*time.Second, regsByIdentityHandler)))
+	server.Get("/api/rest/v1/attendees/duplicates", filter.HasGroupOrApiToken(config.OidcAdminGroup(), filter.WithTimeout(60*time.Second, findDuplicatesHandler)))
+	server.Post("/api/rest/v1/attendees/{id}/merge", filter.HasGroupOrApiToken(config.OidcAdminGroup(), filter.WithTimeout(60*time.Second, mergeAttendeesHandler)))
 
 	identityRegexp = regexp.MustCompile("^[a-zA-Z0-9]+$")
+	savedSearchNameRegexp = regexp.MustCompile("^[a-z0-9][a-z0-9_-]{0,79}$")
 }
 
 // --- handlers ---
@@ -83,7 +92,17 @@ func writeAdminInfoHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	validationErrs := validate(ctx, dto, adminInfo)
+	history, err := attendeeService.GetFullStatusHistory(ctx, attendee)
+	if err != nil {
+		adminInfoReadErrorHandler(ctx, w, r, err)
+		return
+	} else if len(history) == 0 {
+		adminInfoReadErrorHandler(ctx, w, r, errors.New("got empty status change history"))
+		return
+	}
+	currentStatus := history[len(history)-1].Status
+
+	validationErrs := validate(ctx, dto, adminInfo, attendee, currentStatus)
 	if len(validationErrs) != 0 {
 		adminInfoValidationErrorHandler(ctx, w, r, validationErrs)
 		return
@@ -120,6 +139,13 @@ func findAttendeesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	respondWithSearchResults(ctx, w, r, criteria, limitedAccess)
+}
+
+// respondWithSearchResults runs the search and writes the results in the format requested by the Accept header.
+//
+// With limited access, only a subset of the fields and only attending registrations are visible.
+func respondWithSearchResults(ctx context.Context, w http.ResponseWriter, r *http.Request, criteria *attendee.AttendeeSearchCriteria, limitedAccess bool) {
 	if limitedAccess {
 		criteria.FillFields = limitToAllowedFields(criteria.FillFields)
 		for i := range criteria.MatchAny {
@@ -127,9 +153,14 @@ func findAttendeesHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if contentType := streamContentType(r); contentType != "" {
+		streamAttendees(ctx, w, r, criteria, contentType)
+		return
+	}
+
 	results, err := attendeeService.FindAttendees(ctx, criteria)
 	if err != nil {
-		searchReadErrorHandler(ctx, w, r, err)
+		searchErrorHandler(ctx, w, r, err)
 		return
 	}
 
@@ -141,7 +172,7 @@ func limitToAllowedFields(desired []string) []string {
 	allowed := []string{"id", "nickname", "first_name", "last_name", "country",
 		"spoken_languages", "registration_language", "birthday", "pronouns", "tshirt_size",
 		"flags", "options", "packages", "status",
-		"total_dues", "payment_balance", "current_dues", "identity_subject", "avatar",
+		"currency", "total_dues", "payment_balance", "current_dues", "identity_subject", "avatar",
 	}
 
 	result := make([]string, 0)
@@ -269,6 +300,19 @@ func searchCriteriaParseErrorHandler(ctx context.Context, w http.ResponseWriter,
 	ctlutil.ErrorHandler(ctx, w, r, "search.parse.error", http.StatusBadRequest, url.Values{})
 }
 
+func searchErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, attendeesrv.InvalidSearchCursorError) {
+		searchCursorInvalidErrorHandler(ctx, w, r, err)
+	} else {
+		searchReadErrorHandler(ctx, w, r, err)
+	}
+}
+
+func searchCursorInvalidErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("received invalid search cursor: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "search.cursor.invalid", http.StatusBadRequest, url.Values{"cursor": {err.Error()}})
+}
+
 func searchReadErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
 	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("attendee search failed: %s", err.Error())
 	ctlutil.ErrorHandler(ctx, w, r, "search.read.error", http.StatusInternalServerError, url.Values{})
@@ -0,0 +1,140 @@
+package adminctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/duplicates"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/mailservice"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
+	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/go-http-utils/headers"
+)
+
+const defaultDuplicateMinScore = 0.5
+
+func findDuplicatesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	minScore := defaultDuplicateMinScore
+	if minScoreStr := r.URL.Query().Get("min_score"); minScoreStr != "" {
+		var err error
+		minScore, err = strconv.ParseFloat(minScoreStr, 64)
+		if err != nil || minScore < 0 || minScore > 1 {
+			duplicatesParamInvalidErrorHandler(ctx, w, r, url.Values{"min_score": {"must be a number between 0 and 1"}})
+			return
+		}
+	}
+
+	result, err := attendeeService.FindDuplicates(ctx, minScore)
+	if err != nil {
+		duplicatesReadErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, result)
+}
+
+func mergeAttendeesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	survivor, err := attendeeByIdMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+
+	dto, err := parseBodyToMergeDto(ctx, w, r)
+	if err != nil {
+		return
+	}
+	if dto.DuplicateId == 0 || dto.DuplicateId == survivor.ID {
+		mergeValidationErrorHandler(ctx, w, r, url.Values{"duplicate_id": {"must be the id of a different registration"}})
+		return
+	}
+
+	duplicate, err := attendeeService.GetAttendee(ctx, dto.DuplicateId)
+	if err != nil {
+		ctlutil.AttendeeNotFoundErrorHandler(ctx, w, r, dto.DuplicateId)
+		return
+	}
+
+	result, err := attendeeService.MergeAttendees(ctx, survivor, duplicate, dto.Comment)
+	if err != nil {
+		if errors.Is(err, attendeesrv.MergeDeletedError) || errors.Is(err, attendeesrv.MergeHasPaymentsError) {
+			mergeUnavailableErrorHandler(ctx, w, r, err)
+		} else if errors.Is(err, paymentservice.DownstreamError) || errors.Is(err, mailservice.DownstreamError) {
+			mergeDownstreamErrorHandler(ctx, w, r, err)
+		} else {
+			mergeWriteErrorHandler(ctx, w, r, err)
+		}
+		return
+	}
+
+	aulogging.Logger.Ctx(ctx).Info().Printf("merged attendee %d into %d", duplicate.ID, survivor.ID)
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, result)
+}
+
+func parseBodyToMergeDto(ctx context.Context, w http.ResponseWriter, r *http.Request) (*duplicates.MergeDto, error) {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	dto := &duplicates.MergeDto{}
+	err := decoder.Decode(dto)
+	if err != nil {
+		mergeParseErrorHandler(ctx, w, r, err)
+	}
+	return dto, err
+}
+
+// --- error handlers ---
+
+func duplicatesParamInvalidErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, errs url.Values) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("received invalid duplicates parameters: %v", errs)
+	ctlutil.ErrorHandler(ctx, w, r, "duplicates.param.invalid", http.StatusBadRequest, errs)
+}
+
+func duplicatesReadErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("could not scan for duplicates: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "duplicates.read.error", http.StatusInternalServerError, url.Values{})
+}
+
+func mergeParseErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("merge body could not be parsed: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "merge.parse.error", http.StatusBadRequest, url.Values{})
+}
+
+func mergeValidationErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, errs url.Values) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("received merge data with validation errors: %v", errs)
+	ctlutil.ErrorHandler(ctx, w, r, "merge.data.invalid", http.StatusBadRequest, errs)
+}
+
+func mergeUnavailableErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	message := "merge.status.invalid"
+	if errors.Is(err, attendeesrv.MergeHasPaymentsError) {
+		message = "merge.has.payments"
+	}
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("unavailable merge attempted: %s - %s", message, err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, message, http.StatusConflict, url.Values{"details": []string{err.Error()}})
+}
+
+func mergeDownstreamErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	message := "merge.payment.error"
+	if errors.Is(err, mailservice.DownstreamError) {
+		message = "merge.mail.error"
+	}
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("downstream error during merge: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, message, http.StatusBadGateway, url.Values{"details": []string{err.Error()}})
+}
+
+func mergeWriteErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("could not merge attendees: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "merge.write.error", http.StatusInternalServerError, url.Values{})
+}
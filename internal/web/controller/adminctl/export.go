@@ -0,0 +1,175 @@
+package adminctl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/tabular"
+	"github.com/go-http-utils/headers"
+)
+
+// streamContentType returns the streaming content type the client asked for in its Accept header,
+// or the empty string if it wants a single json document.
+func streamContentType(r *http.Request) string {
+	accept := r.Header.Get(headers.Accept)
+	if strings.Contains(accept, media.ContentTypeXlsx) {
+		return media.ContentTypeXlsx
+	} else if strings.Contains(accept, "text/csv") {
+		return media.ContentTypeTextCsv
+	} else if strings.Contains(accept, media.ContentTypeApplicationNdjson) {
+		return media.ContentTypeApplicationNdjson
+	}
+	return ""
+}
+
+func streamAttendees(ctx context.Context, w http.ResponseWriter, r *http.Request, criteria *attendee.AttendeeSearchCriteria, contentType string) {
+	if contentType == media.ContentTypeApplicationNdjson {
+		streamAttendeesNdjson(ctx, w, r, criteria)
+	} else {
+		exportAttendees(ctx, w, r, criteria, contentType)
+	}
+}
+
+// streamAttendeesNdjson writes one json search result per line.
+func streamAttendeesNdjson(ctx context.Context, w http.ResponseWriter, r *http.Request, criteria *attendee.AttendeeSearchCriteria) {
+	encoder := json.NewEncoder(w)
+	streamSearchResults(ctx, w, r, criteria, media.ContentTypeApplicationNdjson,
+		func() error {
+			w.Header().Add(headers.ContentType, media.ContentTypeApplicationNdjson)
+			w.WriteHeader(http.StatusOK)
+			return nil
+		},
+		func(result *attendee.AttendeeSearchResult) error {
+			return encoder.Encode(result)
+		},
+		func() error {
+			return nil
+		})
+}
+
+// exportAttendees streams the search results as a spreadsheet, one row per attendee.
+//
+// The columns are the fields filled in for the criteria. The list variants of flags, options etc.
+// are left out, their comma separated forms are included anyway.
+func exportAttendees(ctx context.Context, w http.ResponseWriter, r *http.Request, criteria *attendee.AttendeeSearchCriteria, contentType string) {
+	fieldIndexes := searchResultFieldIndexes()
+	columns := make([]string, 0)
+	for _, name := range attendeeService.SearchResultFields(criteria.FillFields) {
+		if !strings.HasSuffix(name, "_list") {
+			columns = append(columns, name)
+		}
+	}
+
+	var out tabular.Writer
+	streamSearchResults(ctx, w, r, criteria, contentType,
+		func() error {
+			filename := "attendees.csv"
+			if contentType == media.ContentTypeXlsx {
+				filename = "attendees.xlsx"
+			}
+			w.Header().Add(headers.ContentType, contentType)
+			w.Header().Add(headers.ContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+			w.WriteHeader(http.StatusOK)
+
+			if contentType == media.ContentTypeXlsx {
+				var err error
+				out, err = tabular.NewXlsxWriter(w, "Attendees")
+				if err != nil {
+					return err
+				}
+			} else {
+				out = tabular.NewCsvWriter(w)
+			}
+
+			header := make([]any, len(columns))
+			for i, name := range columns {
+				header[i] = name
+			}
+			return out.WriteRow(header)
+		},
+		func(result *attendee.AttendeeSearchResult) error {
+			return out.WriteRow(searchResultCells(result, columns, fieldIndexes))
+		},
+		func() error {
+			return out.Close()
+		})
+}
+
+// streamSearchResults runs the search and passes each result to write.
+//
+// The response is only started by calling begin once the first result arrives, so a failed search still
+// gets an error response. After that, errors can only be logged, and the client gets a truncated response.
+func streamSearchResults(ctx context.Context, w http.ResponseWriter, r *http.Request, criteria *attendee.AttendeeSearchCriteria, contentType string,
+	begin func() error, write func(result *attendee.AttendeeSearchResult) error, end func() error) {
+	started := false
+	count := 0
+	err := attendeeService.StreamAttendees(ctx, criteria, func(result *attendee.AttendeeSearchResult) error {
+		if !started {
+			started = true
+			if err := begin(); err != nil {
+				return err
+			}
+		}
+		count++
+		return write(result)
+	})
+	if err == nil && !started {
+		started = true
+		err = begin()
+	}
+	if err != nil {
+		if !started {
+			searchErrorHandler(ctx, w, r, err)
+			return
+		}
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("attendee search streaming failed after %d results: %s", count, err.Error())
+		return
+	}
+
+	if err := end(); err != nil {
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("attendee search streaming failed to complete: %s", err.Error())
+		return
+	}
+	aulogging.Logger.Ctx(ctx).Info().Printf("streamed %d attendees as %s", count, contentType)
+}
+
+// searchResultFieldIndexes maps the json names of the search result fields to their index in the struct.
+func searchResultFieldIndexes() map[string]int {
+	resultType := reflect.TypeOf(attendee.AttendeeSearchResult{})
+	indexes := make(map[string]int)
+	for i := 0; i < resultType.NumField(); i++ {
+		name, _, _ := strings.Cut(resultType.Field(i).Tag.Get("json"), ",")
+		indexes[name] = i
+	}
+	return indexes
+}
+
+func searchResultCells(result *attendee.AttendeeSearchResult, columns []string, fieldIndexes map[string]int) []any {
+	value := reflect.ValueOf(result).Elem()
+	cells := make([]any, len(columns))
+	for i, name := range columns {
+		field := value.Field(fieldIndexes[name])
+		if field.Kind() == reflect.Pointer {
+			if field.IsNil() {
+				continue
+			}
+			field = field.Elem()
+		}
+		switch field.Kind() {
+		case reflect.String:
+			cells[i] = field.String()
+		case reflect.Int, reflect.Int64:
+			cells[i] = field.Int()
+		case reflect.Uint, reflect.Uint64:
+			cells[i] = int64(field.Uint())
+		}
+	}
+	return cells
+}
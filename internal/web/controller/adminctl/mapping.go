@@ -1,7 +1,9 @@
 package adminctl
 
 import (
+	"encoding/json"
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/admin"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/savedsearch"
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
 	"strings"
 )
@@ -25,6 +27,25 @@ func mapAdminInfoToDto(a *entity.AdminInfo, dto *admin.AdminInfoDto) {
 	dto.ManualDuesDescription = a.ManualDuesDescription
 }
 
+func mapDtoToSavedSearch(dto *savedsearch.SavedSearchDto, s *entity.SavedSearch) error {
+	criteria, err := json.Marshal(dto.Criteria)
+	if err != nil {
+		return err
+	}
+	// the name comes from the path and is never changed
+	s.Description = dto.Description
+	s.Permissions = addWrappingCommas(dto.Permissions)
+	s.Criteria = string(criteria)
+	return nil
+}
+
+func mapSavedSearchToDto(s *entity.SavedSearch, dto *savedsearch.SavedSearchDto) error {
+	dto.Name = s.Name
+	dto.Description = s.Description
+	dto.Permissions = removeWrappingCommas(s.Permissions)
+	return json.Unmarshal([]byte(s.Criteria), &dto.Criteria)
+}
+
 func removeWrappingCommas(v string) string {
 	v = strings.TrimPrefix(v, ",")
 	v = strings.TrimSuffix(v, ",")
@@ -0,0 +1,255 @@
+package adminctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/savedsearch"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/web/filter"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-http-utils/headers"
+	"gorm.io/gorm"
+)
+
+var savedSearchNameRegexp *regexp.Regexp
+
+func allSavedSearchesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	searches, err := attendeeService.GetAllSavedSearches(ctx)
+	if err != nil {
+		savedSearchReadErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	// only list the searches the caller may run
+	response := savedsearch.SavedSearchList{
+		Searches: make([]savedsearch.SavedSearchDto, 0),
+	}
+	for _, search := range searches {
+		allowed, err := attendeeService.CanRunSavedSearch(ctx, search)
+		if err != nil {
+			savedSearchReadErrorHandler(ctx, w, r, err)
+			return
+		}
+		if allowed {
+			dto := savedsearch.SavedSearchDto{}
+			if err := mapSavedSearchToDto(search, &dto); err != nil {
+				savedSearchReadErrorHandler(ctx, w, r, err)
+				return
+			}
+			response.Searches = append(response.Searches, dto)
+		}
+	}
+
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, response)
+}
+
+func getSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	search, err := runnableSavedSearchMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+
+	dto := savedsearch.SavedSearchDto{}
+	if err := mapSavedSearchToDto(search, &dto); err != nil {
+		savedSearchReadErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, dto)
+}
+
+func writeSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	name, err := savedSearchNameFromVars(ctx, w, r)
+	if err != nil {
+		return
+	}
+	dto, err := parseBodyToSavedSearchDto(ctx, w, r)
+	if err != nil {
+		return
+	}
+	validationErrs := validateSavedSearch(ctx, dto, name)
+	if len(validationErrs) != 0 {
+		savedSearchValidationErrorHandler(ctx, w, r, validationErrs)
+		return
+	}
+
+	search, err := attendeeService.GetSavedSearch(ctx, name)
+	created := errors.Is(err, gorm.ErrRecordNotFound)
+	if created {
+		search = &entity.SavedSearch{Name: name}
+	} else if err != nil {
+		savedSearchReadErrorHandler(ctx, w, r, err)
+		return
+	}
+	if err := mapDtoToSavedSearch(dto, search); err != nil {
+		savedSearchWriteErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	err = attendeeService.SaveSavedSearch(ctx, search)
+	if err != nil {
+		savedSearchWriteErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	if created {
+		aulogging.Logger.Ctx(ctx).Info().Printf("created saved search %s", name)
+		w.Header().Set(headers.Location, r.RequestURI)
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func deleteSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	search, err := savedSearchMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+
+	err = attendeeService.DeleteSavedSearch(ctx, search)
+	if err != nil {
+		savedSearchWriteErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func runSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	search, err := runnableSavedSearchMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+
+	dto := savedsearch.SavedSearchDto{}
+	if err := mapSavedSearchToDto(search, &dto); err != nil {
+		savedSearchReadErrorHandler(ctx, w, r, err)
+		return
+	}
+	criteria := dto.Criteria
+
+	// paging is not part of the saved search, so each run can choose it
+	query := r.URL.Query()
+	if numResultsStr := query.Get("num_results"); numResultsStr != "" {
+		numResults, err := strconv.ParseUint(numResultsStr, 10, 32)
+		if err != nil || numResults == 0 {
+			savedSearchValidationErrorHandler(ctx, w, r, url.Values{"num_results": {"must be a positive integer"}})
+			return
+		}
+		criteria.NumResults = uint(numResults)
+	}
+	criteria.Cursor = query.Get("cursor")
+
+	limitedAccess := !filter.IsGroupOrApiTokenCond(r, config.OidcAdminGroup())
+	respondWithSearchResults(ctx, w, r, &criteria, limitedAccess)
+}
+
+// runnableSavedSearchMustReturnOnError obtains the saved search named in the path, and checks that the
+// caller may run it.
+func runnableSavedSearchMustReturnOnError(ctx context.Context, w http.ResponseWriter, r *http.Request) (*entity.SavedSearch, error) {
+	search, err := savedSearchMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed, err := attendeeService.CanRunSavedSearch(ctx, search)
+	if err != nil || !allowed {
+		culprit := ctxvalues.Subject(ctx)
+		ctlutil.UnauthorizedError(ctx, w, r, "you are not authorized for this operation - the attempt has been logged", fmt.Sprintf("unauthorized access attempt to saved search %s by %s", search.Name, culprit))
+		return nil, errors.New("not allowed to run saved search")
+	}
+	return search, nil
+}
+
+func savedSearchMustReturnOnError(ctx context.Context, w http.ResponseWriter, r *http.Request) (*entity.SavedSearch, error) {
+	name, err := savedSearchNameFromVars(ctx, w, r)
+	if err != nil {
+		return nil, err
+	}
+
+	search, err := attendeeService.GetSavedSearch(ctx, name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		savedSearchNotFoundErrorHandler(ctx, w, r, name)
+		return nil, err
+	} else if err != nil {
+		savedSearchReadErrorHandler(ctx, w, r, err)
+		return nil, err
+	}
+	return search, nil
+}
+
+func savedSearchNameFromVars(ctx context.Context, w http.ResponseWriter, r *http.Request) (string, error) {
+	name := chi.URLParam(r, "name")
+	if !savedSearchNameRegexp.MatchString(name) {
+		savedSearchNameInvalidErrorHandler(ctx, w, r, name)
+		return "", errors.New("invalid saved search name")
+	}
+	return name, nil
+}
+
+func parseBodyToSavedSearchDto(ctx context.Context, w http.ResponseWriter, r *http.Request) (*savedsearch.SavedSearchDto, error) {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	dto := &savedsearch.SavedSearchDto{}
+	err := decoder.Decode(dto)
+	if err != nil {
+		savedSearchParseErrorHandler(ctx, w, r, err)
+	}
+	return dto, err
+}
+
+// --- error handlers ---
+
+func savedSearchNameInvalidErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, name string) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("received invalid saved search name '%s'", url.QueryEscape(name))
+	ctlutil.ErrorHandler(ctx, w, r, "search.name.invalid", http.StatusBadRequest, url.Values{"name": {"must consist of lowercase letters, digits, - and _, and be at most 80 characters long"}})
+}
+
+func savedSearchNotFoundErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, name string) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("saved search %s not found", name)
+	ctlutil.ErrorHandler(ctx, w, r, "search.name.notfound", http.StatusNotFound, url.Values{})
+}
+
+func savedSearchParseErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("saved search body could not be parsed: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "search.parse.error", http.StatusBadRequest, url.Values{})
+}
+
+func savedSearchValidationErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, errs url.Values) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("received saved search data with validation errors: %v", errs)
+	ctlutil.ErrorHandler(ctx, w, r, "search.data.invalid", http.StatusBadRequest, errs)
+}
+
+func savedSearchReadErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("saved search(es) could not be read: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "search.read.error", http.StatusInternalServerError, url.Values{})
+}
+
+func savedSearchWriteErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("saved search could not be written: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "search.write.error", http.StatusInternalServerError, url.Values{})
+}
@@ -4,13 +4,15 @@ import (
 	"context"
 	aulogging "github.com/StephanHCB/go-autumn-logging"
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/admin"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/savedsearch"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
 	"github.com/eurofurence/reg-attendee-service/internal/web/util/validation"
 	"net/url"
 )
 
-func validate(ctx context.Context, a *admin.AdminInfoDto, trustedOriginalState *entity.AdminInfo) url.Values {
+func validate(ctx context.Context, a *admin.AdminInfoDto, trustedOriginalState *entity.AdminInfo, attd *entity.Attendee, currentStatus status.Status) url.Values {
 	errs := url.Values{}
 
 	if a.Id != 0 && a.Id != trustedOriginalState.ID {
@@ -20,7 +22,7 @@ func validate(ctx context.Context, a *admin.AdminInfoDto, trustedOriginalState *
 	validation.CheckCombinationOfAllowedValues(&errs, config.AllowedPermissions(), "permissions", a.Permissions)
 
 	validation.CheckCombinationOfAllowedValues(&errs, config.AllowedFlagsAdminOnly(), "flags", a.Flags)
-	if err := attendeeService.CanChangeChoiceTo(ctx, "admin flag", trustedOriginalState.Flags, a.Flags, config.FlagsConfigAdminOnly()); err != nil {
+	if err := attendeeService.CanChangeChoiceTo(ctx, "admin flag", trustedOriginalState.Flags, a.Flags, config.FlagsConfigAdminOnly(), attd, currentStatus); err != nil {
 		errs.Add("flags", err.Error())
 	}
 
@@ -34,3 +36,31 @@ func validate(ctx context.Context, a *admin.AdminInfoDto, trustedOriginalState *
 	}
 	return errs
 }
+
+func validateSavedSearch(ctx context.Context, s *savedsearch.SavedSearchDto, name string) url.Values {
+	errs := url.Values{}
+
+	if s.Name != "" && s.Name != name {
+		errs.Add("name", "name field must be empty or match the name in the path")
+	}
+
+	validation.CheckLength(&errs, 0, 255, "description", s.Description)
+	validation.CheckCombinationOfAllowedValues(&errs, config.AllowedPermissions(), "permissions", s.Permissions)
+
+	if len(s.Criteria.MatchAny) == 0 {
+		errs.Add("criteria", "criteria must contain at least one entry in match_any, or the search never finds anybody")
+	}
+	if s.Criteria.Cursor != "" {
+		errs.Add("criteria", "criteria must not contain a cursor, pass it when running the search instead")
+	}
+
+	if len(errs) != 0 {
+		if config.LoggingSeverity() == "DEBUG" {
+			logger := aulogging.Logger.Ctx(ctx).Debug()
+			for key, val := range errs {
+				logger.Printf("saved search dto validation error for key %s: %s", key, val)
+			}
+		}
+	}
+	return errs
+}
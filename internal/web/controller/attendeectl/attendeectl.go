@@ -41,6 +41,16 @@ func Create(server chi.Router, attendeeSrv attendeesrv.AttendeeService) {
 	server.Put("/api/rest/v1/attendees/{id}", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, updateAttendeeHandler)))
 	server.Get("/api/rest/v1/attendees/{id}/due-date", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, getDueDateHandler)))
 	server.Put("/api/rest/v1/attendees/{id}/due-date", filter.HasGroupOrApiToken(config.OidcAdminGroup(), filter.WithTimeout(3*time.Second, overrideDueDateHandler)))
+	server.Get("/api/rest/v1/attendees/{id}/invoices", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, getInvoicesHandler)))
+	server.Get("/api/rest/v1/attendees/{id}/invoices/{number}/pdf", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, getInvoicePdfHandler)))
+	server.Get("/api/rest/v1/attendees/{id}/history", filter.HasGroupOrApiToken(config.OidcAdminGroup(), filter.WithTimeout(3*time.Second, getHistoryHandler)))
+	server.Get("/api/rest/v1/attendees/{id}/history/as-of", filter.HasGroupOrApiToken(config.OidcAdminGroup(), filter.WithTimeout(3*time.Second, getAsOfHandler)))
+	server.Post("/api/rest/v1/attendees/{id}/history/{historyId}/restore", filter.HasGroupOrApiToken(config.OidcAdminGroup(), filter.WithTimeout(3*time.Second, restoreHandler)))
+
+	server.Post("/api/rest/v1/attendees/{id}/transfer", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, offerTransferHandler)))
+	server.Get("/api/rest/v1/attendees/{id}/transfer", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, getTransferHandler)))
+	server.Delete("/api/rest/v1/attendees/{id}/transfer", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, cancelTransferHandler)))
+	server.Post("/api/rest/v1/transfers/{code}/accept", filter.LoggedIn(filter.WithTimeout(3*time.Second, acceptTransferHandler)))
 
 	server.Get("/api/rest/v1/attendees/{id}/flags/{flag}", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, getFlagHandler)))
 	server.Get("/api/rest/v1/attendees/{id}/options/{option}", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, getOptionHandler)))
@@ -63,24 +73,42 @@ func newAttendeeHandler(w http.ResponseWriter, r *http.Request) {
 	orig := *newAttendee
 	mapDtoToAttendee(dto, newAttendee)
 
-	limitDeltas, err := attendeeService.ComputeDeltasAndCheckLimitOverrun(ctx, &orig, newAttendee, status.Deleted, status.New)
+	limitDeltas, queued, err := attendeeService.ComputeDeltasAndWaitlistPlacement(ctx, &orig, newAttendee, status.Deleted, status.New)
 	if err != nil {
 		attendeeOverrunErrorHandler(ctx, w, r, err)
 		return
 	}
 
-	id, err := attendeeService.RegisterNewAttendee(ctx, newAttendee)
-	if err != nil {
-		attendeeWriteErrorHandler(ctx, w, r, err)
-		return
-	}
+	// a voucher can be redeemed together with the registration, in which case the registration fails if it cannot be redeemed
+	voucherCode := r.URL.Query().Get("voucher")
+
+	var id uint
+	err = attendeeService.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		id, err = attendeeService.RegisterNewAttendee(ctx, newAttendee)
+		if err != nil {
+			return err
+		}
+
+		if err := attendeeService.RecordLimitChanges(ctx, limitDeltas); err != nil {
+			return err
+		}
+
+		if err := attendeeService.PlaceOnWaitlist(ctx, newAttendee, queued); err != nil {
+			return err
+		}
 
-	if err := attendeeService.RecordLimitChanges(ctx, limitDeltas); err != nil {
+		if voucherCode != "" {
+			return attendeeService.RedeemVoucher(ctx, newAttendee, voucherCode)
+		}
+		return nil
+	})
+	if err != nil {
 		attendeeWriteErrorHandler(ctx, w, r, err)
 		return
 	}
 
-	location := fmt.Sprintf("%s/%d", r.RequestURI, id)
+	location := fmt.Sprintf("%s/%d", r.URL.Path, id)
 	aulogging.Logger.Ctx(ctx).Info().Printf("sending Location %s", location)
 	w.Header().Set(headers.Location, location)
 	w.WriteHeader(http.StatusCreated)
@@ -145,19 +173,29 @@ func updateAttendeeHandler(w http.ResponseWriter, r *http.Request) {
 	orig := *attd // copy before mapping changes
 	mapDtoToAttendee(dto, attd)
 
-	limitChanges, err := attendeeService.ComputeDeltasAndCheckLimitOverrun(ctx, &orig, attd, latestStatus, latestStatus)
-	if err != nil {
-		attendeeOverrunErrorHandler(ctx, w, r, err)
+	if err := attendeeService.CheckGroupConstraint(ctx, attd); err != nil {
+		if errors.Is(err, attendeesrv.GroupConstraintError) {
+			attendeeValidationErrorHandler(ctx, w, r, url.Values{"packages": {config.GroupConstraintMsg()}})
+		} else {
+			attendeeWriteErrorHandler(ctx, w, r, err)
+		}
 		return
 	}
 
-	err = attendeeService.UpdateAttendee(ctx, attd, suppressMinorUpdateEmail)
+	limitChanges, err := attendeeService.ComputeDeltasAndCheckLimitOverrun(ctx, &orig, attd, latestStatus, latestStatus)
 	if err != nil {
-		attendeeWriteErrorHandler(ctx, w, r, err)
+		attendeeOverrunErrorHandler(ctx, w, r, err)
 		return
 	}
 
-	if err = attendeeService.RecordLimitChanges(ctx, limitChanges); err != nil {
+	err = attendeeService.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := attendeeService.UpdateAttendee(ctx, attd, suppressMinorUpdateEmail); err != nil {
+			return err
+		}
+
+		return attendeeService.RecordLimitChanges(ctx, limitChanges)
+	})
+	if err != nil {
 		attendeeWriteErrorHandler(ctx, w, r, err)
 		return
 	}
@@ -183,8 +221,15 @@ func getDueDateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	installments, err := attendeeService.GetInstallments(ctx, existingAttendee)
+	if err != nil {
+		attendeeReadErrorHandler(ctx, w, r, err)
+		return
+	}
+
 	dto := attendee.DueDate{
-		DueDate: existingAttendee.CacheDueDate,
+		DueDate:      existingAttendee.CacheDueDate,
+		Installments: installments,
 	}
 	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
 	ctlutil.WriteJson(ctx, w, dto)
@@ -482,7 +527,9 @@ func attendeeParseErrorHandler(ctx context.Context, w http.ResponseWriter, r *ht
 
 func attendeeWriteErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
 	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("attendee could not be written: %s", err.Error())
-	if err.Error() == "duplicate attendee data - you are already registered" {
+	if isVoucherRedemptionError(err) {
+		ctlutil.ErrorHandler(ctx, w, r, "attendee.voucher.invalid", http.StatusBadRequest, url.Values{"voucher": {err.Error()}})
+	} else if err.Error() == "duplicate attendee data - you are already registered" {
 		ctlutil.ErrorHandler(ctx, w, r, "attendee.data.duplicate", http.StatusConflict, url.Values{"attendee": {"there is already an attendee with this information (looking at nickname, email, and zip code)"}})
 	} else if err.Error() == "duplicate - must use a separate email address and identity account for each person" {
 		ctlutil.ErrorHandler(ctx, w, r, "attendee.user.duplicate", http.StatusConflict, url.Values{"user": {"you already have a registration - please use a separate email address and matching account per person"}})
@@ -494,6 +541,14 @@ func attendeeWriteErrorHandler(ctx context.Context, w http.ResponseWriter, r *ht
 	// TODO: distinguish attendee.payment.error -> bad gateway
 }
 
+func isVoucherRedemptionError(err error) bool {
+	return errors.Is(err, attendeesrv.VoucherNotFoundError) ||
+		errors.Is(err, attendeesrv.VoucherExpiredError) ||
+		errors.Is(err, attendeesrv.VoucherUsedUpError) ||
+		errors.Is(err, attendeesrv.VoucherNotApplicableError) ||
+		errors.Is(err, attendeesrv.VoucherCurrencyError)
+}
+
 func attendeeReadErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
 	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("attendee could not be read: %s", err.Error())
 	ctlutil.ErrorHandler(ctx, w, r, "attendee.read.error", http.StatusInternalServerError, url.Values{})
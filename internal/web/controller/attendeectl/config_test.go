@@ -5,8 +5,11 @@ import (
 	"errors"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/duplicates"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/history"
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
@@ -42,6 +45,10 @@ type MockAttendeeService struct {
 
 var _ attendeesrv.AttendeeService = (*MockAttendeeService)(nil)
 
+func (s *MockAttendeeService) WithTransaction(ctx context.Context, f func(ctx context.Context) error) error {
+	return f(ctx)
+}
+
 func (s *MockAttendeeService) NewAttendee(ctx context.Context) *entity.Attendee {
 	return &entity.Attendee{}
 }
@@ -66,11 +73,11 @@ func (s *MockAttendeeService) CanChangeEmailTo(ctx context.Context, originalEmai
 	return nil
 }
 
-func (s *MockAttendeeService) CanChangeChoiceTo(ctx context.Context, what string, originalChoiceStr string, newChoiceStr string, configuration map[string]config.ChoiceConfig) error {
+func (s *MockAttendeeService) CanChangeChoiceTo(ctx context.Context, what string, originalChoiceStr string, newChoiceStr string, configuration map[string]config.ChoiceConfig, newState *entity.Attendee, currentStatus status.Status) error {
 	return nil
 }
 
-func (s *MockAttendeeService) CanChangeChoiceToCurrentStatus(ctx context.Context, what string, originalChoice []attendee.PackageState, newChoice []attendee.PackageState, configuration map[string]config.ChoiceConfig, currentStatus status.Status) error {
+func (s *MockAttendeeService) CanChangeChoiceToCurrentStatus(ctx context.Context, what string, originalChoice []attendee.PackageState, newChoice []attendee.PackageState, configuration map[string]config.ChoiceConfig, newState *entity.Attendee, currentStatus status.Status) error {
 	return nil
 }
 
@@ -110,10 +117,70 @@ func (s *MockAttendeeService) ComputeDeltasAndCheckLimitOverrun(ctx context.Cont
 	return nil, nil
 }
 
+func (s *MockAttendeeService) ComputeDeltasAndWaitlistPlacement(ctx context.Context, oldState *entity.Attendee, currentState *entity.Attendee, oldStatus status.Status, newStatus status.Status) ([]*entity.Count, map[string]int, error) {
+	return nil, nil, nil
+}
+
 func (s *MockAttendeeService) RecordLimitChanges(ctx context.Context, deltas []*entity.Count) error {
 	return nil
 }
 
+func (s *MockAttendeeService) PlaceOnWaitlist(ctx context.Context, attendee *entity.Attendee, queued map[string]int) error {
+	return nil
+}
+
+func (s *MockAttendeeService) GetOutboxMails(ctx context.Context, state string) ([]*entity.OutboxMail, error) {
+	return make([]*entity.OutboxMail, 0), nil
+}
+
+func (s *MockAttendeeService) GetOutboxMail(ctx context.Context, id uint) (*entity.OutboxMail, error) {
+	return &entity.OutboxMail{}, nil
+}
+
+func (s *MockAttendeeService) RetryOutboxMail(ctx context.Context, mail *entity.OutboxMail) error {
+	return nil
+}
+
+func (s *MockAttendeeService) DiscardOutboxMail(ctx context.Context, mail *entity.OutboxMail) error {
+	return nil
+}
+
+func (s *MockAttendeeService) DispatchOutboxMails(ctx context.Context) error {
+	return nil
+}
+
+func (s *MockAttendeeService) GetWebhookDeliveries(ctx context.Context, subscriber string, state string, attendeeId uint, limit int) ([]*entity.WebhookDelivery, error) {
+	return make([]*entity.WebhookDelivery, 0), nil
+}
+
+func (s *MockAttendeeService) DispatchWebhooks(ctx context.Context) error {
+	return nil
+}
+
+func (s *MockAttendeeService) GetAttendeeHistory(ctx context.Context, attendeeId uint) ([]history.HistoryEntry, error) {
+	return make([]history.HistoryEntry, 0), nil
+}
+
+func (s *MockAttendeeService) GetAttendeeAsOf(ctx context.Context, attendeeId uint, asOf time.Time) (*entity.Attendee, *entity.AdminInfo, error) {
+	return &entity.Attendee{}, &entity.AdminInfo{}, nil
+}
+
+func (s *MockAttendeeService) RestoreAttendeeBefore(ctx context.Context, attendeeId uint, historyId uint) (*entity.Attendee, error) {
+	return &entity.Attendee{}, nil
+}
+
+func (s *MockAttendeeService) StreamAttendees(ctx context.Context, criteria *attendee.AttendeeSearchCriteria, f func(result *attendee.AttendeeSearchResult) error) error {
+	return nil
+}
+
+func (s *MockAttendeeService) SearchResultFields(fillFields []string) []string {
+	return []string{"id"}
+}
+
+func (s *MockAttendeeService) GetWaitlist(ctx context.Context, key string) ([]*entity.WaitlistEntry, error) {
+	return make([]*entity.WaitlistEntry, 0), nil
+}
+
 func (s *MockAttendeeService) GetLimitBookings(ctx context.Context, key string) (*entity.Count, error) {
 	return &entity.Count{}, nil
 }
@@ -160,6 +227,130 @@ func (s *MockAttendeeService) GetAllBans(ctx context.Context) ([]*entity.Ban, er
 	return make([]*entity.Ban, 0), nil
 }
 
+func (s *MockAttendeeService) GetAllSavedSearches(ctx context.Context) ([]*entity.SavedSearch, error) {
+	return make([]*entity.SavedSearch, 0), nil
+}
+
+func (s *MockAttendeeService) GetSavedSearch(ctx context.Context, name string) (*entity.SavedSearch, error) {
+	return &entity.SavedSearch{}, nil
+}
+
+func (s *MockAttendeeService) SaveSavedSearch(ctx context.Context, search *entity.SavedSearch) error {
+	return nil
+}
+
+func (s *MockAttendeeService) DeleteSavedSearch(ctx context.Context, search *entity.SavedSearch) error {
+	return nil
+}
+
+func (s *MockAttendeeService) CanRunSavedSearch(ctx context.Context, search *entity.SavedSearch) (bool, error) {
+	return true, nil
+}
+
+func (s *MockAttendeeService) FindDuplicates(ctx context.Context, minScore float64) (*duplicates.DuplicateList, error) {
+	return &duplicates.DuplicateList{}, nil
+}
+
+func (s *MockAttendeeService) MergeAttendees(ctx context.Context, survivor *entity.Attendee, duplicate *entity.Attendee, comment string) (*duplicates.MergeResult, error) {
+	return &duplicates.MergeResult{}, nil
+}
+
+func (s *MockAttendeeService) GetPendingTransfer(ctx context.Context, attendeeId uint) (*entity.Transfer, error) {
+	return &entity.Transfer{}, nil
+}
+
+func (s *MockAttendeeService) GetTransferByCode(ctx context.Context, code string) (*entity.Transfer, error) {
+	return &entity.Transfer{}, nil
+}
+
+func (s *MockAttendeeService) OfferTransfer(ctx context.Context, attendee *entity.Attendee, email string) (*entity.Transfer, error) {
+	return &entity.Transfer{}, nil
+}
+
+func (s *MockAttendeeService) CancelTransfer(ctx context.Context, transfer *entity.Transfer) error {
+	return nil
+}
+
+func (s *MockAttendeeService) AcceptTransfer(ctx context.Context, transfer *entity.Transfer, attendee *entity.Attendee) error {
+	return nil
+}
+
+func (s *MockAttendeeService) GetGroup(ctx context.Context, id uint) (*entity.Group, []*entity.GroupMember, error) {
+	return &entity.Group{}, []*entity.GroupMember{}, nil
+}
+
+func (s *MockAttendeeService) GetGroupOf(ctx context.Context, attendeeId uint) (*entity.Group, error) {
+	return &entity.Group{}, nil
+}
+
+func (s *MockAttendeeService) GetGroupInvitations(ctx context.Context, attendeeId uint) ([]*entity.Group, error) {
+	return []*entity.Group{}, nil
+}
+
+func (s *MockAttendeeService) CreateGroup(ctx context.Context, owner *entity.Attendee, name string) (*entity.Group, error) {
+	return &entity.Group{}, nil
+}
+
+func (s *MockAttendeeService) DisbandGroup(ctx context.Context, group *entity.Group) error {
+	return nil
+}
+
+func (s *MockAttendeeService) InviteToGroup(ctx context.Context, group *entity.Group, invitee *entity.Attendee) error {
+	return nil
+}
+
+func (s *MockAttendeeService) JoinGroup(ctx context.Context, group *entity.Group, attendee *entity.Attendee) error {
+	return nil
+}
+
+func (s *MockAttendeeService) RemoveFromGroup(ctx context.Context, group *entity.Group, attendee *entity.Attendee) error {
+	return nil
+}
+
+func (s *MockAttendeeService) CheckGroupConstraint(ctx context.Context, attendee *entity.Attendee) error {
+	return nil
+}
+
+func (s *MockAttendeeService) GetAllVouchers(ctx context.Context) ([]*entity.Voucher, error) {
+	return make([]*entity.Voucher, 0), nil
+}
+
+func (s *MockAttendeeService) GetVoucher(ctx context.Context, code string) (*entity.Voucher, error) {
+	return &entity.Voucher{}, errors.New("some error, this is a mock")
+}
+
+func (s *MockAttendeeService) CountVoucherUses(ctx context.Context, voucher *entity.Voucher) (int, error) {
+	return 0, nil
+}
+
+func (s *MockAttendeeService) SaveVoucher(ctx context.Context, voucher *entity.Voucher) error {
+	return nil
+}
+
+func (s *MockAttendeeService) DeleteVoucher(ctx context.Context, voucher *entity.Voucher) error {
+	return nil
+}
+
+func (s *MockAttendeeService) GetRedeemedVouchers(ctx context.Context, attendeeId uint) ([]*entity.Voucher, error) {
+	return make([]*entity.Voucher, 0), nil
+}
+
+func (s *MockAttendeeService) RedeemVoucher(ctx context.Context, attendee *entity.Attendee, code string) error {
+	return nil
+}
+
+func (s *MockAttendeeService) RemoveVoucherRedemption(ctx context.Context, attendee *entity.Attendee, voucher *entity.Voucher) error {
+	return nil
+}
+
+func (s *MockAttendeeService) GetInstallments(ctx context.Context, att *entity.Attendee) ([]attendee.Installment, error) {
+	return make([]attendee.Installment, 0), nil
+}
+
+func (s *MockAttendeeService) GetInvoices(ctx context.Context, att *entity.Attendee) ([]attendee.Invoice, error) {
+	return make([]attendee.Invoice, 0), nil
+}
+
 func (s *MockAttendeeService) GetAdditionalInfo(ctx context.Context, attendeeId uint, area string) (string, error) {
 	return "", nil
 }
@@ -0,0 +1,130 @@
+package attendeectl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/history"
+	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-http-utils/headers"
+)
+
+func getHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := idFromVars(ctx, w, r)
+	if err != nil {
+		return
+	}
+	if _, err := attendeeService.GetAttendee(ctx, id); err != nil {
+		ctlutil.AttendeeNotFoundErrorHandler(ctx, w, r, id)
+		return
+	}
+
+	entries, err := attendeeService.GetAttendeeHistory(ctx, id)
+	if err != nil {
+		historyReadErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, history.HistoryList{Entries: entries})
+}
+
+func getAsOfHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := idFromVars(ctx, w, r)
+	if err != nil {
+		return
+	}
+	asOf, err := time.Parse(time.RFC3339, r.URL.Query().Get("timestamp"))
+	if err != nil {
+		historyParamErrorHandler(ctx, w, r, url.Values{"timestamp": {"must be a timestamp in RFC 3339 format, e.g. 2022-12-08T10:00:00Z"}})
+		return
+	}
+	if _, err := attendeeService.GetAttendee(ctx, id); err != nil {
+		ctlutil.AttendeeNotFoundErrorHandler(ctx, w, r, id)
+		return
+	}
+
+	attd, _, err := attendeeService.GetAttendeeAsOf(ctx, id, asOf)
+	if err != nil {
+		if errors.Is(err, attendeesrv.NotYetRegisteredError) {
+			historyNotYetRegisteredErrorHandler(ctx, w, r, id)
+		} else {
+			historyReadErrorHandler(ctx, w, r, err)
+		}
+		return
+	}
+
+	dto := attendee.AttendeeDto{}
+	mapAttendeeToDto(attd, &dto)
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, dto)
+}
+
+func restoreHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := idFromVars(ctx, w, r)
+	if err != nil {
+		return
+	}
+	historyIdStr := chi.URLParam(r, "historyId")
+	historyId, err := strconv.ParseUint(historyIdStr, 10, 32)
+	if err != nil {
+		historyParamErrorHandler(ctx, w, r, url.Values{"historyId": {"must be a positive integer"}})
+		return
+	}
+	if _, err := attendeeService.GetAttendee(ctx, id); err != nil {
+		ctlutil.AttendeeNotFoundErrorHandler(ctx, w, r, id)
+		return
+	}
+
+	attd, err := attendeeService.RestoreAttendeeBefore(ctx, id, uint(historyId))
+	if err != nil {
+		if errors.Is(err, attendeesrv.HistoryEntryNotFoundError) {
+			historyEntryNotFoundErrorHandler(ctx, w, r, id, uint(historyId))
+		} else if errors.Is(err, attendeesrv.IntroducesOverrun) {
+			attendeeOverrunErrorHandler(ctx, w, r, err)
+		} else {
+			attendeeWriteErrorHandler(ctx, w, r, err)
+		}
+		return
+	}
+
+	dto := attendee.AttendeeDto{}
+	mapAttendeeToDto(attd, &dto)
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, dto)
+}
+
+func historyParamErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, errs url.Values) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("received invalid history parameters: %v", errs)
+	ctlutil.ErrorHandler(ctx, w, r, "history.param.invalid", http.StatusBadRequest, errs)
+}
+
+func historyNotYetRegisteredErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, id uint) {
+	aulogging.Logger.Ctx(ctx).Info().Printf("attendee id %d did not exist at the requested time", id)
+	ctlutil.ErrorHandler(ctx, w, r, "history.notfound", http.StatusNotFound, url.Values{"timestamp": {attendeesrv.NotYetRegisteredError.Error()}})
+}
+
+func historyEntryNotFoundErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, id uint, historyId uint) {
+	aulogging.Logger.Ctx(ctx).Info().Printf("history entry %d not found for attendee id %d", historyId, id)
+	ctlutil.ErrorHandler(ctx, w, r, "history.notfound", http.StatusNotFound, url.Values{"historyId": {attendeesrv.HistoryEntryNotFoundError.Error()}})
+}
+
+func historyReadErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("could not read attendee history: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "history.read.error", http.StatusInternalServerError, url.Values{})
+}
@@ -0,0 +1,181 @@
+package attendeectl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/web/filter"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/pdf"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-http-utils/headers"
+)
+
+func getInvoicesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	invoices, err := invoicesMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, attendee.InvoiceList{Invoices: invoices})
+}
+
+func getInvoicePdfHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	invoices, err := invoicesMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+
+	number := chi.URLParam(r, "number")
+	for _, invoice := range invoices {
+		if invoice.Number == number {
+			w.Header().Add(headers.ContentType, media.ContentTypeApplicationPdf)
+			w.Header().Add(headers.ContentDisposition, fmt.Sprintf(`inline; filename="%s.pdf"`, url.PathEscape(number)))
+			w.WriteHeader(http.StatusOK)
+			if _, err := renderInvoice(invoice).WriteTo(w); err != nil {
+				aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("failed to write invoice pdf: %s", err.Error())
+			}
+			return
+		}
+	}
+	invoiceNotFoundErrorHandler(ctx, w, r, number)
+}
+
+func invoicesMustReturnOnError(ctx context.Context, w http.ResponseWriter, r *http.Request) ([]attendee.Invoice, error) {
+	id, err := idFromVars(ctx, w, r)
+	if err != nil {
+		return nil, err
+	}
+	existingAttendee, err := attendeeService.GetAttendee(ctx, id)
+	if err != nil {
+		ctlutil.AttendeeNotFoundErrorHandler(ctx, w, r, id)
+		return nil, err
+	}
+
+	if err := filter.IsSubjectOrGroupOrApiToken(w, r, existingAttendee.Identity, config.OidcAdminGroup()); err != nil {
+		return nil, err
+	}
+
+	invoices, err := attendeeService.GetInvoices(ctx, existingAttendee)
+	if err != nil {
+		invoiceReadErrorHandler(ctx, w, r, err)
+		return nil, err
+	}
+	return invoices, nil
+}
+
+const (
+	invoiceLeft      = 50.0
+	invoiceRight     = pdf.PageWidth - 50.0
+	invoiceLineSize  = 10.0
+	invoiceLineSkip  = 14.0
+	invoiceBottom    = 100.0
+	invoiceVatColumn = invoiceRight - 110.0
+)
+
+// renderInvoice lays out an invoice on A4 pages, with the issuer and footer taken from the configuration.
+func renderInvoice(invoice attendee.Invoice) *pdf.Document {
+	cfg := config.Invoices()
+	d := pdf.New()
+
+	y := pdf.PageHeight - 60
+	for i, line := range cfg.Issuer {
+		d.Text(invoiceLeft, y, 9, i == 0, line)
+		y -= 11
+	}
+	if cfg.VatId != "" {
+		d.Text(invoiceLeft, y, 9, false, "VAT ID: "+cfg.VatId)
+	}
+
+	y = pdf.PageHeight - 180
+	d.TextRight(invoiceRight, y, invoiceLineSize, true, "Invoice "+invoice.Number)
+	d.TextRight(invoiceRight, y-invoiceLineSkip, invoiceLineSize, false, "Date: "+invoice.IssuedOn)
+	if invoice.Superseded {
+		d.TextRight(invoiceRight, y-2*invoiceLineSkip, invoiceLineSize, true, "Superseded by a later invoice")
+	}
+	for _, line := range invoice.Recipient {
+		d.Text(invoiceLeft, y, invoiceLineSize, false, line)
+		y -= invoiceLineSkip
+	}
+
+	y = min(y, pdf.PageHeight-240) - 2*invoiceLineSkip
+	itemsHeader := func() {
+		d.Text(invoiceLeft, y, invoiceLineSize, true, "Qty")
+		d.Text(invoiceLeft+35, y, invoiceLineSize, true, "Description")
+		d.TextRight(invoiceVatColumn, y, invoiceLineSize, true, "VAT")
+		d.TextRight(invoiceRight, y, invoiceLineSize, true, "Amount ("+invoice.Currency+")")
+		d.Line(invoiceLeft, y-4, invoiceRight, y-4)
+		y -= invoiceLineSkip + 4
+	}
+	nextLine := func() {
+		y -= invoiceLineSkip
+		if y < invoiceBottom {
+			d.AddPage()
+			y = pdf.PageHeight - 60
+			itemsHeader()
+		}
+	}
+
+	itemsHeader()
+	for _, item := range invoice.Items {
+		d.TextRight(invoiceLeft+20, y, invoiceLineSize, false, strconv.Itoa(item.Count))
+		d.Text(invoiceLeft+35, y, invoiceLineSize, false, item.Description)
+		d.TextRight(invoiceVatColumn, y, invoiceLineSize, false, formatVatPercent(item.VatPercent))
+		d.TextRight(invoiceRight, y, invoiceLineSize, false, formatCents(item.Amount))
+		nextLine()
+	}
+	d.Line(invoiceLeft, y+invoiceLineSkip-4, invoiceRight, y+invoiceLineSkip-4)
+	d.Text(invoiceLeft+35, y, invoiceLineSize, true, "Total")
+	d.TextRight(invoiceRight, y, invoiceLineSize, true, formatCents(invoice.Total))
+	nextLine()
+
+	for _, vat := range invoice.VatBreakdown {
+		percent := formatVatPercent(vat.VatPercent)
+		d.Text(invoiceLeft+35, y, invoiceLineSize, false, fmt.Sprintf("included net amount at %s VAT", percent))
+		d.TextRight(invoiceRight, y, invoiceLineSize, false, formatCents(vat.Net))
+		nextLine()
+		d.Text(invoiceLeft+35, y, invoiceLineSize, false, fmt.Sprintf("included VAT at %s", percent))
+		d.TextRight(invoiceRight, y, invoiceLineSize, false, formatCents(vat.Vat))
+		nextLine()
+	}
+
+	for i, line := range cfg.Footer {
+		d.Text(invoiceLeft, 40+float64(len(cfg.Footer)-1-i)*10, 8, false, line)
+	}
+	return d
+}
+
+func formatCents(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+func formatVatPercent(percent float64) string {
+	return strconv.FormatFloat(percent, 'f', -1, 64) + "%"
+}
+
+func invoiceNotFoundErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, number string) {
+	aulogging.Logger.Ctx(ctx).Info().Printf("invoice %s not found", number)
+	ctlutil.ErrorHandler(ctx, w, r, "invoice.notfound", http.StatusNotFound, url.Values{})
+}
+
+func invoiceReadErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("invoices could not be read: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "invoice.read.error", http.StatusInternalServerError, url.Values{})
+}
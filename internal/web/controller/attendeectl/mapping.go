@@ -39,6 +39,11 @@ func mapDtoToAttendee(dto *attendee.AttendeeDto, a *entity.Attendee) {
 	} else {
 		a.RegistrationLanguage = addWrappingCommas(config.DefaultRegistrationLanguage())
 	}
+	if dto.Currency != "" {
+		a.Currency = dto.Currency
+	} else if a.Currency == "" {
+		a.Currency = config.Currency()
+	}
 	a.Flags = addWrappingCommas(dto.Flags)
 	a.Packages = packagesFromDto(dto.Packages, dto.PackagesList)
 	a.Options = addWrappingCommas(dto.Options)
@@ -66,6 +71,10 @@ func mapAttendeeToDto(a *entity.Attendee, dto *attendee.AttendeeDto) {
 	dto.TshirtSize = a.TshirtSize
 	dto.SpokenLanguages = removeWrappingCommas(a.SpokenLanguages)
 	dto.RegistrationLanguage = removeWrappingCommas(a.RegistrationLanguage)
+	dto.Currency = a.Currency
+	if dto.Currency == "" {
+		dto.Currency = config.Currency()
+	}
 	dto.Flags = removeWrappingCommas(a.Flags)
 	dto.Packages = packagesFromEntity(a.Packages)
 	dto.PackagesList = packagesListFromEntity(a.Packages)
@@ -0,0 +1,236 @@
+package attendeectl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/transfer"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/eurofurence/reg-attendee-service/internal/web/filter"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/validation"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-http-utils/headers"
+)
+
+func offerTransferHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	attd, err := transferAttendeeMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	dto, err := parseBodyToTransferOfferDto(ctx, w, r)
+	if err != nil {
+		return
+	}
+	errs := url.Values{}
+	validation.CheckLength(&errs, 1, 200, "email", dto.Email)
+	if validation.ViolatesPattern(emailPattern, dto.Email) {
+		errs.Add("email", "email field is not plausible, must match "+emailPattern)
+	}
+	if len(errs) != 0 {
+		transferValidationErrorHandler(ctx, w, r, errs)
+		return
+	}
+
+	if _, err := attendeeService.OfferTransfer(ctx, attd, dto.Email); err != nil {
+		if errors.Is(err, attendeesrv.TransferStatusError) {
+			transferUnavailableErrorHandler(ctx, w, r, "transfer.status.invalid", err)
+		} else {
+			transferWriteErrorHandler(ctx, w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set(headers.Location, r.URL.Path)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func getTransferHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	attd, err := transferAttendeeMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	pending, err := attendeeService.GetPendingTransfer(ctx, attd.ID)
+	if err != nil {
+		transferNotFoundErrorHandler(ctx, w, r)
+		return
+	}
+
+	dto := transfer.TransferDto{
+		AttendeeId: pending.AttendeeId,
+		Email:      pending.ToEmail,
+		ExpiresAt:  pending.ExpiresAt.Format(time.RFC3339),
+	}
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, dto)
+}
+
+func cancelTransferHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	attd, err := transferAttendeeMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	pending, err := attendeeService.GetPendingTransfer(ctx, attd.ID)
+	if err != nil {
+		transferNotFoundErrorHandler(ctx, w, r)
+		return
+	}
+
+	if err := attendeeService.CancelTransfer(ctx, pending); err != nil {
+		transferWriteErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func acceptTransferHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	offer, err := attendeeService.GetTransferByCode(ctx, chi.URLParam(r, "code"))
+	if err != nil {
+		transferNotFoundErrorHandler(ctx, w, r)
+		return
+	}
+	dto, err := parseBodyToAttendeeDto(ctx, w, r)
+	if err != nil {
+		return
+	}
+	attd, err := attendeeService.GetAttendee(ctx, offer.AttendeeId)
+	if err != nil {
+		ctlutil.AttendeeNotFoundErrorHandler(ctx, w, r, offer.AttendeeId)
+		return
+	}
+	latestStatus, err := obtainAttendeeLatestStatusMustReturnOnError(ctx, w, r, attd)
+	if err != nil {
+		return
+	}
+
+	// only the personal data is taken from the recipient, packages and the like stay as they are
+	merged := attendee.AttendeeDto{}
+	mapAttendeeToDto(attd, &merged)
+	mapPersonalData(dto, &merged)
+	validationErrs := validate(ctx, &merged, attd, latestStatus)
+	if !strings.EqualFold(merged.Email, offer.ToEmail) {
+		validationErrs.Add("email", "must be the email address the transfer was offered to")
+	}
+	if len(validationErrs) != 0 {
+		attendeeValidationErrorHandler(ctx, w, r, validationErrs)
+		return
+	}
+	mapDtoToAttendee(&merged, attd)
+
+	if err := attendeeService.AcceptTransfer(ctx, offer, attd); err != nil {
+		if errors.Is(err, attendeesrv.TransferStatusError) {
+			transferUnavailableErrorHandler(ctx, w, r, "transfer.status.invalid", err)
+		} else if errors.Is(err, attendeesrv.TransferNotPendingError) {
+			transferUnavailableErrorHandler(ctx, w, r, "transfer.state.invalid", err)
+		} else if errors.Is(err, attendeesrv.TransferExpiredError) {
+			transferUnavailableErrorHandler(ctx, w, r, "transfer.expired", err)
+		} else if errors.Is(err, attendeesrv.TransferRecipientError) {
+			transferUnavailableErrorHandler(ctx, w, r, "transfer.recipient.invalid", err)
+		} else if errors.Is(err, attendeesrv.BanCandidateError) {
+			transferUnavailableErrorHandler(ctx, w, r, "transfer.ban.match", errors.New("the recipient matches a ban rule, please contact the registration team"))
+		} else {
+			attendeeWriteErrorHandler(ctx, w, r, err)
+		}
+		return
+	}
+
+	result := attendee.AttendeeDto{}
+	mapAttendeeToDto(attd, &result)
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, result)
+}
+
+// mapPersonalData copies the fields that describe the person, as opposed to the registration.
+func mapPersonalData(from *attendee.AttendeeDto, to *attendee.AttendeeDto) {
+	to.Nickname = from.Nickname
+	to.FirstName = from.FirstName
+	to.LastName = from.LastName
+	to.Street = from.Street
+	to.Zip = from.Zip
+	to.City = from.City
+	to.Country = from.Country
+	to.State = from.State
+	to.Email = from.Email
+	to.Phone = from.Phone
+	to.Telegram = from.Telegram
+	to.Partner = from.Partner
+	to.Birthday = from.Birthday
+	to.Gender = from.Gender
+	to.Pronouns = from.Pronouns
+	to.TshirtSize = from.TshirtSize
+	to.SpokenLanguages = from.SpokenLanguages
+	to.RegistrationLanguage = from.RegistrationLanguage
+	to.UserComments = from.UserComments
+}
+
+// transferAttendeeMustReturnOnError loads the attendee from the path, which only the owner or an admin may transfer.
+func transferAttendeeMustReturnOnError(ctx context.Context, w http.ResponseWriter, r *http.Request) (*entity.Attendee, error) {
+	id, err := idFromVars(ctx, w, r)
+	if err != nil {
+		return nil, err
+	}
+	attd, err := attendeeService.GetAttendee(ctx, id)
+	if err != nil {
+		ctlutil.AttendeeNotFoundErrorHandler(ctx, w, r, id)
+		return nil, err
+	}
+	if err := filter.IsSubjectOrGroupOrApiToken(w, r, attd.Identity, config.OidcAdminGroup()); err != nil {
+		return nil, err
+	}
+	return attd, nil
+}
+
+func parseBodyToTransferOfferDto(ctx context.Context, w http.ResponseWriter, r *http.Request) (*transfer.TransferOfferDto, error) {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	dto := &transfer.TransferOfferDto{}
+	err := decoder.Decode(dto)
+	if err != nil {
+		transferParseErrorHandler(ctx, w, r, err)
+	}
+	return dto, err
+}
+
+func transferParseErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("transfer body could not be parsed: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "transfer.parse.error", http.StatusBadRequest, url.Values{})
+}
+
+func transferValidationErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, errs url.Values) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("received transfer data with validation errors: %v", errs)
+	ctlutil.ErrorHandler(ctx, w, r, "transfer.data.invalid", http.StatusBadRequest, errs)
+}
+
+func transferNotFoundErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	aulogging.Logger.Ctx(ctx).Info().Printf("transfer not found")
+	ctlutil.ErrorHandler(ctx, w, r, "transfer.notfound", http.StatusNotFound, url.Values{})
+}
+
+func transferUnavailableErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, message string, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("transfer not possible: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, message, http.StatusConflict, url.Values{"details": {err.Error()}})
+}
+
+func transferWriteErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("transfer could not be written: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "transfer.write.error", http.StatusInternalServerError, url.Values{})
+}
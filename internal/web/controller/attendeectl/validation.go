@@ -96,6 +96,13 @@ func validate(ctx context.Context, a *attendee.AttendeeDto, trustedOriginalState
 	if validation.NotInAllowedValues(config.AllowedRegistrationLanguages(), a.RegistrationLanguage) {
 		errs.Add("registration_language", "registration_language field must be one of "+strings.Join(config.AllowedRegistrationLanguages(), ",")+" or it can be left blank, which counts as "+config.DefaultRegistrationLanguage())
 	}
+	if a.Currency != "" {
+		if validation.NotInAllowedValues(config.AllowedCurrencies(), a.Currency) {
+			errs.Add("currency", "currency field must be one of "+strings.Join(config.AllowedCurrencies(), ",")+" or it can be left blank, which counts as "+config.Currency())
+		} else if trustedOriginalState.ID != 0 && a.Currency != originalCurrency(trustedOriginalState) {
+			errs.Add("currency", "currency cannot be changed after registration")
+		}
+	}
 	validation.CheckCombinationOfAllowedValues(&errs, config.AllowedFlagsNoAdmin(), "flags", a.Flags)
 	checkPackagesValid(&errs, config.PackagesConfig(), a.Packages)
 	checkPackagesListValid(&errs, config.PackagesConfig(), a.PackagesList)
@@ -104,15 +111,23 @@ func validate(ctx context.Context, a *attendee.AttendeeDto, trustedOriginalState
 		errs.Add("tshirt_size", "optional tshirt_size field must be empty or one of "+strings.Join(config.AllowedTshirtSizes(), ","))
 	}
 
+	// constraints on choices may refer to the other choices, the birthday and the status after the change
+	newState := *trustedOriginalState
+	mapDtoToAttendee(a, &newState)
+	constraintStatus := currentStatus
+	if constraintStatus == "irrelevant" {
+		constraintStatus = status.New
+	}
+
 	// check permission to change flags, packages, options, email to their new values
-	if err := attendeeService.CanChangeChoiceTo(ctx, "flag", trustedOriginalState.Flags, a.Flags, config.FlagsConfigNoAdmin()); err != nil {
+	if err := attendeeService.CanChangeChoiceTo(ctx, "flag", trustedOriginalState.Flags, a.Flags, config.FlagsConfigNoAdmin(), &newState, constraintStatus); err != nil {
 		errs.Add("flags", err.Error())
 	}
 	newPackagesList := packagesListWithPrecedence(a.Packages, a.PackagesList)
-	if err := attendeeService.CanChangeChoiceToCurrentStatus(ctx, "package", packagesListFromEntity(trustedOriginalState.Packages), newPackagesList, config.PackagesConfig(), currentStatus); err != nil {
+	if err := attendeeService.CanChangeChoiceToCurrentStatus(ctx, "package", packagesListFromEntity(trustedOriginalState.Packages), newPackagesList, config.PackagesConfig(), &newState, currentStatus); err != nil {
 		errs.Add("packages", err.Error())
 	}
-	if err := attendeeService.CanChangeChoiceTo(ctx, "option", trustedOriginalState.Options, a.Options, config.OptionsConfig()); err != nil {
+	if err := attendeeService.CanChangeChoiceTo(ctx, "option", trustedOriginalState.Options, a.Options, config.OptionsConfig(), &newState, constraintStatus); err != nil {
 		errs.Add("options", err.Error())
 	}
 	if err := attendeeService.CanChangeEmailTo(ctx, trustedOriginalState.Email, a.Email); err != nil {
@@ -134,6 +149,15 @@ func validate(ctx context.Context, a *attendee.AttendeeDto, trustedOriginalState
 	return errs
 }
 
+// originalCurrency is the billing currency of an existing registration, which is the default currency
+// for registrations from before there was a choice.
+func originalCurrency(a *entity.Attendee) string {
+	if a.Currency == "" {
+		return config.Currency()
+	}
+	return a.Currency
+}
+
 func validateDueDateChange(ctx context.Context, d *attendee.DueDate, trustedOriginalState *entity.Attendee) url.Values {
 	errs := url.Values{}
 
@@ -31,6 +31,7 @@ func tstCreateValidAttendee() attendee.AttendeeDto {
 		Gender:               "other",
 		SpokenLanguages:      "de,en",
 		RegistrationLanguage: "en-US",
+		Currency:             "EUR",
 		Flags:                "anon,ev",
 		Packages:             "attendance,mountain-trip,mountain-trip,mountain-trip,room-none,sponsor2,stage", // must be sorted for tests to work
 		PackagesList: []attendee.PackageState{
@@ -233,7 +234,7 @@ func TestValidateChoiceFieldsAndId(t *testing.T) {
 
 	expected := url.Values{
 		"gender":  []string{"optional gender field must be one of male, female, other, notprovided, or it can be left blank, which counts as notprovided"},
-		"options": []string{"options field must be a comma separated combination of any of anim,art,music,suit"},
+		"options": []string{"options field must be a comma separated combination of any of anim,art,junior,music,suit"},
 		"flags":   []string{"flags field must be a comma separated combination of any of anon,ev,hc,terms-accepted"},
 		"packages": []string{
 			"package room-none occurs too many times, can occur at most 1 times",
@@ -274,6 +275,17 @@ func TestValidatePreventSettingIdFieldWrongValue(t *testing.T) {
 	performValidationTest(t, &a, expected, 16)
 }
 
+func TestValidateUnknownCurrency(t *testing.T) {
+	docs.Description("an attendee can only choose one of the configured currencies")
+	a := tstCreateValidAttendee()
+	a.Currency = "XYZ"
+
+	expected := url.Values{
+		"currency": []string{"currency field must be one of EUR,GBP or it can be left blank, which counts as EUR"},
+	}
+	performValidationTest(t, &a, expected, 0)
+}
+
 func TestValidateWrongEmailWhitespaceInUsername(t *testing.T) {
 	docs.Description("an attendee with whitespace in the username part of the email address must be rejected")
 	performEmailValidationTest(t, "white\tspace@mailinator.com")
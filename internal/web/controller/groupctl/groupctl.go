@@ -0,0 +1,365 @@
+package groupctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/groups"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/eurofurence/reg-attendee-service/internal/web/filter"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/validation"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-http-utils/headers"
+)
+
+var attendeeService attendeesrv.AttendeeService
+
+func Create(server chi.Router, attendeeSrv attendeesrv.AttendeeService) {
+	attendeeService = attendeeSrv
+
+	server.Post("/api/rest/v1/attendees/{id}/group", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, createGroupHandler)))
+	server.Get("/api/rest/v1/attendees/{id}/group", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, getGroupOfAttendeeHandler)))
+	server.Get("/api/rest/v1/attendees/{id}/group-invitations", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, getGroupInvitationsHandler)))
+	server.Get("/api/rest/v1/groups/{groupId}", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, getGroupHandler)))
+	server.Delete("/api/rest/v1/groups/{groupId}", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, disbandGroupHandler)))
+	server.Post("/api/rest/v1/groups/{groupId}/members/{id}", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, inviteHandler)))
+	server.Post("/api/rest/v1/groups/{groupId}/members/{id}/accept", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, acceptHandler)))
+	server.Delete("/api/rest/v1/groups/{groupId}/members/{id}", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, removeHandler)))
+}
+
+func createGroupHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	attd, err := attendeeFromVarsMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	if err := filter.IsSubjectOrGroupOrApiToken(w, r, attd.Identity, config.OidcAdminGroup()); err != nil {
+		return
+	}
+	dto, err := parseBodyToGroupCreateDto(ctx, w, r)
+	if err != nil {
+		return
+	}
+	errs := url.Values{}
+	validation.CheckLength(&errs, 1, 80, "name", dto.Name)
+	if len(errs) != 0 {
+		groupValidationErrorHandler(ctx, w, r, errs)
+		return
+	}
+
+	group, err := attendeeService.CreateGroup(ctx, attd, dto.Name)
+	if err != nil {
+		groupWriteErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	location := fmt.Sprintf("/api/rest/v1/groups/%d", group.ID)
+	aulogging.Logger.Ctx(ctx).Info().Printf("sending Location %s", location)
+	w.Header().Set(headers.Location, location)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func getGroupOfAttendeeHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	attd, err := attendeeFromVarsMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	if err := filter.IsSubjectOrGroupOrApiToken(w, r, attd.Identity, config.OidcAdminGroup()); err != nil {
+		return
+	}
+	group, err := attendeeService.GetGroupOf(ctx, attd.ID)
+	if err != nil {
+		groupNotFoundErrorHandler(ctx, w, r)
+		return
+	}
+	dto, err := groupToDto(ctx, group)
+	if err != nil {
+		groupReadErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, dto)
+}
+
+func getGroupInvitationsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	attd, err := attendeeFromVarsMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	if err := filter.IsSubjectOrGroupOrApiToken(w, r, attd.Identity, config.OidcAdminGroup()); err != nil {
+		return
+	}
+	invitations, err := attendeeService.GetGroupInvitations(ctx, attd.ID)
+	if err != nil {
+		groupReadErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	result := groups.GroupList{
+		Groups: make([]groups.Group, len(invitations)),
+	}
+	for i, group := range invitations {
+		dto, err := groupToDto(ctx, group)
+		if err != nil {
+			groupReadErrorHandler(ctx, w, r, err)
+			return
+		}
+		result.Groups[i] = *dto
+	}
+
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, result)
+}
+
+func getGroupHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	group, members, err := groupFromVarsMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	// members and invitees may see who else is in the group
+	memberIds := make([]uint, len(members))
+	for i, m := range members {
+		memberIds[i] = m.AttendeeId
+	}
+	if err := isOwnerOfOneOfOrAdmin(w, r, memberIds...); err != nil {
+		return
+	}
+	dto, err := groupToDto(ctx, group)
+	if err != nil {
+		groupReadErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, dto)
+}
+
+func disbandGroupHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	group, _, err := groupFromVarsMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	if err := isOwnerOfOneOfOrAdmin(w, r, group.OwnerId); err != nil {
+		return
+	}
+
+	if err := attendeeService.DisbandGroup(ctx, group); err != nil {
+		groupWriteErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func inviteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	group, _, err := groupFromVarsMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	if err := isOwnerOfOneOfOrAdmin(w, r, group.OwnerId); err != nil {
+		return
+	}
+	invitee, err := attendeeFromVarsMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+
+	if err := attendeeService.InviteToGroup(ctx, group, invitee); err != nil {
+		groupWriteErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func acceptHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	group, _, err := groupFromVarsMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	attd, err := attendeeFromVarsMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	if err := filter.IsSubjectOrGroupOrApiToken(w, r, attd.Identity, config.OidcAdminGroup()); err != nil {
+		return
+	}
+
+	if err := attendeeService.JoinGroup(ctx, group, attd); err != nil {
+		groupWriteErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func removeHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	group, _, err := groupFromVarsMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	attd, err := attendeeFromVarsMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	// the owner can remove anyone, everyone else can only leave or decline
+	if err := isOwnerOfOneOfOrAdmin(w, r, group.OwnerId, attd.ID); err != nil {
+		return
+	}
+
+	if err := attendeeService.RemoveFromGroup(ctx, group, attd); err != nil {
+		groupWriteErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func groupToDto(ctx context.Context, group *entity.Group) (*groups.Group, error) {
+	_, members, err := attendeeService.GetGroup(ctx, group.ID)
+	if err != nil {
+		return nil, err
+	}
+	dto := groups.Group{
+		Id:      group.ID,
+		Name:    group.Name,
+		Owner:   group.OwnerId,
+		Members: make([]groups.GroupMember, len(members)),
+	}
+	for i, m := range members {
+		attd, err := attendeeService.GetAttendee(ctx, m.AttendeeId)
+		if err != nil {
+			return nil, err
+		}
+		dto.Members[i] = groups.GroupMember{
+			Id:       m.AttendeeId,
+			Nickname: attd.Nickname,
+			Joined:   m.State == entity.GroupMemberStateAccepted,
+		}
+	}
+	return &dto, nil
+}
+
+// isOwnerOfOneOfOrAdmin allows access if the logged in subject owns one of the registrations, or for admins and the api token.
+func isOwnerOfOneOfOrAdmin(w http.ResponseWriter, r *http.Request, attendeeIds ...uint) error {
+	ctx := r.Context()
+	if filter.IsGroupOrApiTokenCond(r, config.OidcAdminGroup()) {
+		return nil
+	}
+	subject := ctxvalues.Subject(ctx)
+	for _, id := range attendeeIds {
+		attd, err := attendeeService.GetAttendee(ctx, id)
+		if err == nil && subject != "" && attd.Identity == subject {
+			return nil
+		}
+	}
+	ctlutil.UnauthorizedError(ctx, w, r, "you are not authorized to access this data - the attempt has been logged", fmt.Sprintf("unauthorized access attempt for group %s by %s", chi.URLParam(r, "groupId"), subject))
+	return errors.New("subject does not own any of the registrations - unauthorized")
+}
+
+func attendeeFromVarsMustReturnOnError(ctx context.Context, w http.ResponseWriter, r *http.Request) (*entity.Attendee, error) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		ctlutil.InvalidAttendeeIdErrorHandler(ctx, w, r, url.QueryEscape(idStr))
+		return nil, err
+	}
+	attd, err := attendeeService.GetAttendee(ctx, uint(id))
+	if err != nil {
+		ctlutil.AttendeeNotFoundErrorHandler(ctx, w, r, uint(id))
+		return nil, err
+	}
+	return attd, nil
+}
+
+func groupFromVarsMustReturnOnError(ctx context.Context, w http.ResponseWriter, r *http.Request) (*entity.Group, []*entity.GroupMember, error) {
+	idStr := chi.URLParam(r, "groupId")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().Printf("received invalid group id '%s'", url.QueryEscape(idStr))
+		ctlutil.ErrorHandler(ctx, w, r, "group.id.invalid", http.StatusBadRequest, url.Values{})
+		return nil, nil, err
+	}
+	group, members, err := attendeeService.GetGroup(ctx, uint(id))
+	if err != nil {
+		groupNotFoundErrorHandler(ctx, w, r)
+		return nil, nil, err
+	}
+	return group, members, nil
+}
+
+func parseBodyToGroupCreateDto(ctx context.Context, w http.ResponseWriter, r *http.Request) (*groups.GroupCreate, error) {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	dto := &groups.GroupCreate{}
+	err := decoder.Decode(dto)
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("group body could not be parsed: %s", err.Error())
+		ctlutil.ErrorHandler(ctx, w, r, "group.parse.error", http.StatusBadRequest, url.Values{})
+	}
+	return dto, err
+}
+
+func groupValidationErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, errs url.Values) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("received group data with validation errors: %v", errs)
+	ctlutil.ErrorHandler(ctx, w, r, "group.data.invalid", http.StatusBadRequest, errs)
+}
+
+func groupNotFoundErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	aulogging.Logger.Ctx(ctx).Info().Printf("group not found")
+	ctlutil.ErrorHandler(ctx, w, r, "group.notfound", http.StatusNotFound, url.Values{})
+}
+
+func groupReadErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("group could not be read: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "group.read.error", http.StatusInternalServerError, url.Values{})
+}
+
+func groupWriteErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	conflicts := map[error]string{
+		attendeesrv.GroupStatusError:         "group.status.invalid",
+		attendeesrv.GroupMembershipError:     "group.member.conflict",
+		attendeesrv.GroupAlreadyInvitedError: "group.invitation.exists",
+		attendeesrv.GroupNotInvitedError:     "group.invitation.missing",
+		attendeesrv.GroupNotMemberError:      "group.member.missing",
+		attendeesrv.GroupOwnerError:          "group.owner.remove",
+	}
+	for conflict, message := range conflicts {
+		if errors.Is(err, conflict) {
+			aulogging.Logger.Ctx(ctx).Warn().Printf("group change not possible: %s", err.Error())
+			ctlutil.ErrorHandler(ctx, w, r, message, http.StatusConflict, url.Values{"details": {err.Error()}})
+			return
+		}
+	}
+	if errors.Is(err, attendeesrv.GroupConstraintError) {
+		aulogging.Logger.Ctx(ctx).Warn().Printf("group change not possible: %s", err.Error())
+		ctlutil.ErrorHandler(ctx, w, r, "group.constraint.violated", http.StatusConflict, url.Values{"details": {config.GroupConstraintMsg()}})
+		return
+	}
+	aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("group could not be written: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "group.write.error", http.StatusInternalServerError, url.Values{})
+}
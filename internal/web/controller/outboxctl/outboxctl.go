@@ -0,0 +1,181 @@
+package outboxctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/outbox"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/mailservice"
+	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/eurofurence/reg-attendee-service/internal/web/filter"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-http-utils/headers"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var attendeeService attendeesrv.AttendeeService
+
+func Create(server chi.Router, attendeeSrv attendeesrv.AttendeeService) {
+	attendeeService = attendeeSrv
+
+	server.Get("/api/rest/v1/outbox", filter.HasGroupOrApiToken(config.OidcAdminGroup(), filter.WithTimeout(3*time.Second, listOutboxHandler)))
+	server.Get("/api/rest/v1/outbox/{id}", filter.HasGroupOrApiToken(config.OidcAdminGroup(), filter.WithTimeout(3*time.Second, getOutboxHandler)))
+	server.Post("/api/rest/v1/outbox/{id}/retry", filter.HasGroupOrApiToken(config.OidcAdminGroup(), filter.WithTimeout(10*time.Second, retryOutboxHandler)))
+	server.Delete("/api/rest/v1/outbox/{id}", filter.HasGroupOrApiToken(config.OidcAdminGroup(), filter.WithTimeout(3*time.Second, discardOutboxHandler)))
+}
+
+func listOutboxHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	state := r.URL.Query().Get("state")
+	if state == "" {
+		state = entity.OutboxStateFailed
+	}
+	if state != entity.OutboxStatePending && state != entity.OutboxStateSending && state != entity.OutboxStateSent && state != entity.OutboxStateFailed {
+		outboxStateInvalidErrorHandler(ctx, w, r, state)
+		return
+	}
+
+	mails, err := attendeeService.GetOutboxMails(ctx, state)
+	if err != nil {
+		outboxReadErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	response := outbox.OutboxMailList{
+		Mails: make([]outbox.OutboxMail, len(mails)),
+	}
+	for i, m := range mails {
+		mapOutboxMailToDto(ctx, m, &response.Mails[i])
+	}
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, response)
+}
+
+func getOutboxHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	mail, err := outboxMailFromVars(ctx, w, r)
+	if err != nil {
+		return
+	}
+
+	response := outbox.OutboxMail{}
+	mapOutboxMailToDto(ctx, mail, &response)
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, response)
+}
+
+func retryOutboxHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	mail, err := outboxMailFromVars(ctx, w, r)
+	if err != nil {
+		return
+	}
+
+	err = attendeeService.RetryOutboxMail(ctx, mail)
+	if err != nil {
+		outboxWriteErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	response := outbox.OutboxMail{}
+	mapOutboxMailToDto(ctx, mail, &response)
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, response)
+}
+
+func discardOutboxHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	mail, err := outboxMailFromVars(ctx, w, r)
+	if err != nil {
+		return
+	}
+
+	err = attendeeService.DiscardOutboxMail(ctx, mail)
+	if err != nil {
+		outboxWriteErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func outboxMailFromVars(ctx context.Context, w http.ResponseWriter, r *http.Request) (*entity.OutboxMail, error) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		invalidOutboxIdErrorHandler(ctx, w, r, idStr)
+		return nil, err
+	}
+
+	mail, err := attendeeService.GetOutboxMail(ctx, uint(id))
+	if err != nil {
+		outboxNotFoundErrorHandler(ctx, w, r, uint(id))
+		return nil, err
+	}
+	return mail, nil
+}
+
+func mapOutboxMailToDto(ctx context.Context, mail *entity.OutboxMail, dto *outbox.OutboxMail) {
+	dto.Id = mail.ID
+	dto.AttendeeId = mail.AttendeeId
+	dto.CommonID = mail.CommonID
+	dto.State = mail.State
+	dto.Attempts = mail.Attempts
+	dto.NextAttempt = mail.NextAttempt.Format(time.RFC3339)
+	dto.LastError = mail.LastError
+	dto.Created = mail.CreatedAt.Format(time.RFC3339)
+
+	payload := mailservice.MailSendDto{}
+	if err := json.Unmarshal([]byte(mail.Payload), &payload); err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("outbox mail %d has an invalid payload: %s", mail.ID, err.Error())
+		dto.To = []string{}
+		return
+	}
+	dto.Lang = payload.Lang
+	dto.To = payload.To
+	if dto.To == nil {
+		dto.To = []string{}
+	}
+}
+
+func invalidOutboxIdErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, id string) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("received invalid outbox mail id '%s'", url.QueryEscape(id))
+	ctlutil.ErrorHandler(ctx, w, r, "outbox.id.invalid", http.StatusBadRequest, url.Values{})
+}
+
+func outboxStateInvalidErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, state string) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("received invalid outbox state '%s'", url.QueryEscape(state))
+	ctlutil.ErrorHandler(ctx, w, r, "outbox.state.invalid", http.StatusBadRequest, url.Values{"state": {"must be one of pending, sending, sent, failed"}})
+}
+
+func outboxNotFoundErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, id uint) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("outbox mail id %d not found", id)
+	ctlutil.ErrorHandler(ctx, w, r, "outbox.id.notfound", http.StatusNotFound, url.Values{})
+}
+
+func outboxWriteErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("outbox mail could not be updated: %s", err.Error())
+	if errors.Is(err, attendeesrv.OutboxMailAlreadySentError) {
+		ctlutil.ErrorHandler(ctx, w, r, "outbox.mail.sent", http.StatusConflict, url.Values{"state": {"this mail has already been sent"}})
+	} else if errors.Is(err, attendeesrv.OutboxMailSendingError) {
+		ctlutil.ErrorHandler(ctx, w, r, "outbox.mail.sending", http.StatusConflict, url.Values{"state": {"this mail is being sent right now"}})
+	} else {
+		ctlutil.ErrorHandler(ctx, w, r, "outbox.write.error", http.StatusInternalServerError, url.Values{})
+	}
+}
+
+func outboxReadErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("outbox mail(s) could not be read: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "outbox.read.error", http.StatusInternalServerError, url.Values{})
+}
@@ -9,6 +9,7 @@ import (
 
 	aulogging "github.com/StephanHCB/go-autumn-logging"
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/counts"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/waitlist"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
 	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
 	"github.com/eurofurence/reg-attendee-service/internal/web/filter"
@@ -25,6 +26,7 @@ func Create(server chi.Router, attendeeSrv attendeesrv.AttendeeService) {
 
 	server.Get("/api/rest/v1/packages/{package}/limit", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, getPackageLimit)))
 	server.Post("/api/rest/v1/packages/{package}/limit", filter.HasGroupOrApiToken(config.OidcAdminGroup(), filter.WithTimeout(30*time.Second, recalcPackageLimit)))
+	server.Get("/api/rest/v1/packages/{package}/waitlist", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, getPackageWaitlist)))
 }
 
 func getPackageLimit(w http.ResponseWriter, r *http.Request) {
@@ -76,6 +78,58 @@ func recalcPackageLimit(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func getPackageWaitlist(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	code, choice, err := packageFromVars(ctx, w, r)
+	if err != nil {
+		return
+	}
+
+	if !choice.Waitlist {
+		packageNoWaitlistErrorHandler(ctx, w, r, code)
+		return
+	}
+
+	queue, err := attendeeService.GetWaitlist(ctx, code)
+	if err != nil {
+		otherErrorHandler(ctx, w, r, code, err)
+		return
+	}
+
+	// normal users only get to see their own position(s) in the queue
+	seeAll := filter.IsGroupOrApiTokenCond(r, config.OidcAdminGroup())
+	owned := make(map[uint]bool)
+	if !seeAll {
+		ownedAttendees, err := attendeeService.IsOwnerFor(ctx)
+		if err != nil {
+			otherErrorHandler(ctx, w, r, code, err)
+			return
+		}
+		for _, att := range ownedAttendees {
+			owned[att.ID] = true
+		}
+	}
+
+	dto := waitlist.Waitlist{
+		Package: code,
+		Length:  len(queue),
+		Entries: make([]waitlist.WaitlistEntry, 0),
+	}
+	for i, entry := range queue {
+		if seeAll || owned[entry.AttendeeId] {
+			dto.Entries = append(dto.Entries, waitlist.WaitlistEntry{
+				Position: i + 1,
+				Id:       entry.AttendeeId,
+				Count:    entry.Count,
+				Since:    entry.CreatedAt.Format(time.RFC3339),
+			})
+		}
+	}
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, dto)
+}
+
 func packageFromVars(ctx context.Context, w http.ResponseWriter, r *http.Request) (string, config.ChoiceConfig, error) {
 	code := chi.URLParam(r, "package")
 	choice, ok := config.Configuration().Choices.Packages[code]
@@ -96,6 +150,11 @@ func packageUnlimitedErrorHandler(ctx context.Context, w http.ResponseWriter, r
 	ctlutil.ErrorHandler(ctx, w, r, "package.param.unlimited", http.StatusBadRequest, url.Values{"details": []string{"this package is unlimited, we do not track allocations for unlimited packages"}})
 }
 
+func packageNoWaitlistErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, code string) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("package %s has no waiting list", url.QueryEscape(code))
+	ctlutil.ErrorHandler(ctx, w, r, "package.param.nowaitlist", http.StatusBadRequest, url.Values{"details": []string{"this package does not have a waiting list"}})
+}
+
 func otherErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, code string, err error) {
 	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("failed to check limits for package %s: %s", url.QueryEscape(code), err.Error())
 	ctlutil.ErrorHandler(ctx, w, r, "package.read.error", http.StatusInternalServerError, url.Values{})
@@ -0,0 +1,264 @@
+package statusctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/bulkstatus"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/mailservice"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
+	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/go-http-utils/headers"
+)
+
+func bulkStatusHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	dto, err := parseBodyToBulkStatusChangeDto(ctx, w, r)
+	if err != nil {
+		return
+	}
+	validationErrs := validateBulk(ctx, dto)
+	if len(validationErrs) != 0 {
+		statusChangeValidationErrorHandler(ctx, w, r, validationErrs)
+		return
+	}
+
+	ids := dto.Ids
+	if dto.Criteria != nil {
+		ids, err = bulkIdsFromCriteria(ctx, dto)
+		if err != nil {
+			statusReadErrorHandler(ctx, w, r, err)
+			return
+		}
+		if len(ids) > config.BulkStatusMaxIds() {
+			statusChangeValidationErrorHandler(ctx, w, r, url.Values{"criteria": {fmt.Sprintf("matches %d attendees, but at most %d can be changed at once", len(ids), config.BulkStatusMaxIds())}})
+			return
+		}
+	}
+
+	simulated := &simulatedLimits{deltas: make(map[string]int)}
+	results := processBulk(ctx, ids, func(ctx context.Context, id uint) bulkstatus.BulkStatusChangeResult {
+		return bulkStatusChange(ctx, id, dto, simulated)
+	})
+
+	resultList := bulkstatus.BulkStatusChangeResultList{
+		DryRun:  dto.DryRun,
+		Results: results,
+	}
+	for _, result := range results {
+		if result.Success {
+			resultList.Succeeded++
+		} else {
+			resultList.Failed++
+		}
+	}
+	aulogging.Logger.Ctx(ctx).Info().Printf("bulk status change to %s (dry run %t): %d succeeded, %d failed", dto.Status, dto.DryRun, resultList.Succeeded, resultList.Failed)
+
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, resultList)
+}
+
+// processBulk runs f for all ids with bounded concurrency, and returns the results in the order of the ids.
+//
+// The in-memory database is not safe for concurrent use, so then the ids are processed one after the other.
+func processBulk(ctx context.Context, ids []uint, f func(ctx context.Context, id uint) bulkstatus.BulkStatusChangeResult) []bulkstatus.BulkStatusChangeResult {
+	results := make([]bulkstatus.BulkStatusChangeResult, len(ids))
+
+	workers := min(config.BulkStatusConcurrency(), len(ids))
+	if config.DatabaseUse() == config.Inmemory {
+		workers = min(1, len(ids))
+	}
+
+	indexes := make(chan int)
+	wg := sync.WaitGroup{}
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = f(ctx, ids[i])
+			}
+		}()
+	}
+	for i := range ids {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+// simulatedLimits adds up the limit changes of a dry run, which are never recorded, so each attendee is
+// checked against what the attendees before it in the same bulk change would have taken.
+type simulatedLimits struct {
+	mu     sync.Mutex
+	deltas map[string]int
+}
+
+// apply checks that the limit changes of one attendee still fit on top of the ones simulated so far,
+// and if they do, adds them.
+func (l *simulatedLimits) apply(ctx context.Context, limitChanges []*entity.Count) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, delta := range limitChanges {
+		current, err := attendeeService.GetLimitBookings(ctx, delta.Name)
+		if err != nil {
+			return err
+		}
+		if current.Pending+current.Attending+l.deltas[delta.Name]+delta.Pending+delta.Attending > config.PackagesConfig()[delta.Name].Limit {
+			return fmt.Errorf("cannot allocate package '%s', stock limit reached by earlier attendees in this bulk change: %w", delta.Name, attendeesrv.IntroducesOverrun)
+		}
+	}
+	for _, delta := range limitChanges {
+		l.deltas[delta.Name] += delta.Pending + delta.Attending
+	}
+	return nil
+}
+
+// bulkStatusChange performs the same steps as a single status change, but reports the outcome instead of
+// writing an error response.
+func bulkStatusChange(ctx context.Context, id uint, dto *bulkstatus.BulkStatusChangeDto, simulated *simulatedLimits) bulkstatus.BulkStatusChangeResult {
+	result := bulkstatus.BulkStatusChangeResult{Id: id}
+	fail := func(message string, err error) bulkstatus.BulkStatusChangeResult {
+		aulogging.Logger.Ctx(ctx).Info().Printf("bulk status change for attendee id %d failed: %s - %s", id, message, err.Error())
+		result.Error = message
+		result.Details = err.Error()
+		return result
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fail("status.write.error", err)
+	}
+
+	att, err := attendeeService.GetAttendee(ctx, id)
+	if err != nil {
+		return fail("attendee.id.notfound", err)
+	}
+	history, err := attendeeService.GetFullStatusHistory(ctx, att)
+	if err != nil {
+		return fail("status.read.error", err)
+	} else if len(history) == 0 {
+		return fail("status.read.error", errors.New("got empty status change history"))
+	}
+	oldStatus := history[len(history)-1].Status
+	result.OldStatus = oldStatus
+
+	if err := attendeeService.StatusChangeAllowed(ctx, att, oldStatus, dto.Status); err != nil {
+		return fail("auth.forbidden", err)
+	}
+	if err := attendeeService.StatusChangePossible(ctx, att, oldStatus, dto.Status); err != nil {
+		if errors.Is(err, paymentservice.DownstreamError) || errors.Is(err, mailservice.DownstreamError) {
+			return fail(downstreamMessage(err), err)
+		}
+		return fail(unavailableMessage(err), err)
+	}
+
+	if dto.DryRun {
+		limitChanges, err := attendeeService.ComputeDeltasAndCheckLimitOverrun(ctx, att, att, oldStatus, dto.Status)
+		if err == nil {
+			err = simulated.apply(ctx, limitChanges)
+		}
+		if err != nil {
+			return fail(unavailableMessage(err), err)
+		}
+		result.NewStatus = dto.Status
+		result.Success = true
+		return result
+	}
+
+	err = attendeeService.WithTransaction(ctx, func(ctx context.Context) error {
+		// computed inside the transaction, which locks the counts, so attendees processed earlier or concurrently
+		// in the same bulk change are taken into account
+		limitChanges, err := attendeeService.ComputeDeltasAndCheckLimitOverrun(ctx, att, att, oldStatus, dto.Status)
+		if err != nil {
+			return err
+		}
+
+		err = attendeeService.UpdateDuesAndDoStatusChangeIfNeeded(ctx, att, oldStatus, dto.Status, dto.Comment, "", false, false)
+		if err != nil {
+			return err
+		}
+
+		return attendeeService.RecordLimitChanges(ctx, limitChanges)
+	})
+	if err != nil {
+		if errors.Is(err, paymentservice.DownstreamError) || errors.Is(err, mailservice.DownstreamError) {
+			return fail(downstreamMessage(err), err)
+		} else if errors.Is(err, attendeesrv.IntroducesOverrun) {
+			return fail(unavailableMessage(err), err)
+		}
+		return fail("status.write.error", err)
+	}
+
+	// dues changes may have advanced the status further than requested
+	history, err = attendeeService.GetFullStatusHistory(ctx, att)
+	if err == nil && len(history) > 0 {
+		result.NewStatus = history[len(history)-1].Status
+	} else {
+		result.NewStatus = dto.Status
+	}
+	result.Success = true
+	return result
+}
+
+func bulkIdsFromCriteria(ctx context.Context, dto *bulkstatus.BulkStatusChangeDto) ([]uint, error) {
+	found, err := attendeeService.FindAttendees(ctx, dto.Criteria)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, len(found.Attendees))
+	for i, a := range found.Attendees {
+		ids[i] = a.Id
+	}
+	return ids, nil
+}
+
+func validateBulk(ctx context.Context, dto *bulkstatus.BulkStatusChangeDto) url.Values {
+	// the original status does not matter for validation
+	errs := validate(ctx, status.New, &status.StatusChangeDto{
+		Status:  dto.Status,
+		Comment: dto.Comment,
+	})
+
+	if len(dto.Ids) == 0 && dto.Criteria == nil {
+		errs.Add("ids", "must specify either ids or criteria")
+	} else if len(dto.Ids) > 0 && dto.Criteria != nil {
+		errs.Add("ids", "cannot specify both ids and criteria")
+	} else if len(dto.Ids) > config.BulkStatusMaxIds() {
+		errs.Add("ids", fmt.Sprintf("at most %d attendees can be changed at once", config.BulkStatusMaxIds()))
+	}
+
+	seen := make(map[uint]bool)
+	for _, id := range dto.Ids {
+		if seen[id] {
+			errs.Add("ids", fmt.Sprintf("duplicate id %d", id))
+		}
+		seen[id] = true
+	}
+	return errs
+}
+
+func parseBodyToBulkStatusChangeDto(ctx context.Context, w http.ResponseWriter, r *http.Request) (*bulkstatus.BulkStatusChangeDto, error) {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	dto := &bulkstatus.BulkStatusChangeDto{}
+	err := decoder.Decode(dto)
+	if err != nil {
+		statusParseErrorHandler(ctx, w, r, err)
+	}
+	return dto, err
+}
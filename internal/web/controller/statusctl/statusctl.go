@@ -33,6 +33,7 @@ func Create(server chi.Router, attendeeSrv attendeesrv.AttendeeService) {
 	server.Post("/api/rest/v1/attendees/{id}/status", filter.LoggedInOrApiToken(filter.WithTimeout(10*time.Second, postStatusHandler)))
 	server.Get("/api/rest/v1/attendees/{id}/status-history", filter.HasGroupOrApiToken(config.OidcAdminGroup(), filter.WithTimeout(3*time.Second, getStatusHistoryHandler)))
 	server.Post("/api/rest/v1/attendees/{id}/status/resend", filter.HasGroupOrApiToken(config.OidcAdminGroup(), filter.WithTimeout(10*time.Second, resendStatusMailHandler)))
+	server.Post("/api/rest/v1/status/bulk", filter.HasGroupOrApiToken(config.OidcAdminGroup(), filter.WithTimeout(120*time.Second, bulkStatusHandler)))
 	server.Post("/api/rest/v1/attendees/{id}/payments-changed", filter.HasGroupOrApiToken(config.OidcAdminGroup(), filter.WithTimeout(10*time.Second, paymentsChangedHandler)))
 }
 
@@ -104,21 +105,25 @@ func postStatusHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = attendeeService.UpdateDuesAndDoStatusChangeIfNeeded(ctx, att, latestStatusChange.Status, dto.Status, dto.Comment, "", false, false)
+	err = attendeeService.WithTransaction(ctx, func(ctx context.Context) error {
+		err := attendeeService.UpdateDuesAndDoStatusChangeIfNeeded(ctx, att, latestStatusChange.Status, dto.Status, dto.Comment, "", false, false)
+		if err != nil {
+			return err
+		}
+
+		return attendeeService.RecordLimitChanges(ctx, limitChanges)
+	})
 	if err != nil {
 		if errors.Is(err, paymentservice.DownstreamError) || errors.Is(err, mailservice.DownstreamError) {
 			statusChangeDownstreamError(ctx, w, r, err)
+		} else if errors.Is(err, attendeesrv.MixedCurrenciesError) {
+			statusChangeUnavailableErrorHandler(ctx, w, r, err)
 		} else {
 			statusWriteErrorHandler(ctx, w, r, err)
 		}
 		return
 	}
 
-	if err := attendeeService.RecordLimitChanges(ctx, limitChanges); err != nil {
-		statusWriteErrorHandler(ctx, w, r, err)
-		return
-	}
-
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -206,6 +211,8 @@ func paymentsChangedHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if errors.Is(err, paymentservice.DownstreamError) || errors.Is(err, mailservice.DownstreamError) {
 			statusChangeDownstreamError(ctx, w, r, err)
+		} else if errors.Is(err, attendeesrv.MixedCurrenciesError) {
+			statusChangeUnavailableErrorHandler(ctx, w, r, err)
 		} else {
 			statusWriteErrorHandler(ctx, w, r, err)
 		}
@@ -243,35 +250,44 @@ func statusChangeForbiddenErrorHandler(ctx context.Context, w http.ResponseWrite
 }
 
 func statusChangeUnavailableErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
-	message := "status.data.invalid"
+	message := unavailableMessage(err)
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("unavailable status change attempted: %s - %s", message, err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, message, http.StatusConflict, url.Values{"details": []string{err.Error()}})
+}
+
+func statusChangeDownstreamError(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("downstream error during status change: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, downstreamMessage(err), http.StatusBadGateway, url.Values{"details": []string{err.Error()}})
+}
+
+func unavailableMessage(err error) string {
 	if errors.Is(err, attendeesrv.SameStatusError) {
-		message = "status.unchanged.invalid"
+		return "status.unchanged.invalid"
 	} else if errors.Is(err, attendeesrv.InsufficientPaymentError) {
-		message = "status.unpaid.dues"
+		return "status.unpaid.dues"
 	} else if errors.Is(err, attendeesrv.HasPaymentBalanceError) {
-		message = "status.has.paid"
+		return "status.has.paid"
 	} else if errors.Is(err, attendeesrv.CannotDeleteError) {
-		message = "status.cannot.delete"
+		return "status.cannot.delete"
 	} else if errors.Is(err, attendeesrv.GoToApprovedFirst) {
-		message = "status.use.approved"
+		return "status.use.approved"
 	} else if errors.Is(err, attendeesrv.BanCandidateError) {
-		message = "status.ban.match"
+		return "status.ban.match"
 	} else if errors.Is(err, attendeesrv.IntroducesOverrun) {
-		message = "status.package.overrun"
+		return "status.package.overrun"
+	} else if errors.Is(err, attendeesrv.MixedCurrenciesError) {
+		return "status.currency.mismatch"
 	}
-	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("unavailable status change attempted: %s - %s", message, err.Error())
-	ctlutil.ErrorHandler(ctx, w, r, message, http.StatusConflict, url.Values{"details": []string{err.Error()}})
+	return "status.data.invalid"
 }
 
-func statusChangeDownstreamError(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
-	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("downstream error during status change: %s", err.Error())
-	message := "unknown"
+func downstreamMessage(err error) string {
 	if errors.Is(err, paymentservice.DownstreamError) {
-		message = "status.payment.error"
+		return "status.payment.error"
 	} else if errors.Is(err, mailservice.DownstreamError) {
-		message = "status.mail.error"
+		return "status.mail.error"
 	}
-	ctlutil.ErrorHandler(ctx, w, r, message, http.StatusBadGateway, url.Values{"details": []string{err.Error()}})
+	return "unknown"
 }
 
 // --- helpers ---
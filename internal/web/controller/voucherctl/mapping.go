@@ -0,0 +1,47 @@
+package voucherctl
+
+import (
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/vouchers"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+)
+
+func mapDtoToVoucher(dto *vouchers.VoucherDto, v *entity.Voucher) {
+	// code is taken from the path, uses are read only
+	v.Description = dto.Description
+	v.Kind = dto.Kind
+	v.Amount = dto.Amount
+	v.Currency = ""
+	if dto.Kind == entity.VoucherKindFixed {
+		v.Currency = dto.Currency
+		if v.Currency == "" {
+			v.Currency = config.Currency()
+		}
+	}
+	v.Package = dto.Package
+	v.MaxUses = dto.MaxUses
+	v.ValidUntil = dto.ValidUntil
+}
+
+func mapVoucherToDto(v *entity.Voucher, uses int, dto *vouchers.VoucherDto) {
+	dto.Code = v.Code
+	dto.Description = v.Description
+	dto.Kind = v.Kind
+	dto.Amount = v.Amount
+	dto.Currency = voucherCurrency(v)
+	dto.Package = v.Package
+	dto.MaxUses = v.MaxUses
+	dto.ValidUntil = v.ValidUntil
+	dto.Uses = uses
+}
+
+// voucherCurrency is the currency of a fixed amount, vouchers from before there was a choice are in the default currency.
+func voucherCurrency(v *entity.Voucher) string {
+	if v.Kind != entity.VoucherKindFixed {
+		return ""
+	}
+	if v.Currency == "" {
+		return config.Currency()
+	}
+	return v.Currency
+}
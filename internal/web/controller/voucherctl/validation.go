@@ -0,0 +1,60 @@
+package voucherctl
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/vouchers"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/validation"
+)
+
+func validate(ctx context.Context, dto *vouchers.VoucherDto, code string) url.Values {
+	errs := url.Values{}
+
+	if dto.Code != "" && strings.ToUpper(dto.Code) != code {
+		errs.Add("code", "code field must be empty or match the code in the path")
+	}
+	validation.CheckLength(&errs, 1, 256, "description", dto.Description)
+	switch dto.Kind {
+	case entity.VoucherKindPercent:
+		if dto.Amount < 1 || dto.Amount > 100 {
+			errs.Add("amount", "percentage must be between 1 and 100")
+		}
+		if dto.Currency != "" {
+			errs.Add("currency", "currency field must be empty for percentages")
+		}
+	case entity.VoucherKindFixed:
+		if dto.Amount < 1 {
+			errs.Add("amount", "fixed amount must be positive")
+		}
+		if dto.Currency != "" && validation.NotInAllowedValues(config.AllowedCurrencies(), dto.Currency) {
+			errs.Add("currency", "currency field must be empty or one of "+strings.Join(config.AllowedCurrencies(), ","))
+		}
+	default:
+		errs.Add("kind", "kind must be one of percent, fixed")
+	}
+	if dto.Package != "" {
+		if _, ok := config.PackagesConfig()[dto.Package]; !ok {
+			errs.Add("package", "package field must be empty or one of the configured packages")
+		}
+	}
+	if dto.MaxUses < 0 {
+		errs.Add("max_uses", "max_uses cannot be negative")
+	}
+	if dto.ValidUntil != "" && validation.InvalidISODate(dto.ValidUntil) {
+		errs.Add("valid_until", "valid_until field must be empty or an ISO date, as in 2024-06-01")
+	}
+
+	if len(errs) != 0 {
+		if config.LoggingSeverity() == "DEBUG" {
+			for key, val := range errs {
+				aulogging.Logger.Ctx(ctx).Debug().Printf("voucher dto validation error for key %s: %s", key, val)
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,347 @@
+package voucherctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/vouchers"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/eurofurence/reg-attendee-service/internal/web/filter"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-http-utils/headers"
+	"gorm.io/gorm"
+)
+
+var attendeeService attendeesrv.AttendeeService
+
+var voucherCodeRegexp = regexp.MustCompile("^[A-Z0-9_-]{3,32}$")
+
+func Create(server chi.Router, attendeeSrv attendeesrv.AttendeeService) {
+	attendeeService = attendeeSrv
+
+	server.Get("/api/rest/v1/vouchers", filter.HasGroupOrApiToken(config.OidcAdminGroup(), filter.WithTimeout(3*time.Second, allVouchersHandler)))
+	server.Get("/api/rest/v1/vouchers/{code}", filter.HasGroupOrApiToken(config.OidcAdminGroup(), filter.WithTimeout(3*time.Second, getVoucherHandler)))
+	server.Put("/api/rest/v1/vouchers/{code}", filter.HasGroupOrApiToken(config.OidcAdminGroup(), filter.WithTimeout(3*time.Second, writeVoucherHandler)))
+	server.Delete("/api/rest/v1/vouchers/{code}", filter.HasGroupOrApiToken(config.OidcAdminGroup(), filter.WithTimeout(3*time.Second, deleteVoucherHandler)))
+
+	server.Get("/api/rest/v1/attendees/{id}/vouchers", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, getRedeemedVouchersHandler)))
+	server.Post("/api/rest/v1/attendees/{id}/vouchers", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, redeemVoucherHandler)))
+	server.Delete("/api/rest/v1/attendees/{id}/vouchers/{code}", filter.HasGroupOrApiToken(config.OidcAdminGroup(), filter.WithTimeout(3*time.Second, removeRedemptionHandler)))
+}
+
+func allVouchersHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	all, err := attendeeService.GetAllVouchers(ctx)
+	if err != nil {
+		voucherReadErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	result := vouchers.VoucherList{
+		Vouchers: make([]vouchers.VoucherDto, len(all)),
+	}
+	for i, voucher := range all {
+		uses, err := attendeeService.CountVoucherUses(ctx, voucher)
+		if err != nil {
+			voucherReadErrorHandler(ctx, w, r, err)
+			return
+		}
+		mapVoucherToDto(voucher, uses, &result.Vouchers[i])
+	}
+
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, result)
+}
+
+func getVoucherHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	voucher, err := voucherMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	uses, err := attendeeService.CountVoucherUses(ctx, voucher)
+	if err != nil {
+		voucherReadErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	dto := vouchers.VoucherDto{}
+	mapVoucherToDto(voucher, uses, &dto)
+
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, dto)
+}
+
+func writeVoucherHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	code, err := voucherCodeFromVars(ctx, w, r)
+	if err != nil {
+		return
+	}
+	dto, err := parseBodyToVoucherDto(ctx, w, r)
+	if err != nil {
+		return
+	}
+	validationErrs := validate(ctx, dto, code)
+	if len(validationErrs) != 0 {
+		voucherValidationErrorHandler(ctx, w, r, validationErrs)
+		return
+	}
+
+	voucher, err := attendeeService.GetVoucher(ctx, code)
+	created := errors.Is(err, gorm.ErrRecordNotFound)
+	if created {
+		voucher = &entity.Voucher{Code: code}
+	} else if err != nil {
+		voucherReadErrorHandler(ctx, w, r, err)
+		return
+	}
+	mapDtoToVoucher(dto, voucher)
+
+	err = attendeeService.SaveVoucher(ctx, voucher)
+	if err != nil {
+		voucherWriteErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	if created {
+		aulogging.Logger.Ctx(ctx).Info().Printf("created voucher %s", code)
+		location := fmt.Sprintf("/api/rest/v1/vouchers/%s", code)
+		w.Header().Set(headers.Location, location)
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func deleteVoucherHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	voucher, err := voucherMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+
+	err = attendeeService.DeleteVoucher(ctx, voucher)
+	if err != nil {
+		voucherWriteErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func getRedeemedVouchersHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	attd, err := attendeeFromVarsMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	if err := filter.IsSubjectOrGroupOrApiToken(w, r, attd.Identity, config.OidcAdminGroup()); err != nil {
+		return
+	}
+
+	redeemed, err := attendeeService.GetRedeemedVouchers(ctx, attd.ID)
+	if err != nil {
+		voucherReadErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	result := vouchers.RedeemedVoucherList{
+		Vouchers: make([]vouchers.RedeemedVoucher, len(redeemed)),
+	}
+	for i, voucher := range redeemed {
+		result.Vouchers[i] = vouchers.RedeemedVoucher{
+			Code:        voucher.Code,
+			Description: voucher.Description,
+			Kind:        voucher.Kind,
+			Amount:      voucher.Amount,
+			Currency:    voucherCurrency(voucher),
+			Package:     voucher.Package,
+		}
+	}
+
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, result)
+}
+
+func redeemVoucherHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	attd, err := attendeeFromVarsMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	if err := filter.IsSubjectOrGroupOrApiToken(w, r, attd.Identity, config.OidcAdminGroup()); err != nil {
+		return
+	}
+	dto, err := parseBodyToVoucherRedeemDto(ctx, w, r)
+	if err != nil {
+		return
+	}
+	if dto.Code == "" {
+		voucherValidationErrorHandler(ctx, w, r, url.Values{"code": {"code field must not be empty"}})
+		return
+	}
+
+	err = attendeeService.RedeemVoucher(ctx, attd, dto.Code)
+	if err != nil {
+		voucherWriteErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func removeRedemptionHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	attd, err := attendeeFromVarsMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	voucher, err := voucherMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+
+	err = attendeeService.RemoveVoucherRedemption(ctx, attd, voucher)
+	if err != nil {
+		voucherWriteErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func attendeeFromVarsMustReturnOnError(ctx context.Context, w http.ResponseWriter, r *http.Request) (*entity.Attendee, error) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		ctlutil.InvalidAttendeeIdErrorHandler(ctx, w, r, url.QueryEscape(idStr))
+		return nil, err
+	}
+	attd, err := attendeeService.GetAttendee(ctx, uint(id))
+	if err != nil {
+		ctlutil.AttendeeNotFoundErrorHandler(ctx, w, r, uint(id))
+		return nil, err
+	}
+	return attd, nil
+}
+
+func voucherMustReturnOnError(ctx context.Context, w http.ResponseWriter, r *http.Request) (*entity.Voucher, error) {
+	code, err := voucherCodeFromVars(ctx, w, r)
+	if err != nil {
+		return nil, err
+	}
+
+	voucher, err := attendeeService.GetVoucher(ctx, code)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		voucherNotFoundErrorHandler(ctx, w, r, code)
+		return nil, err
+	} else if err != nil {
+		voucherReadErrorHandler(ctx, w, r, err)
+		return nil, err
+	}
+	return voucher, nil
+}
+
+// voucherCodeFromVars obtains the voucher code from the path. Codes are not case-sensitive.
+func voucherCodeFromVars(ctx context.Context, w http.ResponseWriter, r *http.Request) (string, error) {
+	code := strings.ToUpper(chi.URLParam(r, "code"))
+	if !voucherCodeRegexp.MatchString(code) {
+		voucherCodeInvalidErrorHandler(ctx, w, r, code)
+		return "", errors.New("invalid voucher code")
+	}
+	return code, nil
+}
+
+func parseBodyToVoucherDto(ctx context.Context, w http.ResponseWriter, r *http.Request) (*vouchers.VoucherDto, error) {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	dto := &vouchers.VoucherDto{}
+	err := decoder.Decode(dto)
+	if err != nil {
+		voucherParseErrorHandler(ctx, w, r, err)
+	}
+	return dto, err
+}
+
+func parseBodyToVoucherRedeemDto(ctx context.Context, w http.ResponseWriter, r *http.Request) (*vouchers.VoucherRedeem, error) {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	dto := &vouchers.VoucherRedeem{}
+	err := decoder.Decode(dto)
+	if err != nil {
+		voucherParseErrorHandler(ctx, w, r, err)
+	}
+	return dto, err
+}
+
+// --- error handlers ---
+
+func voucherCodeInvalidErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, code string) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("received invalid voucher code '%s'", url.QueryEscape(code))
+	ctlutil.ErrorHandler(ctx, w, r, "voucher.code.invalid", http.StatusBadRequest, url.Values{"code": {"must consist of letters, digits, - and _, and be 3 to 32 characters long"}})
+}
+
+func voucherNotFoundErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, code string) {
+	aulogging.Logger.Ctx(ctx).Info().Printf("voucher %s not found", code)
+	ctlutil.ErrorHandler(ctx, w, r, "voucher.notfound", http.StatusNotFound, url.Values{})
+}
+
+func voucherParseErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("voucher body could not be parsed: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "voucher.parse.error", http.StatusBadRequest, url.Values{})
+}
+
+func voucherValidationErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, errs url.Values) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("received voucher data with validation errors: %v", errs)
+	ctlutil.ErrorHandler(ctx, w, r, "voucher.data.invalid", http.StatusBadRequest, errs)
+}
+
+func voucherReadErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("voucher(s) could not be read: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "voucher.read.error", http.StatusInternalServerError, url.Values{})
+}
+
+func voucherWriteErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, attendeesrv.VoucherNotFoundError) {
+		aulogging.Logger.Ctx(ctx).Info().Printf("voucher not found: %s", err.Error())
+		ctlutil.ErrorHandler(ctx, w, r, "voucher.notfound", http.StatusNotFound, url.Values{})
+		return
+	}
+	conflicts := map[error]string{
+		attendeesrv.VoucherStatusError:          "voucher.status.invalid",
+		attendeesrv.VoucherExpiredError:         "voucher.expired",
+		attendeesrv.VoucherUsedUpError:          "voucher.used.up",
+		attendeesrv.VoucherAlreadyRedeemedError: "voucher.redemption.exists",
+		attendeesrv.VoucherNotApplicableError:   "voucher.package.missing",
+		attendeesrv.VoucherNotRedeemedError:     "voucher.redemption.missing",
+		attendeesrv.VoucherInUseError:           "voucher.in.use",
+		attendeesrv.VoucherCurrencyError:        "voucher.currency.mismatch",
+	}
+	for conflict, message := range conflicts {
+		if errors.Is(err, conflict) {
+			aulogging.Logger.Ctx(ctx).Warn().Printf("voucher change not possible: %s", err.Error())
+			ctlutil.ErrorHandler(ctx, w, r, message, http.StatusConflict, url.Values{"details": {err.Error()}})
+			return
+		}
+	}
+	aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("voucher could not be written: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "voucher.write.error", http.StatusInternalServerError, url.Values{})
+}
@@ -0,0 +1,109 @@
+package webhookctl
+
+import (
+	"context"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/webhook"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/eurofurence/reg-attendee-service/internal/web/filter"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-http-utils/headers"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+var attendeeService attendeesrv.AttendeeService
+
+func Create(server chi.Router, attendeeSrv attendeesrv.AttendeeService) {
+	attendeeService = attendeeSrv
+
+	server.Get("/api/rest/v1/webhooks/deliveries", filter.HasGroupOrApiToken(config.OidcAdminGroup(), filter.WithTimeout(3*time.Second, listDeliveriesHandler)))
+}
+
+func listDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	subscriber := query.Get("subscriber")
+	if subscriber != "" {
+		if _, ok := config.WebhooksConfig()[subscriber]; !ok {
+			webhookParamInvalidErrorHandler(ctx, w, r, "subscriber", subscriber, "no such webhook subscriber")
+			return
+		}
+	}
+
+	state := query.Get("state")
+	if state != "" && state != entity.WebhookStatePending && state != entity.WebhookStateSent && state != entity.WebhookStateFailed {
+		webhookParamInvalidErrorHandler(ctx, w, r, "state", state, "must be one of pending, sent, failed")
+		return
+	}
+
+	var attendeeId uint
+	if attendeeIdStr := query.Get("attendee_id"); attendeeIdStr != "" {
+		id, err := strconv.ParseUint(attendeeIdStr, 10, 32)
+		if err != nil || id == 0 {
+			webhookParamInvalidErrorHandler(ctx, w, r, "attendee_id", attendeeIdStr, "must be a positive integer")
+			return
+		}
+		attendeeId = uint(id)
+	}
+
+	limit := defaultLimit
+	if limitStr := query.Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l < 1 || l > maxLimit {
+			webhookParamInvalidErrorHandler(ctx, w, r, "limit", limitStr, "must be an integer between 1 and 1000")
+			return
+		}
+		limit = l
+	}
+
+	deliveries, err := attendeeService.GetWebhookDeliveries(ctx, subscriber, state, attendeeId, limit)
+	if err != nil {
+		webhookReadErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	response := webhook.DeliveryList{
+		Deliveries: make([]webhook.Delivery, len(deliveries)),
+	}
+	for i, d := range deliveries {
+		mapDeliveryToDto(d, &response.Deliveries[i])
+	}
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, response)
+}
+
+func mapDeliveryToDto(delivery *entity.WebhookDelivery, dto *webhook.Delivery) {
+	dto.Id = delivery.ID
+	dto.Subscriber = delivery.Subscriber
+	dto.Event = delivery.Event
+	dto.AttendeeId = delivery.AttendeeId
+	dto.State = delivery.State
+	dto.Attempts = delivery.Attempts
+	dto.NextAttempt = delivery.NextAttempt.Format(time.RFC3339)
+	dto.LastStatus = delivery.LastStatus
+	dto.LastError = delivery.LastError
+	dto.Created = delivery.CreatedAt.Format(time.RFC3339)
+}
+
+func webhookParamInvalidErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, param string, value string, details string) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("received invalid webhook delivery log parameter %s='%s'", param, url.QueryEscape(value))
+	ctlutil.ErrorHandler(ctx, w, r, "webhook.param.invalid", http.StatusBadRequest, url.Values{param: {details}})
+}
+
+func webhookReadErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("webhook deliveries could not be read: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "webhook.read.error", http.StatusInternalServerError, url.Values{})
+}
@@ -1,6 +1,10 @@
 package media
 
 const ContentTypeApplicationJson = "application/json"
+const ContentTypeApplicationNdjson = "application/x-ndjson"
 const ContentTypeTextPlain = "text/plain; charset=utf-8"
+const ContentTypeTextCsv = "text/csv; charset=utf-8"
+const ContentTypeApplicationPdf = "application/pdf"
+const ContentTypeXlsx = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
 
 const HeaderXApiKey = "X-Api-Key"
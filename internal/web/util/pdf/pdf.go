@@ -0,0 +1,151 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// A4 page size in points.
+const (
+	PageWidth  = 595.0
+	PageHeight = 842.0
+)
+
+// Document is a minimal PDF document with text and lines, using the standard Helvetica fonts,
+// so no fonts need to be embedded.
+//
+// Coordinates are in points, with the origin in the bottom left corner of the page. Text can use
+// the characters of Windows-1252, anything else is replaced by '?'.
+type Document struct {
+	pages []*bytes.Buffer
+}
+
+// New returns a Document with a single empty page.
+func New() *Document {
+	d := &Document{}
+	d.AddPage()
+	return d
+}
+
+// AddPage starts a new page. All further drawing goes to this page.
+func (d *Document) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+// Text draws text with its baseline starting at x, y.
+func (d *Document) Text(x float64, y float64, size float64, bold bool, text string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.current(), "BT /%s %s Tf %s %s Td (%s) Tj ET\n", font, num(size), num(x), num(y), escape(winAnsi(text)))
+}
+
+// TextRight draws text such that it ends at x.
+func (d *Document) TextRight(x float64, y float64, size float64, bold bool, text string) {
+	d.Text(x-TextWidth(text, size), y, size, bold, text)
+}
+
+// Line draws a thin line from x1, y1 to x2, y2.
+func (d *Document) Line(x1 float64, y1 float64, x2 float64, y2 float64) {
+	fmt.Fprintf(d.current(), "0.5 w %s %s m %s %s l S\n", num(x1), num(y1), num(x2), num(y2))
+}
+
+// TextWidth is the width of text at the given font size.
+//
+// Always measures in regular Helvetica. Bold text is slightly wider, except for digits, which have
+// the same width in both, so amounts line up either way.
+func TextWidth(text string, size float64) float64 {
+	units := 0
+	for _, b := range winAnsi(text) {
+		if b >= 32 && b <= 126 {
+			units += helveticaWidths[b-32]
+		} else {
+			units += 556
+		}
+	}
+	return float64(units) * size / 1000
+}
+
+// WriteTo writes the complete document to w.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	out := &bytes.Buffer{}
+	offsets := make([]int, 0)
+	object := func(content string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(out, "%d 0 obj\n%s\nendobj\n", len(offsets), content)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	kids := &bytes.Buffer{}
+	for i := range d.pages {
+		fmt.Fprintf(kids, " %d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s ] /Count %d >>", kids.String(), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			num(PageWidth), num(PageHeight), 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", page.Len(), page.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.WriteTo(w)
+}
+
+func (d *Document) current() *bytes.Buffer {
+	return d.pages[len(d.pages)-1]
+}
+
+func num(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// winAnsi converts text to Windows-1252, as needed for the standard fonts.
+func winAnsi(text string) []byte {
+	result := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch {
+		case r >= 32 && r <= 126 || r >= 0xA0 && r <= 0xFF:
+			result = append(result, byte(r))
+		case r == '€':
+			result = append(result, 0x80)
+		default:
+			result = append(result, '?')
+		}
+	}
+	return result
+}
+
+// escape makes encoded text safe for use in a string literal.
+func escape(encoded []byte) []byte {
+	result := make([]byte, 0, len(encoded))
+	for _, b := range encoded {
+		if b == '(' || b == ')' || b == '\\' {
+			result = append(result, '\\')
+		}
+		result = append(result, b)
+	}
+	return result
+}
+
+// helveticaWidths are the widths of the printable ascii characters in Helvetica, in 1/1000 of the font size.
+var helveticaWidths = [...]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space to /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556, // 0 to ?
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778, // @ to O
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556, // P to _
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556, // ` to o
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584, // p to ~
+}
@@ -0,0 +1,51 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocument(t *testing.T) {
+	docs.Description("the pdf writer produces a document with a valid cross reference table")
+	d := New()
+	d.Text(50, 800, 12, true, "Invoice (copy)")
+	d.Line(50, 790, 545, 790)
+	d.AddPage()
+	d.TextRight(545, 800, 10, false, "12.50 €")
+
+	buf := bytes.Buffer{}
+	_, err := d.WriteTo(&buf)
+	require.NoError(t, err)
+	out := buf.String()
+
+	require.True(t, strings.HasPrefix(out, "%PDF-1.4\n"))
+	require.True(t, strings.HasSuffix(out, "%%EOF\n"))
+	require.Contains(t, out, "/Kids [ 5 0 R 7 0 R ] /Count 2")
+	require.Contains(t, out, "BT /F2 12 Tf 50 800 Td (Invoice \\(copy\\)) Tj ET\n")
+	require.Contains(t, out, "0.5 w 50 790 m 545 790 l S\n")
+	require.Contains(t, out, "Td (12.50 \x80) Tj ET\n")
+
+	startxref, err := strconv.Atoi(regexp.MustCompile(`startxref\n(\d+)\n`).FindStringSubmatch(out)[1])
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(out[startxref:], "xref\n0 9\n"))
+	offsets := regexp.MustCompile(`(\d{10}) 00000 n \n`).FindAllStringSubmatch(out[startxref:], -1)
+	require.Len(t, offsets, 8)
+	for i, match := range offsets {
+		offset, _ := strconv.Atoi(match[1])
+		require.True(t, strings.HasPrefix(out[offset:], fmt.Sprintf("%d 0 obj\n", i+1)))
+	}
+}
+
+func TestTextWidth(t *testing.T) {
+	docs.Description("text width is measured in Helvetica, so text can be right aligned")
+	require.InDelta(t, 5.56, TextWidth("0", 10), 0.001)
+	require.InDelta(t, 17.76, TextWidth("(i)", 20), 0.001)
+	require.InDelta(t, 5.56, TextWidth("€", 10), 0.001)
+}
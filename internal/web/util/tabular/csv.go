@@ -0,0 +1,52 @@
+package tabular
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+// NewCsvWriter returns a Writer for comma separated values.
+//
+// Text that spreadsheet programs would interpret as a formula is prefixed with a single quote, so
+// user supplied values cannot inject formulas.
+func NewCsvWriter(w io.Writer) Writer {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) WriteRow(cells []any) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		switch v := cell.(type) {
+		case nil:
+			record[i] = ""
+		case string:
+			record[i] = escapeFormula(v)
+		default:
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	if err := c.w.Write(record); err != nil {
+		return err
+	}
+	// rows are streamed to the client as they are written
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func escapeFormula(v string) string {
+	if v != "" && strings.ContainsAny(v[:1], "=+-@\t\r") {
+		return "'" + v
+	}
+	return v
+}
@@ -0,0 +1,11 @@
+// Package tabular writes rows of cells as spreadsheet files, one row at a time, so large exports
+// need not be kept in memory.
+package tabular
+
+// Writer writes rows of cells. Cells may be strings, int64 numbers, or nil for an empty cell.
+type Writer interface {
+	WriteRow(cells []any) error
+
+	// Close completes the file. It does not close the underlying io.Writer.
+	Close() error
+}
@@ -0,0 +1,60 @@
+package tabular
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCsvWriter(t *testing.T) {
+	docs.Description("the csv writer quotes as needed and prevents formula injection")
+	buf := bytes.Buffer{}
+	w := NewCsvWriter(&buf)
+	require.NoError(t, w.WriteRow([]any{"id", "nickname", "flags"}))
+	require.NoError(t, w.WriteRow([]any{int64(12), "=HYPERLINK(\"x\")", "anon,hc"}))
+	require.NoError(t, w.WriteRow([]any{int64(-3), nil, "@home"}))
+	require.NoError(t, w.Close())
+	require.Equal(t, "id,nickname,flags\n12,\"'=HYPERLINK(\"\"x\"\")\",\"anon,hc\"\n-3,,'@home\n", buf.String())
+}
+
+func TestXlsxWriter(t *testing.T) {
+	docs.Description("the xlsx writer produces a workbook with a single sheet")
+	buf := bytes.Buffer{}
+	w, err := NewXlsxWriter(&buf, "Attendees")
+	require.NoError(t, err)
+	require.NoError(t, w.WriteRow([]any{"id", "nickname"}))
+	require.NoError(t, w.WriteRow([]any{int64(12), "<Tom & Jerry>", nil, "x"}))
+	require.NoError(t, w.Close())
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	names := make([]string, 0)
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+	}
+	require.Equal(t, []string{"[Content_Types].xml", "_rels/.rels", "xl/_rels/workbook.xml.rels", "xl/workbook.xml", "xl/worksheets/sheet1.xml"}, names)
+
+	sheetFile, err := archive.Open("xl/worksheets/sheet1.xml")
+	require.NoError(t, err)
+	sheet, err := io.ReadAll(sheetFile)
+	require.NoError(t, err)
+	require.Contains(t, string(sheet), `<sheetData><row r="1"><c r="A1" t="inlineStr"><is><t xml:space="preserve">id</t></is></c>`+
+		`<c r="B1" t="inlineStr"><is><t xml:space="preserve">nickname</t></is></c></row>`+
+		`<row r="2"><c r="A2"><v>12</v></c><c r="B2" t="inlineStr"><is><t xml:space="preserve">&lt;Tom &amp; Jerry&gt;</t></is></c>`+
+		`<c r="D2" t="inlineStr"><is><t xml:space="preserve">x</t></is></c></row></sheetData></worksheet>`)
+}
+
+func TestColumnName(t *testing.T) {
+	docs.Description("spreadsheet column names are computed correctly")
+	require.Equal(t, "A", columnName(0))
+	require.Equal(t, "Z", columnName(25))
+	require.Equal(t, "AA", columnName(26))
+	require.Equal(t, "AZ", columnName(51))
+	require.Equal(t, "BA", columnName(52))
+	require.Equal(t, "ZZ", columnName(701))
+	require.Equal(t, "AAA", columnName(702))
+}
@@ -0,0 +1,119 @@
+package tabular
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const xlsxHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+var xlsxStaticParts = []struct {
+	name    string
+	content string
+}{
+	{"[Content_Types].xml", xlsxHeader + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", xlsxHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/_rels/workbook.xml.rels", xlsxHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+type xlsxWriter struct {
+	zip   *zip.Writer
+	sheet io.Writer
+	row   int
+}
+
+// NewXlsxWriter returns a Writer for an Office Open XML workbook with a single sheet of the given name.
+//
+// The sheet is written as rows arrive, using inline strings, so nothing but the current row
+// is kept in memory.
+func NewXlsxWriter(w io.Writer, sheetName string) (Writer, error) {
+	z := zip.NewWriter(w)
+	for _, part := range xlsxStaticParts {
+		if err := writeZipPart(z, part.name, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	workbook := xlsxHeader + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="` + escapeXml(sheetName) + `" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	if err := writeZipPart(z, "xl/workbook.xml", workbook); err != nil {
+		return nil, err
+	}
+
+	sheet, err := z.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.WriteString(sheet, xlsxHeader+`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	if err != nil {
+		return nil, err
+	}
+	return &xlsxWriter{zip: z, sheet: sheet}, nil
+}
+
+func (x *xlsxWriter) WriteRow(cells []any) error {
+	x.row++
+	buf := bytes.Buffer{}
+	fmt.Fprintf(&buf, `<row r="%d">`, x.row)
+	for i, cell := range cells {
+		ref := columnName(i) + strconv.Itoa(x.row)
+		switch v := cell.(type) {
+		case nil:
+			// empty cells are simply left out
+		case string:
+			fmt.Fprintf(&buf, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, escapeXml(v))
+		default:
+			fmt.Fprintf(&buf, `<c r="%s"><v>%v</v></c>`, ref, v)
+		}
+	}
+	buf.WriteString(`</row>`)
+	_, err := x.sheet.Write(buf.Bytes())
+	return err
+}
+
+func (x *xlsxWriter) Close() error {
+	if _, err := io.WriteString(x.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return x.zip.Close()
+}
+
+func writeZipPart(z *zip.Writer, name string, content string) error {
+	part, err := z.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(part, content)
+	return err
+}
+
+func escapeXml(v string) string {
+	buf := bytes.Buffer{}
+	// replaces characters that are not allowed in xml documents
+	_ = xml.EscapeText(&buf, []byte(v))
+	return buf.String()
+}
+
+// columnName returns the spreadsheet column name for a zero based index, e.g. 0 is A, 26 is AA.
+func columnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
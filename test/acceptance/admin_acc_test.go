@@ -688,6 +688,7 @@ func TestAdminWrite_ManualDuesPositive_AfterApprove(t *testing.T) {
 	mail2 := tstNewStatusMail(testcase, status.Approved, false)
 	mail2.Variables["total_dues"] = "EUR 335.00"
 	mail2.Variables["remaining_dues"] = "EUR 335.00"
+	mail2.Variables["installment_due"] = "EUR 335.00"
 	docs.Then("and the expected email messages were sent via the mail service")
 	tstRequireMailRequests(t, []mailservice.MailSendDto{
 		mail1,
@@ -921,6 +922,7 @@ func TestAdminWrite_ManualDuesNegativePartial_AfterApprove(t *testing.T) {
 	mail2 := tstNewStatusMail(testcase, status.Approved, false)
 	mail2.Variables["total_dues"] = "EUR 175.00"
 	mail2.Variables["remaining_dues"] = "EUR 175.00"
+	mail2.Variables["installment_due"] = "EUR 175.00"
 	tstRequireMailRequests(t, []mailservice.MailSendDto{
 		mail1,
 		mail2,
@@ -1120,6 +1122,7 @@ func TestSearch_RegdeskOk(t *testing.T) {
         }
       ],
       "status": "approved",
+      "currency": "EUR",
       "total_dues": 25500,
       "payment_balance": 0,
       "current_dues": 25500,
@@ -1194,6 +1197,7 @@ func TestSearch_SponsordeskOk(t *testing.T) {
         }
       ],
       "status": "paid",
+      "currency": "EUR",
       "total_dues": 25500,
       "payment_balance": 25500,
       "current_dues": 0,
@@ -1397,6 +1401,7 @@ func TestSearch_AdminOk(t *testing.T) {
         }
       ],
       "status": "new",
+      "currency": "EUR",
       "total_dues": 0,
       "payment_balance": 0,
       "current_dues": 0,
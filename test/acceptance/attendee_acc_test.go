@@ -89,6 +89,60 @@ func TestCreateNewAttendeeInvalidPackagesList(t *testing.T) {
 	})
 }
 
+func TestCreateNewAttendee_ExpressionConstraintSatisfied(t *testing.T) {
+	docs.Given("given the configuration for public standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an unauthenticated user")
+
+	docs.When("when they create a new attendee with an option whose constraint refers to their age and packages, and satisfy it")
+	attendeeSent := tstBuildValidAttendee("nac1-")
+	attendeeSent.Options = "junior,music"
+	response := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(attendeeSent), tstNoToken())
+
+	docs.Then("then the attendee is successfully created")
+	require.Equal(t, http.StatusCreated, response.status, "unexpected http response status")
+}
+
+func TestCreateNewAttendeeInvalid_AgeConstraint(t *testing.T) {
+	docs.Given("given the configuration for public standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an unauthenticated user")
+
+	docs.When("when they create a new attendee with an option that requires them to be younger than they are")
+	attendeeSent := tstBuildValidAttendee("nac2-")
+	attendeeSent.Options = "junior"
+	attendeeSent.Birthday = "1996-08-16" // turns 27 on the reference date
+	response := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(attendeeSent), tstNoToken())
+
+	docs.Then("then the attendee is rejected with the configured constraint message")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "attendee.data.invalid", url.Values{
+		"options": []string{"cannot pick junior: The junior meetup is for full attendees under 27. - constraint violated"},
+	})
+}
+
+func TestCreateNewAttendeeInvalid_CrossCategoryConstraint(t *testing.T) {
+	docs.Given("given the configuration for public standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an unauthenticated user")
+
+	docs.When("when they create a new day guest attendee with an option that requires the convention ticket package")
+	attendeeSent := tstBuildValidAttendee("nac3-")
+	tstOverridePackages(&attendeeSent, "room-none,day-sat,sponsor2")
+	attendeeSent.Options = "junior"
+	response := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(attendeeSent), tstNoToken())
+
+	docs.Then("then the attendee is rejected with the configured constraint message")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "attendee.data.invalid", url.Values{
+		"options": []string{"cannot pick junior: The junior meetup is for full attendees under 27. - constraint violated"},
+	})
+}
+
 func TestCreateNewAttendeeInvalid_NoMandatoryPackage(t *testing.T) {
 	docs.Given("given the configuration for public standard registration")
 	tstSetup(false, false, true)
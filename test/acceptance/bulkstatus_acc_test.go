@@ -0,0 +1,302 @@
+package acceptance
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/bulkstatus"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/counts"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
+	"github.com/stretchr/testify/require"
+)
+
+// -----------------------------------------------
+// acceptance tests for the bulk status change api
+// -----------------------------------------------
+
+const tstBulkStatusUrl = "/api/rest/v1/status/bulk"
+
+func TestBulkStatus_DryRun(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given two new attendees, one of whom matches a ban rule")
+	ban := tstBuildValidBanRule("bulk1-")
+	ban.NicknamePattern = "^banned"
+	banResponse := tstPerformPost("/api/rest/v1/bans", tstRenderJson(ban), tstValidAdminToken(t))
+	require.Equal(t, http.StatusCreated, banResponse.status)
+	loc1, att1 := tstBulkRegister(t, "bulk1a-", tstValidUserToken(t, 101), "")
+	loc2, att2 := tstBulkRegister(t, "bulk1b-", tstValidUserToken(t, 102), "BannedCheetah")
+
+	docs.When("when an admin requests a dry run for approving both")
+	response := tstPerformPost(tstBulkStatusUrl, tstRenderJson(bulkstatus.BulkStatusChangeDto{
+		Ids:     []uint{att1.Id, att2.Id},
+		Status:  status.Approved,
+		Comment: "bulk1",
+		DryRun:  true,
+	}), tstValidAdminToken(t))
+
+	docs.Then("then the request is successful and reports which status changes would fail")
+	require.Equal(t, http.StatusOK, response.status)
+	actual := bulkstatus.BulkStatusChangeResultList{}
+	tstParseJson(response.body, &actual)
+	require.Equal(t, bulkstatus.BulkStatusChangeResultList{
+		DryRun:    true,
+		Succeeded: 1,
+		Failed:    1,
+		Results: []bulkstatus.BulkStatusChangeResult{
+			{Id: att1.Id, OldStatus: status.New, NewStatus: status.Approved, Success: true},
+			{Id: att2.Id, OldStatus: status.New, Error: "status.ban.match", Details: "this attendee matches a ban rule and cannot be approved, please review and either cancel or set the skip_ban_check admin flag to allow approval"},
+		},
+	}, actual)
+
+	docs.Then("and nothing has been changed")
+	tstVerifyStatus(t, loc1, status.New)
+	tstVerifyStatus(t, loc2, status.New)
+	require.Empty(t, paymentMock.Recording())
+	require.Empty(t, mailMock.Recording())
+}
+
+func TestBulkStatus_Success(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given three new attendees")
+	loc1, att1 := tstBulkRegister(t, "bulk2a-", tstValidUserToken(t, 101), "")
+	loc2, att2 := tstBulkRegister(t, "bulk2b-", tstValidUserToken(t, 102), "")
+	loc3, att3 := tstBulkRegister(t, "bulk2c-", tstValidStaffToken(t, 202), "")
+
+	docs.When("when an admin approves them in bulk, together with an id that does not exist")
+	response := tstPerformPost(tstBulkStatusUrl, tstRenderJson(bulkstatus.BulkStatusChangeDto{
+		Ids:     []uint{att1.Id, att2.Id, 789, att3.Id},
+		Status:  status.Approved,
+		Comment: "bulk2",
+	}), tstValidAdminToken(t))
+
+	docs.Then("then the request is successful and reports the outcome for each id, in order")
+	require.Equal(t, http.StatusOK, response.status)
+	actual := bulkstatus.BulkStatusChangeResultList{}
+	tstParseJson(response.body, &actual)
+	require.False(t, actual.DryRun)
+	require.Equal(t, 3, actual.Succeeded)
+	require.Equal(t, 1, actual.Failed)
+	require.Equal(t, 4, len(actual.Results))
+	for _, i := range []int{0, 1, 3} {
+		require.Equal(t, bulkstatus.BulkStatusChangeResult{
+			Id:        []uint{att1.Id, att2.Id, 789, att3.Id}[i],
+			OldStatus: status.New,
+			NewStatus: status.Approved,
+			Success:   true,
+		}, actual.Results[i])
+	}
+	require.Equal(t, uint(789), actual.Results[2].Id)
+	require.Equal(t, "attendee.id.notfound", actual.Results[2].Error)
+
+	docs.Then("and the attendees have been approved, with dues booked for each")
+	tstVerifyStatus(t, loc1, status.Approved)
+	tstVerifyStatus(t, loc2, status.Approved)
+	tstVerifyStatus(t, loc3, status.Approved)
+	require.Equal(t, 3, len(paymentMock.Recording()))
+	for _, transaction := range paymentMock.Recording() {
+		require.Equal(t, paymentservice.Due, transaction.TransactionType)
+	}
+	require.Equal(t, 3, len(mailMock.Recording()))
+}
+
+func TestBulkStatus_Criteria(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given two new attendees and one who is already approved")
+	loc1, _ := tstBulkRegister(t, "bulk3a-", tstValidUserToken(t, 101), "")
+	loc2, _ := tstBulkRegister(t, "bulk3b-", tstValidUserToken(t, 102), "")
+	loc3, _ := tstRegisterAttendeeAndTransitionToStatus(t, "bulk3c-", status.Approved)
+
+	docs.When("when an admin approves all new attendees in bulk, using search criteria")
+	response := tstPerformPost(tstBulkStatusUrl, tstRenderJson(bulkstatus.BulkStatusChangeDto{
+		Criteria: &attendee.AttendeeSearchCriteria{
+			MatchAny: []attendee.AttendeeSearchSingleCriterion{{Status: []status.Status{status.New}}},
+		},
+		Status:  status.Approved,
+		Comment: "bulk3",
+	}), tstValidAdminToken(t))
+
+	docs.Then("then the request is successful and only the matching attendees have been approved")
+	require.Equal(t, http.StatusOK, response.status)
+	actual := bulkstatus.BulkStatusChangeResultList{}
+	tstParseJson(response.body, &actual)
+	require.Equal(t, 2, actual.Succeeded)
+	require.Equal(t, 0, actual.Failed)
+	tstVerifyStatus(t, loc1, status.Approved)
+	tstVerifyStatus(t, loc2, status.Approved)
+	tstVerifyStatus(t, loc3, status.Approved)
+}
+
+func TestBulkStatus_Overrun(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given two cancelled attendees with a counted package of which only one is left")
+	loc1, att1 := tstPkgStatRegisterAndProgressWithPackages(t, "bulk4a-", status.Cancelled, "mountain-trip", 1)
+	loc2, att2 := tstPkgStatRegisterAndProgressWithPackages(t, "bulk4b-", status.Cancelled, "mountain-trip", 202)
+	err := database.GetRepository().ResetCount(context.TODO(), &entity.Count{
+		Area:      entity.CountAreaPackage,
+		Name:      "mountain-trip",
+		Attending: 3,
+	})
+	require.NoError(t, err)
+
+	docs.When("when an admin approves both in bulk")
+	response := tstPerformPost(tstBulkStatusUrl, tstRenderJson(bulkstatus.BulkStatusChangeDto{
+		Ids:     []uint{att1.Id, att2.Id},
+		Status:  status.Approved,
+		Comment: "bulk4",
+	}), tstValidAdminToken(t))
+
+	docs.Then("then the request is successful, but only one of them could be approved")
+	require.Equal(t, http.StatusOK, response.status)
+	actual := bulkstatus.BulkStatusChangeResultList{}
+	tstParseJson(response.body, &actual)
+	require.Equal(t, 1, actual.Succeeded)
+	require.Equal(t, 1, actual.Failed)
+	for _, result := range actual.Results {
+		if !result.Success {
+			require.Equal(t, "status.package.overrun", result.Error)
+		}
+	}
+	tstRequirePackageCount(t, "mountain-trip", counts.PackageCount{Attending: 4, Limit: 4})
+
+	docs.Then("and the other one is still cancelled")
+	statuses := []status.Status{tstReadStatus(t, loc1), tstReadStatus(t, loc2)}
+	require.ElementsMatch(t, []status.Status{status.Approved, status.Cancelled}, statuses)
+}
+
+func TestBulkStatus_DryRunOverrun(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given two cancelled attendees with a counted package of which only one is left")
+	loc1, att1 := tstPkgStatRegisterAndProgressWithPackages(t, "bulk8a-", status.Cancelled, "mountain-trip", 1)
+	loc2, att2 := tstPkgStatRegisterAndProgressWithPackages(t, "bulk8b-", status.Cancelled, "mountain-trip", 202)
+	err := database.GetRepository().ResetCount(context.TODO(), &entity.Count{
+		Area:      entity.CountAreaPackage,
+		Name:      "mountain-trip",
+		Attending: 3,
+	})
+	require.NoError(t, err)
+
+	docs.When("when an admin requests a dry run for approving both")
+	response := tstPerformPost(tstBulkStatusUrl, tstRenderJson(bulkstatus.BulkStatusChangeDto{
+		Ids:     []uint{att1.Id, att2.Id},
+		Status:  status.Approved,
+		Comment: "bulk8",
+		DryRun:  true,
+	}), tstValidAdminToken(t))
+
+	docs.Then("then the request is successful, and reports that only one of them could be approved")
+	require.Equal(t, http.StatusOK, response.status)
+	actual := bulkstatus.BulkStatusChangeResultList{}
+	tstParseJson(response.body, &actual)
+	require.Equal(t, 1, actual.Succeeded)
+	require.Equal(t, 1, actual.Failed)
+	for _, result := range actual.Results {
+		if !result.Success {
+			require.Equal(t, "status.package.overrun", result.Error)
+		}
+	}
+
+	docs.Then("and nothing has been changed")
+	tstRequirePackageCount(t, "mountain-trip", counts.PackageCount{Attending: 3, Limit: 4})
+	tstVerifyStatus(t, loc1, status.Cancelled)
+	tstVerifyStatus(t, loc2, status.Cancelled)
+}
+
+func TestBulkStatus_InvalidRequest(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.When("when an admin sends a bulk status change without ids or criteria and with an invalid status")
+	response := tstPerformPost(tstBulkStatusUrl, tstRenderJson(bulkstatus.BulkStatusChangeDto{
+		Status:  "happy",
+		Comment: "bulk5",
+	}), tstValidAdminToken(t))
+
+	docs.Then("then the request fails with the correct error")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "status.data.invalid", url.Values{
+		"ids":    {"must specify either ids or criteria"},
+		"status": {"status must be one of new,approved,partially paid,paid,checked in,waiting,cancelled,deleted"},
+	})
+}
+
+func TestBulkStatus_DuplicateIds(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee")
+	loc, att := tstRegisterAttendee(t, "bulk6-")
+
+	docs.When("when an admin sends a bulk status change that lists the attendee twice")
+	response := tstPerformPost(tstBulkStatusUrl, tstRenderJson(bulkstatus.BulkStatusChangeDto{
+		Ids:     []uint{att.Id, att.Id},
+		Status:  status.Approved,
+		Comment: "bulk6",
+	}), tstValidAdminToken(t))
+
+	docs.Then("then the request fails with the correct error and nothing is changed")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "status.data.invalid", url.Values{
+		"ids": {fmt.Sprintf("duplicate id %d", att.Id)},
+	})
+	tstVerifyStatus(t, loc, status.New)
+}
+
+func TestBulkStatus_DenyUser(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee")
+	token := tstValidUserToken(t, 101)
+	loc, att := tstRegisterAttendeeWithToken(t, "bulk7-", token)
+
+	docs.When("when they attempt a bulk status change")
+	response := tstPerformPost(tstBulkStatusUrl, tstRenderJson(bulkstatus.BulkStatusChangeDto{
+		Ids:     []uint{att.Id},
+		Status:  status.Cancelled,
+		Comment: "bulk7",
+	}), token)
+
+	docs.Then("then the request is denied as unauthorized (403) and the correct error is returned")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+	tstVerifyStatus(t, loc, status.New)
+}
+
+// --- helpers ---
+
+func tstBulkRegister(t *testing.T, testcase string, token string, nickname string) (string, attendee.AttendeeDto) {
+	dto := tstBuildValidAttendee(testcase)
+	if nickname != "" {
+		dto.Nickname = nickname
+	}
+	creationResponse := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(dto), token)
+	require.Equal(t, http.StatusCreated, creationResponse.status, "unexpected http response status")
+
+	rereadResponse := tstPerformGet(creationResponse.location, token)
+	require.Equal(t, http.StatusOK, rereadResponse.status, "unexpected http response status")
+	tstParseJson(rereadResponse.body, &dto)
+	return creationResponse.location, dto
+}
+
+func tstReadStatus(t *testing.T, loc string) status.Status {
+	response := tstPerformGet(loc+"/status", tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, response.status)
+	statusDto := status.StatusDto{}
+	tstParseJson(response.body, &statusDto)
+	return statusDto.Status
+}
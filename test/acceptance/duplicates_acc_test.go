@@ -0,0 +1,289 @@
+package acceptance
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/admin"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/duplicates"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
+	"github.com/stretchr/testify/require"
+)
+
+// ----------------------------------------------------------
+// acceptance tests for duplicate detection and merging
+// ----------------------------------------------------------
+
+const tstDuplicatesUrl = "/api/rest/v1/attendees/duplicates"
+
+func TestDuplicates_Scan(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given two registrations with the same name, birthday and phone, and a different one")
+	_, att1 := tstBulkRegister(t, "dupl1a-", tstValidUserToken(t, 101), "")
+	_, att2 := tstBulkRegister(t, "dupl1b-", tstValidUserToken(t, 102), "OtherCheetah")
+	other := tstBuildValidAttendee("dupl1c-")
+	other.FirstName = "Jana"
+	other.LastName = "Schulz"
+	other.Birthday = "1990-01-01"
+	other.Phone = "+1 555 1234 567"
+	response := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(other), tstValidStaffToken(t, 202))
+	require.Equal(t, http.StatusCreated, response.status)
+
+	docs.When("when an admin scans for duplicates")
+	response = tstPerformGet(tstDuplicatesUrl, tstValidAdminToken(t))
+
+	docs.Then("then only the two similar registrations are reported, with the reasons and a high score")
+	require.Equal(t, http.StatusOK, response.status)
+	actual := duplicates.DuplicateList{}
+	tstParseJson(response.body, &actual)
+	require.Equal(t, duplicates.DuplicateList{
+		Duplicates: []duplicates.Duplicate{
+			{
+				Ids:     []uint{att1.Id, att2.Id},
+				Score:   0.97,
+				Reasons: []string{duplicates.ReasonSamePhone, duplicates.ReasonSimilarNameAndBirthday},
+			},
+		},
+	}, actual)
+}
+
+func TestDuplicates_MinScore(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given two registrations that only share the phone number")
+	_, _ = tstBulkRegister(t, "dupl2a-", tstValidUserToken(t, 101), "")
+	second := tstBuildValidAttendee("dupl2b-")
+	second.FirstName = "Jana"
+	second.LastName = "Schulz"
+	response := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(second), tstValidUserToken(t, 102))
+	require.Equal(t, http.StatusCreated, response.status)
+
+	docs.When("when an admin scans for duplicates with a minimum score above that of a shared phone number")
+	response = tstPerformGet(tstDuplicatesUrl+"?min_score=0.8", tstValidAdminToken(t))
+
+	docs.Then("then they are not reported")
+	require.Equal(t, http.StatusOK, response.status)
+	actual := duplicates.DuplicateList{}
+	tstParseJson(response.body, &actual)
+	require.Empty(t, actual.Duplicates)
+}
+
+func TestDuplicates_InvalidMinScore(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.When("when an admin scans for duplicates with an invalid minimum score")
+	response := tstPerformGet(tstDuplicatesUrl+"?min_score=2", tstValidAdminToken(t))
+
+	docs.Then("then the request fails with the correct error")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "duplicates.param.invalid", url.Values{
+		"min_score": {"must be a number between 0 and 1"},
+	})
+}
+
+func TestDuplicates_DenyUser(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.When("when a regular user attempts to scan for duplicates")
+	response := tstPerformGet(tstDuplicatesUrl, tstValidUserToken(t, 101))
+
+	docs.Then("then the request is denied as unauthorized (403) and the correct error is returned")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+}
+
+func TestMerge_Success(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given two registrations of the same person, the second one with additional info and admin comments")
+	loc1, att1 := tstBulkRegister(t, "merge1a-", tstValidUserToken(t, 101), "")
+	loc2, att2 := tstBulkRegister(t, "merge1b-", tstValidUserToken(t, 102), "")
+	response := tstPerformPost(loc2+"/additional-info/myarea", `{"room":"412"}`, tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, response.status)
+	response = tstPerformPut(loc2+"/admin", tstRenderJson(admin.AdminInfoDto{AdminComments: "called on the phone"}), tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, response.status)
+
+	docs.When("when an admin merges the second registration into the first")
+	response = tstPerformPost(loc1+"/merge", tstRenderJson(duplicates.MergeDto{
+		DuplicateId: att2.Id,
+		Comment:     "registered twice",
+	}), tstValidAdminToken(t))
+
+	docs.Then("then the request is successful and reports what was moved")
+	require.Equal(t, http.StatusOK, response.status)
+	result := duplicates.MergeResult{}
+	tstParseJson(response.body, &result)
+	require.Equal(t, duplicates.MergeResult{
+		SurvivingId:               att1.Id,
+		MergedId:                  att2.Id,
+		MovedAdditionalInfo:       []string{"myarea"},
+		ConflictingAdditionalInfo: []string{},
+		StatusHistoryEntries:      1,
+	}, result)
+
+	docs.Then("and the additional info has been moved")
+	response = tstPerformGet(loc1+"/additional-info/myarea", tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, response.status)
+	require.Equal(t, `{"room":"412"}`, response.body)
+	response = tstPerformGet(loc2+"/additional-info/myarea", tstValidAdminToken(t))
+	require.Equal(t, http.StatusNotFound, response.status)
+
+	docs.Then("and the admin comments have been carried over")
+	response = tstPerformGet(loc1+"/admin", tstValidAdminToken(t))
+	adminInfo := admin.AdminInfoDto{}
+	tstParseJson(response.body, &adminInfo)
+	require.Equal(t, fmt.Sprintf("merged %d into this registration: registered twice\nmerged from %d: called on the phone", att2.Id, att2.Id), adminInfo.AdminComments)
+
+	docs.Then("and the status history has been moved, without changing the status of the surviving registration")
+	response = tstPerformGet(loc1+"/status-history", tstValidAdminToken(t))
+	history := status.StatusHistoryDto{}
+	tstParseJson(response.body, &history)
+	require.Equal(t, 3, len(history.StatusHistory))
+	require.Contains(t, history.StatusHistory[1].Comment, fmt.Sprintf("merged from %d, originally new at ", att2.Id))
+	require.Equal(t, fmt.Sprintf("merged %d into this registration: registered twice", att2.Id), history.StatusHistory[2].Comment)
+	tstVerifyStatus(t, loc1, status.New)
+
+	docs.Then("and the merged registration has been cancelled")
+	tstVerifyStatus(t, loc2, status.Cancelled)
+	require.Equal(t, 1, len(mailMock.Recording()))
+	require.Equal(t, "change-status-cancelled", mailMock.Recording()[0].CommonID)
+}
+
+func TestMerge_StatusHistory(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given two registrations of the same person, the second one with a status change")
+	loc1, _ := tstBulkRegister(t, "merge7a-", tstValidUserToken(t, 101), "")
+	loc2, att2 := tstBulkRegister(t, "merge7b-", tstValidUserToken(t, 102), "")
+	response := tstPerformPost(loc2+"/status", tstRenderJson(status.StatusChangeDto{
+		Status:  status.Waiting,
+		Comment: "no room left",
+	}), tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, response.status)
+
+	docs.When("when an admin merges the second registration into the first")
+	response = tstPerformPost(loc1+"/merge", tstRenderJson(duplicates.MergeDto{
+		DuplicateId: att2.Id,
+		Comment:     "registered twice",
+	}), tstValidAdminToken(t))
+
+	docs.Then("then the request is successful and reports the moved status history entries")
+	require.Equal(t, http.StatusOK, response.status)
+	result := duplicates.MergeResult{}
+	tstParseJson(response.body, &result)
+	require.Equal(t, 2, result.StatusHistoryEntries)
+
+	docs.Then("and the moved entries record the original status, but keep the status of the surviving registration")
+	response = tstPerformGet(loc1+"/status-history", tstValidAdminToken(t))
+	history := status.StatusHistoryDto{}
+	tstParseJson(response.body, &history)
+	require.Equal(t, 4, len(history.StatusHistory))
+	for _, entry := range history.StatusHistory {
+		require.Equal(t, status.New, entry.Status)
+	}
+	require.Contains(t, history.StatusHistory[1].Comment, fmt.Sprintf("merged from %d, originally new at ", att2.Id))
+	require.Contains(t, history.StatusHistory[2].Comment, fmt.Sprintf("merged from %d, originally waiting at ", att2.Id))
+	require.True(t, strings.HasSuffix(history.StatusHistory[2].Comment, ": no room left"))
+	require.Equal(t, fmt.Sprintf("merged %d into this registration: registered twice", att2.Id), history.StatusHistory[3].Comment)
+	tstVerifyStatus(t, loc1, status.New)
+	tstVerifyStatus(t, loc2, status.Cancelled)
+}
+
+func TestMerge_ConflictingAdditionalInfo(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given two registrations that both have additional info for the same area")
+	loc1, _ := tstBulkRegister(t, "merge2a-", tstValidUserToken(t, 101), "")
+	loc2, att2 := tstBulkRegister(t, "merge2b-", tstValidUserToken(t, 102), "")
+	require.Equal(t, http.StatusNoContent, tstPerformPost(loc1+"/additional-info/myarea", `{"room":"1"}`, tstValidAdminToken(t)).status)
+	require.Equal(t, http.StatusNoContent, tstPerformPost(loc2+"/additional-info/myarea", `{"room":"2"}`, tstValidAdminToken(t)).status)
+
+	docs.When("when an admin merges them")
+	response := tstPerformPost(loc1+"/merge", tstRenderJson(duplicates.MergeDto{DuplicateId: att2.Id}), tstValidAdminToken(t))
+
+	docs.Then("then the request is successful, but both values are kept where they were")
+	require.Equal(t, http.StatusOK, response.status)
+	result := duplicates.MergeResult{}
+	tstParseJson(response.body, &result)
+	require.Equal(t, []string{}, result.MovedAdditionalInfo)
+	require.Equal(t, []string{"myarea"}, result.ConflictingAdditionalInfo)
+	require.Equal(t, `{"room":"1"}`, tstPerformGet(loc1+"/additional-info/myarea", tstValidAdminToken(t)).body)
+	require.Equal(t, `{"room":"2"}`, tstPerformGet(loc2+"/additional-info/myarea", tstValidAdminToken(t)).body)
+}
+
+func TestMerge_HasPayments(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given two registrations, the second of which has payments")
+	loc1, _ := tstBulkRegister(t, "merge3a-", tstValidUserToken(t, 101), "")
+	loc2, att2 := tstBulkRegister(t, "merge3b-", tstValidUserToken(t, 102), "")
+	_ = paymentMock.InjectTransaction(context.Background(), tstCreateTransaction(att2.Id, paymentservice.Payment, 1000))
+
+	docs.When("when an admin attempts to merge them")
+	response := tstPerformPost(loc1+"/merge", tstRenderJson(duplicates.MergeDto{DuplicateId: att2.Id}), tstValidAdminToken(t))
+
+	docs.Then("then the request fails with the correct error and nothing is changed")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "merge.has.payments", url.Values{
+		"details": {"the duplicate registration has payments, please move them in the payment service first"},
+	})
+	tstVerifyStatus(t, loc2, status.New)
+}
+
+func TestMerge_SameId(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given a registration")
+	loc, att := tstRegisterAttendee(t, "merge4-")
+
+	docs.When("when an admin attempts to merge it into itself")
+	response := tstPerformPost(loc+"/merge", tstRenderJson(duplicates.MergeDto{DuplicateId: att.Id}), tstValidAdminToken(t))
+
+	docs.Then("then the request fails with the correct error")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "merge.data.invalid", url.Values{
+		"duplicate_id": {"must be the id of a different registration"},
+	})
+}
+
+func TestMerge_UnknownDuplicate(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given a registration")
+	loc, _ := tstRegisterAttendee(t, "merge5-")
+
+	docs.When("when an admin attempts to merge a registration that does not exist into it")
+	response := tstPerformPost(loc+"/merge", tstRenderJson(duplicates.MergeDto{DuplicateId: 789}), tstValidAdminToken(t))
+
+	docs.Then("then the request fails with the correct error")
+	tstRequireErrorResponse(t, response, http.StatusNotFound, "attendee.id.notfound", url.Values{})
+}
+
+func TestMerge_DenyUser(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given two registrations")
+	token := tstValidUserToken(t, 101)
+	loc1, _ := tstBulkRegister(t, "merge6a-", token, "")
+	_, att2 := tstBulkRegister(t, "merge6b-", tstValidUserToken(t, 102), "")
+
+	docs.When("when a regular user attempts to merge them")
+	response := tstPerformPost(loc1+"/merge", tstRenderJson(duplicates.MergeDto{DuplicateId: att2.Id}), token)
+
+	docs.Then("then the request is denied as unauthorized (403) and the correct error is returned")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+}
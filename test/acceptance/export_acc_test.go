@@ -0,0 +1,149 @@
+package acceptance
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/admin"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/stretchr/testify/require"
+)
+
+// --------------------------------------------------
+// acceptance tests for spreadsheet export of searches
+// --------------------------------------------------
+
+func TestSearchExport_AdminCsv(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given two existing attendees")
+	_, _ = tstRegisterAttendee(t, "export1a-")
+	_, _ = tstBulkRegister(t, "export1b-", tstValidUserToken(t, 101), "AardvarkZebra")
+
+	docs.When("when an admin searches for attendees sorted by nickname, asking for csv")
+	search := attendee.AttendeeSearchCriteria{
+		MatchAny: []attendee.AttendeeSearchSingleCriterion{
+			{},
+		},
+		FillFields: []string{"nickname", "zip", "flags", "total_dues"},
+		SortBy:     "nickname",
+	}
+	response := tstPerformPostWithAccept("/api/rest/v1/attendees/find", tstRenderJson(search), tstValidAdminToken(t), "text/csv")
+
+	docs.Then("then the request is successful and the csv contains the desired fields in the requested order")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	require.Equal(t, media.ContentTypeTextCsv, response.contentType)
+	require.Equal(t, [][]string{
+		{"id", "badge_id", "nickname", "zip", "flags", "total_dues"},
+		{"2", "2N", "AardvarkZebra", "export1b-12345", "anon,hc,terms-accepted", "0"},
+		{"1", "1C", "BlackCheetah", "export1a-12345", "anon,hc,terms-accepted", "0"},
+	}, tstParseCsv(t, response.body))
+}
+
+func TestSearchExport_RegdeskCsvLimited(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an approved attendee who has been given the regdesk permission, and a new attendee")
+	loc, att := tstRegisterAttendeeAndTransitionToStatus(t, "export2a-", status.Approved)
+	permBody := admin.AdminInfoDto{
+		Permissions: "regdesk",
+	}
+	permissionResponse := tstPerformPut(loc+"/admin", tstRenderJson(permBody), tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, permissionResponse.status)
+	_, _ = tstBulkRegister(t, "export2b-", tstValidUserToken(t, 101), "AardvarkZebra")
+
+	docs.When("when they search for attendees asking for csv, including forbidden fields")
+	search := attendee.AttendeeSearchCriteria{
+		MatchAny: []attendee.AttendeeSearchSingleCriterion{
+			{},
+		},
+		FillFields: []string{"nickname", "email", "status"},
+	}
+	response := tstPerformPostWithAccept("/api/rest/v1/attendees/find", tstRenderJson(search), tstValidUserToken(t, att.Id), "text/csv")
+
+	docs.Then("then the request is successful, but the csv only contains allowed fields and attending attendees")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	require.Equal(t, [][]string{
+		{"id", "badge_id", "nickname", "status"},
+		{"1", "1C", "BlackCheetah", "approved"},
+	}, tstParseCsv(t, response.body))
+}
+
+func TestSearchExport_AdminXlsx(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee")
+	_, _ = tstRegisterAttendee(t, "export3-")
+
+	docs.When("when an admin searches for attendees, asking for xlsx")
+	search := attendee.AttendeeSearchCriteria{
+		MatchAny: []attendee.AttendeeSearchSingleCriterion{
+			{},
+		},
+		FillFields: []string{"nickname", "current_dues"},
+	}
+	response := tstPerformPostWithAccept("/api/rest/v1/attendees/find", tstRenderJson(search), tstValidAdminToken(t), media.ContentTypeXlsx)
+
+	docs.Then("then the request is successful and a workbook with the attendees is returned")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	require.Equal(t, media.ContentTypeXlsx, response.contentType)
+	sheet := tstReadZipEntry(t, response.body, "xl/worksheets/sheet1.xml")
+	require.Contains(t, sheet, `<row r="1"><c r="A1" t="inlineStr"><is><t xml:space="preserve">id</t></is></c>`)
+	require.Contains(t, sheet, `<row r="2"><c r="A2"><v>1</v></c><c r="B2" t="inlineStr"><is><t xml:space="preserve">1C</t></is></c>`+
+		`<c r="C2" t="inlineStr"><is><t xml:space="preserve">BlackCheetah</t></is></c><c r="D2"><v>0</v></c></row>`)
+	require.NotContains(t, sheet, `<row r="3">`)
+}
+
+func TestSearchExport_NoResults(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.When("when an admin searches for attendees asking for csv, but nobody matches")
+	search := attendee.AttendeeSearchCriteria{
+		MatchAny: []attendee.AttendeeSearchSingleCriterion{
+			{Ids: []uint{42}},
+		},
+		FillFields: []string{"nickname"},
+	}
+	response := tstPerformPostWithAccept("/api/rest/v1/attendees/find", tstRenderJson(search), tstValidAdminToken(t), "text/csv")
+
+	docs.Then("then the request is successful and the csv only contains the header")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	require.Equal(t, [][]string{
+		{"id", "badge_id", "nickname"},
+	}, tstParseCsv(t, response.body))
+}
+
+// --- helpers ---
+
+func tstParseCsv(t *testing.T, body string) [][]string {
+	records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+	require.NoError(t, err)
+	return records
+}
+
+func tstReadZipEntry(t *testing.T, body string, name string) string {
+	archive, err := zip.NewReader(bytes.NewReader([]byte(body)), int64(len(body)))
+	require.NoError(t, err)
+	entry, err := archive.Open(name)
+	require.NoError(t, err)
+	defer entry.Close()
+	content, err := io.ReadAll(entry)
+	require.NoError(t, err)
+	return string(content)
+}
@@ -0,0 +1,133 @@
+package acceptance
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/admin"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/stretchr/testify/require"
+)
+
+// ---------------------------------------------
+// acceptance tests for fuzzy attendee search
+// ---------------------------------------------
+
+func TestFuzzySearch_RelevanceOrder(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given three attendees with similar last names, and one with a different one")
+	_, att1 := tstFuzzyRegister(t, "fuzzy1a-", tstValidUserToken(t, 101), "Mueller")
+	_, att2 := tstFuzzyRegister(t, "fuzzy1b-", tstValidUserToken(t, 102), "Müller")
+	_, _ = tstFuzzyRegister(t, "fuzzy1c-", tstValidStaffToken(t, 202), "Schulz")
+	_, att4 := tstFuzzyRegister(t, "fuzzy1d-", tstValidUserToken(t, 103), "Müllner")
+
+	docs.When("when an admin does a fuzzy search for the name without diacritics, sorted by relevance")
+	search := attendee.AttendeeSearchCriteria{
+		MatchAny: []attendee.AttendeeSearchSingleCriterion{
+			{Fuzzy: "hans muller"},
+		},
+		FillFields: []string{"name"},
+		SortBy:     "relevance",
+	}
+	result := tstSearchPage(t, search)
+
+	docs.Then("then the exact match comes first, followed by the close matches, and each has its relevance")
+	require.Equal(t, []uint{att2.Id, att1.Id, att4.Id}, tstSearchResultIds(result))
+	require.Equal(t, 1.0, *result.Attendees[0].Relevance)
+	require.Equal(t, 0.9, *result.Attendees[1].Relevance)
+	require.Equal(t, 0.9, *result.Attendees[2].Relevance)
+	require.Equal(t, "Müller", *result.Attendees[0].LastName)
+}
+
+func TestFuzzySearch_Paging(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given three attendees with similar last names")
+	_, att1 := tstFuzzyRegister(t, "fuzzy2a-", tstValidUserToken(t, 101), "Meier")
+	_, att2 := tstFuzzyRegister(t, "fuzzy2b-", tstValidUserToken(t, 102), "Mayr")
+	_, att3 := tstFuzzyRegister(t, "fuzzy2c-", tstValidStaffToken(t, 202), "Meyer")
+
+	docs.When("when an admin does a fuzzy search sorted by relevance, two at a time")
+	search := attendee.AttendeeSearchCriteria{
+		MatchAny: []attendee.AttendeeSearchSingleCriterion{
+			{Fuzzy: "meier"},
+		},
+		SortBy:     "relevance",
+		NumResults: 2,
+	}
+	page1 := tstSearchPage(t, search)
+
+	docs.Then("then the pages contain all matches in order of relevance")
+	require.Equal(t, []uint{att1.Id, att3.Id}, tstSearchResultIds(page1))
+	require.Equal(t, int64(3), *page1.Total)
+	require.NotEmpty(t, page1.Next)
+	search.Cursor = page1.Next
+	page2 := tstSearchPage(t, search)
+	require.Equal(t, []uint{att2.Id}, tstSearchResultIds(page2))
+	require.Empty(t, page2.Next)
+}
+
+func TestFuzzySearch_Comments(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee with an admin comment")
+	loc, att := tstFuzzyRegister(t, "fuzzy3-", tstValidUserToken(t, 101), "Schulz")
+	response := tstPerformPut(loc+"/admin", tstRenderJson(admin.AdminInfoDto{AdminComments: "paid in café"}), tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, response.status)
+
+	docs.When("when an admin does a fuzzy search for a word in the comment, with a typo")
+	result := tstSearchPage(t, attendee.AttendeeSearchCriteria{
+		MatchAny: []attendee.AttendeeSearchSingleCriterion{
+			{Fuzzy: "cafe"},
+			{Fuzzy: "payd"},
+		},
+		FillFields: []string{"admin_comments"},
+	})
+
+	docs.Then("then the attendee is found, with the lower relevance of a comment match")
+	require.Equal(t, []uint{att.Id}, tstSearchResultIds(result))
+	require.Equal(t, 0.5, *result.Attendees[0].Relevance)
+}
+
+func TestFuzzySearch_OtherConditionsApply(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given two attendees with the same name, only one of them approved")
+	_, att1 := tstRegisterAttendeeAndTransitionToStatus(t, "fuzzy4a-", status.Approved)
+	_, _ = tstFuzzyRegister(t, "fuzzy4b-", tstValidUserToken(t, 101), "Mustermann")
+
+	docs.When("when an admin does a fuzzy search for approved attendees")
+	result := tstSearchPage(t, attendee.AttendeeSearchCriteria{
+		MatchAny: []attendee.AttendeeSearchSingleCriterion{
+			{Fuzzy: "Musterman", Status: []status.Status{status.Approved}},
+		},
+	})
+
+	docs.Then("then only the approved attendee is found")
+	require.Equal(t, []uint{att1.Id}, tstSearchResultIds(result))
+}
+
+// --- helpers ---
+
+func tstFuzzyRegister(t *testing.T, testcase string, token string, lastName string) (string, attendee.AttendeeDto) {
+	dto := tstBuildValidAttendee(testcase)
+	dto.LastName = lastName
+	creationResponse := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(dto), token)
+	require.Equal(t, http.StatusCreated, creationResponse.status, "unexpected http response status")
+
+	rereadResponse := tstPerformGet(creationResponse.location, token)
+	require.Equal(t, http.StatusOK, rereadResponse.status, "unexpected http response status")
+	tstParseJson(rereadResponse.body, &dto)
+	return creationResponse.location, dto
+}
@@ -0,0 +1,328 @@
+package acceptance
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/groups"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/webhook"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------
+// acceptance tests for attendee groups
+// ------------------------------------------
+
+func TestGroups_CreateInviteAccept(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given two attendees")
+	ownerToken := tstValidUserToken(t, 101)
+	ownerLoc, owner := tstBulkRegister(t, "grp1a-", ownerToken, "")
+	inviteeToken := tstValidUserToken(t, 102)
+	inviteeLoc, invitee := tstBulkRegister(t, "grp1b-", inviteeToken, "WhiteTiger")
+
+	docs.When("when the first one creates a group and invites the second one")
+	groupLoc := tstCreateGroup(t, ownerLoc, ownerToken, "Cheetah Pack")
+	mailMock.Reset()
+	response := tstPerformPostNoBody(fmt.Sprintf("%s/members/%d", groupLoc, invitee.Id), ownerToken)
+
+	docs.Then("then the invitation is successful and the invitee is informed")
+	require.Equal(t, http.StatusNoContent, response.status)
+	mails := mailMock.Recording()
+	require.Equal(t, 1, len(mails))
+	require.Equal(t, "group-invitation", mails[0].CommonID)
+	require.Equal(t, []string{invitee.Email}, mails[0].To)
+	require.Equal(t, "Cheetah Pack", mails[0].Variables["group_name"])
+	require.Equal(t, "BlackCheetah", mails[0].Variables["owner_nickname"])
+
+	docs.Then("and the invitee sees the invitation, but is not a member yet")
+	response = tstPerformGet(inviteeLoc+"/group-invitations", inviteeToken)
+	require.Equal(t, http.StatusOK, response.status)
+	invitations := groups.GroupList{}
+	tstParseJson(response.body, &invitations)
+	require.Equal(t, 1, len(invitations.Groups))
+	require.Equal(t, "Cheetah Pack", invitations.Groups[0].Name)
+	tstRequireErrorResponse(t, tstPerformGet(inviteeLoc+"/group", inviteeToken), http.StatusNotFound, "group.notfound", url.Values{})
+
+	docs.When("when the invitee accepts")
+	tstWaitForWebhooks()
+	webhookMock.Reset()
+	response = tstPerformPostNoBody(fmt.Sprintf("%s/members/%d/accept", groupLoc, invitee.Id), inviteeToken)
+
+	docs.Then("then the request is successful and both are listed as members")
+	require.Equal(t, http.StatusNoContent, response.status)
+	response = tstPerformGet(inviteeLoc+"/group", inviteeToken)
+	require.Equal(t, http.StatusOK, response.status)
+	group := groups.Group{}
+	tstParseJson(response.body, &group)
+	require.Equal(t, "Cheetah Pack", group.Name)
+	require.Equal(t, owner.Id, group.Owner)
+	require.Equal(t, groups.GroupMember{Id: owner.Id, Nickname: "BlackCheetah", Joined: true}, group.Members[0])
+	require.Equal(t, groups.GroupMember{Id: invitee.Id, Nickname: "WhiteTiger", Joined: true}, group.Members[1])
+	require.Equal(t, groupLoc, fmt.Sprintf("/api/rest/v1/groups/%d", group.Id))
+
+	docs.Then("and downstream services have been informed")
+	tstWaitForWebhooks()
+	require.Equal(t, 1, len(webhookMock.Recording()))
+	event := webhook.Event{}
+	tstParseJson(string(webhookMock.Recording()[0].Body), &event)
+	require.Equal(t, webhook.EventAttendeeGroupChanged, event.Event)
+	require.Equal(t, invitee.Id, event.AttendeeId)
+	require.Equal(t, group.Id, event.GroupId)
+
+	docs.Then("and the invitation is gone")
+	response = tstPerformGet(inviteeLoc+"/group-invitations", inviteeToken)
+	invitations = groups.GroupList{}
+	tstParseJson(response.body, &invitations)
+	require.Equal(t, 0, len(invitations.Groups))
+}
+
+func TestGroups_FindByGroup(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given a group with two members and an open invitation")
+	ownerToken := tstValidUserToken(t, 101)
+	ownerLoc, owner := tstBulkRegister(t, "grp2a-", ownerToken, "")
+	_, member := tstBulkRegister(t, "grp2b-", tstValidUserToken(t, 102), "WhiteTiger")
+	_, invitee := tstBulkRegister(t, "grp2c-", tstValidStaffToken(t, 202), "RedFox")
+	_, outsider := tstBulkRegister(t, "grp2d-", tstValidStaffToken(t, 1234567890), "BlueJay")
+	groupLoc := tstCreateGroup(t, ownerLoc, ownerToken, "Cheetah Pack")
+	tstInviteToGroup(t, groupLoc, member.Id, ownerToken)
+	require.Equal(t, http.StatusNoContent, tstPerformPostNoBody(fmt.Sprintf("%s/members/%d/accept", groupLoc, member.Id), tstValidUserToken(t, 102)).status)
+	tstInviteToGroup(t, groupLoc, invitee.Id, ownerToken)
+
+	docs.When("when an admin searches for the members of the group")
+	groupId := tstIdFromLocation(groupLoc)
+	criteria := attendee.AttendeeSearchCriteria{
+		MatchAny: []attendee.AttendeeSearchSingleCriterion{
+			{GroupIds: []uint{groupId}},
+		},
+		FillFields: []string{"nickname", "group_id"},
+	}
+	response := tstPerformPost("/api/rest/v1/attendees/find", tstRenderJson(criteria), tstValidAdminToken(t))
+
+	docs.Then("then exactly the members are returned, with their group")
+	require.Equal(t, http.StatusOK, response.status)
+	result := attendee.AttendeeSearchResultList{}
+	tstParseJson(response.body, &result)
+	require.Equal(t, 2, len(result.Attendees))
+	require.Equal(t, owner.Id, result.Attendees[0].Id)
+	require.Equal(t, member.Id, result.Attendees[1].Id)
+	for _, a := range result.Attendees {
+		require.NotNil(t, a.GroupId)
+		require.Equal(t, groupId, *a.GroupId)
+	}
+
+	docs.Then("and attendees outside the group carry no group")
+	criteria = attendee.AttendeeSearchCriteria{
+		MatchAny: []attendee.AttendeeSearchSingleCriterion{
+			{Ids: []uint{invitee.Id, outsider.Id}},
+		},
+		FillFields: []string{"nickname", "group_id"},
+	}
+	response = tstPerformPost("/api/rest/v1/attendees/find", tstRenderJson(criteria), tstValidAdminToken(t))
+	result = attendee.AttendeeSearchResultList{}
+	tstParseJson(response.body, &result)
+	require.Equal(t, 2, len(result.Attendees))
+	for _, a := range result.Attendees {
+		require.Nil(t, a.GroupId)
+	}
+}
+
+func TestGroups_AcceptWithoutInvitation(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given a group and an attendee who has not been invited")
+	ownerToken := tstValidUserToken(t, 101)
+	ownerLoc, _ := tstBulkRegister(t, "grp3a-", ownerToken, "")
+	_, other := tstBulkRegister(t, "grp3b-", tstValidUserToken(t, 102), "WhiteTiger")
+	groupLoc := tstCreateGroup(t, ownerLoc, ownerToken, "Cheetah Pack")
+
+	docs.When("when the attendee attempts to join")
+	response := tstPerformPostNoBody(fmt.Sprintf("%s/members/%d/accept", groupLoc, other.Id), tstValidUserToken(t, 102))
+
+	docs.Then("then the request fails")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "group.invitation.missing", url.Values{
+		"details": {"the attendee has no open invitation to this group"},
+	})
+}
+
+func TestGroups_OnlyOneGroupPerAttendee(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee who is already the owner of a group")
+	ownerToken := tstValidUserToken(t, 101)
+	ownerLoc, _ := tstBulkRegister(t, "grp4a-", ownerToken, "")
+	_ = tstCreateGroup(t, ownerLoc, ownerToken, "Cheetah Pack")
+
+	docs.When("when they attempt to create a second group")
+	response := tstPerformPost(ownerLoc+"/group", tstRenderJson(groups.GroupCreate{Name: "Second Pack"}), ownerToken)
+
+	docs.Then("then the request fails")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "group.member.conflict", url.Values{
+		"details": {"the attendee is already a member of a group"},
+	})
+}
+
+func TestGroups_InvalidName(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee")
+	ownerToken := tstValidUserToken(t, 101)
+	ownerLoc, _ := tstBulkRegister(t, "grp5a-", ownerToken, "")
+
+	docs.When("when they attempt to create a group with an overly long name")
+	response := tstPerformPost(ownerLoc+"/group", tstRenderJson(groups.GroupCreate{Name: strings.Repeat("x", 81)}), ownerToken)
+
+	docs.Then("then the request fails with a validation error")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "group.data.invalid", url.Values{
+		"name": {"name field must be at least 1 and at most 80 characters long"},
+	})
+}
+
+func TestGroups_ConstraintOnJoin(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given a group and a day guest")
+	ownerToken := tstValidUserToken(t, 101)
+	ownerLoc, _ := tstBulkRegister(t, "grp6a-", ownerToken, "")
+	groupLoc := tstCreateGroup(t, ownerLoc, ownerToken, "Cheetah Pack")
+	dayGuest := tstBuildValidAttendee("grp6b-")
+	tstOverridePackages(&dayGuest, "room-none,day-sat,sponsor2")
+	creationResponse := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(dayGuest), tstValidUserToken(t, 102))
+	require.Equal(t, http.StatusCreated, creationResponse.status)
+	dayGuestId := tstIdFromLocation(creationResponse.location)
+
+	docs.When("when the owner attempts to invite the day guest")
+	response := tstPerformPostNoBody(fmt.Sprintf("%s/members/%d", groupLoc, dayGuestId), ownerToken)
+
+	docs.Then("then the request fails with the configured message")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "group.constraint.violated", url.Values{
+		"details": {"Day guests cannot join a group."},
+	})
+}
+
+func TestGroups_ConstraintOnUpdate(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given a group owner")
+	ownerToken := tstValidUserToken(t, 101)
+	ownerLoc, owner := tstBulkRegister(t, "grp7a-", ownerToken, "")
+	_ = tstCreateGroup(t, ownerLoc, ownerToken, "Cheetah Pack")
+
+	docs.When("when an admin changes their registration to a day guest")
+	changed := owner
+	tstOverridePackages(&changed, "room-none,day-sat,sponsor2")
+	response := tstPerformPut(ownerLoc, tstRenderJson(changed), tstValidAdminToken(t))
+
+	docs.Then("then the request fails with the configured message")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "attendee.data.invalid", url.Values{
+		"packages": {"Day guests cannot join a group."},
+	})
+}
+
+func TestGroups_OwnerCannotLeave(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given a group owner")
+	ownerToken := tstValidUserToken(t, 101)
+	ownerLoc, owner := tstBulkRegister(t, "grp8a-", ownerToken, "")
+	groupLoc := tstCreateGroup(t, ownerLoc, ownerToken, "Cheetah Pack")
+
+	docs.When("when they attempt to leave their group")
+	response := tstPerformDelete(fmt.Sprintf("%s/members/%d", groupLoc, owner.Id), ownerToken)
+
+	docs.Then("then the request fails")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "group.owner.remove", url.Values{
+		"details": {"the owner cannot leave the group, please disband it instead"},
+	})
+}
+
+func TestGroups_MemberLeavesAndOwnerDisbands(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given a group with two members")
+	ownerToken := tstValidUserToken(t, 101)
+	memberToken := tstValidUserToken(t, 102)
+	ownerLoc, _ := tstBulkRegister(t, "grp9a-", ownerToken, "")
+	memberLoc, member := tstBulkRegister(t, "grp9b-", memberToken, "WhiteTiger")
+	groupLoc := tstCreateGroup(t, ownerLoc, ownerToken, "Cheetah Pack")
+	tstInviteToGroup(t, groupLoc, member.Id, ownerToken)
+	require.Equal(t, http.StatusNoContent, tstPerformPostNoBody(fmt.Sprintf("%s/members/%d/accept", groupLoc, member.Id), memberToken).status)
+
+	docs.When("when the member leaves")
+	response := tstPerformDelete(fmt.Sprintf("%s/members/%d", groupLoc, member.Id), memberToken)
+
+	docs.Then("then the request is successful and they are no longer in a group")
+	require.Equal(t, http.StatusNoContent, response.status)
+	tstRequireErrorResponse(t, tstPerformGet(memberLoc+"/group", memberToken), http.StatusNotFound, "group.notfound", url.Values{})
+
+	docs.When("when the owner disbands the group")
+	response = tstPerformDelete(groupLoc, ownerToken)
+
+	docs.Then("then the request is successful and the group is gone")
+	require.Equal(t, http.StatusNoContent, response.status)
+	tstRequireErrorResponse(t, tstPerformGet(groupLoc, tstValidAdminToken(t)), http.StatusNotFound, "group.notfound", url.Values{})
+	tstRequireErrorResponse(t, tstPerformGet(ownerLoc+"/group", ownerToken), http.StatusNotFound, "group.notfound", url.Values{})
+}
+
+func TestGroups_DenyOutsiders(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given a group and an attendee who is not involved with it")
+	ownerToken := tstValidUserToken(t, 101)
+	ownerLoc, _ := tstBulkRegister(t, "grp10a-", ownerToken, "")
+	_, other := tstBulkRegister(t, "grp10b-", tstValidUserToken(t, 102), "WhiteTiger")
+	groupLoc := tstCreateGroup(t, ownerLoc, ownerToken, "Cheetah Pack")
+	outsiderToken := tstValidUserToken(t, 102)
+
+	docs.When("when the outsider attempts to view the group, invite themselves, or disband it")
+	responses := []tstWebResponse{
+		tstPerformGet(groupLoc, outsiderToken),
+		tstPerformPostNoBody(fmt.Sprintf("%s/members/%d", groupLoc, other.Id), outsiderToken),
+		tstPerformDelete(groupLoc, outsiderToken),
+		tstPerformGet(ownerLoc+"/group", outsiderToken),
+	}
+
+	docs.Then("then all requests are denied")
+	for _, response := range responses {
+		tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", url.Values{
+			"details": {"you are not authorized to access this data - the attempt has been logged"},
+		})
+	}
+}
+
+// helper functions
+
+func tstCreateGroup(t *testing.T, attendeeLoc string, token string, name string) string {
+	response := tstPerformPost(attendeeLoc+"/group", tstRenderJson(groups.GroupCreate{Name: name}), token)
+	require.Equal(t, http.StatusCreated, response.status, "unexpected http response status")
+	return response.location
+}
+
+func tstInviteToGroup(t *testing.T, groupLoc string, attendeeId uint, token string) {
+	response := tstPerformPostNoBody(fmt.Sprintf("%s/members/%d", groupLoc, attendeeId), token)
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+}
+
+func tstIdFromLocation(location string) uint {
+	var id uint
+	_, _ = fmt.Sscanf(location[strings.LastIndex(location, "/")+1:], "%d", &id)
+	return id
+}
@@ -0,0 +1,403 @@
+package acceptance
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/admin"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/counts"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/history"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------
+// acceptance tests for the attendee history
+// ------------------------------------------
+
+func TestAttendeeHistory_AdminSuccess(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee whose data and admin info have been changed by an admin")
+	loc, att := tstRegisterAttendee(t, "hist1-")
+	token := tstValidAdminToken(t)
+	changed := att
+	changed.Nickname = "Changed Nick"
+	changed.City = "Hamburg"
+	updateResponse := tstPerformPut(loc, tstRenderJson(changed), token)
+	require.Equal(t, http.StatusOK, updateResponse.status)
+	adminResponse := tstPerformPut(loc+"/admin", tstRenderJson(admin.AdminInfoDto{Permissions: "sponsordesk"}), token)
+	require.Equal(t, http.StatusNoContent, adminResponse.status)
+
+	docs.When("when an admin requests the history of the attendee")
+	response := tstPerformGet(loc+"/history", token)
+
+	docs.Then("then the request is successful and lists both changes in order, with field level diffs")
+	require.Equal(t, http.StatusOK, response.status)
+	actual := history.HistoryList{}
+	tstParseJson(response.body, &actual)
+	require.Equal(t, 2, len(actual.Entries))
+
+	attendeeChange := actual.Entries[0]
+	require.Equal(t, "Attendee", attendeeChange.Entity)
+	require.Equal(t, "1234567890", attendeeChange.Identity)
+	require.NotEmpty(t, attendeeChange.RequestId)
+	require.NotEmpty(t, attendeeChange.Timestamp)
+	require.Equal(t, []history.FieldChange{
+		{Field: "City", OldValue: att.City, NewValue: "Hamburg"},
+		{Field: "Nickname", OldValue: att.Nickname, NewValue: "Changed Nick"},
+	}, attendeeChange.Changes)
+
+	adminChange := actual.Entries[1]
+	require.Equal(t, "AdminInfo", adminChange.Entity)
+	require.Equal(t, []history.FieldChange{
+		{Field: "Flags", OldValue: "", NewValue: ","},
+		{Field: "Permissions", OldValue: "", NewValue: ",sponsordesk,"},
+	}, adminChange.Changes)
+	require.True(t, attendeeChange.Id < adminChange.Id)
+}
+
+func TestAttendeeHistory_NoChanges(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee that has never been changed")
+	loc, _ := tstRegisterAttendee(t, "hist2-")
+
+	docs.When("when an admin requests the history of the attendee")
+	response := tstPerformGet(loc+"/history", tstValidAdminToken(t))
+
+	docs.Then("then the request is successful and returns an empty list")
+	require.Equal(t, http.StatusOK, response.status)
+	actual := history.HistoryList{}
+	tstParseJson(response.body, &actual)
+	require.NotNil(t, actual.Entries)
+	require.Equal(t, 0, len(actual.Entries))
+}
+
+func TestAttendeeHistory_DenyUser(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee")
+	token := tstValidUserToken(t, 101)
+	loc, _ := tstRegisterAttendeeWithToken(t, "hist3-", token)
+
+	docs.When("when they attempt to read their own history")
+	response := tstPerformGet(loc+"/history", token)
+
+	docs.Then("then the request is denied as unauthorized (403) and the correct error is returned")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+}
+
+func TestAttendeeHistory_NotFound(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.When("when an admin requests the history of an attendee that does not exist")
+	response := tstPerformGet("/api/rest/v1/attendees/789/history", tstValidAdminToken(t))
+
+	docs.Then("then the request fails with the correct error")
+	tstRequireErrorResponse(t, response, http.StatusNotFound, "attendee.id.notfound", url.Values{})
+}
+
+func TestAttendeeAsOf_AdminSuccess(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee who has been changed twice by an admin")
+	loc, att := tstRegisterAttendee(t, "hist4-")
+	token := tstValidAdminToken(t)
+
+	time.Sleep(5 * time.Millisecond)
+	beforeChanges := time.Now().UTC()
+	time.Sleep(5 * time.Millisecond)
+
+	first := att
+	first.Nickname = "First Change"
+	require.Equal(t, http.StatusOK, tstPerformPut(loc, tstRenderJson(first), token).status)
+
+	time.Sleep(5 * time.Millisecond)
+	betweenChanges := time.Now().UTC()
+	time.Sleep(5 * time.Millisecond)
+
+	second := first
+	second.Nickname = "Second Change"
+	second.Zip = "99999"
+	require.Equal(t, http.StatusOK, tstPerformPut(loc, tstRenderJson(second), token).status)
+
+	docs.When("when an admin requests the attendee as of before and between the changes")
+	beforeResponse := tstPerformGet(loc+"/history/as-of?timestamp="+beforeChanges.Format(time.RFC3339Nano), token)
+	betweenResponse := tstPerformGet(loc+"/history/as-of?timestamp="+betweenChanges.Format(time.RFC3339Nano), token)
+
+	docs.Then("then the requests are successful and return the attendee as it was at those times")
+	require.Equal(t, http.StatusOK, beforeResponse.status)
+	beforeDto := attendee.AttendeeDto{}
+	tstParseJson(beforeResponse.body, &beforeDto)
+	require.Equal(t, att, beforeDto)
+
+	require.Equal(t, http.StatusOK, betweenResponse.status)
+	betweenDto := attendee.AttendeeDto{}
+	tstParseJson(betweenResponse.body, &betweenDto)
+	require.Equal(t, first, betweenDto)
+
+	docs.Then("and the attendee itself is unchanged")
+	currentResponse := tstPerformGet(loc, token)
+	currentDto := attendee.AttendeeDto{}
+	tstParseJson(currentResponse.body, &currentDto)
+	require.Equal(t, "Second Change", currentDto.Nickname)
+}
+
+func TestAttendeeAsOf_BeforeRegistration(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee")
+	loc, _ := tstRegisterAttendee(t, "hist5-")
+
+	docs.When("when an admin requests the attendee as of a time before they registered")
+	response := tstPerformGet(loc+"/history/as-of?timestamp=2020-01-01T00:00:00Z", tstValidAdminToken(t))
+
+	docs.Then("then the request fails with the correct error")
+	tstRequireErrorResponse(t, response, http.StatusNotFound, "history.notfound", url.Values{"timestamp": {"the attendee did not exist at that time"}})
+}
+
+func TestAttendeeAsOf_InvalidTimestamp(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee")
+	loc, _ := tstRegisterAttendee(t, "hist6-")
+
+	docs.When("when an admin requests the attendee as of an invalid time")
+	response := tstPerformGet(loc+"/history/as-of?timestamp=yesterday", tstValidAdminToken(t))
+
+	docs.Then("then the request fails with the correct error")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "history.param.invalid", url.Values{"timestamp": {"must be a timestamp in RFC 3339 format, e.g. 2022-12-08T10:00:00Z"}})
+}
+
+func TestAttendeeAsOf_DenyUser(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee")
+	token := tstValidUserToken(t, 101)
+	loc, _ := tstRegisterAttendeeWithToken(t, "hist7-", token)
+
+	docs.When("when they attempt to read an earlier version of their registration")
+	response := tstPerformGet(loc+"/history/as-of?timestamp=2022-12-08T00:00:00Z", token)
+
+	docs.Then("then the request is denied as unauthorized (403) and the correct error is returned")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+}
+
+// --- restore ---
+
+func TestAttendeeRestore_AdminSuccess(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an approved attendee with a counted package")
+	loc, att := tstPkgStatRegisterAndProgressWithPackages(t, "hist8-", status.Approved, "mountain-trip", 1)
+	tstRequirePackageCount(t, "mountain-trip", counts.PackageCount{Attending: 1, Limit: 4})
+
+	docs.Given("given an admin has accidentally removed the package")
+	token := tstValidAdminToken(t)
+	wiped := att
+	tstOverridePackages(&wiped, "attendance,room-none,sponsor2,stage")
+	require.Equal(t, http.StatusOK, tstPerformPut(loc, tstRenderJson(wiped), token).status)
+	tstRequirePackageCount(t, "mountain-trip", counts.PackageCount{Attending: 0, Limit: 4})
+	before := tstReadHistory(t, loc).Entries
+	accidentalChange := tstFindHistoryEntry(t, before, "Packages")
+	transactionsBefore := len(paymentMock.Recording())
+
+	docs.When("when an admin restores the attendee to the state before the accidental change")
+	response := tstPerformPost(fmt.Sprintf("%s/history/%d/restore", loc, accidentalChange.Id), "", token)
+
+	docs.Then("then the request is successful and returns the restored attendee")
+	require.Equal(t, http.StatusOK, response.status)
+	restored := attendee.AttendeeDto{}
+	tstParseJson(response.body, &restored)
+	require.Equal(t, att.Packages, restored.Packages)
+
+	docs.Then("and the package is counted again")
+	tstRequirePackageCount(t, "mountain-trip", counts.PackageCount{Attending: 1, Limit: 4})
+
+	docs.Then("and the dues have been recalculated")
+	require.Equal(t, transactionsBefore+1, len(paymentMock.Recording()))
+	latestTransaction := paymentMock.Recording()[transactionsBefore]
+	require.Equal(t, paymentservice.Due, latestTransaction.TransactionType)
+	require.Equal(t, int64(3000), latestTransaction.Amount.GrossCent)
+
+	docs.Then("and the restore is recorded in the history")
+	after := tstReadHistory(t, loc).Entries
+	require.True(t, len(after) > len(before))
+	require.Contains(t, after[len(before)].Changes, history.FieldChange{Field: "Packages", OldValue: accidentalChange.Changes[0].NewValue, NewValue: accidentalChange.Changes[0].OldValue})
+}
+
+func TestAttendeeRestore_AdminInfo(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee whose admin info has been changed twice")
+	loc, _ := tstRegisterAttendee(t, "hist9-")
+	token := tstValidAdminToken(t)
+	require.Equal(t, http.StatusNoContent, tstPerformPut(loc+"/admin", tstRenderJson(admin.AdminInfoDto{Permissions: "sponsordesk"}), token).status)
+	require.Equal(t, http.StatusNoContent, tstPerformPut(loc+"/admin", tstRenderJson(admin.AdminInfoDto{Permissions: "regdesk"}), token).status)
+	before := tstReadHistory(t, loc)
+	require.Equal(t, 2, len(before.Entries))
+
+	docs.When("when an admin restores the attendee to the state before the second change")
+	response := tstPerformPost(fmt.Sprintf("%s/history/%d/restore", loc, before.Entries[1].Id), "", token)
+
+	docs.Then("then the request is successful and the admin info is back to the state after the first change")
+	require.Equal(t, http.StatusOK, response.status)
+	adminResponse := tstPerformGet(loc+"/admin", token)
+	adminInfo := admin.AdminInfoDto{}
+	tstParseJson(adminResponse.body, &adminInfo)
+	require.Equal(t, "sponsordesk", adminInfo.Permissions)
+}
+
+func TestAttendeeRestore_Overrun(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an approved attendee whose counted package was removed by an admin")
+	loc, att := tstPkgStatRegisterAndProgressWithPackages(t, "hist10-", status.Approved, "mountain-trip", 1)
+	token := tstValidAdminToken(t)
+	wiped := att
+	tstOverridePackages(&wiped, "attendance,room-none,sponsor2,stage")
+	require.Equal(t, http.StatusOK, tstPerformPut(loc, tstRenderJson(wiped), token).status)
+	before := tstReadHistory(t, loc).Entries
+
+	docs.Given("given the package has since sold out")
+	err := database.GetRepository().ResetCount(context.TODO(), &entity.Count{
+		Area:      entity.CountAreaPackage,
+		Name:      "mountain-trip",
+		Attending: 4,
+	})
+	require.NoError(t, err)
+
+	docs.When("when an admin attempts to restore the attendee to the state before the change")
+	response := tstPerformPost(fmt.Sprintf("%s/history/%d/restore", loc, tstFindHistoryEntry(t, before, "Packages").Id), "", token)
+
+	docs.Then("then the request fails with the correct error")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "attendee.package.overrun", url.Values{
+		"packages_list": []string{"cannot allocate package 'mountain-trip', stock limit reached - please remove this package to continue: this change introduces a package overrun"},
+	})
+
+	docs.Then("and the attendee is unchanged")
+	current := attendee.AttendeeDto{}
+	tstParseJson(tstPerformGet(loc, token).body, &current)
+	require.Equal(t, wiped.Packages, current.Packages)
+	require.Equal(t, len(before), len(tstReadHistory(t, loc).Entries))
+}
+
+func TestAttendeeRestore_AcrossDelete(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee whose nickname was changed, and who was then deleted")
+	loc, att := tstRegisterAttendee(t, "hist14-")
+	token := tstValidAdminToken(t)
+	changed := att
+	changed.Nickname = "Changed Nick"
+	require.Equal(t, http.StatusOK, tstPerformPut(loc, tstRenderJson(changed), token).status)
+	nicknameChange := tstFindHistoryEntry(t, tstReadHistory(t, loc).Entries, "Nickname")
+	response := tstPerformPost(loc+"/status", tstRenderJson(status.StatusChangeDto{Status: status.Deleted, Comment: "duplicate"}), token)
+	require.Equal(t, http.StatusNoContent, response.status)
+
+	docs.When("when an admin restores the attendee to the state before the nickname change")
+	response = tstPerformPost(fmt.Sprintf("%s/history/%d/restore", loc, nicknameChange.Id), "", token)
+
+	docs.Then("then the request is successful and the nickname is restored")
+	require.Equal(t, http.StatusOK, response.status)
+	restored := attendee.AttendeeDto{}
+	tstParseJson(response.body, &restored)
+	require.Equal(t, att.Nickname, restored.Nickname)
+
+	docs.Then("and the attendee is still deleted, matching its status")
+	tstVerifyStatus(t, loc, status.Deleted)
+	attd, err := database.GetRepository().GetAttendeeById(context.TODO(), restored.Id)
+	require.NoError(t, err)
+	require.True(t, attd.DeletedAt.Valid)
+}
+
+func TestAttendeeRestore_UnknownEntry(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given two attendees, one of which has been changed")
+	loc1, att1 := tstRegisterAttendee(t, "hist11a-")
+	loc2, _ := tstRegisterAttendeeWithToken(t, "hist11b-", tstValidUserToken(t, 101))
+	token := tstValidAdminToken(t)
+	changed := att1
+	changed.Nickname = "Changed Nick"
+	require.Equal(t, http.StatusOK, tstPerformPut(loc1, tstRenderJson(changed), token).status)
+	entries := tstReadHistory(t, loc1).Entries
+
+	docs.When("when an admin attempts to restore the other attendee using that history entry")
+	response := tstPerformPost(fmt.Sprintf("%s/history/%d/restore", loc2, entries[0].Id), "", token)
+
+	docs.Then("then the request fails with the correct error")
+	tstRequireErrorResponse(t, response, http.StatusNotFound, "history.notfound", url.Values{"historyId": {"no such history entry for this attendee"}})
+}
+
+func TestAttendeeRestore_InvalidEntry(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee")
+	loc, _ := tstRegisterAttendee(t, "hist12-")
+
+	docs.When("when an admin attempts a restore with an invalid history entry id")
+	response := tstPerformPost(loc+"/history/latest/restore", "", tstValidAdminToken(t))
+
+	docs.Then("then the request fails with the correct error")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "history.param.invalid", url.Values{"historyId": {"must be a positive integer"}})
+}
+
+func TestAttendeeRestore_DenyUser(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee")
+	token := tstValidUserToken(t, 101)
+	loc, _ := tstRegisterAttendeeWithToken(t, "hist13-", token)
+
+	docs.When("when they attempt to restore an earlier version of their registration")
+	response := tstPerformPost(loc+"/history/1/restore", "", token)
+
+	docs.Then("then the request is denied as unauthorized (403) and the correct error is returned")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+}
+
+func tstReadHistory(t *testing.T, location string) history.HistoryList {
+	response := tstPerformGet(location+"/history", tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, response.status)
+	result := history.HistoryList{}
+	tstParseJson(response.body, &result)
+	return result
+}
+
+func tstFindHistoryEntry(t *testing.T, entries []history.HistoryEntry, field string) history.HistoryEntry {
+	for _, entry := range entries {
+		for _, change := range entry.Changes {
+			if change.Field == field {
+				return entry
+			}
+		}
+	}
+	require.FailNow(t, "no history entry changes field "+field)
+	return history.HistoryEntry{}
+}
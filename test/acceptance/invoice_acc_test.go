@@ -0,0 +1,175 @@
+package acceptance
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------
+// acceptance tests for invoices
+// ------------------------------------------
+
+func tstApproveViaApi(t *testing.T, testcase string) (location string, att attendee.AttendeeDto) {
+	location, att = tstRegisterAttendeeWithToken(t, testcase, tstValidStaffToken(t, 1))
+	body := status.StatusChangeDto{
+		Status:  status.Approved,
+		Comment: testcase,
+	}
+	response := tstPerformPost(location+"/status", tstRenderJson(body), tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, response.status)
+	return
+}
+
+func tstReadInvoices(t *testing.T, location string, token string) []attendee.Invoice {
+	response := tstPerformGet(location+"/invoices", token)
+	require.Equal(t, http.StatusOK, response.status)
+	list := attendee.InvoiceList{}
+	tstParseJson(response.body, &list)
+	return list.Invoices
+}
+
+func TestInvoice_IssuedOnApproval(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee who has just been approved")
+	loc, _ := tstApproveViaApi(t, "inv1-")
+
+	docs.When("when the attendee reads their invoices")
+	invoices := tstReadInvoices(t, loc, tstValidStaffToken(t, 1))
+
+	docs.Then("then there is one invoice over the dues, with the packages as items and the vat broken down")
+	require.Equal(t, []attendee.Invoice{{
+		Number:    "EF2022-000001",
+		IssuedOn:  tstToday,
+		Recipient: []string{"Hans Mustermann", "Teststraße 24", "inv1-12345 Berlin", "Sachsen", "DE"},
+		Currency:  "EUR",
+		Items: []attendee.InvoiceItem{
+			{Description: "Entrance Fee (Convention Ticket)", Count: 1, Amount: 9000, VatPercent: 19},
+			{Description: "Supersponsor Upgrade", Count: 1, Amount: 16000, VatPercent: 19},
+			{Description: "Entrance Fee (Stage Ticket)", Count: 1, Amount: 500, VatPercent: 19},
+		},
+		VatBreakdown: []attendee.InvoiceVat{
+			{VatPercent: 19, Net: 21429, Vat: 4071, Gross: 25500},
+		},
+		Total: 25500,
+	}}, invoices)
+
+	docs.When("when the attendee downloads the invoice")
+	response := tstPerformGet(loc+"/invoices/EF2022-000001/pdf", tstValidStaffToken(t, 1))
+
+	docs.Then("then they receive it as a pdf document")
+	require.Equal(t, http.StatusOK, response.status)
+	require.Equal(t, "application/pdf", response.contentType)
+	require.True(t, strings.HasPrefix(response.body, "%PDF-1.4\n"))
+	require.Contains(t, response.body, "(Invoice EF2022-000001)")
+	require.Contains(t, response.body, "(255.00)")
+}
+
+func TestInvoice_SupersededOnPackageChange(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an approved attendee with an invoice")
+	loc, att := tstApproveViaApi(t, "inv2-")
+
+	docs.When("when an admin adds a package")
+	tstAddPackages(&att, "boat-trip")
+	updateResponse := tstPerformPut(loc, tstRenderJson(att), tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, updateResponse.status)
+
+	docs.Then("then a new invoice is issued that supersedes the first one")
+	invoices := tstReadInvoices(t, loc, tstValidAdminToken(t))
+	require.Equal(t, 2, len(invoices))
+	require.True(t, invoices[0].Superseded)
+	require.Equal(t, int64(25500), invoices[0].Total)
+	require.False(t, invoices[1].Superseded)
+	require.Equal(t, "EF2022-000002", invoices[1].Number)
+	require.Equal(t, int64(27500), invoices[1].Total)
+	require.Equal(t, attendee.InvoiceItem{Description: "Boat Trip", Count: 1, Amount: 2000, VatPercent: 19}, invoices[1].Items[1])
+
+	docs.When("when the admin saves the attendee again without changes")
+	updateResponse = tstPerformPut(loc, tstRenderJson(att), tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, updateResponse.status)
+
+	docs.Then("then no further invoice is issued")
+	require.Equal(t, 2, len(tstReadInvoices(t, loc, tstValidAdminToken(t))))
+}
+
+func TestInvoice_CancelledWithoutPayment(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an approved attendee with an invoice who has not paid anything")
+	loc, _ := tstApproveViaApi(t, "inv3-")
+
+	docs.When("when an admin cancels the registration")
+	body := status.StatusChangeDto{
+		Status:  status.Cancelled,
+		Comment: "inv3",
+	}
+	response := tstPerformPost(loc+"/status", tstRenderJson(body), tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, response.status)
+
+	docs.Then("then an invoice without items supersedes the first one")
+	invoices := tstReadInvoices(t, loc, tstValidAdminToken(t))
+	require.Equal(t, 2, len(invoices))
+	require.True(t, invoices[0].Superseded)
+	require.Equal(t, []attendee.InvoiceItem{}, invoices[1].Items)
+	require.Equal(t, []attendee.InvoiceVat{}, invoices[1].VatBreakdown)
+	require.Equal(t, int64(0), invoices[1].Total)
+}
+
+func TestInvoice_NoneWhileNew(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee in status new, who has no dues")
+	loc, _ := tstRegisterAttendeeWithToken(t, "inv4-", tstValidUserToken(t, 101))
+
+	docs.When("when the attendee reads their invoices")
+	invoices := tstReadInvoices(t, loc, tstValidUserToken(t, 101))
+
+	docs.Then("then there are none")
+	require.Equal(t, []attendee.Invoice{}, invoices)
+}
+
+func TestInvoice_DenyOther(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given two users, the second of which has been approved")
+	token1 := tstValidUserToken(t, 101)
+	loc, _ := tstApproveViaApi(t, "inv5-")
+
+	docs.When("when the first one attempts to read the invoices of the second one")
+	response := tstPerformGet(loc+"/invoices", token1)
+
+	docs.Then("then the request is denied")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized to access this data - the attempt has been logged")
+
+	docs.Then("and the same goes for the pdf")
+	pdfResponse := tstPerformGet(loc+"/invoices/EF2022-000001/pdf", token1)
+	tstRequireErrorResponse(t, pdfResponse, http.StatusForbidden, "auth.forbidden", "you are not authorized to access this data - the attempt has been logged")
+}
+
+func TestInvoice_PdfNotFound(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an approved attendee with an invoice")
+	loc, _ := tstApproveViaApi(t, "inv6-")
+
+	docs.When("when an admin requests an invoice number that was not issued to this attendee")
+	response := tstPerformGet(loc+"/invoices/EF2022-000099/pdf", tstValidAdminToken(t))
+
+	docs.Then("then the invoice is not found")
+	tstRequireErrorResponse(t, response, http.StatusNotFound, "invoice.notfound", url.Values{})
+}
@@ -0,0 +1,218 @@
+package acceptance
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/outbox"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/mailservice"
+	"github.com/stretchr/testify/require"
+)
+
+// --------------------------------------
+// acceptance tests for the mail outbox
+// --------------------------------------
+
+func TestOutbox_StatusChangeSucceedsWhileMailServiceDown(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an approved attendee")
+	loc, _ := tstRegisterAttendeeAndTransitionToStatus(t, "outbox1-", status.Approved)
+
+	docs.Given("given the mail service is unavailable")
+	mailMock.SimulateError(mailservice.DownstreamError)
+
+	docs.When("when an admin cancels their registration")
+	response := tstOutboxCancel(t, loc, "outbox1-")
+
+	docs.Then("then the status change is successful")
+	require.Equal(t, http.StatusNoContent, response.status)
+	tstVerifyStatus(t, loc, status.Cancelled)
+
+	docs.Then("and the mail is pending in the outbox with the error recorded")
+	mails := tstOutboxList(t, "pending")
+	require.Equal(t, 1, len(mails.Mails))
+	mail := mails.Mails[0]
+	require.Equal(t, tstIdFromLoc(loc), mail.AttendeeId)
+	require.Equal(t, "change-status-cancelled", mail.CommonID)
+	require.Equal(t, []string{"jsquirrel_github_9a6d@packetloss.de"}, mail.To)
+	require.Equal(t, "pending", mail.State)
+	require.Equal(t, 1, mail.Attempts)
+	require.Equal(t, mailservice.DownstreamError.Error(), mail.LastError)
+
+	docs.Then("and no failed mails are listed yet")
+	require.Empty(t, tstOutboxList(t, "").Mails)
+}
+
+func TestOutbox_RetrySendsMail(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given a status change mail that could not be delivered")
+	loc, _ := tstRegisterAttendeeAndTransitionToStatus(t, "outbox2-", status.Approved)
+	mailMock.SimulateError(mailservice.DownstreamError)
+	require.Equal(t, http.StatusNoContent, tstOutboxCancel(t, loc, "outbox2-").status)
+	pending := tstOutboxList(t, "pending")
+	require.Equal(t, 1, len(pending.Mails))
+
+	docs.Given("given the mail service is available again")
+	mailMock.Reset()
+
+	docs.When("when an admin retries the mail")
+	response := tstPerformPostNoBody(fmt.Sprintf("/api/rest/v1/outbox/%d/retry", pending.Mails[0].Id), tstValidAdminToken(t))
+
+	docs.Then("then the request is successful and the mail is marked as sent")
+	require.Equal(t, http.StatusOK, response.status)
+	retried := outbox.OutboxMail{}
+	tstParseJson(response.body, &retried)
+	require.Equal(t, "sent", retried.State)
+	require.Equal(t, 1, retried.Attempts)
+	require.Equal(t, "", retried.LastError)
+
+	docs.Then("and the mail was sent via the mail service")
+	require.Equal(t, 1, len(mailMock.Recording()))
+	require.Equal(t, "change-status-cancelled", mailMock.Recording()[0].CommonID)
+
+	docs.Then("and it is no longer pending")
+	require.Empty(t, tstOutboxList(t, "pending").Mails)
+	require.Equal(t, 1, len(tstOutboxList(t, "sent").Mails))
+}
+
+func TestOutbox_DiscardRemovesMail(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given a status change mail that could not be delivered")
+	loc, _ := tstRegisterAttendeeAndTransitionToStatus(t, "outbox3-", status.Approved)
+	mailMock.SimulateError(mailservice.DownstreamError)
+	require.Equal(t, http.StatusNoContent, tstOutboxCancel(t, loc, "outbox3-").status)
+	pending := tstOutboxList(t, "pending")
+	require.Equal(t, 1, len(pending.Mails))
+	mailUrl := fmt.Sprintf("/api/rest/v1/outbox/%d", pending.Mails[0].Id)
+
+	docs.When("when an admin discards the mail")
+	response := tstPerformDelete(mailUrl, tstValidAdminToken(t))
+
+	docs.Then("then the request is successful")
+	require.Equal(t, http.StatusNoContent, response.status)
+
+	docs.Then("and the mail is gone from the outbox")
+	require.Empty(t, tstOutboxList(t, "pending").Mails)
+	readAgain := tstPerformGet(mailUrl, tstValidAdminToken(t))
+	tstRequireErrorResponse(t, readAgain, http.StatusNotFound, "outbox.id.notfound", nil)
+}
+
+func TestOutbox_SentMailCannotBeRetriedOrDiscarded(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given a status change mail that was delivered successfully")
+	loc, _ := tstRegisterAttendeeAndTransitionToStatus(t, "outbox4-", status.Approved)
+	require.Equal(t, http.StatusNoContent, tstOutboxCancel(t, loc, "outbox4-").status)
+	sent := tstOutboxList(t, "sent")
+	require.Equal(t, 1, len(sent.Mails))
+	mailUrl := fmt.Sprintf("/api/rest/v1/outbox/%d", sent.Mails[0].Id)
+	mailMock.Reset()
+
+	docs.When("when an admin attempts to retry or discard the mail")
+	retryResponse := tstPerformPostNoBody(mailUrl+"/retry", tstValidAdminToken(t))
+	discardResponse := tstPerformDelete(mailUrl, tstValidAdminToken(t))
+
+	docs.Then("then both requests are rejected with the correct error")
+	tstRequireErrorResponse(t, retryResponse, http.StatusConflict, "outbox.mail.sent", url.Values{"state": {"this mail has already been sent"}})
+	tstRequireErrorResponse(t, discardResponse, http.StatusConflict, "outbox.mail.sent", url.Values{"state": {"this mail has already been sent"}})
+
+	docs.Then("and no mail was sent again")
+	require.Empty(t, mailMock.Recording())
+}
+
+func TestOutbox_SendingMailCannotBeRetriedOrDiscarded(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given a status change mail that could not be delivered")
+	loc, _ := tstRegisterAttendeeAndTransitionToStatus(t, "outbox5-", status.Approved)
+	mailMock.SimulateError(mailservice.DownstreamError)
+	require.Equal(t, http.StatusNoContent, tstOutboxCancel(t, loc, "outbox5-").status)
+	pending := tstOutboxList(t, "pending")
+	require.Equal(t, 1, len(pending.Mails))
+	mailUrl := fmt.Sprintf("/api/rest/v1/outbox/%d", pending.Mails[0].Id)
+	mailMock.Reset()
+
+	docs.Given("given another instance is making a delivery attempt right now")
+	mail, err := database.GetRepository().GetOutboxMailById(context.TODO(), pending.Mails[0].Id)
+	require.Nil(t, err)
+	claimed, err := database.GetRepository().ClaimOutboxMail(context.TODO(), mail, time.Now(), time.Now().Add(time.Hour))
+	require.Nil(t, err)
+	require.True(t, claimed)
+
+	docs.When("when an admin attempts to retry or discard the mail")
+	retryResponse := tstPerformPostNoBody(mailUrl+"/retry", tstValidAdminToken(t))
+	discardResponse := tstPerformDelete(mailUrl, tstValidAdminToken(t))
+
+	docs.Then("then both requests are rejected with the correct error")
+	tstRequireErrorResponse(t, retryResponse, http.StatusConflict, "outbox.mail.sending", url.Values{"state": {"this mail is being sent right now"}})
+	tstRequireErrorResponse(t, discardResponse, http.StatusConflict, "outbox.mail.sending", url.Values{"state": {"this mail is being sent right now"}})
+
+	docs.Then("and no mail was sent again")
+	require.Empty(t, mailMock.Recording())
+	require.Equal(t, 1, len(tstOutboxList(t, "sending").Mails))
+}
+
+func TestOutbox_DenyUser(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given a regular user")
+	token := tstValidUserToken(t, 101)
+
+	docs.When("when they attempt to list the outbox")
+	response := tstPerformGet("/api/rest/v1/outbox", token)
+
+	docs.Then("then the request is denied as unauthorized (403) and the correct error is returned")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+}
+
+func TestOutbox_InvalidState(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an admin")
+	token := tstValidAdminToken(t)
+
+	docs.When("when they attempt to list the outbox with an invalid state")
+	response := tstPerformGet("/api/rest/v1/outbox?state=exploded", token)
+
+	docs.Then("then the request fails with the correct error")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "outbox.state.invalid", url.Values{"state": {"must be one of pending, sending, sent, failed"}})
+}
+
+// --- helpers ---
+
+func tstOutboxCancel(t *testing.T, loc string, testcase string) tstWebResponse {
+	body := status.StatusChangeDto{
+		Status:  status.Cancelled,
+		Comment: testcase,
+	}
+	return tstPerformPost(loc+"/status", tstRenderJson(body), tstValidAdminToken(t))
+}
+
+func tstOutboxList(t *testing.T, state string) outbox.OutboxMailList {
+	requestUrl := "/api/rest/v1/outbox"
+	if state != "" {
+		requestUrl += "?state=" + state
+	}
+	response := tstPerformGet(requestUrl, tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, response.status)
+	result := outbox.OutboxMailList{}
+	tstParseJson(response.body, &result)
+	return result
+}
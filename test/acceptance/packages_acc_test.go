@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
 	"testing"
 
@@ -11,8 +12,10 @@ import (
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/counts"
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/waitlist"
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
 	"github.com/stretchr/testify/require"
 )
 
@@ -243,6 +246,469 @@ func TestPackageLimitStatusTransitions(t *testing.T) {
 	}
 }
 
+// --- getPackageWaitlist ---
+
+func TestPackageWaitlistDenyWhileNotLoggedIn(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given a user who is not logged in")
+
+	docs.When("when they attempt to read a package waiting list while not logged in")
+	response := tstPerformGet("/api/rest/v1/packages/boat-trip/waitlist", tstNoToken())
+
+	docs.Then("then the request is denied")
+	tstRequireErrorResponse(t, response, http.StatusUnauthorized, "auth.unauthorized", "you must be logged in for this operation")
+}
+
+func TestPackageWaitlistNotConfigured(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given a user who is logged in")
+	token := tstValidUserToken(t, 101)
+
+	docs.When("when they try to read the waiting list for a limited package without a waiting list")
+	response := tstPerformGet("/api/rest/v1/packages/mountain-trip/waitlist", token)
+
+	docs.Then("then the request fails with the expected error")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "package.param.nowaitlist", "this package does not have a waiting list")
+}
+
+func TestPackageWaitlistRegistrationIsQueued(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given a counted package with a waiting list has already sold out")
+	tstPkgWaitlistSoldOut(t)
+
+	docs.When("when a logged in user registers, adding the sold out package")
+	token := tstValidUserToken(t, 101)
+	loc := tstPkgWaitlistRegister(t, "pwl1-", token)
+
+	docs.Then("then the registration is placed in status waiting")
+	tstVerifyStatus(t, loc, status.Waiting)
+
+	docs.Then("and they receive the waiting status mail")
+	require.Equal(t, 1, len(mailMock.Recording()))
+	require.Equal(t, "change-status-waiting", mailMock.Recording()[0].CommonID)
+
+	docs.Then("and the package counts have not been changed")
+	tstRequirePackageCount(t, "boat-trip", counts.PackageCount{Attending: 4, Limit: 4})
+
+	docs.Then("and they can see their position in the waiting list")
+	tstRequirePackageWaitlist(t, token, waitlist.Waitlist{
+		Package: "boat-trip",
+		Length:  1,
+		Entries: []waitlist.WaitlistEntry{
+			{Position: 1, Id: tstIdFromLoc(loc), Count: 1},
+		},
+	})
+}
+
+func TestPackageWaitlistOtherUserOnlySeesLength(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given a counted package with a waiting list has already sold out")
+	tstPkgWaitlistSoldOut(t)
+
+	docs.Given("given a registration that has been placed on the waiting list")
+	_ = tstPkgWaitlistRegister(t, "pwl2-", tstValidUserToken(t, 101))
+
+	docs.When("when a different logged in user reads the waiting list")
+	docs.Then("then they only see the length of the queue, but no entries")
+	tstRequirePackageWaitlist(t, tstValidUserToken(t, 102), waitlist.Waitlist{
+		Package: "boat-trip",
+		Length:  1,
+		Entries: []waitlist.WaitlistEntry{},
+	})
+}
+
+func TestPackageWaitlistPromotionOnCancellation(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an approved attendee with a counted package that has a waiting list")
+	firstLoc, _ := tstPkgStatRegisterAndProgressWithPackages(t, "pwl3a-", status.Approved, "boat-trip", 1)
+
+	docs.Given("given the package has sold out")
+	tstPkgWaitlistSoldOut(t)
+
+	docs.Given("given two more registrations that have been placed on the waiting list")
+	secondLoc := tstPkgWaitlistRegister(t, "pwl3b-", tstValidUserToken(t, 101))
+	thirdLoc := tstPkgWaitlistRegister(t, "pwl3c-", tstValidUserToken(t, 102))
+	mailMock.Reset()
+
+	docs.When("when an admin cancels the approved attendee")
+	body := status.StatusChangeDto{
+		Status:  status.Cancelled,
+		Comment: "pwl3",
+	}
+	response := tstPerformPost(firstLoc+"/status", tstRenderJson(body), tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, response.status)
+
+	docs.Then("then the first registration in the waiting list is approved automatically")
+	tstVerifyStatus(t, secondLoc, status.Approved)
+	tstVerifyStatus(t, thirdLoc, status.Waiting)
+
+	docs.Then("and the cancellation and approval mails have been sent")
+	require.Equal(t, 2, len(mailMock.Recording()))
+	require.Equal(t, "change-status-cancelled", mailMock.Recording()[0].CommonID)
+	require.Equal(t, "change-status-approved", mailMock.Recording()[1].CommonID)
+	require.Equal(t, fmt.Sprintf("%d", tstIdFromLoc(secondLoc)), mailMock.Recording()[1].Variables["badge_number"])
+
+	docs.Then("and the package is sold out again")
+	tstRequirePackageCount(t, "boat-trip", counts.PackageCount{Attending: 4, Limit: 4})
+
+	docs.Then("and the remaining registration has moved up in the waiting list")
+	tstRequirePackageWaitlist(t, tstValidAdminToken(t), waitlist.Waitlist{
+		Package: "boat-trip",
+		Length:  1,
+		Entries: []waitlist.WaitlistEntry{
+			{Position: 1, Id: tstIdFromLoc(thirdLoc), Count: 1},
+		},
+	})
+}
+
+func TestPackageWaitlistCancelledWhileQueued(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given a counted package with a waiting list has already sold out")
+	tstPkgWaitlistSoldOut(t)
+
+	docs.Given("given a registration that has been placed on the waiting list")
+	loc := tstPkgWaitlistRegister(t, "pwl4-", tstValidUserToken(t, 101))
+
+	docs.When("when an admin cancels the queued registration")
+	body := status.StatusChangeDto{
+		Status:  status.Cancelled,
+		Comment: "pwl4",
+	}
+	response := tstPerformPost(loc+"/status", tstRenderJson(body), tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, response.status)
+
+	docs.Then("then the package counts have not been changed")
+	tstRequirePackageCount(t, "boat-trip", counts.PackageCount{Attending: 4, Limit: 4})
+
+	docs.Then("and the registration has been removed from the waiting list")
+	tstRequirePackageWaitlist(t, tstValidApiToken(), waitlist.Waitlist{
+		Package: "boat-trip",
+		Length:  0,
+		Entries: []waitlist.WaitlistEntry{},
+	})
+}
+
+// --- age based prices and limits ---
+
+func TestPackageAgeBasedPrice(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee who is old enough for the senior rate of the convention ticket")
+	token := tstValidUserToken(t, 101)
+	dto := tstBuildValidAttendee("age1-")
+	dto.Birthday = "1958-08-16" // turns 65 on the reference date
+	creationResponse := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(dto), token)
+	require.Equal(t, http.StatusCreated, creationResponse.status, "unexpected http response status")
+
+	docs.When("when an admin approves the registration")
+	body := status.StatusChangeDto{
+		Status:  status.Approved,
+		Comment: "age1-approve",
+	}
+	response := tstPerformPost(creationResponse.location+"/status", tstRenderJson(body), tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, response.status)
+
+	docs.Then("then the dues are booked with the senior rate")
+	require.Equal(t, 1, len(paymentMock.Recording()))
+	duesTx := paymentMock.Recording()[0]
+	require.Equal(t, int64(6000+500+16000), duesTx.Amount.GrossCent)
+	require.Equal(t, "dues adjustment due to change in status or selected packages - age based prices: Entrance Fee (Convention Ticket): Senior Rate", duesTx.Comment)
+
+	docs.Then("and the status mail mentions the age based price")
+	require.Equal(t, 1, len(mailMock.Recording()))
+	require.Equal(t, "EUR 225.00", mailMock.Recording()[0].Variables["total_dues"])
+	require.Equal(t, "Entrance Fee (Convention Ticket): Senior Rate", mailMock.Recording()[0].Variables["age_based_prices"])
+}
+
+func TestPackageAgeLimitDeny(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given a user who is too old for the mountain trip")
+	token := tstValidUserToken(t, 101)
+	dto := tstBuildValidAttendee("age2-")
+	dto.Birthday = "1947-08-15" // turned 76 the day before the reference date
+	tstAddPackages(&dto, "mountain-trip")
+
+	docs.When("when they attempt to register with the mountain trip")
+	response := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(dto), token)
+
+	docs.Then("then the registration is rejected with an appropriate error")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "attendee.data.invalid", url.Values{
+		"packages": []string{"cannot pick package mountain-trip: only available to attendees aged 75 or younger on 2023-08-16"},
+	})
+}
+
+func TestPackageAgeLimitAllowOnBoundary(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given a user who is exactly at the age limit for the mountain trip")
+	token := tstValidUserToken(t, 101)
+	dto := tstBuildValidAttendee("age3-")
+	dto.Birthday = "1947-08-17" // still 75 on the reference date
+	tstAddPackages(&dto, "mountain-trip")
+
+	docs.When("when they register with the mountain trip")
+	response := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(dto), token)
+
+	docs.Then("then the registration is successful")
+	require.Equal(t, http.StatusCreated, response.status, "unexpected http response status")
+}
+
+// --- price schedules ---
+
+func TestPackagePriceLockedAtFirstBooking(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee who booked the supersponsor upgrade before its price went up")
+	token := tstValidUserToken(t, 101)
+	creationResponse := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(tstBuildValidAttendee("sched1-")), token)
+	require.Equal(t, http.StatusCreated, creationResponse.status, "unexpected http response status")
+
+	docs.When("when an admin approves the registration after the price went up")
+	tstToday = "2023-02-01"
+	body := status.StatusChangeDto{
+		Status:  status.Approved,
+		Comment: "sched1-approve",
+	}
+	response := tstPerformPost(creationResponse.location+"/status", tstRenderJson(body), tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, response.status)
+
+	docs.Then("then the dues are booked with the price at the time of booking")
+	require.Equal(t, 1, len(paymentMock.Recording()))
+	require.Equal(t, int64(9000+500+16000), paymentMock.Recording()[0].Amount.GrossCent)
+}
+
+func TestPackagePriceLateBookingNotChangedRetroactively(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an approved attendee who booked the supersponsor upgrade after its price went up")
+	tstToday = "2023-02-01"
+	token := tstValidUserToken(t, 101)
+	dto := tstBuildValidAttendee("sched2-")
+	creationResponse := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(dto), token)
+	require.Equal(t, http.StatusCreated, creationResponse.status, "unexpected http response status")
+	body := status.StatusChangeDto{
+		Status:  status.Approved,
+		Comment: "sched2-approve",
+	}
+	response := tstPerformPost(creationResponse.location+"/status", tstRenderJson(body), tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, response.status)
+	require.Equal(t, 1, len(paymentMock.Recording()))
+	require.Equal(t, int64(9000+500+18000), paymentMock.Recording()[0].Amount.GrossCent)
+
+	docs.When("when the dues are recalculated on a date when a different price would apply")
+	tstToday = "2022-12-20"
+	tstAddPackages(&dto, "boat-trip")
+	updateResponse := tstPerformPut(creationResponse.location, tstRenderJson(dto), token)
+	require.Equal(t, http.StatusOK, updateResponse.status, "unexpected http response status")
+
+	docs.Then("then only the newly added package is added to the dues")
+	require.Equal(t, 2, len(paymentMock.Recording()))
+	require.Equal(t, int64(2000), paymentMock.Recording()[1].Amount.GrossCent)
+}
+
+// --- payment plans ---
+
+func TestPackagePaymentPlanInstallments(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an approved attendee who booked the sponsor upgrade, which is payable in installments")
+	token := tstValidUserToken(t, 101)
+	dto := tstBuildValidAttendee("plan1-")
+	dto.Packages = strings.Replace(dto.Packages, "sponsor2", "sponsor", 1)
+	dto.PackagesList = tstPackagesListFromPackages(dto.Packages)
+	creationResponse := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(dto), token)
+	require.Equal(t, http.StatusCreated, creationResponse.status, "unexpected http response status")
+	body := status.StatusChangeDto{
+		Status:  status.Approved,
+		Comment: "plan1-approve",
+	}
+	response := tstPerformPost(creationResponse.location+"/status", tstRenderJson(body), tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, response.status)
+	require.Equal(t, 1, len(paymentMock.Recording()))
+	require.Equal(t, int64(9000+500+6500), paymentMock.Recording()[0].Amount.GrossCent)
+
+	docs.Then("then the attendee can see their installments")
+	tstRequireInstallments(t, creationResponse.location, token, "2022-12-22", []attendee.Installment{
+		{DueDate: "2022-12-22", Amount: 9500 + 2600, Open: 9500 + 2600},
+		{DueDate: "2023-02-01", Amount: 1950, Open: 1950},
+		{DueDate: "2023-04-01", Amount: 1950, Open: 1950},
+	})
+
+	docs.When("when they pay less than the first installment")
+	tstInjectPaymentAndNotify(t, creationResponse.location, 5000)
+
+	docs.Then("then the registration is not yet partially paid")
+	tstRequireAttendeeStatus(t, status.Approved, tstPerformGet(creationResponse.location+"/status", token).body)
+
+	docs.When("when they pay the rest of the first installment")
+	tstInjectPaymentAndNotify(t, creationResponse.location, 7100)
+
+	docs.Then("then the registration is partially paid, and the next installment is due")
+	tstRequireAttendeeStatus(t, status.PartiallyPaid, tstPerformGet(creationResponse.location+"/status", token).body)
+	tstRequireInstallments(t, creationResponse.location, token, "2023-02-01", []attendee.Installment{
+		{DueDate: "2022-12-22", Amount: 9500 + 2600, Open: 0},
+		{DueDate: "2023-02-01", Amount: 1950, Open: 1950},
+		{DueDate: "2023-04-01", Amount: 1950, Open: 1950},
+	})
+
+	docs.Then("and the status mail asks for the next installment only")
+	mail := mailMock.Recording()[len(mailMock.Recording())-1]
+	require.Equal(t, "change-status-partially paid", mail.CommonID)
+	require.Equal(t, "EUR 39.00", mail.Variables["remaining_dues"])
+	require.Equal(t, "EUR 19.50", mail.Variables["installment_due"])
+	require.Equal(t, "01.02.2023", mail.Variables["due_date"])
+}
+
+// --- currencies ---
+
+func TestPackagePricesInBillingCurrency(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee who chose to pay in GBP at registration")
+	token := tstValidUserToken(t, 101)
+	dto := tstBuildValidAttendee("curr1-")
+	dto.Currency = "GBP"
+	creationResponse := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(dto), token)
+	require.Equal(t, http.StatusCreated, creationResponse.status, "unexpected http response status")
+
+	docs.When("when an admin approves the registration")
+	body := status.StatusChangeDto{
+		Status:  status.Approved,
+		Comment: "curr1-approve",
+	}
+	response := tstPerformPost(creationResponse.location+"/status", tstRenderJson(body), tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, response.status)
+
+	docs.Then("then the dues are booked with the GBP prices, in GBP")
+	require.Equal(t, 1, len(paymentMock.Recording()))
+	duesTx := paymentMock.Recording()[0]
+	require.Equal(t, "GBP", duesTx.Amount.Currency)
+	require.Equal(t, int64(7800+450+13800), duesTx.Amount.GrossCent)
+
+	docs.Then("and the status mail shows the dues in GBP")
+	require.Equal(t, 1, len(mailMock.Recording()))
+	require.Equal(t, "GBP 220.50", mailMock.Recording()[0].Variables["total_dues"])
+}
+
+func TestPackageCurrencyCannotBeChanged(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee who chose to pay in GBP at registration")
+	token := tstValidUserToken(t, 101)
+	dto := tstBuildValidAttendee("curr2-")
+	dto.Currency = "GBP"
+	creationResponse := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(dto), token)
+	require.Equal(t, http.StatusCreated, creationResponse.status, "unexpected http response status")
+
+	docs.When("when they attempt to switch to EUR")
+	dto.Currency = "EUR"
+	response := tstPerformPut(creationResponse.location, tstRenderJson(dto), token)
+
+	docs.Then("then the update is rejected with an appropriate error")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "attendee.data.invalid", url.Values{
+		"currency": []string{"currency cannot be changed after registration"},
+	})
+}
+
+func TestPackageUnknownCurrency(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.When("when a user attempts to register with a currency that is not configured")
+	token := tstValidUserToken(t, 101)
+	dto := tstBuildValidAttendee("curr3-")
+	dto.Currency = "USD"
+	response := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(dto), token)
+
+	docs.Then("then the registration is rejected with an appropriate error")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "attendee.data.invalid", url.Values{
+		"currency": []string{"currency field must be one of EUR,GBP or it can be left blank, which counts as EUR"},
+	})
+}
+
+func TestPackagePaymentInOtherCurrencyIsRefused(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an approved attendee who chose to pay in GBP at registration")
+	token := tstValidUserToken(t, 101)
+	dto := tstBuildValidAttendee("curr4-")
+	dto.Currency = "GBP"
+	creationResponse := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(dto), token)
+	require.Equal(t, http.StatusCreated, creationResponse.status, "unexpected http response status")
+	body := status.StatusChangeDto{
+		Status:  status.Approved,
+		Comment: "curr4-approve",
+	}
+	response := tstPerformPost(creationResponse.location+"/status", tstRenderJson(body), tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, response.status)
+
+	docs.When("when a payment in EUR arrives for them")
+	attid := tstIdFromLocation(creationResponse.location)
+	_ = paymentMock.InjectTransaction(context.Background(), tstCreateTransaction(attid, paymentservice.Payment, 22050))
+	response = tstPerformPost(creationResponse.location+"/payments-changed", "", tstValidApiToken())
+
+	docs.Then("then the balances are not calculated and an appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "status.currency.mismatch",
+		"there are transactions in a currency other than the billing currency of the attendee, please correct them in the payment service: found EUR, expected GBP")
+
+	docs.Then("and the status is unchanged")
+	tstRequireAttendeeStatus(t, status.Approved, tstPerformGet(creationResponse.location+"/status", token).body)
+}
+
+func tstInjectPaymentAndNotify(t *testing.T, location string, amount int64) {
+	attid := tstIdFromLocation(location)
+	_ = paymentMock.InjectTransaction(context.Background(), tstCreateTransaction(attid, paymentservice.Payment, amount))
+	response := tstPerformPost(location+"/payments-changed", "", tstValidApiToken())
+	require.Equal(t, http.StatusNoContent, response.status)
+}
+
+func tstRequireInstallments(t *testing.T, location string, token string, expectedDueDate string, expected []attendee.Installment) {
+	t.Helper()
+	response := tstPerformGet(location+"/due-date", token)
+	require.Equal(t, http.StatusOK, response.status)
+	actual := attendee.DueDate{}
+	tstParseJson(response.body, &actual)
+	require.Equal(t, attendee.DueDate{DueDate: expectedDueDate, Installments: expected}, actual)
+}
+
 // other tests are baked into various reg and status cases - find usages on this function to find them
 
 func tstRequirePackageCount(t *testing.T, pkg string, expected counts.PackageCount) {
@@ -283,3 +749,39 @@ func tstPkgStatRegisterAndProgressWithPackages(t *testing.T, testcase string, ta
 
 	return creationResponse.location, dto
 }
+
+func tstPkgWaitlistSoldOut(t *testing.T) {
+	// simulated by database manipulation
+	current, err := database.GetRepository().GetCount(context.TODO(), entity.CountAreaPackage, "boat-trip")
+	require.NoError(t, err)
+	err = database.GetRepository().ResetCount(context.TODO(), &entity.Count{
+		Area:      entity.CountAreaPackage,
+		Name:      "boat-trip",
+		Pending:   current.Pending,
+		Attending: 4 - current.Pending,
+	})
+	require.NoError(t, err)
+}
+
+func tstPkgWaitlistRegister(t *testing.T, testcase string, token string) string {
+	dto := tstBuildValidAttendee(testcase)
+	tstAddPackages(&dto, "boat-trip")
+	creationResponse := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(dto), token)
+	require.Equal(t, http.StatusCreated, creationResponse.status, "unexpected http response status")
+	return creationResponse.location
+}
+
+func tstRequirePackageWaitlist(t *testing.T, token string, expected waitlist.Waitlist) {
+	t.Helper()
+
+	response := tstPerformGet("/api/rest/v1/packages/"+expected.Package+"/waitlist", token)
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+
+	actual := waitlist.Waitlist{}
+	tstParseJson(response.body, &actual)
+	for i := range actual.Entries {
+		require.NotEmpty(t, actual.Entries[i].Since)
+		actual.Entries[i].Since = ""
+	}
+	require.EqualValues(t, expected, actual, "unexpected waiting list in response")
+}
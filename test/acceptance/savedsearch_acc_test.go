@@ -0,0 +1,258 @@
+package acceptance
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/admin"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/savedsearch"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------
+// acceptance tests for the saved search api
+// ------------------------------------------
+
+const tstSavedSearchUrl = "/api/rest/v1/searches"
+
+func TestSavedSearch_AdminCrud(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.When("when an admin saves a new search")
+	search := tstBuildValidSavedSearch("regdesk")
+	response := tstPerformPut(tstSavedSearchUrl+"/approved-sponsors", tstRenderJson(search), tstValidAdminToken(t))
+
+	docs.Then("then the search is created")
+	require.Equal(t, http.StatusCreated, response.status)
+	require.Equal(t, tstSavedSearchUrl+"/approved-sponsors", response.location)
+
+	docs.Then("and it can be read back, with its name filled in")
+	readResponse := tstPerformGet(tstSavedSearchUrl+"/approved-sponsors", tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, readResponse.status)
+	actual := savedsearch.SavedSearchDto{}
+	tstParseJson(readResponse.body, &actual)
+	search.Name = "approved-sponsors"
+	require.Equal(t, search, actual)
+
+	docs.When("when the admin updates the search")
+	search.Description = "changed"
+	updateResponse := tstPerformPut(tstSavedSearchUrl+"/approved-sponsors", tstRenderJson(search), tstValidAdminToken(t))
+
+	docs.Then("then the update is successful and shows up in the list of searches")
+	require.Equal(t, http.StatusNoContent, updateResponse.status)
+	listResponse := tstPerformGet(tstSavedSearchUrl, tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, listResponse.status)
+	list := savedsearch.SavedSearchList{}
+	tstParseJson(listResponse.body, &list)
+	require.Equal(t, savedsearch.SavedSearchList{Searches: []savedsearch.SavedSearchDto{search}}, list)
+
+	docs.When("when the admin deletes the search")
+	deleteResponse := tstPerformDelete(tstSavedSearchUrl+"/approved-sponsors", tstValidAdminToken(t))
+
+	docs.Then("then it is gone")
+	require.Equal(t, http.StatusNoContent, deleteResponse.status)
+	rereadResponse := tstPerformGet(tstSavedSearchUrl+"/approved-sponsors", tstValidAdminToken(t))
+	tstRequireErrorResponse(t, rereadResponse, http.StatusNotFound, "search.name.notfound", url.Values{})
+}
+
+func TestSavedSearch_RunAdmin(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an approved and a new attendee, and a saved search for approved attendees")
+	_, att1 := tstRegisterAttendeeAndTransitionToStatus(t, "saved2a-", status.Approved)
+	_, _ = tstBulkRegister(t, "saved2b-", tstValidUserToken(t, 101), "")
+	tstCreateSavedSearch(t, "approved", tstBuildValidSavedSearch(""))
+
+	docs.When("when an admin runs the search")
+	response := tstPerformPostNoBody(tstSavedSearchUrl+"/approved/run", tstValidAdminToken(t))
+
+	docs.Then("then the request is successful and only the approved attendee is returned")
+	require.Equal(t, http.StatusOK, response.status)
+	result := attendee.AttendeeSearchResultList{}
+	tstParseJson(response.body, &result)
+	require.Equal(t, []uint{att1.Id}, tstSearchResultIds(result))
+}
+
+func TestSavedSearch_RunPaged(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given two approved attendees and a saved search for approved attendees")
+	_, att1 := tstRegisterAttendeeAndTransitionToStatus(t, "saved3a-", status.Approved)
+	loc2, att2 := tstBulkRegister(t, "saved3b-", tstValidUserToken(t, 101), "")
+	statusResponse := tstPerformPost(loc2+"/status", tstRenderJson(status.StatusChangeDto{Status: status.Approved, Comment: "saved3"}), tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, statusResponse.status)
+	tstCreateSavedSearch(t, "approved", tstBuildValidSavedSearch(""))
+
+	docs.When("when an admin runs the search one result at a time")
+	response := tstPerformPostNoBody(tstSavedSearchUrl+"/approved/run?num_results=1", tstValidAdminToken(t))
+
+	docs.Then("then the first page contains the first attendee and a cursor for the next page")
+	require.Equal(t, http.StatusOK, response.status)
+	page := attendee.AttendeeSearchResultList{}
+	tstParseJson(response.body, &page)
+	require.Equal(t, 1, len(page.Attendees))
+	require.Equal(t, att1.Id, page.Attendees[0].Id)
+	require.NotEmpty(t, page.Next)
+
+	docs.Then("and the second page contains the second attendee")
+	response = tstPerformPostNoBody(tstSavedSearchUrl+"/approved/run?num_results=1&cursor="+url.QueryEscape(page.Next), tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, response.status)
+	page = attendee.AttendeeSearchResultList{}
+	tstParseJson(response.body, &page)
+	require.Equal(t, []uint{att2.Id}, tstSearchResultIds(page))
+}
+
+func TestSavedSearch_RunCsv(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an approved attendee and a saved search for approved attendees")
+	_, att1 := tstRegisterAttendeeAndTransitionToStatus(t, "saved4-", status.Approved)
+	tstCreateSavedSearch(t, "approved", tstBuildValidSavedSearch(""))
+
+	docs.When("when an admin runs the search, asking for csv")
+	response := tstPerformPostWithAccept(tstSavedSearchUrl+"/approved/run", "", tstValidAdminToken(t), media.ContentTypeTextCsv)
+
+	docs.Then("then the request is successful and the result is returned as csv")
+	require.Equal(t, http.StatusOK, response.status)
+	require.Equal(t, media.ContentTypeTextCsv, response.contentType)
+	rows := tstParseCsv(t, response.body)
+	require.Equal(t, 2, len(rows))
+	require.Equal(t, att1.Nickname, rows[1][2])
+}
+
+func TestSavedSearch_RunWithPermission(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an approved attendee who has been given the sponsordesk permission")
+	loc, att := tstRegisterAttendeeAndTransitionToStatus(t, "saved5-", status.Approved)
+	permissionResponse := tstPerformPut(loc+"/admin", tstRenderJson(admin.AdminInfoDto{Permissions: "sponsordesk"}), tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, permissionResponse.status)
+
+	docs.Given("given one saved search that requires the sponsordesk permission, and one that requires regdesk")
+	tstCreateSavedSearch(t, "sponsors", tstBuildValidSavedSearch("sponsordesk,regdesk"))
+	tstCreateSavedSearch(t, "regdesk-only", tstBuildValidSavedSearch("regdesk"))
+	token := tstValidUserToken(t, att.Id)
+
+	docs.When("when they list the saved searches")
+	listResponse := tstPerformGet(tstSavedSearchUrl, token)
+
+	docs.Then("then they only see the search they may run")
+	require.Equal(t, http.StatusOK, listResponse.status)
+	list := savedsearch.SavedSearchList{}
+	tstParseJson(listResponse.body, &list)
+	require.Equal(t, 1, len(list.Searches))
+	require.Equal(t, "sponsors", list.Searches[0].Name)
+
+	docs.When("when they run that search")
+	response := tstPerformPostNoBody(tstSavedSearchUrl+"/sponsors/run", token)
+
+	docs.Then("then the request is successful, but they get a limited set of fields")
+	require.Equal(t, http.StatusOK, response.status)
+	result := attendee.AttendeeSearchResultList{}
+	tstParseJson(response.body, &result)
+	require.Equal(t, 1, len(result.Attendees))
+	require.Equal(t, att.Id, result.Attendees[0].Id)
+	require.Nil(t, result.Attendees[0].Email)
+
+	docs.When("when they attempt to run the other search")
+	deniedResponse := tstPerformPostNoBody(tstSavedSearchUrl+"/regdesk-only/run", token)
+
+	docs.Then("then the request is denied as unauthorized (403) and the correct error is returned")
+	tstRequireErrorResponse(t, deniedResponse, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+}
+
+func TestSavedSearch_DenyUserWrite(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.When("when a regular user attempts to save a search")
+	response := tstPerformPut(tstSavedSearchUrl+"/mine", tstRenderJson(tstBuildValidSavedSearch("")), tstValidUserToken(t, 101))
+
+	docs.Then("then the request is denied as unauthorized (403) and the correct error is returned")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+}
+
+func TestSavedSearch_DenyAnonymous(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.When("when an anonymous user attempts to list the saved searches")
+	response := tstPerformGet(tstSavedSearchUrl, tstNoToken())
+
+	docs.Then("then the request is denied as unauthenticated (401) and the correct error is returned")
+	tstRequireErrorResponse(t, response, http.StatusUnauthorized, "auth.unauthorized", "you must be logged in for this operation")
+}
+
+func TestSavedSearch_NotFound(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.When("when an admin runs a search that does not exist")
+	response := tstPerformPostNoBody(tstSavedSearchUrl+"/unknown/run", tstValidAdminToken(t))
+
+	docs.Then("then the request fails with the correct error")
+	tstRequireErrorResponse(t, response, http.StatusNotFound, "search.name.notfound", url.Values{})
+}
+
+func TestSavedSearch_InvalidName(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.When("when an admin attempts to save a search with an invalid name")
+	response := tstPerformPut(tstSavedSearchUrl+"/Not-Valid", tstRenderJson(tstBuildValidSavedSearch("")), tstValidAdminToken(t))
+
+	docs.Then("then the request fails with the correct error")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "search.name.invalid", url.Values{
+		"name": {"must consist of lowercase letters, digits, - and _, and be at most 80 characters long"},
+	})
+}
+
+func TestSavedSearch_InvalidData(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.When("when an admin attempts to save a search with invalid data")
+	search := savedsearch.SavedSearchDto{
+		Name: "other",
+		Criteria: attendee.AttendeeSearchCriteria{
+			Cursor: "abc",
+		},
+	}
+	response := tstPerformPut(tstSavedSearchUrl+"/mine", tstRenderJson(search), tstValidAdminToken(t))
+
+	docs.Then("then the request fails with the correct error")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "search.data.invalid", url.Values{
+		"name": {"name field must be empty or match the name in the path"},
+		"criteria": {
+			"criteria must contain at least one entry in match_any, or the search never finds anybody",
+			"criteria must not contain a cursor, pass it when running the search instead",
+		},
+	})
+}
+
+// --- helpers ---
+
+func tstBuildValidSavedSearch(permissions string) savedsearch.SavedSearchDto {
+	return savedsearch.SavedSearchDto{
+		Description: "all approved attendees",
+		Permissions: permissions,
+		Criteria: attendee.AttendeeSearchCriteria{
+			MatchAny: []attendee.AttendeeSearchSingleCriterion{{Status: []status.Status{status.Approved}}},
+		},
+	}
+}
+
+func tstCreateSavedSearch(t *testing.T, name string, search savedsearch.SavedSearchDto) {
+	response := tstPerformPut(tstSavedSearchUrl+"/"+name, tstRenderJson(search), tstValidAdminToken(t))
+	require.Equal(t, http.StatusCreated, response.status)
+}
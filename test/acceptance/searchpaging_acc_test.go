@@ -0,0 +1,176 @@
+package acceptance
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/stretchr/testify/require"
+)
+
+// -----------------------------------------------------
+// acceptance tests for paged and streamed attendee search
+// -----------------------------------------------------
+
+func TestSearchPaging_AllPages(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given three existing attendees, two of them with the same nickname")
+	_, _ = tstRegisterAttendee(t, "paging1a-")
+	_, _ = tstBulkRegister(t, "paging1b-", tstValidUserToken(t, 101), "AardvarkZebra")
+	_, _ = tstBulkRegister(t, "paging1c-", tstValidUserToken(t, 102), "")
+
+	docs.When("when an admin searches for attendees sorted by nickname, two at a time")
+	search := attendee.AttendeeSearchCriteria{
+		MatchAny: []attendee.AttendeeSearchSingleCriterion{
+			{},
+		},
+		FillFields: []string{"nickname"},
+		SortBy:     "nickname",
+		NumResults: 2,
+	}
+	page1 := tstSearchPage(t, search)
+
+	docs.Then("then the first page contains two attendees, the total, and a cursor for the next page")
+	require.Equal(t, []uint{2, 1}, tstSearchResultIds(page1))
+	require.Equal(t, int64(3), *page1.Total)
+	require.NotEmpty(t, page1.Next)
+
+	docs.Then("and the second page contains the remaining attendee, and no cursor")
+	search.Cursor = page1.Next
+	page2 := tstSearchPage(t, search)
+	require.Equal(t, []uint{3}, tstSearchResultIds(page2))
+	require.Equal(t, int64(3), *page2.Total)
+	require.Empty(t, page2.Next)
+}
+
+func TestSearchPaging_UnpagedHasNoTotal(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee")
+	_, _ = tstRegisterAttendee(t, "paging2-")
+
+	docs.When("when an admin searches for attendees without asking for pages")
+	search := attendee.AttendeeSearchCriteria{
+		MatchAny: []attendee.AttendeeSearchSingleCriterion{
+			{},
+		},
+	}
+	result := tstSearchPage(t, search)
+
+	docs.Then("then all attendees are returned, without total or cursor")
+	require.Equal(t, []uint{1}, tstSearchResultIds(result))
+	require.Nil(t, result.Total)
+	require.Empty(t, result.Next)
+}
+
+func TestSearchPaging_InvalidCursor(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.When("when an admin continues a search with a cursor that was not issued by the service")
+	search := attendee.AttendeeSearchCriteria{
+		MatchAny: []attendee.AttendeeSearchSingleCriterion{
+			{},
+		},
+		NumResults: 2,
+		Cursor:     "not a cursor",
+	}
+	response := tstPerformPost("/api/rest/v1/attendees/find", tstRenderJson(search), tstValidAdminToken(t))
+
+	docs.Then("then the request fails with the appropriate error")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "search.cursor.invalid", url.Values{
+		"cursor": {"invalid search cursor: malformed cursor"},
+	})
+}
+
+func TestSearchPaging_CursorSortMismatch(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given two existing attendees, and a cursor from a search sorted by nickname")
+	_, _ = tstRegisterAttendee(t, "paging4a-")
+	_, _ = tstBulkRegister(t, "paging4b-", tstValidUserToken(t, 101), "")
+	search := attendee.AttendeeSearchCriteria{
+		MatchAny: []attendee.AttendeeSearchSingleCriterion{
+			{},
+		},
+		SortBy:     "nickname",
+		NumResults: 1,
+	}
+	page1 := tstSearchPage(t, search)
+	require.NotEmpty(t, page1.Next)
+
+	docs.When("when an admin continues the search, but sorted by name")
+	search.Cursor = page1.Next
+	search.SortBy = "name"
+	response := tstPerformPost("/api/rest/v1/attendees/find", tstRenderJson(search), tstValidAdminToken(t))
+
+	docs.Then("then the request fails with the appropriate error")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "search.cursor.invalid", url.Values{
+		"cursor": {"invalid search cursor: cursor does not match sort_by and sort_order"},
+	})
+}
+
+func TestSearchStream_Ndjson(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given three existing attendees")
+	_, _ = tstRegisterAttendee(t, "stream1a-")
+	_, _ = tstBulkRegister(t, "stream1b-", tstValidUserToken(t, 101), "")
+	_, _ = tstBulkRegister(t, "stream1c-", tstValidUserToken(t, 102), "")
+
+	docs.When("when an admin searches for attendees in descending order, asking for ndjson")
+	search := attendee.AttendeeSearchCriteria{
+		MatchAny: []attendee.AttendeeSearchSingleCriterion{
+			{},
+		},
+		FillFields: []string{"zip"},
+		SortOrder:  "descending",
+	}
+	response := tstPerformPostWithAccept("/api/rest/v1/attendees/find", tstRenderJson(search), tstValidAdminToken(t), media.ContentTypeApplicationNdjson)
+
+	docs.Then("then the request is successful and each attendee is returned as a separate line of json")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	require.Equal(t, media.ContentTypeApplicationNdjson, response.contentType)
+	zips := make([]string, 0)
+	scanner := bufio.NewScanner(strings.NewReader(response.body))
+	for scanner.Scan() {
+		result := attendee.AttendeeSearchResult{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &result))
+		zips = append(zips, *result.Zip)
+	}
+	require.Equal(t, []string{"stream1c-12345", "stream1b-12345", "stream1a-12345"}, zips)
+}
+
+// --- helpers ---
+
+func tstSearchPage(t *testing.T, search attendee.AttendeeSearchCriteria) attendee.AttendeeSearchResultList {
+	response := tstPerformPost("/api/rest/v1/attendees/find", tstRenderJson(search), tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	result := attendee.AttendeeSearchResultList{}
+	tstParseJson(response.body, &result)
+	return result
+}
+
+func tstSearchResultIds(result attendee.AttendeeSearchResultList) []uint {
+	ids := make([]uint, 0)
+	for _, a := range result.Attendees {
+		ids = append(ids, a.Id)
+	}
+	return ids
+}
@@ -8,9 +8,11 @@ import (
 	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/mailservice"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/webhookservice"
 	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
 	"github.com/eurofurence/reg-attendee-service/internal/web/app"
 	"net/http/httptest"
+	"os"
 	"time"
 )
 
@@ -21,10 +23,21 @@ var (
 	paymentMock paymentservice.Mock
 	mailMock    mailservice.Mock
 	authMock    authservice.Mock
+	webhookMock webhookservice.Mock
+	attSrv      attendeesrv.AttendeeService
 )
 
 const tstDefaultConfigFile = "../../test/testconfig-base.yaml"
 
+// the date the attendee service believes it is, tests may change it, it is reset on shutdown
+const tstDefaultToday = "2022-12-08"
+
+var tstToday = tstDefaultToday
+
+// set this environment variable to sqlite to run the acceptance tests against a real sql database
+// (an in-memory sqlite database) instead of the simulated inmemory database
+const tstEnvDatabase = "REG_TEST_DATABASE"
+
 func tstSetupConfigIrrelevant() {
 	tstSetup(true, false, true)
 }
@@ -84,6 +97,7 @@ func tstSetup(needLogin bool, staffReg bool, afterTarget bool) {
 	mailMock = mailservice.CreateMock()
 	authMock = authservice.CreateMock()
 	authMock.Enable()
+	webhookMock = webhookservice.CreateMock()
 	tstSetupAuthMockResponses()
 	tstSetupDatabase()
 	tstSetupHttpTestServer()
@@ -95,9 +109,9 @@ func tstSetupConfig(configFilePath string) {
 }
 
 func tstSetupHttpTestServer() {
-	attSrv := attendeesrv.New()
+	attSrv = attendeesrv.New()
 	attSrv.(*attendeesrv.AttendeeServiceImplData).Now = func() time.Time {
-		t, _ := time.Parse(config.IsoDateFormat, "2022-12-08")
+		t, _ := time.Parse(config.IsoDateFormat, tstToday)
 		return t
 	}
 	router := app.CreateRouter(context.Background(), attSrv)
@@ -105,14 +119,27 @@ func tstSetupHttpTestServer() {
 }
 
 func tstSetupDatabase() {
+	if os.Getenv(tstEnvDatabase) == string(config.Sqlite) {
+		conf := config.Configuration()
+		conf.Database.Use = config.Sqlite
+		conf.Database.Database = ":memory:"
+	}
 	database.Open()
 	config.EnableTestingMigrateDatabase()
 	database.MigrateIfSwitchedOn()
 }
 
+// tstWaitForWebhooks waits until the webhooks triggered by earlier requests have been delivered.
+func tstWaitForWebhooks() {
+	attSrv.(*attendeesrv.AttendeeServiceImplData).WaitForWebhookDispatches()
+}
+
 func tstShutdown() {
 	ts.Close()
+	tstWaitForWebhooks()
 	database.Close()
 	paymentMock.Reset()
 	mailMock.Reset()
+	webhookMock.Reset()
+	tstToday = tstDefaultToday
 }
@@ -628,8 +628,8 @@ func TestStatusChange_Admin_Approved_Paid_WithGraceAmount(t *testing.T) {
 	tstStatusChange_Admin_Allow(t, testcase,
 		status.Approved, status.Paid,
 		[]paymentservice.Transaction{tstCreateTransaction(1, paymentservice.Payment, 25400)},
-		[]paymentservice.Transaction{},
-		[]mailservice.MailSendDto{tstNewStatusMailWithAmounts(testcase, status.Paid, 1, 255, false)},
+		[]paymentservice.Transaction{tstWriteOffDues(-100)},
+		[]mailservice.MailSendDto{tstNewStatusMailWithAmounts(testcase, status.Paid, 0, 254, false)},
 	)
 }
 
@@ -0,0 +1,273 @@
+package acceptance
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/history"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/transfer"
+	"github.com/stretchr/testify/require"
+)
+
+// ---------------------------------------------------
+// acceptance tests for transferring a registration
+// ---------------------------------------------------
+
+const tstTransferRecipientEmail = "recipient@example.com"
+
+func TestTransfer_Success(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee who offers their registration to someone else")
+	ownerToken := tstValidUserToken(t, 101)
+	loc, att := tstBulkRegister(t, "xfer1a-", ownerToken, "")
+	code := tstOfferTransfer(t, loc, ownerToken, tstTransferRecipientEmail)
+
+	docs.Then("and the pending offer can be read by the owner")
+	response := tstPerformGet(loc+"/transfer", ownerToken)
+	require.Equal(t, http.StatusOK, response.status)
+	offer := transfer.TransferDto{}
+	tstParseJson(response.body, &offer)
+	require.Equal(t, att.Id, offer.AttendeeId)
+	require.Equal(t, tstTransferRecipientEmail, offer.Email)
+	require.Equal(t, "2022-12-22T00:00:00Z", offer.ExpiresAt)
+
+	docs.When("when the recipient accepts the transfer with their personal data")
+	recipientToken := tstValidUserToken(t, 102)
+	response = tstPerformPost("/api/rest/v1/transfers/"+code+"/accept", tstRenderJson(tstBuildTransferRecipient("xfer1b-")), recipientToken)
+
+	docs.Then("then the request is successful and the registration now carries the recipient's data")
+	require.Equal(t, http.StatusOK, response.status)
+	actual := attendee.AttendeeDto{}
+	tstParseJson(response.body, &actual)
+	require.Equal(t, att.Id, actual.Id)
+	require.Equal(t, "WhiteTiger", actual.Nickname)
+	require.Equal(t, "Erika", actual.FirstName)
+	require.Equal(t, tstTransferRecipientEmail, actual.Email)
+	require.Equal(t, att.PackagesList, actual.PackagesList)
+	tstVerifyStatus(t, loc, status.New)
+
+	docs.Then("and the registration now belongs to the recipient")
+	require.Equal(t, http.StatusOK, tstPerformGet(loc, recipientToken).status)
+	require.Equal(t, http.StatusForbidden, tstPerformGet(loc, ownerToken).status)
+
+	docs.Then("and the change of owner has been historized")
+	response = tstPerformGet(loc+"/history", tstValidAdminToken(t))
+	historyList := history.HistoryList{}
+	tstParseJson(response.body, &historyList)
+	require.Contains(t, historyList.Entries[len(historyList.Entries)-1].Changes, history.FieldChange{Field: "Identity", OldValue: "101", NewValue: "102"})
+
+	docs.Then("and both parties have been informed")
+	mails := mailMock.Recording()
+	require.Equal(t, 3, len(mails))
+	require.Equal(t, "transfer-given", mails[1].CommonID)
+	require.Equal(t, []string{att.Email}, mails[1].To)
+	require.Equal(t, "transfer-received", mails[2].CommonID)
+	require.Equal(t, []string{tstTransferRecipientEmail}, mails[2].To)
+	require.Equal(t, "BlackCheetah", mails[2].Variables["previous_nickname"])
+
+	docs.Then("and the transfer cannot be accepted a second time")
+	response = tstPerformPost("/api/rest/v1/transfers/"+code+"/accept", tstRenderJson(tstBuildTransferRecipient("xfer1c-")), tstValidStaffToken(t, 202))
+	tstRequireErrorResponse(t, response, http.StatusConflict, "transfer.state.invalid", url.Values{
+		"details": {"this transfer has already been accepted or cancelled"},
+	})
+}
+
+func TestTransfer_Cancel(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee who has offered their registration to someone else")
+	ownerToken := tstValidUserToken(t, 101)
+	loc, _ := tstBulkRegister(t, "xfer2a-", ownerToken, "")
+	code := tstOfferTransfer(t, loc, ownerToken, tstTransferRecipientEmail)
+
+	docs.When("when they withdraw the offer")
+	response := tstPerformDelete(loc+"/transfer", ownerToken)
+
+	docs.Then("then the request is successful and the offer is gone")
+	require.Equal(t, http.StatusNoContent, response.status)
+	tstRequireErrorResponse(t, tstPerformGet(loc+"/transfer", ownerToken), http.StatusNotFound, "transfer.notfound", url.Values{})
+
+	docs.Then("and the recipient can no longer accept it")
+	response = tstPerformPost("/api/rest/v1/transfers/"+code+"/accept", tstRenderJson(tstBuildTransferRecipient("xfer2b-")), tstValidUserToken(t, 102))
+	tstRequireErrorResponse(t, response, http.StatusConflict, "transfer.state.invalid", url.Values{
+		"details": {"this transfer has already been accepted or cancelled"},
+	})
+}
+
+func TestTransfer_NewOfferReplacesOld(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee who has offered their registration twice")
+	ownerToken := tstValidUserToken(t, 101)
+	loc, _ := tstBulkRegister(t, "xfer3a-", ownerToken, "")
+	firstCode := tstOfferTransfer(t, loc, ownerToken, "first@example.com")
+	_ = tstOfferTransfer(t, loc, ownerToken, tstTransferRecipientEmail)
+
+	docs.When("when the first recipient attempts to accept")
+	first := tstBuildTransferRecipient("xfer3b-")
+	first.Email = "first@example.com"
+	response := tstPerformPost("/api/rest/v1/transfers/"+firstCode+"/accept", tstRenderJson(first), tstValidUserToken(t, 102))
+
+	docs.Then("then the request fails, because only the latest offer is valid")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "transfer.state.invalid", url.Values{
+		"details": {"this transfer has already been accepted or cancelled"},
+	})
+}
+
+func TestTransfer_BanMatch(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given a ban rule, and an attendee who offers their registration to someone else")
+	ban := tstBuildValidBanRule("xfer4-")
+	ban.NicknamePattern = "^banned"
+	require.Equal(t, http.StatusCreated, tstPerformPost("/api/rest/v1/bans", tstRenderJson(ban), tstValidAdminToken(t)).status)
+	ownerToken := tstValidUserToken(t, 101)
+	loc, _ := tstBulkRegister(t, "xfer4a-", ownerToken, "")
+	code := tstOfferTransfer(t, loc, ownerToken, tstTransferRecipientEmail)
+
+	docs.When("when a recipient who matches the ban rule accepts")
+	recipient := tstBuildTransferRecipient("xfer4b-")
+	recipient.Nickname = "BannedTiger"
+	response := tstPerformPost("/api/rest/v1/transfers/"+code+"/accept", tstRenderJson(recipient), tstValidUserToken(t, 102))
+
+	docs.Then("then the request fails with the correct error and the registration is unchanged")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "transfer.ban.match", url.Values{
+		"details": {"the recipient matches a ban rule, please contact the registration team"},
+	})
+	require.Equal(t, http.StatusOK, tstPerformGet(loc, ownerToken).status)
+}
+
+func TestTransfer_RecipientAlreadyRegistered(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee who offers their registration to someone who is registered already")
+	ownerToken := tstValidUserToken(t, 101)
+	loc, _ := tstBulkRegister(t, "xfer5a-", ownerToken, "")
+	recipientToken := tstValidUserToken(t, 102)
+	_, _ = tstBulkRegister(t, "xfer5b-", recipientToken, "OtherCheetah")
+	code := tstOfferTransfer(t, loc, ownerToken, tstTransferRecipientEmail)
+
+	docs.When("when the recipient accepts")
+	response := tstPerformPost("/api/rest/v1/transfers/"+code+"/accept", tstRenderJson(tstBuildTransferRecipient("xfer5c-")), recipientToken)
+
+	docs.Then("then the request fails with the correct error")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "transfer.recipient.invalid", url.Values{
+		"details": {"the recipient must not own this or any other registration"},
+	})
+}
+
+func TestTransfer_WrongEmail(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee who offers their registration to someone else")
+	ownerToken := tstValidUserToken(t, 101)
+	loc, _ := tstBulkRegister(t, "xfer6a-", ownerToken, "")
+	code := tstOfferTransfer(t, loc, ownerToken, tstTransferRecipientEmail)
+
+	docs.When("when the recipient accepts with a different email address")
+	recipient := tstBuildTransferRecipient("xfer6b-")
+	recipient.Email = "someone.else@example.com"
+	response := tstPerformPost("/api/rest/v1/transfers/"+code+"/accept", tstRenderJson(recipient), tstValidUserToken(t, 102))
+
+	docs.Then("then the request fails with the correct error")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "attendee.data.invalid", url.Values{
+		"email": {"must be the email address the transfer was offered to"},
+	})
+}
+
+func TestTransfer_UnknownCode(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.When("when someone attempts to accept a transfer that does not exist")
+	response := tstPerformPost("/api/rest/v1/transfers/nosuchcode/accept", tstRenderJson(tstBuildTransferRecipient("xfer7-")), tstValidUserToken(t, 102))
+
+	docs.Then("then the request fails with the correct error")
+	tstRequireErrorResponse(t, response, http.StatusNotFound, "transfer.notfound", url.Values{})
+}
+
+func TestTransfer_CancelledRegistration(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given a cancelled attendee")
+	loc, _ := tstRegisterAttendeeAndTransitionToStatus(t, "xfer8-", status.Cancelled)
+
+	docs.When("when an admin attempts to offer the registration to someone else")
+	response := tstPerformPost(loc+"/transfer", tstRenderJson(transfer.TransferOfferDto{Email: tstTransferRecipientEmail}), tstValidAdminToken(t))
+
+	docs.Then("then the request fails with the correct error")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "transfer.status.invalid", url.Values{
+		"details": {"cancelled or deleted registrations cannot be transferred"},
+	})
+}
+
+func TestTransfer_InvalidEmail(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee")
+	ownerToken := tstValidUserToken(t, 101)
+	loc, _ := tstBulkRegister(t, "xfer9-", ownerToken, "")
+
+	docs.When("when they offer their registration to an invalid email address")
+	response := tstPerformPost(loc+"/transfer", tstRenderJson(transfer.TransferOfferDto{Email: "not an email"}), ownerToken)
+
+	docs.Then("then the request fails with the correct error")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "transfer.data.invalid", url.Values{
+		"email": {"email field is not plausible, must match ^[^\\@\\s]+\\@[^\\@\\s]+$"},
+	})
+}
+
+func TestTransfer_DenyOtherUser(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee")
+	loc, _ := tstBulkRegister(t, "xfer10-", tstValidUserToken(t, 101), "")
+
+	docs.When("when another user attempts to offer their registration")
+	response := tstPerformPost(loc+"/transfer", tstRenderJson(transfer.TransferOfferDto{Email: tstTransferRecipientEmail}), tstValidUserToken(t, 102))
+
+	docs.Then("then the request is denied as unauthorized (403) and no offer is made")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized to access this data - the attempt has been logged")
+	require.Empty(t, mailMock.Recording())
+}
+
+// --- helpers ---
+
+// tstOfferTransfer offers the registration and returns the code from the mail sent to the recipient.
+func tstOfferTransfer(t *testing.T, loc string, token string, email string) string {
+	mailsBefore := len(mailMock.Recording())
+	response := tstPerformPost(loc+"/transfer", tstRenderJson(transfer.TransferOfferDto{Email: email}), token)
+	require.Equal(t, http.StatusCreated, response.status, "unexpected http response status")
+
+	mails := mailMock.Recording()
+	require.Equal(t, mailsBefore+1, len(mails))
+	offerMail := mails[len(mails)-1]
+	require.Equal(t, "transfer-offered", offerMail.CommonID)
+	require.Equal(t, []string{email}, offerMail.To)
+	code := offerMail.Variables["transfer_code"]
+	require.NotEmpty(t, code, fmt.Sprintf("no transfer code in mail %v", offerMail))
+	return code
+}
+
+func tstBuildTransferRecipient(testcase string) attendee.AttendeeDto {
+	dto := tstBuildValidAttendee(testcase)
+	dto.Nickname = "WhiteTiger"
+	dto.FirstName = "Erika"
+	dto.Email = tstTransferRecipientEmail
+	return dto
+}
@@ -132,6 +132,21 @@ func tstPerformPost(relativeUrlWithLeadingSlash string, requestBody string, toke
 	return tstWebResponseFromResponse(response)
 }
 
+func tstPerformPostWithAccept(relativeUrlWithLeadingSlash string, requestBody string, token string, accept string) tstWebResponse {
+	request, err := http.NewRequest(http.MethodPost, ts.URL+relativeUrlWithLeadingSlash, strings.NewReader(requestBody))
+	if err != nil {
+		log.Fatal(err)
+	}
+	tstAddAuth(request, token)
+	request.Header.Set(headers.ContentType, media.ContentTypeApplicationJson)
+	request.Header.Set(headers.Accept, accept)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return tstWebResponseFromResponse(response)
+}
+
 func tstPerformPostNoBody(relativeUrlWithLeadingSlash string, token string) tstWebResponse {
 	request, err := http.NewRequest(http.MethodPost, ts.URL+relativeUrlWithLeadingSlash, nil)
 	if err != nil {
@@ -178,6 +193,7 @@ func tstBuildValidAttendee(testcase string) attendee.AttendeeDto {
 		Pronouns:             "he/him",
 		SpokenLanguages:      "de,en",
 		RegistrationLanguage: "en-US",
+		Currency:             "EUR",
 		Flags:                "anon,hc,terms-accepted",
 		Packages:             packages, // ignored because PackagesList is set and takes precedence
 		PackagesList:         tstPackagesListFromPackages(packages),
@@ -315,6 +331,11 @@ func tstGuessDuesReason(amount int64, comment string) string {
 	return reason
 }
 
+func tstWriteOffDues(amount int64) paymentservice.Transaction {
+	reason := `{"packages_list":[{"name":"attendance","count":1},{"name":"room-none","count":1},{"name":"sponsor2","count":1},{"name":"stage","count":1}],"manual_dues":{},"write_off":true}`
+	return tstValidAttendeeDuesWithReason(amount, "write-off of remaining dues within payment tolerance", reason)
+}
+
 func tstValidAttendeeDuesWithReason(amount int64, comment string, reason string) paymentservice.Transaction {
 	return paymentservice.Transaction{
 		TransactionIdentifier: "",
@@ -350,6 +371,8 @@ func tstNewStatusMail(testcase string, newStatus status.Status, async bool) mail
 			"total_dues":                 "EUR 255.00",
 			"pending_payments":           "EUR 0.00",
 			"due_date":                   "",
+			"installment_due":            "EUR 0.00",
+			"age_based_prices":           "",
 			"regsys_url":                 "http://localhost:10000/register",
 
 			// room group variables
@@ -370,18 +393,22 @@ func tstNewStatusMail(testcase string, newStatus status.Status, async bool) mail
 	}
 	if newStatus == status.Approved {
 		result.Variables["remaining_dues"] = "EUR 255.00"
+		result.Variables["installment_due"] = "EUR 255.00"
 		result.Variables["due_date"] = "22.12.2022"
 	}
 	if newStatus == status.PartiallyPaid {
 		result.Variables["remaining_dues"] = "EUR 100.00"
+		result.Variables["installment_due"] = "EUR 100.00"
 		result.Variables["due_date"] = "22.12.2022"
 	}
 	if newStatus == status.Paid {
 		result.Variables["remaining_dues"] = "EUR 0.00"
+		result.Variables["installment_due"] = "EUR 0.00"
 	}
 	if newStatus == status.Cancelled {
 		result.Variables["total_dues"] = "EUR 0.00"
 		result.Variables["remaining_dues"] = "EUR 0.00"
+		result.Variables["installment_due"] = "EUR 0.00"
 		result.Variables["reason"] = testcase
 	}
 	if newStatus == status.Waiting {
@@ -400,6 +427,7 @@ func tstGuestMail(testcase string) mailservice.MailSendDto {
 func tstNewStatusMailWithAmounts(testcase string, newStatus status.Status, remaining float64, total float64, async bool) mailservice.MailSendDto {
 	result := tstNewStatusMail(testcase, newStatus, async)
 	result.Variables["remaining_dues"] = fmt.Sprintf("EUR %0.2f", remaining)
+	result.Variables["installment_due"] = fmt.Sprintf("EUR %0.2f", max(remaining, 0))
 	result.Variables["total_dues"] = fmt.Sprintf("EUR %0.2f", total)
 	if remaining > 0 {
 		result.Variables["due_date"] = "22.12.2022"
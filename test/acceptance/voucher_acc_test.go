@@ -0,0 +1,302 @@
+package acceptance
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/vouchers"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------
+// acceptance tests for the voucher api
+// ------------------------------------------
+
+const tstVoucherUrl = "/api/rest/v1/vouchers"
+
+func TestVoucher_AdminCrud(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.When("when an admin creates a voucher, using a lower case code")
+	voucher := tstBuildValidVoucher()
+	response := tstPerformPut(tstVoucherUrl+"/summer10", tstRenderJson(voucher), tstValidAdminToken(t))
+
+	docs.Then("then the voucher is created, with its code in upper case")
+	require.Equal(t, http.StatusCreated, response.status)
+	require.Equal(t, tstVoucherUrl+"/SUMMER10", response.location)
+
+	docs.Then("and it can be read back")
+	readResponse := tstPerformGet(tstVoucherUrl+"/SUMMER10", tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, readResponse.status)
+	actual := vouchers.VoucherDto{}
+	tstParseJson(readResponse.body, &actual)
+	voucher.Code = "SUMMER10"
+	require.Equal(t, voucher, actual)
+
+	docs.When("when the admin updates the voucher")
+	voucher.MaxUses = 20
+	updateResponse := tstPerformPut(tstVoucherUrl+"/SUMMER10", tstRenderJson(voucher), tstValidAdminToken(t))
+
+	docs.Then("then the update is successful and shows up in the list of vouchers")
+	require.Equal(t, http.StatusNoContent, updateResponse.status)
+	listResponse := tstPerformGet(tstVoucherUrl, tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, listResponse.status)
+	list := vouchers.VoucherList{}
+	tstParseJson(listResponse.body, &list)
+	require.Equal(t, vouchers.VoucherList{Vouchers: []vouchers.VoucherDto{voucher}}, list)
+
+	docs.When("when the admin deletes the voucher")
+	deleteResponse := tstPerformDelete(tstVoucherUrl+"/SUMMER10", tstValidAdminToken(t))
+
+	docs.Then("then it is gone")
+	require.Equal(t, http.StatusNoContent, deleteResponse.status)
+	rereadResponse := tstPerformGet(tstVoucherUrl+"/SUMMER10", tstValidAdminToken(t))
+	tstRequireErrorResponse(t, rereadResponse, http.StatusNotFound, "voucher.notfound", url.Values{})
+}
+
+func TestVoucher_CreateInvalid(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.When("when an admin attempts to create a voucher with invalid data")
+	voucher := vouchers.VoucherDto{
+		Code:        "OTHER",
+		Description: "",
+		Kind:        "percent",
+		Amount:      150,
+		Package:     "no-such-package",
+		MaxUses:     -1,
+		ValidUntil:  "tomorrow",
+	}
+	response := tstPerformPut(tstVoucherUrl+"/SUMMER10", tstRenderJson(voucher), tstValidAdminToken(t))
+
+	docs.Then("then the request fails with all validation errors")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "voucher.data.invalid", url.Values{
+		"code":        {"code field must be empty or match the code in the path"},
+		"description": {"description field must be at least 1 and at most 256 characters long"},
+		"amount":      {"percentage must be between 1 and 100"},
+		"package":     {"package field must be empty or one of the configured packages"},
+		"max_uses":    {"max_uses cannot be negative"},
+		"valid_until": {"valid_until field must be empty or an ISO date, as in 2024-06-01"},
+	})
+
+	docs.Then("and invalid codes are rejected")
+	response = tstPerformPut(tstVoucherUrl+"/no%20way", tstRenderJson(tstBuildValidVoucher()), tstValidAdminToken(t))
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "voucher.code.invalid", url.Values{"code": {"must consist of letters, digits, - and _, and be 3 to 32 characters long"}})
+}
+
+func TestVoucher_CreateDenyUser(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.When("when a normal user attempts to create a voucher")
+	response := tstPerformPut(tstVoucherUrl+"/SUMMER10", tstRenderJson(tstBuildValidVoucher()), tstValidUserToken(t, 101))
+
+	docs.Then("then the request is denied")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+}
+
+func TestVoucher_RedeemPercent(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an approved attendee and a voucher for 10 percent off")
+	loc, att := tstRegisterAttendeeAndTransitionToStatus(t, "vouch1-", status.Approved)
+	tstCreateVoucher(t, "TENOFF", tstBuildValidVoucher())
+
+	docs.When("when the attendee redeems the voucher")
+	response := tstPerformPost(loc+"/vouchers", tstRenderJson(vouchers.VoucherRedeem{Code: "tenoff"}), tstValidStaffToken(t, 1))
+
+	docs.Then("then the discount is booked as a separate dues transaction")
+	require.Equal(t, http.StatusNoContent, response.status)
+	require.Equal(t, 1, len(paymentMock.Recording()))
+	tx := paymentMock.Recording()[0]
+	require.Equal(t, int64(-2550), tx.Amount.GrossCent)
+	require.Equal(t, "discount for voucher TENOFF: Summer Discount", tx.Comment)
+	require.Contains(t, tx.Reason, `"voucher":"TENOFF"`)
+
+	docs.Then("and the attendee can see the voucher they redeemed")
+	listResponse := tstPerformGet(loc+"/vouchers", tstValidStaffToken(t, 1))
+	require.Equal(t, http.StatusOK, listResponse.status)
+	list := vouchers.RedeemedVoucherList{}
+	tstParseJson(listResponse.body, &list)
+	require.Equal(t, []vouchers.RedeemedVoucher{{Code: "TENOFF", Description: "Summer Discount", Kind: "percent", Amount: 10}}, list.Vouchers)
+
+	docs.Then("and the attendee is found when searching for the voucher")
+	criteria := attendee.AttendeeSearchCriteria{
+		MatchAny: []attendee.AttendeeSearchSingleCriterion{
+			{VoucherCodes: []string{"TENOFF"}},
+		},
+	}
+	findResponse := tstPerformPost("/api/rest/v1/attendees/find", tstRenderJson(criteria), tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, findResponse.status)
+	result := attendee.AttendeeSearchResultList{}
+	tstParseJson(findResponse.body, &result)
+	require.Equal(t, []uint{att.Id}, tstSearchResultIds(result))
+
+	docs.When("when the package the discount depends on changes")
+	tstAddPackages(&att, "boat-trip")
+	updateResponse := tstPerformPut(loc, tstRenderJson(att), tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, updateResponse.status)
+
+	docs.Then("then the discount is adjusted as well")
+	require.Equal(t, 3, len(paymentMock.Recording()))
+	require.Equal(t, int64(2000), paymentMock.Recording()[1].Amount.GrossCent)
+	require.Equal(t, int64(-200), paymentMock.Recording()[2].Amount.GrossCent)
+	require.Equal(t, "discount for voucher TENOFF: Summer Discount", paymentMock.Recording()[2].Comment)
+}
+
+func TestVoucher_RedeemDuringRegistration(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given a fixed amount voucher for the stage pass")
+	voucher := tstBuildValidVoucher()
+	voucher.Kind = "fixed"
+	voucher.Amount = 1000
+	voucher.Package = "stage"
+	tstCreateVoucher(t, "FREESTAGE", voucher)
+
+	docs.When("when an attendee registers with the voucher")
+	creationResponse := tstPerformPost("/api/rest/v1/attendees?voucher=FREESTAGE", tstRenderJson(tstBuildValidAttendee("vouch2-")), tstValidUserToken(t, 101))
+	require.Equal(t, http.StatusCreated, creationResponse.status)
+
+	docs.When("and the registration is approved")
+	body := status.StatusChangeDto{
+		Status:  status.Approved,
+		Comment: "vouch2-approve",
+	}
+	response := tstPerformPost(creationResponse.location+"/status", tstRenderJson(body), tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, response.status)
+
+	docs.Then("then the discount is booked after the dues, limited to the price of the stage pass")
+	require.Equal(t, 2, len(paymentMock.Recording()))
+	require.Equal(t, int64(25500), paymentMock.Recording()[0].Amount.GrossCent)
+	require.Equal(t, int64(-500), paymentMock.Recording()[1].Amount.GrossCent)
+	require.Equal(t, "discount for voucher FREESTAGE: Summer Discount", paymentMock.Recording()[1].Comment)
+}
+
+func TestVoucher_RedeemDuringRegistrationInvalid(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.When("when an attendee registers with a voucher that does not exist")
+	creationResponse := tstPerformPost("/api/rest/v1/attendees?voucher=NOSUCHCODE", tstRenderJson(tstBuildValidAttendee("vouch3-")), tstValidUserToken(t, 101))
+
+	docs.Then("then the registration fails")
+	tstRequireErrorResponse(t, creationResponse, http.StatusBadRequest, "attendee.voucher.invalid", url.Values{"voucher": {"there is no voucher with this code"}})
+
+	docs.Then("and no registration has been made")
+	response := tstPerformGet("/api/rest/v1/attendees", tstValidUserToken(t, 101))
+	require.Equal(t, http.StatusNotFound, response.status)
+}
+
+func TestVoucher_RedeemLimits(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given two attendees, and vouchers that are used up, expired, or for a package they did not book")
+	loc1, _ := tstRegisterAttendeeWithToken(t, "vouch4a-", tstValidUserToken(t, 101))
+	loc2, _ := tstRegisterAttendeeWithToken(t, "vouch4b-", tstValidUserToken(t, 102))
+	once := tstBuildValidVoucher()
+	once.MaxUses = 1
+	tstCreateVoucher(t, "ONCE", once)
+	expired := tstBuildValidVoucher()
+	expired.ValidUntil = "2022-12-07"
+	tstCreateVoucher(t, "EXPIRED", expired)
+	boat := tstBuildValidVoucher()
+	boat.Package = "boat-trip"
+	tstCreateVoucher(t, "BOAT", boat)
+	require.Equal(t, http.StatusNoContent, tstPerformPost(loc1+"/vouchers", tstRenderJson(vouchers.VoucherRedeem{Code: "ONCE"}), tstValidUserToken(t, 101)).status)
+
+	docs.Then("then redeeming the vouchers fails with the reason")
+	response := tstPerformPost(loc1+"/vouchers", tstRenderJson(vouchers.VoucherRedeem{Code: "ONCE"}), tstValidUserToken(t, 101))
+	tstRequireErrorResponse(t, response, http.StatusConflict, "voucher.redemption.exists", url.Values{"details": {"this voucher has already been redeemed for this registration"}})
+	response = tstPerformPost(loc2+"/vouchers", tstRenderJson(vouchers.VoucherRedeem{Code: "ONCE"}), tstValidUserToken(t, 102))
+	tstRequireErrorResponse(t, response, http.StatusConflict, "voucher.used.up", url.Values{"details": {"this voucher has reached its usage limit"}})
+	response = tstPerformPost(loc2+"/vouchers", tstRenderJson(vouchers.VoucherRedeem{Code: "EXPIRED"}), tstValidUserToken(t, 102))
+	tstRequireErrorResponse(t, response, http.StatusConflict, "voucher.expired", url.Values{"details": {"this voucher has expired"}})
+	response = tstPerformPost(loc2+"/vouchers", tstRenderJson(vouchers.VoucherRedeem{Code: "BOAT"}), tstValidUserToken(t, 102))
+	tstRequireErrorResponse(t, response, http.StatusConflict, "voucher.package.missing", url.Values{"details": {"this voucher is for a package that is not booked"}})
+	response = tstPerformPost(loc2+"/vouchers", tstRenderJson(vouchers.VoucherRedeem{Code: "NOSUCHCODE"}), tstValidUserToken(t, 102))
+	tstRequireErrorResponse(t, response, http.StatusNotFound, "voucher.notfound", url.Values{})
+
+	docs.Then("and attendees cannot redeem vouchers for other registrations")
+	response = tstPerformPost(loc2+"/vouchers", tstRenderJson(vouchers.VoucherRedeem{Code: "BOAT"}), tstValidUserToken(t, 101))
+	require.Equal(t, http.StatusForbidden, response.status)
+}
+
+func TestVoucher_RedeemFixedInOtherCurrency(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee who pays in GBP, and a voucher for a fixed amount in EUR")
+	token := tstValidUserToken(t, 101)
+	dto := tstBuildValidAttendee("vouch6-")
+	dto.Currency = "GBP"
+	creationResponse := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(dto), token)
+	require.Equal(t, http.StatusCreated, creationResponse.status, "unexpected http response status")
+	fixed := tstBuildValidVoucher()
+	fixed.Kind = "fixed"
+	fixed.Amount = 1000
+	fixed.Currency = "EUR"
+	tstCreateVoucher(t, "EURO10", fixed)
+
+	docs.When("when they attempt to redeem the voucher")
+	response := tstPerformPost(creationResponse.location+"/vouchers", tstRenderJson(vouchers.VoucherRedeem{Code: "EURO10"}), token)
+
+	docs.Then("then the redemption fails with the reason")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "voucher.currency.mismatch", url.Values{"details": {"this voucher is for a different currency"}})
+}
+
+func TestVoucher_AdminRemovesRedemption(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an approved attendee who has redeemed a voucher")
+	loc, att := tstRegisterAttendeeAndTransitionToStatus(t, "vouch5-", status.Approved)
+	tstCreateVoucher(t, "TENOFF", tstBuildValidVoucher())
+	require.Equal(t, http.StatusNoContent, tstPerformPost(loc+"/vouchers", tstRenderJson(vouchers.VoucherRedeem{Code: "TENOFF"}), tstValidStaffToken(t, 1)).status)
+
+	docs.Then("then the voucher cannot be deleted while it is in use")
+	response := tstPerformDelete(tstVoucherUrl+"/TENOFF", tstValidAdminToken(t))
+	tstRequireErrorResponse(t, response, http.StatusConflict, "voucher.in.use", url.Values{"details": {"this voucher has been redeemed, please remove the redemptions first"}})
+
+	docs.Then("and the attendee cannot remove it themselves")
+	response = tstPerformDelete(fmt.Sprintf("%s/vouchers/TENOFF", loc), tstValidStaffToken(t, 1))
+	require.Equal(t, http.StatusForbidden, response.status)
+
+	docs.When("when an admin removes the redemption")
+	response = tstPerformDelete(fmt.Sprintf("/api/rest/v1/attendees/%d/vouchers/tenoff", att.Id), tstValidAdminToken(t))
+
+	docs.Then("then the discount is taken back")
+	require.Equal(t, http.StatusNoContent, response.status)
+	require.Equal(t, 2, len(paymentMock.Recording()))
+	require.Equal(t, int64(2550), paymentMock.Recording()[1].Amount.GrossCent)
+	require.Equal(t, "discount for voucher TENOFF removed", paymentMock.Recording()[1].Comment)
+
+	docs.Then("and the voucher can now be deleted")
+	response = tstPerformDelete(tstVoucherUrl+"/TENOFF", tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, response.status)
+}
+
+// --- helpers ---
+
+func tstBuildValidVoucher() vouchers.VoucherDto {
+	return vouchers.VoucherDto{
+		Description: "Summer Discount",
+		Kind:        "percent",
+		Amount:      10,
+	}
+}
+
+func tstCreateVoucher(t *testing.T, code string, dto vouchers.VoucherDto) {
+	response := tstPerformPut(tstVoucherUrl+"/"+code, tstRenderJson(dto), tstValidAdminToken(t))
+	require.Equal(t, http.StatusCreated, response.status)
+}
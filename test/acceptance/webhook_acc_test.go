@@ -136,7 +136,7 @@ func TestPaymentsChangedWebhook_Approved_PartiallyPaid(t *testing.T) {
 
 func TestPaymentsChangedWebhook_Approved_Paid_WithGraceAmount(t *testing.T) {
 	testcase := "pc1a3-"
-	tstStatusChange_Webhook_Success(t, testcase,
+	tstStatusChange_Webhook_SuccessWithTransactions(t, testcase,
 		subcaseAdmOrApi,
 		subcaseAdmOrApiTokens,
 		status.Approved,
@@ -144,7 +144,8 @@ func TestPaymentsChangedWebhook_Approved_Paid_WithGraceAmount(t *testing.T) {
 			tstCreateTransaction(1, paymentservice.Payment, 25400),
 		},
 		status.Paid,
-		[]mailservice.MailSendDto{tstNewStatusMailWithAmounts(testcase, status.Paid, 1, 255, true)},
+		[]paymentservice.Transaction{tstWriteOffDues(-100)},
+		[]mailservice.MailSendDto{tstNewStatusMailWithAmounts(testcase, status.Paid, 0, 254, true)},
 	)
 }
 
@@ -192,6 +193,7 @@ func TestPaymentsChangedWebhook_PartiallyPaid_PartialRefund(t *testing.T) {
 	testcase := "pc2a2p-"
 	mail1 := tstNewStatusMail(testcase, status.PartiallyPaid, true)
 	mail1.Variables["remaining_dues"] = "EUR 155.00"
+	mail1.Variables["installment_due"] = "EUR 155.00"
 	tstStatusChange_Webhook_Success(t, testcase,
 		subcaseAdmOrApi,
 		subcaseAdmOrApiTokens,
@@ -336,11 +338,22 @@ func tstStatusChange_Webhook_Success(t *testing.T, testcase string, subcases []s
 	injectExtraTransactions []paymentservice.Transaction,
 	expectedNewStatus status.Status,
 	expectedMailRequests []mailservice.MailSendDto,
+) {
+	tstStatusChange_Webhook_SuccessWithTransactions(t, testcase, subcases, tokens,
+		oldStatus, injectExtraTransactions, expectedNewStatus, []paymentservice.Transaction{}, expectedMailRequests)
+}
+
+func tstStatusChange_Webhook_SuccessWithTransactions(t *testing.T, testcase string, subcases []string, tokens []string,
+	oldStatus status.Status,
+	injectExtraTransactions []paymentservice.Transaction,
+	expectedNewStatus status.Status,
+	expectedTransactions []paymentservice.Transaction,
+	expectedMailRequests []mailservice.MailSendDto,
 ) {
 	for i, subcase := range subcases {
 		t.Run(subcaseNameMap[subcase], func(t2 *testing.T) {
 			tstStatusChange_Webhook_Success_WithToken(t2, testcase+subcase, tokens[i],
-				oldStatus, injectExtraTransactions, expectedNewStatus, expectedMailRequests)
+				oldStatus, injectExtraTransactions, expectedNewStatus, expectedTransactions, expectedMailRequests)
 		})
 	}
 }
@@ -350,6 +363,7 @@ func tstStatusChange_Webhook_Success_WithToken(t *testing.T, testcase string,
 	oldStatus status.Status,
 	injectExtraTransactions []paymentservice.Transaction,
 	expectedNewStatus status.Status,
+	expectedTransactions []paymentservice.Transaction,
 	expectedMailRequests []mailservice.MailSendDto,
 ) {
 	tstSetup(false, false, true)
@@ -376,8 +390,12 @@ func tstStatusChange_Webhook_Success_WithToken(t *testing.T, testcase string,
 	docs.Then("and the resulting attendee status is " + string(expectedNewStatus) + " as expected")
 	tstVerifyStatus(t, loc, expectedNewStatus)
 
-	docs.Then("and no additional transactions were booked in the payment service")
-	require.Equal(t, 0, len(paymentMock.Recording()))
+	if len(expectedTransactions) == 0 {
+		docs.Then("and no additional transactions were booked in the payment service")
+	} else {
+		docs.Then("and the expected transactions were booked in the payment service")
+	}
+	tstRequireTransactions(t, expectedTransactions)
 
 	docs.Then("and the appropriate email messages were sent via the mail service")
 	tstRequireMailRequests(t, expectedMailRequests)
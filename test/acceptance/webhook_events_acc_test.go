@@ -0,0 +1,253 @@
+package acceptance
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/admin"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/webhook"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/webhookservice"
+	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------
+// acceptance tests for outgoing attendee webhooks
+// ------------------------------------------------
+
+const (
+	tstWebhookEverythingUrl    = "http://localhost:9100/hooks/everything"
+	tstWebhookEverythingSecret = "everything-secret-for-testing"
+	tstWebhookStatusOnlyUrl    = "http://localhost:9101/hooks/status"
+)
+
+func TestWebhooks_RegistrationIsDelivered(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.When("when a new attendee registers")
+	_, att := tstRegisterAttendee(t, "hook1-")
+
+	docs.Then("then exactly one event is sent, to the subscriber that receives all events")
+	tstWaitForWebhooks()
+	require.Equal(t, 1, len(webhookMock.Recording()))
+	request := webhookMock.Recording()[0]
+	require.Equal(t, tstWebhookEverythingUrl, request.Url)
+
+	docs.Then("and it carries the expected event without personal data")
+	event := webhook.Event{}
+	tstParseJson(string(request.Body), &event)
+	require.Equal(t, webhook.Event{
+		Event:      webhook.EventAttendeeRegistered,
+		Timestamp:  "2022-12-08T00:00:00Z",
+		AttendeeId: att.Id,
+	}, event)
+	require.Equal(t, webhook.EventAttendeeRegistered, request.Headers[attendeesrv.WebhookHeaderEvent])
+
+	docs.Then("and it is correctly signed")
+	tstRequireValidWebhookSignature(t, tstWebhookEverythingSecret, request)
+
+	docs.Then("and it is listed as sent in the delivery log")
+	deliveries := tstWebhookDeliveryList(t, fmt.Sprintf("?attendee_id=%d", att.Id))
+	require.Equal(t, 1, len(deliveries.Deliveries))
+	delivery := deliveries.Deliveries[0]
+	require.Equal(t, "everything", delivery.Subscriber)
+	require.Equal(t, webhook.EventAttendeeRegistered, delivery.Event)
+	require.Equal(t, "sent", delivery.State)
+	require.Equal(t, 1, delivery.Attempts)
+	require.Equal(t, http.StatusNoContent, delivery.LastStatus)
+	require.Equal(t, fmt.Sprintf("%d", delivery.Id), request.Headers[attendeesrv.WebhookHeaderDelivery])
+}
+
+func TestWebhooks_StatusChangeIsDeliveredToAllInterestedSubscribers(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an attendee in status new")
+	loc, att := tstRegisterAttendee(t, "hook2-")
+	tstWaitForWebhooks()
+	webhookMock.Reset()
+
+	docs.When("when an admin approves them")
+	body := status.StatusChangeDto{
+		Status:  status.Approved,
+		Comment: "hook2-",
+	}
+	response := tstPerformPost(loc+"/status", tstRenderJson(body), tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, response.status)
+
+	docs.Then("then both subscribers receive the status change event")
+	tstWaitForWebhooks()
+	require.Equal(t, 2, len(webhookMock.Recording()))
+	require.Equal(t, tstWebhookEverythingUrl, webhookMock.Recording()[0].Url)
+	require.Equal(t, tstWebhookStatusOnlyUrl, webhookMock.Recording()[1].Url)
+
+	docs.Then("and the event contains the old and the new status")
+	for _, request := range webhookMock.Recording() {
+		event := webhook.Event{}
+		tstParseJson(string(request.Body), &event)
+		require.Equal(t, webhook.Event{
+			Event:      webhook.EventAttendeeStatusChanged,
+			Timestamp:  "2022-12-08T00:00:00Z",
+			AttendeeId: att.Id,
+			Status:     status.Approved,
+			OldStatus:  status.New,
+		}, event)
+	}
+}
+
+func TestWebhooks_AdminInfoAndAdditionalInfoChanges(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee")
+	loc, att := tstRegisterAttendee(t, "hook3-")
+	tstWaitForWebhooks()
+	webhookMock.Reset()
+
+	docs.When("when an admin changes their admin info and writes an additional info area")
+	adminResponse := tstPerformPut(loc+"/admin", tstRenderJson(admin.AdminInfoDto{Permissions: "sponsordesk"}), tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, adminResponse.status)
+	addInfoResponse := tstPerformPost(loc+"/additional-info/myarea", `{"hook3":"something"}`, tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, addInfoResponse.status)
+
+	docs.Then("then only the subscriber that receives all events is notified, once for each change")
+	tstWaitForWebhooks()
+	require.Equal(t, 2, len(webhookMock.Recording()))
+
+	adminEvent := webhook.Event{}
+	tstParseJson(string(webhookMock.Recording()[0].Body), &adminEvent)
+	require.Equal(t, webhook.EventAttendeeAdminInfoChanged, adminEvent.Event)
+	require.Equal(t, att.Id, adminEvent.AttendeeId)
+
+	addInfoEvent := webhook.Event{}
+	tstParseJson(string(webhookMock.Recording()[1].Body), &addInfoEvent)
+	require.Equal(t, webhook.EventAttendeeAddInfoChanged, addInfoEvent.Event)
+	require.Equal(t, att.Id, addInfoEvent.AttendeeId)
+	require.Equal(t, "myarea", addInfoEvent.Area)
+}
+
+func TestWebhooks_FailingSubscriberKeepsEventsInOrder(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given one of the subscribers is unavailable")
+	webhookMock.SimulateError(tstWebhookEverythingUrl, webhookservice.DownstreamError)
+
+	docs.When("when an attendee registers and is then approved by an admin")
+	loc, att := tstRegisterAttendee(t, "hook4-")
+	body := status.StatusChangeDto{
+		Status:  status.Approved,
+		Comment: "hook4-",
+	}
+	response := tstPerformPost(loc+"/status", tstRenderJson(body), tstValidAdminToken(t))
+
+	docs.Then("then the requests are successful")
+	require.Equal(t, http.StatusNoContent, response.status)
+	tstVerifyStatus(t, loc, status.Approved)
+
+	docs.Then("and the other subscriber still receives its event")
+	tstWaitForWebhooks()
+	require.Equal(t, 1, len(webhookMock.Recording()))
+	require.Equal(t, tstWebhookStatusOnlyUrl, webhookMock.Recording()[0].Url)
+
+	docs.Then("and both events for the unavailable subscriber are pending, with the later one waiting for the earlier one")
+	pending := tstWebhookDeliveryList(t, "?subscriber=everything&state=pending")
+	require.Equal(t, 2, len(pending.Deliveries))
+
+	statusDelivery := pending.Deliveries[0]
+	require.Equal(t, webhook.EventAttendeeStatusChanged, statusDelivery.Event)
+	require.Equal(t, att.Id, statusDelivery.AttendeeId)
+	require.Equal(t, 0, statusDelivery.Attempts)
+
+	registrationDelivery := pending.Deliveries[1]
+	require.Equal(t, webhook.EventAttendeeRegistered, registrationDelivery.Event)
+	require.Equal(t, 1, registrationDelivery.Attempts)
+	require.Equal(t, http.StatusBadGateway, registrationDelivery.LastStatus)
+	require.Equal(t, webhookservice.DownstreamError.Error(), registrationDelivery.LastError)
+	require.Equal(t, "2022-12-08T00:00:30Z", registrationDelivery.NextAttempt)
+}
+
+func TestWebhooks_HangingSubscriberDoesNotHoldUpRequests(t *testing.T) {
+	if os.Getenv(tstEnvDatabase) != string(config.Sqlite) {
+		t.Skip("the in-memory database delivers webhooks right away, because it is not safe for concurrent use")
+	}
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given one of the subscribers does not respond")
+	release := webhookMock.SimulateHanging(tstWebhookEverythingUrl)
+
+	docs.When("when an attendee registers")
+	_, att := tstRegisterAttendee(t, "hook5-")
+
+	docs.Then("then the registration is successful without waiting for the subscriber")
+	require.NotZero(t, att.Id)
+
+	docs.Then("and the event is delivered once the subscriber responds")
+	release()
+	tstWaitForWebhooks()
+	require.Equal(t, 1, len(webhookMock.Recording()))
+	event := webhook.Event{}
+	tstParseJson(string(webhookMock.Recording()[0].Body), &event)
+	require.Equal(t, webhook.EventAttendeeRegistered, event.Event)
+	require.Equal(t, att.Id, event.AttendeeId)
+}
+
+func TestWebhooks_DeliveryLogDenyUser(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given a regular user")
+	token := tstValidUserToken(t, 101)
+
+	docs.When("when they attempt to read the webhook delivery log")
+	response := tstPerformGet("/api/rest/v1/webhooks/deliveries", token)
+
+	docs.Then("then the request is denied as unauthorized (403) and the correct error is returned")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+}
+
+func TestWebhooks_DeliveryLogInvalidParameters(t *testing.T) {
+	tstSetup(false, false, true)
+	defer tstShutdown()
+
+	docs.Given("given an admin")
+	token := tstValidAdminToken(t)
+
+	docs.When("when they attempt to read the webhook delivery log with invalid filters")
+	stateResponse := tstPerformGet("/api/rest/v1/webhooks/deliveries?state=exploded", token)
+	subscriberResponse := tstPerformGet("/api/rest/v1/webhooks/deliveries?subscriber=unknown", token)
+	limitResponse := tstPerformGet("/api/rest/v1/webhooks/deliveries?limit=0", token)
+
+	docs.Then("then the requests fail with the correct errors")
+	tstRequireErrorResponse(t, stateResponse, http.StatusBadRequest, "webhook.param.invalid", url.Values{"state": {"must be one of pending, sent, failed"}})
+	tstRequireErrorResponse(t, subscriberResponse, http.StatusBadRequest, "webhook.param.invalid", url.Values{"subscriber": {"no such webhook subscriber"}})
+	tstRequireErrorResponse(t, limitResponse, http.StatusBadRequest, "webhook.param.invalid", url.Values{"limit": {"must be an integer between 1 and 1000"}})
+}
+
+// --- helpers ---
+
+func tstRequireValidWebhookSignature(t *testing.T, secret string, request webhookservice.DeliveryRequest) {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(request.Headers[attendeesrv.WebhookHeaderTimestamp] + "."))
+	mac.Write(request.Body)
+	require.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), request.Headers[attendeesrv.WebhookHeaderSignature])
+}
+
+func tstWebhookDeliveryList(t *testing.T, query string) webhook.DeliveryList {
+	response := tstPerformGet("/api/rest/v1/webhooks/deliveries"+query, tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, response.status)
+	result := webhook.DeliveryList{}
+	tstParseJson(response.body, &result)
+	return result
+}